@@ -0,0 +1,141 @@
+package bitmap
+
+// Bits is implemented by all encodings of bitmap
+type Bits interface {
+	BitSize() int64
+	SetBit(offset int64, val byte) byte
+	GetBit(offset int64) byte
+	Count(start int64, end int64) int64
+	CountBits(start int64, end int64) int64
+	Pos(bit byte, start int64, end int64) int64
+	ForEachBit(begin int64, end int64, consumer func(offset int64, val byte) bool)
+	ToBytes() []byte
+}
+
+// Encodings of Adaptive
+const (
+	EncodingRaw     = "raw"
+	EncodingRoaring = "roaring"
+)
+
+// Adaptive 根据稀疏程度在 BitMap 和 Roaring 之间自动转换的位图
+// 字符串不短于 threshold 字节且 Roaring 占用的内存不到字符串的一半时转换为 Roaring，
+// Roaring 占用的内存超过字符串长度时转换回 BitMap，两个条件之间的差距避免反复转换
+type Adaptive struct {
+	raw       *BitMap
+	roaring   *Roaring
+	threshold int64
+	// checkedSize 是上次检查是否转换为 Roaring 时字符串的长度，长度翻倍后才再次检查
+	checkedSize int64
+}
+
+// MakeAdaptive makes an empty bitmap, threshold <= 0 disables Roaring
+func MakeAdaptive(threshold int) *Adaptive {
+	return &Adaptive{
+		raw:       New(),
+		threshold: int64(threshold),
+	}
+}
+
+// AdaptiveFromBytes makes a bitmap from string without copying, the encoding is chosen by threshold
+func AdaptiveFromBytes(bytes []byte, threshold int) *Adaptive {
+	a := &Adaptive{
+		raw:       FromBytes(bytes),
+		threshold: int64(threshold),
+	}
+	a.tryCompress()
+	return a
+}
+
+// Encoding returns raw or roaring
+func (a *Adaptive) Encoding() string {
+	if a.roaring != nil {
+		return EncodingRoaring
+	}
+	return EncodingRaw
+}
+
+func (a *Adaptive) bits() Bits {
+	if a.roaring != nil {
+		return a.roaring
+	}
+	return a.raw
+}
+
+// tryCompress converts raw bitmap to Roaring if it is large and sparse
+func (a *Adaptive) tryCompress() {
+	size := int64(len(*a.raw))
+	if a.threshold <= 0 || size < a.threshold || size < 2*a.checkedSize {
+		return
+	}
+	a.checkedSize = size
+	roaring := RoaringFromBytes(*a.raw)
+	if roaring.MemSize()*2 < size {
+		a.roaring = roaring
+		a.raw = nil
+	}
+}
+
+// tryDecompress converts Roaring to raw bitmap if it uses more memory
+func (a *Adaptive) tryDecompress() {
+	if a.roaring.MemSize() <= a.roaring.size {
+		return
+	}
+	a.raw = FromBytes(a.roaring.ToBytes())
+	a.checkedSize = a.roaring.size
+	a.roaring = nil
+}
+
+// BitSize returns number of bits
+func (a *Adaptive) BitSize() int64 {
+	return a.bits().BitSize()
+}
+
+// SetBit sets the bit at offset to val (0 or 1) and returns the original bit, the bitmap grows if necessary
+func (a *Adaptive) SetBit(offset int64, val byte) byte {
+	old := a.bits().SetBit(offset, val)
+	if a.roaring != nil {
+		a.tryDecompress()
+	} else {
+		a.tryCompress()
+	}
+	return old
+}
+
+// GetBit returns the bit at offset, bits beyond the bitmap are 0
+func (a *Adaptive) GetBit(offset int64) byte {
+	return a.bits().GetBit(offset)
+}
+
+// Count returns number of set bits within bytes [start, end], the range is clamped to the bitmap
+func (a *Adaptive) Count(start int64, end int64) int64 {
+	return a.bits().Count(start, end)
+}
+
+// CountBits returns number of set bits within bits [start, end], the range is clamped to the bitmap
+func (a *Adaptive) CountBits(start int64, end int64) int64 {
+	return a.bits().CountBits(start, end)
+}
+
+// Pos returns offset of the first bit equals to bit (0 or 1) within bits [start, end], returns -1 if not found
+func (a *Adaptive) Pos(bit byte, start int64, end int64) int64 {
+	return a.bits().Pos(bit, start, end)
+}
+
+// ForEachBit visits bits within [begin, end), returns false in consumer to break
+func (a *Adaptive) ForEachBit(begin int64, end int64, consumer func(offset int64, val byte) bool) {
+	a.bits().ForEachBit(begin, end, consumer)
+}
+
+// ToBytes returns the string encoded bitmap, it is shared with raw encoding and copied from roaring encoding
+func (a *Adaptive) ToBytes() []byte {
+	return a.bits().ToBytes()
+}
+
+// MemSize returns approximate bytes used by the bitmap
+func (a *Adaptive) MemSize() int64 {
+	if a.roaring != nil {
+		return a.roaring.MemSize()
+	}
+	return int64(cap(*a.raw))
+}
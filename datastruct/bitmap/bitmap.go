@@ -0,0 +1,151 @@
+// Package bitmap 可变长的位图，以字符串的形式保存，用于实现 SETBIT、GETBIT、BITCOUNT、BITPOS 等命令
+// 与 redis 一致，每个字节的最高位是该字节的第0位，即 offset 为0的位是第一个字节的最高位
+package bitmap
+
+import "math/bits"
+
+// BitMap is a growable bitmap stored as the string encoding
+type BitMap []byte
+
+// New makes an empty BitMap
+func New() *BitMap {
+	b := BitMap(make([]byte, 0))
+	return &b
+}
+
+// FromBytes wraps bytes of a string as BitMap, without copying
+func FromBytes(bytes []byte) *BitMap {
+	b := BitMap(bytes)
+	return &b
+}
+
+// ToBytes returns the underlying string
+func (b *BitMap) ToBytes() []byte {
+	return *b
+}
+
+// BitSize returns number of bits
+func (b *BitMap) BitSize() int64 {
+	return int64(len(*b)) * 8
+}
+
+func toByteSize(bitSize int64) int64 {
+	return (bitSize + 7) / 8
+}
+
+// grow extends the bitmap with zero bytes to hold bitSize bits
+func (b *BitMap) grow(bitSize int64) {
+	byteSize := toByteSize(bitSize)
+	gap := byteSize - int64(len(*b))
+	if gap <= 0 {
+		return
+	}
+	*b = append(*b, make([]byte, gap)...)
+}
+
+// SetBit sets the bit at offset to val (0 or 1) and returns the original bit, the bitmap grows if necessary
+func (b *BitMap) SetBit(offset int64, val byte) byte {
+	b.grow(offset + 1)
+	byteIndex := offset / 8
+	mask := byte(1 << (7 - offset%8))
+	old := byte(0)
+	if (*b)[byteIndex]&mask != 0 {
+		old = 1
+	}
+	if val > 0 {
+		(*b)[byteIndex] |= mask
+	} else {
+		(*b)[byteIndex] &^= mask
+	}
+	return old
+}
+
+// GetBit returns the bit at offset, bits beyond the bitmap are 0
+func (b *BitMap) GetBit(offset int64) byte {
+	byteIndex := offset / 8
+	if byteIndex >= int64(len(*b)) {
+		return 0
+	}
+	return ((*b)[byteIndex] >> (7 - offset%8)) & 1
+}
+
+// clampRange 将 [start, end] 限制在 [0, size) 内，返回 ok 为 false 表示区间为空
+func clampRange(start int64, end int64, size int64) (int64, int64, bool) {
+	if start < 0 {
+		start = 0
+	}
+	if end >= size {
+		end = size - 1
+	}
+	return start, end, start <= end
+}
+
+// Count returns number of set bits within bytes [start, end], the range is clamped to the bitmap
+func (b *BitMap) Count(start int64, end int64) int64 {
+	start, end, ok := clampRange(start, end, int64(len(*b)))
+	if !ok {
+		return 0
+	}
+	count := 0
+	for _, c := range (*b)[start : end+1] {
+		count += bits.OnesCount8(c)
+	}
+	return int64(count)
+}
+
+// CountBits returns number of set bits within bits [start, end], the range is clamped to the bitmap
+func (b *BitMap) CountBits(start int64, end int64) int64 {
+	start, end, ok := clampRange(start, end, b.BitSize())
+	if !ok {
+		return 0
+	}
+	var count int64
+	for offset := start; offset <= end; {
+		// 整个字节都在区间内时按字节统计
+		if offset%8 == 0 && offset+7 <= end {
+			count += int64(bits.OnesCount8((*b)[offset/8]))
+			offset += 8
+			continue
+		}
+		count += int64(b.GetBit(offset))
+		offset++
+	}
+	return count
+}
+
+// Pos returns offset of the first bit equals to bit (0 or 1) within bits [start, end], returns -1 if not found
+// the range is clamped to the bitmap, bits beyond the bitmap are not searched
+func (b *BitMap) Pos(bit byte, start int64, end int64) int64 {
+	start, end, ok := clampRange(start, end, b.BitSize())
+	if !ok {
+		return -1
+	}
+	// 跳过全为1或全为0的字节
+	var skip byte
+	if bit == 0 {
+		skip = 0xff
+	}
+	for offset := start; offset <= end; {
+		if offset%8 == 0 && offset+7 <= end && (*b)[offset/8] == skip {
+			offset += 8
+			continue
+		}
+		if b.GetBit(offset) == bit {
+			return offset
+		}
+		offset++
+	}
+	return -1
+}
+
+// ForEachBit visits bits within [begin, end), returns false in consumer to break
+func (b *BitMap) ForEachBit(begin int64, end int64, consumer func(offset int64, val byte) bool) {
+	if end > b.BitSize() {
+		end = b.BitSize()
+	}
+	for offset := begin; offset < end; offset++ {
+		if !consumer(offset, b.GetBit(offset)) {
+			return
+		}
+	}
+}
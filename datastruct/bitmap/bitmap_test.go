@@ -0,0 +1,54 @@
+package bitmap
+
+import "testing"
+
+func TestBitMap(t *testing.T) {
+	b := New()
+	offsets := []int64{0, 7, 9, 100}
+	for _, offset := range offsets {
+		if old := b.SetBit(offset, 1); old != 0 {
+			t.Errorf("expect old bit 0 at %d", offset)
+		}
+	}
+	if b.BitSize() != 104 {
+		t.Errorf("expect 104 bits, actual %d", b.BitSize())
+	}
+	// the first bit is the most significant bit of the first byte, same as redis
+	if b.ToBytes()[0] != 0x81 || b.ToBytes()[1] != 0x40 {
+		t.Errorf("unexpected bytes %x", b.ToBytes()[:2])
+	}
+	for _, offset := range offsets {
+		if b.GetBit(offset) != 1 {
+			t.Errorf("expect bit 1 at %d", offset)
+		}
+	}
+	if b.GetBit(1000) != 0 {
+		t.Error("bits beyond bitmap should be 0")
+	}
+	if old := b.SetBit(7, 0); old != 1 {
+		t.Error("expect old bit 1")
+	}
+
+	if c := b.Count(0, -1); c != 0 {
+		t.Errorf("expect 0 for empty range, actual %d", c)
+	}
+	if c := b.Count(0, 100); c != 3 {
+		t.Errorf("expect 3 set bits, actual %d", c)
+	}
+	if c := b.CountBits(1, 99); c != 1 {
+		t.Errorf("expect 1 set bit within [1, 99], actual %d", c)
+	}
+	if pos := b.Pos(1, 1, 103); pos != 9 {
+		t.Errorf("expect first set bit 9, actual %d", pos)
+	}
+	if pos := b.Pos(0, 0, 103); pos != 1 {
+		t.Errorf("expect first clear bit 1, actual %d", pos)
+	}
+	if pos := b.Pos(1, 101, 103); pos != -1 {
+		t.Errorf("expect -1, actual %d", pos)
+	}
+	full := FromBytes([]byte{0xff, 0xff, 0xfe})
+	if pos := full.Pos(0, 0, 23); pos != 23 {
+		t.Errorf("expect first clear bit 23, actual %d", pos)
+	}
+}
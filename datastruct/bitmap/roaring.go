@@ -0,0 +1,422 @@
+package bitmap
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// Roaring 压缩位图，按 offset 的高位把位分到容器中，每个容器保存 2^16 个位
+// 置位数不超过 arrayMaxSize 的容器保存为有序的 uint16 数组，否则保存为 1024 个 uint64 组成的位图。
+// 与 BitMap 一样记录字符串的长度，对外表现与 BitMap 完全一致，ToBytes 得到相同的字符串
+
+const (
+	containerBits  = 1 << 16
+	containerWords = containerBits / 64
+	// arrayMaxSize 数组容器超过 4096 个元素时比位图容器(8KB)更大
+	arrayMaxSize = 4096
+	// containerOverhead 是每个容器的结构体和指针的大致字节数
+	containerOverhead = 48
+)
+
+type container struct {
+	key   uint64
+	array []uint16
+	words []uint64
+	card  int
+}
+
+func (c *container) contains(low uint16) bool {
+	if c.words != nil {
+		return c.words[low/64]&(1<<(low%64)) != 0
+	}
+	i := c.search(low)
+	return i < len(c.array) && c.array[i] == low
+}
+
+// search returns index of the first element in array not less than low
+func (c *container) search(low uint16) int {
+	return sort.Search(len(c.array), func(i int) bool {
+		return c.array[i] >= low
+	})
+}
+
+// add sets bit low, returns false if it has been set
+func (c *container) add(low uint16) bool {
+	if c.words != nil {
+		mask := uint64(1) << (low % 64)
+		if c.words[low/64]&mask != 0 {
+			return false
+		}
+		c.words[low/64] |= mask
+		c.card++
+		return true
+	}
+	i := c.search(low)
+	if i < len(c.array) && c.array[i] == low {
+		return false
+	}
+	c.array = append(c.array, 0)
+	copy(c.array[i+1:], c.array[i:])
+	c.array[i] = low
+	c.card++
+	if c.card > arrayMaxSize {
+		c.toWords()
+	}
+	return true
+}
+
+// remove clears bit low, returns false if it has not been set
+func (c *container) remove(low uint16) bool {
+	if c.words != nil {
+		mask := uint64(1) << (low % 64)
+		if c.words[low/64]&mask == 0 {
+			return false
+		}
+		c.words[low/64] &^= mask
+		c.card--
+		if c.card <= arrayMaxSize {
+			c.toArray()
+		}
+		return true
+	}
+	i := c.search(low)
+	if i >= len(c.array) || c.array[i] != low {
+		return false
+	}
+	c.array = append(c.array[:i], c.array[i+1:]...)
+	c.card--
+	return true
+}
+
+func (c *container) toWords() {
+	c.words = make([]uint64, containerWords)
+	for _, low := range c.array {
+		c.words[low/64] |= 1 << (low % 64)
+	}
+	c.array = nil
+}
+
+func (c *container) toArray() {
+	c.array = make([]uint16, 0, c.card)
+	for i, word := range c.words {
+		for word != 0 {
+			c.array = append(c.array, uint16(i*64+bits.TrailingZeros64(word)))
+			word &= word - 1
+		}
+	}
+	c.words = nil
+}
+
+// countRange returns number of set bits within [begin, end]
+func (c *container) countRange(begin uint16, end uint16) int {
+	if begin == 0 && end == containerBits-1 {
+		return c.card
+	}
+	if c.words == nil {
+		i := c.search(begin)
+		j := sort.Search(len(c.array), func(i int) bool {
+			return c.array[i] > end
+		})
+		return j - i
+	}
+	first, last := int(begin/64), int(end/64)
+	count := 0
+	for i := first; i <= last; i++ {
+		word := c.words[i]
+		if i == first {
+			word &= ^uint64(0) << (begin % 64)
+		}
+		if i == last {
+			word &= ^uint64(0) >> (63 - end%64)
+		}
+		count += bits.OnesCount64(word)
+	}
+	return count
+}
+
+// nextSet returns the first set bit not less than low
+func (c *container) nextSet(low uint16) (uint16, bool) {
+	if c.words == nil {
+		i := c.search(low)
+		if i < len(c.array) {
+			return c.array[i], true
+		}
+		return 0, false
+	}
+	i := int(low / 64)
+	word := c.words[i] & (^uint64(0) << (low % 64))
+	for {
+		if word != 0 {
+			return uint16(i*64 + bits.TrailingZeros64(word)), true
+		}
+		i++
+		if i >= containerWords {
+			return 0, false
+		}
+		word = c.words[i]
+	}
+}
+
+// nextClear returns the first clear bit not less than low
+func (c *container) nextClear(low uint16) (uint16, bool) {
+	if c.words == nil {
+		// 数组中从 low 开始连续的元素都已置位
+		i := c.search(low)
+		next := int(low)
+		for ; i < len(c.array) && int(c.array[i]) == next; i++ {
+			next++
+		}
+		if next >= containerBits {
+			return 0, false
+		}
+		return uint16(next), true
+	}
+	i := int(low / 64)
+	word := ^c.words[i] & (^uint64(0) << (low % 64))
+	for {
+		if word != 0 {
+			return uint16(i*64 + bits.TrailingZeros64(word)), true
+		}
+		i++
+		if i >= containerWords {
+			return 0, false
+		}
+		word = ^c.words[i]
+	}
+}
+
+func (c *container) memSize() int64 {
+	if c.words != nil {
+		return containerOverhead + containerWords*8
+	}
+	return containerOverhead + int64(cap(c.array))*2
+}
+
+// Roaring is a compressed bitmap for sparse bits
+type Roaring struct {
+	containers []*container
+	// size 是对应的字符串的字节数
+	size int64
+	// mem 是所有容器占用的字节数，在修改容器时更新
+	mem int64
+}
+
+// NewRoaring makes an empty Roaring
+func NewRoaring() *Roaring {
+	return &Roaring{}
+}
+
+// RoaringFromBytes makes a Roaring from string encoded bitmap
+func RoaringFromBytes(bytes []byte) *Roaring {
+	r := &Roaring{size: int64(len(bytes))}
+	for i, b := range bytes {
+		for b != 0 {
+			// 字节的最高位是第0位
+			j := bits.LeadingZeros8(b)
+			r.set(int64(i)*8 + int64(j))
+			b &^= 0x80 >> j
+		}
+	}
+	return r
+}
+
+func splitOffset(offset int64) (uint64, uint16) {
+	return uint64(offset) >> 16, uint16(offset)
+}
+
+// find returns index of the first container whose key is not less than key
+func (r *Roaring) find(key uint64) int {
+	return sort.Search(len(r.containers), func(i int) bool {
+		return r.containers[i].key >= key
+	})
+}
+
+// set sets bit at offset, returns whether it was clear
+func (r *Roaring) set(offset int64) bool {
+	key, low := splitOffset(offset)
+	i := r.find(key)
+	before := int64(0)
+	if i == len(r.containers) || r.containers[i].key != key {
+		r.containers = append(r.containers, nil)
+		copy(r.containers[i+1:], r.containers[i:])
+		r.containers[i] = &container{key: key}
+	} else {
+		before = r.containers[i].memSize()
+	}
+	c := r.containers[i]
+	added := c.add(low)
+	r.mem += c.memSize() - before
+	return added
+}
+
+// clear clears bit at offset, returns whether it was set
+func (r *Roaring) clear(offset int64) bool {
+	key, low := splitOffset(offset)
+	i := r.find(key)
+	if i == len(r.containers) || r.containers[i].key != key {
+		return false
+	}
+	c := r.containers[i]
+	before := c.memSize()
+	if !c.remove(low) {
+		return false
+	}
+	if c.card == 0 {
+		r.containers = append(r.containers[:i], r.containers[i+1:]...)
+		r.mem -= before
+	} else {
+		r.mem += c.memSize() - before
+	}
+	return true
+}
+
+// BitSize returns number of bits
+func (r *Roaring) BitSize() int64 {
+	return r.size * 8
+}
+
+// SetBit sets the bit at offset to val (0 or 1) and returns the original bit, the bitmap grows if necessary
+func (r *Roaring) SetBit(offset int64, val byte) byte {
+	if byteSize := toByteSize(offset + 1); byteSize > r.size {
+		r.size = byteSize
+	}
+	var changed bool
+	if val > 0 {
+		changed = r.set(offset)
+	} else {
+		changed = r.clear(offset)
+	}
+	// 修改成功说明原来的位与 val 不同
+	if changed == (val > 0) {
+		return 0
+	}
+	return 1
+}
+
+// GetBit returns the bit at offset, bits beyond the bitmap are 0
+func (r *Roaring) GetBit(offset int64) byte {
+	key, low := splitOffset(offset)
+	i := r.find(key)
+	if i < len(r.containers) && r.containers[i].key == key && r.containers[i].contains(low) {
+		return 1
+	}
+	return 0
+}
+
+// Cardinality returns number of set bits
+func (r *Roaring) Cardinality() int64 {
+	var n int64
+	for _, c := range r.containers {
+		n += int64(c.card)
+	}
+	return n
+}
+
+// Count returns number of set bits within bytes [start, end], the range is clamped to the bitmap
+func (r *Roaring) Count(start int64, end int64) int64 {
+	start, end, ok := clampRange(start, end, r.size)
+	if !ok {
+		return 0
+	}
+	return r.CountBits(start*8, end*8+7)
+}
+
+// CountBits returns number of set bits within bits [start, end], the range is clamped to the bitmap
+func (r *Roaring) CountBits(start int64, end int64) int64 {
+	start, end, ok := clampRange(start, end, r.BitSize())
+	if !ok {
+		return 0
+	}
+	startKey, startLow := splitOffset(start)
+	endKey, endLow := splitOffset(end)
+	var count int64
+	for i := r.find(startKey); i < len(r.containers) && r.containers[i].key <= endKey; i++ {
+		c := r.containers[i]
+		begin, last := uint16(0), uint16(containerBits-1)
+		if c.key == startKey {
+			begin = startLow
+		}
+		if c.key == endKey {
+			last = endLow
+		}
+		count += int64(c.countRange(begin, last))
+	}
+	return count
+}
+
+// Pos returns offset of the first bit equals to bit (0 or 1) within bits [start, end], returns -1 if not found
+// the range is clamped to the bitmap, bits beyond the bitmap are not searched
+func (r *Roaring) Pos(bit byte, start int64, end int64) int64 {
+	start, end, ok := clampRange(start, end, r.BitSize())
+	if !ok {
+		return -1
+	}
+	key, low := splitOffset(start)
+	var pos int64 = -1
+	if bit == 1 {
+		for i := r.find(key); i < len(r.containers); i++ {
+			c := r.containers[i]
+			if c.key > key {
+				low = 0
+			}
+			if next, ok := c.nextSet(low); ok {
+				pos = int64(c.key)<<16 | int64(next)
+				break
+			}
+		}
+	} else {
+		// 没有容器的区间全为0，candidate 是下一个可能为0的位
+		candidate := start
+		for i := r.find(key); i < len(r.containers); i++ {
+			c := r.containers[i]
+			if int64(c.key)<<16 > candidate {
+				break
+			}
+			if next, ok := c.nextClear(uint16(candidate)); ok {
+				candidate = int64(c.key)<<16 | int64(next)
+				break
+			}
+			candidate = int64(c.key+1) << 16
+		}
+		pos = candidate
+	}
+	if pos < 0 || pos > end {
+		return -1
+	}
+	return pos
+}
+
+// ForEachBit visits bits within [begin, end), returns false in consumer to break
+func (r *Roaring) ForEachBit(begin int64, end int64, consumer func(offset int64, val byte) bool) {
+	if end > r.BitSize() {
+		end = r.BitSize()
+	}
+	for offset := begin; offset < end; offset++ {
+		if !consumer(offset, r.GetBit(offset)) {
+			return
+		}
+	}
+}
+
+// ToBytes returns the string encoded bitmap
+func (r *Roaring) ToBytes() []byte {
+	bytes := make([]byte, r.size)
+	for _, c := range r.containers {
+		base := int64(c.key) << 16
+		low, ok := c.nextSet(0)
+		for ok {
+			offset := base + int64(low)
+			bytes[offset/8] |= 0x80 >> (offset % 8)
+			if low == containerBits-1 {
+				break
+			}
+			low, ok = c.nextSet(low + 1)
+		}
+	}
+	return bytes
+}
+
+// MemSize returns approximate bytes used by containers
+func (r *Roaring) MemSize() int64 {
+	return r.mem
+}
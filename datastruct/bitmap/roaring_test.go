@@ -0,0 +1,125 @@
+package bitmap
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// checkSame compares every query of b with the plain BitMap
+func checkSame(t *testing.T, expected *BitMap, b Bits, rnd *rand.Rand) {
+	t.Helper()
+	if !bytes.Equal(expected.ToBytes(), b.ToBytes()) {
+		t.Fatal("bytes mismatch")
+	}
+	size := expected.BitSize()
+	for i := 0; i < 50; i++ {
+		start := rnd.Int63n(size+16) - 8
+		end := start + rnd.Int63n(size/2+16)
+		if e, a := expected.CountBits(start, end), b.CountBits(start, end); e != a {
+			t.Fatalf("CountBits(%d, %d): expect %d, actual %d", start, end, e, a)
+		}
+		if e, a := expected.Count(start/8, end/8), b.Count(start/8, end/8); e != a {
+			t.Fatalf("Count(%d, %d): expect %d, actual %d", start/8, end/8, e, a)
+		}
+		for bit := byte(0); bit <= 1; bit++ {
+			if e, a := expected.Pos(bit, start, end), b.Pos(bit, start, end); e != a {
+				t.Fatalf("Pos(%d, %d, %d): expect %d, actual %d", bit, start, end, e, a)
+			}
+		}
+	}
+}
+
+func TestRoaring(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	expected := New()
+	r := NewRoaring()
+	// sparse bits spread over many containers, and a dense range which makes bitmap containers
+	for i := 0; i < 20000; i++ {
+		var offset int64
+		if i%2 == 0 {
+			offset = rnd.Int63n(1 << 22)
+		} else {
+			offset = 1<<20 + rnd.Int63n(1<<16)
+		}
+		val := byte(1)
+		if rnd.Intn(4) == 0 {
+			val = 0
+		}
+		if e, a := expected.SetBit(offset, val), r.SetBit(offset, val); e != a {
+			t.Fatalf("SetBit(%d, %d): expect old bit %d, actual %d", offset, val, e, a)
+		}
+	}
+	if r.BitSize() != expected.BitSize() || r.Cardinality() != expected.Count(0, expected.BitSize()) {
+		t.Fatal("size mismatch")
+	}
+	checkSame(t, expected, r, rnd)
+	// clear the dense range so that bitmap containers are converted back to arrays
+	for offset := int64(1 << 20); offset < 1<<20+1<<16; offset++ {
+		expected.SetBit(offset, 0)
+		r.SetBit(offset, 0)
+	}
+	checkSame(t, expected, r, rnd)
+	parsed := RoaringFromBytes(expected.ToBytes())
+	checkSame(t, expected, parsed, rnd)
+	if parsed.MemSize() != r.MemSize() {
+		t.Errorf("expect memory %d, actual %d", r.MemSize(), parsed.MemSize())
+	}
+}
+
+func TestRoaring_Pos(t *testing.T) {
+	r := NewRoaring()
+	// a full container, the first clear bit is in the next container
+	for offset := int64(0); offset < 1<<16; offset++ {
+		r.SetBit(offset, 1)
+	}
+	r.SetBit(1<<16, 1)
+	r.SetBit(1<<17+7, 0)
+	if pos := r.Pos(0, 0, -1+r.BitSize()); pos != 1<<16+1 {
+		t.Errorf("expect first clear bit %d, actual %d", 1<<16+1, pos)
+	}
+	if pos := r.Pos(1, 1<<16+1, r.BitSize()); pos != -1 {
+		t.Errorf("expect -1, actual %d", pos)
+	}
+	if pos := r.Pos(0, 10, 100); pos != -1 {
+		t.Errorf("expect -1, actual %d", pos)
+	}
+}
+
+func TestAdaptive(t *testing.T) {
+	a := MakeAdaptive(1024)
+	for i := int64(0); i < 100; i++ {
+		a.SetBit(i*100000, 1)
+	}
+	if a.Encoding() != EncodingRoaring || a.Count(0, -1+a.BitSize()/8) != 100 {
+		t.Fatalf("sparse bitmap should be roaring, actual %s", a.Encoding())
+	}
+	if a.MemSize()*2 >= a.BitSize()/8 {
+		t.Errorf("roaring should use less memory, actual %d", a.MemSize())
+	}
+	// fill a dense prefix until roaring uses more memory than the string
+	for i := int64(0); i < a.BitSize(); i += 3 {
+		a.SetBit(i, 1)
+		if a.Encoding() == EncodingRaw {
+			break
+		}
+	}
+	if a.Encoding() != EncodingRaw {
+		t.Fatal("dense bitmap should be raw")
+	}
+	if pos := a.Pos(1, 1, a.BitSize()); pos != 3 {
+		t.Errorf("expect 3, actual %d", pos)
+	}
+	small := AdaptiveFromBytes([]byte{0, 0, 0, 1}, 1024)
+	if small.Encoding() != EncodingRaw || small.Pos(1, 0, 31) != 31 {
+		t.Error("small bitmap should be raw")
+	}
+	if AdaptiveFromBytes(make([]byte, 4096), 1024).Encoding() != EncodingRoaring {
+		t.Error("empty large bitmap should be roaring")
+	}
+	disabled := MakeAdaptive(0)
+	disabled.SetBit(1<<20, 1)
+	if disabled.Encoding() != EncodingRaw {
+		t.Error("threshold 0 should disable roaring")
+	}
+}
@@ -0,0 +1,293 @@
+// Package bloom 可扩展的布隆过滤器，与 RedisBloom 的 BF.* 命令语义一致
+// 每个子过滤器的容量和误判率固定，写满后追加一个容量为 expansion 倍、误判率减半的子过滤器，
+// 整体的误判率不超过 errorRate 的两倍。设置 nonScaling 后写满时返回 ErrFull
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+
+	"Godis/datastruct/bitmap"
+)
+
+const (
+	DefaultErrorRate = 0.01
+	DefaultCapacity  = 100
+	DefaultExpansion = 2
+
+	// tighteningRatio 是相邻子过滤器的误判率之比
+	tighteningRatio = 0.5
+)
+
+// ErrFull is returned when adding into a full non-scaling filter
+var ErrFull = errors.New("ERR non scaling filter is full")
+
+// ErrInvalid is returned by Parse for malformed data
+var ErrInvalid = errors.New("ERR invalid bloom filter data")
+
+var magic = []byte("GBF1")
+
+// Filter is a scalable bloom filter
+type Filter struct {
+	errorRate  float64
+	expansion  uint32
+	nonScaling bool
+	subs       []*subFilter
+}
+
+type subFilter struct {
+	bits     *bitmap.BitMap
+	bitCount uint64
+	hashes   uint32
+	capacity uint64
+	count    uint64
+}
+
+// New makes a filter, capacity should be positive, errorRate should be in (0, 1) and expansion should be at least 1
+func New(capacity uint64, errorRate float64, expansion uint32, nonScaling bool) *Filter {
+	f := &Filter{
+		errorRate:  errorRate,
+		expansion:  expansion,
+		nonScaling: nonScaling,
+	}
+	f.subs = append(f.subs, makeSubFilter(capacity, errorRate))
+	return f
+}
+
+// MakeDefault makes a filter with default options, used when adding into a missing key
+func MakeDefault() *Filter {
+	return New(DefaultCapacity, DefaultErrorRate, DefaultExpansion, false)
+}
+
+func makeSubFilter(capacity uint64, errorRate float64) *subFilter {
+	// m = -n*ln(p)/(ln2)^2, k = -log2(p)
+	bitCount := uint64(math.Ceil(float64(capacity) * -math.Log(errorRate) / (math.Ln2 * math.Ln2)))
+	if bitCount < 8 {
+		bitCount = 8
+	}
+	hashes := uint32(math.Ceil(-math.Log2(errorRate)))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &subFilter{
+		bits:     bitmap.FromBytes(make([]byte, (bitCount+7)/8)),
+		bitCount: bitCount,
+		hashes:   hashes,
+		capacity: capacity,
+	}
+}
+
+// hashItem 使用 128 位的 FNV-1a 得到两个独立的哈希值，通过 h1 + i*h2 生成 k 个位置
+func hashItem(item []byte) (uint64, uint64) {
+	h := fnv.New128a()
+	_, _ = h.Write(item)
+	sum := h.Sum(nil)
+	h1 := binary.BigEndian.Uint64(sum[:8])
+	h2 := binary.BigEndian.Uint64(sum[8:])
+	// h2 为偶数时可能与 bitCount 有公因子，导致位置分布不均
+	return h1, h2 | 1
+}
+
+func (sub *subFilter) has(h1 uint64, h2 uint64) bool {
+	for i := uint64(0); i < uint64(sub.hashes); i++ {
+		if sub.bits.GetBit(int64((h1+i*h2)%sub.bitCount)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (sub *subFilter) add(h1 uint64, h2 uint64) {
+	for i := uint64(0); i < uint64(sub.hashes); i++ {
+		sub.bits.SetBit(int64((h1+i*h2)%sub.bitCount), 1)
+	}
+	sub.count++
+}
+
+// Exists returns whether item may have been added
+func (f *Filter) Exists(item []byte) bool {
+	h1, h2 := hashItem(item)
+	return f.exists(h1, h2)
+}
+
+func (f *Filter) exists(h1 uint64, h2 uint64) bool {
+	// 新的子过滤器更可能包含最近添加的元素
+	for i := len(f.subs) - 1; i >= 0; i-- {
+		if f.subs[i].has(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds item into the filter, returns false if the item may exist already
+func (f *Filter) Add(item []byte) (bool, error) {
+	h1, h2 := hashItem(item)
+	if f.exists(h1, h2) {
+		return false, nil
+	}
+	last := f.subs[len(f.subs)-1]
+	if last.count >= last.capacity {
+		if f.nonScaling {
+			return false, ErrFull
+		}
+		errorRate := f.errorRate * math.Pow(tighteningRatio, float64(len(f.subs)))
+		last = makeSubFilter(last.capacity*uint64(f.expansion), errorRate)
+		f.subs = append(f.subs, last)
+	}
+	last.add(h1, h2)
+	return true, nil
+}
+
+// Len returns number of items added
+func (f *Filter) Len() uint64 {
+	var n uint64
+	for _, sub := range f.subs {
+		n += sub.count
+	}
+	return n
+}
+
+// Capacity returns number of items the filter could hold before next expansion
+func (f *Filter) Capacity() uint64 {
+	var n uint64
+	for _, sub := range f.subs {
+		n += sub.capacity
+	}
+	return n
+}
+
+// Size returns bytes of all bit arrays
+func (f *Filter) Size() int {
+	size := 0
+	for _, sub := range f.subs {
+		size += len(sub.bits.ToBytes())
+	}
+	return size
+}
+
+// Filters returns number of sub filters
+func (f *Filter) Filters() int {
+	return len(f.subs)
+}
+
+// ErrorRate returns the error rate of the first sub filter
+func (f *Filter) ErrorRate() float64 {
+	return f.errorRate
+}
+
+// Expansion returns the growth factor of sub filters, 0 means non scaling
+func (f *Filter) Expansion() uint32 {
+	if f.nonScaling {
+		return 0
+	}
+	return f.expansion
+}
+
+// Bytes serializes the filter, used by AOF
+// 格式: magic | errorRate | expansion | nonScaling | 子过滤器数量 | 每个子过滤器的 capacity, count, hashes, bitCount 和位数组
+func (f *Filter) Bytes() []byte {
+	buf := make([]byte, 0, len(magic)+17+f.Size()+len(f.subs)*28)
+	buf = append(buf, magic...)
+	buf = appendUint64(buf, math.Float64bits(f.errorRate))
+	buf = appendUint32(buf, f.expansion)
+	if f.nonScaling {
+		buf = append(buf, 1)
+	} else {
+		buf = append(buf, 0)
+	}
+	buf = appendUint32(buf, uint32(len(f.subs)))
+	for _, sub := range f.subs {
+		buf = appendUint64(buf, sub.capacity)
+		buf = appendUint64(buf, sub.count)
+		buf = appendUint32(buf, sub.hashes)
+		buf = appendUint64(buf, sub.bitCount)
+		buf = append(buf, sub.bits.ToBytes()...)
+	}
+	return buf
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	return append(buf, b[:]...)
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	return append(buf, b[:]...)
+}
+
+// Parse deserializes data generated by Bytes
+func Parse(data []byte) (*Filter, error) {
+	r := &reader{data: data}
+	if string(r.next(len(magic))) != string(magic) {
+		return nil, ErrInvalid
+	}
+	f := &Filter{
+		errorRate: math.Float64frombits(r.uint64()),
+		expansion: r.uint32(),
+	}
+	flag := r.next(1)
+	f.nonScaling = len(flag) == 1 && flag[0] == 1
+	n := r.uint32()
+	if r.err || n == 0 || f.expansion == 0 || !(f.errorRate > 0 && f.errorRate < 1) {
+		return nil, ErrInvalid
+	}
+	for i := uint32(0); i < n; i++ {
+		sub := &subFilter{
+			capacity: r.uint64(),
+			count:    r.uint64(),
+			hashes:   r.uint32(),
+			bitCount: r.uint64(),
+		}
+		if r.err || sub.capacity == 0 || sub.hashes == 0 || sub.bitCount == 0 || sub.bitCount > uint64(len(r.data))*8 {
+			return nil, ErrInvalid
+		}
+		bits := r.next(int((sub.bitCount + 7) / 8))
+		if r.err {
+			return nil, ErrInvalid
+		}
+		sub.bits = bitmap.FromBytes(append([]byte(nil), bits...))
+		f.subs = append(f.subs, sub)
+	}
+	if len(r.data) > 0 {
+		return nil, ErrInvalid
+	}
+	return f, nil
+}
+
+// reader 读取到数据末尾后设置 err，之后的读取都返回零值
+type reader struct {
+	data []byte
+	err  bool
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err || len(r.data) < n {
+		r.err = true
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) uint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (r *reader) uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
@@ -0,0 +1,73 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	f := New(1000, 0.01, 2, false)
+	for i := 0; i < 5000; i++ {
+		f.Add([]byte("item" + strconv.Itoa(i)))
+	}
+	for i := 0; i < 5000; i++ {
+		if !f.Exists([]byte("item" + strconv.Itoa(i))) {
+			t.Fatalf("item%d should exist", i)
+		}
+	}
+	if f.Filters() != 3 || f.Capacity() != 7000 {
+		t.Errorf("expect 3 sub filters with capacity 7000, actual %d %d", f.Filters(), f.Capacity())
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if f.Exists([]byte("other" + strconv.Itoa(i))) {
+			falsePositives++
+		}
+	}
+	// compound error rate is bounded by 2 * 0.01
+	if falsePositives > 200 {
+		t.Errorf("too many false positives: %d", falsePositives)
+	}
+	if added, _ := f.Add([]byte("item0")); added {
+		t.Error("adding existed item should return false")
+	}
+}
+
+func TestFilter_NonScaling(t *testing.T) {
+	f := New(10, 0.001, 2, true)
+	var err error
+	for i := 0; i < 20 && err == nil; i++ {
+		_, err = f.Add([]byte(strconv.Itoa(i)))
+	}
+	if err != ErrFull {
+		t.Errorf("expect ErrFull, actual %v", err)
+	}
+	if f.Len() != 10 || f.Filters() != 1 {
+		t.Errorf("expect 10 items in 1 filter, actual %d in %d", f.Len(), f.Filters())
+	}
+}
+
+func TestParse(t *testing.T) {
+	f := New(100, 0.01, 4, false)
+	for i := 0; i < 300; i++ {
+		f.Add([]byte(strconv.Itoa(i)))
+	}
+	data := f.Bytes()
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(parsed.Bytes()) != string(data) || parsed.Len() != f.Len() || parsed.Expansion() != 4 {
+		t.Error("round trip mismatch")
+	}
+	for i := 0; i < 300; i++ {
+		if !parsed.Exists([]byte(strconv.Itoa(i))) {
+			t.Fatalf("%d should exist", i)
+		}
+	}
+	for _, bad := range [][]byte{nil, []byte("GBF1"), data[:len(data)-1], append(data, 0)} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("expect error for %d bytes", len(bad))
+		}
+	}
+}
@@ -0,0 +1,371 @@
+// Package cuckoo 可扩展的布谷鸟过滤器，与 RedisBloom 的 CF.* 命令语义一致
+// 每个元素保存为 8 位的指纹，可以放在两个候选桶中的任意一个，两个桶互为对方的 alt 桶，因此支持删除。
+// 两个候选桶都满时随机踢出一个指纹并放到它的另一个桶，踢出次数超过 maxIterations 则追加一个容量为 expansion 倍的子过滤器
+package cuckoo
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+
+	"Godis/lib/random"
+)
+
+const (
+	DefaultCapacity      = 1024
+	DefaultBucketSize    = 2
+	DefaultMaxIterations = 20
+	DefaultExpansion     = 1
+
+	emptySlot = 0
+)
+
+// ErrFull is returned when the filter could not expand
+var ErrFull = errors.New("ERR Filter is full")
+
+// ErrInvalid is returned by Parse for malformed data
+var ErrInvalid = errors.New("ERR invalid cuckoo filter data")
+
+var magic = []byte("GCF1")
+
+// Filter is a scalable cuckoo filter
+type Filter struct {
+	capacity      uint64
+	bucketSize    uint8
+	maxIterations uint16
+	expansion     uint16
+	inserted      uint64
+	deleted       uint64
+	subs          []*subFilter
+}
+
+type subFilter struct {
+	// numBuckets 是 2 的幂，alt 桶可以通过异或计算
+	numBuckets uint64
+	slots      []uint8
+}
+
+// New makes a filter, capacity and bucketSize and maxIterations should be positive
+// expansion 0 means the filter could not expand
+func New(capacity uint64, bucketSize uint8, maxIterations uint16, expansion uint16) *Filter {
+	f := &Filter{
+		capacity:      capacity,
+		bucketSize:    bucketSize,
+		maxIterations: maxIterations,
+		expansion:     expansion,
+	}
+	f.subs = append(f.subs, f.makeSubFilter(nextPowerOfTwo((capacity+uint64(bucketSize)-1)/uint64(bucketSize))))
+	return f
+}
+
+// MakeDefault makes a filter with default options, used when adding into a missing key
+func MakeDefault() *Filter {
+	return New(DefaultCapacity, DefaultBucketSize, DefaultMaxIterations, DefaultExpansion)
+}
+
+func nextPowerOfTwo(n uint64) uint64 {
+	p := uint64(1)
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+func (f *Filter) makeSubFilter(numBuckets uint64) *subFilter {
+	return &subFilter{
+		numBuckets: numBuckets,
+		slots:      make([]uint8, numBuckets*uint64(f.bucketSize)),
+	}
+}
+
+// hashItem returns 64 bits hash and non-zero fingerprint of item
+func hashItem(item []byte) (uint64, uint8) {
+	h := fnv.New64a()
+	_, _ = h.Write(item)
+	hash := h.Sum64()
+	return hash, uint8(hash%255 + 1)
+}
+
+func (sub *subFilter) index(hash uint64) uint64 {
+	return hash & (sub.numBuckets - 1)
+}
+
+// altIndex 对 i 和 altIndex(i) 调用 altIndex 互相得到对方
+func (sub *subFilter) altIndex(i uint64, fp uint8) uint64 {
+	return (i ^ (uint64(fp) * 0x5bd1e995)) & (sub.numBuckets - 1)
+}
+
+func (f *Filter) bucket(sub *subFilter, i uint64) []uint8 {
+	size := uint64(f.bucketSize)
+	return sub.slots[i*size : (i+1)*size]
+}
+
+// find returns the bucket and slot index of fp in sub, slot is -1 if not found
+func (f *Filter) find(sub *subFilter, hash uint64, fp uint8) ([]uint8, int) {
+	i1 := sub.index(hash)
+	for _, i := range [2]uint64{i1, sub.altIndex(i1, fp)} {
+		bucket := f.bucket(sub, i)
+		for slot, v := range bucket {
+			if v == fp {
+				return bucket, slot
+			}
+		}
+	}
+	return nil, -1
+}
+
+// insertFree puts fp into a free slot of its candidate buckets
+func (f *Filter) insertFree(sub *subFilter, hash uint64, fp uint8) bool {
+	bucket, slot := f.find(sub, hash, emptySlot)
+	if slot < 0 {
+		return false
+	}
+	bucket[slot] = fp
+	return true
+}
+
+type kick struct {
+	bucket []uint8
+	slot   int
+	fp     uint8
+}
+
+// insertKick 随机踢出指纹直到找到空位，失败时按相反的顺序恢复被踢出的指纹
+func (f *Filter) insertKick(sub *subFilter, hash uint64, fp uint8) bool {
+	i := sub.index(hash)
+	if random.Intn(2) == 1 {
+		i = sub.altIndex(i, fp)
+	}
+	history := make([]kick, 0, f.maxIterations)
+	for n := uint16(0); n < f.maxIterations; n++ {
+		bucket := f.bucket(sub, i)
+		slot := random.Intn(len(bucket))
+		history = append(history, kick{bucket: bucket, slot: slot, fp: bucket[slot]})
+		fp, bucket[slot] = bucket[slot], fp
+		i = sub.altIndex(i, fp)
+		bucket = f.bucket(sub, i)
+		for s, v := range bucket {
+			if v == emptySlot {
+				bucket[s] = fp
+				return true
+			}
+		}
+	}
+	for j := len(history) - 1; j >= 0; j-- {
+		history[j].bucket[history[j].slot] = history[j].fp
+	}
+	return false
+}
+
+// Add adds item into the filter, the same item could be added more than once
+func (f *Filter) Add(item []byte) error {
+	hash, fp := hashItem(item)
+	for _, sub := range f.subs {
+		if f.insertFree(sub, hash, fp) {
+			f.inserted++
+			return nil
+		}
+	}
+	last := f.subs[len(f.subs)-1]
+	if f.insertKick(last, hash, fp) {
+		f.inserted++
+		return nil
+	}
+	if f.expansion == 0 {
+		return ErrFull
+	}
+	last = f.makeSubFilter(last.numBuckets * nextPowerOfTwo(uint64(f.expansion)))
+	f.subs = append(f.subs, last)
+	f.insertFree(last, hash, fp)
+	f.inserted++
+	return nil
+}
+
+// AddNX adds item only if it does not exist, returns whether the item is added
+func (f *Filter) AddNX(item []byte) (bool, error) {
+	if f.Exists(item) {
+		return false, nil
+	}
+	if err := f.Add(item); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Exists returns whether item may have been added
+func (f *Filter) Exists(item []byte) bool {
+	hash, fp := hashItem(item)
+	for _, sub := range f.subs {
+		if _, slot := f.find(sub, hash, fp); slot >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the times item may have been added, it may be larger than the real value
+func (f *Filter) Count(item []byte) int {
+	hash, fp := hashItem(item)
+	count := 0
+	for _, sub := range f.subs {
+		i1 := sub.index(hash)
+		i2 := sub.altIndex(i1, fp)
+		for _, i := range [2]uint64{i1, i2} {
+			for _, v := range f.bucket(sub, i) {
+				if v == fp {
+					count++
+				}
+			}
+			if i1 == i2 {
+				break
+			}
+		}
+	}
+	return count
+}
+
+// Delete removes one occurrence of item, returns whether it is found
+// deleting item never added may remove another item with the same fingerprint
+func (f *Filter) Delete(item []byte) bool {
+	hash, fp := hashItem(item)
+	// 新的子过滤器中的指纹更可能是最近添加的
+	for i := len(f.subs) - 1; i >= 0; i-- {
+		if bucket, slot := f.find(f.subs[i], hash, fp); slot >= 0 {
+			bucket[slot] = emptySlot
+			f.deleted++
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns number of items in filter
+func (f *Filter) Len() uint64 {
+	return f.inserted - f.deleted
+}
+
+// Inserted returns number of items ever inserted
+func (f *Filter) Inserted() uint64 {
+	return f.inserted
+}
+
+// Deleted returns number of items ever deleted
+func (f *Filter) Deleted() uint64 {
+	return f.deleted
+}
+
+// Buckets returns number of buckets of all sub filters
+func (f *Filter) Buckets() uint64 {
+	var n uint64
+	for _, sub := range f.subs {
+		n += sub.numBuckets
+	}
+	return n
+}
+
+// Filters returns number of sub filters
+func (f *Filter) Filters() int {
+	return len(f.subs)
+}
+
+// Size returns bytes of all buckets
+func (f *Filter) Size() int {
+	size := 0
+	for _, sub := range f.subs {
+		size += len(sub.slots)
+	}
+	return size
+}
+
+// BucketSize returns number of fingerprints in each bucket
+func (f *Filter) BucketSize() uint8 {
+	return f.bucketSize
+}
+
+// MaxIterations returns max times of kicking before expanding
+func (f *Filter) MaxIterations() uint16 {
+	return f.maxIterations
+}
+
+// Expansion returns the growth factor of sub filters
+func (f *Filter) Expansion() uint16 {
+	return f.expansion
+}
+
+// Bytes serializes the filter, used by AOF
+// 格式: magic | capacity | bucketSize | maxIterations | expansion | inserted | deleted | 子过滤器数量 | 每个子过滤器的桶数和指纹
+func (f *Filter) Bytes() []byte {
+	buf := make([]byte, 0, len(magic)+33+f.Size()+len(f.subs)*8)
+	buf = append(buf, magic...)
+	buf = appendUint64(buf, f.capacity)
+	buf = append(buf, f.bucketSize)
+	buf = appendUint16(buf, f.maxIterations)
+	buf = appendUint16(buf, f.expansion)
+	buf = appendUint64(buf, f.inserted)
+	buf = appendUint64(buf, f.deleted)
+	buf = appendUint32(buf, uint32(len(f.subs)))
+	for _, sub := range f.subs {
+		buf = appendUint64(buf, sub.numBuckets)
+		buf = append(buf, sub.slots...)
+	}
+	return buf
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	return append(buf, b[:]...)
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	return append(buf, b[:]...)
+}
+
+func appendUint16(buf []byte, v uint16) []byte {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return append(buf, b[:]...)
+}
+
+// Parse deserializes data generated by Bytes
+func Parse(data []byte) (*Filter, error) {
+	if len(data) < len(magic)+33 || string(data[:len(magic)]) != string(magic) {
+		return nil, ErrInvalid
+	}
+	data = data[len(magic):]
+	f := &Filter{
+		capacity:      binary.BigEndian.Uint64(data),
+		bucketSize:    data[8],
+		maxIterations: binary.BigEndian.Uint16(data[9:]),
+		expansion:     binary.BigEndian.Uint16(data[11:]),
+		inserted:      binary.BigEndian.Uint64(data[13:]),
+		deleted:       binary.BigEndian.Uint64(data[21:]),
+	}
+	n := binary.BigEndian.Uint32(data[29:])
+	data = data[33:]
+	if f.bucketSize == 0 || f.maxIterations == 0 || n == 0 || f.deleted > f.inserted {
+		return nil, ErrInvalid
+	}
+	for i := uint32(0); i < n; i++ {
+		if len(data) < 8 {
+			return nil, ErrInvalid
+		}
+		numBuckets := binary.BigEndian.Uint64(data)
+		data = data[8:]
+		if numBuckets == 0 || numBuckets&(numBuckets-1) != 0 || numBuckets > uint64(len(data))/uint64(f.bucketSize) {
+			return nil, ErrInvalid
+		}
+		size := numBuckets * uint64(f.bucketSize)
+		f.subs = append(f.subs, &subFilter{
+			numBuckets: numBuckets,
+			slots:      append([]uint8(nil), data[:size]...),
+		})
+		data = data[size:]
+	}
+	if len(data) > 0 {
+		return nil, ErrInvalid
+	}
+	return f, nil
+}
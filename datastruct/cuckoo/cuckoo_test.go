@@ -0,0 +1,106 @@
+package cuckoo
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	f := New(1000, 2, 20, 1)
+	for i := 0; i < 3000; i++ {
+		if err := f.Add([]byte("item" + strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f.Filters() < 2 || f.Len() != 3000 {
+		t.Errorf("expect expanded filter with 3000 items, actual %d filters %d items", f.Filters(), f.Len())
+	}
+	for i := 0; i < 3000; i++ {
+		if !f.Exists([]byte("item" + strconv.Itoa(i))) {
+			t.Fatalf("item%d should exist", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if f.Exists([]byte("other" + strconv.Itoa(i))) {
+			falsePositives++
+		}
+	}
+	// each lookup checks 2 buckets of 2 fingerprints in each sub filter, error rate is about 4 * filters / 255
+	if falsePositives > 10000*4*f.Filters()/255 {
+		t.Errorf("too many false positives: %d", falsePositives)
+	}
+	for i := 0; i < 3000; i++ {
+		if !f.Delete([]byte("item" + strconv.Itoa(i))) {
+			t.Fatalf("failed to delete item%d", i)
+		}
+	}
+	if f.Len() != 0 {
+		t.Errorf("expect empty filter, actual %d items", f.Len())
+	}
+	for _, sub := range f.subs {
+		for _, v := range sub.slots {
+			if v != emptySlot {
+				t.Fatal("all slots should be empty after deleting every item")
+			}
+		}
+	}
+}
+
+func TestFilter_Full(t *testing.T) {
+	f := New(8, 2, 10, 0)
+	var err error
+	added := 0
+	for i := 0; i < 100 && err == nil; i++ {
+		if err = f.Add([]byte(strconv.Itoa(i))); err == nil {
+			added++
+		}
+	}
+	if err != ErrFull {
+		t.Fatalf("expect ErrFull, actual %v", err)
+	}
+	// failed insertion should not lose any fingerprint
+	for i := 0; i < added; i++ {
+		if !f.Exists([]byte(strconv.Itoa(i))) {
+			t.Errorf("%d should exist", i)
+		}
+	}
+}
+
+func TestFilter_Count(t *testing.T) {
+	f := MakeDefault()
+	for i := 0; i < 3; i++ {
+		_ = f.Add([]byte("a"))
+	}
+	if n := f.Count([]byte("a")); n != 3 {
+		t.Errorf("expect 3, actual %d", n)
+	}
+	if added, _ := f.AddNX([]byte("a")); added {
+		t.Error("AddNX should not add existed item")
+	}
+	f.Delete([]byte("a"))
+	if n := f.Count([]byte("a")); n != 2 {
+		t.Errorf("expect 2, actual %d", n)
+	}
+}
+
+func TestParse(t *testing.T) {
+	f := New(100, 4, 50, 2)
+	for i := 0; i < 500; i++ {
+		_ = f.Add([]byte(strconv.Itoa(i)))
+	}
+	f.Delete([]byte("0"))
+	data := f.Bytes()
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(parsed.Bytes()) != string(data) || parsed.Len() != 499 || parsed.Filters() != f.Filters() {
+		t.Error("round trip mismatch")
+	}
+	for _, bad := range [][]byte{nil, []byte("GCF1"), data[:len(data)-1], append(data, 0)} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("expect error for %d bytes", len(bad))
+		}
+	}
+}
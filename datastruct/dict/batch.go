@@ -0,0 +1,100 @@
+package dict
+
+// 批量操作先将key按分段分组，每个分段只加一次锁，减少多key命令反复加锁解锁的开销
+// 注意批量操作会自行加锁，不能在已经通过 RWLocks 持有锁时调用
+
+// BatchGet 批量读取，返回的values和exists与keys一一对应
+func (dict *Concurrent[K, V]) BatchGet(keys []K) (values []V, exists []bool) {
+	if dict == nil {
+		panic(any("dict is nil"))
+	}
+	values = make([]V, len(keys))
+	exists = make([]bool, len(keys))
+	dict.batch(keys, false, func(s *shard[K, V], i int) {
+		values[i], exists[i] = s.m[keys[i]]
+	})
+	return values, exists
+}
+
+// BatchPut 批量写入，vals与keys一一对应，返回新插入的键值对数
+func (dict *Concurrent[K, V]) BatchPut(keys []K, vals []V) (result int) {
+	if dict == nil {
+		panic(any("dict is nil"))
+	}
+	if len(keys) != len(vals) {
+		panic(any("keys and vals must have the same length"))
+	}
+	dict.batch(keys, true, func(s *shard[K, V], i int) {
+		if _, ok := s.m[keys[i]]; !ok {
+			dict.addCount()
+			result++
+		}
+		dict.beforeWrite(s)
+		s.m[keys[i]] = vals[i]
+	})
+	return result
+}
+
+// BatchRemove 批量删除，返回删除的键值对数
+func (dict *Concurrent[K, V]) BatchRemove(keys []K) (result int) {
+	if dict == nil {
+		panic(any("dict is nil"))
+	}
+	dict.batch(keys, true, func(s *shard[K, V], i int) {
+		if _, ok := s.m[keys[i]]; ok {
+			dict.beforeWrite(s)
+			delete(s.m, keys[i])
+			dict.decreaseCount()
+			result++
+		}
+	})
+	return result
+}
+
+// batch 将keys按分段分组，对每个分段加一次锁，在持有锁时对该分段中的每个key调用fn
+// fn的参数i为key在keys中的下标
+func (dict *Concurrent[K, V]) batch(keys []K, write bool, fn func(s *shard[K, V], i int)) {
+	hashes := make([]uint32, len(keys))
+	indices := make([]int, len(keys))
+	for i, key := range keys {
+		hashes[i] = dict.hash(key)
+		indices[i] = i
+	}
+	dict.loadTable().batch(dict, hashes, indices, write, fn)
+}
+
+func (t *shardTable[K, V]) batch(dict *Concurrent[K, V], hashes []uint32, indices []int, write bool, fn func(s *shard[K, V], i int)) {
+	groups := make(map[uint32][]int)
+	for _, i := range indices {
+		index := t.spread(hashes[i])
+		groups[index] = append(groups[index], i)
+	}
+	// 已迁移分段中的key到新表中重新分组
+	var migrated []int
+	// 分段表已通过 AtomicSwap 交给其他字典，这些key到字典当前的分段表中重新分组
+	var swapped []int
+	for index, group := range groups {
+		s := t.shards[index]
+		s.lock(write)
+		if s.migrated {
+			s.unlock(write)
+			migrated = append(migrated, group...)
+			continue
+		}
+		if t.owner != dict {
+			s.unlock(write)
+			swapped = append(swapped, group...)
+			continue
+		}
+		for _, i := range group {
+			fn(s, i)
+		}
+		s.unlock(write)
+	}
+	if len(migrated) > 0 {
+		t.next.batch(dict, hashes, migrated, write, fn)
+	}
+	if len(swapped) > 0 {
+		dict.loadTable().batch(dict, hashes, swapped, write, fn)
+	}
+}
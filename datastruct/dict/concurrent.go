@@ -1,30 +1,87 @@
 package dict
 
 import (
+	"Godis/datastruct/lock"
+	"Godis/lib/random"
+	"context"
 	"math"
-	"math/rand"
+	"math/bits"
 	"sort"
 	"sync"
 	"sync/atomic"
-	"time"
 )
 
-// ConcurrentDict 分段字典，一个Dict中有多个shard
-type ConcurrentDict struct {
-	// 分段表的切片
-	table []*shard
+// Concurrent 分段字典，一个Dict中有多个shard
+// 使用泛型保存键值，避免 interface{} 带来的装箱和类型断言开销
+// 当平均每个分段的键值对数超过 maxShardLoad 时，字典会在后台将分段数扩大一倍，
+// 与 Redis 的渐进式 rehash 类似，扩容期间新旧两张分段表同时存在，旧表中的分段被逐个迁移到新表，
+// 迁移某个分段时只持有该分段的锁，其余分段的读写不受影响
+type Concurrent[K comparable, V any] struct {
+	// 当前的分段表(*shardTable)，扩容期间为旧表，新表挂在旧表的 next 上
+	table atomic.Value
+	// 计算key的哈希值
+	hash func(K) uint32
 	// 记录Dict的键值对数
 	count int32
-	// 记录Dict的分段数
+	// 记录Dict创建时的分段数，Clear 时恢复为该值
 	shardCount int
+	// 是否正在扩容，保证同一时间只有一个扩容任务
+	rehashing int32
+
+	// 快照相关，见 snapshot.go
+	// snapshotMu 保证同一时间只有一个快照
+	snapshotMu sync.Mutex
+	// 是否存在快照，存在快照时不扩容
+	snapshotting int32
+	// 当前快照的 epoch，0 表示没有快照
+	snapshotEpoch uint64
+	// 上一个快照的 epoch，受 snapshotMu 保护
+	epoch uint64
+
+	// 字典的唯一编号，同时锁住两个字典的分段时(见 merge.go)按编号从小到大加锁，避免死锁
+	id uint64
+
+	// 分段锁的等待时间统计(*LockStats)，见 lockstats.go
+	lockStats atomic.Value
+}
+
+// dictIdGen 为每个分段字典分配唯一编号
+var dictIdGen uint64
+
+// ConcurrentDict 键为字符串、值为任意类型的分段字典，实现了Dict接口
+type ConcurrentDict = Concurrent[string, interface{}]
+
+// shardTable 分段表
+type shardTable[K comparable, V any] struct {
+	shards []*shard[K, V]
+	// 扩容的目标表，在旧表的第一个分段迁移前设置，之后不再改变
+	// 只有在持有分段锁并确认该分段已迁移后才可以读取
+	next *shardTable[K, V]
+	// 分段表所属的字典，AtomicSwap 会在持有表中全部分段写锁时修改
+	// 加锁后发现分段表已不属于当前字典，说明加锁前两个字典交换了数据，需要重新加载分段表
+	owner *Concurrent[K, V]
 }
 
 // shard 每个分段都有自己的mutex锁
-type shard struct {
-	m     map[string]interface{}
+type shard[K comparable, V any] struct {
+	m     map[K]V
 	mutex sync.RWMutex
+	// 分段中的数据已迁移到新表，受mutex保护
+	migrated bool
+	// 被快照冻结的 map 及对应快照的 epoch，受mutex保护
+	snap      map[K]V
+	snapEpoch uint64
+	// m 与 snap 是同一个 map，修改前需要先复制
+	shared bool
 }
 
+const (
+	// maxShardLoad 平均每个分段的键值对数超过该值时触发扩容
+	maxShardLoad = 16
+	// maxShardCount 分段数上限
+	maxShardCount = 1 << 20
+)
+
 // computeCapacity 对于输入的param，输出不小于param的最小2的幂次
 func computeCapacity(param int) (size int) {
 	if param <= 16 {
@@ -45,23 +102,44 @@ func computeCapacity(param int) (size int) {
 
 // MakeConcurrent 根据输入的分段数构造ConcurrentDict
 func MakeConcurrent(shardCount int) *ConcurrentDict {
+	return MakeTyped[string, interface{}](shardCount, loadStringHash())
+}
+
+// MakeConcurrentDict is an alias of MakeConcurrent
+func MakeConcurrentDict(shardCount int) *ConcurrentDict {
+	return MakeConcurrent(shardCount)
+}
+
+// MakeTyped 根据输入的分段数和哈希函数构造泛型分段字典
+func MakeTyped[K comparable, V any](shardCount int, hash func(K) uint32) *Concurrent[K, V] {
 	// 取整计算分段数
 	shardCount = computeCapacity(shardCount)
-	table := make([]*shard, shardCount)
-	for i := 0; i < shardCount; i++ {
-		// 初始化每个shard
-		table[i] = &shard{
-			m: make(map[string]interface{}),
-		}
-	}
-	d := &ConcurrentDict{
-		table:      table,
+	d := &Concurrent[K, V]{
+		hash:       hash,
 		count:      0,
 		shardCount: shardCount,
+		id:         atomic.AddUint64(&dictIdGen, 1),
 	}
+	d.storeTable(makeShardTable[K, V](shardCount))
 	return d
 }
 
+// MakeStringKeyed 构造键为字符串的泛型分段字典
+func MakeStringKeyed[V any](shardCount int) *Concurrent[string, V] {
+	return MakeTyped[string, V](shardCount, loadStringHash())
+}
+
+func makeShardTable[K comparable, V any](shardCount int) *shardTable[K, V] {
+	shards := make([]*shard[K, V], shardCount)
+	for i := 0; i < shardCount; i++ {
+		// 初始化每个shard
+		shards[i] = &shard[K, V]{
+			m: make(map[K]V),
+		}
+	}
+	return &shardTable[K, V]{shards: shards}
+}
+
 /*
 hash算法
 初始化一个哈希值为初始值
@@ -83,52 +161,108 @@ func fnv32(key string) uint32 {
 }
 
 // 将hashCode映射到对应的分段表中
-func (dict *ConcurrentDict) spread(hashCode uint32) uint32 {
-	if dict == nil {
-		panic(any("dict is nil"))
-	}
+func (t *shardTable[K, V]) spread(hashCode uint32) uint32 {
 	// 计算分段表的数量
-	tableSize := uint32(len(dict.table))
+	tableSize := uint32(len(t.shards))
 	// 将hashCode映射到分段表中
 	return (tableSize - 1) & hashCode
 }
 
-// 通过下标返回对应的分段表
-func (dict *ConcurrentDict) getShard(index uint32) *shard {
+func (dict *Concurrent[K, V]) loadTable() *shardTable[K, V] {
 	if dict == nil {
 		panic(any("dict is nil"))
 	}
-	return dict.table[index]
+	return dict.table.Load().(*shardTable[K, V])
+}
+
+// storeTable 将新建的分段表设置为字典当前的分段表
+func (dict *Concurrent[K, V]) storeTable(t *shardTable[K, V]) {
+	t.owner = dict
+	dict.table.Store(t)
+}
+
+// lockShard 找到hashCode所在的分段并加锁
+// 若分段已迁移则释放锁并到新表中继续查找，返回的分段一定未被迁移
+// 若分段表已通过 AtomicSwap 交给了其他字典，则重新加载分段表
+func (dict *Concurrent[K, V]) lockShard(hashCode uint32, write bool) *shard[K, V] {
+	t := dict.loadTable()
+	for {
+		index := t.spread(hashCode)
+		s := t.shards[index]
+		dict.lockWithStats(s, index, write)
+		if s.migrated {
+			s.unlock(write)
+			t = t.next
+			continue
+		}
+		if t.owner != dict {
+			s.unlock(write)
+			t = dict.loadTable()
+			continue
+		}
+		return s
+	}
+}
+
+// getShard 返回hashCode所在的分段，调用方需已通过 RWLocks 持有该分段的锁
+// 持有锁期间分段不会被迁移，因此无需再加锁
+func (dict *Concurrent[K, V]) getShard(hashCode uint32) *shard[K, V] {
+	t := dict.loadTable()
+	for {
+		s := t.shards[t.spread(hashCode)]
+		if !s.migrated {
+			return s
+		}
+		t = t.next
+	}
+}
+
+func (s *shard[K, V]) lock(write bool) {
+	if write {
+		s.mutex.Lock()
+	} else {
+		s.mutex.RLock()
+	}
+}
+
+func (s *shard[K, V]) tryLock(write bool) bool {
+	if write {
+		return s.mutex.TryLock()
+	}
+	return s.mutex.TryRLock()
+}
+
+func (s *shard[K, V]) unlock(write bool) {
+	if write {
+		s.mutex.Unlock()
+	} else {
+		s.mutex.RUnlock()
+	}
 }
 
 // 开始实现 datastruct/dict/dict.go 中定义的Dict接口
 
-func (dict *ConcurrentDict) Get(key string) (val interface{}, exists bool) {
+func (dict *Concurrent[K, V]) Get(key K) (val V, exists bool) {
 	if dict == nil {
 		panic(any("dict is nil"))
 	}
-	hashCode := fnv32(key)
-	index := dict.spread(hashCode)
-	s := dict.getShard(index)
-	// 这里为什么用Lock而非RLock,不太明白
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	// 只读操作使用读锁，同一分段上的多个读操作可以并发执行
+	s := dict.lockShard(dict.hash(key), false)
+	defer s.mutex.RUnlock()
 	val, exists = s.m[key]
 	return val, exists
 }
 
-func (dict *ConcurrentDict) GetWithLock(key string) (val interface{}, exists bool) {
+func (dict *Concurrent[K, V]) GetWithLock(key K) (val V, exists bool) {
 	if dict == nil {
 		panic(any("dict is nil"))
 	}
-	hashCode := fnv32(key)
-	index := dict.spread(hashCode)
-	s := dict.getShard(index)
+	s := dict.getShard(dict.hash(key))
 	val, exists = s.m[key]
 	return val, exists
 }
 
-func (dict *ConcurrentDict) Len() int {
+func (dict *Concurrent[K, V]) Len() int {
 	if dict == nil {
 		panic(any("dict is nil"))
 	}
@@ -136,52 +270,48 @@ func (dict *ConcurrentDict) Len() int {
 	return int(atomic.LoadInt32(&dict.count))
 }
 
-func (dict *ConcurrentDict) Put(key string, val interface{}) (result int) {
+func (dict *Concurrent[K, V]) Put(key K, val V) (result int) {
 	if dict == nil {
 		panic(any("dict is nil"))
 	}
-	hashCode := fnv32(key)
-	index := dict.spread(hashCode)
-	s := dict.getShard(index)
-	s.mutex.Lock()
+	s := dict.lockShard(dict.hash(key), true)
 	defer s.mutex.Unlock()
 	// 判断该key是否已经存在
 	if _, ok := s.m[key]; ok {
+		dict.beforeWrite(s)
 		s.m[key] = val
 		return 0
 	}
 	// count计数器加一，原子操作
 	dict.addCount()
+	dict.beforeWrite(s)
 	s.m[key] = val
 	return 1
 }
 
-func (dict *ConcurrentDict) PutWithLock(key string, val interface{}) (result int) {
+func (dict *Concurrent[K, V]) PutWithLock(key K, val V) (result int) {
 	if dict == nil {
 		panic(any("dict is nil"))
 	}
-	hashCode := fnv32(key)
-	index := dict.spread(hashCode)
-	s := dict.getShard(index)
+	s := dict.getShard(dict.hash(key))
 
 	if _, ok := s.m[key]; ok {
+		dict.beforeWrite(s)
 		s.m[key] = val
 		return 0
 	}
 	// count计数器加一，原子操作
 	dict.addCount()
+	dict.beforeWrite(s)
 	s.m[key] = val
 	return 1
 }
 
-func (dict *ConcurrentDict) PutIfAbsent(key string, val interface{}) (result int) {
+func (dict *Concurrent[K, V]) PutIfAbsent(key K, val V) (result int) {
 	if dict == nil {
 		panic(any("dict is nil"))
 	}
-	hashCode := fnv32(key)
-	index := dict.spread(hashCode)
-	s := dict.getShard(index)
-	s.mutex.Lock()
+	s := dict.lockShard(dict.hash(key), true)
 	defer s.mutex.Unlock()
 	// 判断该key是否已经存在
 	if _, ok := s.m[key]; ok {
@@ -189,37 +319,35 @@ func (dict *ConcurrentDict) PutIfAbsent(key string, val interface{}) (result int
 		return 0
 	}
 	dict.addCount()
+	dict.beforeWrite(s)
 	s.m[key] = val
 	return 1
 }
 
-func (dict *ConcurrentDict) PutIfAbsentWithLock(key string, val interface{}) (result int) {
+func (dict *Concurrent[K, V]) PutIfAbsentWithLock(key K, val V) (result int) {
 	if dict == nil {
 		panic(any("dict is nil"))
 	}
-	hashCode := fnv32(key)
-	index := dict.spread(hashCode)
-	s := dict.getShard(index)
+	s := dict.getShard(dict.hash(key))
 
 	if _, ok := s.m[key]; ok {
 		return 0
 	}
+	dict.beforeWrite(s)
 	s.m[key] = val
 	dict.addCount()
 	return 1
 }
 
-func (dict *ConcurrentDict) PutIfExist(key string, val interface{}) (result int) {
+func (dict *Concurrent[K, V]) PutIfExist(key K, val V) (result int) {
 	if dict == nil {
 		panic(any("dict is nil"))
 	}
-	hashCode := fnv32(key)
-	index := dict.spread(hashCode)
-	s := dict.getShard(index)
-	s.mutex.Lock()
+	s := dict.lockShard(dict.hash(key), true)
 	defer s.mutex.Unlock()
 	// 判断该key是否已经存在,如果存在
 	if _, ok := s.m[key]; ok {
+		dict.beforeWrite(s)
 		s.m[key] = val
 		// 这里返回1代表Put成功而非键值对数量增加
 		return 1
@@ -231,49 +359,50 @@ func (dict *ConcurrentDict) PutIfExist(key string, val interface{}) (result int)
 	return 0
 }
 
-func (dict *ConcurrentDict) PutIfExistsWithLock(key string, val interface{}) (result int) {
+func (dict *Concurrent[K, V]) PutIfExistsWithLock(key K, val V) (result int) {
 	if dict == nil {
 		panic(any("dict is nil"))
 	}
-	hashCode := fnv32(key)
-	index := dict.spread(hashCode)
-	s := dict.getShard(index)
+	s := dict.getShard(dict.hash(key))
 
 	if _, ok := s.m[key]; ok {
+		dict.beforeWrite(s)
 		s.m[key] = val
 		return 1
 	}
 	return 0
 }
 
-func (dict *ConcurrentDict) Remove(key string) (val interface{}, result int) {
+func (dict *Concurrent[K, V]) Remove(key K) (val V, result int) {
 	if dict == nil {
 		panic(any("dict is nil"))
 	}
-	hashCode := fnv32(key)
-	index := dict.spread(hashCode)
-	s := dict.getShard(index)
-	s.mutex.Lock()
+	s := dict.lockShard(dict.hash(key), true)
 	defer s.mutex.Unlock()
 	// 判断该key是否已经存在,如果存在
 	if v, ok := s.m[key]; ok {
+		dict.beforeWrite(s)
 		delete(s.m, key)
 		dict.decreaseCount()
 		// 这里返回1代表Put成功而非键值对数量增加
 		return v, 1
 	}
-	return nil, 0
+	return val, 0
+}
+
+// PutIfExists is an alias of PutIfExist
+func (dict *Concurrent[K, V]) PutIfExists(key K, val V) (result int) {
+	return dict.PutIfExist(key, val)
 }
 
-func (dict *ConcurrentDict) RemoveWithLock(key string) (val interface{}, result int) {
+func (dict *Concurrent[K, V]) RemoveWithLock(key K) (val V, result int) {
 	if dict == nil {
 		panic(any("dict is nil"))
 	}
-	hashCode := fnv32(key)
-	index := dict.spread(hashCode)
-	s := dict.getShard(index)
+	s := dict.getShard(dict.hash(key))
 
 	if val, ok := s.m[key]; ok {
+		dict.beforeWrite(s)
 		delete(s.m, key)
 		dict.decreaseCount()
 		return val, 1
@@ -281,175 +410,340 @@ func (dict *ConcurrentDict) RemoveWithLock(key string) (val interface{}, result
 	return val, 0
 }
 
-func (dict *ConcurrentDict) ForEach(consumer Consumer) {
+func (dict *Concurrent[K, V]) ForEach(consumer func(key K, val V) bool) {
 	if dict == nil {
 		panic(any("dict is nil"))
 	}
 	// 遍历每个分段
-	// _和s是index和value
-	for _, s := range dict.table {
-		s.mutex.RLock()
-		// 定义函数f
-		f := func() bool {
-			// 函数结束时释放对应分段的锁
-			defer s.mutex.RUnlock()
-			// 遍历此分段中的map
-			for key, value := range s.m {
-				continues := consumer(key, value)
-				if !continues {
-					return false
-				}
-			}
-			return true
-		}
-		// 执行f并判断执行结果
-		if !f() {
+	t := dict.loadTable()
+	for i := range t.shards {
+		if !t.forEachShard(i, consumer) {
 			break
 		}
 	}
 }
 
-func (dict *ConcurrentDict) Keys() []string {
+// forEachShard 遍历下标为index的分段，返回false代表遍历被consumer中断
+// 若分段已迁移，则依次遍历它在新表中拆分出的两个分段
+func (t *shardTable[K, V]) forEachShard(index int, consumer func(key K, val V) bool) bool {
+	s := t.shards[index]
+	s.mutex.RLock()
+	if s.migrated {
+		s.mutex.RUnlock()
+		return t.next.forEachShard(index, consumer) &&
+			t.next.forEachShard(index+len(t.shards), consumer)
+	}
+	// 函数结束时释放对应分段的锁
+	defer s.mutex.RUnlock()
+	// 遍历此分段中的map
+	for key, value := range s.m {
+		continues := consumer(key, value)
+		if !continues {
+			return false
+		}
+	}
+	return true
+}
+
+func (dict *Concurrent[K, V]) Keys() []K {
 	if dict == nil {
 		panic(any("dict is nil"))
 	}
 	// 创建一个和字典长度相同的字符串切片,但是在遍历过程中Dict也有可能会继续增加新的键值对
-	keys := make([]string, dict.Len())
+	keys := make([]K, dict.Len())
 	// 遍历所有分段，存储keys，复用了ForEach方法
 	i := 0
-	dict.ForEach(func(key string, val interface{}) bool {
+	dict.ForEach(func(key K, val V) bool {
 		if i < len(keys) {
 			keys[i] = key
 		} else { // 超过容量就使用append方法继续添加
 			keys = append(keys, key)
 		}
+		i++
 		return true
 	})
+	// 遍历过程中有键值对被删除时，去掉多余的零值
+	if i < len(keys) {
+		keys = keys[:i]
+	}
 	return keys
 }
 
-// RandomKey 是RandomKeys的辅助函数，用某一分段中读取随机一个key
-func (s *shard) RandomKey() string {
-	if s == nil {
-		panic(any("shard is nil"))
+// Scan 基于游标遍历字典，返回本次遍历到的key和下一次的游标，返回的游标为0代表遍历结束
+// 与 Redis 的 dictScan 相同，游标按照分段下标的反向二进制位递增，
+// 因此遍历过程中即使字典扩容或被并发修改，在整个遍历期间一直存在的key至少会被返回一次(可能重复)
+// 每次至少遍历一个完整的分段，返回的key数量可能超过count
+func (dict *Concurrent[K, V]) Scan(cursor int, count int) ([]K, int) {
+	if dict == nil {
+		panic(any("dict is nil"))
 	}
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-	// 由于map的读取是随机的，所以返回第一个key即可
-	for key := range s.m {
-		return key
+	if count <= 0 {
+		count = 10
 	}
-	// shard为空时返回空字符串
-	return ""
+	t := dict.loadTable()
+	mask := uint32(len(t.shards) - 1)
+	v := uint32(cursor)
+	keys := make([]K, 0, count)
+	for {
+		// 已迁移的分段会继续遍历它在新表中拆分出的分段，与遍历旧表中的该分段等价
+		t.forEachShard(int(v&mask), func(key K, val V) bool {
+			keys = append(keys, key)
+			return true
+		})
+		// 将高位置1后反转、加一、再反转，即对分段下标的高位进行加一
+		v |= ^mask
+		v = bits.Reverse32(v)
+		v++
+		v = bits.Reverse32(v)
+		if v == 0 || len(keys) >= count {
+			break
+		}
+	}
+	return keys, int(v)
 }
 
-func (dict *ConcurrentDict) RandomKeys(limit int) []string {
+func (dict *Concurrent[K, V]) RandomKeys(limit int) []K {
 	size := dict.Len()
 	// limit大于Dict长度时返回所有Key
 	if limit > size {
 		return dict.Keys()
 	}
-	keys := make([]string, limit)
-	// 下面将随机选择shard并调用RandomKey方法
-	// rand.NewSource(time.Now().UnixNano())创建一个随机数种子
-	// rand.New()基于随机数种子创建随机数生成器
-	nR := rand.New(rand.NewSource(time.Now().UnixNano()))
-	for i := 0; i < limit; i++ {
-		s := dict.getShard(uint32(nR.Intn(dict.shardCount)))
-		keys[i] = s.RandomKey()
+	keys := make([]K, 0, limit)
+	nR := random.New()
+	if random.Deterministic() {
+		all := deterministicKeys(dict.Keys())
+		for len(all) > 0 && len(keys) < limit {
+			keys = append(keys, all[nR.Intn(len(all))])
+		}
+		return keys
+	}
+	sampler := dict.newKeySampler(nR)
+	for len(keys) < limit {
+		key, ok := sampler.sample()
+		if !ok {
+			// 字典在采样期间被清空
+			break
+		}
+		keys = append(keys, key)
 	}
 	return keys
 }
 
-func (dict *ConcurrentDict) RandomDistinctKeys(limit int) []string {
+// RandomDistinctKeys 随机选取limit个不重复的key，字典中的key不足limit个时返回全部key
+func (dict *Concurrent[K, V]) RandomDistinctKeys(limit int) []K {
 	size := dict.Len()
-	if limit > size {
+	if limit >= size {
 		return dict.Keys()
 	}
+	nR := random.New()
+	// 需要超过一半的key时重复采样的概率很高，直接取出全部key后随机选取
+	if limit*2 > size || random.Deterministic() {
+		keys := deterministicKeys(dict.Keys())
+		nR.Shuffle(len(keys), func(i, j int) {
+			keys[i], keys[j] = keys[j], keys[i]
+		})
+		if limit < len(keys) {
+			keys = keys[:limit]
+		}
+		return keys
+	}
 	// 为区分不同的key，使用map存储随机出来的key
 	// map的值定义为空结构体，因为不需要用到值
-	keys := make(map[string]struct{})
-
-	nR := rand.New(rand.NewSource(time.Now().UnixNano()))
-	for i := 0; i < limit; i++ {
-		s := dict.getShard(uint32(nR.Intn(dict.shardCount)))
-		key := s.RandomKey()
-		// 先判断是否取到了key,再判断是否已经存在此key
-		if key != "" {
-			if _, exists := keys[key]; !exists {
-				keys[key] = struct{}{}
-			}
+	seen := make(map[K]struct{}, limit)
+	keys := make([]K, 0, limit)
+	sampler := dict.newKeySampler(nR)
+	// 每次采样重复的概率不超过1/2，正常情况下远用不完尝试次数
+	for attempts := 0; len(keys) < limit && attempts < limit*8; attempts++ {
+		key, ok := sampler.sample()
+		if !ok {
+			break
 		}
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	// 采样期间字典被大量删除时尝试次数可能用尽，遍历字典补足
+	if len(keys) < limit {
+		dict.ForEach(func(key K, val V) bool {
+			if _, exists := seen[key]; !exists {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+			return len(keys) < limit
+		})
 	}
-	// 将map转为切片返回
-	result := make([]string, limit)
-	i := 0
-	for k := range keys {
-		result[i] = k
-		i++
-	}
-	return result
+	return keys
 }
 
-func (dict *ConcurrentDict) Clear() {
-	*dict = *MakeConcurrent(dict.shardCount)
+// Clear 清空字典，分段数恢复为创建时的大小
+// 正在进行的扩容会迁移到被丢弃的旧表上，不会影响新表
+func (dict *Concurrent[K, V]) Clear() {
+	dict.storeTable(makeShardTable[K, V](dict.shardCount))
+	atomic.StoreInt32(&dict.count, 0)
 }
 
-func (dict *ConcurrentDict) addCount() int32 {
-	return atomic.AddInt32(&dict.count, 1)
+// addCount 计数器加一，并检查是否需要扩容
+func (dict *Concurrent[K, V]) addCount() int32 {
+	count := atomic.AddInt32(&dict.count, 1)
+	dict.tryRehash(count)
+	return count
 }
 
-func (dict *ConcurrentDict) decreaseCount() int32 {
+func (dict *Concurrent[K, V]) decreaseCount() int32 {
 	return atomic.AddInt32(&dict.count, -1)
 }
 
+// tryRehash 平均负载超过阈值时在后台启动扩容
+// 调用方可能持有分段锁(如 PutWithLock)，因此迁移必须在新的协程中进行
+func (dict *Concurrent[K, V]) tryRehash(count int32) {
+	t := dict.loadTable()
+	size := len(t.shards)
+	if int(count) <= size*maxShardLoad || size >= maxShardCount {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&dict.rehashing, 0, 1) {
+		return
+	}
+	// 存在快照时不扩容，快照创建时会先设置 snapshotting 再等待 rehashing 归零
+	if atomic.LoadInt32(&dict.snapshotting) == 1 {
+		atomic.StoreInt32(&dict.rehashing, 0)
+		return
+	}
+	go dict.rehash(t)
+}
+
+// rehash 将分段表扩大一倍并逐个迁移旧表中的分段
+// 旧表下标为i的分段中的key，在新表中只会落在下标i或i+size的分段上
+func (dict *Concurrent[K, V]) rehash(old *shardTable[K, V]) {
+	defer atomic.StoreInt32(&dict.rehashing, 0)
+	size := len(old.shards)
+	next := makeShardTable[K, V](size * 2)
+	next.owner = dict
+	old.next = next
+	for i, s := range old.shards {
+		// 新表中的这两个分段在 s 标记为已迁移之前不会被其他协程访问，所以只需锁住 s
+		s.mutex.Lock()
+		low, high := next.shards[i], next.shards[i+size]
+		for key, val := range s.m {
+			if dict.hash(key)&uint32(size) == 0 {
+				low.m[key] = val
+			} else {
+				high.m[key] = val
+			}
+		}
+		s.m = nil
+		s.migrated = true
+		s.mutex.Unlock()
+	}
+	// 期间若字典被 Clear，当前表已不是 old，保持不变
+	dict.table.CompareAndSwap(old, next)
+}
+
 // RWLocks locks write keys and read keys together. allow duplicate keys
-func (dict *ConcurrentDict) RWLocks(writeKeys []string, readKeys []string) {
-	keys := append(writeKeys, readKeys...)
-	indices := dict.toLockIndices(keys, false)
-	writeIndexSet := make(map[uint32]struct{})
-	for _, wKey := range writeKeys {
-		idx := dict.spread(fnv32(wKey))
-		writeIndexSet[idx] = struct{}{}
-	}
-	for _, index := range indices {
-		_, w := writeIndexSet[index]
-		mu := &dict.table[index].mutex
-		if w {
-			mu.Lock()
-		} else {
-			mu.RLock()
+// 扩容期间按照先旧表后新表、表内按下标递增的顺序加锁，避免死锁
+func (dict *Concurrent[K, V]) RWLocks(writeKeys []K, readKeys []K) {
+	for !dict.rwLocks(writeKeys, readKeys, false) {
+	}
+}
+
+// TryRWLocks locks write keys and read keys together, gives up and returns ctx.Err() when ctx is done
+// 有分段被占用时释放已获得的锁稍后重试，成功后同样需要调用 RWUnLocks 解锁
+func (dict *Concurrent[K, V]) TryRWLocks(ctx context.Context, writeKeys []K, readKeys []K) error {
+	return lock.RetryUntil(ctx, func() bool {
+		return dict.rwLocks(writeKeys, readKeys, true)
+	})
+}
+
+// rwLocks 尝试锁住所有key所在的分段，try 为 true 时遇到被占用的分段不等待
+// 若分段被占用或加锁期间分段表通过 AtomicSwap 交给了其他字典，释放已持有的锁并返回false，由调用方重试
+func (dict *Concurrent[K, V]) rwLocks(writeKeys []K, readKeys []K, try bool) bool {
+	t := dict.loadTable()
+	var held []*shard[K, V]
+	var heldWrite []bool
+	for {
+		// 使用完整切片表达式，避免 append 修改调用方的底层数组
+		keys := append(writeKeys[:len(writeKeys):len(writeKeys)], readKeys...)
+		indices := t.toLockIndices(keys, dict.hash, false)
+		writeIndexSet := make(map[uint32]struct{})
+		for _, wKey := range writeKeys {
+			idx := t.spread(dict.hash(wKey))
+			writeIndexSet[idx] = struct{}{}
+		}
+		migrated := make(map[uint32]struct{})
+		for _, index := range indices {
+			_, w := writeIndexSet[index]
+			s := t.shards[index]
+			if !try {
+				dict.lockWithStats(s, index, w)
+			} else if !s.tryLock(w) {
+				for i, s := range held {
+					s.unlock(heldWrite[i])
+				}
+				return false
+			}
+			if s.migrated {
+				s.unlock(w)
+				migrated[index] = struct{}{}
+				continue
+			}
+			held = append(held, s)
+			heldWrite = append(heldWrite, w)
+		}
+		// 持有表中任意一个分段的锁时 AtomicSwap 无法完成，此时检查 owner 即可
+		if len(indices) > len(migrated) && t.owner != dict {
+			for i, s := range held {
+				s.unlock(heldWrite[i])
+			}
+			return false
+		}
+		if len(migrated) == 0 {
+			return true
 		}
+		// 已迁移分段中的key到新表中继续加锁
+		writeKeys = t.filterKeys(writeKeys, dict.hash, migrated)
+		readKeys = t.filterKeys(readKeys, dict.hash, migrated)
+		t = t.next
 	}
 }
 
-func (dict *ConcurrentDict) RWUnLocks(writeKeys []string, readKeys []string) {
-	keys := append(writeKeys, readKeys...)
-	indices := dict.toLockIndices(keys, false)
-	writeIndexSet := make(map[uint32]struct{})
+func (dict *Concurrent[K, V]) RWUnLocks(writeKeys []K, readKeys []K) {
+	// 调用方持有锁，key所在的分段不会被迁移，直接找到分段解锁即可
+	shards := make(map[*shard[K, V]]bool)
 	for _, wKey := range writeKeys {
-		idx := dict.spread(fnv32(wKey))
-		writeIndexSet[idx] = struct{}{}
-	}
-	for _, index := range indices {
-		_, w := writeIndexSet[index]
-		mu := &dict.table[index].mutex
-		if w {
-			mu.Unlock()
-		} else {
-			mu.RUnlock()
+		shards[dict.getShard(dict.hash(wKey))] = true
+	}
+	for _, rKey := range readKeys {
+		s := dict.getShard(dict.hash(rKey))
+		if _, ok := shards[s]; !ok {
+			shards[s] = false
 		}
 	}
+	for s, w := range shards {
+		s.unlock(w)
+	}
+}
+
+// filterKeys 返回位于migrated中分段上的key
+func (t *shardTable[K, V]) filterKeys(keys []K, hash func(K) uint32, migrated map[uint32]struct{}) []K {
+	var result []K
+	for _, key := range keys {
+		if _, ok := migrated[t.spread(hash(key))]; ok {
+			result = append(result, key)
+		}
+	}
+	return result
 }
 
 // 对于ConcurrentDict也要解决锁定一组键的问题
-func (dict *ConcurrentDict) toLockIndices(keys []string, reverse bool) []uint32 {
+func (t *shardTable[K, V]) toLockIndices(keys []K, hash func(K) uint32, reverse bool) []uint32 {
 	// 求所有keys的哈希值及其对应的段表，并排序
 	// 作者在博客中此处的map值为bool型，但github源码中为struct{}
 	// indexMap := make(map[uint32]bool)
 	indexMap := make(map[uint32]struct{})
 	for _, key := range keys {
-		index := dict.spread(fnv32(key))
+		index := t.spread(hash(key))
 		// 得出需要加锁的分段，并加入map中
 		indexMap[index] = struct{}{}
 	}
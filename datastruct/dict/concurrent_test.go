@@ -1,9 +1,12 @@
 package dict
 
 import (
+	"Godis/lib/random"
+	"context"
 	"math/rand"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -218,6 +221,82 @@ func TestConcurrentDict_RandomKey(t *testing.T) {
 	}
 }
 
+func TestConcurrentDict_RandomDistinctKeys(t *testing.T) {
+	d := MakeConcurrentDict(0)
+	count := 100
+	for i := 0; i < count; i++ {
+		d.Put("k"+strconv.Itoa(i), i)
+	}
+	for limit := 1; limit <= count+10; limit++ {
+		result := d.RandomDistinctKeys(limit)
+		expected := limit
+		if expected > count {
+			expected = count
+		}
+		distinct := make(map[string]struct{})
+		for _, key := range result {
+			if _, ok := d.Get(key); !ok {
+				t.Errorf("random distinct keys returned missing key %q", key)
+			}
+			distinct[key] = struct{}{}
+		}
+		if len(result) != expected || len(distinct) != expected {
+			t.Errorf("expect %d distinct keys, actual %d(%d distinct)", expected, len(result), len(distinct))
+		}
+	}
+}
+
+func TestConcurrentDict_RandomKeysFairness(t *testing.T) {
+	// 哈希值即为key本身，16个分段中分段0有16个key，其余分段各有1个key
+	d := MakeTyped[int, int](16, func(key int) uint32 {
+		return uint32(key)
+	})
+	for i := 0; i < 16; i++ {
+		d.Put(i*16, i)
+	}
+	for i := 1; i < 16; i++ {
+		d.Put(i, i)
+	}
+	rounds := 300
+	inShard0 := 0
+	for i := 0; i < rounds; i++ {
+		for _, key := range d.RandomKeys(20) {
+			if key%16 == 0 {
+				inShard0++
+			}
+		}
+	}
+	// 每个key被选中的概率相同，分段0中的key约占16/31
+	ratio := float64(inShard0) / float64(rounds*20)
+	if ratio < 0.45 || ratio > 0.58 {
+		t.Errorf("random keys are not fair, ratio of shard 0: %f", ratio)
+	}
+}
+
+func TestConcurrentDict_RandomKeysDeterministic(t *testing.T) {
+	defer random.Reset()
+	pick := func(reversed bool) ([]string, []string) {
+		random.Seed(42)
+		d := MakeConcurrentDict(0)
+		for i := 0; i < 100; i++ {
+			j := i
+			if reversed {
+				j = 99 - i
+			}
+			d.Put("k"+strconv.Itoa(j), j)
+		}
+		return d.RandomKeys(10), d.RandomDistinctKeys(10)
+	}
+	keys1, distinct1 := pick(false)
+	keys2, distinct2 := pick(true)
+	// 相同的种子和数据，无论插入顺序如何结果都相同
+	for i := range keys1 {
+		if keys1[i] != keys2[i] || distinct1[i] != distinct2[i] {
+			t.Fatalf("random keys are not deterministic: %v %v, %v %v", keys1, keys2, distinct1, distinct2)
+		}
+	}
+}
+
 func TestConcurrentDict_Clear(t *testing.T) {
 	d := MakeConcurrentDict(0)
 	count := 100
@@ -234,6 +313,358 @@ func TestConcurrentDict_Clear(t *testing.T) {
 	}
 }
 
+func TestConcurrentDict_Rehash(t *testing.T) {
+	d := MakeConcurrentDict(0)
+	count := 10000
+	var wg sync.WaitGroup
+	wg.Add(count)
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			defer wg.Done()
+			key := "k" + strconv.Itoa(i)
+			// 扩容期间读写需要保证正确
+			d.RWLocks([]string{key}, nil)
+			d.PutWithLock(key, i)
+			d.RWUnLocks([]string{key}, nil)
+			val, ok := d.Get(key)
+			if !ok || val.(int) != i {
+				t.Error("rehash test failed, key: " + key)
+			}
+		}(i)
+	}
+	wg.Wait()
+	// 等待后台扩容结束
+	for atomic.LoadInt32(&d.rehashing) == 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if size := len(d.loadTable().shards); size <= 16 {
+		t.Errorf("expect dict to grow, shard count: %d", size)
+	}
+	if d.Len() != count || len(d.Keys()) != count {
+		t.Errorf("expect %d keys, actual: %d", count, len(d.Keys()))
+	}
+	for i := 0; i < count; i++ {
+		key := "k" + strconv.Itoa(i)
+		if val, ok := d.Get(key); !ok || val.(int) != i {
+			t.Error("rehash test failed, key: " + key)
+		}
+	}
+}
+
+func TestConcurrentDict_Scan(t *testing.T) {
+	d := MakeConcurrentDict(0)
+	count := 1000
+	for i := 0; i < count; i++ {
+		d.Put("k"+strconv.Itoa(i), i)
+	}
+	seen := make(map[string]struct{})
+	cursor := 0
+	for {
+		// 遍历期间写入新的key会触发扩容，原有的key仍应全部被遍历到
+		for i := 0; i < 100; i++ {
+			d.Put(RandString(8), i)
+		}
+		var keys []string
+		keys, cursor = d.Scan(cursor, 20)
+		for _, key := range keys {
+			seen[key] = struct{}{}
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := 0; i < count; i++ {
+		key := "k" + strconv.Itoa(i)
+		if _, ok := seen[key]; !ok {
+			t.Error("scan test failed, missing key: " + key)
+		}
+	}
+}
+
+func TestConcurrentDict_Stats(t *testing.T) {
+	d := MakeConcurrent(0)
+	count := 100
+	for i := 0; i < count; i++ {
+		d.Put("k"+strconv.Itoa(i), i)
+	}
+	stats := d.Stats()
+	if stats.ShardCount != 16 || len(stats.ShardSizes) != 16 {
+		t.Errorf("expect 16 shards, actual: %d", stats.ShardCount)
+	}
+	if stats.Entries != count {
+		t.Errorf("expect %d entries, actual: %d", count, stats.Entries)
+	}
+	if stats.MinShardSize > stats.MaxShardSize || float64(stats.MaxShardSize) < stats.LoadFactor {
+		t.Errorf("illegal stats: %+v", stats)
+	}
+}
+
+func TestConcurrentDict_Batch(t *testing.T) {
+	d := MakeConcurrent(0)
+	count := 2000
+	keys := make([]string, count)
+	vals := make([]interface{}, count)
+	for i := 0; i < count; i++ {
+		keys[i] = "k" + strconv.Itoa(i)
+		vals[i] = i
+	}
+	// 数量超过扩容阈值，批量写入期间会触发扩容
+	if ret := d.BatchPut(keys, vals); ret != count {
+		t.Errorf("batch put test failed: expected %d, actual: %d", count, ret)
+	}
+	if ret := d.BatchPut(keys[:10], vals[:10]); ret != 0 {
+		t.Errorf("batch put test failed: expected 0, actual: %d", ret)
+	}
+	values, exists := d.BatchGet(append(keys, "missing"))
+	for i := 0; i < count; i++ {
+		if !exists[i] || values[i].(int) != i {
+			t.Error("batch get test failed, key: " + keys[i])
+		}
+	}
+	if exists[count] {
+		t.Error("batch get test failed: expected missing key not exists")
+	}
+	if ret := d.BatchRemove(keys[:count/2]); ret != count/2 {
+		t.Errorf("batch remove test failed: expected %d, actual: %d", count/2, ret)
+	}
+	if d.Len() != count-count/2 {
+		t.Errorf("batch remove test failed: expected len %d, actual: %d", count-count/2, d.Len())
+	}
+	if _, ok := d.Get(keys[0]); ok {
+		t.Error("batch remove test failed, key: " + keys[0])
+	}
+}
+
+func TestConcurrentDict_Snapshot(t *testing.T) {
+	d := MakeConcurrent(0)
+	count := 1000
+	for i := 0; i < count; i++ {
+		d.Put("k"+strconv.Itoa(i), i)
+	}
+	snap := d.Snapshot()
+	// 快照创建后的修改不应被快照看到
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < count; i++ {
+			key := "k" + strconv.Itoa(i)
+			if i%2 == 0 {
+				d.Remove(key)
+			} else {
+				d.Put(key, -i)
+			}
+			d.Put("new"+strconv.Itoa(i), i)
+		}
+	}()
+	seen := make(map[string]int)
+	snap.ForEach(func(key string, val interface{}) bool {
+		seen[key] = val.(int)
+		return true
+	})
+	wg.Wait()
+	snap.Release()
+	if len(seen) != count {
+		t.Errorf("snapshot test failed: expected %d keys, actual: %d", count, len(seen))
+	}
+	for i := 0; i < count; i++ {
+		key := "k" + strconv.Itoa(i)
+		if val, ok := seen[key]; !ok || val != i {
+			t.Error("snapshot test failed, key: " + key)
+		}
+	}
+	// 释放快照后可以继续扩容，修改对字典可见
+	for atomic.LoadInt32(&d.rehashing) == 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if d.Len() != count/2+count {
+		t.Errorf("snapshot test failed: expected len %d, actual: %d", count/2+count, d.Len())
+	}
+	if val, _ := d.Get("k1"); val.(int) != -1 {
+		t.Error("snapshot test failed: expected k1 updated")
+	}
+	d.Snapshot().Release()
+}
+
+func TestConcurrentDict_Merge(t *testing.T) {
+	d := MakeConcurrent(0)
+	other := MakeConcurrent(0)
+	for i := 0; i < 100; i++ {
+		d.Put("k"+strconv.Itoa(i), i)
+	}
+	// 数量超过扩容阈值，合并期间会触发扩容
+	for i := 50; i < 1000; i++ {
+		other.Put("k"+strconv.Itoa(i), -i)
+	}
+	if ret := d.Merge(other, false); ret != 900 {
+		t.Errorf("merge test failed: expected 900, actual: %d", ret)
+	}
+	if d.Len() != 1000 || other.Len() != 950 {
+		t.Errorf("merge test failed: expected len 1000 and 950, actual: %d and %d", d.Len(), other.Len())
+	}
+	if val, _ := d.Get("k50"); val.(int) != 50 {
+		t.Error("merge test failed: expected existing key not replaced")
+	}
+	if ret := d.Merge(other, true); ret != 0 {
+		t.Errorf("merge test failed: expected 0, actual: %d", ret)
+	}
+	if val, _ := d.Get("k50"); val.(int) != -50 {
+		t.Error("merge test failed: expected existing key replaced")
+	}
+}
+
+func TestConcurrentDict_MoveKey(t *testing.T) {
+	src := MakeConcurrent(0)
+	dst := MakeConcurrent(0)
+	src.Put("a", 1)
+	src.Put("b", 2)
+	dst.Put("b", 3)
+	if !src.MoveKey(dst, "a") {
+		t.Error("move key test failed: expected a moved")
+	}
+	if _, ok := src.Get("a"); ok {
+		t.Error("move key test failed: expected a removed from src")
+	}
+	if val, _ := dst.Get("a"); val.(int) != 1 {
+		t.Error("move key test failed: expected a in dst")
+	}
+	if src.MoveKey(dst, "b") || src.MoveKey(dst, "missing") {
+		t.Error("move key test failed: expected move failed")
+	}
+	if src.Len() != 1 || dst.Len() != 2 {
+		t.Errorf("move key test failed: expected len 1 and 2, actual: %d and %d", src.Len(), dst.Len())
+	}
+}
+
+func TestConcurrentDict_AtomicSwap(t *testing.T) {
+	d1 := MakeConcurrent(0)
+	d2 := MakeConcurrent(0)
+	for i := 0; i < 1000; i++ {
+		d1.Put("a"+strconv.Itoa(i), i)
+	}
+	d2.Put("b", 0)
+	// 交换与写入并发执行，写入必须落在写入时字典当前的数据上，计数不能错乱
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := "w" + strconv.Itoa(w) + "-" + strconv.Itoa(i)
+				d1.Put(key, i)
+				d1.Remove(key)
+				d2.RWLocks([]string{key}, nil)
+				d2.PutWithLock(key, i)
+				d2.RemoveWithLock(key)
+				d2.RWUnLocks([]string{key}, nil)
+			}
+		}(w)
+	}
+	for i := 0; i < 100; i++ {
+		d1.AtomicSwap(d2)
+	}
+	wg.Wait()
+	d1.AtomicSwap(d2)
+	if d1.Len() != 1 || d2.Len() != 1000 {
+		t.Errorf("swap test failed: expected len 1 and 1000, actual: %d and %d", d1.Len(), d2.Len())
+	}
+	if len(d1.Keys()) != 1 || len(d2.Keys()) != 1000 {
+		t.Error("swap test failed: unexpected keys")
+	}
+	if _, ok := d1.Get("b"); !ok {
+		t.Error("swap test failed: expected b in d1")
+	}
+	if val, _ := d2.Get("a1"); val.(int) != 1 {
+		t.Error("swap test failed: expected a1 in d2")
+	}
+}
+
+func TestConcurrentDict_TryRWLocks(t *testing.T) {
+	d := MakeConcurrent(16)
+	d.RWLocks([]string{"a"}, []string{"b"})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := d.TryRWLocks(ctx, []string{"c", "a"}, nil); err != context.DeadlineExceeded {
+		t.Errorf("try lock test failed: expected deadline exceeded, actual: %v", err)
+	}
+	// 共享读锁可以获得，且失败时不应留下任何锁
+	if err := d.TryRWLocks(context.Background(), nil, []string{"b"}); err != nil {
+		t.Errorf("try lock test failed: %v", err)
+	}
+	d.RWUnLocks(nil, []string{"b"})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		d.RWUnLocks([]string{"a"}, []string{"b"})
+	}()
+	ctx2, cancel2 := context.WithTimeout(context.Background(), time.Second)
+	defer cancel2()
+	if err := d.TryRWLocks(ctx2, []string{"c", "a"}, []string{"b"}); err != nil {
+		t.Errorf("try lock test failed: %v", err)
+	}
+	d.PutWithLock("a", 1)
+	d.RWUnLocks([]string{"c", "a"}, []string{"b"})
+	// 所有锁都已释放
+	d.RWLocks([]string{"a", "b", "c"}, nil)
+	d.RWUnLocks([]string{"a", "b", "c"}, nil)
+}
+
+func TestConcurrent_Typed(t *testing.T) {
+	d := MakeTyped[int, time.Time](0, func(key int) uint32 {
+		return uint32(key)
+	})
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		d.Put(i, now.Add(time.Duration(i)*time.Second))
+	}
+	for i := 0; i < 1000; i++ {
+		val, ok := d.Get(i)
+		if !ok || !val.Equal(now.Add(time.Duration(i)*time.Second)) {
+			t.Error("typed test failed, key: " + strconv.Itoa(i))
+		}
+	}
+	if _, ok := d.Get(1000); ok {
+		t.Error("typed test failed: expected missing key")
+	}
+	val, result := d.Remove(1000)
+	if result != 0 || !val.IsZero() {
+		t.Error("typed test failed: expected zero value when removing missing key")
+	}
+}
+
+// getExclusive 是修改前使用互斥锁的 Get，用于对比读锁的性能
+func getExclusive(dict *ConcurrentDict, key string) (val interface{}, exists bool) {
+	s := dict.lockShard(dict.hash(key), true)
+	defer s.mutex.Unlock()
+	val, exists = s.m[key]
+	return val, exists
+}
+
+// benchmarkGet 并发读取少量热点key，热点key集中在少数分段上，锁竞争更明显
+func benchmarkGet(b *testing.B, get func(d *ConcurrentDict, key string) (interface{}, bool)) {
+	d := MakeConcurrent(0)
+	keys := make([]string, 64)
+	for i := range keys {
+		keys[i] = "k" + strconv.Itoa(i)
+		d.Put(keys[i], i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			get(d, keys[i%len(keys)])
+			i++
+		}
+	})
+}
+
+func BenchmarkConcurrentDict_Get(b *testing.B) {
+	benchmarkGet(b, (*ConcurrentDict).Get)
+}
+
+func BenchmarkConcurrentDict_GetExclusive(b *testing.B) {
+	benchmarkGet(b, getExclusive)
+}
+
 var r = rand.New(rand.NewSource(time.Now().UnixNano()))
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
 
@@ -245,3 +676,47 @@ func RandString(n int) string {
 	}
 	return string(b)
 }
+
+func TestConcurrentDict_LockStats(t *testing.T) {
+	d := MakeConcurrent(16)
+	d.Put("a", 1)
+	if d.LockStats() != nil {
+		t.Error("lock stats should be disabled by default")
+	}
+	stats := MakeLockStats()
+	d.SetLockStats(stats)
+	d.RWLocks([]string{"a"}, nil)
+	done := make(chan struct{})
+	go func() {
+		d.Get("a")
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	d.RWUnLocks([]string{"a"}, nil)
+	<-done
+
+	shards := stats.Shards()
+	if len(shards) != 1 {
+		t.Fatalf("expect 1 shard, actual %d", len(shards))
+	}
+	s := shards[0]
+	if s.Acquisitions != 2 || s.Contended != 1 {
+		t.Errorf("expect 2 acquisitions and 1 contended, actual %d and %d", s.Acquisitions, s.Contended)
+	}
+	if s.MaxWait < 10*time.Millisecond || s.TotalWait != s.MaxWait {
+		t.Errorf("unexpected wait time: total %s, max %s", s.TotalWait, s.MaxWait)
+	}
+	var waits int64
+	for _, n := range s.Histogram {
+		waits += n
+	}
+	if waits != 1 || s.Histogram[len(LockWaitBuckets)-2] != 1 {
+		t.Errorf("unexpected histogram: %v", s.Histogram)
+	}
+
+	d.SetLockStats(nil)
+	d.Get("a")
+	if shards := stats.Shards(); shards[0].Acquisitions != 2 {
+		t.Error("lock should not be recorded after disabled")
+	}
+}
@@ -1,7 +1,8 @@
 package dict
 
 // Consumer 用于遍历字典的函数类型，范围false时中断遍历
-type Consumer func(key string, val interface{}) bool
+// 使用类型别名，使泛型字典 Concurrent[string, interface{}] 的 ForEach 也满足 Dict 接口
+type Consumer = func(key string, val interface{}) bool
 
 // Dict 把字典定义为接口方便更改字典的具体实现
 // 字典的键为字符串，值可以为其他类型
@@ -29,3 +30,23 @@ type Dict interface {
 	// Clear 清空字典
 	Clear()
 }
+
+// LockableDict 支持由调用方统一加锁的字典
+// 先通过 RWLocks 锁住需要访问的键，再调用 WithLock 系列方法，最后通过 RWUnLocks 解锁
+type LockableDict interface {
+	Dict
+	GetWithLock(key string) (val interface{}, exists bool)
+	PutWithLock(key string, val interface{}) (result int)
+	PutIfAbsentWithLock(key string, val interface{}) (result int)
+	PutIfExistsWithLock(key string, val interface{}) (result int)
+	RemoveWithLock(key string) (val interface{}, result int)
+	RWLocks(writeKeys []string, readKeys []string)
+	RWUnLocks(writeKeys []string, readKeys []string)
+}
+
+var (
+	_ LockableDict = (*ConcurrentDict)(nil)
+	_ LockableDict = (*SimpleDict)(nil)
+	_ LockableDict = (*LockedDict)(nil)
+	_ Dict         = (*PackedDict)(nil)
+)
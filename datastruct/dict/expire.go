@@ -0,0 +1,189 @@
+package dict
+
+import (
+	"Godis/lib/clock"
+	"Godis/lib/random"
+	"time"
+)
+
+// ExpireDict 在 Dict 的基础上为每个键单独记录过期时间，用于哈希表的 HEXPIRE 系列命令
+// 读操作可能在读锁下并发执行，因此读操作只把过期的键视为不存在，不做删除；
+// 过期的键在写操作或 RemoveExpired 中删除，调用方需持有写锁
+// 与 SimpleDict 一样不保证并发安全
+type ExpireDict struct {
+	Dict
+	expires map[string]time.Time
+}
+
+// MakeExpire wraps the given dict with per key expiration
+func MakeExpire(inner Dict) *ExpireDict {
+	return &ExpireDict{
+		Dict:    inner,
+		expires: make(map[string]time.Time),
+	}
+}
+
+func (dict *ExpireDict) isExpired(key string, now time.Time) bool {
+	expireAt, ok := dict.expires[key]
+	return ok && !now.Before(expireAt)
+}
+
+// removeIfExpired 删除已过期的键，只能在写操作中调用
+func (dict *ExpireDict) removeIfExpired(key string) {
+	if dict.isExpired(key, clock.Now()) {
+		dict.Dict.Remove(key)
+		delete(dict.expires, key)
+	}
+}
+
+// Expire sets expiration of key, returns false if key not exists
+func (dict *ExpireDict) Expire(key string, expireAt time.Time) bool {
+	dict.removeIfExpired(key)
+	if _, ok := dict.Dict.Get(key); !ok {
+		return false
+	}
+	dict.expires[key] = expireAt
+	return true
+}
+
+// Persist removes expiration of key, returns false if key has no expiration
+func (dict *ExpireDict) Persist(key string) bool {
+	dict.removeIfExpired(key)
+	if _, ok := dict.expires[key]; !ok {
+		return false
+	}
+	delete(dict.expires, key)
+	return true
+}
+
+// ExpireTime returns expiration of key, returns false if key not exists or has no expiration
+func (dict *ExpireDict) ExpireTime(key string) (time.Time, bool) {
+	if dict.isExpired(key, clock.Now()) {
+		return time.Time{}, false
+	}
+	expireAt, ok := dict.expires[key]
+	return expireAt, ok
+}
+
+// RemoveExpired removes all keys expired before now, returns count of removed keys
+func (dict *ExpireDict) RemoveExpired(now time.Time) int {
+	removed := 0
+	for key := range dict.expires {
+		if dict.isExpired(key, now) {
+			dict.Dict.Remove(key)
+			delete(dict.expires, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// NextExpireTime returns the earliest expiration, returns false if no key has expiration
+func (dict *ExpireDict) NextExpireTime() (time.Time, bool) {
+	var next time.Time
+	found := false
+	for _, expireAt := range dict.expires {
+		if !found || expireAt.Before(next) {
+			next = expireAt
+			found = true
+		}
+	}
+	return next, found
+}
+
+// ExpiresCount returns count of keys with expiration
+func (dict *ExpireDict) ExpiresCount() int {
+	return len(dict.expires)
+}
+
+func (dict *ExpireDict) Get(key string) (val interface{}, exists bool) {
+	if dict.isExpired(key, clock.Now()) {
+		return nil, false
+	}
+	return dict.Dict.Get(key)
+}
+
+// Len returns count of keys not expired
+func (dict *ExpireDict) Len() int {
+	now := clock.Now()
+	expired := 0
+	for key := range dict.expires {
+		if dict.isExpired(key, now) {
+			expired++
+		}
+	}
+	return dict.Dict.Len() - expired
+}
+
+// Put sets value and removes expiration of key, like HSET
+func (dict *ExpireDict) Put(key string, val interface{}) (result int) {
+	dict.removeIfExpired(key)
+	delete(dict.expires, key)
+	return dict.Dict.Put(key, val)
+}
+
+func (dict *ExpireDict) PutIfAbsent(key string, val interface{}) (result int) {
+	dict.removeIfExpired(key)
+	return dict.Dict.PutIfAbsent(key, val)
+}
+
+// PutIfExist updates value and keeps expiration of key
+func (dict *ExpireDict) PutIfExist(key string, val interface{}) (result int) {
+	dict.removeIfExpired(key)
+	return dict.Dict.PutIfExist(key, val)
+}
+
+func (dict *ExpireDict) Remove(key string) (val interface{}, result int) {
+	dict.removeIfExpired(key)
+	delete(dict.expires, key)
+	return dict.Dict.Remove(key)
+}
+
+func (dict *ExpireDict) ForEach(consumer Consumer) {
+	now := clock.Now()
+	dict.Dict.ForEach(func(key string, val interface{}) bool {
+		if dict.isExpired(key, now) {
+			return true
+		}
+		return consumer(key, val)
+	})
+}
+
+func (dict *ExpireDict) Keys() []string {
+	keys := make([]string, 0, dict.Dict.Len())
+	dict.ForEach(func(key string, val interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// RandomKeys 从未过期的键中随机选取，可能重复
+func (dict *ExpireDict) RandomKeys(limit int) []string {
+	keys := deterministicKeys(dict.Keys())
+	if len(keys) == 0 {
+		return nil
+	}
+	result := make([]string, limit)
+	for i := range result {
+		result[i] = keys[random.Intn(len(keys))]
+	}
+	return result
+}
+
+// RandomDistinctKeys 从未过期的键中随机选取不重复的键
+func (dict *ExpireDict) RandomDistinctKeys(limit int) []string {
+	keys := deterministicKeys(dict.Keys())
+	random.Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+	if limit < len(keys) {
+		keys = keys[:limit]
+	}
+	return keys
+}
+
+func (dict *ExpireDict) Clear() {
+	dict.Dict.Clear()
+	dict.expires = make(map[string]time.Time)
+}
@@ -0,0 +1,140 @@
+package dict
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"sync/atomic"
+)
+
+// 键为字符串的字典通过哈希函数选择分段
+// fnv32 的结果可以预测，攻击者能构造出大量落在同一个分段上的 key，使该分段的锁竞争严重、扩容失效(hash flooding)
+// 因此分段选择使用带种子的哈希函数，种子默认在进程启动时随机生成:
+//   - fnv: 以种子扰动初始值和结果的 fnv-1a，速度快，能打乱预先构造好的 key，但不能抵御针对性的攻击
+//   - siphash: SipHash-1-3，较慢，种子未知时无法构造碰撞，与 Redis 的 dict 相同
+// 字典在创建时确定哈希函数，之后不再改变；AtomicSwap 直接交换分段表，要求两个字典使用相同的哈希函数，
+// 所以 SetStringHash 只应在创建字典之前调用
+
+const (
+	// HashFnv is seeded fnv-1a
+	HashFnv = "fnv"
+	// HashSipHash is SipHash-1-3
+	HashSipHash = "siphash"
+)
+
+// stringHash is hash function of string keys used by newly created dicts
+var stringHash atomic.Value
+
+func init() {
+	stringHash.Store(seededFnv32(randomSeed()))
+}
+
+// SetStringHash sets hash function of string keys for dicts created later, zero seed means random seed
+func SetStringHash(function string, seed uint64) error {
+	if seed == 0 {
+		seed = randomSeed()
+	}
+	switch function {
+	case "", HashFnv:
+		stringHash.Store(seededFnv32(seed))
+	case HashSipHash:
+		// SipHash 的密钥是 128 位，由种子派生出第二个 64 位
+		k0, k1 := seed, splitMix64(seed)
+		stringHash.Store(func(key string) uint32 {
+			h := sipHash(k0, k1, key, 1, 3)
+			return uint32(h ^ h>>32)
+		})
+	default:
+		return errors.New("unknown hash function: " + function)
+	}
+	return nil
+}
+
+func loadStringHash() func(string) uint32 {
+	return stringHash.Load().(func(string) uint32)
+}
+
+func randomSeed() uint64 {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		panic(err)
+	}
+	return binary.LittleEndian.Uint64(buf[:]) | 1
+}
+
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// seededFnv32 returns fnv-1a whose offset basis and result are perturbed by seed
+// fnv 的低位分布较差，而分段下标取的正是低位，所以最后用 murmur3 的 fmix32 混合所有位
+func seededFnv32(seed uint64) func(string) uint32 {
+	basis := uint32(2166136261) ^ uint32(seed)
+	mix := uint32(seed >> 32)
+	return func(key string) uint32 {
+		hash := basis
+		for i := 0; i < len(key); i++ {
+			hash ^= uint32(key[i])
+			hash *= prime32
+		}
+		hash ^= mix
+		hash ^= hash >> 16
+		hash *= 0x85ebca6b
+		hash ^= hash >> 13
+		hash *= 0xc2b2ae35
+		hash ^= hash >> 16
+		return hash
+	}
+}
+
+// sipHash computes SipHash-c-d of s with key (k0, k1)
+func sipHash(k0, k1 uint64, s string, c, d int) uint64 {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+	compress := func(m uint64) {
+		v3 ^= m
+		for i := 0; i < c; i++ {
+			round()
+		}
+		v0 ^= m
+	}
+	length := len(s)
+	for ; len(s) >= 8; s = s[8:] {
+		m := uint64(s[0]) | uint64(s[1])<<8 | uint64(s[2])<<16 | uint64(s[3])<<24 |
+			uint64(s[4])<<32 | uint64(s[5])<<40 | uint64(s[6])<<48 | uint64(s[7])<<56
+		compress(m)
+	}
+	// 最后不足8字节的部分，最高字节为长度
+	last := uint64(length) << 56
+	for i := 0; i < len(s); i++ {
+		last |= uint64(s[i]) << (8 * i)
+	}
+	compress(last)
+	v2 ^= 0xff
+	for i := 0; i < d; i++ {
+		round()
+	}
+	return v0 ^ v1 ^ v2 ^ v3
+}
@@ -0,0 +1,66 @@
+package dict
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestSipHash(t *testing.T) {
+	// SipHash-2-4 的参考向量，密钥为 00 01 .. 0f，消息为 00 01 .. (n-1)
+	k0, k1 := uint64(0x0706050403020100), uint64(0x0f0e0d0c0b0a0908)
+	message := make([]byte, 16)
+	for i := range message {
+		message[i] = byte(i)
+	}
+	cases := []struct {
+		length   int
+		expected uint64
+	}{
+		{0, 0x726fdb47dd0e0e31},
+		{15, 0xa129ca6149be45e5},
+	}
+	for _, c := range cases {
+		if h := sipHash(k0, k1, string(message[:c.length]), 2, 4); h != c.expected {
+			t.Errorf("siphash of %d bytes: expect %x, actual %x", c.length, c.expected, h)
+		}
+	}
+}
+
+func TestSetStringHash(t *testing.T) {
+	defer func() {
+		_ = SetStringHash(HashFnv, 0)
+	}()
+	for _, function := range []string{HashFnv, HashSipHash} {
+		if err := SetStringHash(function, 42); err != nil {
+			t.Fatal(err)
+		}
+		h1 := loadStringHash()
+		_ = SetStringHash(function, 42)
+		h2 := loadStringHash()
+		_ = SetStringHash(function, 43)
+		h3 := loadStringHash()
+		same, differ := true, false
+		for _, key := range []string{"", "a", "key:1", "a long key which is longer than 8 bytes"} {
+			same = same && h1(key) == h2(key)
+			differ = differ || h1(key) != h3(key)
+		}
+		if !same || !differ {
+			t.Errorf("%s: hash should be determined by seed", function)
+		}
+
+		// 字典在创建时确定哈希函数
+		d := MakeConcurrent(16)
+		for i := 0; i < 100; i++ {
+			d.Put("key:"+strconv.Itoa(i), i)
+		}
+		_ = SetStringHash(function, 44)
+		for i := 0; i < 100; i++ {
+			if _, ok := d.Get("key:" + strconv.Itoa(i)); !ok {
+				t.Errorf("%s: key should be found after hash function changed", function)
+			}
+		}
+	}
+	if err := SetStringHash("md5", 0); err == nil {
+		t.Error("expect error of unknown hash function")
+	}
+}
@@ -0,0 +1,127 @@
+package dict
+
+import "sync"
+
+// LockedDict 用一把读写锁保护内部字典，使 SimpleDict 等非并发安全的字典可以在多个协程间共享
+// 适用于集群节点表、事务表这类规模很小的内部字典，数据量大且竞争激烈时应使用 ConcurrentDict
+type LockedDict struct {
+	mu   sync.RWMutex
+	dict Dict
+}
+
+// MakeLocked wraps the given dict with a read-write mutex
+func MakeLocked(inner Dict) *LockedDict {
+	return &LockedDict{
+		dict: inner,
+	}
+}
+
+// MakeSyncSimple creates a thread-safe SimpleDict
+func MakeSyncSimple() *LockedDict {
+	return MakeLocked(MakeSimple())
+}
+
+func (dict *LockedDict) Get(key string) (val interface{}, exists bool) {
+	dict.mu.RLock()
+	defer dict.mu.RUnlock()
+	return dict.dict.Get(key)
+}
+
+func (dict *LockedDict) Len() int {
+	dict.mu.RLock()
+	defer dict.mu.RUnlock()
+	return dict.dict.Len()
+}
+
+func (dict *LockedDict) Put(key string, val interface{}) (result int) {
+	dict.mu.Lock()
+	defer dict.mu.Unlock()
+	return dict.dict.Put(key, val)
+}
+
+func (dict *LockedDict) PutIfAbsent(key string, val interface{}) (result int) {
+	dict.mu.Lock()
+	defer dict.mu.Unlock()
+	return dict.dict.PutIfAbsent(key, val)
+}
+
+func (dict *LockedDict) PutIfExist(key string, val interface{}) (result int) {
+	dict.mu.Lock()
+	defer dict.mu.Unlock()
+	return dict.dict.PutIfExist(key, val)
+}
+
+func (dict *LockedDict) Remove(key string) (val interface{}, result int) {
+	dict.mu.Lock()
+	defer dict.mu.Unlock()
+	return dict.dict.Remove(key)
+}
+
+// ForEach 遍历期间持有读锁，consumer 中不能修改该字典，否则会死锁
+func (dict *LockedDict) ForEach(consumer Consumer) {
+	dict.mu.RLock()
+	defer dict.mu.RUnlock()
+	dict.dict.ForEach(consumer)
+}
+
+func (dict *LockedDict) Keys() []string {
+	dict.mu.RLock()
+	defer dict.mu.RUnlock()
+	return dict.dict.Keys()
+}
+
+func (dict *LockedDict) RandomKeys(limit int) []string {
+	dict.mu.RLock()
+	defer dict.mu.RUnlock()
+	return dict.dict.RandomKeys(limit)
+}
+
+func (dict *LockedDict) RandomDistinctKeys(limit int) []string {
+	dict.mu.RLock()
+	defer dict.mu.RUnlock()
+	return dict.dict.RandomDistinctKeys(limit)
+}
+
+func (dict *LockedDict) Clear() {
+	dict.mu.Lock()
+	defer dict.mu.Unlock()
+	dict.dict.Clear()
+}
+
+// RWLocks 整个字典只有一把锁，有写键时加写锁，否则加读锁
+// 之后调用 WithLock 系列方法访问字典，完成后必须以相同的参数调用 RWUnLocks
+func (dict *LockedDict) RWLocks(writeKeys []string, readKeys []string) {
+	if len(writeKeys) > 0 {
+		dict.mu.Lock()
+	} else {
+		dict.mu.RLock()
+	}
+}
+
+func (dict *LockedDict) RWUnLocks(writeKeys []string, readKeys []string) {
+	if len(writeKeys) > 0 {
+		dict.mu.Unlock()
+	} else {
+		dict.mu.RUnlock()
+	}
+}
+
+func (dict *LockedDict) GetWithLock(key string) (val interface{}, exists bool) {
+	return dict.dict.Get(key)
+}
+
+func (dict *LockedDict) PutWithLock(key string, val interface{}) (result int) {
+	return dict.dict.Put(key, val)
+}
+
+func (dict *LockedDict) PutIfAbsentWithLock(key string, val interface{}) (result int) {
+	return dict.dict.PutIfAbsent(key, val)
+}
+
+func (dict *LockedDict) PutIfExistsWithLock(key string, val interface{}) (result int) {
+	return dict.dict.PutIfExist(key, val)
+}
+
+func (dict *LockedDict) RemoveWithLock(key string) (val interface{}, result int) {
+	return dict.dict.Remove(key)
+}
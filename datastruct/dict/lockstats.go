@@ -0,0 +1,139 @@
+package dict
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 分段锁的等待时间统计，用于排查 key 分布不均导致的锁竞争
+// 默认关闭，开启后加锁前先 TryLock，失败时记录阻塞等待的时间；TryRWLocks 不阻塞，不计入统计
+// 分段以加锁时所在分段表中的下标标识，扩容后同一个下标对应的 key 会变化
+
+// LockWaitBuckets are upper bounds of the wait time histogram, the last bucket of histogram counts longer waits
+var LockWaitBuckets = [...]time.Duration{
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// ShardLockStats 单个分段的加锁统计
+type ShardLockStats struct {
+	// Index 分段的下标
+	Index uint32
+	// Acquisitions 加锁次数
+	Acquisitions int64
+	// Contended 需要等待的加锁次数
+	Contended int64
+	// TotalWait 等待时间之和
+	TotalWait time.Duration
+	// MaxWait 最长的一次等待
+	MaxWait time.Duration
+	// Histogram 等待时间的分布，Histogram[i] 为不超过 LockWaitBuckets[i] 的等待次数
+	Histogram [len(LockWaitBuckets) + 1]int64
+}
+
+// shardLockCounter 与 ShardLockStats 字段相同，通过原子操作修改
+type shardLockCounter struct {
+	acquisitions int64
+	contended    int64
+	totalWait    int64
+	maxWait      int64
+	histogram    [len(LockWaitBuckets) + 1]int64
+}
+
+// LockStats 记录字典中每个分段的加锁等待时间
+type LockStats struct {
+	// shard index -> *shardLockCounter
+	shards sync.Map
+}
+
+// MakeLockStats creates an empty LockStats
+func MakeLockStats() *LockStats {
+	return &LockStats{}
+}
+
+func (stats *LockStats) record(index uint32, wait time.Duration, contended bool) {
+	v, ok := stats.shards.Load(index)
+	if !ok {
+		v, _ = stats.shards.LoadOrStore(index, &shardLockCounter{})
+	}
+	c := v.(*shardLockCounter)
+	atomic.AddInt64(&c.acquisitions, 1)
+	if !contended {
+		return
+	}
+	atomic.AddInt64(&c.contended, 1)
+	atomic.AddInt64(&c.totalWait, int64(wait))
+	for {
+		max := atomic.LoadInt64(&c.maxWait)
+		if int64(wait) <= max || atomic.CompareAndSwapInt64(&c.maxWait, max, int64(wait)) {
+			break
+		}
+	}
+	bucket := sort.Search(len(LockWaitBuckets), func(i int) bool {
+		return wait <= LockWaitBuckets[i]
+	})
+	atomic.AddInt64(&c.histogram[bucket], 1)
+}
+
+// Shards returns stats of shards which have been locked, in descending order of total wait time
+func (stats *LockStats) Shards() []ShardLockStats {
+	var result []ShardLockStats
+	stats.shards.Range(func(key, value interface{}) bool {
+		c := value.(*shardLockCounter)
+		s := ShardLockStats{
+			Index:        key.(uint32),
+			Acquisitions: atomic.LoadInt64(&c.acquisitions),
+			Contended:    atomic.LoadInt64(&c.contended),
+			TotalWait:    time.Duration(atomic.LoadInt64(&c.totalWait)),
+			MaxWait:      time.Duration(atomic.LoadInt64(&c.maxWait)),
+		}
+		for i := range c.histogram {
+			s.Histogram[i] = atomic.LoadInt64(&c.histogram[i])
+		}
+		result = append(result, s)
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalWait != result[j].TotalWait {
+			return result[i].TotalWait > result[j].TotalWait
+		}
+		if result[i].Acquisitions != result[j].Acquisitions {
+			return result[i].Acquisitions > result[j].Acquisitions
+		}
+		return result[i].Index < result[j].Index
+	})
+	return result
+}
+
+// SetLockStats starts recording lock wait time into stats, nil stops recording
+func (dict *Concurrent[K, V]) SetLockStats(stats *LockStats) {
+	dict.lockStats.Store(stats)
+}
+
+// LockStats returns stats set by SetLockStats, nil means recording is disabled
+func (dict *Concurrent[K, V]) LockStats() *LockStats {
+	stats, _ := dict.lockStats.Load().(*LockStats)
+	return stats
+}
+
+// lockWithStats 阻塞地锁住下标为index的分段，开启统计时记录等待时间
+func (dict *Concurrent[K, V]) lockWithStats(s *shard[K, V], index uint32, write bool) {
+	stats := dict.LockStats()
+	if stats == nil {
+		s.lock(write)
+		return
+	}
+	if s.tryLock(write) {
+		stats.record(index, 0, false)
+		return
+	}
+	start := time.Now()
+	s.lock(write)
+	stats.record(index, time.Since(start), true)
+}
@@ -0,0 +1,138 @@
+package dict
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 字典之间的批量操作，用于 SWAPDB、MOVE、跨数据库 COPY 等命令
+// 这些操作不会在遍历整个字典期间一直持有锁：Merge 每次只锁一个分段，MoveKey 只锁两个分段，
+// AtomicSwap 只在交换分段表的瞬间锁住两个字典的全部分段，耗时与数据量无关
+
+// Merge 将other中的键值对写入dict，replace为false时跳过dict中已存在的键，返回新插入的键值对数
+// 逐个分段复制other的数据，复制时只持有other一个分段的读锁，写入dict时不持有other的锁
+// 因此两个字典互相 Merge 不会死锁，但 Merge 不是原子的，期间other的修改可能只有一部分被合并
+func (dict *Concurrent[K, V]) Merge(other *Concurrent[K, V], replace bool) (result int) {
+	if dict == nil || other == nil {
+		panic(any("dict is nil"))
+	}
+	if dict == other {
+		return 0
+	}
+	var keys []K
+	var vals []V
+	t := other.loadTable()
+	for i := range t.shards {
+		keys, vals = keys[:0], vals[:0]
+		t.forEachShard(i, func(key K, val V) bool {
+			keys = append(keys, key)
+			vals = append(vals, val)
+			return true
+		})
+		dict.batch(keys, true, func(s *shard[K, V], i int) {
+			if _, ok := s.m[keys[i]]; ok {
+				if replace {
+					dict.beforeWrite(s)
+					s.m[keys[i]] = vals[i]
+				}
+				return
+			}
+			dict.addCount()
+			result++
+			dict.beforeWrite(s)
+			s.m[keys[i]] = vals[i]
+		})
+	}
+	return result
+}
+
+// MoveKey 将key从dict移动到dst，key在dict中不存在或在dst中已存在时返回false
+// 同时持有key在两个字典中所在分段的写锁，其他协程不会看到key同时存在或同时不存在于两个字典中
+func (dict *Concurrent[K, V]) MoveKey(dst *Concurrent[K, V], key K) bool {
+	if dict == nil || dst == nil {
+		panic(any("dict is nil"))
+	}
+	if dict == dst {
+		return false
+	}
+	// 按字典编号从小到大加锁，与 AtomicSwap 的加锁顺序一致
+	var src, target *shard[K, V]
+	if dict.id < dst.id {
+		src = dict.lockShard(dict.hash(key), true)
+		target = dst.lockShard(dst.hash(key), true)
+	} else {
+		target = dst.lockShard(dst.hash(key), true)
+		src = dict.lockShard(dict.hash(key), true)
+	}
+	defer src.mutex.Unlock()
+	defer target.mutex.Unlock()
+	val, ok := src.m[key]
+	if !ok {
+		return false
+	}
+	if _, ok := target.m[key]; ok {
+		return false
+	}
+	dict.beforeWrite(src)
+	delete(src.m, key)
+	dict.decreaseCount()
+	dst.beforeWrite(target)
+	target.m[key] = val
+	dst.addCount()
+	return true
+}
+
+// AtomicSwap 交换两个字典中的全部数据
+// 只交换分段表和计数，不复制数据。交换期间持有两个字典全部分段的写锁，
+// 其他协程要么看到交换前的数据，要么看到交换后的数据
+// 交换前会等待两个字典的快照释放以及正在进行的扩容结束
+func (dict *Concurrent[K, V]) AtomicSwap(other *Concurrent[K, V]) {
+	if dict == nil || other == nil {
+		panic(any("dict is nil"))
+	}
+	if dict == other {
+		return
+	}
+	first, second := dict, other
+	if first.id > second.id {
+		first, second = second, first
+	}
+	// 快照持有分段表，交换期间不能存在快照
+	first.snapshotMu.Lock()
+	defer first.snapshotMu.Unlock()
+	second.snapshotMu.Lock()
+	defer second.snapshotMu.Unlock()
+	// 扩容期间分段表会被替换，占用 rehashing 标志等待扩容结束并阻止新的扩容
+	first.stopRehash()
+	defer atomic.StoreInt32(&first.rehashing, 0)
+	second.stopRehash()
+	defer atomic.StoreInt32(&second.rehashing, 0)
+
+	t1, t2 := first.loadTable(), second.loadTable()
+	for _, s := range t1.shards {
+		s.mutex.Lock()
+	}
+	for _, s := range t2.shards {
+		s.mutex.Lock()
+	}
+	t1.owner, t2.owner = second, first
+	first.table.Store(t2)
+	second.table.Store(t1)
+	// 修改计数前需要持有分段锁，此时计数不会变化
+	count1, count2 := atomic.LoadInt32(&first.count), atomic.LoadInt32(&second.count)
+	atomic.StoreInt32(&first.count, count2)
+	atomic.StoreInt32(&second.count, count1)
+	for _, s := range t1.shards {
+		s.mutex.Unlock()
+	}
+	for _, s := range t2.shards {
+		s.mutex.Unlock()
+	}
+}
+
+// stopRehash 等待正在进行的扩容结束，并阻止新的扩容，之后需将 rehashing 置为0
+func (dict *Concurrent[K, V]) stopRehash() {
+	for !atomic.CompareAndSwapInt32(&dict.rehashing, 0, 1) {
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,227 @@
+package dict
+
+import (
+	"Godis/datastruct/listpack"
+	"Godis/lib/random"
+)
+
+// PackedDict 元素较少时用 listpack 依次保存 field, value，超过阈值后自动转换为 SimpleDict
+// 只能保存 []byte 类型的值，写入其他类型的值时同样会转换，转换后不会再转换回 listpack
+// 与 SimpleDict 一样不保证并发安全
+type PackedDict struct {
+	pack   *listpack.ListPack
+	simple *SimpleDict
+	// maxEntries 是 listpack 中最多的键值对数，maxValue 是 field 和 value 的最大字节数
+	maxEntries int
+	maxValue   int
+}
+
+// Encodings of PackedDict
+const (
+	EncodingListpack  = "listpack"
+	EncodingHashtable = "hashtable"
+)
+
+// MakePacked makes a PackedDict, maxEntries <= 0 disables listpack
+func MakePacked(maxEntries int, maxValue int) *PackedDict {
+	dict := &PackedDict{
+		maxEntries: maxEntries,
+		maxValue:   maxValue,
+	}
+	if maxEntries > 0 {
+		dict.pack = listpack.New()
+	} else {
+		dict.simple = MakeSimple()
+	}
+	return dict
+}
+
+// Encoding returns listpack or hashtable
+func (dict *PackedDict) Encoding() string {
+	if dict.pack != nil {
+		return EncodingListpack
+	}
+	return EncodingHashtable
+}
+
+// PackedSize returns bytes used by listpack, returns -1 if it has been converted to hashtable
+func (dict *PackedDict) PackedSize() int {
+	if dict.pack == nil {
+		return -1
+	}
+	return dict.pack.Size()
+}
+
+// find returns index of field in listpack, returns -1 if not found
+func (dict *PackedDict) find(key string) int {
+	index := -1
+	dict.pack.ForEach(func(i int, entry []byte) bool {
+		if i%2 == 0 && string(entry) == key {
+			index = i
+			return false
+		}
+		return true
+	})
+	return index
+}
+
+// fits 返回键值对能否继续保存在 listpack 中
+func (dict *PackedDict) fits(key string, val interface{}) bool {
+	bytes, ok := val.([]byte)
+	return ok && len(key) <= dict.maxValue && len(bytes) <= dict.maxValue
+}
+
+// convert 将 listpack 中的所有键值对转存到 SimpleDict
+func (dict *PackedDict) convert() {
+	simple := MakeSimple()
+	var key string
+	dict.pack.ForEach(func(i int, entry []byte) bool {
+		if i%2 == 0 {
+			key = string(entry)
+		} else {
+			val := make([]byte, len(entry))
+			copy(val, entry)
+			simple.m[key] = val
+		}
+		return true
+	})
+	dict.simple = simple
+	dict.pack = nil
+}
+
+func (dict *PackedDict) Get(key string) (val interface{}, exists bool) {
+	if dict.pack == nil {
+		return dict.simple.Get(key)
+	}
+	index := dict.find(key)
+	if index < 0 {
+		return nil, false
+	}
+	return dict.pack.Get(index + 1), true
+}
+
+func (dict *PackedDict) Len() int {
+	if dict.pack == nil {
+		return dict.simple.Len()
+	}
+	return dict.pack.Len() / 2
+}
+
+func (dict *PackedDict) Put(key string, val interface{}) (result int) {
+	if dict.pack != nil && !dict.fits(key, val) {
+		dict.convert()
+	}
+	if dict.pack == nil {
+		return dict.simple.Put(key, val)
+	}
+	index := dict.find(key)
+	if index >= 0 {
+		dict.pack.Set(index+1, val.([]byte))
+		return 0
+	}
+	if dict.Len() >= dict.maxEntries {
+		dict.convert()
+		return dict.simple.Put(key, val)
+	}
+	dict.pack.Append([]byte(key), val.([]byte))
+	return 1
+}
+
+func (dict *PackedDict) PutIfAbsent(key string, val interface{}) (result int) {
+	if _, exists := dict.Get(key); exists {
+		return 0
+	}
+	return dict.Put(key, val)
+}
+
+func (dict *PackedDict) PutIfExist(key string, val interface{}) (result int) {
+	if _, exists := dict.Get(key); !exists {
+		return 0
+	}
+	dict.Put(key, val)
+	return 1
+}
+
+func (dict *PackedDict) Remove(key string) (val interface{}, result int) {
+	if dict.pack == nil {
+		return dict.simple.Remove(key)
+	}
+	index := dict.find(key)
+	if index < 0 {
+		return nil, 0
+	}
+	val = dict.pack.Get(index + 1)
+	dict.pack.Remove(index, 2)
+	return val, 1
+}
+
+// ForEach 遍历 listpack 时传给 consumer 的值是副本，可以保留
+func (dict *PackedDict) ForEach(consumer Consumer) {
+	if dict.pack == nil {
+		dict.simple.ForEach(consumer)
+		return
+	}
+	var key string
+	dict.pack.ForEach(func(i int, entry []byte) bool {
+		if i%2 == 0 {
+			key = string(entry)
+			return true
+		}
+		val := make([]byte, len(entry))
+		copy(val, entry)
+		return consumer(key, val)
+	})
+}
+
+func (dict *PackedDict) Keys() []string {
+	if dict.pack == nil {
+		return dict.simple.Keys()
+	}
+	keys := make([]string, 0, dict.Len())
+	dict.pack.ForEach(func(i int, entry []byte) bool {
+		if i%2 == 0 {
+			keys = append(keys, string(entry))
+		}
+		return true
+	})
+	return keys
+}
+
+func (dict *PackedDict) RandomKeys(limit int) []string {
+	if dict.pack == nil {
+		return dict.simple.RandomKeys(limit)
+	}
+	if dict.Len() == 0 {
+		return nil
+	}
+	// listpack 中的键按插入顺序排列，不需要 deterministicKeys
+	keys := dict.Keys()
+	result := make([]string, limit)
+	for i := range result {
+		result[i] = keys[random.Intn(len(keys))]
+	}
+	return result
+}
+
+func (dict *PackedDict) RandomDistinctKeys(limit int) []string {
+	if dict.pack == nil {
+		return dict.simple.RandomDistinctKeys(limit)
+	}
+	keys := dict.Keys()
+	random.Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+	if limit < len(keys) {
+		keys = keys[:limit]
+	}
+	return keys
+}
+
+// Clear 清空字典，已转换为 SimpleDict 的字典清空后仍然是 SimpleDict
+func (dict *PackedDict) Clear() {
+	if dict.pack == nil {
+		dict.simple.Clear()
+		return
+	}
+	dict.pack.Clear()
+}
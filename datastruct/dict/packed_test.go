@@ -0,0 +1,48 @@
+package dict
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPackedDict_Convert(t *testing.T) {
+	d := MakePacked(10, 16)
+	for i := 0; i < 10; i++ {
+		d.Put("k"+strconv.Itoa(i), []byte(strconv.Itoa(i)))
+	}
+	if d.Put("k0", []byte("updated")) != 0 {
+		t.Error("update existing field should return 0")
+	}
+	if _, result := d.Remove("k1"); result != 1 {
+		t.Error("remove existing field should return 1")
+	}
+	if d.Encoding() != EncodingListpack {
+		t.Errorf("expect listpack, actual %s", d.Encoding())
+	}
+
+	// too many entries
+	d.Put("k1", []byte("1"))
+	d.Put("k10", []byte("10"))
+	if d.Encoding() != EncodingHashtable {
+		t.Errorf("expect hashtable after exceeding max entries, actual %s", d.Encoding())
+	}
+	if d.Len() != 11 {
+		t.Errorf("expect 11 fields, actual %d", d.Len())
+	}
+	val, _ := d.Get("k0")
+	if string(val.([]byte)) != "updated" {
+		t.Errorf("expect updated, actual %s", val)
+	}
+
+	// too long value
+	d = MakePacked(10, 16)
+	d.Put("a", []byte("1"))
+	d.Put("b", []byte(strings.Repeat("x", 17)))
+	if d.Encoding() != EncodingHashtable {
+		t.Errorf("expect hashtable after exceeding max value, actual %s", d.Encoding())
+	}
+	if d.Len() != 2 {
+		t.Errorf("expect 2 fields, actual %d", d.Len())
+	}
+}
@@ -0,0 +1,168 @@
+package dict
+
+import (
+	"Godis/lib/random"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// 随机选取key时，若先等概率选择分段再从分段中选择key，较小分段中的key会被过多地选中
+// keySampler 按分段大小加权选择分段，再在分段中等概率选择key，使每个key被选中的概率相同
+//
+// 字典较稠密时使用拒绝采样：等概率选择分段后，以 分段大小/randomBound 的概率接受该分段，
+// 无需统计所有分段的大小；字典较稀疏时拒绝次数过多，改为统计各分段大小后按前缀和选择分段
+
+const (
+	// randomBound 拒绝采样时分段大小的上界
+	// 扩容保证平均负载不超过 maxShardLoad，超过上界的分段很少见，其中的key被选中的概率会略低
+	randomBound = maxShardLoad * 2
+	// maxRejectAttempts 拒绝采样的期望尝试次数超过该值时改为统计分段大小
+	maxRejectAttempts = 64
+)
+
+type keySampler[K comparable, V any] struct {
+	dict *Concurrent[K, V]
+	r    *rand.Rand
+
+	// 以下字段在统计分段大小后使用
+	collected bool
+	shards    []*shard[K, V]
+	// prefix[i] 为前i+1个分段的大小之和
+	prefix []int
+}
+
+func (dict *Concurrent[K, V]) newKeySampler(r *rand.Rand) *keySampler[K, V] {
+	sampler := &keySampler[K, V]{
+		dict: dict,
+		r:    r,
+	}
+	size := dict.Len()
+	if size == 0 || len(dict.loadTable().shards)*randomBound/size > maxRejectAttempts {
+		sampler.collect()
+	}
+	return sampler
+}
+
+// sample 随机返回一个key，返回false代表字典为空
+func (sampler *keySampler[K, V]) sample() (K, bool) {
+	if !sampler.collected {
+		if key, ok := sampler.sampleReject(); ok {
+			return key, true
+		}
+		sampler.collect()
+	}
+	for i := 0; i < 2; i++ {
+		if key, ok := sampler.sampleWeighted(); ok {
+			return key, true
+		}
+		// 统计后分段发生了变化(扩容或删除)，重新统计
+		sampler.collect()
+	}
+	var zero K
+	return zero, false
+}
+
+func (sampler *keySampler[K, V]) sampleReject() (K, bool) {
+	t := sampler.dict.loadTable()
+	for i := 0; i < maxRejectAttempts*4; i++ {
+		s := t.randomLeaf(sampler.r.Intn(len(t.shards)), sampler.r)
+		size := len(s.m)
+		if size > 0 && sampler.r.Intn(randomBound) < size {
+			key := s.keyAt(sampler.r.Intn(size))
+			s.mutex.RUnlock()
+			return key, true
+		}
+		s.mutex.RUnlock()
+	}
+	var zero K
+	return zero, false
+}
+
+// collect 统计所有分段的大小
+func (sampler *keySampler[K, V]) collect() {
+	t := sampler.dict.loadTable()
+	sampler.collected = true
+	sampler.shards = sampler.shards[:0]
+	sampler.prefix = sampler.prefix[:0]
+	total := 0
+	for i := range t.shards {
+		sampler.shards = t.leaves(i, sampler.shards)
+	}
+	for _, s := range sampler.shards {
+		s.mutex.RLock()
+		total += len(s.m)
+		s.mutex.RUnlock()
+		sampler.prefix = append(sampler.prefix, total)
+	}
+}
+
+func (sampler *keySampler[K, V]) sampleWeighted() (K, bool) {
+	var zero K
+	if len(sampler.prefix) == 0 || sampler.prefix[len(sampler.prefix)-1] == 0 {
+		return zero, false
+	}
+	n := sampler.r.Intn(sampler.prefix[len(sampler.prefix)-1])
+	// 找到第一个前缀和大于n的分段
+	i := sort.SearchInts(sampler.prefix, n+1)
+	offset := n
+	if i > 0 {
+		offset -= sampler.prefix[i-1]
+	}
+	s := sampler.shards[i]
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.migrated || len(s.m) == 0 {
+		return zero, false
+	}
+	// 统计后分段大小可能已经变化
+	return s.keyAt(offset % len(s.m)), true
+}
+
+// randomLeaf 返回下标为index的分段，若已迁移则随机选择它在新表中拆分出的一个分段
+// 返回的分段持有读锁
+func (t *shardTable[K, V]) randomLeaf(index int, r *rand.Rand) *shard[K, V] {
+	s := t.shards[index]
+	s.mutex.RLock()
+	if s.migrated {
+		s.mutex.RUnlock()
+		return t.next.randomLeaf(index+r.Intn(2)*len(t.shards), r)
+	}
+	return s
+}
+
+// leaves 将下标为index的分段追加到result中，已迁移的分段追加其在新表中的两个分段
+func (t *shardTable[K, V]) leaves(index int, result []*shard[K, V]) []*shard[K, V] {
+	s := t.shards[index]
+	s.mutex.RLock()
+	migrated := s.migrated
+	s.mutex.RUnlock()
+	if migrated {
+		result = t.next.leaves(index, result)
+		return t.next.leaves(index+len(t.shards), result)
+	}
+	return append(result, s)
+}
+
+// keyAt 返回分段中遍历顺序第offset个key，调用方需持有锁且保证 offset < len(s.m)
+func (s *shard[K, V]) keyAt(offset int) K {
+	for key := range s.m {
+		if offset == 0 {
+			return key
+		}
+		offset--
+	}
+	var zero K
+	return zero
+}
+
+// deterministicKeys 确定性模式下对keys排序后返回，消除 map 遍历顺序的随机性，使随机选取的结果只取决于随机数种子
+func deterministicKeys[K comparable](keys []K) []K {
+	if !random.Deterministic() {
+		return keys
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+	return keys
+}
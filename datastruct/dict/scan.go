@@ -0,0 +1,75 @@
+package dict
+
+import (
+	"container/heap"
+	"math"
+	"math/bits"
+)
+
+// scanPosition returns position of key in the cursor space of ScanKeys
+// 与 Concurrent.Scan 相同，按哈希值的反向二进制位排列，低位相同的 key 相邻
+func scanPosition(key string) uint32 {
+	return bits.Reverse32(fnv32(key))
+}
+
+// positionHeap is a max heap of positions
+type positionHeap []uint32
+
+func (h positionHeap) Len() int            { return len(h) }
+func (h positionHeap) Less(i, j int) bool  { return h[i] > h[j] }
+func (h positionHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *positionHeap) Push(x interface{}) { *h = append(*h, x.(uint32)) }
+func (h *positionHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// ScanKeys 基于游标遍历不支持 Scan 的字典，返回本次遍历到的key和下一次的游标，返回的游标为0代表遍历结束
+// SimpleDict 底层的 map 无法按桶遍历，因此把 key 按哈希值映射到固定的 2^32 个位置上，游标是下一个要访问的位置，
+// 每次返回位置不小于游标的 count 个 key。游标与字典的大小无关，遍历期间一直存在的key恰好返回一次，
+// 哈希值相同的 key 在同一次中返回，因此返回的key数量可能超过count。
+// 每次调用需要遍历整个字典，但只保存 count 个位置，不会复制所有的 key
+func ScanKeys(d Dict, cursor int, count int) ([]string, int) {
+	if count <= 0 {
+		count = 10
+	}
+	if cursor < 0 || int64(cursor) > math.MaxUint32 {
+		return nil, 0
+	}
+	start := uint32(cursor)
+	// 第一次遍历找出不小于游标的第 count 小的位置
+	positions := make(positionHeap, 0, count)
+	remaining := 0
+	d.ForEach(func(key string, val interface{}) bool {
+		pos := scanPosition(key)
+		if pos < start {
+			return true
+		}
+		remaining++
+		if len(positions) < count {
+			heap.Push(&positions, pos)
+		} else if pos < positions[0] {
+			positions[0] = pos
+			heap.Fix(&positions, 0)
+		}
+		return true
+	})
+	if remaining == 0 {
+		return nil, 0
+	}
+	end := positions[0]
+	// 第二次遍历取出 [start, end] 内的 key
+	keys := make([]string, 0, count)
+	d.ForEach(func(key string, val interface{}) bool {
+		if pos := scanPosition(key); pos >= start && pos <= end {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	if remaining <= count || end == math.MaxUint32 {
+		return keys, 0
+	}
+	return keys, int(end) + 1
+}
@@ -1,5 +1,7 @@
 package dict
 
+import "Godis/lib/random"
+
 type SimpleDict struct {
 	m map[string]interface{}
 }
@@ -79,34 +81,66 @@ func (dict *SimpleDict) Keys() []string {
 	return result
 }
 
+// RandomKeys 随机选择limit个键，可能重复，字典为空时返回nil
+// map的遍历顺序只是起点随机，不能直接取第一个键，这里先取出全部键再随机选择
 func (dict *SimpleDict) RandomKeys(limit int) []string {
+	if len(dict.m) == 0 {
+		return nil
+	}
+	keys := deterministicKeys(dict.Keys())
 	result := make([]string, limit)
-	for i := 0; i < limit; i++ {
-		for k := range dict.m {
-			result[i] = k
-			break
-		}
+	for i := range result {
+		result[i] = keys[random.Intn(len(keys))]
 	}
 	return result
 }
 
+// RandomDistinctKeys 随机选择limit个不重复的键，字典中的键不足limit个时返回全部键
 func (dict *SimpleDict) RandomDistinctKeys(limit int) []string {
-	size := limit
-	if size > len(dict.m) {
-		size = len(dict.m)
-	}
-	result := make([]string, size)
-	i := 0
-	for k := range dict.m {
-		if i == size {
-			break
-		}
-		result[i] = k
-		i++
+	keys := deterministicKeys(dict.Keys())
+	random.Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+	if limit < len(keys) {
+		keys = keys[:limit]
 	}
-	return result
+	return keys
 }
 
+// Clear 清空字典，原地替换底层map，已持有该字典指针的调用方看到的是清空后的字典
 func (dict *SimpleDict) Clear() {
-	*dict = *MakeSimple()
+	dict.m = make(map[string]interface{})
 }
+
+// 以下方法与 ConcurrentDict 保持一致，使两者可以互相替换
+// SimpleDict 没有锁，WithLock 系列等同于普通方法，RWLocks/RWUnLocks 不做任何事
+// 需要并发安全时使用 MakeLocked 包装
+
+func (dict *SimpleDict) GetWithLock(key string) (val interface{}, exists bool) {
+	return dict.Get(key)
+}
+
+func (dict *SimpleDict) PutWithLock(key string, val interface{}) (result int) {
+	return dict.Put(key, val)
+}
+
+func (dict *SimpleDict) PutIfAbsentWithLock(key string, val interface{}) (result int) {
+	return dict.PutIfAbsent(key, val)
+}
+
+func (dict *SimpleDict) PutIfExistsWithLock(key string, val interface{}) (result int) {
+	return dict.PutIfExist(key, val)
+}
+
+// PutIfExists is an alias of PutIfExist
+func (dict *SimpleDict) PutIfExists(key string, val interface{}) (result int) {
+	return dict.PutIfExist(key, val)
+}
+
+func (dict *SimpleDict) RemoveWithLock(key string) (val interface{}, result int) {
+	return dict.Remove(key)
+}
+
+func (dict *SimpleDict) RWLocks(writeKeys []string, readKeys []string) {}
+
+func (dict *SimpleDict) RWUnLocks(writeKeys []string, readKeys []string) {}
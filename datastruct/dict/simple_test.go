@@ -0,0 +1,103 @@
+package dict
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSimpleDict_RandomKeys(t *testing.T) {
+	d := MakeSimple()
+	if keys := d.RandomKeys(3); len(keys) != 0 {
+		t.Errorf("expect no keys from empty dict, actual %d", len(keys))
+	}
+	count := 100
+	for i := 0; i < count; i++ {
+		d.Put("k"+strconv.Itoa(i), i)
+	}
+	distinct := make(map[string]struct{})
+	for _, key := range d.RandomKeys(1000) {
+		distinct[key] = struct{}{}
+	}
+	if len(distinct) < 2 {
+		t.Error("random keys always return the same key")
+	}
+	for _, limit := range []int{1, 50, count, count + 10} {
+		expected := limit
+		if expected > count {
+			expected = count
+		}
+		distinct = make(map[string]struct{})
+		for _, key := range d.RandomDistinctKeys(limit) {
+			distinct[key] = struct{}{}
+		}
+		if len(distinct) != expected {
+			t.Errorf("expect %d distinct keys, actual %d", expected, len(distinct))
+		}
+	}
+	d.Clear()
+	if d.Len() != 0 {
+		t.Errorf("expect empty dict after clear, actual %d", d.Len())
+	}
+}
+
+func TestLockedDict(t *testing.T) {
+	d := MakeSyncSimple()
+	count := 100
+	var wg sync.WaitGroup
+	wg.Add(count)
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			defer wg.Done()
+			key := "k" + strconv.Itoa(i)
+			d.Put(key, i)
+			d.RWLocks([]string{key}, nil)
+			if _, ok := d.GetWithLock(key); ok {
+				d.PutIfExistsWithLock(key, i+1)
+			}
+			d.RWUnLocks([]string{key}, nil)
+			d.Get(key)
+		}(i)
+	}
+	wg.Wait()
+	if d.Len() != count {
+		t.Errorf("expect %d keys, actual %d", count, d.Len())
+	}
+	val, _ := d.Get("k1")
+	if val.(int) != 2 {
+		t.Errorf("expect 2, actual %v", val)
+	}
+}
+
+func TestScanKeys(t *testing.T) {
+	d := MakeSimple()
+	count := 1000
+	for i := 0; i < count; i++ {
+		d.Put("k"+strconv.Itoa(i), i)
+	}
+	seen := make(map[string]int)
+	cursor := 0
+	for {
+		// 遍历期间写入其他key，原有的key仍应恰好被遍历一次
+		for i := 0; i < 20; i++ {
+			d.Put(RandString(8), i)
+		}
+		var keys []string
+		keys, cursor = ScanKeys(d, cursor, 20)
+		for _, key := range keys {
+			seen[key]++
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	for i := 0; i < count; i++ {
+		key := "k" + strconv.Itoa(i)
+		if seen[key] != 1 {
+			t.Errorf("expect key %s visited once, actual %d", key, seen[key])
+		}
+	}
+	if keys, cursor := ScanKeys(MakeSimple(), 0, 10); len(keys) != 0 || cursor != 0 {
+		t.Error("scan of empty dict should finish immediately")
+	}
+}
@@ -0,0 +1,111 @@
+package dict
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// 写时复制快照
+// 创建快照时短暂锁住全部分段并记录快照的 epoch，之后各分段在第一次被修改前，
+// 把当前的 map 留给快照、自己改写一份拷贝，快照因此看到的是创建时刻的数据；
+// 遍历快照时每个分段只在取出 map 的瞬间加锁，遍历过程中不持有任何锁
+// 注意快照只冻结键到值的映射，值本身(如 *DataEntity 指向的列表)若被原地修改，快照中也会看到修改
+
+// Snapshot 分段字典在某一时刻的只读视图，使用完毕后必须调用 Release
+type Snapshot[K comparable, V any] struct {
+	dict  *Concurrent[K, V]
+	table *shardTable[K, V]
+	epoch uint64
+}
+
+// Snapshot 创建快照，同一时间只能存在一个快照，前一个快照 Release 之前会阻塞
+// 快照存在期间字典不会扩容
+func (dict *Concurrent[K, V]) Snapshot() *Snapshot[K, V] {
+	if dict == nil {
+		panic(any("dict is nil"))
+	}
+	dict.snapshotMu.Lock()
+	// 先阻止新的扩容，再等待进行中的扩容结束，之后分段表不再变化
+	atomic.StoreInt32(&dict.snapshotting, 1)
+	for atomic.LoadInt32(&dict.rehashing) == 1 {
+		time.Sleep(time.Millisecond)
+	}
+	t := dict.loadTable()
+	// 锁住全部分段后再生效，保证快照不会包含一个事务的部分修改
+	// 按下标顺序加锁，与 RWLocks 的加锁顺序一致，避免死锁
+	for _, s := range t.shards {
+		s.mutex.Lock()
+	}
+	dict.epoch++
+	epoch := dict.epoch
+	atomic.StoreUint64(&dict.snapshotEpoch, epoch)
+	for _, s := range t.shards {
+		s.mutex.Unlock()
+	}
+	return &Snapshot[K, V]{
+		dict:  dict,
+		table: t,
+		epoch: epoch,
+	}
+}
+
+// ForEach 遍历快照中的键值对，consumer 返回 false 时中断遍历
+// 遍历过的分段会立即释放冻结的数据，因此每个快照只能遍历一次
+func (snap *Snapshot[K, V]) ForEach(consumer func(key K, val V) bool) {
+	for _, s := range snap.table.shards {
+		s.mutex.Lock()
+		s.freeze(snap.epoch)
+		m := s.snap
+		s.mutex.Unlock()
+
+		for key, val := range m {
+			if !consumer(key, val) {
+				return
+			}
+		}
+		// 该分段已遍历完，释放冻结的 map，之后的修改无需再复制
+		s.mutex.Lock()
+		s.snap = nil
+		s.shared = false
+		s.mutex.Unlock()
+	}
+}
+
+// Release 释放快照，允许字典继续扩容以及创建新的快照
+func (snap *Snapshot[K, V]) Release() {
+	dict := snap.dict
+	atomic.StoreUint64(&dict.snapshotEpoch, 0)
+	for _, s := range snap.table.shards {
+		s.mutex.Lock()
+		s.snap = nil
+		s.shared = false
+		s.mutex.Unlock()
+	}
+	atomic.StoreInt32(&dict.snapshotting, 0)
+	dict.snapshotMu.Unlock()
+}
+
+// freeze 将分段当前的 map 交给 epoch 对应的快照，调用方需持有分段的写锁
+func (s *shard[K, V]) freeze(epoch uint64) {
+	if s.snapEpoch < epoch {
+		s.snap = s.m
+		s.snapEpoch = epoch
+		s.shared = true
+	}
+}
+
+// beforeWrite 在修改分段前调用，调用方需持有分段的写锁
+// 如果当前 map 被快照引用，先复制一份再修改
+func (dict *Concurrent[K, V]) beforeWrite(s *shard[K, V]) {
+	if epoch := atomic.LoadUint64(&dict.snapshotEpoch); epoch != 0 {
+		s.freeze(epoch)
+	}
+	if s.shared {
+		m := make(map[K]V, len(s.m))
+		for key, val := range s.m {
+			m[key] = val
+		}
+		s.m = m
+		s.shared = false
+	}
+}
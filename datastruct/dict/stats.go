@@ -0,0 +1,62 @@
+package dict
+
+import "sync/atomic"
+
+// Stats 分段字典的统计信息，用于诊断哈希倾斜以及调整分段数
+type Stats struct {
+	// ShardCount 分段数，扩容期间已迁移的分段按其在新表中拆分出的两个分段计算
+	ShardCount int
+	// ShardSizes 每个分段中的键值对数
+	ShardSizes []int
+	// Entries 各分段键值对数之和
+	Entries int
+	// LoadFactor 平均每个分段的键值对数
+	LoadFactor float64
+	// MaxShardSize 键值对最多的分段的大小
+	MaxShardSize int
+	// MinShardSize 键值对最少的分段的大小
+	MinShardSize int
+	// Rehashing 统计时是否正在扩容
+	Rehashing bool
+}
+
+// Stats 统计每个分段的键值对数
+// 逐个分段加读锁统计，不会阻塞整个字典，因此结果不是严格的快照
+func (dict *Concurrent[K, V]) Stats() *Stats {
+	t := dict.loadTable()
+	stats := &Stats{
+		ShardSizes: make([]int, 0, len(t.shards)),
+		Rehashing:  atomic.LoadInt32(&dict.rehashing) == 1,
+	}
+	for i := range t.shards {
+		stats.ShardSizes = t.shardSizes(i, stats.ShardSizes)
+	}
+	stats.ShardCount = len(stats.ShardSizes)
+	for i, size := range stats.ShardSizes {
+		stats.Entries += size
+		if i == 0 || size > stats.MaxShardSize {
+			stats.MaxShardSize = size
+		}
+		if i == 0 || size < stats.MinShardSize {
+			stats.MinShardSize = size
+		}
+	}
+	if stats.ShardCount > 0 {
+		stats.LoadFactor = float64(stats.Entries) / float64(stats.ShardCount)
+	}
+	return stats
+}
+
+// shardSizes 将下标为index的分段的大小追加到sizes中，已迁移的分段追加其在新表中的两个分段
+func (t *shardTable[K, V]) shardSizes(index int, sizes []int) []int {
+	s := t.shards[index]
+	s.mutex.RLock()
+	if s.migrated {
+		s.mutex.RUnlock()
+		sizes = t.next.shardSizes(index, sizes)
+		return t.next.shardSizes(index+len(t.shards), sizes)
+	}
+	size := len(s.m)
+	s.mutex.RUnlock()
+	return append(sizes, size)
+}
@@ -0,0 +1,214 @@
+package expire
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Index 按过期时间排序的key索引，用于主动过期
+// 索引按key的哈希分为多个分段，每个分段是以过期时间为序的最小堆，同时用map记录每个key在堆中的位置，
+// 因此更新、删除某个key的过期时间都是 O(log n)。
+// 每个分段有自己的锁，不同key的 EXPIRE 等写操作以及主动过期不会争用同一把锁
+// 并发安全
+type Index struct {
+	shards []*shard
+	// cursor 下次 PopExpired 开始的分段，轮流开始使各分段的key都能及时过期
+	cursor uint32
+}
+
+type shard struct {
+	mu    sync.Mutex
+	items itemHeap
+	pos   map[string]*item
+}
+
+type item struct {
+	key      string
+	expireAt time.Time
+	// index 在堆中的下标，由 itemHeap.Swap 维护
+	index int
+}
+
+// shardCount 分段数，必须是2的幂
+const shardCount = 16
+
+const prime32 = uint32(16777619)
+
+// MakeIndex creates an empty expiration index
+func MakeIndex() *Index {
+	shards := make([]*shard, shardCount)
+	for i := range shards {
+		shards[i] = &shard{
+			pos: make(map[string]*item),
+		}
+	}
+	return &Index{
+		shards: shards,
+	}
+}
+
+func fnv32(key string) uint32 {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= prime32
+	}
+	return hash
+}
+
+func (idx *Index) getShard(key string) *shard {
+	return idx.shards[fnv32(key)&uint32(len(idx.shards)-1)]
+}
+
+// Set 设置或更新key的过期时间
+func (idx *Index) Set(key string, expireAt time.Time) {
+	s := idx.getShard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if it, ok := s.pos[key]; ok {
+		it.expireAt = expireAt
+		heap.Fix(&s.items, it.index)
+		return
+	}
+	it := &item{
+		key:      key,
+		expireAt: expireAt,
+	}
+	heap.Push(&s.items, it)
+	s.pos[key] = it
+}
+
+// Remove 删除key的过期时间，key不在索引中时什么也不做
+func (idx *Index) Remove(key string) {
+	s := idx.getShard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.pos[key]
+	if !ok {
+		return
+	}
+	heap.Remove(&s.items, it.index)
+	delete(s.pos, key)
+}
+
+// Get 返回key的过期时间
+func (idx *Index) Get(key string) (time.Time, bool) {
+	s := idx.getShard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.pos[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	return it.expireAt, true
+}
+
+// Peek 返回最早过期的key及其过期时间，索引为空时返回false
+func (idx *Index) Peek() (string, time.Time, bool) {
+	var earliest *item
+	for _, s := range idx.shards {
+		s.mu.Lock()
+		if len(s.items) > 0 && (earliest == nil || s.items[0].expireAt.Before(earliest.expireAt)) {
+			it := *s.items[0]
+			earliest = &it
+		}
+		s.mu.Unlock()
+	}
+	if earliest == nil {
+		return "", time.Time{}, false
+	}
+	return earliest.key, earliest.expireAt, true
+}
+
+// PopExpired 从索引中取出最多limit个在now之前(含)过期的key，按过期时间从早到晚排列
+// 各分段依次取出已过期的key，超过limit时不保证取出的是全局最早过期的key
+// 取出的key不再位于索引中，调用方需自行检查key是否确实过期
+// 若在取出之后key又被设置了新的过期时间，它会重新进入索引
+func (idx *Index) PopExpired(now time.Time, limit int) []string {
+	var popped []*item
+	start := atomic.AddUint32(&idx.cursor, 1)
+	for i := 0; i < len(idx.shards) && len(popped) < limit; i++ {
+		s := idx.shards[(start+uint32(i))&uint32(len(idx.shards)-1)]
+		popped = s.popExpired(now, limit-len(popped), popped)
+	}
+	sort.SliceStable(popped, func(i, j int) bool {
+		return popped[i].expireAt.Before(popped[j].expireAt)
+	})
+	keys := make([]string, len(popped))
+	for i, it := range popped {
+		keys[i] = it.key
+	}
+	return keys
+}
+
+func (s *shard) popExpired(now time.Time, limit int, popped []*item) []*item {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ; limit > 0 && len(s.items) > 0; limit-- {
+		it := s.items[0]
+		if it.expireAt.After(now) {
+			break
+		}
+		heap.Pop(&s.items)
+		delete(s.pos, it.key)
+		popped = append(popped, it)
+	}
+	return popped
+}
+
+// Len 返回索引中key的数量
+func (idx *Index) Len() int {
+	n := 0
+	for _, s := range idx.shards {
+		s.mu.Lock()
+		n += len(s.items)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Clear 清空索引
+func (idx *Index) Clear() {
+	for _, s := range idx.shards {
+		s.mu.Lock()
+		s.items = nil
+		s.pos = make(map[string]*item)
+		s.mu.Unlock()
+	}
+}
+
+// itemHeap 实现 container/heap 接口
+type itemHeap []*item
+
+func (h itemHeap) Len() int {
+	return len(h)
+}
+
+func (h itemHeap) Less(i, j int) bool {
+	return h[i].expireAt.Before(h[j].expireAt)
+}
+
+func (h itemHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *itemHeap) Push(x any) {
+	it := x.(*item)
+	it.index = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *itemHeap) Pop() any {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	it.index = -1
+	*h = old[:n-1]
+	return it
+}
@@ -0,0 +1,93 @@
+package expire
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIndex(t *testing.T) {
+	idx := MakeIndex()
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		idx.Set("k"+strconv.Itoa(i), now.Add(time.Duration(10-i)*time.Second))
+	}
+	// k9 最早过期
+	key, expireAt, ok := idx.Peek()
+	if !ok || key != "k9" || !expireAt.Equal(now.Add(time.Second)) {
+		t.Errorf("expect k9 to be the earliest, actual %s", key)
+	}
+	// 更新和删除
+	idx.Set("k0", now.Add(-time.Second))
+	idx.Remove("k9")
+	if idx.Len() != 9 {
+		t.Errorf("expect 9 keys, actual %d", idx.Len())
+	}
+	keys := idx.PopExpired(now.Add(3*time.Second), 100)
+	expected := []string{"k0", "k8", "k7"}
+	if len(keys) != len(expected) {
+		t.Fatalf("expect %v, actual %v", expected, keys)
+	}
+	for i, key := range keys {
+		if key != expected[i] {
+			t.Errorf("expect %v, actual %v", expected, keys)
+		}
+	}
+	if _, ok := idx.Get("k0"); ok {
+		t.Error("popped key should be removed from index")
+	}
+	keys = idx.PopExpired(now.Add(time.Hour), 2)
+	if len(keys) != 2 || idx.Len() != 4 {
+		t.Errorf("expect 2 keys popped and 4 left, actual %d and %d", len(keys), idx.Len())
+	}
+	idx.Clear()
+	if _, _, ok := idx.Peek(); ok {
+		t.Error("expect empty index after clear")
+	}
+}
+
+func TestIndexConcurrent(t *testing.T) {
+	idx := MakeIndex()
+	now := time.Now()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := strconv.Itoa(g) + ":" + strconv.Itoa(i)
+				idx.Set(key, now.Add(time.Duration(i)*time.Millisecond))
+				// 奇数key延后过期，再删除一半
+				if i%2 == 1 {
+					idx.Set(key, now.Add(time.Hour))
+				}
+				if i%4 == 1 {
+					idx.Remove(key)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if idx.Len() != 8*750 {
+		t.Fatalf("expect %d keys, actual %d", 8*750, idx.Len())
+	}
+	// 分批取出，每批不超过limit且按过期时间排列
+	popped := 0
+	for {
+		keys := idx.PopExpired(now.Add(time.Second), 100)
+		if len(keys) == 0 {
+			break
+		}
+		if len(keys) > 100 {
+			t.Fatalf("expect at most 100 keys, actual %d", len(keys))
+		}
+		popped += len(keys)
+	}
+	if popped != 8*500 || idx.Len() != 8*250 {
+		t.Errorf("expect %d keys popped and %d left, actual %d and %d", 8*500, 8*250, popped, idx.Len())
+	}
+	if _, expireAt, ok := idx.Peek(); !ok || !expireAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("expect keys expiring in an hour left, actual %v", expireAt)
+	}
+}
@@ -0,0 +1,104 @@
+// Package geo 基于有序集合的地理位置索引，与 redis 一致，成员的 score 是 52 位的 geohash
+// 搜索时先根据半径选择 geohash 的精度，在中心及相邻的8个区域对应的 score 区间内查找，再按实际距离过滤
+package geo
+
+import (
+	"fmt"
+	"math"
+
+	"Godis/datastruct/sortedset"
+	"Godis/lib/geohash"
+)
+
+// Location is a member found by search
+type Location struct {
+	Member string
+	Lon    float64
+	Lat    float64
+	// Dist is distance in meters to the search center
+	Dist float64
+	// Hash is the full precision geohash, also the score in sorted set
+	Hash uint64
+}
+
+// Add puts member at the coordinate into zset, returns whether member is new
+func Add(zset *sortedset.SortedSet, member string, lon float64, lat float64) (bool, error) {
+	if !geohash.Valid(lon, lat) {
+		return false, fmt.Errorf("ERR invalid longitude,latitude pair %f,%f", lon, lat)
+	}
+	return zset.Add(member, float64(geohash.Encode(lon, lat))), nil
+}
+
+// Position returns coordinate of member, which is the center of its geohash cell
+func Position(zset *sortedset.SortedSet, member string) (lon float64, lat float64, ok bool) {
+	element, ok := zset.Get(member)
+	if !ok {
+		return 0, 0, false
+	}
+	lon, lat = geohash.Decode(uint64(element.Score))
+	return lon, lat, true
+}
+
+// Dist returns distance in meters between two members, ok is false if any member not exists
+func Dist(zset *sortedset.SortedSet, member1 string, member2 string) (dist float64, ok bool) {
+	lon1, lat1, ok1 := Position(zset, member1)
+	lon2, lat2, ok2 := Position(zset, member2)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	return geohash.Distance(lon1, lat1, lon2, lat2), true
+}
+
+// SearchRadius returns members within radius meters from the center, in no particular order
+func SearchRadius(zset *sortedset.SortedSet, lon float64, lat float64, radius float64) []*Location {
+	return search(zset, lon, lat, radius, func(location *Location) bool {
+		return location.Dist <= radius
+	})
+}
+
+// SearchBox returns members within the box of width and height meters centered on the coordinate, in no particular order
+func SearchBox(zset *sortedset.SortedSet, lon float64, lat float64, width float64, height float64) []*Location {
+	// 用外接圆的半径确定搜索区域
+	radius := math.Sqrt(width*width+height*height) / 2
+	return search(zset, lon, lat, radius, func(location *Location) bool {
+		// 纬度方向和经度方向的距离分别与高度和宽度比较，经度方向的距离在成员所在的纬度上计算
+		if geohash.Distance(location.Lon, location.Lat, location.Lon, lat) > height/2 {
+			return false
+		}
+		return geohash.Distance(location.Lon, location.Lat, lon, location.Lat) <= width/2
+	})
+}
+
+func search(zset *sortedset.SortedSet, lon float64, lat float64, radius float64, accept func(location *Location) bool) []*Location {
+	step := geohash.EstimateStepsByRadius(radius, lat)
+	center := geohash.EncodeWithStep(lon, lat, step)
+	areas := append([]geohash.Hash{center}, center.Neighbors()...)
+	// 精度很低时相邻区域可能重复，例如经度方向只有一两个格子
+	visited := make(map[uint64]struct{}, len(areas))
+	var result []*Location
+	for _, area := range areas {
+		if _, ok := visited[area.Bits]; ok {
+			continue
+		}
+		visited[area.Bits] = struct{}{}
+		min, max := area.ScoreRange()
+		minBorder := &sortedset.ScoreBorder{Value: float64(min)}
+		maxBorder := &sortedset.ScoreBorder{Value: float64(max), Exclude: true}
+		zset.ForEach(minBorder, maxBorder, 0, -1, false, func(element *sortedset.Element) bool {
+			hash := uint64(element.Score)
+			memberLon, memberLat := geohash.Decode(hash)
+			location := &Location{
+				Member: element.Member,
+				Lon:    memberLon,
+				Lat:    memberLat,
+				Dist:   geohash.Distance(lon, lat, memberLon, memberLat),
+				Hash:   hash,
+			}
+			if accept(location) {
+				result = append(result, location)
+			}
+			return true
+		})
+	}
+	return result
+}
@@ -0,0 +1,178 @@
+package geo
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"testing"
+
+	"Godis/datastruct/sortedset"
+	"Godis/lib/geohash"
+)
+
+// makeSicily makes the same index as examples in redis documents
+func makeSicily(t *testing.T) *sortedset.SortedSet {
+	zset := sortedset.Make()
+	places := []struct {
+		member   string
+		lon, lat float64
+	}{
+		{"Palermo", 13.361389, 38.115556},
+		{"Catania", 15.087269, 37.502669},
+		{"edge1", 12.758489, 38.788135},
+		{"edge2", 17.241510, 38.788135},
+	}
+	for _, place := range places {
+		if _, err := Add(zset, place.member, place.lon, place.lat); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return zset
+}
+
+func membersOf(locations []*Location) string {
+	members := make([]string, 0, len(locations))
+	for _, location := range locations {
+		members = append(members, location.Member)
+	}
+	sort.Strings(members)
+	return strings.Join(members, ",")
+}
+
+func TestAdd(t *testing.T) {
+	zset := sortedset.Make()
+	tests := []struct {
+		name     string
+		member   string
+		lon, lat float64
+		isNew    bool
+		err      bool
+	}{
+		{"new member", "a", 13.361389, 38.115556, true, false},
+		{"update member", "a", 15.087269, 37.502669, false, false},
+		{"longitude out of range", "b", 180.1, 0, false, true},
+		{"latitude out of range", "b", 0, 85.06, false, true},
+		{"latitude limit", "c", -180, -85.05112878, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isNew, err := Add(zset, tt.member, tt.lon, tt.lat)
+			if (err != nil) != tt.err || isNew != tt.isNew {
+				t.Fatalf("expect new %v error %v, actual new %v error %v", tt.isNew, tt.err, isNew, err)
+			}
+			if tt.err {
+				if _, _, ok := Position(zset, tt.member); ok {
+					t.Error("invalid coordinate should not be added")
+				}
+				return
+			}
+			// 位置是 geohash 格子的中心，误差在1米以内
+			lon, lat, ok := Position(zset, tt.member)
+			if !ok || math.Abs(lon-tt.lon) > 1e-5 || math.Abs(lat-tt.lat) > 1e-5 {
+				t.Errorf("expect position %f,%f, actual %f,%f", tt.lon, tt.lat, lon, lat)
+			}
+		})
+	}
+}
+
+func TestDist(t *testing.T) {
+	zset := makeSicily(t)
+	tests := []struct {
+		name             string
+		member1, member2 string
+		expected         float64
+		ok               bool
+	}{
+		{"same as redis", "Palermo", "Catania", 166274.1516, true},
+		{"same member", "Palermo", "Palermo", 0, true},
+		{"missing member", "Palermo", "Foo", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dist, ok := Dist(zset, tt.member1, tt.member2)
+			if ok != tt.ok || math.Abs(dist-tt.expected) > 0.01 {
+				t.Errorf("expect %f %v, actual %f %v", tt.expected, tt.ok, dist, ok)
+			}
+		})
+	}
+}
+
+type searchFunc func(zset *sortedset.SortedSet, lon, lat float64) []*Location
+
+func radius(meters float64) searchFunc {
+	return func(zset *sortedset.SortedSet, lon, lat float64) []*Location {
+		return SearchRadius(zset, lon, lat, meters)
+	}
+}
+
+func box(width, height float64) searchFunc {
+	return func(zset *sortedset.SortedSet, lon, lat float64) []*Location {
+		return SearchBox(zset, lon, lat, width, height)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	zset := makeSicily(t)
+	tests := []struct {
+		name     string
+		lon, lat float64
+		search   searchFunc
+		expected string
+	}{
+		{"radius 200km", 15, 37, radius(200 * 1000), "Catania,Palermo"},
+		{"radius 100km", 15, 37, radius(100 * 1000), "Catania"},
+		{"radius 10m", 15, 37, radius(10), ""},
+		{"radius covers the earth", -165, -37, radius(20000 * 1000), "Catania,Palermo,edge1,edge2"},
+		{"box 400km", 15, 37, box(400*1000, 400*1000), "Catania,Palermo,edge1,edge2"},
+		// edge1 和 edge2 与中心的距离小于外接圆半径，但在纬度方向超出了盒子
+		{"box 400km*200km", 15, 37, box(400*1000, 200*1000), "Catania"},
+		{"box 200km", 15, 37, box(200*1000, 200*1000), "Catania"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locations := tt.search(zset, tt.lon, tt.lat)
+			if actual := membersOf(locations); actual != tt.expected {
+				t.Errorf("expect %q, actual %q", tt.expected, actual)
+			}
+			for _, location := range locations {
+				lon, lat, _ := Position(zset, location.Member)
+				if location.Lon != lon || location.Lat != lat {
+					t.Errorf("expect position of %s %f,%f, actual %f,%f", location.Member, lon, lat, location.Lon, location.Lat)
+				}
+				if dist := geohash.Distance(tt.lon, tt.lat, lon, lat); location.Dist != dist {
+					t.Errorf("expect distance of %s %f, actual %f", location.Member, dist, location.Dist)
+				}
+			}
+		})
+	}
+	// 与 redis 文档中的结果一致
+	for _, location := range SearchRadius(zset, 15, 37, 200*1000) {
+		if location.Member == "Catania" && math.Abs(location.Dist-56441.3) > 1 {
+			t.Errorf("expect distance of Catania 56441.3, actual %f", location.Dist)
+		}
+	}
+}
+
+func TestSearchAcrossAntimeridian(t *testing.T) {
+	zset := sortedset.Make()
+	tests := []struct {
+		member   string
+		lon, lat float64
+	}{
+		{"west", 179.99, 0},
+		{"east", -179.99, 0},
+		{"far", 170, 0},
+	}
+	for _, tt := range tests {
+		if _, err := Add(zset, tt.member, tt.lon, tt.lat); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 相邻的格子跨越180度经线
+	if actual := membersOf(SearchRadius(zset, 180, 0, 5000)); actual != "east,west" {
+		t.Errorf("expect east,west, actual %q", actual)
+	}
+	if actual := membersOf(SearchRadius(zset, -180, 0, 5000)); actual != "east,west" {
+		t.Errorf("expect east,west, actual %q", actual)
+	}
+}
@@ -0,0 +1,167 @@
+package hash
+
+import (
+	"Godis/datastruct/dict"
+	"time"
+)
+
+// Hash 是哈希表类型的数据结构，field 较少时使用 listpack 编码，超过阈值后转换为 hashtable
+// 设置过字段过期时间后，内部的字典被包装为 ExpireDict，过期的字段对读操作不可见
+// 与 dict.SimpleDict 一样不保证并发安全，调用方需持有 key 的锁
+type Hash struct {
+	packed *dict.PackedDict
+	// expire 在第一次设置字段过期时间时创建，包装 packed
+	expire *dict.ExpireDict
+}
+
+// Make makes an empty hash, maxEntries <= 0 disables listpack
+func Make(maxEntries int, maxValue int) *Hash {
+	return &Hash{
+		packed: dict.MakePacked(maxEntries, maxValue),
+	}
+}
+
+func (h *Hash) dict() dict.Dict {
+	if h.expire != nil {
+		return h.expire
+	}
+	return h.packed
+}
+
+// Encoding returns listpack or hashtable
+func (h *Hash) Encoding() string {
+	return h.packed.Encoding()
+}
+
+// PackedSize returns bytes used by listpack, returns -1 if it has been converted to hashtable
+func (h *Hash) PackedSize() int {
+	return h.packed.PackedSize()
+}
+
+// Get returns value of field
+func (h *Hash) Get(field string) ([]byte, bool) {
+	raw, exists := h.dict().Get(field)
+	if !exists {
+		return nil, false
+	}
+	value, _ := raw.([]byte)
+	return value, true
+}
+
+// Has returns true if the field exists
+func (h *Hash) Has(field string) bool {
+	_, exists := h.dict().Get(field)
+	return exists
+}
+
+// Set puts field and removes its expiration, returns 1 if the field is new
+func (h *Hash) Set(field string, value []byte) int {
+	return h.dict().Put(field, value)
+}
+
+// SetIfAbsent puts field only if it not exists, returns 1 if the field is put
+func (h *Hash) SetIfAbsent(field string, value []byte) int {
+	return h.dict().PutIfAbsent(field, value)
+}
+
+// Del removes field, returns 1 if the field existed
+func (h *Hash) Del(field string) int {
+	_, result := h.dict().Remove(field)
+	return result
+}
+
+// Len returns number of fields
+func (h *Hash) Len() int {
+	if h == nil {
+		return 0
+	}
+	return h.dict().Len()
+}
+
+// ForEach visits each field and value, returns false in consumer to break
+func (h *Hash) ForEach(consumer func(field string, value []byte) bool) {
+	h.dict().ForEach(func(key string, val interface{}) bool {
+		value, _ := val.([]byte)
+		return consumer(key, value)
+	})
+}
+
+// Fields returns all field names
+func (h *Hash) Fields() []string {
+	return h.dict().Keys()
+}
+
+// Scan returns fields visited from cursor and the next cursor, the returned cursor is 0 when the scan finished
+// 游标与哈希表的大小和编码无关，遍历期间一直存在的字段恰好返回一次，见 dict.ScanKeys
+func (h *Hash) Scan(cursor int, count int) ([]string, int) {
+	return dict.ScanKeys(h.dict(), cursor, count)
+}
+
+// RandomField returns a random field, returns false if the hash is empty
+func (h *Hash) RandomField() (string, bool) {
+	fields := h.dict().RandomKeys(1)
+	if len(fields) == 0 {
+		return "", false
+	}
+	return fields[0], true
+}
+
+// RandomFields returns limit random fields, the result may contain duplicated fields
+func (h *Hash) RandomFields(limit int) []string {
+	return h.dict().RandomKeys(limit)
+}
+
+// RandomDistinctFields returns at most limit random fields without duplication
+func (h *Hash) RandomDistinctFields(limit int) []string {
+	return h.dict().RandomDistinctKeys(limit)
+}
+
+/* ---- Field Expiration ---- */
+
+// Expire sets expiration of field, returns false if the field not exists
+func (h *Hash) Expire(field string, expireAt time.Time) bool {
+	if h.expire == nil {
+		h.expire = dict.MakeExpire(h.packed)
+	}
+	return h.expire.Expire(field, expireAt)
+}
+
+// Persist removes expiration of field, returns false if the field has no expiration
+func (h *Hash) Persist(field string) bool {
+	if h.expire == nil {
+		return false
+	}
+	return h.expire.Persist(field)
+}
+
+// ExpireTime returns expiration of field, returns false if the field has no expiration
+func (h *Hash) ExpireTime(field string) (time.Time, bool) {
+	if h.expire == nil {
+		return time.Time{}, false
+	}
+	return h.expire.ExpireTime(field)
+}
+
+// RemoveExpired removes all fields expired before now, returns count of removed fields
+func (h *Hash) RemoveExpired(now time.Time) int {
+	if h.expire == nil {
+		return 0
+	}
+	return h.expire.RemoveExpired(now)
+}
+
+// NextExpireTime returns the earliest expiration of fields, returns false if no field has expiration
+func (h *Hash) NextExpireTime() (time.Time, bool) {
+	if h.expire == nil {
+		return time.Time{}, false
+	}
+	return h.expire.NextExpireTime()
+}
+
+// ExpiresCount returns count of fields with expiration
+func (h *Hash) ExpiresCount() int {
+	if h.expire == nil {
+		return 0
+	}
+	return h.expire.ExpiresCount()
+}
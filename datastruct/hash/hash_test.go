@@ -0,0 +1,94 @@
+package hash
+
+import (
+	"Godis/datastruct/dict"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHash(t *testing.T) {
+	h := Make(4, 16)
+	for i := 0; i < 4; i++ {
+		if h.Set("f"+strconv.Itoa(i), []byte(strconv.Itoa(i))) != 1 {
+			t.Error("set new field should return 1")
+		}
+	}
+	if h.Set("f0", []byte("updated")) != 0 {
+		t.Error("set existing field should return 0")
+	}
+	if h.SetIfAbsent("f0", []byte("x")) != 0 {
+		t.Error("set existing field if absent should return 0")
+	}
+	if h.Encoding() != dict.EncodingListpack {
+		t.Errorf("expect listpack, actual %s", h.Encoding())
+	}
+	h.Set("f4", []byte("4"))
+	if h.Encoding() != dict.EncodingHashtable {
+		t.Errorf("expect hashtable, actual %s", h.Encoding())
+	}
+	if h.Len() != 5 {
+		t.Errorf("expect 5 fields, actual %d", h.Len())
+	}
+	if value, _ := h.Get("f0"); string(value) != "updated" {
+		t.Errorf("expect updated, actual %s", value)
+	}
+	if h.Del("f1") != 1 || h.Del("f1") != 0 || h.Has("f1") {
+		t.Error("delete field failed")
+	}
+
+	fields := h.Fields()
+	sort.Strings(fields)
+	if len(fields) != 4 || fields[0] != "f0" || fields[3] != "f4" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	count := 0
+	h.ForEach(func(field string, value []byte) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Error("ForEach should stop when consumer returns false")
+	}
+	if field, ok := h.RandomField(); !ok || !h.Has(field) {
+		t.Errorf("unexpected random field %s", field)
+	}
+	if len(h.RandomFields(10)) != 10 {
+		t.Error("random fields may contain duplicated fields")
+	}
+	if len(h.RandomDistinctFields(10)) != 4 {
+		t.Error("random distinct fields should not exceed the number of fields")
+	}
+}
+
+func TestHash_Expire(t *testing.T) {
+	h := Make(4, 16)
+	h.Set("a", []byte("1"))
+	h.Set("b", []byte("2"))
+	if h.Persist("a") {
+		t.Error("field without ttl should not be persisted")
+	}
+	if h.Expire("c", time.Now().Add(time.Hour)) {
+		t.Error("expire should fail on missing field")
+	}
+	h.Expire("a", time.Now().Add(-time.Second))
+	h.Expire("b", time.Now().Add(time.Hour))
+	if h.Encoding() != dict.EncodingListpack {
+		t.Errorf("expire should keep encoding, actual %s", h.Encoding())
+	}
+	if h.Has("a") || h.Len() != 1 {
+		t.Error("expired field should be invisible")
+	}
+	if _, ok := h.ExpireTime("b"); !ok {
+		t.Error("expect ttl of b")
+	}
+	if h.RemoveExpired(time.Now()) != 1 || h.ExpiresCount() != 1 {
+		t.Error("expect expired field removed")
+	}
+	// HSET removes expiration
+	h.Set("b", []byte("3"))
+	if _, ok := h.NextExpireTime(); ok {
+		t.Error("expect no field with ttl")
+	}
+}
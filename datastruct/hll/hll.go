@@ -0,0 +1,388 @@
+// Package hll HyperLogLog 基数估计，序列化格式与 redis 完全一致，可以与 redis 互相导入导出
+// 格式为16字节的头部加寄存器数据:
+//
+//	+------+---+-----+----------+
+//	| HYLL | E | N/U | Cardin.  |
+//	+------+---+-----+----------+
+//
+// E 为编码方式(0为dense，1为sparse)，N/U 为3个未使用的字节，Cardin. 为8字节小端序的基数缓存，最高位为1表示缓存失效
+// 共有16384个寄存器，dense 编码中每个寄存器占6位，sparse 编码用游程编码压缩连续为0的寄存器
+package hll
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+const (
+	// precision 寄存器下标的位数
+	precision = 14
+	// RegisterCount is number of registers
+	RegisterCount = 1 << precision
+	registerMask  = RegisterCount - 1
+	// q 哈希值中用于计算连续0个数的位数
+	q = 64 - precision
+	// registerBits dense 编码中每个寄存器的位数
+	registerBits = 6
+	registerMax  = 1<<registerBits - 1
+
+	headerSize = 16
+	denseSize  = headerSize + (RegisterCount*registerBits+7)/8
+
+	encodingDense  = 0
+	encodingSparse = 1
+
+	// sparse 编码的操作码，详见 encodeSparse
+	sparseValMaxValue = 32
+	sparseValMaxLen   = 4
+	sparseZeroMaxLen  = 64
+	sparseXZeroMaxLen = 16384
+
+	// alphaInf 是 Ertl 估计算法中的常数 0.5/ln(2)
+	alphaInf = 0.721347520444481703680
+)
+
+// SparseMaxBytes sparse 编码的最大字节数(不含头部)，超过后转换为 dense 编码，与 redis hll-sparse-max-bytes 的默认值一致
+var SparseMaxBytes = 3000
+
+var magic = []byte("HYLL")
+
+// ErrInvalid is returned when parsing bytes which are not a valid HyperLogLog
+var ErrInvalid = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value")
+
+// HLL is a HyperLogLog
+// registers are decoded in memory, use Bytes to get the redis compatible representation
+type HLL struct {
+	registers [RegisterCount]uint8
+	sparse    bool
+	// cache 是基数缓存，cacheValid 为 false 时需要重新计算
+	cache      uint64
+	cacheValid bool
+}
+
+// New makes an empty HyperLogLog in sparse encoding
+func New() *HLL {
+	return &HLL{
+		sparse:     true,
+		cacheValid: true,
+	}
+}
+
+// IsValid returns whether data looks like a HyperLogLog, without decoding registers
+func IsValid(data []byte) bool {
+	if len(data) < headerSize || string(data[:4]) != string(magic) {
+		return false
+	}
+	switch data[4] {
+	case encodingDense:
+		return len(data) == denseSize
+	case encodingSparse:
+		return true
+	}
+	return false
+}
+
+// Parse decodes HyperLogLog from the redis representation
+func Parse(data []byte) (*HLL, error) {
+	if !IsValid(data) {
+		return nil, ErrInvalid
+	}
+	h := &HLL{
+		sparse: data[4] == encodingSparse,
+	}
+	card := data[8:16]
+	if card[7]&(1<<7) == 0 {
+		h.cache = binary.LittleEndian.Uint64(card)
+		h.cacheValid = true
+	}
+	if h.sparse {
+		if err := h.decodeSparse(data[headerSize:]); err != nil {
+			return nil, err
+		}
+		return h, nil
+	}
+	h.decodeDense(data[headerSize:])
+	return h, nil
+}
+
+// Encoding returns sparse or dense
+func (h *HLL) Encoding() string {
+	if h.sparse {
+		return "sparse"
+	}
+	return "dense"
+}
+
+// Add adds element, returns true if any register changed, which means the estimated cardinality may change
+func (h *HLL) Add(element []byte) bool {
+	index, count := patternLen(element)
+	if h.registers[index] >= count {
+		return false
+	}
+	h.registers[index] = count
+	h.cacheValid = false
+	return true
+}
+
+// patternLen returns register index of element and the position of first 1 bit in the rest bits of its hash
+func patternLen(element []byte) (uint16, uint8) {
+	hash := murmurHash64A(element, 0xadc83b19)
+	index := uint16(hash & registerMask)
+	hash >>= precision
+	// 保证循环一定会结束，count 最大为 q+1
+	hash |= 1 << q
+	count := uint8(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return index, count
+}
+
+// Count returns the estimated cardinality
+func (h *HLL) Count() uint64 {
+	if h.cacheValid {
+		return h.cache
+	}
+	h.cache = estimate(&h.registers)
+	h.cacheValid = true
+	return h.cache
+}
+
+// Merge sets every register to the max value of the same register in h and others
+func (h *HLL) Merge(others ...*HLL) {
+	for _, other := range others {
+		for i, val := range other.registers {
+			if val > h.registers[i] {
+				h.registers[i] = val
+				h.cacheValid = false
+			}
+		}
+	}
+}
+
+// ToDense converts the encoding to dense, it never converts back to sparse
+func (h *HLL) ToDense() {
+	h.sparse = false
+}
+
+// Bytes returns the redis compatible representation
+// sparse encoding is converted to dense if it is longer than SparseMaxBytes or a register exceeds 32
+func (h *HLL) Bytes() []byte {
+	var body []byte
+	if h.sparse {
+		var ok bool
+		body, ok = h.encodeSparse()
+		if !ok {
+			h.sparse = false
+		}
+	}
+	if !h.sparse {
+		body = h.encodeDense()
+	}
+	data := make([]byte, headerSize, headerSize+len(body))
+	copy(data, magic)
+	if h.sparse {
+		data[4] = encodingSparse
+	} else {
+		data[4] = encodingDense
+	}
+	if h.cacheValid {
+		binary.LittleEndian.PutUint64(data[8:16], h.cache)
+	} else {
+		data[15] = 1 << 7
+	}
+	return append(data, body...)
+}
+
+/* ---- dense encoding ---- */
+
+// dense 编码中第 i 个寄存器从第 i*6 位开始，从字节的低位向高位排列
+
+func (h *HLL) decodeDense(body []byte) {
+	for i := 0; i < RegisterCount; i++ {
+		byteIndex := i * registerBits / 8
+		shift := uint(i * registerBits & 7)
+		val := uint(body[byteIndex]) >> shift
+		if shift > 8-registerBits {
+			val |= uint(body[byteIndex+1]) << (8 - shift)
+		}
+		h.registers[i] = uint8(val & registerMax)
+	}
+}
+
+func (h *HLL) encodeDense() []byte {
+	body := make([]byte, denseSize-headerSize)
+	for i, val := range h.registers {
+		if val > registerMax {
+			val = registerMax
+		}
+		byteIndex := i * registerBits / 8
+		shift := uint(i * registerBits & 7)
+		body[byteIndex] |= val << shift
+		if shift > 8-registerBits {
+			body[byteIndex+1] |= val >> (8 - shift)
+		}
+	}
+	return body
+}
+
+/* ---- sparse encoding ---- */
+
+// sparse 编码由三种操作码组成:
+//   ZERO:  00xxxxxx，xxxxxx+1 个连续为0的寄存器(1-64)
+//   XZERO: 01xxxxxx yyyyyyyy，xxxxxxyyyyyyyy+1 个连续为0的寄存器(1-16384)
+//   VAL:   1vvvvvxx，xx+1 个连续的值为 vvvvv+1 的寄存器，值为1-32，个数为1-4
+
+func (h *HLL) decodeSparse(body []byte) error {
+	index := 0
+	for i := 0; i < len(body); i++ {
+		op := body[i]
+		var runLen int
+		var val uint8
+		switch {
+		case op&0xc0 == 0: // ZERO
+			runLen = int(op&0x3f) + 1
+		case op&0xc0 == 0x40: // XZERO
+			if i+1 >= len(body) {
+				return ErrInvalid
+			}
+			runLen = (int(op&0x3f)<<8 | int(body[i+1])) + 1
+			i++
+		default: // VAL
+			runLen = int(op&0x3) + 1
+			val = (op>>2)&0x1f + 1
+		}
+		if index+runLen > RegisterCount {
+			return ErrInvalid
+		}
+		for j := 0; j < runLen; j++ {
+			h.registers[index+j] = val
+		}
+		index += runLen
+	}
+	if index != RegisterCount {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// encodeSparse returns false if the result is longer than SparseMaxBytes or a register cannot be encoded
+func (h *HLL) encodeSparse() ([]byte, bool) {
+	var body []byte
+	for i := 0; i < RegisterCount; {
+		val := h.registers[i]
+		runLen := 1
+		for i+runLen < RegisterCount && h.registers[i+runLen] == val {
+			runLen++
+		}
+		i += runLen
+		if val > sparseValMaxValue {
+			return nil, false
+		}
+		for runLen > 0 {
+			var n int
+			switch {
+			case val > 0:
+				n = minInt(runLen, sparseValMaxLen)
+				body = append(body, 0x80|(val-1)<<2|byte(n-1))
+			case runLen <= sparseZeroMaxLen:
+				n = runLen
+				body = append(body, byte(n-1))
+			default:
+				n = minInt(runLen, sparseXZeroMaxLen)
+				body = append(body, 0x40|byte((n-1)>>8), byte(n-1))
+			}
+			runLen -= n
+		}
+		if len(body) > SparseMaxBytes {
+			return nil, false
+		}
+	}
+	return body, true
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+/* ---- estimation ---- */
+
+// estimate 使用 Otmar Ertl 提出的改进算法，与 redis 一致，在基数较小和较大时都不需要额外的修正
+func estimate(registers *[RegisterCount]uint8) uint64 {
+	var histogram [64]int
+	for _, val := range registers {
+		histogram[val]++
+	}
+	m := float64(RegisterCount)
+	z := m * tau((m-float64(histogram[q+1]))/m)
+	for j := q; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * sigma(float64(histogram[0])/m)
+	return uint64(math.Round(alphaInf * m * m / z))
+}
+
+func sigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
+		}
+	}
+}
+
+func tau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if prev == z {
+			return z / 3
+		}
+	}
+}
+
+// murmurHash64A 与 redis 使用的 MurmurHash64A 一致，按小端序读取
+func murmurHash64A(data []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+	h := seed ^ uint64(len(data))*m
+	for len(data) >= 8 {
+		k := binary.LittleEndian.Uint64(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+		data = data[8:]
+	}
+	if len(data) > 0 {
+		for i := len(data) - 1; i >= 0; i-- {
+			h ^= uint64(data[i]) << (8 * uint(i))
+		}
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
@@ -0,0 +1,67 @@
+package hll
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestHLL(t *testing.T) {
+	h := New()
+	if h.Count() != 0 {
+		t.Errorf("expect 0, actual %d", h.Count())
+	}
+	for _, n := range []int{1, 10, 100, 1000, 10000, 100000} {
+		h = New()
+		for i := 0; i < n; i++ {
+			h.Add([]byte("element" + strconv.Itoa(i)))
+		}
+		// standard error of 16384 registers is 0.81%
+		if err := math.Abs(float64(h.Count())-float64(n)) / float64(n); err > 0.03 {
+			t.Errorf("estimated %d for %d elements", h.Count(), n)
+		}
+		data := h.Bytes()
+		parsed, err := Parse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.registers != h.registers || parsed.Count() != h.Count() || parsed.Encoding() != h.Encoding() {
+			t.Errorf("round trip mismatch of %d elements", n)
+		}
+	}
+	if h.Encoding() != "dense" {
+		t.Errorf("expect dense for 100000 elements, actual %s", h.Encoding())
+	}
+	if h.Add([]byte("element0")) {
+		t.Error("adding existed element should not change registers")
+	}
+}
+
+func TestHLL_Merge(t *testing.T) {
+	a, b := New(), New()
+	for i := 0; i < 1000; i++ {
+		a.Add([]byte(strconv.Itoa(i)))
+		b.Add([]byte(strconv.Itoa(i + 500)))
+	}
+	a.Merge(b)
+	if count := a.Count(); count < 1450 || count > 1550 {
+		t.Errorf("expect about 1500, actual %d", count)
+	}
+}
+
+func TestParse(t *testing.T) {
+	empty := New().Bytes()
+	// an empty sparse HyperLogLog is a single XZERO opcode covering all registers, same as redis
+	if len(empty) != headerSize+2 || empty[headerSize] != 0x7f || empty[headerSize+1] != 0xff {
+		t.Errorf("unexpected empty HyperLogLog %x", empty)
+	}
+	for _, data := range [][]byte{
+		[]byte("hello"),
+		append([]byte("HYLL"), make([]byte, 12)...),                   // dense without registers
+		append(append([]byte("HYLL\x01"), make([]byte, 11)...), 0x00), // sparse covering 1 register
+	} {
+		if _, err := Parse(data); err == nil {
+			t.Errorf("expect error for %q", data)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// Package json 是 RedisJSON 风格的 json 文档，文档解析后以树的形式保存，读写时不需要重新解析整个文档
+// 树中的值为 nil、bool、json.Number、string、*Array 或 *Object，对象保持键的插入顺序，数字保持原样
+// 与其他数据结构一样不保证并发安全，调用方需持有 key 的锁
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// Object is a json object keeping insertion order of keys
+type Object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func makeObject() *Object {
+	return &Object{
+		values: make(map[string]interface{}),
+	}
+}
+
+// Get returns value of key
+func (o *Object) Get(key string) (interface{}, bool) {
+	val, ok := o.values[key]
+	return val, ok
+}
+
+// Set puts key, new key is appended after existing keys
+func (o *Object) Set(key string, val interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = val
+}
+
+// Remove removes key, returns false if key not exists
+func (o *Object) Remove(key string) bool {
+	if _, ok := o.values[key]; !ok {
+		return false
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Len returns number of keys
+func (o *Object) Len() int {
+	return len(o.keys)
+}
+
+// Array is a json array
+type Array struct {
+	elements []interface{}
+}
+
+// Len returns number of elements
+func (a *Array) Len() int {
+	return len(a.elements)
+}
+
+// Document is a parsed json document
+type Document struct {
+	root interface{}
+}
+
+// Parse parses data as a json document
+func Parse(data []byte) (*Document, error) {
+	root, err := parseValue(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{root: root}, nil
+}
+
+// parseValue parses data as exactly one json value
+func parseValue(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	val, err := decodeValue(decoder)
+	if err == io.EOF {
+		return nil, errors.New("expected value")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err = decoder.Token(); err != io.EOF {
+		return nil, errors.New("trailing characters after json value")
+	}
+	return val, nil
+}
+
+// decodeValue 使用 Token 逐个读取，以便保持对象中键的顺序
+func decodeValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		// nil, bool, json.Number or string
+		return token, nil
+	}
+	switch delim {
+	case '{':
+		obj := makeObject()
+		for decoder.More() {
+			token, err = decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := token.(string)
+			val, err := decodeValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			obj.Set(key, val)
+		}
+		// 读取 '}'
+		if _, err = decoder.Token(); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	case '[':
+		arr := &Array{elements: make([]interface{}, 0)}
+		for decoder.More() {
+			val, err := decodeValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			arr.elements = append(arr.elements, val)
+		}
+		if _, err = decoder.Token(); err != nil {
+			return nil, err
+		}
+		return arr, nil
+	}
+	return nil, errors.New("unexpected " + delim.String())
+}
+
+// Format controls serialization, same as INDENT, NEWLINE and SPACE options of JSON.GET
+// the zero value produces compact json
+type Format struct {
+	Indent  string
+	Newline string
+	Space   string
+}
+
+// Marshal serializes the whole document
+func (d *Document) Marshal(format *Format) []byte {
+	buf := &bytes.Buffer{}
+	writeValue(buf, d.root, format, 0)
+	return buf.Bytes()
+}
+
+// MarshalValue serializes a value in document, []interface{} is serialized as an array of values in document
+func MarshalValue(val interface{}, format *Format) []byte {
+	buf := &bytes.Buffer{}
+	writeValue(buf, val, format, 0)
+	return buf.Bytes()
+}
+
+// MarshalObject serializes values as a json object with given keys, len(keys) must equal len(values)
+func MarshalObject(keys []string, values []interface{}, format *Format) []byte {
+	obj := makeObject()
+	for i, key := range keys {
+		obj.Set(key, values[i])
+	}
+	return MarshalValue(obj, format)
+}
+
+func writeNewline(buf *bytes.Buffer, format *Format, level int) {
+	buf.WriteString(format.Newline)
+	for i := 0; i < level; i++ {
+		buf.WriteString(format.Indent)
+	}
+}
+
+func writeValue(buf *bytes.Buffer, val interface{}, format *Format, level int) {
+	switch v := val.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		if v {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case json.Number:
+		buf.WriteString(string(v))
+	case string:
+		writeString(buf, v)
+	case *Array:
+		writeArray(buf, v.elements, format, level)
+	case []interface{}:
+		writeArray(buf, v, format, level)
+	case *Object:
+		buf.WriteByte('{')
+		for i, key := range v.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeNewline(buf, format, level+1)
+			writeString(buf, key)
+			buf.WriteByte(':')
+			buf.WriteString(format.Space)
+			writeValue(buf, v.values[key], format, level+1)
+		}
+		if len(v.keys) > 0 {
+			writeNewline(buf, format, level)
+		}
+		buf.WriteByte('}')
+	}
+}
+
+func writeArray(buf *bytes.Buffer, elements []interface{}, format *Format, level int) {
+	buf.WriteByte('[')
+	for i, element := range elements {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeNewline(buf, format, level+1)
+		writeValue(buf, element, format, level+1)
+	}
+	if len(elements) > 0 {
+		writeNewline(buf, format, level)
+	}
+	buf.WriteByte(']')
+}
+
+const hexDigits = "0123456789abcdef"
+
+// writeString 只转义 json 要求转义的字符，与 encoding/json 不同，不转义 HTML 字符
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			buf.WriteRune(r)
+			i += size
+			continue
+		}
+		switch c {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[c>>4])
+				buf.WriteByte(hexDigits[c&0xf])
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+		i++
+	}
+	buf.WriteByte('"')
+}
+
+// 估算内存时使用的64位平台上的结构大小，与 memsize 包一致
+const (
+	interfaceSize = 16
+	stringHeader  = 16
+	sliceHeader   = 24
+	// mapEntrySize map 中每个键值对: string 头、interface 和 map 开销
+	mapEntrySize = stringHeader + interfaceSize + 8
+)
+
+// Size returns estimated memory usage of the document in bytes
+func (d *Document) Size() int {
+	return interfaceSize + valueSize(d.root)
+}
+
+func valueSize(val interface{}) int {
+	switch v := val.(type) {
+	case json.Number:
+		return stringHeader + len(v)
+	case string:
+		return stringHeader + len(v)
+	case *Array:
+		size := sliceHeader + cap(v.elements)*interfaceSize
+		for _, element := range v.elements {
+			size += valueSize(element)
+		}
+		return size
+	case *Object:
+		size := sliceHeader + cap(v.keys)*stringHeader
+		for _, key := range v.keys {
+			size += len(key) + mapEntrySize + valueSize(v.values[key])
+		}
+		return size
+	}
+	return 0
+}
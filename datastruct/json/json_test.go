@@ -0,0 +1,132 @@
+package json
+
+import (
+	"testing"
+)
+
+func mustParse(t *testing.T, data string) *Document {
+	doc, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func mustParsePath(t *testing.T, path string) *Path {
+	p, err := ParsePath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestParse(t *testing.T) {
+	data := `{"b":1,"a":[true,null,"x<\"\n"],"c":{},"d":1.50}`
+	doc := mustParse(t, data)
+	// 保持键的顺序和数字的原样
+	if actual := string(doc.Marshal(&Format{})); actual != data {
+		t.Errorf("expect %s, actual %s", data, actual)
+	}
+	expected := "{\n  \"b\": 1,\n  \"a\": [\n    true,\n    null,\n    \"x<\\\"\\n\"\n  ],\n  \"c\": {},\n  \"d\": 1.50\n}"
+	if actual := string(doc.Marshal(&Format{Indent: "  ", Newline: "\n", Space: " "})); actual != expected {
+		t.Errorf("expect %s, actual %s", expected, actual)
+	}
+	for _, invalid := range []string{"", "{", `{"a":1}x`, `{"a" 1}`, "[1,]", "1 2"} {
+		if _, err := Parse([]byte(invalid)); err == nil {
+			t.Errorf("expect error for %q", invalid)
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	for _, path := range []string{"$", ".", "a", ".a.b", "$.a[0]", "$['a b'][*]", `$["a"].*`, "$[-1]", "[0]"} {
+		if _, err := ParsePath(path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+	for _, path := range []string{"$..a", "$.", "$[a]", "$['a'", "$[1", "$x"} {
+		if _, err := ParsePath(path); err == nil {
+			t.Errorf("expect error for %s", path)
+		}
+	}
+	if !mustParsePath(t, ".").IsRoot() || !mustParsePath(t, "$").IsRoot() || mustParsePath(t, "$").IsLegacy() {
+		t.Error("unexpected root path")
+	}
+}
+
+func TestGet(t *testing.T) {
+	doc := mustParse(t, `{"a":{"x":1,"y":2},"b":[{"x":3},{"x":4},5],"a b":6}`)
+	cases := []struct {
+		path     string
+		expected string
+	}{
+		{"$", `[{"a":{"x":1,"y":2},"b":[{"x":3},{"x":4},5],"a b":6}]`},
+		{"$.a.x", `[1]`},
+		{"$.a.*", `[1,2]`},
+		{"$.b[*].x", `[3,4]`},
+		{"$.b[-1]", `[5]`},
+		{"$.b[3]", `[]`},
+		{"$['a b']", `[6]`},
+		{"$.c", `[]`},
+		{"$.a[0]", `[]`},
+	}
+	for _, c := range cases {
+		actual := string(MarshalValue(doc.Get(mustParsePath(t, c.path)), &Format{}))
+		if actual != c.expected {
+			t.Errorf("%s: expect %s, actual %s", c.path, c.expected, actual)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	doc := mustParse(t, `{"a":{"x":1},"b":[1,2]}`)
+	set, err := doc.Set(mustParsePath(t, "$.a.x"), []byte(`{"n":true}`), SetAlways)
+	if err != nil || !set {
+		t.Fatalf("set failed: %v", err)
+	}
+	// 新增的键追加在末尾
+	if set, _ = doc.Set(mustParsePath(t, "$.a.y"), []byte(`"v"`), SetAlways); !set {
+		t.Error("expect new key added")
+	}
+	if set, _ = doc.Set(mustParsePath(t, "$.b[*]"), []byte(`{}`), SetAlways); !set {
+		t.Error("expect elements replaced")
+	}
+	if set, _ = doc.Set(mustParsePath(t, "$.missing.y"), []byte(`1`), SetAlways); set {
+		t.Error("missing parent should not be created")
+	}
+	if set, _ = doc.Set(mustParsePath(t, "$.a.x"), []byte(`1`), SetIfNotExists); set {
+		t.Error("NX should not update existing value")
+	}
+	if set, _ = doc.Set(mustParsePath(t, "$.a.z"), []byte(`1`), SetIfExists); set {
+		t.Error("XX should not add new key")
+	}
+	if _, err = doc.Set(mustParsePath(t, "$.a"), []byte(`{`), SetAlways); err == nil {
+		t.Error("expect error for invalid json")
+	}
+	// 通配符匹配的每个位置持有独立的值
+	doc.Set(mustParsePath(t, "$.b[0].k"), []byte(`1`), SetAlways)
+	expected := `{"a":{"x":{"n":true},"y":"v"},"b":[{"k":1},{}]}`
+	if actual := string(doc.Marshal(&Format{})); actual != expected {
+		t.Errorf("expect %s, actual %s", expected, actual)
+	}
+	if set, _ = doc.Set(mustParsePath(t, "$"), []byte(`[1]`), SetAlways); !set || string(doc.Marshal(&Format{})) != "[1]" {
+		t.Error("expect root replaced")
+	}
+}
+
+func TestDel(t *testing.T) {
+	doc := mustParse(t, `{"a":{"x":1,"y":2},"b":[1,2,3,4]}`)
+	if removed := doc.Del(mustParsePath(t, "$.a.x")); removed != 1 {
+		t.Errorf("expect 1 removed, actual %d", removed)
+	}
+	if removed := doc.Del(mustParsePath(t, "$.b[*]")); removed != 4 {
+		t.Errorf("expect 4 removed, actual %d", removed)
+	}
+	if removed := doc.Del(mustParsePath(t, "$.c")); removed != 0 {
+		t.Errorf("expect 0 removed, actual %d", removed)
+	}
+	expected := `{"a":{"y":2},"b":[]}`
+	if actual := string(doc.Marshal(&Format{})); actual != expected {
+		t.Errorf("expect %s, actual %s", expected, actual)
+	}
+}
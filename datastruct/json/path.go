@@ -0,0 +1,257 @@
+package json
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// 路径支持 JSONPath 的子集: 根节点 $、子节点 .key 和 ['key']、数组下标 [n] (负数从尾部计数)、通配符 .* 和 [*]
+// 不以 $ 开头的是 RedisJSON 的旧式路径，如 . 和 .a.b，它只返回第一个匹配的值
+
+var errInvalidPath = errors.New("invalid path")
+
+type segmentKind uint8
+
+const (
+	segmentKey segmentKind = iota
+	segmentIndex
+	segmentWildcard
+)
+
+type segment struct {
+	kind  segmentKind
+	key   string
+	index int
+}
+
+// Path is a parsed path
+type Path struct {
+	raw      string
+	legacy   bool
+	segments []segment
+}
+
+// ParsePath parses JSONPath or legacy path
+func ParsePath(path string) (*Path, error) {
+	p := &Path{raw: path}
+	rest := path
+	if strings.HasPrefix(rest, "$") {
+		rest = rest[1:]
+	} else {
+		p.legacy = true
+		if rest == "." {
+			rest = ""
+		} else if !strings.HasPrefix(rest, ".") && !strings.HasPrefix(rest, "[") {
+			rest = "." + rest
+		}
+	}
+	for len(rest) > 0 {
+		var seg segment
+		var err error
+		switch rest[0] {
+		case '.':
+			seg, rest, err = parseDotSegment(rest[1:])
+		case '[':
+			seg, rest, err = parseBracketSegment(rest[1:])
+		default:
+			err = errInvalidPath
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.segments = append(p.segments, seg)
+	}
+	return p, nil
+}
+
+// parseDotSegment parses .key or .*, rest is the path after dot
+func parseDotSegment(rest string) (segment, string, error) {
+	if strings.HasPrefix(rest, "*") {
+		return segment{kind: segmentWildcard}, rest[1:], nil
+	}
+	end := strings.IndexAny(rest, ".[")
+	if end < 0 {
+		end = len(rest)
+	}
+	// 空的 key，包括不支持的递归下降 ..
+	if end == 0 {
+		return segment{}, "", errInvalidPath
+	}
+	return segment{kind: segmentKey, key: rest[:end]}, rest[end:], nil
+}
+
+// parseBracketSegment parses ['key'], ["key"], [n] or [*], rest is the path after [
+func parseBracketSegment(rest string) (segment, string, error) {
+	if len(rest) > 0 && (rest[0] == '\'' || rest[0] == '"') {
+		quote := rest[0]
+		var key []byte
+		i := 1
+		for ; i < len(rest) && rest[i] != quote; i++ {
+			if rest[i] == '\\' && i+1 < len(rest) {
+				i++
+			}
+			key = append(key, rest[i])
+		}
+		if i+1 >= len(rest) || rest[i+1] != ']' {
+			return segment{}, "", errInvalidPath
+		}
+		return segment{kind: segmentKey, key: string(key)}, rest[i+2:], nil
+	}
+	end := strings.IndexByte(rest, ']')
+	if end < 0 {
+		return segment{}, "", errInvalidPath
+	}
+	content := strings.TrimSpace(rest[:end])
+	if content == "*" {
+		return segment{kind: segmentWildcard}, rest[end+1:], nil
+	}
+	index, err := strconv.Atoi(content)
+	if err != nil {
+		return segment{}, "", errInvalidPath
+	}
+	return segment{kind: segmentIndex, index: index}, rest[end+1:], nil
+}
+
+// IsLegacy returns true if path does not start with $
+func (p *Path) IsLegacy() bool {
+	return p.legacy
+}
+
+// IsRoot returns true if path is the root of document
+func (p *Path) IsRoot() bool {
+	return len(p.segments) == 0
+}
+
+func (p *Path) String() string {
+	return p.raw
+}
+
+// node is a value matched by path and its position in parent, parent is nil for root
+type node struct {
+	value  interface{}
+	parent interface{}
+	key    string
+	index  int
+}
+
+// find returns values matched by segments in document order
+// 与 JSONPath 一致，类型不匹配的节点被忽略，不视为错误
+func (d *Document) find(segments []segment) []node {
+	nodes := []node{{value: d.root}}
+	for _, seg := range segments {
+		var next []node
+		for _, n := range nodes {
+			switch container := n.value.(type) {
+			case *Object:
+				if seg.kind == segmentKey {
+					if val, ok := container.Get(seg.key); ok {
+						next = append(next, node{value: val, parent: container, key: seg.key})
+					}
+				} else if seg.kind == segmentWildcard {
+					for _, key := range container.keys {
+						next = append(next, node{value: container.values[key], parent: container, key: key})
+					}
+				}
+			case *Array:
+				if seg.kind == segmentIndex {
+					index := seg.index
+					if index < 0 {
+						index += len(container.elements)
+					}
+					if index >= 0 && index < len(container.elements) {
+						next = append(next, node{value: container.elements[index], parent: container, index: index})
+					}
+				} else if seg.kind == segmentWildcard {
+					for i, element := range container.elements {
+						next = append(next, node{value: element, parent: container, index: i})
+					}
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// Get returns values matched by path
+func (d *Document) Get(path *Path) []interface{} {
+	nodes := d.find(path.segments)
+	values := make([]interface{}, len(nodes))
+	for i, n := range nodes {
+		values[i] = n.value
+	}
+	return values
+}
+
+// SetPolicy decides whether JSON.SET updates existing values, same as NX and XX options
+type SetPolicy uint8
+
+const (
+	SetAlways SetPolicy = iota
+	SetIfNotExists
+	SetIfExists
+)
+
+// Set replaces values matched by path with value,
+// if nothing matches and the last segment of path is a key, the key is added into matched parent objects.
+// returns false if nothing is set
+func (d *Document) Set(path *Path, value []byte, policy SetPolicy) (bool, error) {
+	// 每个位置需要独立的值，否则修改其中一个会影响其他位置，因此每次重新解析
+	if _, err := parseValue(value); err != nil {
+		return false, err
+	}
+	nodes := d.find(path.segments)
+	if len(nodes) > 0 {
+		if policy == SetIfNotExists {
+			return false, nil
+		}
+		for _, n := range nodes {
+			val, _ := parseValue(value)
+			switch parent := n.parent.(type) {
+			case nil:
+				d.root = val
+			case *Object:
+				parent.Set(n.key, val)
+			case *Array:
+				parent.elements[n.index] = val
+			}
+		}
+		return true, nil
+	}
+	last := len(path.segments) - 1
+	if policy == SetIfExists || last < 0 || path.segments[last].kind != segmentKey {
+		return false, nil
+	}
+	key := path.segments[last].key
+	set := false
+	for _, n := range d.find(path.segments[:last]) {
+		if obj, ok := n.value.(*Object); ok {
+			val, _ := parseValue(value)
+			obj.Set(key, val)
+			set = true
+		}
+	}
+	return set, nil
+}
+
+// Del removes values matched by path, returns number of removed values
+// the root cannot be removed by Del, the caller should remove the whole document
+func (d *Document) Del(path *Path) int {
+	nodes := d.find(path.segments)
+	removed := 0
+	// 通配符匹配的数组元素按下标递增排列，从后往前删除避免下标变化
+	for i := len(nodes) - 1; i >= 0; i-- {
+		switch parent := nodes[i].parent.(type) {
+		case *Object:
+			if parent.Remove(nodes[i].key) {
+				removed++
+			}
+		case *Array:
+			index := nodes[i].index
+			parent.elements = append(parent.elements[:index], parent.elements[index+1:]...)
+			removed++
+		}
+	}
+	return removed
+}
@@ -9,10 +9,12 @@ type Consumer func(i int, v interface{}) bool
 
 type List interface {
 	Add(val interface{})
+	AddFirst(val interface{})
 	Get(index int) (val interface{})
 	Set(index int, val interface{})
 	Insert(index int, val interface{})
 	Remove(index int) (val interface{})
+	RemoveFirst() (val interface{})
 	RemoveLast() (val interface{})
 	RemoveAllByVal(expected Expected) int
 	RemoveByVal(expected Expected, count int) int
@@ -33,6 +33,26 @@ func (list *LinkedList) Add(val interface{}) {
 	list.size++
 }
 
+// AddFirst adds value to the head
+func (list *LinkedList) AddFirst(val interface{}) {
+	if list == nil {
+		panic("list is nil")
+	}
+	n := &node{
+		val: val,
+	}
+	if list.first == nil {
+		// empty list
+		list.first = n
+		list.last = n
+	} else {
+		n.next = list.first
+		list.first.prev = n
+		list.first = n
+	}
+	list.size++
+}
+
 func (list *LinkedList) find(index int) (n *node) {
 	if index < list.size/2 {
 		n = list.first
@@ -133,6 +153,20 @@ func (list *LinkedList) Remove(index int) (val interface{}) {
 	return n.val
 }
 
+// RemoveFirst removes the first element and returns its value
+func (list *LinkedList) RemoveFirst() (val interface{}) {
+	if list == nil {
+		panic("list is nil")
+	}
+	if list.first == nil {
+		// empty list
+		return nil
+	}
+	n := list.first
+	list.removeNode(n)
+	return n.val
+}
+
 // RemoveLast removes the last element and returns its value
 func (list *LinkedList) RemoveLast() (val interface{}) {
 	if list == nil {
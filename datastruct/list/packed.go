@@ -0,0 +1,247 @@
+package list
+
+import "Godis/datastruct/listpack"
+
+// PackedList 元素较少时用 listpack 保存，超过阈值后自动转换为 QuickList
+// QuickList 每页预分配 pageSize 个元素的空间，对只有几个元素的列表浪费较多内存
+// 只能保存 []byte 类型的值，写入其他类型的值时同样会转换，转换后不会再转换回 listpack
+type PackedList struct {
+	pack  *listpack.ListPack
+	quick *QuickList
+	// maxEntries 是 listpack 中最多的元素数，maxValue 是元素的最大字节数
+	maxEntries int
+	maxValue   int
+}
+
+// Encodings of PackedList
+const (
+	EncodingListpack  = "listpack"
+	EncodingQuicklist = "quicklist"
+)
+
+// NewPackedList makes a PackedList, maxEntries <= 0 disables listpack
+func NewPackedList(maxEntries int, maxValue int) *PackedList {
+	l := &PackedList{
+		maxEntries: maxEntries,
+		maxValue:   maxValue,
+	}
+	if maxEntries > 0 {
+		l.pack = listpack.New()
+	} else {
+		l.quick = NewQuickList()
+	}
+	return l
+}
+
+// Encoding returns listpack or quicklist
+func (l *PackedList) Encoding() string {
+	if l.pack != nil {
+		return EncodingListpack
+	}
+	return EncodingQuicklist
+}
+
+// PackedSize returns bytes used by listpack, returns -1 if it has been converted to quicklist
+func (l *PackedList) PackedSize() int {
+	if l.pack == nil {
+		return -1
+	}
+	return l.pack.Size()
+}
+
+func copyBytes(entry []byte) []byte {
+	val := make([]byte, len(entry))
+	copy(val, entry)
+	return val
+}
+
+// prepare 在写入 val 前检查是否需要转换为 QuickList，grow 表示写入后元素数是否增加
+func (l *PackedList) prepare(val interface{}, grow bool) {
+	if l.pack == nil {
+		return
+	}
+	bytes, ok := val.([]byte)
+	if ok && len(bytes) <= l.maxValue && (!grow || l.pack.Len() < l.maxEntries) {
+		return
+	}
+	quick := NewQuickList()
+	l.pack.ForEach(func(i int, entry []byte) bool {
+		quick.Add(copyBytes(entry))
+		return true
+	})
+	l.quick = quick
+	l.pack = nil
+}
+
+func (l *PackedList) Add(val interface{}) {
+	l.prepare(val, true)
+	if l.pack == nil {
+		l.quick.Add(val)
+		return
+	}
+	l.pack.Append(val.([]byte))
+}
+
+func (l *PackedList) AddFirst(val interface{}) {
+	l.prepare(val, true)
+	if l.pack == nil {
+		l.quick.AddFirst(val)
+		return
+	}
+	l.pack.Insert(0, val.([]byte))
+}
+
+func (l *PackedList) Get(index int) (val interface{}) {
+	if l.pack == nil {
+		return l.quick.Get(index)
+	}
+	return l.pack.Get(index)
+}
+
+func (l *PackedList) Set(index int, val interface{}) {
+	l.prepare(val, false)
+	if l.pack == nil {
+		l.quick.Set(index, val)
+		return
+	}
+	l.pack.Set(index, val.([]byte))
+}
+
+func (l *PackedList) Insert(index int, val interface{}) {
+	l.prepare(val, true)
+	if l.pack == nil {
+		l.quick.Insert(index, val)
+		return
+	}
+	l.pack.Insert(index, val.([]byte))
+}
+
+func (l *PackedList) Remove(index int) (val interface{}) {
+	if l.pack == nil {
+		return l.quick.Remove(index)
+	}
+	val = l.pack.Get(index)
+	l.pack.Remove(index, 1)
+	return val
+}
+
+func (l *PackedList) RemoveFirst() (val interface{}) {
+	if l.pack == nil {
+		return l.quick.RemoveFirst()
+	}
+	if l.pack.Len() == 0 {
+		return nil
+	}
+	return l.Remove(0)
+}
+
+func (l *PackedList) RemoveLast() (val interface{}) {
+	if l.pack == nil {
+		return l.quick.RemoveLast()
+	}
+	if l.pack.Len() == 0 {
+		return nil
+	}
+	return l.Remove(l.pack.Len() - 1)
+}
+
+// matchedIndexes 返回匹配的元素下标，reverse 为 true 时从尾部开始匹配，count <= 0 表示不限数量
+func (l *PackedList) matchedIndexes(expected Expected, count int, reverse bool) []int {
+	var indexes []int
+	l.pack.ForEach(func(i int, entry []byte) bool {
+		if expected(copyBytes(entry)) {
+			indexes = append(indexes, i)
+		}
+		return true
+	})
+	if count > 0 && len(indexes) > count {
+		if reverse {
+			indexes = indexes[len(indexes)-count:]
+		} else {
+			indexes = indexes[:count]
+		}
+	}
+	return indexes
+}
+
+func (l *PackedList) removeIndexes(indexes []int) int {
+	// 从后向前删除，前面元素的下标不受影响
+	for i := len(indexes) - 1; i >= 0; i-- {
+		l.pack.Remove(indexes[i], 1)
+	}
+	return len(indexes)
+}
+
+func (l *PackedList) RemoveAllByVal(expected Expected) int {
+	if l.pack == nil {
+		return l.quick.RemoveAllByVal(expected)
+	}
+	return l.removeIndexes(l.matchedIndexes(expected, 0, false))
+}
+
+func (l *PackedList) RemoveByVal(expected Expected, count int) int {
+	if l.pack == nil {
+		return l.quick.RemoveByVal(expected, count)
+	}
+	return l.removeIndexes(l.matchedIndexes(expected, count, false))
+}
+
+func (l *PackedList) ReverseRemoveByVal(expected Expected, count int) int {
+	if l.pack == nil {
+		return l.quick.ReverseRemoveByVal(expected, count)
+	}
+	return l.removeIndexes(l.matchedIndexes(expected, count, true))
+}
+
+func (l *PackedList) Len() int {
+	if l.pack == nil {
+		return l.quick.Len()
+	}
+	return l.pack.Len()
+}
+
+// ForEach 遍历 listpack 时传给 consumer 的值是副本，可以保留
+func (l *PackedList) ForEach(consumer Consumer) {
+	if l.pack == nil {
+		l.quick.ForEach(consumer)
+		return
+	}
+	l.pack.ForEach(func(i int, entry []byte) bool {
+		return consumer(i, copyBytes(entry))
+	})
+}
+
+func (l *PackedList) Contains(expected Expected) bool {
+	if l.pack == nil {
+		return l.quick.Contains(expected)
+	}
+	contains := false
+	l.pack.ForEach(func(i int, entry []byte) bool {
+		contains = expected(copyBytes(entry))
+		return !contains
+	})
+	return contains
+}
+
+func (l *PackedList) Range(start int, stop int) []interface{} {
+	if l.pack == nil {
+		return l.quick.Range(start, stop)
+	}
+	if start < 0 || start >= l.pack.Len() {
+		panic("`start` out of range")
+	}
+	if stop < start || stop > l.pack.Len() {
+		panic("`stop` out of range")
+	}
+	result := make([]interface{}, 0, stop-start)
+	l.pack.ForEach(func(i int, entry []byte) bool {
+		if i >= stop {
+			return false
+		}
+		if i >= start {
+			result = append(result, copyBytes(entry))
+		}
+		return true
+	})
+	return result
+}
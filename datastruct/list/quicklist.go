@@ -49,6 +49,45 @@ func (ql *QuickList) Add(val interface{}) {
 	backNode.Value = backPage
 }
 
+// AddFirst adds value to the head
+func (ql *QuickList) AddFirst(val interface{}) {
+	ql.size++
+	if ql.data.Len() > 0 {
+		frontNode := ql.data.Front()
+		frontPage := frontNode.Value.([]interface{})
+		if len(frontPage) < cap(frontPage) {
+			// shift elements in page
+			frontPage = append(frontPage, nil)
+			copy(frontPage[1:], frontPage)
+			frontPage[0] = val
+			frontNode.Value = frontPage
+			return
+		}
+	}
+	// empty list or full page, create new page
+	page := make([]interface{}, 0, pageSize)
+	page = append(page, val)
+	ql.data.PushFront(page)
+}
+
+// RemoveFirst removes the first element and returns its value
+func (ql *QuickList) RemoveFirst() interface{} {
+	if ql.Len() == 0 {
+		return nil
+	}
+	ql.size--
+	frontNode := ql.data.Front()
+	frontPage := frontNode.Value.([]interface{})
+	val := frontPage[0]
+	if len(frontPage) == 1 {
+		ql.data.Remove(frontNode)
+		return val
+	}
+	frontPage[0] = nil // for gc
+	frontNode.Value = frontPage[1:]
+	return val
+}
+
 // find returns page and in-page-offset of given index
 func (ql *QuickList) find(index int) *iterator {
 	if ql == nil {
@@ -388,3 +427,70 @@ func (ql *QuickList) Range(start int, stop int) []interface{} {
 	}
 	return slice
 }
+
+// Iterator traverses QuickList in both directions and supports modification during traversal
+// Iterator is invalid after the list is modified by methods other than Iterator's
+type Iterator struct {
+	iter  *iterator
+	index int
+}
+
+// IteratorAt returns an Iterator pointing to the element at the given index, the index should between [0, list.size)
+func (ql *QuickList) IteratorAt(index int) *Iterator {
+	return &Iterator{
+		iter:  ql.find(index),
+		index: index,
+	}
+}
+
+// Valid returns whether iterator points to an element
+func (it *Iterator) Valid() bool {
+	return it.index >= 0 && it.index < it.iter.ql.size
+}
+
+// Index returns index of current element
+func (it *Iterator) Index() int {
+	return it.index
+}
+
+// Value returns current element
+func (it *Iterator) Value() interface{} {
+	if !it.Valid() {
+		panic("iterator out of bound")
+	}
+	return it.iter.get()
+}
+
+// Next moves to the next element, returns false if there is no next element
+func (it *Iterator) Next() bool {
+	if !it.Valid() {
+		return false
+	}
+	it.index++
+	return it.iter.next()
+}
+
+// Prev moves to the previous element, returns false if there is no previous element
+func (it *Iterator) Prev() bool {
+	if !it.Valid() {
+		return false
+	}
+	it.index--
+	return it.iter.prev()
+}
+
+// Set updates current element
+func (it *Iterator) Set(val interface{}) {
+	if !it.Valid() {
+		panic("iterator out of bound")
+	}
+	it.iter.set(val)
+}
+
+// Remove removes current element and returns its value, then iterator points to the next element
+func (it *Iterator) Remove() interface{} {
+	if !it.Valid() {
+		panic("iterator out of bound")
+	}
+	return it.iter.remove()
+}
@@ -0,0 +1,152 @@
+package list
+
+import (
+	"testing"
+)
+
+// checkList compares all elements of ql with expected
+func checkList(t *testing.T, ql *QuickList, expected []interface{}) {
+	t.Helper()
+	if ql.Len() != len(expected) {
+		t.Fatalf("expect len %d, actual %d", len(expected), ql.Len())
+	}
+	if len(expected) == 0 {
+		return
+	}
+	actual := ql.Range(0, ql.Len())
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatalf("expect %v at %d, actual %v", expected[i], i, actual[i])
+		}
+	}
+	// 分页边界处的随机访问
+	for _, i := range []int{0, pageSize - 1, pageSize, len(expected) - 1} {
+		if i < len(expected) && ql.Get(i) != expected[i] {
+			t.Fatalf("expect %v at %d, actual %v", expected[i], i, ql.Get(i))
+		}
+	}
+}
+
+func TestQuickListHead(t *testing.T) {
+	type op struct {
+		kind string // add, addFirst, removeFirst or removeLast
+		n    int
+	}
+	tests := []struct {
+		name string
+		ops  []op
+	}{
+		{"add first within a page", []op{{"addFirst", 10}}},
+		{"add first across pages", []op{{"addFirst", pageSize*2 + 3}}},
+		{"remove first across pages", []op{{"add", pageSize*2 + 3}, {"removeFirst", pageSize + 5}}},
+		{"add first after remove first", []op{{"add", pageSize}, {"removeFirst", 1}, {"addFirst", 2}}},
+		{"head and tail", []op{{"add", pageSize}, {"addFirst", pageSize + 1}, {"removeFirst", pageSize + 5}, {"removeLast", 10}}},
+		{"remove first until empty", []op{{"addFirst", 5}, {"removeFirst", 6}, {"addFirst", 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ql := NewQuickList()
+			var expected []interface{}
+			next := 0
+			for _, o := range tt.ops {
+				for i := 0; i < o.n; i++ {
+					switch o.kind {
+					case "add":
+						ql.Add(next)
+						expected = append(expected, next)
+						next++
+					case "addFirst":
+						ql.AddFirst(next)
+						expected = append([]interface{}{next}, expected...)
+						next++
+					case "removeFirst":
+						var want interface{}
+						if len(expected) > 0 {
+							want = expected[0]
+							expected = expected[1:]
+						}
+						if val := ql.RemoveFirst(); val != want {
+							t.Fatalf("expect RemoveFirst returns %v, actual %v", want, val)
+						}
+					case "removeLast":
+						want := expected[len(expected)-1]
+						expected = expected[:len(expected)-1]
+						if val := ql.RemoveLast(); val != want {
+							t.Fatalf("expect RemoveLast returns %v, actual %v", want, val)
+						}
+					}
+				}
+			}
+			checkList(t, ql, expected)
+		})
+	}
+}
+
+func TestQuickListIterator(t *testing.T) {
+	size := pageSize*2 + 10
+	makeList := func() (*QuickList, []interface{}) {
+		ql := NewQuickList()
+		expected := make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			ql.Add(i)
+			expected = append(expected, i)
+		}
+		return ql, expected
+	}
+
+	ql, expected := makeList()
+	it := ql.IteratorAt(0)
+	for i := 0; i < size; i++ {
+		if it.Index() != i || it.Value() != expected[i] {
+			t.Fatalf("expect %v at %d, actual %v at %d", expected[i], i, it.Value(), it.Index())
+		}
+		it.Next()
+	}
+	if it.Valid() || it.Next() {
+		t.Error("expect iterator at end")
+	}
+	it = ql.IteratorAt(size - 1)
+	for i := size - 1; i >= 0; i-- {
+		if it.Value() != expected[i] {
+			t.Fatalf("expect %v at %d, actual %v", expected[i], i, it.Value())
+		}
+		it.Prev()
+	}
+	if it.Valid() || it.Prev() {
+		t.Error("expect iterator before begin")
+	}
+
+	tests := []struct {
+		name   string
+		start  int
+		remove func(v int) bool
+		set    func(v int) int
+	}{
+		{"remove all", 0, func(v int) bool { return true }, nil},
+		{"remove evens", 0, func(v int) bool { return v%2 == 0 }, nil},
+		{"remove page boundaries", 0, func(v int) bool { return v%pageSize == 0 || v%pageSize == pageSize-1 }, nil},
+		{"remove tail from middle", size / 2, func(v int) bool { return true }, nil},
+		{"set", 0, func(v int) bool { return false }, func(v int) int { return -v }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ql, expected := makeList()
+			remaining := expected[:tt.start:tt.start]
+			for it := ql.IteratorAt(tt.start); it.Valid(); {
+				v := it.Value().(int)
+				if tt.remove(v) {
+					if removed := it.Remove(); removed != v {
+						t.Fatalf("expect removed %d, actual %v", v, removed)
+					}
+					continue
+				}
+				if tt.set != nil {
+					it.Set(tt.set(v))
+				}
+				remaining = append(remaining, it.Value())
+				it.Next()
+			}
+			checkList(t, ql, remaining)
+		})
+	}
+}
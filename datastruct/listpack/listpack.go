@@ -0,0 +1,142 @@
+// Package listpack 紧凑编码的字节串列表，所有元素连续存放在一个 []byte 中
+// 用于元素较少的 hash、list 和 zset，避免为每个小对象分配完整的 map、跳表或 quicklist 页
+// 随机访问、插入和删除都需要从头遍历，时间复杂度为 O(N)，只适合保存少量元素
+package listpack
+
+import "encoding/binary"
+
+// 每个元素编码为 uvarint 长度前缀 + 内容
+
+// ListPack is a compact list of byte strings stored in one continuous buffer
+type ListPack struct {
+	buf []byte
+	n   int
+}
+
+// New makes an empty ListPack
+func New() *ListPack {
+	return &ListPack{}
+}
+
+// Len returns number of entries
+func (lp *ListPack) Len() int {
+	return lp.n
+}
+
+// Size returns bytes used by encoded entries
+func (lp *ListPack) Size() int {
+	return len(lp.buf)
+}
+
+// offset returns the position of the i-th entry in buf, returns len(buf) if i == n
+func (lp *ListPack) offset(i int) int {
+	pos := 0
+	for ; i > 0; i-- {
+		size, n := binary.Uvarint(lp.buf[pos:])
+		pos += n + int(size)
+	}
+	return pos
+}
+
+func encodedLen(entries [][]byte) int {
+	total := 0
+	for _, entry := range entries {
+		total += uvarintLen(uint64(len(entry))) + len(entry)
+	}
+	return total
+}
+
+func uvarintLen(x uint64) int {
+	n := 1
+	for x >= 0x80 {
+		x >>= 7
+		n++
+	}
+	return n
+}
+
+// splice replaces count entries starting at index with entries
+func (lp *ListPack) splice(index int, count int, entries ...[]byte) {
+	if index < 0 || count < 0 || index+count > lp.n {
+		panic("listpack: index out of bound")
+	}
+	start := lp.offset(index)
+	end := start
+	for i := 0; i < count; i++ {
+		size, n := binary.Uvarint(lp.buf[end:])
+		end += n + int(size)
+	}
+	added := encodedLen(entries)
+	tail := len(lp.buf) - end
+	newLen := start + added + tail
+	if newLen > cap(lp.buf) {
+		buf := make([]byte, newLen, newLen+newLen/4)
+		copy(buf, lp.buf[:start])
+		copy(buf[start+added:], lp.buf[end:])
+		lp.buf = buf
+	} else {
+		old := lp.buf
+		lp.buf = lp.buf[:newLen]
+		copy(lp.buf[start+added:], old[end:end+tail])
+	}
+	pos := start
+	for _, entry := range entries {
+		pos += binary.PutUvarint(lp.buf[pos:], uint64(len(entry)))
+		pos += copy(lp.buf[pos:], entry)
+	}
+	lp.n += len(entries) - count
+}
+
+// Append adds entries to the tail
+func (lp *ListPack) Append(entries ...[]byte) {
+	lp.splice(lp.n, 0, entries...)
+}
+
+// Insert inserts entries before the index-th entry, index == Len() means appending
+func (lp *ListPack) Insert(index int, entries ...[]byte) {
+	lp.splice(index, 0, entries...)
+}
+
+// Set replaces the index-th entry
+func (lp *ListPack) Set(index int, entry []byte) {
+	lp.splice(index, 1, entry)
+}
+
+// Remove removes count entries starting at index
+func (lp *ListPack) Remove(index int, count int) {
+	lp.splice(index, count)
+}
+
+// Get returns a copy of the index-th entry
+func (lp *ListPack) Get(index int) []byte {
+	if index < 0 || index >= lp.n {
+		panic("listpack: index out of bound")
+	}
+	pos := lp.offset(index)
+	size, n := binary.Uvarint(lp.buf[pos:])
+	pos += n
+	entry := make([]byte, size)
+	copy(entry, lp.buf[pos:pos+int(size)])
+	return entry
+}
+
+// ForEach visits entries from head to tail, stops if consumer returns false
+// entry shares memory with the ListPack, consumer must copy it before retaining or modifying
+func (lp *ListPack) ForEach(consumer func(i int, entry []byte) bool) {
+	pos := 0
+	for i := 0; i < lp.n; i++ {
+		size, n := binary.Uvarint(lp.buf[pos:])
+		pos += n
+		entry := lp.buf[pos : pos+int(size) : pos+int(size)]
+		pos += int(size)
+		if !consumer(i, entry) {
+			return
+		}
+	}
+}
+
+// Clear removes all entries
+func (lp *ListPack) Clear() {
+	lp.buf = nil
+	lp.n = 0
+}
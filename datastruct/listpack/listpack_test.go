@@ -0,0 +1,48 @@
+package listpack
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func toStrings(lp *ListPack) []string {
+	var result []string
+	lp.ForEach(func(i int, entry []byte) bool {
+		result = append(result, string(entry))
+		return true
+	})
+	return result
+}
+
+func TestListPack(t *testing.T) {
+	lp := New()
+	for i := 0; i < 10; i++ {
+		lp.Append([]byte(strconv.Itoa(i)))
+	}
+	lp.Insert(0, []byte("head"))
+	lp.Insert(lp.Len(), []byte("tail"))
+	lp.Set(5, []byte(strings.Repeat("x", 200))) // long entry needs 2 bytes length prefix
+	lp.Remove(1, 2)
+	expected := []string{"head", "2", "3", strings.Repeat("x", 200), "5", "6", "7", "8", "9", "tail"}
+	actual := toStrings(lp)
+	if strings.Join(actual, ",") != strings.Join(expected, ",") {
+		t.Errorf("expect %v, actual %v", expected, actual)
+	}
+	if lp.Len() != len(expected) {
+		t.Errorf("expect len %d, actual %d", len(expected), lp.Len())
+	}
+	for i, s := range expected {
+		if string(lp.Get(i)) != s {
+			t.Errorf("expect %s at %d, actual %s", s, i, lp.Get(i))
+		}
+	}
+	lp.Set(3, []byte("4"))
+	if string(lp.Get(3)) != "4" || string(lp.Get(4)) != "5" {
+		t.Error("set shorter entry failed")
+	}
+	lp.Remove(0, lp.Len())
+	if lp.Len() != 0 || lp.Size() != 0 {
+		t.Error("expect empty")
+	}
+}
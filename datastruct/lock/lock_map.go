@@ -1,8 +1,10 @@
 package lock
 
 import (
+	"context"
 	"sort"
 	"sync"
+	"time"
 )
 
 // 在concurrent.go中实现的ConCurrentDict中使用了分段锁，保证了对于单个key的并发读写问题
@@ -82,7 +84,7 @@ func (locks *Locks) Locks(keys ...string) {
 	// 先得到加锁顺序
 	indices := locks.toLockIndices(keys, false)
 	// 再依序加锁
-	for index := range indices {
+	for _, index := range indices {
 		mu := locks.table[index]
 		mu.Lock()
 	}
@@ -100,7 +102,8 @@ func (locks *Locks) RLocks(keys ...string) {
 
 // RWLocks locks write keys and read keys together. allow duplicate keys
 func (locks *Locks) RWLocks(writeKeys []string, readKeys []string) {
-	keys := append(writeKeys, readKeys...)
+	// 使用完整切片表达式，避免 append 修改调用方的底层数组
+	keys := append(writeKeys[:len(writeKeys):len(writeKeys)], readKeys...)
 	indices := locks.toLockIndices(keys, false)
 	// 需要写的键放在一个map中
 	writeIndexSet := make(map[uint32]struct{})
@@ -122,7 +125,7 @@ func (locks *Locks) RWLocks(writeKeys []string, readKeys []string) {
 
 func (locks *Locks) UnLocks(keys ...string) {
 	indices := locks.toLockIndices(keys, false)
-	for index := range indices {
+	for _, index := range indices {
 		mu := locks.table[index]
 		mu.Unlock()
 	}
@@ -137,7 +140,7 @@ func (locks *Locks) RUnLocks(keys ...string) {
 }
 
 func (locks *Locks) RWUnLocks(writeKeys []string, readKeys []string) {
-	keys := append(writeKeys, readKeys...)
+	keys := append(writeKeys[:len(writeKeys):len(writeKeys)], readKeys...)
 	indices := locks.toLockIndices(keys, false)
 	// 需要写的键放在一个map中
 	writeIndexSet := make(map[uint32]struct{})
@@ -157,6 +160,67 @@ func (locks *Locks) RWUnLocks(writeKeys []string, readKeys []string) {
 	}
 }
 
+/* ---- Try Lock ---- */
+// RWLocks 会一直阻塞直到获得所有锁，持有锁的协程出现问题时调用方会被永远卡住
+// TryRWLocks 使用 TryLock 尝试按相同的顺序加锁，有锁被占用时释放已获得的锁，稍后重试，
+// 直到获得所有锁或 ctx 结束。重试期间不持有任何锁，因此不会与 RWLocks 形成死锁
+// 注意一直被占用的锁可能使 TryRWLocks 饿死，调用方需通过 ctx 设置超时
+
+// TryLockRetryInterval 两次尝试加锁之间的间隔
+const TryLockRetryInterval = time.Millisecond
+
+// TryRWLocks locks write keys and read keys together, gives up and returns ctx.Err() when ctx is done
+// all locks are released on failure
+func (locks *Locks) TryRWLocks(ctx context.Context, writeKeys []string, readKeys []string) error {
+	keys := append(writeKeys[:len(writeKeys):len(writeKeys)], readKeys...)
+	indices := locks.toLockIndices(keys, false)
+	writeIndexSet := make(map[uint32]struct{})
+	for _, wKey := range writeKeys {
+		writeIndexSet[locks.spread(fnv32(wKey))] = struct{}{}
+	}
+	return RetryUntil(ctx, func() bool {
+		for i, index := range indices {
+			_, w := writeIndexSet[index]
+			mu := locks.table[index]
+			if w && mu.TryLock() || !w && mu.TryRLock() {
+				continue
+			}
+			// 释放已获得的锁
+			for _, acquired := range indices[:i] {
+				if _, w := writeIndexSet[acquired]; w {
+					locks.table[acquired].Unlock()
+				} else {
+					locks.table[acquired].RUnlock()
+				}
+			}
+			return false
+		}
+		return true
+	})
+}
+
+// RetryUntil calls try until it returns true, returns ctx.Err() if ctx is done before that
+// try is called at least once
+func RetryUntil(ctx context.Context, try func() bool) error {
+	var timer *time.Timer
+	for {
+		if try() {
+			return nil
+		}
+		if timer == nil {
+			timer = time.NewTimer(TryLockRetryInterval)
+			defer timer.Stop()
+		} else {
+			timer.Reset(TryLockRetryInterval)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
 /* ---- Hash Functions ---- */
 
 const prime32 = uint32(16777619)
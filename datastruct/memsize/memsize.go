@@ -0,0 +1,224 @@
+// Package memsize 估算key和值占用的内存，用于 MEMORY USAGE 命令以及 maxmemory 的内存统计
+// 估算值基于64位平台上Go运行时的内存布局，忽略内存分配器的对齐和碎片，只用于比较和统计，不是精确值
+package memsize
+
+import (
+	"Godis/datastruct/bloom"
+	"Godis/datastruct/cuckoo"
+	"Godis/datastruct/dict"
+	"Godis/datastruct/hash"
+	"Godis/datastruct/json"
+	"Godis/datastruct/list"
+	"Godis/datastruct/set"
+	"Godis/datastruct/sortedset"
+	"Godis/datastruct/timeseries"
+	"Godis/interface/database"
+)
+
+// 64位平台上常用结构的大小
+const (
+	pointerSize   = 8
+	stringHeader  = 16 // data pointer + len
+	sliceHeader   = 24 // data pointer + len + cap
+	interfaceSize = 16 // type pointer + data pointer
+	float64Size   = 8
+	int64Size     = 8
+	timeSize      = 24 // time.Time: wall, ext, loc
+
+	// mapEntryOverhead map中每个键值对除键值本身以外的开销
+	// 包括 tophash、溢出桶指针，以及装载因子(6.5/8)导致的空闲槽位，按平均值估算
+	mapEntryOverhead = 8
+	// mapHeader map本身的开销(hmap结构体)
+	mapHeader = 48
+
+	// quickListPageSize 与 list.QuickList 的页大小保持一致
+	quickListPageSize = 1024
+	// listElementSize container/list 的节点: next, prev, list 指针和 Value 接口
+	listElementSize = 3*pointerSize + interfaceSize
+	// linkedNodeSize list.LinkedList 的节点: val 接口和 prev, next 指针
+	linkedNodeSize = interfaceSize + 2*pointerSize
+
+	// skiplistAvgLevel 跳表节点层数的期望值，每层晋升的概率为1/4，期望为 1/(1-1/4)
+	skiplistAvgLevel = 4.0 / 3
+	// skiplistLevelSize 跳表每一层: slice 中的 *Level 指针和 Level 结构体(forward 指针 + span)
+	skiplistLevelSize = pointerSize + pointerSize + int64Size
+
+	// embStrSize database.EmbStr: 长度和固定大小的缓冲区
+	embStrSize = 1 + 44
+
+	// entitySize *DataEntity 指向的 DataEntity 结构体
+	entitySize = interfaceSize
+
+	// packedHeader listpack 编码的对象: 两个指针、两个阈值和 ListPack 结构体(buf 切片 + 元素数)
+	packedHeader = 2*pointerSize + 2*int64Size + sliceHeader + int64Size
+)
+
+// packed 是 listpack 编码的对象，PackedSize 返回-1表示已转换为完整结构
+type packed interface {
+	PackedSize() int
+}
+
+// DictEntrySize DB的数据字典中每个key除key字符串外的开销: 分段 map 中的 string 头、*DataEntity 指针和 map 开销
+const DictEntrySize = stringHeader + pointerSize + mapEntryOverhead
+
+// TTLEntrySize ttlMap 中每个设置了过期时间的key的开销: string 头、time.Time 和 map 开销
+const TTLEntrySize = stringHeader + timeSize + mapEntryOverhead
+
+// SizeOf 估算key在DB数据字典中占用的内存，包括key字符串本身和字典的开销
+func SizeOf(key string) int64 {
+	return int64(len(key) + DictEntrySize)
+}
+
+// MemUsage 估算 DataEntity 占用的内存，会遍历集合中的所有元素
+func MemUsage(entity *database.DataEntity) int64 {
+	return MemUsageSampled(entity, 0)
+}
+
+// MemUsageSampled 估算 DataEntity 占用的内存
+// samples 大于0时只遍历集合中的前 samples 个元素，用它们的平均大小估算整个集合，与 MEMORY USAGE 的 SAMPLES 选项一致
+func MemUsageSampled(entity *database.DataEntity, samples int) int64 {
+	if entity == nil {
+		return 0
+	}
+	return entitySize + valueSize(entity.Data, samples)
+}
+
+func valueSize(val interface{}, samples int) int64 {
+	// 哈希表在 listpack 编码时也需要计入字段过期时间
+	if h, ok := val.(*hash.Hash); ok {
+		return hashSize(h, samples)
+	}
+	// listpack 编码的对象直接使用编码后的大小
+	if p, ok := val.(packed); ok {
+		if size := p.PackedSize(); size >= 0 {
+			return int64(packedHeader + size)
+		}
+	}
+	switch v := val.(type) {
+	case []byte:
+		return int64(sliceHeader + cap(v))
+	case int64:
+		return int64Size
+	case *database.EmbStr:
+		return embStrSize
+	case string:
+		return int64(stringHeader + len(v))
+	case list.List:
+		return listSize(v, samples)
+	case *dict.ExpireDict:
+		size := dictSize(v, samples)
+		// 记录过期时间的 map 与 ttlMap 结构相同，key字符串与数据共享
+		size += mapHeader + int64(v.ExpiresCount())*TTLEntrySize
+		return size
+	case dict.Dict:
+		return dictSize(v, samples)
+	case *set.Set:
+		return setSize(v, samples)
+	case *sortedset.SortedSet:
+		return sortedSetSize(v, samples)
+	case *bloom.Filter:
+		return int64(v.Size())
+	case *cuckoo.Filter:
+		return int64(v.Size())
+	case *timeseries.Series:
+		return int64(v.Size())
+	case *json.Document:
+		return int64(v.Size())
+	}
+	return 0
+}
+
+// sampler 累计前 samples 个元素的大小，并按元素总数等比例放大
+type sampler struct {
+	limit int
+	count int
+	sum   int64
+}
+
+// add 返回false时停止遍历
+func (s *sampler) add(size int64) bool {
+	s.count++
+	s.sum += size
+	return s.limit <= 0 || s.count < s.limit
+}
+
+func (s *sampler) estimate(total int) int64 {
+	if s.count == 0 {
+		return 0
+	}
+	if s.count >= total {
+		return s.sum
+	}
+	return s.sum * int64(total) / int64(s.count)
+}
+
+func listSize(l list.List, samples int) int64 {
+	total := l.Len()
+	s := &sampler{limit: samples}
+	l.ForEach(func(i int, v interface{}) bool {
+		return s.add(valueSize(v, 0))
+	})
+	size := s.estimate(total)
+	switch l.(type) {
+	case *list.QuickList, *list.PackedList:
+		// 已转换为 quicklist 的 PackedList 与 QuickList 相同
+		// 每页是容量为 pageSize 的 []interface{}，挂在 container/list 的节点上
+		pages := (total + quickListPageSize - 1) / quickListPageSize
+		size += int64(pages) * (quickListPageSize*interfaceSize + listElementSize + sliceHeader)
+	default:
+		size += int64(total) * linkedNodeSize
+	}
+	return size
+}
+
+func dictSize(d dict.Dict, samples int) int64 {
+	total := d.Len()
+	s := &sampler{limit: samples}
+	d.ForEach(func(key string, val interface{}) bool {
+		return s.add(int64(len(key)) + valueSize(val, 0))
+	})
+	return mapHeader + int64(total)*(stringHeader+interfaceSize+mapEntryOverhead) + s.estimate(total)
+}
+
+func hashSize(h *hash.Hash, samples int) int64 {
+	var size int64
+	if packedSize := h.PackedSize(); packedSize >= 0 {
+		size = int64(packedHeader + packedSize)
+	} else {
+		total := h.Len()
+		s := &sampler{limit: samples}
+		h.ForEach(func(field string, value []byte) bool {
+			return s.add(int64(len(field)) + valueSize(value, 0))
+		})
+		size = mapHeader + int64(total)*(stringHeader+interfaceSize+mapEntryOverhead) + s.estimate(total)
+	}
+	if expires := h.ExpiresCount(); expires > 0 {
+		// 记录过期时间的 map 与 ttlMap 结构相同，key字符串与数据共享
+		size += mapHeader + int64(expires)*TTLEntrySize
+	}
+	return size
+}
+
+func setSize(st *set.Set, samples int) int64 {
+	total := st.Len()
+	s := &sampler{limit: samples}
+	st.ForEach(func(member string) bool {
+		return s.add(int64(len(member)))
+	})
+	// 集合底层是值为nil的字典
+	return mapHeader + int64(total)*(stringHeader+interfaceSize+mapEntryOverhead) + s.estimate(total)
+}
+
+func sortedSetSize(z *sortedset.SortedSet, samples int) int64 {
+	total := int(z.Len())
+	s := &sampler{limit: samples}
+	if total > 0 {
+		z.ForEachByRank(0, int64(total), false, func(element *sortedset.Element) bool {
+			return s.add(int64(len(element.Member)))
+		})
+	}
+	// 成员字符串由字典和跳表共享
+	dictEntry := stringHeader + pointerSize + mapEntryOverhead
+	skiplistNode := stringHeader + float64Size + pointerSize + sliceHeader + skiplistAvgLevel*skiplistLevelSize
+	return mapHeader + int64(float64(total)*(float64(dictEntry)+skiplistNode)) + s.estimate(total)
+}
@@ -0,0 +1,45 @@
+package memsize
+
+import (
+	"Godis/datastruct/dict"
+	"Godis/datastruct/list"
+	"Godis/datastruct/set"
+	"Godis/datastruct/sortedset"
+	"Godis/interface/database"
+	"strconv"
+	"testing"
+)
+
+func TestMemUsage(t *testing.T) {
+	if SizeOf("key") != int64(3+DictEntrySize) {
+		t.Errorf("wrong key size %d", SizeOf("key"))
+	}
+	str := &database.DataEntity{Data: make([]byte, 100)}
+	if MemUsage(str) < 100 {
+		t.Errorf("string size %d is less than its content", MemUsage(str))
+	}
+
+	l := list.NewQuickList()
+	hash := dict.MakeSimple()
+	s := set.Make()
+	z := sortedset.Make()
+	for i := 0; i < 1000; i++ {
+		member := "member" + strconv.Itoa(1000+i)
+		l.Add([]byte(member))
+		hash.Put(member, []byte(member))
+		s.Add(member)
+		z.Add(member, float64(i))
+	}
+	for _, data := range []interface{}{l, hash, s, z} {
+		entity := &database.DataEntity{Data: data}
+		exact := MemUsage(entity)
+		if exact < 1000*int64(len("member000")) {
+			t.Errorf("%T size %d is less than its content", data, exact)
+		}
+		// 元素大小接近，采样估算的结果应与遍历全部元素相近
+		sampled := MemUsageSampled(entity, 10)
+		if sampled < exact*9/10 || sampled > exact*11/10 {
+			t.Errorf("%T sampled size %d is far from %d", data, sampled, exact)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package set
+
+import "strconv"
+
+// 集合运算的代价估计，单位是成员的查找或插入次数
+// 对百万级的集合做运算可能长时间占用锁，慢命令日志中会附上代价估计，便于确认是哪个集合导致的
+
+// Algorithms of set operations
+const (
+	AlgorithmIterateSmallest = "iterate-smallest"
+	AlgorithmProbe           = "probe"
+	AlgorithmCopyRemove      = "copy-remove"
+	AlgorithmMerge           = "merge"
+)
+
+// Cost is the estimated work of a set operation
+type Cost struct {
+	Algorithm string
+	// Work is estimated number of member lookups and insertions
+	Work int
+	// Sizes are sizes of the operands
+	Sizes []int
+}
+
+func (cost Cost) String() string {
+	s := "algorithm=" + cost.Algorithm + " work=" + strconv.Itoa(cost.Work) + " sizes="
+	for i, size := range cost.Sizes {
+		if i > 0 {
+			s += ","
+		}
+		s += strconv.Itoa(size)
+	}
+	return s
+}
+
+func sizesOf(sets []*Set) []int {
+	sizes := make([]int, len(sets))
+	for i, set := range sets {
+		sizes[i] = set.Len()
+	}
+	return sizes
+}
+
+// IntersectCost estimates Intersect and IntersectCard, limit > 0 means counting stops at limit
+// the smallest set is iterated and its members are looked up in the others
+func IntersectCost(limit int, sets ...*Set) Cost {
+	sizes := sizesOf(sets)
+	smallest := 0
+	for i, size := range sizes {
+		if i == 0 || size < smallest {
+			smallest = size
+		}
+	}
+	if limit > 0 && limit < smallest {
+		// 提前结束时至少需要检查 limit 个成员
+		smallest = limit
+	}
+	work := 0
+	if len(sets) > 0 {
+		work = smallest * len(sets)
+	}
+	return Cost{
+		Algorithm: AlgorithmIterateSmallest,
+		Work:      work,
+		Sizes:     sizes,
+	}
+}
+
+// UnionCost estimates Union, every member of every set is inserted into the result
+func UnionCost(sets ...*Set) Cost {
+	sizes := sizesOf(sets)
+	work := 0
+	for _, size := range sizes {
+		work += size
+	}
+	return Cost{
+		Algorithm: AlgorithmMerge,
+		Work:      work,
+		Sizes:     sizes,
+	}
+}
+
+// DiffCost estimates Diff and selects its algorithm in the same way as redis:
+// probe looks up every member of the first set in the others, about half of the lookups are skipped on average
+// copy-remove copies the first set and removes members of the others from it
+func DiffCost(sets ...*Set) Cost {
+	sizes := sizesOf(sets)
+	if len(sets) == 0 {
+		return Cost{Algorithm: AlgorithmProbe}
+	}
+	probeWork := sizes[0] * len(sets) / 2
+	removeWork := 0
+	for _, size := range sizes {
+		removeWork += size
+	}
+	if probeWork <= removeWork {
+		return Cost{Algorithm: AlgorithmProbe, Work: probeWork, Sizes: sizes}
+	}
+	return Cost{Algorithm: AlgorithmCopyRemove, Work: removeWork, Sizes: sizes}
+}
@@ -2,6 +2,7 @@ package set
 
 import (
 	"Godis/datastruct/dict"
+	"sort"
 )
 
 // Set is a set of elements based on hash table
@@ -75,6 +76,15 @@ func (set *Set) ForEach(consumer func(member string) bool) {
 	})
 }
 
+// Scan returns members visited from cursor and the next cursor, the returned cursor is 0 when the scan finished
+// 游标与集合的大小无关，遍历期间一直存在的成员恰好返回一次，见 dict.ScanKeys
+func (set *Set) Scan(cursor int, count int) ([]string, int) {
+	if set == nil || set.dict == nil {
+		return nil, 0
+	}
+	return dict.ScanKeys(set.dict, cursor, count)
+}
+
 // ShallowCopy copies all members to another set
 func (set *Set) ShallowCopy() *Set {
 	result := Make()
@@ -86,25 +96,43 @@ func (set *Set) ShallowCopy() *Set {
 }
 
 // Intersect intersects two sets
+// 按大小排序后遍历最小的集合，逐个检查成员是否存在于其他集合中，时间复杂度为 O(N*M)，N为最小集合的大小，M为集合数
 func Intersect(sets ...*Set) *Set {
 	result := Make()
+	forEachIntersected(sets, func(member string) bool {
+		result.Add(member)
+		return true
+	})
+	return result
+}
+
+// IntersectCard returns cardinality of the intersection, stops counting once it reaches limit, limit <= 0 means no limit
+func IntersectCard(limit int, sets ...*Set) int {
+	count := 0
+	forEachIntersected(sets, func(member string) bool {
+		count++
+		return limit <= 0 || count < limit
+	})
+	return count
+}
+
+// forEachIntersected visits members of the intersection, stops if consumer returns false
+func forEachIntersected(sets []*Set, consumer func(member string) bool) {
 	if len(sets) == 0 {
-		return result
+		return
 	}
-
-	countMap := make(map[string]int)
-	for _, set := range sets {
-		set.ForEach(func(member string) bool {
-			countMap[member]++
-			return true
-		})
+	sorted := sortBySize(sets)
+	if sorted[0].Len() == 0 {
+		return
 	}
-	for k, v := range countMap {
-		if v == len(sets) {
-			result.Add(k)
+	sorted[0].ForEach(func(member string) bool {
+		for _, set := range sorted[1:] {
+			if !set.Has(member) {
+				return true
+			}
 		}
-	}
-	return result
+		return consumer(member)
+	})
 }
 
 // Union adds two sets
@@ -120,15 +148,43 @@ func Union(sets ...*Set) *Set {
 }
 
 // Diff subtracts two sets
+// 根据 DiffCost 选择算法: 逐个检查第一个集合的成员是否存在于其他集合中，或者复制第一个集合后删除其他集合的成员
 func Diff(sets ...*Set) *Set {
-	if len(sets) == 0 {
+	if len(sets) == 0 || sets[0].Len() == 0 {
 		return Make()
 	}
+	if DiffCost(sets...).Algorithm == AlgorithmCopyRemove {
+		return diffByRemove(sets)
+	}
+	return diffByProbe(sets)
+}
+
+// diffByProbe 其他集合按大小从大到小检查，成员更可能尽早被排除
+func diffByProbe(sets []*Set) *Set {
+	result := Make()
+	others := sortBySize(sets[1:])
+	for i, j := 0, len(others)-1; i < j; i, j = i+1, j-1 {
+		others[i], others[j] = others[j], others[i]
+	}
+	sets[0].ForEach(func(member string) bool {
+		for _, set := range others {
+			if set.Has(member) {
+				return true
+			}
+		}
+		result.Add(member)
+		return true
+	})
+	return result
+}
+
+// diffByRemove 结果为空后不再遍历剩余的集合
+func diffByRemove(sets []*Set) *Set {
 	result := sets[0].ShallowCopy()
-	for i := 1; i < len(sets); i++ {
-		sets[i].ForEach(func(member string) bool {
+	for _, set := range sets[1:] {
+		set.ForEach(func(member string) bool {
 			result.Remove(member)
-			return true
+			return result.Len() > 0
 		})
 		if result.Len() == 0 {
 			break
@@ -137,6 +193,16 @@ func Diff(sets ...*Set) *Set {
 	return result
 }
 
+// sortBySize returns a copy of sets sorted by size in ascending order
+func sortBySize(sets []*Set) []*Set {
+	sorted := make([]*Set, len(sets))
+	copy(sorted, sets)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Len() < sorted[j].Len()
+	})
+	return sorted
+}
+
 // RandomMembers randomly returns keys of the given number, may contain duplicated key
 func (set *Set) RandomMembers(limit int) []string {
 	if set == nil || set.dict == nil {
@@ -0,0 +1,141 @@
+package set
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// rangeSet makes a set of integers within [begin, end)
+func rangeSet(begin, end int) *Set {
+	set := Make()
+	for i := begin; i < end; i++ {
+		set.Add(strconv.Itoa(i))
+	}
+	return set
+}
+
+func members(set *Set) string {
+	slice := set.ToSlice()
+	sort.Slice(slice, func(i, j int) bool {
+		a, _ := strconv.Atoi(slice[i])
+		b, _ := strconv.Atoi(slice[j])
+		return a < b
+	})
+	return strings.Join(slice, ",")
+}
+
+func TestIntersect(t *testing.T) {
+	tests := []struct {
+		name     string
+		sets     []*Set
+		expected string
+		work     int
+	}{
+		{"smallest first", []*Set{rangeSet(0, 3), rangeSet(0, 100), rangeSet(1, 50)}, "1,2", 9},
+		{"smallest last", []*Set{rangeSet(0, 100), rangeSet(1, 50), rangeSet(0, 3)}, "1,2", 9},
+		{"disjoint", []*Set{rangeSet(0, 10), rangeSet(10, 20)}, "", 20},
+		{"empty operand", []*Set{rangeSet(0, 100), Make()}, "", 0},
+		{"single set", []*Set{rangeSet(0, 3)}, "0,1,2", 3},
+		{"no set", nil, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := members(Intersect(tt.sets...)); actual != tt.expected {
+				t.Errorf("expect %q, actual %q", tt.expected, actual)
+			}
+			expectedCard := len(strings.Split(tt.expected, ","))
+			if tt.expected == "" {
+				expectedCard = 0
+			}
+			if card := IntersectCard(0, tt.sets...); card != expectedCard {
+				t.Errorf("expect card %d, actual %d", expectedCard, card)
+			}
+			// 遍历最小的集合，代价与参数顺序无关
+			cost := IntersectCost(0, tt.sets...)
+			if cost.Algorithm != AlgorithmIterateSmallest || cost.Work != tt.work {
+				t.Errorf("expect work %d, actual %s", tt.work, cost)
+			}
+		})
+	}
+	if card := IntersectCard(2, rangeSet(0, 10), rangeSet(0, 10)); card != 2 {
+		t.Errorf("expect counting stops at limit, actual %d", card)
+	}
+	if cost := IntersectCost(2, rangeSet(0, 10), rangeSet(0, 10)); cost.Work != 4 {
+		t.Errorf("expect work 4 with limit, actual %s", cost)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name      string
+		sets      []*Set
+		expected  string
+		algorithm string
+	}{
+		{"small first set probes", []*Set{rangeSet(0, 5), rangeSet(2, 1000)}, "0,1", AlgorithmProbe},
+		{"large first set probes", []*Set{rangeSet(0, 1000), rangeSet(2, 1000)}, "0,1", AlgorithmProbe},
+		{"many operands copy and remove", []*Set{rangeSet(0, 100), rangeSet(0, 10), rangeSet(10, 20), rangeSet(20, 30), rangeSet(30, 90)}, "90,91,92,93,94,95,96,97,98,99", AlgorithmCopyRemove},
+		{"removes everything", []*Set{rangeSet(0, 10), rangeSet(0, 5), rangeSet(5, 10), Make(), Make()}, "", AlgorithmCopyRemove},
+		{"empty first set", []*Set{Make(), rangeSet(0, 10)}, "", AlgorithmProbe},
+		{"single set", []*Set{rangeSet(0, 3)}, "0,1,2", AlgorithmProbe},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if cost := DiffCost(tt.sets...); cost.Algorithm != tt.algorithm {
+				t.Errorf("expect algorithm %s, actual %s", tt.algorithm, cost)
+			}
+			if actual := members(Diff(tt.sets...)); actual != tt.expected {
+				t.Errorf("expect %q, actual %q", tt.expected, actual)
+			}
+			// 两种算法结果相同
+			if len(tt.sets) > 0 && tt.sets[0].Len() > 0 {
+				if actual := members(diffByProbe(tt.sets)); actual != tt.expected {
+					t.Errorf("probe: expect %q, actual %q", tt.expected, actual)
+				}
+				if actual := members(diffByRemove(tt.sets)); actual != tt.expected {
+					t.Errorf("copy-remove: expect %q, actual %q", tt.expected, actual)
+				}
+			}
+		})
+	}
+	// Diff 不修改参数
+	first := rangeSet(0, 10)
+	Diff(first, rangeSet(0, 5), Make(), Make(), Make())
+	if first.Len() != 10 {
+		t.Errorf("expect first set unchanged, actual %s", members(first))
+	}
+}
+
+func TestUnion(t *testing.T) {
+	tests := []struct {
+		name     string
+		sets     []*Set
+		expected string
+	}{
+		{"overlapped", []*Set{rangeSet(0, 3), rangeSet(2, 5)}, "0,1,2,3,4"},
+		{"with empty set", []*Set{Make(), rangeSet(0, 2)}, "0,1"},
+		{"no set", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := members(Union(tt.sets...)); actual != tt.expected {
+				t.Errorf("expect %q, actual %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSortBySize(t *testing.T) {
+	sets := []*Set{rangeSet(0, 3), rangeSet(0, 1), rangeSet(0, 2)}
+	sorted := sortBySize(sets)
+	for i, set := range sorted {
+		if set.Len() != i+1 {
+			t.Errorf("expect size %d at %d, actual %d", i+1, i, set.Len())
+		}
+	}
+	if sets[0].Len() != 3 {
+		t.Error("sortBySize should not modify its argument")
+	}
+}
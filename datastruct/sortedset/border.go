@@ -25,6 +25,7 @@ type Border interface {
 	less(element *Element) bool
 	getValue() interface{}
 	getExclude() bool
+	// isIntersected returns true if no value is within [min, max], must be called on min
 	isIntersected(max Border) bool
 }
 
@@ -81,6 +82,9 @@ var scoreNegativeInfBorder = &ScoreBorder{
 
 // ParseScoreBorder creates ScoreBorder from redis arguments
 func ParseScoreBorder(s string) (Border, error) {
+	if s == "" {
+		return nil, errors.New("ERR min or max is not a float")
+	}
 	if s == "inf" || s == "+inf" {
 		return scorePositiveInfBorder, nil
 	}
@@ -110,12 +114,20 @@ func ParseScoreBorder(s string) (Border, error) {
 }
 
 func (border *ScoreBorder) isIntersected(max Border) bool {
+	maxBorder := max.(*ScoreBorder)
+	if border.Inf == scorePositiveInf || maxBorder.Inf == scoreNegativeInf {
+		return true
+	}
+	if border.Inf == scoreNegativeInf || maxBorder.Inf == scorePositiveInf {
+		return false
+	}
 	minValue := border.Value
-	maxValue := max.(*ScoreBorder).Value
+	maxValue := maxBorder.Value
 	return minValue > maxValue || (minValue == maxValue && (border.getExclude() || max.getExclude()))
 }
 
 // LexBorder represents range of a string value, including: <, <=, >, >=, +, -
+// 成员按 score 和 member 排序，只有所有成员的 score 相同时按字典序的范围查询才有意义，与 Redis 一致
 type LexBorder struct {
 	Inf     int8
 	Value   string
@@ -168,6 +180,9 @@ var lexNegativeInfBorder = &LexBorder{
 
 // ParseLexBorder creates LexBorder from redis arguments
 func ParseLexBorder(s string) (Border, error) {
+	if s == "" {
+		return nil, errors.New("ERR min or max not valid string range item")
+	}
 	if s == "+" {
 		return lexPositiveInfBorder, nil
 	}
@@ -194,7 +209,14 @@ func ParseLexBorder(s string) (Border, error) {
 }
 
 func (border *LexBorder) isIntersected(max Border) bool {
+	maxBorder := max.(*LexBorder)
+	if border.Inf == lexPositiveInf || maxBorder.Inf == lexNegativeInf {
+		return true
+	}
+	if border.Inf == lexNegativeInf || maxBorder.Inf == lexPositiveInf {
+		return false
+	}
 	minValue := border.Value
-	maxValue := max.(*LexBorder).Value
-	return border.Inf == '+' || minValue > maxValue || (minValue == maxValue && (border.getExclude() || max.getExclude()))
+	maxValue := maxBorder.Value
+	return minValue > maxValue || (minValue == maxValue && (border.getExclude() || max.getExclude()))
 }
@@ -0,0 +1,207 @@
+package sortedset
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"Godis/datastruct/listpack"
+)
+
+// 元素较少时 SortedSet 用 listpack 按 (score, member) 升序依次保存 member, score，不创建 dict 和跳表
+// score 编码为 8 字节的 IEEE 754 表示
+// listpack 模式下的操作先解码出全部元素再处理，时间复杂度为 O(N)，元素数超过阈值后自动转换为跳表
+
+// Encodings of SortedSet
+const (
+	EncodingListpack = "listpack"
+	EncodingSkiplist = "skiplist"
+)
+
+// MakePacked makes a SortedSet encoded as listpack until it has more than maxEntries members
+// or a member longer than maxValue bytes, maxEntries <= 0 disables listpack
+func MakePacked(maxEntries int, maxValue int) *SortedSet {
+	if maxEntries <= 0 {
+		return Make()
+	}
+	return &SortedSet{
+		pack:       listpack.New(),
+		maxEntries: maxEntries,
+		maxValue:   maxValue,
+	}
+}
+
+// Encoding returns listpack or skiplist
+func (sortedSet *SortedSet) Encoding() string {
+	if sortedSet.pack != nil {
+		return EncodingListpack
+	}
+	return EncodingSkiplist
+}
+
+// PackedSize returns bytes used by listpack, returns -1 if it is encoded as skiplist
+func (sortedSet *SortedSet) PackedSize() int {
+	if sortedSet.pack == nil {
+		return -1
+	}
+	return sortedSet.pack.Size()
+}
+
+func encodeScore(score float64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, math.Float64bits(score))
+	return buf
+}
+
+func decodeScore(buf []byte) float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(buf))
+}
+
+func elementLess(a *Element, b *Element) bool {
+	if a.Score == b.Score {
+		return a.Member < b.Member
+	}
+	return a.Score < b.Score
+}
+
+// packedElements decodes all elements in ascending order
+func (sortedSet *SortedSet) packedElements() []*Element {
+	elements := make([]*Element, 0, sortedSet.pack.Len()/2)
+	var member string
+	sortedSet.pack.ForEach(func(i int, entry []byte) bool {
+		if i%2 == 0 {
+			member = string(entry)
+		} else {
+			elements = append(elements, &Element{Member: member, Score: decodeScore(entry)})
+		}
+		return true
+	})
+	return elements
+}
+
+// storePacked encodes sorted elements into listpack
+func (sortedSet *SortedSet) storePacked(elements []*Element) {
+	entries := make([][]byte, 0, 2*len(elements))
+	for _, element := range elements {
+		entries = append(entries, []byte(element.Member), encodeScore(element.Score))
+	}
+	sortedSet.pack.Clear()
+	sortedSet.pack.Append(entries...)
+}
+
+// packedFind returns index of member in listpack pairs, returns -1 if not found
+func (sortedSet *SortedSet) packedFind(member string) (index int, score float64) {
+	index = -1
+	found := false
+	sortedSet.pack.ForEach(func(i int, entry []byte) bool {
+		if found {
+			score = decodeScore(entry)
+			return false
+		}
+		if i%2 == 0 && string(entry) == member {
+			index = i / 2
+			found = true
+		}
+		return true
+	})
+	return index, score
+}
+
+// convert 将 listpack 中的元素转存到 dict 和跳表
+func (sortedSet *SortedSet) convert(elements []*Element) {
+	sortedSet.dict = make(map[string]*Element, len(elements))
+	sortedSet.skiplist = makeSkiplist()
+	for _, element := range elements {
+		sortedSet.dict[element.Member] = element
+		sortedSet.skiplist.insert(element.Member, element.Score)
+	}
+	sortedSet.pack = nil
+}
+
+func (sortedSet *SortedSet) packedAdd(member string, score float64) bool {
+	elements := sortedSet.packedElements()
+	inserted := true
+	for i, element := range elements {
+		if element.Member == member {
+			elements = append(elements[:i], elements[i+1:]...)
+			inserted = false
+			break
+		}
+	}
+	element := &Element{Member: member, Score: score}
+	pos := sort.Search(len(elements), func(i int) bool {
+		return elementLess(element, elements[i])
+	})
+	elements = append(elements, nil)
+	copy(elements[pos+1:], elements[pos:])
+	elements[pos] = element
+	if len(elements) > sortedSet.maxEntries || len(member) > sortedSet.maxValue {
+		sortedSet.convert(elements)
+		return inserted
+	}
+	if inserted {
+		// 新元素只需插入一对，无需重新编码
+		sortedSet.pack.Insert(2*pos, []byte(member), encodeScore(score))
+	} else {
+		sortedSet.storePacked(elements)
+	}
+	return inserted
+}
+
+func (sortedSet *SortedSet) packedRemove(member string) bool {
+	index, _ := sortedSet.packedFind(member)
+	if index < 0 {
+		return false
+	}
+	sortedSet.pack.Remove(2*index, 2)
+	return true
+}
+
+func (sortedSet *SortedSet) packedGetRank(member string, desc bool) int64 {
+	index, _ := sortedSet.packedFind(member)
+	if index < 0 {
+		return -1
+	}
+	if desc {
+		return sortedSet.Len() - 1 - int64(index)
+	}
+	return int64(index)
+}
+
+// packedInRange returns elements within the given border in ascending or descending order
+func (sortedSet *SortedSet) packedInRange(min Border, max Border, desc bool) []*Element {
+	var result []*Element
+	for _, element := range sortedSet.packedElements() {
+		if min.less(element) && max.greater(element) {
+			result = append(result, element)
+		}
+	}
+	if desc {
+		reverseElements(result)
+	}
+	return result
+}
+
+func reverseElements(elements []*Element) {
+	for i, j := 0, len(elements)-1; i < j; i, j = i+1, j-1 {
+		elements[i], elements[j] = elements[j], elements[i]
+	}
+}
+
+// packedRemoveWhere removes elements matching remove and returns them in ascending order
+func (sortedSet *SortedSet) packedRemoveWhere(remove func(i int, element *Element) bool) []*Element {
+	elements := sortedSet.packedElements()
+	var removed []*Element
+	kept := elements[:0]
+	for i, element := range elements {
+		if remove(i, element) {
+			removed = append(removed, element)
+		} else {
+			kept = append(kept, element)
+		}
+	}
+	if len(removed) > 0 {
+		sortedSet.storePacked(kept)
+	}
+	return removed
+}
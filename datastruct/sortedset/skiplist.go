@@ -1,8 +1,8 @@
 package sortedset
 
 import (
+	"Godis/lib/random"
 	"math/bits"
-	"math/rand"
 )
 
 const (
@@ -57,7 +57,7 @@ func makeSkiplist() *skiplist {
 
 func randomLevel() int16 {
 	total := uint64(1)<<uint64(maxLevel) - 1
-	k := rand.Uint64() % total
+	k := random.Uint64() % total
 	return maxLevel - int16(bits.Len64(k+1)) + 1
 }
 
@@ -2,12 +2,18 @@ package sortedset
 
 import (
 	"strconv"
+
+	"Godis/datastruct/listpack"
 )
 
 // SortedSet is a set which keys sorted by bound score
 type SortedSet struct {
 	dict     map[string]*Element
 	skiplist *skiplist
+	// pack is not nil if the set is encoded as listpack, see MakePacked
+	pack       *listpack.ListPack
+	maxEntries int
+	maxValue   int
 }
 
 // Make makes a new SortedSet
@@ -20,6 +26,9 @@ func Make() *SortedSet {
 
 // Add puts member into set,  and returns whether it has inserted new node
 func (sortedSet *SortedSet) Add(member string, score float64) bool {
+	if sortedSet.pack != nil {
+		return sortedSet.packedAdd(member, score)
+	}
 	element, ok := sortedSet.dict[member]
 	sortedSet.dict[member] = &Element{
 		Member: member,
@@ -38,11 +47,21 @@ func (sortedSet *SortedSet) Add(member string, score float64) bool {
 
 // Len returns number of members in set
 func (sortedSet *SortedSet) Len() int64 {
+	if sortedSet.pack != nil {
+		return int64(sortedSet.pack.Len() / 2)
+	}
 	return int64(len(sortedSet.dict))
 }
 
 // Get returns the given member
 func (sortedSet *SortedSet) Get(member string) (element *Element, ok bool) {
+	if sortedSet.pack != nil {
+		index, score := sortedSet.packedFind(member)
+		if index < 0 {
+			return nil, false
+		}
+		return &Element{Member: member, Score: score}, true
+	}
 	element, ok = sortedSet.dict[member]
 	if !ok {
 		return nil, false
@@ -52,6 +71,9 @@ func (sortedSet *SortedSet) Get(member string) (element *Element, ok bool) {
 
 // Remove removes the given member from set
 func (sortedSet *SortedSet) Remove(member string) bool {
+	if sortedSet.pack != nil {
+		return sortedSet.packedRemove(member)
+	}
 	v, ok := sortedSet.dict[member]
 	if ok {
 		sortedSet.skiplist.remove(member, v.Score)
@@ -63,6 +85,9 @@ func (sortedSet *SortedSet) Remove(member string) bool {
 
 // GetRank returns the rank of the given member, sort by ascending order, rank starts from 0
 func (sortedSet *SortedSet) GetRank(member string, desc bool) (rank int64) {
+	if sortedSet.pack != nil {
+		return sortedSet.packedGetRank(member, desc)
+	}
 	element, ok := sortedSet.dict[member]
 	if !ok {
 		return -1
@@ -85,6 +110,18 @@ func (sortedSet *SortedSet) ForEachByRank(start int64, stop int64, desc bool, co
 	if stop < start || stop > size {
 		panic("illegal end " + strconv.FormatInt(stop, 10))
 	}
+	if sortedSet.pack != nil {
+		elements := sortedSet.packedElements()
+		if desc {
+			reverseElements(elements)
+		}
+		for _, element := range elements[start:stop] {
+			if !consumer(element) {
+				break
+			}
+		}
+		return
+	}
 
 	// find start node
 	var node *node
@@ -127,29 +164,42 @@ func (sortedSet *SortedSet) RangeByRank(start int64, stop int64, desc bool) []*E
 }
 
 // RangeCount returns the number of  members which score or member within the given border
+// 通过首尾节点的排名计算，时间复杂度为 O(logN)
 func (sortedSet *SortedSet) RangeCount(min Border, max Border) int64 {
-	var i int64 = 0
-	// ascending order
-	sortedSet.ForEachByRank(0, sortedSet.Len(), false, func(element *Element) bool {
-		gtMin := min.less(element) // greater than min
-		if !gtMin {
-			// has not into range, continue foreach
-			return true
-		}
-		ltMax := max.greater(element) // less than max
-		if !ltMax {
-			// break through score border, break foreach
-			return false
-		}
-		// gtMin && ltMax
-		i++
-		return true
-	})
-	return i
+	if sortedSet.pack != nil {
+		return int64(len(sortedSet.packedInRange(min, max, false)))
+	}
+	first := sortedSet.skiplist.getFirstInRange(min, max)
+	if first == nil {
+		return 0
+	}
+	last := sortedSet.skiplist.getLastInRange(min, max)
+	if last == nil {
+		return 0
+	}
+	firstRank := sortedSet.skiplist.getRank(first.Member, first.Score)
+	lastRank := sortedSet.skiplist.getRank(last.Member, last.Score)
+	return lastRank - firstRank + 1
 }
 
 // ForEach visits members which score or member within the given border
 func (sortedSet *SortedSet) ForEach(min Border, max Border, offset int64, limit int64, desc bool, consumer func(element *Element) bool) {
+	if sortedSet.pack != nil {
+		elements := sortedSet.packedInRange(min, max, desc)
+		if offset >= int64(len(elements)) {
+			return
+		}
+		elements = elements[offset:]
+		if limit >= 0 && limit < int64(len(elements)) {
+			elements = elements[:limit]
+		}
+		for _, element := range elements {
+			if !consumer(element) {
+				break
+			}
+		}
+		return
+	}
 	// find start node
 	var node *node
 	if desc {
@@ -158,13 +208,21 @@ func (sortedSet *SortedSet) ForEach(min Border, max Border, offset int64, limit
 		node = sortedSet.skiplist.getFirstInRange(min, max)
 	}
 
-	for node != nil && offset > 0 {
+	// 通过排名跳过 offset 个节点，无需逐个遍历
+	if node != nil && offset > 0 {
+		rank := sortedSet.skiplist.getRank(node.Member, node.Score)
 		if desc {
-			node = node.backward
+			rank -= offset
 		} else {
-			node = node.level[0].forward
+			rank += offset
+		}
+		if rank < 1 || rank > sortedSet.skiplist.length {
+			return
+		}
+		node = sortedSet.skiplist.getByRank(rank)
+		if !min.less(&node.Element) || !max.greater(&node.Element) {
+			return
 		}
-		offset--
 	}
 
 	// A negative limit returns all elements from the offset
@@ -204,6 +262,12 @@ func (sortedSet *SortedSet) Range(min Border, max Border, offset int64, limit in
 
 // RemoveRange removes members which score or member within the given border
 func (sortedSet *SortedSet) RemoveRange(min Border, max Border) int64 {
+	if sortedSet.pack != nil {
+		removed := sortedSet.packedRemoveWhere(func(i int, element *Element) bool {
+			return min.less(element) && max.greater(element)
+		})
+		return int64(len(removed))
+	}
 	removed := sortedSet.skiplist.RemoveRange(min, max, 0)
 	for _, element := range removed {
 		delete(sortedSet.dict, element.Member)
@@ -212,6 +276,11 @@ func (sortedSet *SortedSet) RemoveRange(min Border, max Border) int64 {
 }
 
 func (sortedSet *SortedSet) PopMin(count int) []*Element {
+	if sortedSet.pack != nil {
+		return sortedSet.packedRemoveWhere(func(i int, element *Element) bool {
+			return i < count
+		})
+	}
 	first := sortedSet.skiplist.getFirstInRange(scoreNegativeInfBorder, scorePositiveInfBorder)
 	if first == nil {
 		return nil
@@ -227,9 +296,35 @@ func (sortedSet *SortedSet) PopMin(count int) []*Element {
 	return removed
 }
 
+// PopMax removes and returns at most count members with the highest scores, in descending order
+func (sortedSet *SortedSet) PopMax(count int) []*Element {
+	if sortedSet.pack != nil {
+		size := int(sortedSet.Len())
+		removed := sortedSet.packedRemoveWhere(func(i int, element *Element) bool {
+			return i >= size-count
+		})
+		reverseElements(removed)
+		return removed
+	}
+	var removed []*Element
+	for n := sortedSet.skiplist.tail; n != nil && len(removed) < count; n = sortedSet.skiplist.tail {
+		element := n.Element
+		sortedSet.skiplist.remove(element.Member, element.Score)
+		delete(sortedSet.dict, element.Member)
+		removed = append(removed, &element)
+	}
+	return removed
+}
+
 // RemoveByRank removes member ranking within [start, stop)
 // sort by ascending order and rank starts from 0
 func (sortedSet *SortedSet) RemoveByRank(start int64, stop int64) int64 {
+	if sortedSet.pack != nil {
+		removed := sortedSet.packedRemoveWhere(func(i int, element *Element) bool {
+			return int64(i) >= start && int64(i) < stop
+		})
+		return int64(len(removed))
+	}
 	removed := sortedSet.skiplist.RemoveRangeByRank(start+1, stop+1)
 	for _, element := range removed {
 		delete(sortedSet.dict, element.Member)
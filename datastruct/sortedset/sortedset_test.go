@@ -0,0 +1,75 @@
+package sortedset
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLexRange(t *testing.T) {
+	for _, sortedSet := range []*SortedSet{MakePacked(128, 64), Make()} {
+		for i := 0; i < 10; i++ {
+			sortedSet.Add(fmt.Sprintf("m%d", i), 0)
+		}
+		cases := []struct {
+			min, max string
+			expected int64
+		}{
+			{"-", "+", 10},
+			{"[m2", "+", 8},
+			{"(m2", "+", 7},
+			{"-", "[m2", 3},
+			{"-", "(m2", 2},
+			{"(m1", "[m3", 2},
+			{"[m3", "[m3", 1},
+			{"(m3", "[m3", 0},
+			{"[m5", "[m3", 0},
+			{"+", "-", 0},
+			{"+", "+", 0},
+			{"-", "-", 0},
+			{"[a", "[b", 0},
+			{"[n", "+", 0},
+		}
+		for _, c := range cases {
+			min, _ := ParseLexBorder(c.min)
+			max, _ := ParseLexBorder(c.max)
+			if count := sortedSet.RangeCount(min, max); count != c.expected {
+				t.Errorf("%s [%s, %s]: expect %d, actual %d", sortedSet.Encoding(), c.min, c.max, c.expected, count)
+			}
+			if elements := sortedSet.Range(min, max, 0, -1, true); int64(len(elements)) != c.expected {
+				t.Errorf("%s desc [%s, %s]: expect %d, actual %d", sortedSet.Encoding(), c.min, c.max, c.expected, len(elements))
+			}
+		}
+		min, _ := ParseLexBorder("(m7")
+		max, _ := ParseLexBorder("+")
+		if removed := sortedSet.RemoveRange(min, max); removed != 2 || sortedSet.Len() != 8 {
+			t.Errorf("%s: expect 2 removed, actual %d", sortedSet.Encoding(), removed)
+		}
+	}
+	if _, err := ParseLexBorder(""); err == nil {
+		t.Error("expect error for empty border")
+	}
+}
+
+func TestScoreRange_Inf(t *testing.T) {
+	sortedSet := Make()
+	for i := 0; i < 10; i++ {
+		sortedSet.Add(fmt.Sprintf("m%d", i), float64(i))
+	}
+	cases := []struct {
+		min, max string
+		expected int64
+	}{
+		{"-inf", "+inf", 10},
+		{"3", "+inf", 7},
+		{"-inf", "-3", 0},
+		{"-inf", "(3", 3},
+		{"+inf", "-inf", 0},
+	}
+	for _, c := range cases {
+		min, _ := ParseScoreBorder(c.min)
+		max, _ := ParseScoreBorder(c.max)
+		if count := sortedSet.RangeCount(min, max); count != c.expected {
+			t.Errorf("[%s, %s]: expect %d, actual %d", c.min, c.max, c.expected, count)
+		}
+	}
+}
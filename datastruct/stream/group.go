@@ -0,0 +1,249 @@
+package stream
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrGroupExists is returned when creating a group with existed name
+var ErrGroupExists = errors.New("BUSYGROUP Consumer Group name already exists")
+
+// Group is a consumer group
+// 已投递但未确认的条目保存在 pending entry list (PEL) 中，每个消费者也持有自己的 PEL，两者共享 PendingEntry
+type Group struct {
+	Name string
+	// LastDeliveredID is id of the last entry delivered to consumers of this group
+	LastDeliveredID ID
+	pending         map[ID]*PendingEntry
+	consumers       map[string]*Consumer
+}
+
+// PendingEntry is an entry delivered but not acknowledged
+type PendingEntry struct {
+	ID       ID
+	Consumer string
+	// DeliveryTime is unix time in milliseconds of the last delivery
+	DeliveryTime  int64
+	DeliveryCount uint64
+}
+
+// Consumer is a consumer of group
+type Consumer struct {
+	Name string
+	// SeenTime is unix time in milliseconds of the last interaction
+	SeenTime int64
+	pending  map[ID]*PendingEntry
+}
+
+// PendingCount returns number of entries pending on this consumer
+func (c *Consumer) PendingCount() int {
+	return len(c.pending)
+}
+
+// CreateGroup creates a consumer group which delivers entries after lastID
+func (s *Stream) CreateGroup(name string, lastID ID) (*Group, error) {
+	if _, ok := s.groups[name]; ok {
+		return nil, ErrGroupExists
+	}
+	if s.groups == nil {
+		s.groups = make(map[string]*Group)
+	}
+	group := &Group{
+		Name:            name,
+		LastDeliveredID: lastID,
+		pending:         make(map[ID]*PendingEntry),
+		consumers:       make(map[string]*Consumer),
+	}
+	s.groups[name] = group
+	return group, nil
+}
+
+// Group returns the consumer group with the given name, nil if not exists
+func (s *Stream) Group(name string) *Group {
+	return s.groups[name]
+}
+
+// DestroyGroup removes consumer group, returns whether it existed
+func (s *Stream) DestroyGroup(name string) bool {
+	if _, ok := s.groups[name]; !ok {
+		return false
+	}
+	delete(s.groups, name)
+	return true
+}
+
+// Groups returns all consumer groups ordered by name
+func (s *Stream) Groups() []*Group {
+	groups := make([]*Group, 0, len(s.groups))
+	for _, group := range s.groups {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
+
+// Consumer returns consumer with the given name, creates it if not exists
+func (g *Group) Consumer(name string, nowMs int64) *Consumer {
+	consumer, ok := g.consumers[name]
+	if !ok {
+		consumer = &Consumer{
+			Name:     name,
+			SeenTime: nowMs,
+			pending:  make(map[ID]*PendingEntry),
+		}
+		g.consumers[name] = consumer
+	}
+	return consumer
+}
+
+// DeleteConsumer removes consumer and its pending entries, returns number of pending entries removed
+func (g *Group) DeleteConsumer(name string) int {
+	consumer, ok := g.consumers[name]
+	if !ok {
+		return 0
+	}
+	for id := range consumer.pending {
+		delete(g.pending, id)
+	}
+	delete(g.consumers, name)
+	return len(consumer.pending)
+}
+
+// Consumers returns all consumers ordered by name
+func (g *Group) Consumers() []*Consumer {
+	consumers := make([]*Consumer, 0, len(g.consumers))
+	for _, consumer := range g.consumers {
+		consumers = append(consumers, consumer)
+	}
+	sort.Slice(consumers, func(i, j int) bool {
+		return consumers[i].Name < consumers[j].Name
+	})
+	return consumers
+}
+
+// ReadNew delivers at most count entries after LastDeliveredID to consumer, which is XREADGROUP with ">"
+// delivered entries are added into PEL unless noAck is true
+func (g *Group) ReadNew(s *Stream, consumerName string, count int, nowMs int64, noAck bool) []*Entry {
+	consumer := g.Consumer(consumerName, nowMs)
+	consumer.SeenTime = nowMs
+	start, ok := g.LastDeliveredID.Next()
+	if !ok {
+		return nil
+	}
+	entries := s.Range(start, MaxID, count, false)
+	for _, entry := range entries {
+		g.LastDeliveredID = entry.ID
+		if noAck {
+			continue
+		}
+		// 条目可能已被其他消费者认领过，重新投递时转移给当前消费者
+		if pe, ok := g.pending[entry.ID]; ok {
+			delete(g.consumers[pe.Consumer].pending, entry.ID)
+		}
+		pe := &PendingEntry{
+			ID:            entry.ID,
+			Consumer:      consumerName,
+			DeliveryTime:  nowMs,
+			DeliveryCount: 1,
+		}
+		g.pending[entry.ID] = pe
+		consumer.pending[entry.ID] = pe
+	}
+	return entries
+}
+
+// ReadPending returns history of consumer, which is XREADGROUP with an explicit id
+// entries deleted from stream are returned with nil Fields
+func (g *Group) ReadPending(s *Stream, consumerName string, start ID, count int, nowMs int64) []*Entry {
+	consumer := g.Consumer(consumerName, nowMs)
+	consumer.SeenTime = nowMs
+	pending := sortPending(consumer.pending, start, MaxID)
+	if count > 0 && len(pending) > count {
+		pending = pending[:count]
+	}
+	entries := make([]*Entry, len(pending))
+	for i, pe := range pending {
+		entry, ok := s.Get(pe.ID)
+		if !ok {
+			entry = &Entry{ID: pe.ID}
+		}
+		entries[i] = entry
+		pe.DeliveryTime = nowMs
+		pe.DeliveryCount++
+	}
+	return entries
+}
+
+// Ack removes entries from PEL, returns number of entries actually acknowledged
+func (g *Group) Ack(ids ...ID) int {
+	acked := 0
+	for _, id := range ids {
+		pe, ok := g.pending[id]
+		if !ok {
+			continue
+		}
+		delete(g.pending, id)
+		delete(g.consumers[pe.Consumer].pending, id)
+		acked++
+	}
+	return acked
+}
+
+// PendingCount returns length of PEL
+func (g *Group) PendingCount() int {
+	return len(g.pending)
+}
+
+// Pending returns pending entries within [start, end] ordered by id, at most count entries if count > 0
+// only entries of the consumer are returned if consumerName is not empty
+func (g *Group) Pending(start ID, end ID, count int, consumerName string) []*PendingEntry {
+	pel := g.pending
+	if consumerName != "" {
+		consumer, ok := g.consumers[consumerName]
+		if !ok {
+			return nil
+		}
+		pel = consumer.pending
+	}
+	result := sortPending(pel, start, end)
+	if count > 0 && len(result) > count {
+		result = result[:count]
+	}
+	return result
+}
+
+// Claim transfers pending entries idle for at least minIdle milliseconds to consumer, returns claimed entries
+// entries not in PEL are ignored, like XCLAIM without FORCE
+func (g *Group) Claim(consumerName string, minIdle int64, ids []ID, nowMs int64) []*PendingEntry {
+	consumer := g.Consumer(consumerName, nowMs)
+	consumer.SeenTime = nowMs
+	var claimed []*PendingEntry
+	for _, id := range ids {
+		pe, ok := g.pending[id]
+		if !ok || nowMs-pe.DeliveryTime < minIdle {
+			continue
+		}
+		delete(g.consumers[pe.Consumer].pending, id)
+		pe.Consumer = consumerName
+		pe.DeliveryTime = nowMs
+		pe.DeliveryCount++
+		consumer.pending[id] = pe
+		claimed = append(claimed, pe)
+	}
+	return claimed
+}
+
+func sortPending(pel map[ID]*PendingEntry, start ID, end ID) []*PendingEntry {
+	result := make([]*PendingEntry, 0, len(pel))
+	for id, pe := range pel {
+		if !id.Less(start) && !end.Less(id) {
+			result = append(result, pe)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID.Less(result[j].ID)
+	})
+	return result
+}
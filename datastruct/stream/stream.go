@@ -0,0 +1,288 @@
+// Package stream 与 redis 一致的 stream 数据结构
+// redis 用基数树保存 listpack 节点，树的键是节点中第一个条目的 ID。
+// stream 只能在尾部追加，ID 单调递增，因此这里用按 ID 有序的切片保存条目，
+// 追加、按 ID 二分查找范围、从头部裁剪的复杂度与基数树相同，且更节省内存
+package stream
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ID is the id of a stream entry, composed of milliseconds timestamp and sequence number
+type ID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+var (
+	// MinID is the smallest id, which is "-" in range queries
+	MinID = ID{}
+	// MaxID is the largest id, which is "+" in range queries
+	MaxID = ID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+)
+
+var (
+	ErrInvalidID  = errors.New("ERR Invalid stream ID specified as stream command argument")
+	ErrIDTooSmall = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	ErrIDZero     = errors.New("ERR The ID specified in XADD must be greater than 0-0")
+	// ErrIDExhausted means the stream reached the largest id and could not generate new ones
+	ErrIDExhausted = errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+)
+
+// ParseID parses "ms-seq" or "ms", missing sequence number is defaultSeq,
+// so that "ms" means the first id in range start and the last id in range end
+func ParseID(s string, defaultSeq uint64) (ID, error) {
+	msStr, seqStr, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msStr, 10, 64)
+	if err != nil {
+		return ID{}, ErrInvalidID
+	}
+	if !hasSeq {
+		return ID{Ms: ms, Seq: defaultSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return ID{}, ErrInvalidID
+	}
+	return ID{Ms: ms, Seq: seq}, nil
+}
+
+func (id ID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Compare returns -1, 0 or 1 if id is less than, equal to or greater than other
+func (id ID) Compare(other ID) int {
+	switch {
+	case id.Ms < other.Ms:
+		return -1
+	case id.Ms > other.Ms:
+		return 1
+	case id.Seq < other.Seq:
+		return -1
+	case id.Seq > other.Seq:
+		return 1
+	}
+	return 0
+}
+
+// Less returns whether id is less than other
+func (id ID) Less(other ID) bool {
+	return id.Compare(other) < 0
+}
+
+// Next returns the smallest id greater than id, ok is false if id is MaxID
+func (id ID) Next() (next ID, ok bool) {
+	if id.Seq < math.MaxUint64 {
+		return ID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	}
+	if id.Ms < math.MaxUint64 {
+		return ID{Ms: id.Ms + 1}, true
+	}
+	return id, false
+}
+
+// Entry is an entry of stream, Fields are field-value pairs
+type Entry struct {
+	ID     ID
+	Fields [][]byte
+}
+
+// Stream is an append-only log of entries ordered by id
+type Stream struct {
+	entries []*Entry
+	lastID  ID
+	// entriesAdded 是历史上添加过的条目总数，包括已被删除或裁剪的条目
+	entriesAdded uint64
+	// maxDeletedID 是被删除或裁剪的最大 ID
+	maxDeletedID ID
+	groups       map[string]*Group
+}
+
+// Make creates an empty stream
+func Make() *Stream {
+	return &Stream{}
+}
+
+// Len returns number of entries
+func (s *Stream) Len() int {
+	return len(s.entries)
+}
+
+// LastID returns id of the last added entry, it won't decrease even if the entry is deleted
+func (s *Stream) LastID() ID {
+	return s.lastID
+}
+
+// EntriesAdded returns number of entries ever added
+func (s *Stream) EntriesAdded() uint64 {
+	return s.entriesAdded
+}
+
+// MaxDeletedID returns the largest id deleted or trimmed
+func (s *Stream) MaxDeletedID() ID {
+	return s.maxDeletedID
+}
+
+// NextID generates id for the next entry, nowMs is the current unix time in milliseconds
+// 时钟回拨时沿用 lastID 的时间戳并递增序号，保证 ID 单调递增
+func (s *Stream) NextID(nowMs uint64) (ID, error) {
+	if nowMs > s.lastID.Ms {
+		return ID{Ms: nowMs}, nil
+	}
+	next, ok := s.lastID.Next()
+	if !ok {
+		return ID{}, ErrIDExhausted
+	}
+	return next, nil
+}
+
+// NextSeq generates id with the given milliseconds part, which is "ms-*" in XADD
+func (s *Stream) NextSeq(ms uint64) (ID, error) {
+	if ms > s.lastID.Ms {
+		return ID{Ms: ms}, nil
+	}
+	if ms < s.lastID.Ms || s.lastID.Seq == math.MaxUint64 {
+		return ID{}, ErrIDTooSmall
+	}
+	return ID{Ms: ms, Seq: s.lastID.Seq + 1}, nil
+}
+
+// Add appends an entry with the given id, which must be greater than LastID
+func (s *Stream) Add(id ID, fields [][]byte) error {
+	if id == MinID {
+		return ErrIDZero
+	}
+	if id.Compare(s.lastID) <= 0 {
+		return ErrIDTooSmall
+	}
+	s.entries = append(s.entries, &Entry{ID: id, Fields: fields})
+	s.lastID = id
+	s.entriesAdded++
+	return nil
+}
+
+// AddAuto appends an entry with generated id, which is "*" in XADD
+func (s *Stream) AddAuto(nowMs uint64, fields [][]byte) (ID, error) {
+	id, err := s.NextID(nowMs)
+	if err != nil {
+		return ID{}, err
+	}
+	return id, s.Add(id, fields)
+}
+
+// search returns index of the first entry whose id is not less than id
+func (s *Stream) search(id ID) int {
+	return sort.Search(len(s.entries), func(i int) bool {
+		return !s.entries[i].ID.Less(id)
+	})
+}
+
+// Get returns the entry with the given id
+func (s *Stream) Get(id ID) (*Entry, bool) {
+	i := s.search(id)
+	if i < len(s.entries) && s.entries[i].ID == id {
+		return s.entries[i], true
+	}
+	return nil, false
+}
+
+// Range returns entries within [start, end], at most count entries if count > 0
+// entries are in descending order if reverse is true, which is XREVRANGE
+func (s *Stream) Range(start ID, end ID, count int, reverse bool) []*Entry {
+	if end.Less(start) {
+		return nil
+	}
+	begin := s.search(start)
+	stop := len(s.entries)
+	if next, ok := end.Next(); ok {
+		stop = s.search(next)
+	}
+	n := stop - begin
+	if n <= 0 {
+		return nil
+	}
+	if count > 0 && count < n {
+		n = count
+	}
+	result := make([]*Entry, n)
+	for i := range result {
+		if reverse {
+			result[i] = s.entries[stop-1-i]
+		} else {
+			result[i] = s.entries[begin+i]
+		}
+	}
+	return result
+}
+
+// Delete removes entries with the given ids, returns number of entries actually removed
+func (s *Stream) Delete(ids ...ID) int {
+	removed := 0
+	for _, id := range ids {
+		i := s.search(id)
+		if i >= len(s.entries) || s.entries[i].ID != id {
+			continue
+		}
+		copy(s.entries[i:], s.entries[i+1:])
+		s.entries[len(s.entries)-1] = nil
+		s.entries = s.entries[:len(s.entries)-1]
+		if s.maxDeletedID.Less(id) {
+			s.maxDeletedID = id
+		}
+		removed++
+	}
+	return removed
+}
+
+// trimFront removes the first n entries
+func (s *Stream) trimFront(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	if last := s.entries[n-1].ID; s.maxDeletedID.Less(last) {
+		s.maxDeletedID = last
+	}
+	// 复制到新的切片，释放被裁剪的条目
+	s.entries = append([]*Entry(nil), s.entries[n:]...)
+	return n
+}
+
+// TrimMaxLen removes the oldest entries until at most maxLen entries remain, returns number of entries removed
+// limit > 0 means removing at most limit entries, like the LIMIT option of XTRIM
+func (s *Stream) TrimMaxLen(maxLen int, limit int) int {
+	n := len(s.entries) - maxLen
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	return s.trimFront(n)
+}
+
+// TrimMinID removes entries whose id is less than minID, returns number of entries removed
+func (s *Stream) TrimMinID(minID ID, limit int) int {
+	n := s.search(minID)
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	return s.trimFront(n)
+}
+
+// First returns the first entry, which is nil if stream is empty
+func (s *Stream) First() *Entry {
+	if len(s.entries) == 0 {
+		return nil
+	}
+	return s.entries[0]
+}
+
+// Last returns the last entry, which is nil if stream is empty
+func (s *Stream) Last() *Entry {
+	if len(s.entries) == 0 {
+		return nil
+	}
+	return s.entries[len(s.entries)-1]
+}
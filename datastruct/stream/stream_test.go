@@ -0,0 +1,143 @@
+package stream
+
+import (
+	"strconv"
+	"testing"
+)
+
+func fields(i int) [][]byte {
+	return [][]byte{[]byte("n"), []byte(strconv.Itoa(i))}
+}
+
+func ids(entries []*Entry) string {
+	s := ""
+	for _, entry := range entries {
+		s += entry.ID.String() + " "
+	}
+	return s
+}
+
+func TestStream_Add(t *testing.T) {
+	s := Make()
+	if err := s.Add(MinID, nil); err != ErrIDZero {
+		t.Errorf("expect ErrIDZero, actual %v", err)
+	}
+	id, _ := s.AddAuto(1000, fields(0))
+	if id != (ID{Ms: 1000}) {
+		t.Errorf("expect 1000-0, actual %s", id)
+	}
+	// clock goes backwards
+	id, _ = s.AddAuto(999, fields(1))
+	if id != (ID{Ms: 1000, Seq: 1}) {
+		t.Errorf("expect 1000-1, actual %s", id)
+	}
+	if err := s.Add(ID{Ms: 1000, Seq: 1}, nil); err != ErrIDTooSmall {
+		t.Errorf("expect ErrIDTooSmall, actual %v", err)
+	}
+	if id, _ := s.NextSeq(1000); id != (ID{Ms: 1000, Seq: 2}) {
+		t.Errorf("expect 1000-2, actual %s", id)
+	}
+	if _, err := s.NextSeq(999); err != ErrIDTooSmall {
+		t.Errorf("expect ErrIDTooSmall, actual %v", err)
+	}
+	if s.Len() != 2 || s.EntriesAdded() != 2 || s.LastID() != id {
+		t.Errorf("unexpected stream state %d %d %s", s.Len(), s.EntriesAdded(), s.LastID())
+	}
+	for _, c := range []struct {
+		s   string
+		seq uint64
+		id  ID
+	}{
+		{"5-3", 0, ID{5, 3}},
+		{"5", 0, ID{5, 0}},
+		{"5", MaxID.Seq, ID{5, MaxID.Seq}},
+	} {
+		if id, err := ParseID(c.s, c.seq); err != nil || id != c.id {
+			t.Errorf("parse %s: expect %s, actual %s %v", c.s, c.id, id, err)
+		}
+	}
+	for _, bad := range []string{"", "a", "1-", "-1", "1-2-3"} {
+		if _, err := ParseID(bad, 0); err == nil {
+			t.Errorf("expect error for %q", bad)
+		}
+	}
+}
+
+func TestStream_RangeAndTrim(t *testing.T) {
+	s := Make()
+	for i := 1; i <= 10; i++ {
+		_ = s.Add(ID{Ms: uint64(i)}, fields(i))
+	}
+	if actual := ids(s.Range(ID{Ms: 3}, ID{Ms: 5}, 0, false)); actual != "3-0 4-0 5-0 " {
+		t.Errorf("range: %s", actual)
+	}
+	if actual := ids(s.Range(MinID, MaxID, 3, true)); actual != "10-0 9-0 8-0 " {
+		t.Errorf("reverse range: %s", actual)
+	}
+	if entries := s.Range(ID{Ms: 5}, ID{Ms: 3}, 0, false); len(entries) != 0 {
+		t.Errorf("expect empty range, actual %s", ids(entries))
+	}
+	if n := s.Delete(ID{Ms: 4}, ID{Ms: 4}, ID{Ms: 100}); n != 1 {
+		t.Errorf("expect 1 deleted, actual %d", n)
+	}
+	if _, ok := s.Get(ID{Ms: 4}); ok {
+		t.Error("4-0 should be deleted")
+	}
+	if n := s.TrimMaxLen(5, 2); n != 2 {
+		t.Errorf("expect 2 trimmed by limit, actual %d", n)
+	}
+	if n := s.TrimMaxLen(5, 0); n != 2 {
+		t.Errorf("expect 2 trimmed, actual %d", n)
+	}
+	if actual := ids(s.Range(MinID, MaxID, 0, false)); actual != "6-0 7-0 8-0 9-0 10-0 " {
+		t.Errorf("after maxlen trim: %s", actual)
+	}
+	if n := s.TrimMinID(ID{Ms: 8}, 0); n != 2 || s.First().ID != (ID{Ms: 8}) {
+		t.Errorf("expect 2 trimmed, actual %d", n)
+	}
+	if s.MaxDeletedID() != (ID{Ms: 7}) || s.EntriesAdded() != 10 {
+		t.Errorf("unexpected max deleted id %s", s.MaxDeletedID())
+	}
+}
+
+func TestGroup(t *testing.T) {
+	s := Make()
+	for i := 1; i <= 5; i++ {
+		_ = s.Add(ID{Ms: uint64(i)}, fields(i))
+	}
+	g, _ := s.CreateGroup("g", ID{Ms: 1})
+	if _, err := s.CreateGroup("g", MinID); err != ErrGroupExists {
+		t.Errorf("expect ErrGroupExists, actual %v", err)
+	}
+	if actual := ids(g.ReadNew(s, "alice", 2, 100, false)); actual != "2-0 3-0 " {
+		t.Errorf("alice read: %s", actual)
+	}
+	if actual := ids(g.ReadNew(s, "bob", 0, 100, false)); actual != "4-0 5-0 " {
+		t.Errorf("bob read: %s", actual)
+	}
+	if len(g.ReadNew(s, "bob", 0, 100, false)) != 0 || g.PendingCount() != 4 {
+		t.Error("no more new entries expected")
+	}
+	if n := g.Ack(ID{Ms: 2}, ID{Ms: 2}); n != 1 {
+		t.Errorf("expect 1 acked, actual %d", n)
+	}
+	claimed := g.Claim("alice", 50, []ID{{Ms: 4}, {Ms: 9}}, 200)
+	if len(claimed) != 1 || claimed[0].DeliveryCount != 2 {
+		t.Errorf("unexpected claimed %v", claimed)
+	}
+	pending := g.Pending(MinID, MaxID, 0, "alice")
+	if len(pending) != 2 || pending[0].ID != (ID{Ms: 3}) || pending[1].ID != (ID{Ms: 4}) {
+		t.Errorf("unexpected pending of alice %v", pending)
+	}
+	s.Delete(ID{Ms: 3})
+	history := g.ReadPending(s, "alice", MinID, 0, 300)
+	if len(history) != 2 || history[0].Fields != nil || history[1].Fields == nil {
+		t.Errorf("unexpected history %s", ids(history))
+	}
+	if n := g.DeleteConsumer("bob"); n != 1 || g.PendingCount() != 2 {
+		t.Errorf("expect 1 pending removed with bob, actual %d", n)
+	}
+	if !s.DestroyGroup("g") || s.Group("g") != nil {
+		t.Error("group should be destroyed")
+	}
+}
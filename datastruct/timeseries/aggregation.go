@@ -0,0 +1,90 @@
+package timeseries
+
+import (
+	"math"
+	"strings"
+)
+
+// Aggregation is the type of aggregation used in range queries and compaction rules
+type Aggregation uint8
+
+const (
+	AggAvg Aggregation = iota
+	AggSum
+	AggMin
+	AggMax
+	AggCount
+	AggFirst
+	AggLast
+	AggRange
+)
+
+var aggregationNames = []string{"avg", "sum", "min", "max", "count", "first", "last", "range"}
+
+func (a Aggregation) String() string {
+	return aggregationNames[a]
+}
+
+// ParseAggregation parses aggregation name case-insensitively
+func ParseAggregation(name string) (Aggregation, bool) {
+	name = strings.ToLower(name)
+	for i, n := range aggregationNames {
+		if n == name {
+			return Aggregation(i), true
+		}
+	}
+	return 0, false
+}
+
+// aggregator accumulates samples in a bucket, all aggregations could be computed from it
+type aggregator struct {
+	count int64
+	sum   float64
+	min   float64
+	max   float64
+	first float64
+	last  float64
+}
+
+func (acc *aggregator) add(value float64) {
+	if acc.count == 0 {
+		acc.min, acc.max, acc.first = value, value, value
+	} else {
+		acc.min = math.Min(acc.min, value)
+		acc.max = math.Max(acc.max, value)
+	}
+	acc.count++
+	acc.sum += value
+	acc.last = value
+}
+
+func (acc *aggregator) result(agg Aggregation) float64 {
+	switch agg {
+	case AggAvg:
+		return acc.sum / float64(acc.count)
+	case AggSum:
+		return acc.sum
+	case AggMin:
+		return acc.min
+	case AggMax:
+		return acc.max
+	case AggCount:
+		return float64(acc.count)
+	case AggFirst:
+		return acc.first
+	case AggLast:
+		return acc.last
+	case AggRange:
+		return acc.max - acc.min
+	}
+	return 0
+}
+
+// bucketStart returns start of the bucket containing timestamp, buckets are aligned to 0
+func bucketStart(timestamp int64, bucketDuration int64) int64 {
+	r := timestamp % bucketDuration
+	if r < 0 {
+		r += bucketDuration
+	}
+	return timestamp - r
+}
@@ -0,0 +1,220 @@
+package timeseries
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// ErrInvalid is returned by Parse for malformed data
+var ErrInvalid = errors.New("ERR invalid time series data")
+
+var magic = []byte("GTS2")
+
+// legacyMagic is the format without labels
+var legacyMagic = []byte("GTS1")
+
+// Bytes serializes the series, used by AOF
+// 格式: magic | retention | policy | 标签数量 | 每个标签 | 规则数量 | 每个规则 | 分块数量 | 每个分块
+// 分块中的时间戳保存为与前一个样本的差值，按变长整数编码，值保存为 8 字节的浮点数
+func (s *Series) Bytes() []byte {
+	// 序列化前裁剪超出保留时长的样本，因此需要写锁
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trim()
+	buf := make([]byte, 0, len(magic)+16+s.count*10)
+	buf = append(buf, magic...)
+	buf = appendVarint(buf, s.retention)
+	buf = append(buf, byte(s.policy))
+	buf = appendUvarint(buf, uint64(len(s.labels)))
+	for _, label := range s.labels {
+		buf = appendUvarint(buf, uint64(len(label.Name)))
+		buf = append(buf, label.Name...)
+		buf = appendUvarint(buf, uint64(len(label.Value)))
+		buf = append(buf, label.Value...)
+	}
+	buf = appendUvarint(buf, uint64(len(s.rules)))
+	for _, rule := range s.rules {
+		buf = appendUvarint(buf, uint64(len(rule.DestKey)))
+		buf = append(buf, rule.DestKey...)
+		buf = append(buf, byte(rule.Aggregation))
+		buf = appendVarint(buf, rule.BucketDuration)
+		buf = appendVarint(buf, rule.bucket)
+		buf = appendUvarint(buf, uint64(rule.acc.count))
+		for _, v := range [...]float64{rule.acc.sum, rule.acc.min, rule.acc.max, rule.acc.first, rule.acc.last} {
+			buf = appendFloat(buf, v)
+		}
+	}
+	buf = appendUvarint(buf, uint64(len(s.chunks)))
+	for _, c := range s.chunks {
+		buf = appendUvarint(buf, uint64(len(c.samples)))
+		prev := int64(0)
+		for _, sample := range c.samples {
+			buf = appendVarint(buf, sample.Timestamp-prev)
+			buf = appendFloat(buf, sample.Value)
+			prev = sample.Timestamp
+		}
+	}
+	return buf
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	return append(buf, b[:n]...)
+}
+
+func appendVarint(buf []byte, v int64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+	return append(buf, b[:n]...)
+}
+
+func appendFloat(buf []byte, v float64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], math.Float64bits(v))
+	return append(buf, b[:]...)
+}
+
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = ErrInvalid
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *reader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = ErrInvalid
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *reader) bytes(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if uint64(len(r.data)) < n {
+		r.err = ErrInvalid
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) byte() byte {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *reader) float() float64 {
+	b := r.bytes(8)
+	if b == nil {
+		return 0
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(b))
+}
+
+// Parse deserializes data generated by Bytes, data in the legacy format without labels is accepted as well
+// destination series of rules are not linked, caller should call Link for each rule
+func Parse(data []byte) (*Series, error) {
+	if len(data) < len(magic) {
+		return nil, ErrInvalid
+	}
+	header := string(data[:len(magic)])
+	if header != string(magic) && header != string(legacyMagic) {
+		return nil, ErrInvalid
+	}
+	r := &reader{data: data[len(magic):]}
+	s := &Series{
+		retention: r.varint(),
+		policy:    DuplicatePolicy(r.byte()),
+	}
+	if r.err != nil || s.retention < 0 || int(s.policy) >= len(policyNames) {
+		return nil, ErrInvalid
+	}
+	// 每个标签、规则和分块都至少占用 1 字节，数量大于剩余的字节数一定是错误的数据
+	if header == string(magic) {
+		numLabels := r.uvarint()
+		if numLabels > uint64(len(r.data)) {
+			return nil, ErrInvalid
+		}
+		for i := uint64(0); i < numLabels; i++ {
+			name := string(r.bytes(r.uvarint()))
+			value := string(r.bytes(r.uvarint()))
+			s.labels = append(s.labels, Label{Name: name, Value: value})
+		}
+	}
+	numRules := r.uvarint()
+	if numRules > uint64(len(r.data)) {
+		return nil, ErrInvalid
+	}
+	for i := uint64(0); i < numRules; i++ {
+		rule := &Rule{
+			DestKey:        string(r.bytes(r.uvarint())),
+			Aggregation:    Aggregation(r.byte()),
+			BucketDuration: r.varint(),
+			bucket:         r.varint(),
+		}
+		rule.acc.count = int64(r.uvarint())
+		rule.acc.sum = r.float()
+		rule.acc.min = r.float()
+		rule.acc.max = r.float()
+		rule.acc.first = r.float()
+		rule.acc.last = r.float()
+		if r.err != nil || int(rule.Aggregation) >= len(aggregationNames) || rule.BucketDuration <= 0 || rule.acc.count < 0 {
+			return nil, ErrInvalid
+		}
+		s.rules = append(s.rules, rule)
+	}
+	numChunks := r.uvarint()
+	if numChunks > uint64(len(r.data)) {
+		return nil, ErrInvalid
+	}
+	prev := int64(math.MinInt64)
+	for i := uint64(0); i < numChunks; i++ {
+		n := r.uvarint()
+		if r.err != nil || n == 0 || n > uint64(len(r.data)) {
+			return nil, ErrInvalid
+		}
+		c := &chunk{samples: make([]Sample, 0, n)}
+		timestamp := int64(0)
+		for j := uint64(0); j < n; j++ {
+			timestamp += r.varint()
+			value := r.float()
+			if r.err != nil || timestamp <= prev {
+				return nil, ErrInvalid
+			}
+			c.samples = append(c.samples, Sample{Timestamp: timestamp, Value: value})
+			prev = timestamp
+		}
+		s.chunks = append(s.chunks, c)
+		s.count += int(n)
+	}
+	if r.err != nil || len(r.data) > 0 {
+		return nil, ErrInvalid
+	}
+	s.trim()
+	return s, nil
+}
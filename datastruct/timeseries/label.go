@@ -0,0 +1,107 @@
+package timeseries
+
+import (
+	"errors"
+	"strings"
+)
+
+// Label is a name value pair attached to series, used by TS.MRANGE to select series
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Labels returns copies of labels
+func (s *Series) Labels() []Label {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Label(nil), s.labels...)
+}
+
+// SetLabels replaces all labels
+func (s *Series) SetLabels(labels []Label) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.labels = append([]Label(nil), labels...)
+}
+
+// ErrInvalidFilter is returned by ParseFilter for malformed filter expressions
+var ErrInvalidFilter = errors.New("ERR TSDB: failed parsing labels")
+
+// Filter is a label matcher of TS.MRANGE
+// 与 RedisTimeSeries 一致:
+// label=value 和 label=(v1,v2) 要求标签等于其中一个值，label= 要求没有该标签；
+// label!=value 和 label!=(v1,v2) 要求标签不等于任何一个值，label!= 要求有该标签
+type Filter struct {
+	Name   string
+	Values []string
+	Equal  bool
+}
+
+// ParseFilter parses a filter expression
+func ParseFilter(expr string) (*Filter, error) {
+	i := strings.IndexByte(expr, '=')
+	if i <= 0 {
+		return nil, ErrInvalidFilter
+	}
+	f := &Filter{Name: expr[:i], Equal: true}
+	if strings.HasSuffix(f.Name, "!") {
+		f.Name = f.Name[:len(f.Name)-1]
+		f.Equal = false
+	}
+	if f.Name == "" {
+		return nil, ErrInvalidFilter
+	}
+	value := expr[i+1:]
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		for _, v := range strings.Split(value[1:len(value)-1], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				f.Values = append(f.Values, v)
+			}
+		}
+	} else if value != "" {
+		f.Values = []string{value}
+	}
+	return f, nil
+}
+
+// IsMatcher returns true if the filter selects series by label values, TS.MRANGE requires at least one matcher
+func (f *Filter) IsMatcher() bool {
+	return f.Equal && len(f.Values) > 0
+}
+
+func (f *Filter) match(labels []Label) bool {
+	var value string
+	exists := false
+	for _, label := range labels {
+		if label.Name == f.Name {
+			value, exists = label.Value, true
+			break
+		}
+	}
+	if len(f.Values) == 0 {
+		return exists != f.Equal
+	}
+	in := false
+	if exists {
+		for _, v := range f.Values {
+			if v == value {
+				in = true
+				break
+			}
+		}
+	}
+	return in == f.Equal
+}
+
+// Match returns true if labels of series match all filters
+func (s *Series) Match(filters []*Filter) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, f := range filters {
+		if !f.match(s.labels) {
+			return false
+		}
+	}
+	return true
+}
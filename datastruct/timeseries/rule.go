@@ -0,0 +1,105 @@
+package timeseries
+
+// Rule is a compaction rule, it aggregates samples of source series in each bucket into one sample of destination series
+// 规则记录当前未结束的时间桶，源序列写入下一个时间桶的样本时才把上一个时间桶的聚合结果写入目标序列。
+// 早于当前时间桶的乱序样本不会更新目标序列
+type Rule struct {
+	DestKey        string
+	Aggregation    Aggregation
+	BucketDuration int64
+
+	dest *Series
+	// bucket 是未结束的时间桶的起点，acc.count 为 0 表示没有未结束的时间桶
+	bucket int64
+	acc    aggregator
+}
+
+// add accumulates a sample, returns the aggregated sample of the closed bucket
+func (r *Rule) add(timestamp int64, value float64) (Sample, bool) {
+	start := bucketStart(timestamp, r.BucketDuration)
+	if r.acc.count > 0 && start != r.bucket {
+		if start < r.bucket {
+			return Sample{}, false
+		}
+		closed := Sample{Timestamp: r.bucket, Value: r.acc.result(r.Aggregation)}
+		r.bucket = start
+		r.acc = aggregator{}
+		r.acc.add(value)
+		return closed, true
+	}
+	r.bucket = start
+	r.acc.add(value)
+	return Sample{}, false
+}
+
+// CreateRule adds a compaction rule writing into dest, which is stored at destKey
+func (s *Series) CreateRule(destKey string, dest *Series, agg Aggregation, bucketDuration int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rule := range s.rules {
+		if rule.DestKey == destKey {
+			return ErrRuleExists
+		}
+	}
+	s.rules = append(s.rules, &Rule{
+		DestKey:        destKey,
+		Aggregation:    agg,
+		BucketDuration: bucketDuration,
+		dest:           dest,
+	})
+	return nil
+}
+
+// DeleteRule removes the compaction rule writing into destKey
+func (s *Series) DeleteRule(destKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, rule := range s.rules {
+		if rule.DestKey == destKey {
+			s.rules = append(s.rules[:i:i], s.rules[i+1:]...)
+			return nil
+		}
+	}
+	return ErrRuleNotFound
+}
+
+// Rules returns copies of compaction rules
+func (s *Series) Rules() []Rule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rules := make([]Rule, len(s.rules))
+	for i, rule := range s.rules {
+		rules[i] = *rule
+	}
+	return rules
+}
+
+// Link sets destination series of the rule writing into destKey, used after Parse
+// 反序列化得到的规则只有目标键名，需要由调用方找到目标序列后关联
+func (s *Series) Link(destKey string, dest *Series) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rule := range s.rules {
+		if rule.DestKey == destKey {
+			rule.dest = dest
+		}
+	}
+}
+
+// Replace replaces content of s with other in place, so that rules of other series writing into s remain valid
+func (s *Series) Replace(other *Series) {
+	if s == other {
+		return
+	}
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retention = other.retention
+	s.policy = other.policy
+	s.chunks = other.chunks
+	s.count = other.count
+	s.rules = other.rules
+	s.labels = other.labels
+	s.scheduleTrim()
+}
@@ -0,0 +1,372 @@
+// Package timeseries 简化的时间序列，与 RedisTimeSeries 的 TS.* 命令语义一致
+// 样本按时间戳有序地保存在若干个分块中，每个分块最多 ChunkSize 个样本。
+// 超出保留时长的样本在读取时被忽略，由时间轮中的任务从头部批量裁剪，避免每次写入都复制分块。
+// 压缩规则把源序列中每个时间桶内的样本聚合成一个样本写入目标序列，
+// 目标序列在源序列的写命令中被修改，此时只持有源序列的键锁，因此 Series 的方法使用自身的锁保护
+package timeseries
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"Godis/lib/timewheel"
+)
+
+// ChunkSize is max number of samples in a chunk
+// 与 RedisTimeSeries 默认的 4096 字节的分块相同，每个样本 16 字节
+const ChunkSize = 256
+
+// trimDelay is the delay of trimming samples older than retention after they expire
+var trimDelay = time.Second
+
+// DuplicatePolicy decides what to do when adding a sample with an existing timestamp
+type DuplicatePolicy uint8
+
+const (
+	PolicyBlock DuplicatePolicy = iota
+	PolicyFirst
+	PolicyLast
+	PolicyMin
+	PolicyMax
+	PolicySum
+)
+
+var policyNames = []string{"block", "first", "last", "min", "max", "sum"}
+
+func (p DuplicatePolicy) String() string {
+	return policyNames[p]
+}
+
+// ParseDuplicatePolicy parses policy name case-insensitively
+func ParseDuplicatePolicy(name string) (DuplicatePolicy, bool) {
+	name = strings.ToLower(name)
+	for i, n := range policyNames {
+		if n == name {
+			return DuplicatePolicy(i), true
+		}
+	}
+	return 0, false
+}
+
+var (
+	ErrDuplicate    = errors.New("ERR TSDB: Error at upsert, update is not supported when DUPLICATE_POLICY is set to BLOCK mode")
+	ErrTooOld       = errors.New("ERR TSDB: Timestamp is older than retention")
+	ErrRuleExists   = errors.New("ERR TSDB: the destination key already has a rule")
+	ErrRuleNotFound = errors.New("ERR TSDB: compaction rule does not exist")
+)
+
+// Sample is a data point of time series
+type Sample struct {
+	Timestamp int64
+	Value     float64
+}
+
+type chunk struct {
+	samples []Sample
+}
+
+func (c *chunk) first() int64 {
+	return c.samples[0].Timestamp
+}
+
+func (c *chunk) last() int64 {
+	return c.samples[len(c.samples)-1].Timestamp
+}
+
+// Series is a time series
+type Series struct {
+	mu sync.RWMutex
+	// retention 是最新样本与最旧样本的最大时间差，单位毫秒，0 表示永久保留
+	retention int64
+	policy    DuplicatePolicy
+	chunks    []*chunk
+	count     int
+	rules     []*Rule
+	labels    []Label
+	// trimPending 表示时间轮中已有裁剪任务
+	trimPending bool
+}
+
+// New makes an empty series, retention is in milliseconds and 0 means keeping samples forever
+func New(retention int64, policy DuplicatePolicy) *Series {
+	return &Series{
+		retention: retention,
+		policy:    policy,
+	}
+}
+
+// Retention returns retention in milliseconds
+func (s *Series) Retention() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.retention
+}
+
+// Policy returns the duplicate policy
+func (s *Series) Policy() DuplicatePolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.policy
+}
+
+// Len returns number of samples
+func (s *Series) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.count - s.staleCount()
+}
+
+// Chunks returns number of chunks
+func (s *Series) Chunks() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.chunks)
+}
+
+// Size returns bytes of samples
+func (s *Series) Size() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.count * 16
+}
+
+// Last returns the latest sample
+func (s *Series) Last() (Sample, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.chunks) == 0 {
+		return Sample{}, false
+	}
+	c := s.chunks[len(s.chunks)-1]
+	return c.samples[len(c.samples)-1], true
+}
+
+// Add adds a sample, timestamp is unix time in milliseconds
+// policy overrides the duplicate policy of series if it is not nil
+// samples in order are aggregated by compaction rules and written into destination series
+func (s *Series) Add(timestamp int64, value float64, policy *DuplicatePolicy) error {
+	s.mu.Lock()
+	if policy == nil {
+		policy = &s.policy
+	}
+	if err := s.upsert(Sample{Timestamp: timestamp, Value: value}, *policy); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	var compacted []compaction
+	for _, rule := range s.rules {
+		if rule.dest == nil {
+			continue
+		}
+		if sample, ok := rule.add(timestamp, value); ok {
+			compacted = append(compacted, compaction{dest: rule.dest, sample: sample})
+		}
+	}
+	s.mu.Unlock()
+	// 释放源序列的锁后再写入目标序列，两个序列互为对方的目标时不会死锁
+	for _, c := range compacted {
+		c.dest.addCompacted(c.sample)
+	}
+	return nil
+}
+
+type compaction struct {
+	dest   *Series
+	sample Sample
+}
+
+// addCompacted 写入聚合后的样本，不会再触发目标序列的压缩规则
+func (s *Series) addCompacted(sample Sample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.upsert(sample, PolicyLast)
+}
+
+func (s *Series) upsert(sample Sample, policy DuplicatePolicy) error {
+	if len(s.chunks) == 0 {
+		s.chunks = append(s.chunks, &chunk{samples: []Sample{sample}})
+		s.count++
+		return nil
+	}
+	lastChunk := s.chunks[len(s.chunks)-1]
+	if s.retention > 0 && sample.Timestamp < lastChunk.last()-s.retention {
+		return ErrTooOld
+	}
+	// 大多数样本按时间顺序到达，直接追加到最后一个分块
+	if sample.Timestamp > lastChunk.last() {
+		if len(lastChunk.samples) >= ChunkSize {
+			lastChunk = &chunk{samples: make([]Sample, 0, ChunkSize)}
+			s.chunks = append(s.chunks, lastChunk)
+		}
+		lastChunk.samples = append(lastChunk.samples, sample)
+		s.count++
+		s.scheduleTrim()
+		return nil
+	}
+	// 乱序的样本插入到所属的分块中，分块过大时拆分
+	ci := sort.Search(len(s.chunks), func(i int) bool {
+		return s.chunks[i].last() >= sample.Timestamp
+	})
+	c := s.chunks[ci]
+	i := sort.Search(len(c.samples), func(i int) bool {
+		return c.samples[i].Timestamp >= sample.Timestamp
+	})
+	if i < len(c.samples) && c.samples[i].Timestamp == sample.Timestamp {
+		old := &c.samples[i]
+		switch policy {
+		case PolicyBlock:
+			return ErrDuplicate
+		case PolicyLast:
+			old.Value = sample.Value
+		case PolicyMin:
+			old.Value = math.Min(old.Value, sample.Value)
+		case PolicyMax:
+			old.Value = math.Max(old.Value, sample.Value)
+		case PolicySum:
+			old.Value += sample.Value
+		}
+		return nil
+	}
+	c.samples = append(c.samples, Sample{})
+	copy(c.samples[i+1:], c.samples[i:])
+	c.samples[i] = sample
+	s.count++
+	if len(c.samples) >= 2*ChunkSize {
+		half := &chunk{samples: append([]Sample(nil), c.samples[ChunkSize:]...)}
+		c.samples = c.samples[:ChunkSize:ChunkSize]
+		s.chunks = append(s.chunks, nil)
+		copy(s.chunks[ci+2:], s.chunks[ci+1:])
+		s.chunks[ci+1] = half
+	}
+	return nil
+}
+
+// minTimestamp returns the earliest timestamp within retention
+func (s *Series) minTimestamp() int64 {
+	if s.retention <= 0 || len(s.chunks) == 0 {
+		return math.MinInt64
+	}
+	return s.chunks[len(s.chunks)-1].last() - s.retention
+}
+
+// staleCount returns number of samples older than retention which are not trimmed yet
+func (s *Series) staleCount() int {
+	minTimestamp := s.minTimestamp()
+	n := 0
+	for _, c := range s.chunks {
+		if c.first() >= minTimestamp {
+			break
+		}
+		n += sort.Search(len(c.samples), func(i int) bool {
+			return c.samples[i].Timestamp >= minTimestamp
+		})
+	}
+	return n
+}
+
+// scheduleTrim adds a trimming job into time wheel if there are samples older than retention, caller should hold the lock
+func (s *Series) scheduleTrim() {
+	if s.trimPending || len(s.chunks) == 0 || s.chunks[0].first() >= s.minTimestamp() {
+		return
+	}
+	s.trimPending = true
+	timewheel.Delay(trimDelay, "", s.Trim)
+}
+
+// Trim removes samples older than retention
+func (s *Series) Trim() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trimPending = false
+	s.trim()
+}
+
+func (s *Series) trim() {
+	if s.retention <= 0 || len(s.chunks) == 0 {
+		return
+	}
+	minTimestamp := s.minTimestamp()
+	n := 0
+	for n < len(s.chunks) && s.chunks[n].last() < minTimestamp {
+		s.count -= len(s.chunks[n].samples)
+		n++
+	}
+	if n > 0 {
+		s.chunks = append([]*chunk(nil), s.chunks[n:]...)
+	}
+	c := s.chunks[0]
+	if c.first() >= minTimestamp {
+		return
+	}
+	i := sort.Search(len(c.samples), func(i int) bool {
+		return c.samples[i].Timestamp >= minTimestamp
+	})
+	s.count -= i
+	c.samples = append([]Sample(nil), c.samples[i:]...)
+}
+
+// forEach visits samples within [from, to] in order, returns false in consumer to break
+// samples older than retention are skipped
+func (s *Series) forEach(from int64, to int64, consumer func(sample Sample) bool) {
+	if minTimestamp := s.minTimestamp(); from < minTimestamp {
+		from = minTimestamp
+	}
+	ci := sort.Search(len(s.chunks), func(i int) bool {
+		return s.chunks[i].last() >= from
+	})
+	for ; ci < len(s.chunks); ci++ {
+		c := s.chunks[ci]
+		i := sort.Search(len(c.samples), func(i int) bool {
+			return c.samples[i].Timestamp >= from
+		})
+		for ; i < len(c.samples); i++ {
+			if c.samples[i].Timestamp > to {
+				return
+			}
+			if !consumer(c.samples[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Range returns samples within [from, to], at most count samples if count > 0
+// if agg is not nil, samples are aggregated into buckets of bucketDuration milliseconds, which is TS.RANGE with AGGREGATION
+func (s *Series) Range(from int64, to int64, count int, agg *Aggregation, bucketDuration int64) []Sample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var result []Sample
+	full := func() bool {
+		return count > 0 && len(result) >= count
+	}
+	if agg == nil {
+		s.forEach(from, to, func(sample Sample) bool {
+			result = append(result, sample)
+			return !full()
+		})
+		return result
+	}
+	var acc aggregator
+	bucket := int64(math.MinInt64)
+	s.forEach(from, to, func(sample Sample) bool {
+		start := bucketStart(sample.Timestamp, bucketDuration)
+		if start != bucket && acc.count > 0 {
+			result = append(result, Sample{Timestamp: bucket, Value: acc.result(*agg)})
+			acc = aggregator{}
+			if full() {
+				return false
+			}
+		}
+		bucket = start
+		acc.add(sample.Value)
+		return true
+	})
+	if acc.count > 0 && !full() {
+		result = append(result, Sample{Timestamp: bucket, Value: acc.result(*agg)})
+	}
+	return result
+}
@@ -0,0 +1,214 @@
+package timeseries
+
+import (
+	"testing"
+)
+
+func TestSeries_Add(t *testing.T) {
+	s := New(0, PolicyBlock)
+	for i := int64(0); i < 1000; i++ {
+		if err := s.Add(i*10, float64(i), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.Len() != 1000 || s.Chunks() != 4 {
+		t.Errorf("expect 1000 samples in 4 chunks, actual %d samples %d chunks", s.Len(), s.Chunks())
+	}
+	if err := s.Add(500, 1, nil); err != ErrDuplicate {
+		t.Errorf("expect ErrDuplicate, actual %v", err)
+	}
+	last := PolicyLast
+	if err := s.Add(500, 1, &last); err != nil {
+		t.Fatal(err)
+	}
+	// out of order samples are inserted into the chunk they belong to
+	for i := int64(0); i < 600; i++ {
+		if err := s.Add(i*10+5, -1, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	samples := s.Range(0, 10000, 0, nil, 0)
+	if len(samples) != 1600 || s.Len() != 1600 {
+		t.Fatalf("expect 1600 samples, actual %d", len(samples))
+	}
+	for i := 1; i < len(samples); i++ {
+		if samples[i].Timestamp <= samples[i-1].Timestamp {
+			t.Fatalf("samples are not ordered at %d", i)
+		}
+	}
+	if got := s.Range(500, 500, 0, nil, 0); len(got) != 1 || got[0].Value != 1 {
+		t.Errorf("expect updated sample, actual %v", got)
+	}
+	if got := s.Range(100, 10000, 3, nil, 0); len(got) != 3 || got[0].Timestamp != 100 {
+		t.Errorf("unexpected range with count: %v", got)
+	}
+}
+
+func TestSeries_Retention(t *testing.T) {
+	s := New(100, PolicyLast)
+	for i := int64(0); i < 1000; i++ {
+		_ = s.Add(i, float64(i), nil)
+	}
+	first := s.Range(0, 1000, 1, nil, 0)
+	if s.Len() != 101 || first[0].Timestamp != 899 {
+		t.Errorf("expect samples in [899, 999], actual %d samples from %d", s.Len(), first[0].Timestamp)
+	}
+	if err := s.Add(10, 1, nil); err != ErrTooOld {
+		t.Errorf("expect ErrTooOld, actual %v", err)
+	}
+	// expired samples are hidden until trimmed by the time wheel
+	if s.Chunks() != 4 {
+		t.Errorf("expect 4 chunks before trimming, actual %d", s.Chunks())
+	}
+	s.Trim()
+	if s.Len() != 101 || s.Chunks() != 1 {
+		t.Errorf("expect 101 samples in 1 chunk after trimming, actual %d samples %d chunks", s.Len(), s.Chunks())
+	}
+}
+
+func TestSeries_Aggregation(t *testing.T) {
+	s := New(0, PolicyBlock)
+	for i := int64(0); i < 10; i++ {
+		_ = s.Add(i, float64(i), nil)
+	}
+	cases := map[Aggregation][]float64{
+		AggAvg:   {1, 4, 7, 9},
+		AggSum:   {3, 12, 21, 9},
+		AggMin:   {0, 3, 6, 9},
+		AggMax:   {2, 5, 8, 9},
+		AggCount: {3, 3, 3, 1},
+		AggFirst: {0, 3, 6, 9},
+		AggLast:  {2, 5, 8, 9},
+		AggRange: {2, 2, 2, 0},
+	}
+	for agg, expected := range cases {
+		agg := agg
+		samples := s.Range(0, 100, 0, &agg, 3)
+		if len(samples) != len(expected) {
+			t.Fatalf("%s: expect %d buckets, actual %d", agg, len(expected), len(samples))
+		}
+		for i, sample := range samples {
+			if sample.Timestamp != int64(i*3) || sample.Value != expected[i] {
+				t.Errorf("%s: expect %d %v, actual %d %v", agg, i*3, expected[i], sample.Timestamp, sample.Value)
+			}
+		}
+	}
+	max := AggMax
+	if samples := s.Range(0, 100, 2, &max, 3); len(samples) != 2 {
+		t.Errorf("expect 2 buckets, actual %d", len(samples))
+	}
+}
+
+func TestSeries_Rule(t *testing.T) {
+	src := New(0, PolicyBlock)
+	dest := New(0, PolicyBlock)
+	if err := src.CreateRule("dest", dest, AggAvg, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.CreateRule("dest", dest, AggSum, 10); err != ErrRuleExists {
+		t.Errorf("expect ErrRuleExists, actual %v", err)
+	}
+	for i := int64(0); i < 35; i++ {
+		_ = src.Add(i, float64(i), nil)
+	}
+	// the bucket [30, 40) is still open
+	samples := dest.Range(0, 100, 0, nil, 0)
+	if len(samples) != 3 || samples[0].Value != 4.5 || samples[2].Timestamp != 20 || samples[2].Value != 24.5 {
+		t.Errorf("unexpected compacted samples: %v", samples)
+	}
+	// samples before the open bucket are not compacted
+	_ = src.Add(-5, 100, nil)
+	_ = src.Add(40, 0, nil)
+	if last, _ := dest.Last(); last.Timestamp != 30 || last.Value != 32 {
+		t.Errorf("unexpected last compacted sample: %v", last)
+	}
+	if err := src.DeleteRule("dest"); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.DeleteRule("dest"); err != ErrRuleNotFound {
+		t.Errorf("expect ErrRuleNotFound, actual %v", err)
+	}
+	_ = src.Add(100, 0, nil)
+	if dest.Len() != 4 {
+		t.Errorf("deleted rule should not write into dest")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	s := New(0, PolicyBlock)
+	s.SetLabels([]Label{{Name: "area", Value: "east"}, {Name: "sensor", Value: "1"}})
+	cases := map[string]bool{
+		"area=east":          true,
+		"area=west":          false,
+		"area=(west,east)":   true,
+		"area!=east":         false,
+		"area!=(west,north)": true,
+		"room=":              true,
+		"area=":              false,
+		"area!=":             true,
+		"room!=":             false,
+		"room!=x":            true,
+	}
+	for expr, expected := range cases {
+		f, err := ParseFilter(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if actual := s.Match([]*Filter{f}); actual != expected {
+			t.Errorf("%s: expect %v, actual %v", expr, expected, actual)
+		}
+	}
+	for _, bad := range []string{"area", "=east", "!=east"} {
+		if _, err := ParseFilter(bad); err == nil {
+			t.Errorf("expect error for %s", bad)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	s := New(3000, PolicyMax)
+	s.SetLabels([]Label{{Name: "area", Value: "east"}})
+	dest := New(0, PolicyBlock)
+	_ = s.CreateRule("dest", dest, AggMax, 60)
+	for i := int64(0); i < 700; i++ {
+		_ = s.Add(i*7, float64(i)/3, nil)
+	}
+	data := s.Bytes()
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(parsed.Bytes()) != string(data) || parsed.Len() != s.Len() || parsed.Retention() != 3000 || parsed.Policy() != PolicyMax {
+		t.Error("round trip mismatch")
+	}
+	if labels := parsed.Labels(); len(labels) != 1 || labels[0] != (Label{Name: "area", Value: "east"}) {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+	rules := parsed.Rules()
+	if len(rules) != 1 || rules[0].DestKey != "dest" || rules[0].dest != nil {
+		t.Fatal("rules should be parsed without destination")
+	}
+	// the open bucket continues after linking
+	restored := New(0, PolicyBlock)
+	parsed.Link("dest", restored)
+	_ = s.Add(4980, 0, nil)
+	_ = parsed.Add(4980, 0, nil)
+	expected, _ := dest.Last()
+	if actual, ok := restored.Last(); !ok || actual != expected {
+		t.Errorf("expect %v, actual %v", expected, actual)
+	}
+	// the legacy format has no labels
+	unlabeled := New(0, PolicyBlock)
+	_ = unlabeled.Add(1, 1, nil)
+	v2 := unlabeled.Bytes()
+	legacy := append([]byte("GTS1"), v2[4:6]...)
+	legacy = append(legacy, v2[7:]...)
+	if parsed, err = Parse(legacy); err != nil || parsed.Len() != 1 || len(parsed.Labels()) != 0 {
+		t.Errorf("failed to parse legacy format: %v", err)
+	}
+	for _, bad := range [][]byte{nil, []byte("GTS1"), data[:len(data)-1], append(data, 0)} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("expect error for %d bytes", len(bad))
+		}
+	}
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"Godis/interface/redis"
+	"context"
 	"github.com/hdt3213/rdb/core"
 	"time"
 )
@@ -27,9 +28,13 @@ type DBEngine interface {
 	DB
 	ExecWithLock(conn redis.Connection, cmdLine [][]byte) redis.Reply
 	ExecMulti(conn redis.Connection, watching map[string]uint32, cmdLines []CmdLine) redis.Reply
+	// RetryTx runs fn and executes returned commands as a transaction watching watchKeys, retries with backoff if watched keys changed
+	RetryTx(ctx context.Context, conn redis.Connection, watchKeys []string, maxAttempts int, fn TxFunc) (redis.Reply, error)
 	GetUndoLogs(dbIndex int, cmdLine [][]byte) []CmdLine
 	ForEach(dbIndex int, cb func(key string, data *DataEntity, expiration *time.Time) bool)
 	RWLocks(dbIndex int, writeKeys []string, readKeys []string)
+	// TryRWLocks is like RWLocks, but gives up and returns ctx.Err() when ctx is done
+	TryRWLocks(ctx context.Context, dbIndex int, writeKeys []string, readKeys []string) error
 	RWUnLocks(dbIndex int, writeKeys []string, readKeys []string)
 	GetDBSize(dbIndex int) (int, int)
 	GetEntity(dbIndex int, key string) (*DataEntity, bool)
@@ -38,7 +43,87 @@ type DBEngine interface {
 	SetKeyDeletedCallback(cb KeyEventCallback)
 }
 
+// TxFunc reads watched keys and returns commands to be executed atomically, used by RetryTx
+// It may be called multiple times, so it should not have side effects other than reading database
+// Returning an error aborts RetryTx without retrying
+type TxFunc func() ([]CmdLine, error)
+
+// DataType is the type tag of DataEntity, it is set when the entity is created and never changes
+type DataType uint8
+
+// Types of DataEntity, the zero value is string
+const (
+	TypeString DataType = iota
+	TypeList
+	TypeHash
+	TypeSet
+	TypeZSet
+	TypeBloom
+	TypeCuckoo
+	TypeTimeSeries
+	TypeJSON
+)
+
+// 与 TYPE 命令的返回值一致，模块类型使用 RedisBloom、RedisTimeSeries 和 RedisJSON 的类型名
+var dataTypeNames = [...]string{"string", "list", "hash", "set", "zset", "MBbloom--", "MBbloomCF", "TSDB-TYPE", "ReJSON-RL"}
+
+// 没有实现 Encoder 的数据结构的编码，模块类型与 redis 一致返回 raw
+var defaultEncodings = [...]string{"raw", "quicklist", "hashtable", "hashtable", "skiplist", "raw", "raw", "raw", "raw"}
+
+func (t DataType) String() string {
+	return dataTypeNames[t]
+}
+
+// Encoder is implemented by data structures which have more than one encoding, such as listpack and hashtable
+type Encoder interface {
+	Encoding() string
+}
+
 // DataEntity stores data bound to a key, including a string, list, hash, set and so on
 type DataEntity struct {
+	// Data of string is []byte, int64 or *EmbStr, see MakeStringData
 	Data interface{}
+	// Type is the type tag of Data, so that callers can tell the type without type assertion
+	Type DataType
+	// Access is LRU clock or LFU counter of the key, maintained by database when maxmemory-policy is lru or lfu
+	// multiple read commands may access it concurrently, use sync/atomic to read or write it
+	Access uint32
+	// Compressed is true if Data is a string compressed by lib/compress, see value-compression-threshold
+	Compressed bool
+}
+
+// embstrSizeLimit 与 redis 一致，不超过 44 字节的字符串使用 embstr 编码
+const embstrSizeLimit = 44
+
+// Encoding returns the internal encoding of Data, same as OBJECT ENCODING
+func (entity *DataEntity) Encoding() string {
+	if entity.Type == TypeString {
+		switch value := entity.Data.(type) {
+		case int64:
+			return "int"
+		case *EmbStr:
+			return "embstr"
+		case []byte:
+			return stringEncoding(value, entity.Compressed)
+		}
+	}
+	if encoder, ok := entity.Data.(Encoder); ok {
+		return encoder.Encoding()
+	}
+	return defaultEncodings[entity.Type]
+}
+
+// stringEncoding returns int for strings representing 64 bits integer, embstr for short strings and raw for others
+func stringEncoding(value []byte, compressed bool) string {
+	if compressed {
+		return "raw"
+	}
+	// 与 redis 一致，有前导 0 或正号等不能原样还原的整数不使用 int 编码
+	if _, ok := parseStringInt(value); ok {
+		return "int"
+	}
+	if len(value) <= embstrSizeLimit {
+		return "embstr"
+	}
+	return "raw"
 }
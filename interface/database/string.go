@@ -0,0 +1,81 @@
+package database
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// 字符串在 DataEntity 中有三种表示，与 redis 的 int、embstr、raw 编码对应:
+// 能原样还原的 64 位整数保存为 int64，不超过 embstrSizeLimit 字节的短字符串保存为 *EmbStr，其他字符串保存为 []byte
+// []byte 放入 interface 时需要分别分配切片头和底层数组，紧凑表示只需要一次分配，计数器类的值不再保存字符串本身
+
+// EmbStr is a short string whose content is allocated together with its length
+type EmbStr struct {
+	size uint8
+	buf  [embstrSizeLimit]byte
+}
+
+// Bytes returns content of the string, the returned slice shares memory with EmbStr and must not be modified
+func (s *EmbStr) Bytes() []byte {
+	return s.buf[:s.size:s.size]
+}
+
+// parseStringInt parses value as int64, returns false if value cannot be restored as it is,
+// such as integers with leading zeros or plus sign, same as redis int encoding
+func parseStringInt(value []byte) (int64, bool) {
+	if len(value) == 0 || len(value) > 20 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	// 在栈上格式化，避免每次写入都分配内存
+	var buf [20]byte
+	if !bytes.Equal(strconv.AppendInt(buf[:0], n, 10), value) {
+		return 0, false
+	}
+	return n, true
+}
+
+// MakeStringData returns the compact representation of string value
+// long value is stored as it is without copying, so the caller must not modify value after calling
+func MakeStringData(value []byte) interface{} {
+	if n, ok := parseStringInt(value); ok {
+		return n
+	}
+	if len(value) <= embstrSizeLimit {
+		s := &EmbStr{size: uint8(len(value))}
+		copy(s.buf[:], value)
+		return s
+	}
+	return value
+}
+
+// StringBytes returns content of string data made by MakeStringData, returns false if data is not a string
+// 整数需要转换为新的切片，其他表示返回的切片与数据共享内存，不能原地修改
+func StringBytes(data interface{}) ([]byte, bool) {
+	switch value := data.(type) {
+	case []byte:
+		return value, true
+	case int64:
+		return strconv.AppendInt(nil, value, 10), true
+	case *EmbStr:
+		return value.Bytes(), true
+	}
+	return nil, false
+}
+
+// StringInt returns value of string data made by MakeStringData as integer,
+// returns false if data is not a string or not an integer in canonical form, such as "+1", "01" and " 1"
+func StringInt(data interface{}) (int64, bool) {
+	switch value := data.(type) {
+	case int64:
+		return value, true
+	case []byte:
+		return parseStringInt(value)
+	case *EmbStr:
+		return parseStringInt(value.Bytes())
+	}
+	return 0, false
+}
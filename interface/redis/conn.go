@@ -34,3 +34,39 @@ type Connection interface {
 
 	Name() string
 }
+
+// Capability 表示连接支持的特性，多个特性按位组合
+type Capability uint64
+
+const (
+	// CapPush means server could push messages to this connection at any time, required by pub/sub
+	CapPush Capability = 1 << iota
+	// CapMulti means this connection could run MULTI/EXEC transactions
+	CapMulti
+	// CapBlocking means this connection could be blocked by blocking commands such as BLPOP
+	CapBlocking
+	// CapRESP3 means this connection speaks RESP3
+	CapRESP3
+	// CapTracking means this connection could receive client side caching invalidations
+	CapTracking
+	// CapReplyMode means this connection supports CLIENT REPLY ON|OFF|SKIP
+	CapReplyMode
+)
+
+// ConnectionV2 在 Connection 的基础上增加特性查询
+// 新增的特性通过 Capability 位声明，避免在代码各处对具体的连接类型做类型断言
+type ConnectionV2 interface {
+	Connection
+	// Capabilities returns all features supported by this connection
+	Capabilities() Capability
+}
+
+// Supports returns true if conn supports all of the given capabilities
+// 未实现 ConnectionV2 的连接只保证支持 Connection 接口本身，视为不支持任何特性
+func Supports(conn Connection, caps Capability) bool {
+	v2, ok := conn.(ConnectionV2)
+	if !ok {
+		return false
+	}
+	return v2.Capabilities()&caps == caps
+}
@@ -4,3 +4,10 @@ package redis
 type Reply interface {
 	ToBytes() []byte
 }
+
+// RESP3Reply is implemented by replies which have their own types in RESP3, such as double and boolean
+// ToBytes returns the RESP2 fallback, so that they can be sent to connections speaking RESP2
+type RESP3Reply interface {
+	Reply
+	ToRESP3Bytes() []byte
+}
@@ -0,0 +1,513 @@
+package aof
+
+import (
+	"Godis/interface/database"
+	"Godis/internal/config"
+	"Godis/internal/redis/connection"
+	"Godis/lib/logger"
+	"Godis/lib/utils"
+	"Godis/redis/parser"
+	"Godis/redis/protocol"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type CmdLine = [][]byte
+
+// Listener will be called-back after receiving a aof payload
+// with a listener we can forward the updates to slave nodes etc.
+type Listener interface {
+	// Callback will be called-back after receiving a aof payload
+	Callback([]CmdLine)
+}
+
+const (
+	// FsyncAlways do fsync for every command
+	FsyncAlways = "always"
+	// FsyncEverySec do fsync every second
+	FsyncEverySec = "everysec"
+	// FsyncNo lets operating system decides when to do fsync
+	FsyncNo = "no"
+)
+
+const (
+	aofQueueSize = 1 << 20
+	// aofBatchSize is max number of payloads written at once
+	aofBatchSize = 1024
+	// fsyncInterval is the interval of background fsync in everysec mode
+	fsyncInterval = time.Second
+	// fsyncDelayLimit 后台 fsync 超过该时长仍未完成时记录警告，与 Redis 一样最多丢失约2秒的数据
+	fsyncDelayLimit = 2 * time.Second
+)
+
+type payload struct {
+	cmdLine CmdLine
+	dbIndex int
+	// wg is not nil if the payload is a barrier marker, see Persister.Barrier
+	wg *sync.WaitGroup
+	// restart is not nil if the payload is a restart marker, see Persister.Restart
+	restart *restartMarker
+}
+
+// isMarker returns true if the payload carries no command but a marker
+func (p *payload) isMarker() bool {
+	return p.restart != nil || p.wg != nil
+}
+
+// Persister receive msgs from channel and write to AOF file
+type Persister struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	// ?
+	tmpDBMaker func() database.DBEngine
+	db         database.DBEngine
+	// aofChan is the channel to receive aof payload(listenCmd will send payload to this channel)
+	aofChan chan *payload
+	// aofFile is the file handler of aof file
+	aofFile *os.File
+	// aofFilename is the path of aof file
+	aofFilename string
+	// aofFsync is the strategy of fsync
+	aofFsync string
+	// aof goroutine will send msg to main goroutine through this channel when aof tasks finished and ready to shut down
+	aofFinished chan struct{}
+	// pause aof for start/finish aof rewrite progress
+	pausingAof sync.Mutex
+	// ?
+	currentDB int
+	listeners map[Listener]struct{}
+	// reuse cmdLine buffer
+	buffer []CmdLine
+	// pending 是写入失败的数据，下次写入时重试，与 Redis 的 aof_buf 相同
+	pending []byte
+	// rewriteBuffer 保存重写期间写入的命令，重写结束时追加到新的 AOF 文件，为 nil 表示没有进行中的重写
+	rewriteBuffer *bytes.Buffer
+	// everysec 模式下由独立的 goroutine 执行 fsync，fsyncChan 用于唤醒它，不持有 pausingAof，不会阻塞写入
+	fsyncChan     chan struct{}
+	fsyncFinished chan struct{}
+	// fsyncing is 1 while background fsync is running
+	fsyncing int32
+	// lastFsync is unix nano of the last successful background fsync
+	lastFsync int64
+	health    health
+}
+
+// NewPersister creates a new aof.Persister
+func NewPersister(db database.DBEngine, filename string, load bool, fsync string, tmpDBMaker func() database.DBEngine) (*Persister, error) {
+	persister := &Persister{}
+	persister.db = db
+	persister.aofFilename = filename
+	persister.aofFsync = strings.ToLower(fsync)
+	persister.tmpDBMaker = tmpDBMaker
+	// ?
+	persister.currentDB = 0
+	// load aof file if needed
+	if load {
+		persister.LoadAof(0)
+	}
+	aofFile, err := os.OpenFile(persister.aofFilename, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	persister.aofFile = aofFile
+	persister.aofChan = make(chan *payload, aofQueueSize)
+	persister.aofFinished = make(chan struct{})
+	persister.listeners = make(map[Listener]struct{})
+	// start aof goroutine to write aof file in background and fsync periodically if needed (see fsyncEverySecond)
+	go func() {
+		persister.listenCmd()
+	}()
+	// Create a context for AOF operations with cancellation support.
+	ctx, cancel := context.WithCancel(context.Background())
+	persister.ctx = ctx
+	persister.cancel = cancel
+	// fsync every second if needed
+	if persister.aofFsync == FsyncEverySec {
+		persister.fsyncEverySecond()
+	}
+	return persister, nil
+}
+
+// RemoveListener removers a listener from aof handler, so we can close the listener
+func (persister *Persister) RemoveListener(listener Listener) {
+	persister.pausingAof.Lock()
+	defer persister.pausingAof.Unlock()
+	delete(persister.listeners, listener)
+}
+
+// SaveCmdLine send command to aof goroutine through channel
+func (persister *Persister) SaveCmdLine(dbIndex int, cmdLine CmdLine) {
+	// aofChan will be set as nil temporarily during load aof see Persister.LoadAof
+	if persister.aofChan == nil {
+		return
+	}
+	// aofFsync的策略决定了是否每条Cmd都要同步
+	if persister.aofFsync == FsyncAlways {
+		p := &payload{
+			cmdLine: cmdLine,
+			dbIndex: dbIndex,
+		}
+		persister.writeAof(p)
+		return
+	}
+
+	persister.aofChan <- &payload{
+		cmdLine: cmdLine,
+		dbIndex: dbIndex,
+	}
+}
+
+// QueueLength returns count of commands waiting in queue to be written
+func (persister *Persister) QueueLength() int {
+	return len(persister.aofChan)
+}
+
+// Barrier blocks until commands saved before it have been written and passed to listeners
+// 主节点通过它得到包含此前所有写命令的复制偏移量
+func (persister *Persister) Barrier() {
+	// always 模式下命令直接写入文件，不经过 aofChan；载入 aof 期间 aofChan 为 nil
+	if persister.aofFsync == FsyncAlways || persister.aofChan == nil {
+		return
+	}
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	persister.aofChan <- &payload{wg: wg}
+	wg.Wait()
+}
+
+// listenCmd listen aof channel and write into file
+// 每次取出通道中已经积压的 payload 作为一批写入，同一批中相同 db 的命令共用一个 SELECT
+func (persister *Persister) listenCmd() {
+	batch := make([]*payload, 0, aofBatchSize)
+	for p := range persister.aofChan {
+		marker := p
+		if !p.isMarker() {
+			batch, marker = persister.drainBatch(append(batch[:0], p))
+			persister.writeAof(batch...)
+		}
+		if marker == nil {
+			continue
+		}
+		// 标记之前的命令已经写入文件并通知了监听器
+		if marker.restart != nil {
+			// 重启标记之前的命令已经写入旧文件
+			marker.restart.done <- persister.swapFile(marker.restart.tmpFile)
+		} else {
+			marker.wg.Done()
+		}
+	}
+	persister.aofFinished <- struct{}{}
+}
+
+// drainBatch appends payloads already in aofChan to batch without blocking
+// it stops at a marker and returns it, the marker must be handled after writing the batch
+func (persister *Persister) drainBatch(batch []*payload) ([]*payload, *payload) {
+	for len(batch) < aofBatchSize {
+		select {
+		case p, ok := <-persister.aofChan:
+			if !ok {
+				return batch, nil
+			}
+			if p.isMarker() {
+				return batch, p
+			}
+			batch = append(batch, p)
+		default:
+			return batch, nil
+		}
+	}
+	return batch, nil
+}
+
+// isCrossDB returns whether the command affects databases other than the one it is saved in
+// 这类命令不能与其它 db 的命令交换顺序
+func isCrossDB(cmdLine CmdLine) bool {
+	switch strings.ToLower(string(cmdLine[0])) {
+	case "flushall", "copy":
+		return true
+	}
+	return false
+}
+
+// coalesceByDB reorders payloads so that commands of the same db are adjacent, currentDB is the selected db before the batch
+// 命令按 db 分组，组内保持原有顺序，当前 db 的命令排在最前面，其余 db 按第一次出现的顺序排列。
+// 不同 db 的命令互不影响，重放得到的数据与原顺序相同；跨 db 的命令作为分界，不参与重排
+func coalesceByDB(payloads []*payload, currentDB int) []*payload {
+	result := make([]*payload, 0, len(payloads))
+	var order []int
+	groups := make(map[int][]*payload)
+	flush := func() {
+		for _, dbIndex := range order {
+			result = append(result, groups[dbIndex]...)
+			currentDB = dbIndex
+		}
+		order = order[:0]
+		groups = make(map[int][]*payload)
+	}
+	for _, p := range payloads {
+		if isCrossDB(p.cmdLine) {
+			flush()
+			result = append(result, p)
+			currentDB = p.dbIndex
+			continue
+		}
+		if _, ok := groups[p.dbIndex]; !ok {
+			if p.dbIndex == currentDB {
+				order = append([]int{p.dbIndex}, order...)
+			} else {
+				order = append(order, p.dbIndex)
+			}
+		}
+		groups[p.dbIndex] = append(groups[p.dbIndex], p)
+	}
+	flush()
+	return result
+}
+
+func (persister *Persister) writeAof(payloads ...*payload) {
+	persister.pausingAof.Lock() // prevent other goroutines from pausing aof
+	defer persister.pausingAof.Unlock()
+	// fsync 为 always 时多个 goroutine 会并发调用 writeAof，需要在加锁后重置 buffer
+	persister.buffer = persister.buffer[:0] // reuse underlying array 重置buffer
+	if len(payloads) > 1 {
+		payloads = coalesceByDB(payloads, persister.currentDB)
+	}
+	var data []byte
+	for _, p := range payloads {
+		// ensure aof is in the right database
+		// 如果数据库不一致增加SELECT命令
+		if p.dbIndex != persister.currentDB {
+			selectCmd := utils.ToCmdLine("SELECT", strconv.Itoa(p.dbIndex))
+			persister.buffer = append(persister.buffer, selectCmd)
+			data = append(data, protocol.MakeMultiBulkReply(selectCmd).ToBytes()...)
+			persister.currentDB = p.dbIndex
+		}
+		// save command
+		persister.buffer = append(persister.buffer, p.cmdLine)
+		data = append(data, protocol.MakeMultiBulkReply(p.cmdLine).ToBytes()...)
+	}
+	// 一批命令只调用一次 Write
+	err := persister.flush(data)
+	if err != nil {
+		logger.Warn(err)
+	}
+	if persister.rewriteBuffer != nil {
+		persister.rewriteBuffer.Write(data)
+	}
+	// 通知AOF的监听器
+	for listener := range persister.listeners {
+		listener.Callback(persister.buffer)
+	}
+	if persister.aofFsync == FsyncAlways && err == nil {
+		// Sync commits the current contents of the file to stable storage.
+		_ = persister.sync(persister.aofFile)
+	}
+}
+
+// LoadAof read aof file, can only be used before Persister.listenCmd started
+// maxBytes用于限制最大读取量，为0时无限制
+func (persister *Persister) LoadAof(maxBytes int) {
+	// 避免在加载AOF期间继续有消息进入
+	aofChan := persister.aofChan
+	persister.aofChan = nil
+	defer func(aofChan chan *payload) {
+		persister.aofChan = aofChan
+	}(aofChan)
+
+	file, err := os.Open(persister.aofFilename)
+	if err != nil {
+		if _, ok := err.(*os.PathError); ok {
+			return
+		}
+		logger.Warn(err)
+		return
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			logger.Warn(err)
+		}
+	}(file)
+
+	// load rdb preamble if needed
+	//decoder := rdb.NewDecoder(file)
+	//err = persister.db.LoadRDB(decoder)
+	//if err != nil {
+	//	// no rdb preamble
+	//	file.Seek(0, io.SeekStart)
+	//} else {
+	//	// has rdb preamble
+	//	_, _ = file.Seek(int64(decoder.GetReadCount())+1, io.SeekStart)
+	//	maxBytes = maxBytes - decoder.GetReadCount()
+	//}
+	var reader io.Reader
+	if maxBytes > 0 {
+		reader = io.LimitReader(file, int64(maxBytes))
+	} else {
+		reader = file
+	}
+	ch := parser.ParseStream(reader)
+	fakeConn := connection.NewFakeConn()
+	for p := range ch {
+		if p.Err != nil {
+			if p.Err == io.EOF {
+				break
+			}
+			logger.Error("parse error: " + p.Err.Error())
+			continue
+		}
+		if p.Data == nil {
+			logger.Error("empty payload")
+			continue
+		}
+		// 为什么是MultiBulkReply [][]byte
+		r, ok := p.Data.(*protocol.MultiBulkReply)
+		if !ok {
+			logger.Error("require multi bulk protocol")
+			continue
+		}
+		ret := persister.db.Exec(fakeConn, r.Args)
+		if protocol.IsErrorReply(ret) {
+			logger.Error("exec err", string(ret.ToBytes()))
+		}
+		if strings.ToLower(string(r.Args[0])) == "select" {
+			// execSelect success, here must be no error
+			dbIndex, err := strconv.Atoi(string(r.Args[1]))
+			if err == nil {
+				persister.currentDB = dbIndex
+			}
+		}
+	}
+}
+
+// Fsync flushes aof file to disk
+// 只在获取文件句柄时持有 pausingAof，fsync 期间不阻塞写入
+func (persister *Persister) Fsync() error {
+	persister.pausingAof.Lock()
+	file := persister.aofFile
+	persister.pausingAof.Unlock()
+	return persister.sync(file)
+}
+
+// Close gracefully stops aof persistence procedure
+func (persister *Persister) Close() {
+	if persister.aofFile != nil {
+		close(persister.aofChan)
+		<-persister.aofFinished // wait for aof finished
+	}
+	persister.cancel()
+	if persister.fsyncFinished != nil {
+		// wait for background fsync finished, then flush the remaining data
+		<-persister.fsyncFinished
+		_ = persister.Fsync()
+	}
+	if persister.aofFile != nil {
+		err := persister.aofFile.Close()
+		if err != nil {
+			logger.Warn(err)
+		}
+	}
+}
+
+// fsyncEverySecond fsync aof file every second in a dedicated goroutine
+func (persister *Persister) fsyncEverySecond() {
+	persister.fsyncChan = make(chan struct{}, 1)
+	persister.fsyncFinished = make(chan struct{})
+	atomic.StoreInt64(&persister.lastFsync, time.Now().UnixNano())
+	go persister.fsyncLoop()
+	ticker := time.NewTicker(fsyncInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				persister.requestFsync()
+			case <-persister.ctx.Done():
+				close(persister.fsyncChan)
+				return
+			}
+		}
+	}()
+}
+
+// requestFsync wakes up the fsync goroutine, it does nothing if the previous fsync has not finished
+func (persister *Persister) requestFsync() {
+	if atomic.LoadInt32(&persister.fsyncing) == 1 {
+		lastFsync := time.Unix(0, atomic.LoadInt64(&persister.lastFsync))
+		if time.Since(lastFsync) > fsyncDelayLimit {
+			atomic.AddInt64(&persister.health.delayedFsync, 1)
+			logger.Warn("Asynchronous AOF fsync is taking too long (disk is busy?). " +
+				"Writing the AOF buffer without waiting for fsync to complete")
+		}
+		return
+	}
+	select {
+	case persister.fsyncChan <- struct{}{}:
+	default:
+	}
+}
+
+// fsyncLoop does fsync when requested until fsyncChan is closed
+func (persister *Persister) fsyncLoop() {
+	for range persister.fsyncChan {
+		atomic.StoreInt32(&persister.fsyncing, 1)
+		if persister.Fsync() == nil {
+			atomic.StoreInt64(&persister.lastFsync, time.Now().UnixNano())
+		}
+		atomic.StoreInt32(&persister.fsyncing, 0)
+	}
+	close(persister.fsyncFinished)
+}
+
+// LastFsync returns time of the last successful background fsync in everysec mode
+func (persister *Persister) LastFsync() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&persister.lastFsync))
+}
+
+func (persister *Persister) generateAof(ctx *RewriteCtx) error {
+	// 生成一个新的Rewrite Handler
+	tmpAof := persister.newRewriteHandler()
+	// 从persister.aofFilename读入AOF文件，加载到临时数据库中
+	tmpAof.LoadAof(int(ctx.fileSize))
+	return writeSnapshot(ctx.tmpFile, tmpAof.db)
+}
+
+// writeSnapshot writes commands rebuilding all data in db
+func writeSnapshot(w io.Writer, db database.DBEngine) error {
+	// 遍历所有的数据库
+	for i := 0; i < config.Properties.Databases; i++ {
+		data := protocol.MakeMultiBulkReply(utils.ToCmdLine("SELECT", strconv.Itoa(i))).ToBytes()
+		_, err := w.Write(data)
+		if err != nil {
+			return err
+		}
+		// 遍历数据库中的所有键值对，并写入AOF
+		db.ForEach(i, func(key string, entity *database.DataEntity, expiration *time.Time) bool {
+			cmd := EntityToCmd(key, entity)
+			if cmd != nil {
+				_, err = w.Write(cmd.ToBytes())
+			}
+			for _, fieldCmd := range HashFieldExpireCmds(key, entity) {
+				if err != nil {
+					break
+				}
+				_, err = w.Write(fieldCmd.ToBytes())
+			}
+			if err == nil && expiration != nil {
+				_, err = w.Write(MakeExpireCmd(key, *expiration).ToBytes())
+			}
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package aof
+
+import (
+	"Godis/lib/utils"
+	"Godis/redis/parser"
+	"Godis/redis/protocol"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func makePayload(dbIndex int, args ...string) *payload {
+	return &payload{
+		cmdLine: utils.ToCmdLine(args...),
+		dbIndex: dbIndex,
+	}
+}
+
+// readAof replays aof file and returns commands of each db in order, and the number of SELECT commands
+func readAof(t *testing.T, filename string) (map[int][]string, int) {
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	cmds := make(map[int][]string)
+	selects := 0
+	dbIndex := 0
+	for p := range parser.ParseStream(file) {
+		if p.Err != nil {
+			break
+		}
+		args := p.Data.(*protocol.MultiBulkReply).Args
+		if strings.ToLower(string(args[0])) == "select" {
+			dbIndex, _ = strconv.Atoi(string(args[1]))
+			selects++
+			continue
+		}
+		cmds[dbIndex] = append(cmds[dbIndex], string(args[1]))
+	}
+	return cmds, selects
+}
+
+func TestWriteAof_Coalesce(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "a.aof")
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	persister := &Persister{
+		aofFile:   file,
+		listeners: make(map[Listener]struct{}),
+	}
+	// clients of 3 databases write alternately
+	var batch []*payload
+	for i := 0; i < 30; i++ {
+		batch = append(batch, makePayload(i%3, "SET", strconv.Itoa(i), "v"))
+	}
+	persister.writeAof(batch...)
+	if persister.currentDB != 2 {
+		t.Errorf("expect current db 2, actual %d", persister.currentDB)
+	}
+	// the next batch starts with commands of the current db
+	persister.writeAof(makePayload(1, "SET", "30", "v"), makePayload(2, "SET", "31", "v"))
+	_ = file.Close()
+
+	cmds, selects := readAof(t, filename)
+	if selects != 3 {
+		t.Errorf("expect 3 SELECT, actual %d", selects)
+	}
+	for dbIndex := 0; dbIndex < 3; dbIndex++ {
+		var expected []string
+		for i := dbIndex; i < 30; i += 3 {
+			expected = append(expected, strconv.Itoa(i))
+		}
+		expected = append(expected, map[int][]string{1: {"30"}, 2: {"31"}}[dbIndex]...)
+		if strings.Join(cmds[dbIndex], ",") != strings.Join(expected, ",") {
+			t.Errorf("db %d: expect %v, actual %v", dbIndex, expected, cmds[dbIndex])
+		}
+	}
+}
+
+type countListener struct {
+	count int32
+}
+
+func (listener *countListener) Callback(cmdLines []CmdLine) {
+	atomic.AddInt32(&listener.count, int32(len(cmdLines)))
+}
+
+func TestBarrier(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "a.aof"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	listener := &countListener{}
+	persister := &Persister{
+		aofFile:     file,
+		aofChan:     make(chan *payload, aofQueueSize),
+		aofFinished: make(chan struct{}),
+		listeners:   map[Listener]struct{}{listener: {}},
+	}
+	for i := 0; i < 1000; i++ {
+		persister.SaveCmdLine(0, utils.ToCmdLine("SET", strconv.Itoa(i), "v"))
+	}
+	if n := persister.QueueLength(); n != 1000 {
+		t.Errorf("expect 1000 commands in queue, actual %d", n)
+	}
+	go persister.listenCmd()
+	// Barrier 返回时此前的命令都已经通知了监听器
+	persister.Barrier()
+	if count := atomic.LoadInt32(&listener.count); count != 1000 {
+		t.Errorf("expect 1000 commands, actual %d", count)
+	}
+	close(persister.aofChan)
+	<-persister.aofFinished
+}
+
+func TestCoalesceByDB_CrossDB(t *testing.T) {
+	payloads := []*payload{
+		makePayload(1, "SET", "a", "1"),
+		makePayload(0, "SET", "b", "1"),
+		makePayload(1, "SET", "c", "1"),
+		makePayload(0, "FLUSHALL"),
+		makePayload(1, "SET", "d", "1"),
+		makePayload(0, "SET", "e", "1"),
+		makePayload(1, "SET", "f", "1"),
+	}
+	var actual []string
+	for _, p := range coalesceByDB(payloads, 0) {
+		name := string(p.cmdLine[0])
+		if len(p.cmdLine) > 1 {
+			name = string(p.cmdLine[1])
+		}
+		actual = append(actual, strconv.Itoa(p.dbIndex)+":"+name)
+	}
+	// commands must not cross FLUSHALL, which clears all databases
+	expected := "0:b,1:a,1:c,0:FLUSHALL,0:e,1:d,1:f"
+	if strings.Join(actual, ",") != expected {
+		t.Errorf("expect %s, actual %s", expected, strings.Join(actual, ","))
+	}
+}
+
+func TestFsyncEverySecond(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "a.aof")
+	persister, err := NewPersister(nil, filename, false, FsyncEverySec, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := persister.LastFsync()
+	persister.SaveCmdLine(0, utils.ToCmdLine("SET", "a", "1"))
+	persister.requestFsync()
+	deadline := time.Now().Add(time.Second)
+	for !persister.LastFsync().After(start) {
+		if time.Now().After(deadline) {
+			t.Fatal("background fsync not finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	persister.Close()
+
+	cmds, _ := readAof(t, filename)
+	if len(cmds[0]) != 1 || cmds[0][0] != "a" {
+		t.Errorf("unexpected aof content %v", cmds)
+	}
+}
+
+func TestWriteAof_RetryAfterError(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "a.aof")
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// writing to a closed file fails
+	_ = file.Close()
+	persister := &Persister{
+		aofFile:   file,
+		listeners: make(map[Listener]struct{}),
+	}
+	persister.writeAof(makePayload(0, "SET", "a", "1"))
+	persister.writeAof(makePayload(0, "SET", "b", "1"))
+	stats := persister.Stats()
+	if stats.LastWriteOK || stats.WriteErrors != 2 || stats.PendingBytes == 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if err := persister.CheckWrite(3); err != nil {
+		t.Errorf("expect writes allowed before 3 errors, actual %v", err)
+	}
+	if err := persister.CheckWrite(2); err == nil || !strings.HasPrefix(err.Error(), "MISCONF") {
+		t.Errorf("expect MISCONF error, actual %v", err)
+	}
+
+	// retry succeeds once the file is writable
+	persister.aofFile, err = os.OpenFile(filename, os.O_APPEND|os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := persister.CheckWrite(2); err != nil {
+		t.Errorf("expect retry succeeded, actual %v", err)
+	}
+	stats = persister.Stats()
+	if !stats.LastWriteOK || stats.PendingBytes != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	_ = persister.aofFile.Close()
+	cmds, _ := readAof(t, filename)
+	if strings.Join(cmds[0], ",") != "a,b" {
+		t.Errorf("expect a,b, actual %v", cmds[0])
+	}
+}
@@ -0,0 +1,139 @@
+package aof
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"Godis/lib/logger"
+)
+
+// health 记录 AOF 的写入、fsync 和重写状态，用于 INFO persistence 以及在持续出错时拒绝写命令
+type health struct {
+	// writeErrors is count of consecutive failed writes, reset by a successful write
+	writeErrors int64
+	// fsyncFailed is 1 if the last fsync failed
+	fsyncFailed int32
+	// lastErr is the last write or fsync error message
+	lastErr atomic.Value // string
+	// delayedFsync counts ticks when the background fsync has not finished within fsyncDelayLimit
+	delayedFsync int64
+	// lastFsyncDuration is nanoseconds took by the last fsync
+	lastFsyncDuration int64
+	// rewriteFailed is 1 if the last rewrite failed
+	rewriteFailed int32
+}
+
+func (h *health) setWriteResult(err error) {
+	if err != nil {
+		atomic.AddInt64(&h.writeErrors, 1)
+		h.lastErr.Store(err.Error())
+		return
+	}
+	atomic.StoreInt64(&h.writeErrors, 0)
+}
+
+func (h *health) setFsyncResult(err error, cost time.Duration) {
+	atomic.StoreInt64(&h.lastFsyncDuration, int64(cost))
+	if err != nil {
+		atomic.StoreInt32(&h.fsyncFailed, 1)
+		h.lastErr.Store(err.Error())
+		return
+	}
+	atomic.StoreInt32(&h.fsyncFailed, 0)
+}
+
+func (h *health) setRewriteResult(err error) {
+	if err != nil {
+		atomic.StoreInt32(&h.rewriteFailed, 1)
+		return
+	}
+	atomic.StoreInt32(&h.rewriteFailed, 0)
+}
+
+// flush writes data after the pending data, must hold pausingAof
+func (persister *Persister) flush(data []byte) error {
+	if len(persister.pending) > 0 {
+		data = append(persister.pending, data...)
+		persister.pending = nil
+	}
+	n, err := persister.aofFile.Write(data)
+	if err != nil {
+		// 保留未写入的部分，文件以 O_APPEND 打开，重试时从中断处继续写入
+		persister.pending = data[n:]
+	}
+	persister.health.setWriteResult(err)
+	return err
+}
+
+// sync does fsync on file and records its result
+func (persister *Persister) sync(file *os.File) error {
+	start := time.Now()
+	err := file.Sync()
+	// aof 重写完成时会关闭旧文件，此前写入的数据已经在新文件中 fsync 过
+	if errors.Is(err, os.ErrClosed) {
+		return nil
+	}
+	persister.health.setFsyncResult(err, time.Since(start))
+	if err != nil {
+		logger.Errorf("fsync failed: %v", err)
+	}
+	return err
+}
+
+// Stats is the health status of aof persistence, see the persistence section of INFO
+type Stats struct {
+	RewriteInProgress bool
+	LastRewriteOK     bool
+	LastWriteOK       bool
+	// WriteErrors is count of consecutive failed writes
+	WriteErrors       int64
+	DelayedFsync      int64
+	LastFsyncDuration time.Duration
+	// PendingBytes is size of data failed to write and waiting for retry
+	PendingBytes int
+}
+
+// Stats returns the health status of aof persistence
+func (persister *Persister) Stats() Stats {
+	persister.pausingAof.Lock()
+	rewriting := persister.rewriteBuffer != nil
+	pending := len(persister.pending)
+	persister.pausingAof.Unlock()
+	h := &persister.health
+	writeErrors := atomic.LoadInt64(&h.writeErrors)
+	return Stats{
+		RewriteInProgress: rewriting,
+		LastRewriteOK:     atomic.LoadInt32(&h.rewriteFailed) == 0,
+		LastWriteOK:       writeErrors == 0 && atomic.LoadInt32(&h.fsyncFailed) == 0,
+		WriteErrors:       writeErrors,
+		DelayedFsync:      atomic.LoadInt64(&h.delayedFsync),
+		LastFsyncDuration: time.Duration(atomic.LoadInt64(&h.lastFsyncDuration)),
+		PendingBytes:      pending,
+	}
+}
+
+// CheckWrite returns error if the aof has failed maxErrors times in a row, maxErrors <= 0 disables the check
+// 出错时会先重试写入未写入的数据和 fsync，成功后恢复正常，与 Redis 在 serverCron 中重试相同
+func (persister *Persister) CheckWrite(maxErrors int) error {
+	if maxErrors <= 0 {
+		return nil
+	}
+	h := &persister.health
+	if atomic.LoadInt64(&h.writeErrors) < int64(maxErrors) && atomic.LoadInt32(&h.fsyncFailed) == 0 {
+		return nil
+	}
+	persister.pausingAof.Lock()
+	err := persister.flush(nil)
+	file := persister.aofFile
+	persister.pausingAof.Unlock()
+	if err == nil {
+		err = persister.sync(file)
+	}
+	if err != nil {
+		msg, _ := h.lastErr.Load().(string)
+		return errors.New("MISCONF Errors writing to the AOF file: " + msg)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package aof
+
+import (
+	"Godis/datastruct/bloom"
+	"Godis/datastruct/cuckoo"
+	Hash "Godis/datastruct/hash"
+	JSON "Godis/datastruct/json"
+	List "Godis/datastruct/list"
+	HashSet "Godis/datastruct/set"
+	SortedSet "Godis/datastruct/sortedset"
+	"Godis/datastruct/timeseries"
+	"Godis/interface/database"
+	"Godis/lib/compress"
+	"Godis/lib/logger"
+	"Godis/redis/protocol"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// EntityToCmd serialize data entity to redis command
+func EntityToCmd(key string, entity *database.DataEntity) *protocol.MultiBulkReply {
+	if entity == nil {
+		return nil
+	}
+	var cmd *protocol.MultiBulkReply
+	switch val := entity.Data.(type) {
+	case []byte, int64, *database.EmbStr:
+		bytes, _ := database.StringBytes(val)
+		if entity.Compressed {
+			raw, err := compress.Decompress(bytes)
+			if err != nil {
+				logger.Warn("decompress value of " + key + " failed: " + err.Error())
+				return nil
+			}
+			bytes = raw
+		}
+		cmd = stringToCmd(key, bytes)
+	case List.List:
+		cmd = listToCmd(key, val)
+	case *Hash.Hash:
+		cmd = hashToCmd(key, val)
+	case *HashSet.Set:
+		cmd = setToCmd(key, val)
+	case *SortedSet.SortedSet:
+		cmd = zSetToCmd(key, val)
+	case *bloom.Filter:
+		cmd = bloomToCmd(key, val)
+	case *cuckoo.Filter:
+		cmd = cuckooToCmd(key, val)
+	case *timeseries.Series:
+		cmd = timeSeriesToCmd(key, val)
+	case *JSON.Document:
+		cmd = jsonToCmd(key, val)
+	}
+	return cmd
+}
+
+var setCmd = []byte("SET")
+
+func stringToCmd(key string, bytes []byte) *protocol.MultiBulkReply {
+	args := make([][]byte, 3)
+	args[0] = setCmd
+	args[1] = []byte(key)
+	args[2] = bytes
+	return protocol.MakeMultiBulkReply(args)
+}
+
+var rPushAllCmd = []byte("RPUSH")
+
+func listToCmd(key string, list List.List) *protocol.MultiBulkReply {
+	args := make([][]byte, 2, 2+list.Len())
+	args[0] = rPushAllCmd
+	args[1] = []byte(key)
+	list.ForEach(func(i int, val interface{}) bool {
+		bytes, _ := val.([]byte)
+		args = append(args, bytes)
+		return true
+	})
+	return protocol.MakeMultiBulkReply(args)
+}
+
+var hMSetCmd = []byte("HMSET")
+
+// hashToCmd 字段的过期时间见 HashFieldExpireCmds
+func hashToCmd(key string, hash *Hash.Hash) *protocol.MultiBulkReply {
+	args := make([][]byte, 2, 2+hash.Len()*2)
+	args[0] = hMSetCmd
+	args[1] = []byte(key)
+	hash.ForEach(func(field string, value []byte) bool {
+		args = append(args, []byte(field), value)
+		return true
+	})
+	return protocol.MakeMultiBulkReply(args)
+}
+
+var hPExpireAtCmd = []byte("HPEXPIREAT")
+
+// HashFieldExpireCmds returns HPEXPIREAT commands restoring expiration of hash fields, it should be written after EntityToCmd
+// fields expiring at the same millisecond share one command, returns nil if entity is not a hash or no field has expiration
+func HashFieldExpireCmds(key string, entity *database.DataEntity) []*protocol.MultiBulkReply {
+	if entity == nil {
+		return nil
+	}
+	hash, ok := entity.Data.(*Hash.Hash)
+	if !ok || hash.ExpiresCount() == 0 {
+		return nil
+	}
+	fieldsAt := make(map[int64][]string)
+	hash.ForEach(func(field string, value []byte) bool {
+		if expireAt, hasTTL := hash.ExpireTime(field); hasTTL {
+			ms := expireAt.UnixMilli()
+			fieldsAt[ms] = append(fieldsAt[ms], field)
+		}
+		return true
+	})
+	times := make([]int64, 0, len(fieldsAt))
+	for ms := range fieldsAt {
+		times = append(times, ms)
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i] < times[j]
+	})
+	cmds := make([]*protocol.MultiBulkReply, 0, len(times))
+	for _, ms := range times {
+		fields := fieldsAt[ms]
+		args := make([][]byte, 0, 5+len(fields))
+		args = append(args, hPExpireAtCmd, []byte(key), []byte(strconv.FormatInt(ms, 10)),
+			[]byte("FIELDS"), []byte(strconv.Itoa(len(fields))))
+		for _, field := range fields {
+			args = append(args, []byte(field))
+		}
+		cmds = append(cmds, protocol.MakeMultiBulkReply(args))
+	}
+	return cmds
+}
+
+var sAddCmd = []byte("SADD")
+
+func setToCmd(key string, set *HashSet.Set) *protocol.MultiBulkReply {
+	args := make([][]byte, 2, 2+set.Len())
+	args[0] = sAddCmd
+	args[1] = []byte(key)
+	set.ForEach(func(member string) bool {
+		args = append(args, []byte(member))
+		return true
+	})
+	return protocol.MakeMultiBulkReply(args)
+}
+
+var zAddCmd = []byte("ZADD")
+
+func zSetToCmd(key string, zset *SortedSet.SortedSet) *protocol.MultiBulkReply {
+	size := zset.Len()
+	args := make([][]byte, 2, 2+size*2)
+	args[0] = zAddCmd
+	args[1] = []byte(key)
+	zset.ForEachByRank(0, size, false, func(element *SortedSet.Element) bool {
+		args = append(args, []byte(strconv.FormatFloat(element.Score, 'f', -1, 64)), []byte(element.Member))
+		return true
+	})
+	return protocol.MakeMultiBulkReply(args)
+}
+
+var bfLoadChunkCmd = []byte("BF.LOADCHUNK")
+
+// bloomToCmd 整个过滤器保存在一个分块中
+func bloomToCmd(key string, filter *bloom.Filter) *protocol.MultiBulkReply {
+	args := make([][]byte, 4)
+	args[0] = bfLoadChunkCmd
+	args[1] = []byte(key)
+	args[2] = []byte("1")
+	args[3] = filter.Bytes()
+	return protocol.MakeMultiBulkReply(args)
+}
+
+var cfLoadChunkCmd = []byte("CF.LOADCHUNK")
+
+func cuckooToCmd(key string, filter *cuckoo.Filter) *protocol.MultiBulkReply {
+	args := make([][]byte, 4)
+	args[0] = cfLoadChunkCmd
+	args[1] = []byte(key)
+	args[2] = []byte("1")
+	args[3] = filter.Bytes()
+	return protocol.MakeMultiBulkReply(args)
+}
+
+var tsLoadChunkCmd = []byte("TS.LOADCHUNK")
+
+// timeSeriesToCmd 所有分块和压缩规则保存在一个命令中
+func timeSeriesToCmd(key string, series *timeseries.Series) *protocol.MultiBulkReply {
+	args := make([][]byte, 4)
+	args[0] = tsLoadChunkCmd
+	args[1] = []byte(key)
+	args[2] = []byte("1")
+	args[3] = series.Bytes()
+	return protocol.MakeMultiBulkReply(args)
+}
+
+var jsonSetCmd = []byte("JSON.SET")
+
+func jsonToCmd(key string, doc *JSON.Document) *protocol.MultiBulkReply {
+	args := make([][]byte, 4)
+	args[0] = jsonSetCmd
+	args[1] = []byte(key)
+	args[2] = []byte("$")
+	args[3] = doc.Marshal(&JSON.Format{})
+	return protocol.MakeMultiBulkReply(args)
+}
+
+var pExpireAtBytes = []byte("PEXPIREAT")
+
+// MakeExpireCmd generates command line to set expiration for the given key
+func MakeExpireCmd(key string, expireAt time.Time) *protocol.MultiBulkReply {
+	args := make([][]byte, 3)
+	args[0] = pExpireAtBytes
+	args[1] = []byte(key)
+	args[2] = []byte(strconv.FormatInt(expireAt.UnixNano()/1e6, 10))
+	return protocol.MakeMultiBulkReply(args)
+}
@@ -2,15 +2,22 @@ package aof
 
 import (
 	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 
-	"Godis/config"
-	"Godis/datastruct/dict"
+	"Godis/datastruct/bloom"
+	"Godis/datastruct/cuckoo"
+	Hash "Godis/datastruct/hash"
+	JSON "Godis/datastruct/json"
 	List "Godis/datastruct/list"
 	"Godis/datastruct/set"
 	SortedSet "Godis/datastruct/sortedset"
+	"Godis/datastruct/timeseries"
 	"Godis/interface/database"
+	"Godis/internal/config"
+	"Godis/lib/compress"
+	"Godis/lib/fileutil"
 	"Godis/lib/logger"
 	rdb "github.com/hdt3213/rdb/encoder"
 	"github.com/hdt3213/rdb/model"
@@ -20,50 +27,38 @@ import (
 
 // GenerateRDB generates rdb file from aof file
 func (persister *Persister) GenerateRDB(rdbFilename string) error {
-	ctx, err := persister.startGenerateRDB(nil, nil)
+	ctx, err := persister.startGenerateRDB(rdbFilename, nil, nil)
 	if err != nil {
 		return err
 	}
 	err = persister.generateRDB(ctx)
 	if err != nil {
+		_ = ctx.tmpFile.Close()
+		_ = os.Remove(ctx.tmpFile.Name())
 		return err
 	}
-	err = ctx.tmpFile.Close()
-	if err != nil {
-		return err
-	}
-	err = os.Rename(ctx.tmpFile.Name(), rdbFilename)
-	if err != nil {
-		return err
-	}
-	return nil
+	return fileutil.CommitTemp(ctx.tmpFile, rdbFilename)
 }
 
 // GenerateRDBForReplication asynchronously generates rdb file from aof file and returns a channel to receive following data
 // parameter listener would receive following updates of rdb
 // parameter hook allows you to do something during aof pausing
 func (persister *Persister) GenerateRDBForReplication(rdbFilename string, listener Listener, hook func()) error {
-	ctx, err := persister.startGenerateRDB(listener, hook)
+	ctx, err := persister.startGenerateRDB(rdbFilename, listener, hook)
 	if err != nil {
 		return err
 	}
 
 	err = persister.generateRDB(ctx)
 	if err != nil {
+		_ = ctx.tmpFile.Close()
+		_ = os.Remove(ctx.tmpFile.Name())
 		return err
 	}
-	err = ctx.tmpFile.Close()
-	if err != nil {
-		return err
-	}
-	err = os.Rename(ctx.tmpFile.Name(), rdbFilename)
-	if err != nil {
-		return err
-	}
-	return nil
+	return fileutil.CommitTemp(ctx.tmpFile, rdbFilename)
 }
 
-func (persister *Persister) startGenerateRDB(newListener Listener, hook func()) (*RewriteCtx, error) {
+func (persister *Persister) startGenerateRDB(rdbFilename string, newListener Listener, hook func()) (*RewriteCtx, error) {
 	persister.pausingAof.Lock() // pausing aof
 	defer persister.pausingAof.Unlock()
 
@@ -76,8 +71,14 @@ func (persister *Persister) startGenerateRDB(newListener Listener, hook func())
 	// get current aof file size
 	fileInfo, _ := os.Stat(persister.aofFilename)
 	filesize := fileInfo.Size()
-	// create tmp file
-	file, err := os.CreateTemp(config.GetTmpDir(), "*.aof")
+	// rdb is more compact than aof, use aof size as estimation
+	err = fileutil.CheckFreeSpace(filepath.Dir(rdbFilename), uint64(filesize))
+	if err != nil {
+		logger.Warn(err)
+		return nil, err
+	}
+	// create tmp file in the same dir, so it can be renamed to rdb file atomically
+	file, err := fileutil.CreateTemp(rdbFilename, "*.rdb")
 	if err != nil {
 		logger.Warn("tmp file create failed")
 		return nil, err
@@ -141,8 +142,15 @@ func (persister *Persister) generateRDB(ctx *RewriteCtx) error {
 				opts = append(opts, rdb.WithTTL(uint64(expiration.UnixNano()/1e6)))
 			}
 			switch obj := entity.Data.(type) {
-			case []byte:
-				err = encoder.WriteStringObject(key, obj, opts...)
+			case []byte, int64, *database.EmbStr:
+				bytes, _ := database.StringBytes(obj)
+				if entity.Compressed {
+					bytes, err = compress.Decompress(bytes)
+					if err != nil {
+						break
+					}
+				}
+				err = encoder.WriteStringObject(key, bytes, opts...)
 			case List.List:
 				vals := make([][]byte, 0, obj.Len())
 				obj.ForEach(func(i int, v interface{}) bool {
@@ -158,11 +166,10 @@ func (persister *Persister) generateRDB(ctx *RewriteCtx) error {
 					return true
 				})
 				err = encoder.WriteSetObject(key, vals, opts...)
-			case dict.Dict:
+			case *Hash.Hash:
 				hash := make(map[string][]byte)
-				obj.ForEach(func(key string, val interface{}) bool {
-					bytes, _ := val.([]byte)
-					hash[key] = bytes
+				obj.ForEach(func(field string, value []byte) bool {
+					hash[field] = value
 					return true
 				})
 				err = encoder.WriteHashMapObject(key, hash, opts...)
@@ -176,6 +183,9 @@ func (persister *Persister) generateRDB(ctx *RewriteCtx) error {
 					return true
 				})
 				err = encoder.WriteZSetObject(key, entries, opts...)
+			case *bloom.Filter, *cuckoo.Filter, *timeseries.Series, *JSON.Document:
+				// rdb 编码器不支持模块类型，布隆过滤器、布谷鸟过滤器、时间序列和 json 文档只能通过 AOF 持久化
+				logger.Warn("module type value " + key + " is not saved in rdb")
 			}
 			if err != nil {
 				err2 = err
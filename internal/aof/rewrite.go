@@ -0,0 +1,224 @@
+package aof
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"Godis/interface/database"
+	"Godis/internal/config"
+	"Godis/lib/fileutil"
+	"Godis/lib/logger"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+)
+
+func (persister *Persister) newRewriteHandler() *Persister {
+	h := &Persister{}
+	h.aofFilename = persister.aofFilename
+	h.db = persister.tmpDBMaker()
+	return h
+}
+
+// ErrRewriteInProgress is returned when starting a rewrite while another one is running
+var ErrRewriteInProgress = errors.New("ERR Background append only file rewriting already in progress")
+
+// RewriteCtx holds context of an AOF rewriting procedure
+type RewriteCtx struct {
+	tmpFile  *os.File // tmpFile is the file handler of aof tmpFile
+	fileSize int64
+	dbIdx    int // selected db index when startRewrite
+}
+
+// Rewrite carries out AOF rewrite
+func (persister *Persister) Rewrite() error {
+	ctx, err := persister.StartRewrite()
+	if err == ErrRewriteInProgress {
+		return err
+	}
+	if err == nil {
+		err = persister.DoRewrite(ctx)
+		if err != nil {
+			persister.abortRewrite(ctx)
+		}
+	}
+	if err == nil {
+		err = persister.FinishRewrite(ctx)
+	}
+	persister.health.setRewriteResult(err)
+	return err
+}
+
+// DoRewrite actually rewrite aof file
+// makes DoRewrite public for testing only, please use Rewrite instead
+func (persister *Persister) DoRewrite(ctx *RewriteCtx) (err error) {
+	// start rewrite
+	if !config.Properties.AofUseRdbPreamble {
+		logger.Info("generate aof preamble")
+		err = persister.generateAof(ctx)
+	} else {
+		logger.Info("generate rdb preamble")
+		err = persister.generateRDB(ctx)
+	}
+	return err
+}
+
+// StartRewrite prepares rewrite procedure
+func (persister *Persister) StartRewrite() (*RewriteCtx, error) {
+	// pausing aof
+	persister.pausingAof.Lock()
+	defer persister.pausingAof.Unlock()
+	if persister.rewriteBuffer != nil {
+		return nil, ErrRewriteInProgress
+	}
+
+	err := persister.aofFile.Sync()
+	if err != nil {
+		logger.Warn("fsync failed")
+		return nil, err
+	}
+
+	// get current aof file size
+	fileInfo, _ := os.Stat(persister.aofFilename)
+	filesize := fileInfo.Size()
+
+	// rewritten file is usually smaller than current one, use its size as estimation
+	err = fileutil.CheckFreeSpace(filepath.Dir(persister.aofFilename), uint64(filesize))
+	if err != nil {
+		logger.Warn(err)
+		return nil, err
+	}
+	// create tmp file in the same dir, so it can be renamed to aof file atomically
+	file, err := fileutil.CreateTemp(persister.aofFilename, "*.aof")
+	if err != nil {
+		logger.Warn("tmp file create failed")
+		return nil, err
+	}
+	// 此后写入的命令同时保存到 rewriteBuffer，它们不在临时数据库加载的 fileSize 字节中
+	persister.rewriteBuffer = &bytes.Buffer{}
+	return &RewriteCtx{
+		tmpFile:  file,
+		fileSize: filesize,
+		dbIdx:    persister.currentDB,
+	}, nil
+}
+
+// FinishRewrite finish rewrite procedure
+func (persister *Persister) FinishRewrite(ctx *RewriteCtx) error {
+	persister.pausingAof.Lock() // pausing aof
+	defer persister.pausingAof.Unlock()
+	tmpFile := ctx.tmpFile
+	buffer := persister.rewriteBuffer
+	persister.rewriteBuffer = nil
+
+	// append commands executed during rewriting to tmpFile
+	// sync tmpFile's db index with online aofFile
+	data := protocol.MakeMultiBulkReply(utils.ToCmdLine("SELECT", strconv.Itoa(ctx.dbIdx))).ToBytes()
+	_, err := tmpFile.Write(data)
+	if err == nil {
+		_, err = buffer.WriteTo(tmpFile)
+	}
+	if err != nil {
+		logger.Error("tmp file rewrite failed: " + err.Error())
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
+		return err
+	}
+
+	// replace current aof file by tmp file
+	_ = persister.aofFile.Close()
+	commitErr := fileutil.CommitTemp(tmpFile, persister.aofFilename)
+	if commitErr != nil {
+		logger.Warn(commitErr)
+	}
+	// reopen aof file for further write
+	aofFile, err := os.OpenFile(persister.aofFilename, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		panic(err)
+	}
+	persister.aofFile = aofFile
+	if commitErr == nil {
+		// 写入失败的数据已经通过 rewriteBuffer 保存到新文件中，不需要再重试
+		persister.pending = nil
+		persister.health.setWriteResult(nil)
+	}
+
+	// write select command again to resume aof file selected db
+	// it should have the same db index with  persister.currentDB
+	data = protocol.MakeMultiBulkReply(utils.ToCmdLine("SELECT", strconv.Itoa(persister.currentDB))).ToBytes()
+	if err := persister.flush(data); err != nil {
+		logger.Warn(err)
+	}
+	return commitErr
+}
+
+// abortRewrite removes the tmp file and stops buffering commands
+func (persister *Persister) abortRewrite(ctx *RewriteCtx) {
+	persister.pausingAof.Lock()
+	defer persister.pausingAof.Unlock()
+	persister.rewriteBuffer = nil
+	_ = ctx.tmpFile.Close()
+	_ = os.Remove(ctx.tmpFile.Name())
+}
+
+type restartMarker struct {
+	tmpFile *os.File
+	done    chan error
+}
+
+// Restart truncates the aof file and restarts it with all data in db, commands saved before Restart are discarded
+// 用于从节点全量同步：需要在新数据生效之前调用，此前保存的命令属于旧数据，写入旧文件后随旧文件一起丢弃，
+// 此后保存的命令追加在快照之后
+func (persister *Persister) Restart(db database.DBEngine) error {
+	tmpFile, err := fileutil.CreateTemp(persister.aofFilename, "*.aof")
+	if err != nil {
+		return err
+	}
+	err = writeSnapshot(tmpFile, db)
+	if err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
+		return err
+	}
+	if persister.aofFsync == FsyncAlways {
+		// always 模式下命令直接写入文件，不经过 aofChan
+		return persister.swapFile(tmpFile)
+	}
+	// 通过 aofChan 发送重启标记，保证此前进入队列的命令写入旧文件
+	marker := &restartMarker{
+		tmpFile: tmpFile,
+		done:    make(chan error, 1),
+	}
+	persister.aofChan <- &payload{restart: marker}
+	return <-marker.done
+}
+
+// swapFile replaces aof file by tmpFile
+func (persister *Persister) swapFile(tmpFile *os.File) error {
+	persister.pausingAof.Lock()
+	defer persister.pausingAof.Unlock()
+	if persister.rewriteBuffer != nil {
+		// 进行中的重写完成后会用旧数据覆盖新文件
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
+		return ErrRewriteInProgress
+	}
+	_ = persister.aofFile.Close()
+	commitErr := fileutil.CommitTemp(tmpFile, persister.aofFilename)
+	aofFile, err := os.OpenFile(persister.aofFilename, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		panic(err)
+	}
+	persister.aofFile = aofFile
+	if commitErr != nil {
+		return commitErr
+	}
+	// 旧文件中未写入的数据已经没有意义
+	persister.pending = nil
+	persister.health.setWriteResult(nil)
+	// 快照最后选择的是最后一个 db，与 currentDB 同步
+	data := protocol.MakeMultiBulkReply(utils.ToCmdLine("SELECT", strconv.Itoa(persister.currentDB))).ToBytes()
+	return persister.flush(data)
+}
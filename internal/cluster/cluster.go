@@ -8,33 +8,32 @@ import (
 
 	"github.com/hdt3213/rdb/core"
 
-	"Godis/config"
-	database2 "Godis/database"
 	"Godis/datastruct/dict"
 	"Godis/datastruct/set"
 	"Godis/interface/database"
 	"Godis/interface/redis"
+	"Godis/internal/config"
+	database2 "Godis/internal/database"
 	"Godis/lib/idgenerator"
 	"Godis/lib/logger"
 	"Godis/redis/parser"
 	"Godis/redis/protocol"
 	"os"
-	"path"
 	"sync"
 )
 
 // Cluster represents a node of godis cluster
 // it holds part of data and coordinates other nodes to finish transactions
 type Cluster struct {
-	self          string
-	addr          string
-	db            database.DBEngine
-	transactions  *dict.SimpleDict // id -> Transaction
-	transactionMu sync.RWMutex
-	topology      topology
-	slotMu        sync.RWMutex
-	slots         map[uint32]*hostSlot
-	idGenerator   *idgenerator.IDGenerator
+	self         string
+	addr         string
+	db           database.DBEngine
+	transactions *dict.LockedDict // id -> Transaction
+	peerAddrs    *dict.LockedDict // node id -> addr
+	topology     topology
+	slotMu       sync.RWMutex
+	slots        map[uint32]*hostSlot
+	idGenerator  *idgenerator.IDGenerator
 
 	clientFactory clientFactory
 }
@@ -55,6 +54,11 @@ type clientFactory interface {
 	Close() error
 }
 
+// peerStatsProvider is implemented by client factories which track statistics of peer connections
+type peerStatsProvider interface {
+	PeerStats(peerAddr string) (peerStats, bool)
+}
+
 const (
 	slotStateHost = iota
 	slotStateImporting
@@ -91,14 +95,16 @@ var allowFastTransaction = true
 // MakeCluster creates and starts a node of cluster
 func MakeCluster() *Cluster {
 	cluster := &Cluster{
-		self:          config.Properties.Self,                            // 当前节点的表示
-		addr:          config.Properties.AnnounceAddress(),               // 当前节点的地址
-		db:            database2.NewStandaloneServer(),                   // 创建一个Server
-		transactions:  dict.MakeSimple(),                                 // 创建一个简单的字典
+		self:          config.Properties.Self,              // 当前节点的表示
+		addr:          config.Properties.AnnounceAddress(), // 当前节点的地址
+		db:            database2.NewStandaloneServer(),     // 创建一个Server
+		transactions:  dict.MakeSyncSimple(),               // 创建一个并发安全的简单字典
+		peerAddrs:     dict.MakeSyncSimple(),
 		idGenerator:   idgenerator.MakeGenerator(config.Properties.Self), // 雪花算法实现ID生成
 		clientFactory: newDefaultClientFactory(),
 	}
-	topologyPersistFile := path.Join(config.Properties.Dir, config.Properties.ClusterConfigFile)
+	// ClusterConfigFile has been resolved to an absolute path within data dir, see config.ResolvePaths
+	topologyPersistFile := config.Properties.ClusterConfigFile
 	cluster.topology = newRaft(cluster, topologyPersistFile)
 	cluster.db.SetKeyInsertedCallback(cluster.makeInsertCallback())
 	cluster.db.SetKeyDeletedCallback(cluster.makeDeleteCallback())
@@ -114,6 +120,7 @@ func MakeCluster() *Cluster {
 	if err != nil {
 		panic(err)
 	}
+	cluster.syncReplicationID()
 	return cluster
 }
 
@@ -127,13 +134,6 @@ func (cluster *Cluster) Close() {
 	cluster.clientFactory.Close()
 }
 
-func isAuthenticated(c redis.Connection) bool {
-	if config.Properties.RequirePass == "" {
-		return true
-	}
-	return c.GetPassword() == config.Properties.RequirePass
-}
-
 // Exec executes command on cluster
 func (cluster *Cluster) Exec(c redis.Connection, cmdLine [][]byte) (result redis.Reply) {
 	defer func() {
@@ -151,7 +151,7 @@ func (cluster *Cluster) Exec(c redis.Connection, cmdLine [][]byte) (result redis
 	if cmdName == "auth" {
 		return database2.Auth(c, cmdLine[1:])
 	}
-	if !isAuthenticated(c) {
+	if !database2.IsAuthenticated(c) {
 		return protocol.MakeErrReply("NOAUTH Authentication required")
 	}
 
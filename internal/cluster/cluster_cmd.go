@@ -0,0 +1,271 @@
+package cluster
+
+import (
+	"Godis/interface/redis"
+	"Godis/redis/protocol"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerCmd("Cluster", execCluster)
+	registerCmd("Debug", execDebug)
+}
+
+// execCluster handles cluster subcommands
+// command line: cluster nodes [stats] | cluster myid | cluster slots | cluster replicate <node-id> | cluster keyslot <key>
+func execCluster(cluster *Cluster, c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) < 2 {
+		return protocol.MakeArgNumErrReply("cluster")
+	}
+	subCmd := strings.ToLower(string(args[1]))
+	switch subCmd {
+	case "nodes":
+		if len(args) > 3 {
+			return protocol.MakeArgNumErrReply("cluster|nodes")
+		}
+		withStats := false
+		if len(args) == 3 {
+			if strings.ToLower(string(args[2])) != "stats" {
+				return protocol.MakeSyntaxErrReply()
+			}
+			withStats = true
+		}
+		return protocol.MakeBulkReply([]byte(cluster.describeNodes(withStats)))
+	case "myid":
+		if len(args) != 2 {
+			return protocol.MakeArgNumErrReply("cluster|myid")
+		}
+		return protocol.MakeBulkReply([]byte(cluster.self))
+	case "slots":
+		if len(args) != 2 {
+			return protocol.MakeArgNumErrReply("cluster|slots")
+		}
+		return cluster.describeSlots()
+	case "replicate":
+		if len(args) != 3 {
+			return protocol.MakeArgNumErrReply("cluster|replicate")
+		}
+		return cluster.execReplicate(string(args[2]))
+	case "keyslot":
+		if len(args) != 3 {
+			return protocol.MakeArgNumErrReply("cluster|keyslot")
+		}
+		return protocol.MakeIntReply(int64(getSlot(string(args[2]))))
+	}
+	return protocol.MakeErrReply("ERR unknown cluster sub command '" + subCmd + "'")
+}
+
+// execDebug handles DEBUG SLOTOWNER, other subcommands are executed by local db
+// command line: debug slotowner <key>
+func execDebug(cluster *Cluster, c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) < 2 {
+		return cluster.db.Exec(c, args)
+	}
+	switch strings.ToLower(string(args[1])) {
+	case "slotowner":
+		if len(args) != 3 {
+			return protocol.MakeArgNumErrReply("debug|slotowner")
+		}
+		return cluster.describeSlotOwner(string(args[2]))
+	case "help":
+		reply := cluster.db.Exec(c, args)
+		if help, ok := reply.(*protocol.MultiBulkReply); ok {
+			help.Args = append(help.Args,
+				[]byte("SLOTOWNER <key>"),
+				[]byte("    Show slot of key honoring hash tags, the node serving it and the local state of the slot."),
+			)
+		}
+		return reply
+	}
+	return cluster.db.Exec(c, args)
+}
+
+// describeSlotOwner 生成 DEBUG SLOTOWNER 的输出: [slot, node-id, node-addr, state]
+// state 为当前节点上槽位的状态: host、importing、migrating，槽位不在当前节点上时为 none
+// 用于排查 CROSSSLOT 错误和检查 key 的设计，如 hash tag 是否生效
+func (cluster *Cluster) describeSlotOwner(key string) redis.Reply {
+	slotID := getSlot(key)
+	node := cluster.pickNode(slotID)
+	if node == nil {
+		return protocol.MakeErrReply("ERR slot " + strconv.Itoa(int(slotID)) + " is not served by any node")
+	}
+	state := "none"
+	if hSlot := cluster.getHostSlot(slotID); hSlot != nil {
+		switch hSlot.state {
+		case slotStateHost:
+			state = "host"
+		case slotStateImporting:
+			state = "importing"
+		case slotStateMovingOut:
+			state = "migrating"
+		}
+	}
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeIntReply(int64(slotID)),
+		protocol.MakeBulkReply([]byte(node.ID)),
+		protocol.MakeBulkReply([]byte(node.Addr)),
+		protocol.MakeBulkReply([]byte(state)),
+	})
+}
+
+// describeNodes 生成 CLUSTER NODES 的输出，每个节点一行，格式与 redis cluster 相同:
+// <id> <ip:port@cport> <flags> <master> <ping-sent> <pong-recv> <config-epoch> <link-state> <slot> <slot> ...
+// godis 没有单独的集群总线端口，cport 与 port 相同
+// withStats 为 true 时(CLUSTER NODES STATS)在 link-state 之后、槽位之前插入与该节点之间连接的统计:
+// sent=<n> received=<n> reconnects=<n> timeouts=<n> connections=<healthy>/<total>
+// 插入的字段会破坏 redis cluster 格式，因此默认不输出
+func (cluster *Cluster) describeNodes(withStats bool) string {
+	provider, _ := cluster.clientFactory.(peerStatsProvider)
+	nodes := cluster.topology.GetNodes()
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+	var sb strings.Builder
+	for _, node := range nodes {
+		addr := node.Addr
+		if i := strings.LastIndex(addr, ":"); i >= 0 {
+			addr += "@" + addr[i+1:]
+		}
+		// marshalSlotIds 会原地排序，使用拷贝避免修改拓扑中的数据
+		slots := make([]*Slot, len(node.Slots))
+		copy(slots, node.Slots)
+		var stats peerStats
+		hasStats := false
+		if provider != nil && node.ID != cluster.self {
+			stats, hasStats = provider.PeerStats(node.Addr)
+		}
+		sb.WriteString(strings.Join([]string{
+			node.ID, addr, describeNodeFlags(node, cluster.self), describeMaster(node), "0", "0", "0", describeLinkState(node, stats, hasStats),
+		}, " "))
+		if withStats {
+			sb.WriteString(" " + describePeerStats(stats))
+		}
+		for _, scope := range marshalSlotIds(slots) {
+			sb.WriteString(" " + scope)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// describeNodeFlags returns flags of node in CLUSTER NODES format
+func describeNodeFlags(node *Node, self string) string {
+	flags := "master"
+	if node.MasterID != "" {
+		flags = "slave"
+	}
+	if node.ID == self {
+		flags = "myself," + flags
+	}
+	if node.Flags&nodeFlagFail > 0 {
+		flags += ",fail"
+	} else if node.Flags&nodeFlagPFail > 0 {
+		flags += ",fail?"
+	}
+	return flags
+}
+
+// describeLinkState returns link state of node in CLUSTER NODES format
+// 节点被标记为故障，或者与其之间的连接都不健康(已关闭或连续失败)时为 disconnected
+func describeLinkState(node *Node, stats peerStats, hasStats bool) string {
+	if node.Flags&(nodeFlagFail|nodeFlagPFail) > 0 {
+		return "disconnected"
+	}
+	if hasStats && stats.Connections > 0 && stats.Healthy == 0 {
+		return "disconnected"
+	}
+	return "connected"
+}
+
+// describePeerStats returns counters of connections to a peer in CLUSTER NODES STATS
+func describePeerStats(stats peerStats) string {
+	return "sent=" + strconv.FormatInt(stats.Sent, 10) +
+		" received=" + strconv.FormatInt(stats.Received, 10) +
+		" reconnects=" + strconv.FormatInt(stats.Reconnects, 10) +
+		" timeouts=" + strconv.FormatInt(stats.Timeouts, 10) +
+		" connections=" + strconv.Itoa(stats.Healthy) + "/" + strconv.Itoa(stats.Connections)
+}
+
+// describeMaster returns master column of node in CLUSTER NODES format
+func describeMaster(node *Node) string {
+	if node.MasterID == "" {
+		return "-"
+	}
+	return node.MasterID
+}
+
+// execReplicate makes current node a replica of the given node
+func (cluster *Cluster) execReplicate(masterID string) redis.Reply {
+	if err := cluster.topology.SetMaster(cluster.self, masterID); err != nil {
+		return err
+	}
+	return protocol.MakeOkReply()
+}
+
+// describeSlots 生成 CLUSTER SLOTS 的输出，每个连续的槽位区间一项:
+// [start, end, [master-host, master-port, master-id], [replica-host, replica-port, replica-id] ...]
+// 客户端可以据此将读请求发往副本
+func (cluster *Cluster) describeSlots() redis.Reply {
+	nodes := cluster.topology.GetNodes()
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+	replicas := make(map[string][]*Node)
+	for _, node := range nodes {
+		if node.MasterID != "" && node.Flags&nodeFlagFail == 0 {
+			replicas[node.MasterID] = append(replicas[node.MasterID], node)
+		}
+	}
+	var result []redis.Reply
+	for _, node := range nodes {
+		if len(node.Slots) == 0 {
+			continue
+		}
+		slots := make([]*Slot, len(node.Slots))
+		copy(slots, node.Slots)
+		for _, scope := range slotScopes(slots) {
+			item := []redis.Reply{
+				protocol.MakeIntReply(int64(scope[0])),
+				protocol.MakeIntReply(int64(scope[1])),
+				describeSlotNode(node),
+			}
+			for _, replica := range replicas[node.ID] {
+				item = append(item, describeSlotNode(replica))
+			}
+			result = append(result, protocol.MakeMultiRawReply(item))
+		}
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+func describeSlotNode(node *Node) redis.Reply {
+	host, portStr, err := net.SplitHostPort(node.Addr)
+	if err != nil {
+		host = node.Addr
+	}
+	port, _ := strconv.ParseInt(portStr, 10, 64)
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte(host)),
+		protocol.MakeIntReply(port),
+		protocol.MakeBulkReply([]byte(node.ID)),
+	})
+}
+
+// slotScopes 将槽位按编号排序后合并为连续区间，返回每个区间的首尾编号
+func slotScopes(slots []*Slot) [][2]uint32 {
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i].ID < slots[j].ID
+	})
+	var scopes [][2]uint32
+	for _, slot := range slots {
+		if n := len(scopes); n > 0 && scopes[n-1][1]+1 == slot.ID {
+			scopes[n-1][1] = slot.ID
+			continue
+		}
+		scopes = append(scopes, [2]uint32{slot.ID, slot.ID})
+	}
+	return scopes
+}
@@ -2,8 +2,8 @@ package cluster
 
 import (
 	"Godis/interface/redis"
+	"Godis/internal/redis/connection"
 	"Godis/lib/utils"
-	"Godis/redis/connection"
 	"Godis/redis/protocol"
 )
 
@@ -14,17 +14,37 @@ func (cluster *Cluster) relay(peerId string, c redis.Connection, cmdLine [][]byt
 		// to self db
 		return cluster.Exec(c, cmdLine)
 	}
-	// peerId is peer.Addr
-	cli, err := cluster.clientFactory.GetPeerClient(peerId)
+	peerAddr := cluster.getPeerAddr(peerId)
+	cli, err := cluster.clientFactory.GetPeerClient(peerAddr)
 	if err != nil {
 		return protocol.MakeErrReply(err.Error())
 	}
 	defer func() {
-		_ = cluster.clientFactory.ReturnPeerClient(peerId, cli)
+		_ = cluster.clientFactory.ReturnPeerClient(peerAddr, cli)
 	}()
 	return cli.Send(cmdLine)
 }
 
+// getPeerAddr returns address of the given node
+// 节点ID与地址无关，地址记录在 peerAddrs 中，由 raft 在节点加入、地址变化和加载快照时更新
+// relay 可能在持有 raft.mu 时调用，因此不能通过 topology.GetNode 查询
+// 未知的节点(如 fixedTopology 中以地址作为ID的节点)直接把ID当作地址
+func (cluster *Cluster) getPeerAddr(peerId string) string {
+	if cluster.peerAddrs != nil {
+		if addr, ok := cluster.peerAddrs.Get(peerId); ok {
+			return addr.(string)
+		}
+	}
+	return peerId
+}
+
+// setPeerAddr records address of the given node
+func (cluster *Cluster) setPeerAddr(peerId string, addr string) {
+	if cluster.peerAddrs != nil {
+		cluster.peerAddrs.Put(peerId, addr)
+	}
+}
+
 // relayByKey function relays command to peer
 // use routeKey to determine peer node
 func (cluster *Cluster) relayByKey(routeKey string, c redis.Connection, args [][]byte) redis.Reply {
@@ -60,17 +80,20 @@ func (cluster *Cluster) ensureKey(key string) protocol.ErrorReply {
 	if protocol.IsErrorReply(resp) {
 		return resp.(protocol.ErrorReply)
 	}
-	if protocol.IsEmptyMultiBulkReply(resp) {
+	dumped, err := protocol.AsBulkArray(resp)
+	if err != nil {
+		return protocol.MakeErrReply("illegal dump key response")
+	}
+	if len(dumped) == 0 {
 		slot.importedKeys.Add(key)
 		return nil
 	}
-	dumpResp := resp.(*protocol.MultiBulkReply)
-	if len(dumpResp.Args) != 2 {
+	if len(dumped) != 2 {
 		return protocol.MakeErrReply("illegal dump key response")
 	}
 	// reuse copy to command ^_^
 	resp = cluster.db.Exec(connection.NewFakeConn(), [][]byte{
-		[]byte("CopyTo"), []byte(key), dumpResp.Args[0], dumpResp.Args[1],
+		[]byte("CopyTo"), []byte(key), dumped[0], dumped[1],
 	})
 	if protocol.IsErrorReply(resp) {
 		return resp.(protocol.ErrorReply)
@@ -1,9 +1,9 @@
 package cluster
 
 import (
-	"Godis/config"
 	"Godis/datastruct/dict"
 	"Godis/interface/redis"
+	"Godis/internal/config"
 	"Godis/lib/logger"
 	"Godis/lib/pool"
 	"Godis/lib/utils"
@@ -13,13 +13,34 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sync"
+	"time"
 )
 
 // defaultClientFactory 结构体实现了 peerClientFactory 接口，用于管理连接池和创建客户端连接
 type defaultClientFactory struct {
 	nodeConnections dict.Dict // map[string]*pool.Pool
+	peerConnections dict.Dict // map[string]*peerConnections
 }
 
+// peerConnections 记录与一个节点之间的所有连接，用于在 CLUSTER NODES 中展示连接的统计和健康状态
+type peerConnections struct {
+	mu      sync.Mutex
+	clients map[*client.Client]struct{}
+	// closed 累计已销毁的连接的统计
+	closed client.Stats
+}
+
+// peerStats is the sum of counters of all connections to a peer
+type peerStats struct {
+	client.Stats
+	Connections int // connections created and not destroyed, including those in use
+	Healthy     int
+}
+
+// maxPeerClientRetry 获取健康连接的最大尝试次数
+const maxPeerClientRetry = 3
+
 // connectionPoolConfig 是连接池的配置参数
 var connectionPoolConfig = pool.Config{
 	MaxIdle:   1,  // 最大空闲连接数
@@ -37,11 +58,17 @@ func (factory *defaultClientFactory) GetPeerClient(peerAddr string) (peerClient,
 			if err != nil {
 				return nil, err
 			}
+			factory.getPeerConnections(peerAddr).add(c)
+			if config.Properties.PeerKeepalive > 0 {
+				c.SetKeepalive(time.Duration(config.Properties.PeerKeepalive) * time.Second)
+			}
 			c.Start()
 			// 集群中所有节点使用相同的密码进行认证
 			if config.Properties.RequirePass != "" {
 				authResp := c.Send(utils.ToCmdLine("AUTH", config.Properties.RequirePass))
 				if !protocol.IsOKReply(authResp) {
+					c.Close()
+					factory.getPeerConnections(peerAddr).remove(c)
 					return nil, fmt.Errorf("auth failed, resp: %s", string(authResp.ToBytes()))
 				}
 			}
@@ -49,26 +76,35 @@ func (factory *defaultClientFactory) GetPeerClient(peerAddr string) (peerClient,
 		}
 		finalizer := func(x interface{}) {
 			logger.Debug("destroy client")
-			cli, ok := x.(client.Client)
+			cli, ok := x.(*client.Client)
 			if !ok {
 				return
 			}
 			cli.Close()
+			factory.getPeerConnections(peerAddr).remove(cli)
 		}
 		connectionPool = pool.New(creator, finalizer, connectionPoolConfig)
 		factory.nodeConnections.Put(peerAddr, connectionPool)
 	} else {
 		connectionPool = raw.(*pool.Pool)
 	}
-	raw, err := connectionPool.Get()
-	if err != nil {
-		return nil, err
-	}
-	conn, ok := raw.(*client.Client)
-	if !ok {
-		return nil, errors.New("connection pool make wrong type")
+	// prefer healthy connections, drop the broken ones
+	for i := 0; i < maxPeerClientRetry; i++ {
+		raw, err := connectionPool.Get()
+		if err != nil {
+			return nil, err
+		}
+		conn, ok := raw.(*client.Client)
+		if !ok {
+			return nil, errors.New("connection pool make wrong type")
+		}
+		if conn.Healthy() {
+			return conn, nil
+		}
+		logger.Info("drop unhealthy connection to " + peerAddr)
+		connectionPool.Discard(conn)
 	}
-	return conn, nil
+	return nil, errors.New("no healthy connection to " + peerAddr)
 }
 
 // ReturnPeerClient 方法将客户端连接放回连接池
@@ -136,7 +172,63 @@ func (factory *defaultClientFactory) NewStream(peerAddr string, cmdLine CmdLine)
 func newDefaultClientFactory() *defaultClientFactory {
 	return &defaultClientFactory{
 		nodeConnections: dict.MakeConcurrent(1), // newDefaultClientFactory 函数创建一个新的默认客户端工厂对象
+		peerConnections: dict.MakeConcurrent(1),
+	}
+}
+
+func (factory *defaultClientFactory) getPeerConnections(peerAddr string) *peerConnections {
+	factory.peerConnections.PutIfAbsent(peerAddr, &peerConnections{
+		clients: make(map[*client.Client]struct{}),
+	})
+	raw, _ := factory.peerConnections.Get(peerAddr)
+	return raw.(*peerConnections)
+}
+
+func (conns *peerConnections) add(c *client.Client) {
+	conns.mu.Lock()
+	defer conns.mu.Unlock()
+	conns.clients[c] = struct{}{}
+}
+
+// remove 将销毁的连接的统计累加到 closed 中
+func (conns *peerConnections) remove(c *client.Client) {
+	conns.mu.Lock()
+	defer conns.mu.Unlock()
+	if _, ok := conns.clients[c]; !ok {
+		return
+	}
+	delete(conns.clients, c)
+	addStats(&conns.closed, c.GetStats())
+}
+
+func addStats(sum *client.Stats, stats client.Stats) {
+	sum.Sent += stats.Sent
+	sum.Received += stats.Received
+	sum.Reconnects += stats.Reconnects
+	sum.Timeouts += stats.Timeouts
+	sum.Dropped += stats.Dropped
+}
+
+// PeerStats returns counters of all connections ever created to the peer, returns false if never connected to it
+func (factory *defaultClientFactory) PeerStats(peerAddr string) (peerStats, bool) {
+	raw, ok := factory.peerConnections.Get(peerAddr)
+	if !ok {
+		return peerStats{}, false
+	}
+	conns := raw.(*peerConnections)
+	conns.mu.Lock()
+	defer conns.mu.Unlock()
+	result := peerStats{
+		Stats:       conns.closed,
+		Connections: len(conns.clients),
+	}
+	for c := range conns.clients {
+		addStats(&result.Stats, c.GetStats())
+		if c.Healthy() {
+			result.Healthy++
+		}
 	}
+	return result, true
 }
 
 func (factory *defaultClientFactory) Close() error {
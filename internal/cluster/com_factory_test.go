@@ -0,0 +1,153 @@
+package cluster
+
+import (
+	"Godis/internal/config"
+	"Godis/lib/utils"
+	"Godis/redis/client"
+	"Godis/redis/parser"
+	"net"
+	"strings"
+	"testing"
+)
+
+// startPeer starts a fake peer answering every command with OK, returns its address
+func startPeer(t *testing.T) string {
+	return startPeerWithReply(t, "+OK\r\n")
+}
+
+// startPeerWithReply starts a fake peer answering every command with reply
+func startPeerWithReply(t *testing.T, reply string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() {
+					_ = conn.Close()
+				}()
+				for payload := range parser.ParseStream(conn) {
+					if payload.Err != nil {
+						return
+					}
+					_, _ = conn.Write([]byte(reply))
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestDiscardUnhealthyPeerClient(t *testing.T) {
+	addr := startPeer(t)
+	factory := newDefaultClientFactory()
+	defer func() {
+		_ = factory.Close()
+	}()
+
+	c1, err := factory.GetPeerClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply := c1.Send(utils.ToCmdLine("PING")); string(reply.ToBytes()) != "+OK\r\n" {
+		t.Fatalf("unexpected reply %q", reply.ToBytes())
+	}
+	// 连接关闭后仍被放回连接池
+	c1.(*client.Client).Close()
+	_ = factory.ReturnPeerClient(addr, c1)
+	if stats, _ := factory.PeerStats(addr); stats.Connections != 1 || stats.Healthy != 0 {
+		t.Errorf("expect 1 unhealthy connection, actual %+v", stats)
+	}
+
+	c2, err := factory.GetPeerClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c2 == c1 || !c2.(*client.Client).Healthy() {
+		t.Error("unhealthy connection should be discarded")
+	}
+	_ = factory.ReturnPeerClient(addr, c2)
+	// 已销毁的连接的统计仍然计入
+	stats, ok := factory.PeerStats(addr)
+	if !ok || stats.Connections != 1 || stats.Healthy != 1 || stats.Sent != 1 || stats.Received != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if _, ok := factory.PeerStats("127.0.0.1:1"); ok {
+		t.Error("expect no stats of unknown peer")
+	}
+}
+
+func TestClusterNodesStats(t *testing.T) {
+	addr := startPeer(t)
+	raft, _ := makeTestLeader("self", "peer")
+	raft.nodes["peer"].Addr = addr
+	cluster := raft.cluster
+	factory := newDefaultClientFactory()
+	defer func() {
+		_ = factory.Close()
+	}()
+	cluster.clientFactory = factory
+	nodeLine := func(args ...string) string {
+		reply := execCluster(cluster, nil, utils.ToCmdLine(append([]string{"cluster", "nodes"}, args...)...))
+		for _, line := range strings.Split(string(reply.ToBytes()), "\n") {
+			if strings.HasPrefix(line, "peer ") {
+				return line
+			}
+		}
+		return string(reply.ToBytes())
+	}
+
+	peerClient, err := factory.GetPeerClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerClient.Send(utils.ToCmdLine("PING"))
+	_ = factory.ReturnPeerClient(addr, peerClient)
+	if line := nodeLine(); !strings.HasSuffix(line, " connected") {
+		t.Errorf("expect standard CLUSTER NODES line, actual %q", line)
+	}
+	expected := " connected sent=1 received=1 reconnects=0 timeouts=0 connections=1/1"
+	if line := nodeLine("stats"); !strings.HasSuffix(line, expected) {
+		t.Errorf("expect line ends with %q, actual %q", expected, line)
+	}
+
+	// 连接都不健康时 link-state 为 disconnected
+	peerClient, _ = factory.GetPeerClient(addr)
+	peerClient.(*client.Client).Close()
+	_ = factory.ReturnPeerClient(addr, peerClient)
+	expected = " disconnected sent=1 received=1 reconnects=0 timeouts=0 connections=0/1"
+	if line := nodeLine("stats"); !strings.HasSuffix(line, expected) {
+		t.Errorf("expect line ends with %q, actual %q", expected, line)
+	}
+
+	if reply := nodeLine("foo"); reply != "-Err syntax error\r\n" {
+		t.Errorf("expect syntax error, actual %q", reply)
+	}
+}
+
+func TestPeerClientAuthFailed(t *testing.T) {
+	addr := startPeerWithReply(t, "-ERR invalid password\r\n")
+	config.Properties.RequirePass = "secret"
+	defer func() {
+		config.Properties.RequirePass = ""
+	}()
+	factory := newDefaultClientFactory()
+	defer func() {
+		_ = factory.Close()
+	}()
+	if _, err := factory.GetPeerClient(addr); err == nil {
+		t.Fatal("expect auth error")
+	}
+	// 认证失败的连接被关闭，不再计入连接数
+	if stats, ok := factory.PeerStats(addr); !ok || stats.Connections != 0 || stats.Sent != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
@@ -53,6 +53,10 @@ func (fixed *fixedTopology) SetSlot(slotIDs []uint32, newNodeID string) protocol
 	return protocol.MakeErrReply("fixed topology does not support set slots")
 }
 
+func (fixed *fixedTopology) SetMaster(nodeID string, masterID string) protocol.ErrorReply {
+	return protocol.MakeErrReply("fixed topology does not support replicas")
+}
+
 func (fixed *fixedTopology) Close() error {
 	return nil
 }
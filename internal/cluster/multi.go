@@ -1,10 +1,10 @@
 package cluster
 
 import (
-	"Godis/database"
 	"Godis/interface/redis"
+	"Godis/internal/database"
+	"Godis/internal/redis/connection"
 	"Godis/lib/utils"
-	"Godis/redis/connection"
 	"Godis/redis/protocol"
 	"strconv"
 )
@@ -155,11 +155,11 @@ func execWatch(cluster *Cluster, conn redis.Connection, args [][]byte) redis.Rep
 		if protocol.IsErrorReply(result) {
 			return result
 		}
-		intResult, ok := result.(*protocol.IntReply)
-		if !ok {
+		ver, verErr := protocol.AsInt(result)
+		if verErr != nil {
 			return protocol.MakeErrReply("get version failed")
 		}
-		watching[key] = uint32(intResult.Code)
+		watching[key] = uint32(ver)
 	}
 	return protocol.MakeOkReply()
 }
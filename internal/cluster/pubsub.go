@@ -15,11 +15,12 @@ func Publish(cluster *Cluster, c redis.Connection, cmdLine [][]byte) redis.Reply
 	var count int64 = 0
 	results := cluster.broadcast(c, modifyCmd(cmdLine, relayPublish))
 	for _, val := range results {
-		if errReply, ok := val.(protocol.ErrorReply); ok {
-			logger.Error("publish occurs error: " + errReply.Error())
-		} else if intReply, ok := val.(*protocol.IntReply); ok {
-			count += intReply.Code
+		n, err := protocol.AsInt(val)
+		if err != nil {
+			logger.Error("publish occurs error: " + err.Error())
+			continue
 		}
+		count += n
 	}
 	return protocol.MakeIntReply(count)
 }
@@ -1,12 +1,14 @@
 package cluster
 
 import (
-	"Godis/config"
 	"Godis/datastruct/lock"
 	"Godis/interface/redis"
+	"Godis/internal/redis/connection"
+	"Godis/lib/crc"
+	"Godis/lib/fileutil"
 	"Godis/lib/logger"
 	"Godis/lib/utils"
-	"Godis/redis/connection"
+	"Godis/lib/webhook"
 	"Godis/redis/protocol"
 	"bufio"
 	"bytes"
@@ -22,7 +24,7 @@ import (
 	"time"
 )
 
-const slotCount int = 16384
+const slotCount int = crc.SlotCount
 
 type raftState int
 
@@ -34,6 +36,10 @@ const (
 	nodeFlagLeader uint32 = 1 << iota
 	nodeFlagCandidate
 	nodeFlagLearner
+	// nodeFlagPFail the leader has not heard from the node within node timeout, only known by leader
+	nodeFlagPFail
+	// nodeFlagFail majority of nodes agreed that the node is unreachable, replicated by raft log
+	nodeFlagFail
 )
 
 const (
@@ -83,9 +89,10 @@ type logEntry struct {
 	Event int
 	wg    *sync.WaitGroup
 	// payload
-	SlotIDs []uint32
-	NodeID  string
-	Addr    string
+	SlotIDs  []uint32
+	NodeID   string
+	Addr     string
+	MasterID string `json:",omitempty"`
 }
 
 func (e *logEntry) marshal() []byte {
@@ -120,12 +127,13 @@ type Raft struct {
 	heartbeatChan  chan *heartbeat
 	persistFile    string
 	electionAlarm  time.Time
-	closeChan      chan struct{}
-	closed         bool
+	closeChan      chan struct{} // closed by Close, use isClosed to check without holding mu
 
 	// for leader
 	nodeIndexMap map[string]*nodeStatus
 	nodeLock     *lock.Locks
+	// nodes whose failure or recovery is being confirmed, see raft_failure.go
+	failureChecks map[string]struct{}
 }
 
 func newRaft(cluster *Cluster, persistFilename string) *Raft {
@@ -220,7 +228,7 @@ func (cluster *Cluster) asRaft() *Raft {
 
 // StartAsSeed starts cluster as seed node
 func (raft *Raft) StartAsSeed(listenAddr string) protocol.ErrorReply {
-	selfNodeID := listenAddr
+	selfNodeID := genNodeID()
 	raft.mu.Lock()
 	defer raft.mu.Unlock()
 	raft.slots = make([]*Slot, slotCount)
@@ -247,6 +255,8 @@ func (raft *Raft) StartAsSeed(listenAddr string) protocol.ErrorReply {
 	}
 	raft.start(leader)
 	raft.cluster.self = selfNodeID
+	raft.cluster.setPeerAddr(selfNodeID, listenAddr)
+	raft.tryPersist()
 	return nil
 }
 
@@ -268,7 +278,7 @@ func (raft *Raft) start(state raftState) {
 	//raft.nodeIndexMap = make(map[string]*nodeStatus)
 	go func() {
 		for {
-			if raft.closed {
+			if raft.isClosed() {
 				logger.Info("quit raft job")
 				return
 			}
@@ -284,9 +294,20 @@ func (raft *Raft) start(state raftState) {
 	}()
 }
 
+// isClosed reports whether Close has been called
+func (raft *Raft) isClosed() bool {
+	select {
+	case <-raft.closeChan:
+		return true
+	default:
+		return false
+	}
+}
+
 func (raft *Raft) Close() error {
-	raft.closed = true
 	close(raft.closeChan)
+	raft.mu.Lock()
+	defer raft.mu.Unlock()
 	return raft.persist()
 }
 
@@ -342,6 +363,8 @@ func (raft *Raft) candidateJob() {
 	raft.term++
 	raft.votedFor = raft.selfNodeID
 	raft.voteCount++
+	// 发起投票前持久化任期和投票对象，重启后不会在同一任期内再次投票
+	raft.tryPersist()
 	currentTerm := raft.term
 	lastLogTerm, lastLogIndex := raft.getLogProgressWithinLock()
 	req := &voteReq{
@@ -372,13 +395,13 @@ func (raft *Raft) candidateJob() {
 				logger.Info(fmt.Sprintf("cannot get vote response from %s, %v", nodeID, err))
 				return
 			}
-			respBody, ok := rawResp.(*protocol.MultiBulkReply)
-			if !ok {
+			respBody, err := protocol.AsBulkArray(rawResp)
+			if err != nil {
 				logger.Info(fmt.Sprintf("cannot get vote response from %s, not a multi bulk reply", nodeID))
 				return
 			}
 			resp := &voteResp{}
-			err := resp.unmarshal(respBody.Args)
+			err = resp.unmarshal(respBody)
 			if err != nil {
 				logger.Info(fmt.Sprintf("cannot get vote response from %s, %v", nodeID, err))
 				return
@@ -399,6 +422,7 @@ func (raft *Raft) candidateJob() {
 				raft.state = follower
 				raft.votedFor = ""
 				raft.leaderId = resp.voteFor
+				raft.tryPersist()
 				return
 			}
 
@@ -434,6 +458,11 @@ func (raft *Raft) candidateJob() {
 		raft.votedFor = ""
 		raft.voteCount = 0
 		logger.Info("win election, take leader of  term " + strconv.Itoa(currentTerm))
+		webhook.Publish(&webhook.Event{
+			Type:   webhook.EventFailover,
+			Node:   raft.selfNodeID,
+			Detail: "elected as leader of term " + strconv.Itoa(currentTerm),
+		})
 	case <-raft.closeChan:
 		return
 	}
@@ -465,12 +494,29 @@ func (raft *Raft) askNodeIndex(node *Node) *nodeStatus {
 	logger.Debugf("ask %s for offset", node.ID)
 	c := connection.NewFakeConn()
 	reply := raft.cluster.relay(node.Addr, c, utils.ToCmdLine("raft", "get-offset"))
-	if protocol.IsErrorReply(reply) {
-		logger.Infof("ask node %s index failed: %v", node.ID, reply)
+	offset, err := protocol.AsInt(reply)
+	if err != nil {
+		logger.Infof("ask node %s index failed: %v", node.ID, err)
 		return nil
 	}
 	return &nodeStatus{
-		receivedIndex: int(reply.(*protocol.IntReply).Code),
+		receivedIndex: int(offset),
+	}
+}
+
+// commitLogEntries applies entries up to commitTo and wakes up their proposers, invoker should hold raft.mu
+func (raft *Raft) commitLogEntries(commitTo int) {
+	// new node (received index is 0) may cause commitTo less than raft.committedIndex
+	if commitTo <= raft.committedIndex {
+		return
+	}
+	toCommit := raft.getLogEntries(raft.committedIndex+1, commitTo+1) // left inclusive, right exclusive
+	raft.applyLogEntries(toCommit)
+	raft.committedIndex = commitTo
+	for _, entry := range toCommit {
+		if entry.wg != nil {
+			entry.wg.Done()
+		}
 	}
 }
 
@@ -495,17 +541,8 @@ func (raft *Raft) leaderJob() {
 	if len(recvedIndices) > 0 {
 		commitTo = recvedIndices[len(recvedIndices)/2]
 	}
-	// new node (received index is 0) may cause commitTo less than raft.committedIndex
-	if commitTo > raft.committedIndex {
-		toCommit := raft.getLogEntries(raft.committedIndex+1, commitTo+1) // left inclusive, right exclusive
-		raft.applyLogEntries(toCommit)
-		raft.committedIndex = commitTo
-		for _, entry := range toCommit {
-			if entry.wg != nil {
-				entry.wg.Done()
-			}
-		}
-	}
+	raft.commitLogEntries(commitTo)
+	raft.checkNodeFailures()
 	// save receivedIndex in local variable in case changed by other goroutines
 	proposalIndex := raft.proposedIndex
 	snapshot := raft.makeSnapshot() // the snapshot is consistent with the committed log
@@ -526,6 +563,7 @@ func (raft *Raft) leaderJob() {
 					// get status, node has back online
 					raft.mu.Lock()
 					raft.nodeIndexMap[node.ID] = status
+					raft.markHeard(node.ID)
 					raft.mu.Unlock()
 				} else {
 					// node still offline
@@ -574,6 +612,7 @@ func (raft *Raft) leaderJob() {
 				}
 				raft.mu.Lock()
 				raft.nodeIndexMap[node.ID].receivedIndex = recvedIndex
+				raft.markHeard(node.ID)
 				raft.mu.Unlock()
 			case protocol.ErrorReply:
 				if respPayload.Error() == prevLogMismatch {
@@ -589,6 +628,9 @@ func (raft *Raft) leaderJob() {
 						logger.Errorf("heartbeat to %s failed: %v", node.ID, err)
 						return
 					}
+					raft.mu.Lock()
+					raft.markHeard(node.ID)
+					raft.mu.Unlock()
 				} else if respPayload.Error() == nodeNotReady {
 					logger.Infof("%s is not ready yet", node.ID)
 					return
@@ -610,7 +652,7 @@ func init() {
 
 func execRaft(cluster *Cluster, c redis.Connection, args [][]byte) redis.Reply {
 	raft := cluster.asRaft()
-	if raft.closed {
+	if raft.isClosed() {
 		return protocol.MakeErrReply(raftClosed)
 	}
 	if len(args) < 2 {
@@ -634,9 +676,13 @@ func execRaft(cluster *Cluster, c redis.Connection, args [][]byte) redis.Reply {
 		// execRaftPropose handles event proposal as leader
 		// command line: raft propose <logEntry>
 		return execRaftPropose(cluster, c, args[2:])
+	case "probe":
+		// execRaftProbe checks whether the suspected node is reachable, asked by leader
+		// command line: raft probe <nodeID>
+		return execRaftProbe(cluster, c, args[2:])
 	case "join":
 		// execRaftJoin handles requests from a new node to join raft group as leader
-		// command line: raft join <address>
+		// command line: raft join <address> [nodeID]
 		return execRaftJoin(cluster, c, args[2:])
 	case "get-leader":
 		// execRaftGetLeader returns leader id and address
@@ -759,6 +805,8 @@ func execRaftRequestVote(cluster *Cluster, c redis.Connection, args [][]byte) re
 	raft.votedFor = req.nodeID
 	raft.term = req.term
 	raft.electionAlarm = nextElectionAlarm()
+	// 回复投票前持久化，避免重启后在同一任期内投给另一个节点
+	raft.tryPersist()
 	resp.voteFor = req.nodeID
 	resp.term = raft.term
 	return protocol.MakeMultiBulkReply(resp.marshal())
@@ -851,6 +899,7 @@ func execRaftHeartbeat(cluster *Cluster, c redis.Connection, args [][]byte) redi
 		raft.term = req.term
 		raft.votedFor = ""
 		raft.leaderId = req.leaderId
+		raft.tryPersist()
 		raft.mu.Unlock()
 	}
 	raft.mu.RLock()
@@ -936,7 +985,7 @@ func (raft *Raft) persist() error {
 	if raft.persistFile == "" {
 		return nil
 	}
-	tmpFile, err := os.CreateTemp(config.Properties.Dir, "tmp-cluster-conf-*.conf")
+	tmpFile, err := fileutil.CreateTemp(raft.persistFile, "tmp-cluster-conf-*.conf")
 	if err != nil {
 		return err
 	}
@@ -948,13 +997,19 @@ func (raft *Raft) persist() error {
 	}
 	_, err = tmpFile.Write(buf.Bytes())
 	if err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
 		return err
 	}
-	err = os.Rename(tmpFile.Name(), raft.persistFile)
-	if err != nil {
-		return err
+	return fileutil.CommitTemp(tmpFile, raft.persistFile)
+}
+
+// tryPersist persists cluster config and only logs the error
+// invoker should provide with raft.mu lock
+func (raft *Raft) tryPersist() {
+	if err := raft.persist(); err != nil {
+		logger.Errorf("persist raft error: %v", err)
 	}
-	return nil
 }
 
 // execRaftPropose handles requests from other nodes (follower or learner) to propose a change
@@ -983,18 +1038,29 @@ func execRaftPropose(cluster *Cluster, c redis.Connection, args [][]byte) redis.
 func (raft *Raft) propose(e *logEntry) protocol.ErrorReply {
 	switch e.Event {
 	case eventNewNode:
+		// 已存在的节点再次提交 eventNewNode 代表更新地址
 		raft.mu.Lock()
-		_, ok := raft.nodes[e.Addr]
+		node, ok := raft.nodes[e.NodeID]
 		raft.mu.Unlock()
-		if ok {
+		if ok && node.Addr == e.Addr {
 			return protocol.MakeErrReply("node exists")
 		}
+	case eventSetMaster:
+		raft.mu.RLock()
+		errReply := raft.checkSetMaster(e.NodeID, e.MasterID)
+		raft.mu.RUnlock()
+		if errReply != nil {
+			return errReply
+		}
+	case eventSetSlot, eventNodeFail, eventNodeRecover:
 	default:
 		panic("unhandled default case")
 	}
 	wg := wgPool.Get().(*sync.WaitGroup)
 	defer wgPool.Put(wg)
 	e.wg = wg
+	// 在加入日志之前 Add，否则 leader 可能在 Add 之前提交该日志导致计数为负
+	e.wg.Add(1)
 	raft.mu.Lock()
 	raft.proposedIndex++
 	raft.log = append(raft.log, e)
@@ -1002,7 +1068,6 @@ func (raft *Raft) propose(e *logEntry) protocol.ErrorReply {
 	e.Term = raft.term
 	e.Index = raft.proposedIndex
 	raft.mu.Unlock()
-	e.wg.Add(1)
 	e.wg.Wait() // wait for the raft group to reach a consensus
 	return nil
 }
@@ -1025,11 +1090,11 @@ func (raft *Raft) Join(seed string) protocol.ErrorReply {
 	if protocol.IsErrorReply(ret) {
 		return ret.(protocol.ErrorReply)
 	}
-	leaderInfo, ok := ret.(*protocol.MultiBulkReply)
-	if !ok || len(leaderInfo.Args) != 2 {
+	leaderInfo, err := protocol.AsBulkArray(ret)
+	if err != nil || len(leaderInfo) != 2 {
 		return protocol.MakeErrReply("ERR get-leader returns wrong reply")
 	}
-	leaderAddr := string(leaderInfo.Args[1])
+	leaderAddr := string(leaderInfo[1])
 
 	/* STEP2: join raft group */
 	leaderCli, err := cluster.clientFactory.GetPeerClient(leaderAddr)
@@ -1042,28 +1107,33 @@ func (raft *Raft) Join(seed string) protocol.ErrorReply {
 			logger.Error(err)
 		}
 	}(cluster.clientFactory, leaderAddr, leaderCli)
-	ret = leaderCli.Send(utils.ToCmdLine("raft", "join", cluster.addr))
+	// 首次启动时生成节点ID，加入后随集群配置一起持久化，之后地址变化也不影响节点身份
+	ret = leaderCli.Send(utils.ToCmdLine("raft", "join", cluster.addr, genNodeID()))
 	if protocol.IsErrorReply(ret) {
 		return ret.(protocol.ErrorReply)
 	}
-	snapshot, ok := ret.(*protocol.MultiBulkReply)
-	if !ok || len(snapshot.Args) < 4 {
+	snapshot, err := protocol.AsBulkArray(ret)
+	if err != nil || len(snapshot) < 4 {
 		return protocol.MakeErrReply("ERR gcluster join returns wrong reply")
 	}
 	raft.mu.Lock()
 	defer raft.mu.Unlock()
-	if errReply := raft.loadSnapshot(snapshot.Args); errReply != nil {
+	if errReply := raft.loadSnapshot(snapshot); errReply != nil {
 		return errReply
 	}
 	cluster.self = raft.selfNodeID
 	raft.start(follower)
+	// 加入后立即保存集群配置，重启时直接从配置文件恢复，无需再次加入
+	raft.tryPersist()
 	return nil
 }
 
 func (raft *Raft) LoadConfigFile() protocol.ErrorReply {
 	f, err := os.Open(raft.persistFile)
-	if err == os.ErrNotExist {
+	if errors.Is(err, os.ErrNotExist) {
 		return errConfigFileNotExist
+	} else if err != nil {
+		return protocol.MakeErrReply("open cluster config file failed: " + err.Error())
 	}
 	defer func() {
 		if err := f.Close(); err != nil {
@@ -1076,6 +1146,9 @@ func (raft *Raft) LoadConfigFile() protocol.ErrorReply {
 		line := append([]byte{}, scanner.Bytes()...) // copy the line...
 		snapshot = append(snapshot, line)
 	}
+	if err := scanner.Err(); err != nil {
+		return protocol.MakeErrReply("read cluster config file failed: " + err.Error())
+	}
 	raft.mu.Lock()
 	defer raft.mu.Unlock()
 	if errReply := raft.loadSnapshot(snapshot); errReply != nil {
@@ -1083,5 +1156,47 @@ func (raft *Raft) LoadConfigFile() protocol.ErrorReply {
 	}
 	raft.cluster.self = raft.selfNodeID
 	raft.start(raft.state)
+	if selfNode := raft.nodes[raft.selfNodeID]; selfNode != nil && selfNode.Addr != raft.cluster.addr {
+		// 重启后地址发生了变化(如IP改变)，节点ID不变，把新地址同步到整个集群
+		logger.Infof("address of %s changed from %s to %s", raft.selfNodeID, selfNode.Addr, raft.cluster.addr)
+		go raft.announceAddr(raft.cluster.addr)
+	}
 	return nil
 }
+
+// announceAddrRetry 同步新地址的最大尝试次数，每次间隔1秒，等待选举完成
+const announceAddrRetry = 30
+
+// announceAddr 通过 raft 日志把本节点的新地址同步到集群中的所有节点
+func (raft *Raft) announceAddr(addr string) {
+	for i := 0; i < announceAddrRetry; i++ {
+		time.Sleep(time.Second)
+		if raft.isClosed() {
+			return
+		}
+		raft.mu.RLock()
+		selfNodeID := raft.selfNodeID
+		leaderId := raft.leaderId
+		state := raft.state
+		raft.mu.RUnlock()
+		var errReply protocol.ErrorReply
+		if state == leader {
+			errReply = raft.propose(&logEntry{
+				Event:  eventNewNode,
+				NodeID: selfNodeID,
+				Addr:   addr,
+			})
+		} else if leaderId != "" && leaderId != selfNodeID {
+			// 与新节点加入使用相同的命令，leader 发现节点已存在时只更新地址
+			resp := raft.cluster.relay(leaderId, connection.NewFakeConn(),
+				utils.ToCmdLine("raft", "join", addr, selfNodeID))
+			errReply, _ = resp.(protocol.ErrorReply)
+		} else {
+			continue
+		}
+		if errReply == nil || errReply.Error() == "node exists" {
+			return
+		}
+		logger.Errorf("announce address of %s failed: %v", selfNodeID, errReply)
+	}
+}
@@ -0,0 +1,245 @@
+package cluster
+
+// raft event handlers
+
+import (
+	"Godis/interface/redis"
+	"Godis/internal/redis/connection"
+	"Godis/lib/logger"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"errors"
+)
+
+const (
+	eventNewNode = iota + 1
+	eventSetSlot
+	eventNodeFail
+	eventNodeRecover
+	eventSetMaster
+)
+
+// invoker should provide with raft.mu lock
+func (raft *Raft) applyLogEntries(entries []*logEntry) {
+	for _, entry := range entries {
+		switch entry.Event {
+		case eventNewNode:
+			raft.cluster.setPeerAddr(entry.NodeID, entry.Addr)
+			if node, ok := raft.nodes[entry.NodeID]; ok {
+				// the node has changed its address, keep its slots
+				node.Addr = entry.Addr
+				continue
+			}
+			node := &Node{
+				ID:   entry.NodeID,
+				Addr: entry.Addr,
+			}
+			raft.nodes[node.ID] = node
+			if raft.state == leader {
+				raft.nodeIndexMap[entry.NodeID] = &nodeStatus{
+					receivedIndex: entry.Index, // the new node should not receive its own join event
+				}
+			}
+		case eventSetSlot:
+			var takeOver []uint32
+			for _, slotID := range entry.SlotIDs {
+				slot := raft.slots[slotID]
+				oldNode := raft.nodes[slot.NodeID]
+				// remove from old oldNode
+				for i, s := range oldNode.Slots {
+					if s.ID == slot.ID {
+						copy(oldNode.Slots[i:], oldNode.Slots[i+1:])
+						oldNode.Slots = oldNode.Slots[:len(oldNode.Slots)-1]
+						break
+					}
+				}
+				newNodeID := entry.NodeID
+				slot.NodeID = newNodeID
+				// fixme: 多个节点同时加入后 re balance 时 newNode 可能为 nil
+				newNode := raft.nodes[slot.NodeID]
+				newNode.Slots = append(newNode.Slots, slot)
+				// 迁移槽位时新节点会先将槽位设置为 importing 状态，
+				// 本地没有记录的槽位是故障转移时由副本直接接管的，数据已通过复制同步到本地
+				if newNodeID == raft.selfNodeID && raft.cluster.getHostSlot(slotID) == nil {
+					takeOver = append(takeOver, slotID)
+				}
+			}
+			if len(takeOver) > 0 {
+				go raft.cluster.takeOverSlots(takeOver)
+			}
+		case eventNodeFail:
+			if node, ok := raft.nodes[entry.NodeID]; ok {
+				logger.Warn("node " + node.ID + "(" + node.Addr + ") is marked as failed")
+				node.Flags = node.Flags&^nodeFlagPFail | nodeFlagFail
+			}
+		case eventNodeRecover:
+			if node, ok := raft.nodes[entry.NodeID]; ok {
+				logger.Infof("node %s(%s) recovered", node.ID, node.Addr)
+				node.Flags &^= nodeFlagFail | nodeFlagPFail
+			}
+		case eventSetMaster:
+			node, ok := raft.nodes[entry.NodeID]
+			if !ok {
+				continue
+			}
+			node.MasterID = entry.MasterID
+			if node.ID == raft.selfNodeID {
+				raft.onSelfMasterChanged(entry.MasterID)
+			}
+		}
+	}
+	raft.tryPersist()
+}
+
+// NewNode creates a new Node when a node request self node for joining cluster
+func (raft *Raft) NewNode(addr string) (*Node, error) {
+	if raft.findNodeByAddr(addr) != nil {
+		return nil, errors.New("node existed")
+	}
+	node := &Node{
+		ID:   genNodeID(),
+		Addr: addr,
+	}
+	raft.nodes[node.ID] = node
+	proposal := &logEntry{
+		Event:  eventNewNode,
+		NodeID: node.ID,
+		Addr:   node.Addr,
+	}
+	conn := connection.NewFakeConn()
+	resp := raft.cluster.relay(raft.leaderId, conn,
+		utils.ToCmdLine("raft", "propose", string(proposal.marshal())))
+	if err, ok := resp.(protocol.ErrorReply); ok {
+		return nil, err
+	}
+	return node, nil
+}
+
+// SetSlot propose
+func (raft *Raft) SetSlot(slotIDs []uint32, newNodeID string) protocol.ErrorReply {
+	proposal := &logEntry{
+		Event:   eventSetSlot,
+		NodeID:  newNodeID,
+		SlotIDs: slotIDs,
+	}
+	conn := connection.NewFakeConn()
+	resp := raft.cluster.relay(raft.leaderId, conn,
+		utils.ToCmdLine("raft", "propose", string(proposal.marshal())))
+	if err, ok := resp.(protocol.ErrorReply); ok {
+		return err
+	}
+	return nil
+}
+
+// SetMaster propose
+func (raft *Raft) SetMaster(nodeID string, masterID string) protocol.ErrorReply {
+	proposal := &logEntry{
+		Event:    eventSetMaster,
+		NodeID:   nodeID,
+		MasterID: masterID,
+	}
+	conn := connection.NewFakeConn()
+	resp := raft.cluster.relay(raft.leaderId, conn,
+		utils.ToCmdLine("raft", "propose", string(proposal.marshal())))
+	if err, ok := resp.(protocol.ErrorReply); ok {
+		return err
+	}
+	return nil
+}
+
+// checkSetMaster validates eventSetMaster, invoker should hold raft.mu
+func (raft *Raft) checkSetMaster(nodeID string, masterID string) protocol.ErrorReply {
+	node, ok := raft.nodes[nodeID]
+	if !ok {
+		return protocol.MakeErrReply("ERR Unknown node " + nodeID)
+	}
+	if masterID == "" {
+		return nil
+	}
+	// 与 redis 一样，只有不持有槽位的节点才能成为副本
+	if len(node.Slots) > 0 {
+		return protocol.MakeErrReply("ERR To set a master the node must be empty and without assigned slots.")
+	}
+	if masterID == nodeID {
+		return protocol.MakeErrReply("ERR Can't replicate myself")
+	}
+	master, ok := raft.nodes[masterID]
+	if !ok {
+		return protocol.MakeErrReply("ERR Unknown node " + masterID)
+	}
+	if master.MasterID != "" {
+		return protocol.MakeErrReply("ERR I can only replicate a master, not a replica.")
+	}
+	return nil
+}
+
+// onSelfMasterChanged starts or stops replication of current node, invoker should hold raft.mu
+func (raft *Raft) onSelfMasterChanged(masterID string) {
+	var masterAddr string
+	if masterID != "" {
+		master, ok := raft.nodes[masterID]
+		if !ok {
+			logger.Errorf("master %s not found", masterID)
+			return
+		}
+		masterAddr = master.Addr
+	}
+	// SLAVEOF 会等待正在进行的复制结束，不能在持有 raft.mu 时执行
+	go raft.cluster.replicate(masterAddr)
+}
+
+// findNodeByAddr returns the node listening on addr, invoker should hold raft.mu
+func (raft *Raft) findNodeByAddr(addr string) *Node {
+	for _, node := range raft.nodes {
+		if node.Addr == addr {
+			return node
+		}
+	}
+	return nil
+}
+
+// execRaftJoin handles requests from a new node to join raft group, current node should be leader
+// command line: raft join addr [nodeID]
+// a known nodeID with a different addr means the node has changed its address
+func execRaftJoin(cluster *Cluster, c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) != 1 && len(args) != 2 {
+		return protocol.MakeArgNumErrReply("raft join")
+	}
+	raft := cluster.asRaft()
+	if raft.state != leader {
+		leaderNode := raft.nodes[raft.leaderId]
+		return protocol.MakeErrReply("NOT LEADER " + leaderNode.ID + " " + leaderNode.Addr)
+	}
+	addr := string(args[0])
+	nodeID := addr // nodes of earlier versions use address as id
+	if len(args) == 2 {
+		nodeID = string(args[1])
+	}
+
+	raft.mu.RLock()
+	node, exist := raft.nodes[nodeID]
+	if !exist {
+		// if node has joint cluster but terminated before persisting cluster config,
+		// it may try to join at next start with a new generated id.
+		// In this case, we only have to send a snapshot for it
+		if node = raft.findNodeByAddr(addr); node != nil {
+			nodeID = node.ID
+			exist = true
+		}
+	}
+	raft.mu.RUnlock()
+	if !exist || node.Addr != addr {
+		proposal := &logEntry{
+			Event:  eventNewNode,
+			NodeID: nodeID,
+			Addr:   addr,
+		}
+		if err := raft.propose(proposal); err != nil {
+			return err
+		}
+	}
+	raft.mu.RLock()
+	snapshot := raft.makeSnapshotForFollower(nodeID)
+	raft.mu.RUnlock()
+	return protocol.MakeMultiBulkReply(snapshot)
+}
@@ -0,0 +1,209 @@
+package cluster
+
+// 故障检测
+// leader 在 cluster-node-timeout 内没有收到某个节点的心跳响应时将其标记为 PFAIL(疑似下线)，
+// 然后请求其他节点探测该节点，超过半数节点(包括 leader 自己)都无法连接时，
+// 通过 raft 日志提交 eventNodeFail，所有节点将其标记为 FAIL；
+// 被标记为 FAIL 的节点恢复心跳后，leader 提交 eventNodeRecover 清除标记
+// 持有槽位的节点被标记为 FAIL 后，leader 将它的一个副本提升为主节点并把槽位转移给该副本
+
+import (
+	"Godis/interface/redis"
+	"Godis/internal/config"
+	"Godis/internal/redis/connection"
+	"Godis/lib/logger"
+	"Godis/lib/utils"
+	"Godis/lib/webhook"
+	"Godis/redis/protocol"
+	"time"
+)
+
+// defaultNodeTimeout 与 redis 的 cluster-node-timeout 默认值相同
+const defaultNodeTimeout = 15 * time.Second
+
+func nodeTimeout() time.Duration {
+	if config.Properties.ClusterNodeTimeout > 0 {
+		return time.Duration(config.Properties.ClusterNodeTimeout) * time.Millisecond
+	}
+	return defaultNodeTimeout
+}
+
+// markHeard records that leader received response from node, invoker should hold raft.mu
+func (raft *Raft) markHeard(nodeID string) {
+	node := raft.nodes[nodeID]
+	if node == nil {
+		return
+	}
+	node.lastHeard = time.Now()
+	node.Flags &^= nodeFlagPFail
+	if node.Flags&nodeFlagFail > 0 {
+		raft.startFailureCheck(nodeID, func() {
+			if err := raft.propose(&logEntry{Event: eventNodeRecover, NodeID: nodeID}); err != nil {
+				logger.Errorf("propose recovery of %s failed: %v", nodeID, err)
+			}
+		})
+	}
+}
+
+// checkNodeFailures marks nodes unreachable for longer than node timeout as PFAIL
+// and starts confirming their failure, invoker should hold raft.mu
+func (raft *Raft) checkNodeFailures() {
+	timeout := nodeTimeout()
+	now := time.Now()
+	for _, node := range raft.nodes {
+		if node.ID == raft.selfNodeID || node.Flags&nodeFlagFail > 0 {
+			continue
+		}
+		if node.lastHeard.IsZero() {
+			// just became leader, count from now
+			node.lastHeard = now
+			continue
+		}
+		if now.Sub(node.lastHeard) <= timeout {
+			continue
+		}
+		if node.Flags&nodeFlagPFail == 0 {
+			logger.Infof("node %s has not responded for %v, mark as PFAIL", node.ID, now.Sub(node.lastHeard))
+			node.Flags |= nodeFlagPFail
+		}
+		nodeID := node.ID
+		raft.startFailureCheck(nodeID, func() {
+			raft.confirmFailure(nodeID)
+		})
+	}
+}
+
+// startFailureCheck runs check in background, only one check of a node runs at the same time
+// invoker should hold raft.mu
+func (raft *Raft) startFailureCheck(nodeID string, check func()) {
+	if raft.failureChecks == nil {
+		raft.failureChecks = make(map[string]struct{})
+	}
+	if _, ok := raft.failureChecks[nodeID]; ok {
+		return
+	}
+	raft.failureChecks[nodeID] = struct{}{}
+	go func() {
+		defer func() {
+			raft.mu.Lock()
+			delete(raft.failureChecks, nodeID)
+			raft.mu.Unlock()
+		}()
+		check()
+	}()
+}
+
+// confirmFailure asks other nodes to probe the PFAIL node
+// the node is marked as FAIL if majority of nodes cannot reach it
+func (raft *Raft) confirmFailure(nodeID string) {
+	raft.mu.RLock()
+	var voters []string
+	for _, node := range raft.nodes {
+		if node.ID != raft.selfNodeID && node.ID != nodeID && node.Flags&nodeFlagFail == 0 {
+			voters = append(voters, node.ID)
+		}
+	}
+	quorum := len(raft.nodes)/2 + 1
+	raft.mu.RUnlock()
+
+	reports := 1 // leader itself
+	conn := connection.NewFakeConn()
+	for _, voter := range voters {
+		resp := raft.cluster.relay(voter, conn, utils.ToCmdLine("raft", "probe", nodeID))
+		if reachable, err := protocol.AsInt(resp); err == nil && reachable == 1 {
+			reports++
+		}
+	}
+	if reports < quorum {
+		logger.Infof("%d of %d nodes cannot reach %s, quorum is %d", reports, len(voters)+1, nodeID, quorum)
+		return
+	}
+	raft.mu.RLock()
+	node := raft.nodes[nodeID]
+	stillFailing := node != nil && node.Flags&nodeFlagPFail > 0
+	raft.mu.RUnlock()
+	if !stillFailing {
+		return
+	}
+	if err := raft.propose(&logEntry{Event: eventNodeFail, NodeID: nodeID}); err != nil {
+		logger.Errorf("propose failure of %s failed: %v", nodeID, err)
+		return
+	}
+	raft.failover(nodeID)
+}
+
+// failover promotes a replica of the failed master and moves slots of the failed master to it
+// the failed master and other replicas become replicas of the promoted one
+func (raft *Raft) failover(failedID string) {
+	raft.mu.RLock()
+	failed := raft.nodes[failedID]
+	if failed == nil || failed.MasterID != "" || len(failed.Slots) == 0 {
+		raft.mu.RUnlock()
+		return
+	}
+	slotIDs := make([]uint32, 0, len(failed.Slots))
+	for _, slot := range failed.Slots {
+		slotIDs = append(slotIDs, slot.ID)
+	}
+	// 选择 ID 最小的正常副本，保证结果确定
+	var promoted string
+	var others []string
+	for _, node := range raft.nodes {
+		if node.MasterID != failedID {
+			continue
+		}
+		if node.Flags&(nodeFlagFail|nodeFlagPFail) > 0 {
+			others = append(others, node.ID)
+			continue
+		}
+		if promoted == "" || node.ID < promoted {
+			if promoted != "" {
+				others = append(others, promoted)
+			}
+			promoted = node.ID
+		} else {
+			others = append(others, node.ID)
+		}
+	}
+	raft.mu.RUnlock()
+	if promoted == "" {
+		logger.Info("no available replica of failed node " + failedID)
+		return
+	}
+
+	logger.Infof("failover: promote %s to replace %s", promoted, failedID)
+	if err := raft.propose(&logEntry{Event: eventSetMaster, NodeID: promoted}); err != nil {
+		logger.Errorf("promote %s failed: %v", promoted, err)
+		return
+	}
+	if err := raft.propose(&logEntry{Event: eventSetSlot, SlotIDs: slotIDs, NodeID: promoted}); err != nil {
+		logger.Errorf("move slots of %s to %s failed: %v", failedID, promoted, err)
+		return
+	}
+	// 故障节点恢复后作为新主节点的副本
+	for _, nodeID := range append(others, failedID) {
+		if err := raft.propose(&logEntry{Event: eventSetMaster, NodeID: nodeID, MasterID: promoted}); err != nil {
+			logger.Errorf("set master of %s failed: %v", nodeID, err)
+		}
+	}
+	webhook.Publish(&webhook.Event{
+		Type:   webhook.EventFailover,
+		Node:   promoted,
+		Detail: "replica promoted to replace failed master " + failedID,
+	})
+}
+
+// execRaftProbe returns 1 if current node cannot reach the given node
+// command line: raft probe nodeID
+func execRaftProbe(cluster *Cluster, c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) != 1 {
+		return protocol.MakeArgNumErrReply("raft probe")
+	}
+	nodeID := string(args[0])
+	resp := cluster.relay(nodeID, connection.NewFakeConn(), utils.ToCmdLine("ping"))
+	if protocol.IsErrorReply(resp) {
+		logger.Info("probe " + nodeID + " failed: " + string(resp.ToBytes()))
+		return protocol.MakeIntReply(1)
+	}
+	return protocol.MakeIntReply(0)
+}
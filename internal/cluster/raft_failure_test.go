@@ -0,0 +1,221 @@
+package cluster
+
+import (
+	"Godis/interface/redis"
+	"Godis/internal/config"
+	"Godis/redis/protocol"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubClientFactory routes peer requests to handlers by address, peers without handler are unreachable
+type stubClientFactory struct {
+	mu       sync.Mutex
+	handlers map[string]func(args [][]byte) redis.Reply
+}
+
+type stubClient struct {
+	handler func(args [][]byte) redis.Reply
+}
+
+func (c *stubClient) Send(args [][]byte) redis.Reply {
+	return c.handler(args)
+}
+
+func (factory *stubClientFactory) handle(addr string, handler func(args [][]byte) redis.Reply) {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	factory.handlers[addr] = handler
+}
+
+func (factory *stubClientFactory) GetPeerClient(peerAddr string) (peerClient, error) {
+	factory.mu.Lock()
+	defer factory.mu.Unlock()
+	handler, ok := factory.handlers[peerAddr]
+	if !ok {
+		return nil, errors.New("connection refused")
+	}
+	return &stubClient{handler: handler}, nil
+}
+
+func (factory *stubClientFactory) ReturnPeerClient(peerAddr string, peerClient peerClient) error {
+	return nil
+}
+
+func (factory *stubClientFactory) NewStream(peerAddr string, cmdLine CmdLine) (peerStream, error) {
+	return nil, errors.New("not supported")
+}
+
+func (factory *stubClientFactory) Close() error {
+	return nil
+}
+
+// makeTestLeader creates a raft leader with the given nodes without starting raft jobs
+// the first node is the leader itself, peers are served by the returned factory
+func makeTestLeader(nodeIDs ...string) (*Raft, *stubClientFactory) {
+	factory := &stubClientFactory{handlers: make(map[string]func(args [][]byte) redis.Reply)}
+	cluster := &Cluster{
+		self:          nodeIDs[0],
+		clientFactory: factory,
+	}
+	raft := newRaft(cluster, "")
+	cluster.topology = raft
+	raft.selfNodeID = nodeIDs[0]
+	raft.leaderId = nodeIDs[0]
+	raft.state = leader
+	raft.nodes = make(map[string]*Node)
+	for _, id := range nodeIDs {
+		raft.nodes[id] = &Node{ID: id, Addr: id, lastHeard: time.Now()}
+	}
+	raft.nodes[nodeIDs[0]].setState(leader)
+	raft.nodeIndexMap = map[string]*nodeStatus{nodeIDs[0]: {}}
+	return raft, factory
+}
+
+// waitFailureChecks commits proposals like a leader until all failure checks finished
+func waitFailureChecks(t *testing.T, raft *Raft) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		raft.mu.Lock()
+		raft.commitLogEntries(raft.proposedIndex)
+		running := len(raft.failureChecks)
+		raft.mu.Unlock()
+		if running == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("failure check does not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// probeHandler answers `raft probe` with reachable or not
+func probeHandler(canReach bool) func(args [][]byte) redis.Reply {
+	return func(args [][]byte) redis.Reply {
+		if strings.ToLower(string(args[1])) != "probe" {
+			return protocol.MakeErrReply("unexpected command")
+		}
+		if canReach {
+			return protocol.MakeIntReply(0)
+		}
+		return protocol.MakeIntReply(1)
+	}
+}
+
+func (raft *Raft) flagsOf(nodeID string) uint32 {
+	raft.mu.RLock()
+	defer raft.mu.RUnlock()
+	return raft.nodes[nodeID].Flags
+}
+
+func TestNodeFailureQuorum(t *testing.T) {
+	timeout := config.Properties.ClusterNodeTimeout
+	config.Properties.ClusterNodeTimeout = 100
+	defer func() {
+		config.Properties.ClusterNodeTimeout = timeout
+	}()
+
+	cases := []struct {
+		name string
+		// whether b, c and d can reach the suspected node a
+		reachable  []bool
+		expectFail bool
+	}{
+		// 包括 leader 在内5个节点中有3个无法连接，达到多数
+		{"majority", []bool{false, false, true}, true},
+		{"all", []bool{false, false, false}, true},
+		// 只有 leader 和 b 无法连接
+		{"minority", []bool{false, true, true}, false},
+	}
+	for _, c := range cases {
+		raft, factory := makeTestLeader("leader", "a", "b", "c", "d")
+		for i, voter := range []string{"b", "c", "d"} {
+			factory.handle(voter, probeHandler(c.reachable[i]))
+		}
+		raft.mu.Lock()
+		raft.nodes["a"].lastHeard = time.Now().Add(-time.Second)
+		raft.checkNodeFailures()
+		raft.mu.Unlock()
+		if raft.flagsOf("a")&nodeFlagPFail == 0 {
+			t.Errorf("%s: a should be marked as PFAIL", c.name)
+		}
+		for _, id := range []string{"b", "c", "d"} {
+			if raft.flagsOf(id)&(nodeFlagPFail|nodeFlagFail) != 0 {
+				t.Errorf("%s: %s should not be suspected", c.name, id)
+			}
+		}
+		waitFailureChecks(t, raft)
+		flags := raft.flagsOf("a")
+		if c.expectFail && (flags&nodeFlagFail == 0 || flags&nodeFlagPFail != 0) {
+			t.Errorf("%s: a should be marked as FAIL, flags %b", c.name, flags)
+		}
+		if !c.expectFail && (flags&nodeFlagFail != 0 || flags&nodeFlagPFail == 0) {
+			t.Errorf("%s: a should stay PFAIL, flags %b", c.name, flags)
+		}
+	}
+}
+
+func TestNodeFailurePropagation(t *testing.T) {
+	raft, _ := makeTestLeader("leader", "a", "b")
+	raft.mu.Lock()
+	raft.nodes["a"].Flags |= nodeFlagPFail
+	raft.nodes["b"].Flags |= nodeFlagPFail
+	raft.applyLogEntries([]*logEntry{{Event: eventNodeFail, NodeID: "a"}})
+	// PFAIL 只是 leader 自己的判断，不会出现在发给其他节点的快照中
+	snapshot := raft.makeSnapshotForFollower("b")
+	raft.mu.Unlock()
+
+	follower, _ := makeTestLeader("b")
+	follower.mu.Lock()
+	if errReply := follower.loadSnapshot(snapshot); errReply != nil {
+		t.Fatal(errReply)
+	}
+	follower.mu.Unlock()
+	if flags := follower.flagsOf("a"); flags&nodeFlagFail == 0 || flags&nodeFlagPFail != 0 {
+		t.Errorf("a should be FAIL in snapshot, flags %b", flags)
+	}
+	if flags := follower.flagsOf("b"); flags&(nodeFlagFail|nodeFlagPFail) != 0 {
+		t.Errorf("PFAIL of b should not be in snapshot, flags %b", flags)
+	}
+
+	// 通过日志收到的 FAIL 与恢复
+	follower.mu.Lock()
+	follower.applyLogEntries([]*logEntry{{Event: eventNodeRecover, NodeID: "a"}})
+	follower.mu.Unlock()
+	if flags := follower.flagsOf("a"); flags&nodeFlagFail != 0 {
+		t.Errorf("a should be recovered, flags %b", flags)
+	}
+}
+
+func TestNodeFailureRecover(t *testing.T) {
+	raft, _ := makeTestLeader("leader", "a", "b")
+	raft.mu.Lock()
+	raft.nodes["a"].Flags |= nodeFlagFail
+	raft.nodes["b"].Flags |= nodeFlagPFail
+	// 恢复心跳后清除 PFAIL，FAIL 需要通过日志清除
+	raft.markHeard("b")
+	raft.markHeard("a")
+	raft.mu.Unlock()
+	if flags := raft.flagsOf("b"); flags&nodeFlagPFail != 0 {
+		t.Errorf("PFAIL of b should be cleared, flags %b", flags)
+	}
+	waitFailureChecks(t, raft)
+	if flags := raft.flagsOf("a"); flags&nodeFlagFail != 0 {
+		t.Errorf("FAIL of a should be cleared, flags %b", flags)
+	}
+
+	// FAIL 的节点不再被重复检测
+	raft.mu.Lock()
+	raft.nodes["b"].Flags |= nodeFlagFail
+	raft.nodes["b"].lastHeard = time.Now().Add(-time.Hour)
+	raft.checkNodeFailures()
+	checks := len(raft.failureChecks)
+	raft.mu.Unlock()
+	if checks != 0 {
+		t.Errorf("failed node should not be checked again, %d checks running", checks)
+	}
+}
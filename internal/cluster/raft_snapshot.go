@@ -2,6 +2,7 @@ package cluster
 
 import (
 	"Godis/redis/protocol"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"sort"
@@ -87,6 +88,7 @@ type nodePayload struct {
 	Addr     string   `json:"addr"`
 	SlotDesc []string `json:"slotDesc"`
 	Flags    uint32   `json:"flags"`
+	MasterID string   `json:"master,omitempty"`
 }
 
 func marshalNodes(nodes map[string]*Node) [][]byte {
@@ -97,7 +99,8 @@ func marshalNodes(nodes map[string]*Node) [][]byte {
 			ID:       node.ID,
 			Addr:     node.Addr,
 			SlotDesc: slotLines,
-			Flags:    node.Flags,
+			Flags:    node.Flags &^ nodeFlagPFail, // PFAIL is a local view of leader
+			MasterID: node.MasterID,
 		}
 		bin, _ := json.Marshal(payload)
 		args = append(args, bin)
@@ -118,9 +121,10 @@ func unmarshalNodes(args [][]byte) (map[string]*Node, error) {
 			return nil, err
 		}
 		node := &Node{
-			ID:    payload.ID,
-			Addr:  payload.Addr,
-			Flags: payload.Flags,
+			ID:       payload.ID,
+			Addr:     payload.Addr,
+			Flags:    payload.Flags,
+			MasterID: payload.MasterID,
 		}
 		for _, slotId := range slotIds {
 			node.Slots = append(node.Slots, &Slot{
@@ -134,6 +138,11 @@ func unmarshalNodes(args [][]byte) (map[string]*Node, error) {
 	return nodeMap, nil
 }
 
+// 快照的格式，每个元素占一行，同时也是集群配置文件的格式:
+// selfNodeID, state, leaderId, term, committedIndex, votedFor, 之后每行一个节点(JSON)
+// 旧版本的快照没有 votedFor，第6行直接是节点
+const snapshotHeaderLen = 6
+
 // genSnapshot
 // invoker provide lock
 func (raft *Raft) makeSnapshot() [][]byte {
@@ -144,6 +153,7 @@ func (raft *Raft) makeSnapshot() [][]byte {
 		[]byte(raft.leaderId),
 		[]byte(strconv.Itoa(raft.term)),
 		[]byte(strconv.Itoa(raft.committedIndex)),
+		[]byte(raft.votedFor),
 	}
 	snapshot = append(snapshot, topology...)
 	return snapshot
@@ -155,12 +165,16 @@ func (raft *Raft) makeSnapshotForFollower(followerId string) [][]byte {
 	snapshot := raft.makeSnapshot()
 	snapshot[0] = []byte(followerId)
 	snapshot[1] = []byte(strconv.Itoa(int(follower)))
+	snapshot[5] = nil // the follower has not voted in current term
 	return snapshot
 }
 
 // invoker provide with lock
 func (raft *Raft) loadSnapshot(snapshot [][]byte) protocol.ErrorReply {
 	// make sure raft.slots and node.Slots is the same object
+	if len(snapshot) < snapshotHeaderLen-1 {
+		return protocol.MakeErrReply("illegal snapshot: too few lines")
+	}
 	selfNodeId := string(snapshot[0])
 	state0, err := strconv.Atoi(string(snapshot[1]))
 	if err != nil {
@@ -179,14 +193,25 @@ func (raft *Raft) loadSnapshot(snapshot [][]byte) protocol.ErrorReply {
 	if err != nil {
 		return protocol.MakeErrReply("illegal commit index: " + string(snapshot[3]))
 	}
-	nodes, err := unmarshalNodes(snapshot[5:])
+	var votedFor string
+	nodeLines := snapshot[snapshotHeaderLen-1:]
+	if len(nodeLines) > 0 && !bytes.HasPrefix(nodeLines[0], []byte("{")) {
+		votedFor = string(nodeLines[0])
+		nodeLines = nodeLines[1:]
+	}
+	nodes, err := unmarshalNodes(nodeLines)
 	if err != nil {
 		return protocol.MakeErrReply(err.Error())
 	}
+	var prevMasterID string
+	if selfNode := raft.nodes[selfNodeId]; selfNode != nil {
+		prevMasterID = selfNode.MasterID
+	}
 	raft.selfNodeID = selfNodeId
 	raft.state = state
 	raft.leaderId = leaderId
 	raft.term = term
+	raft.votedFor = votedFor
 	raft.committedIndex = commitIndex
 	raft.proposedIndex = commitIndex
 	raft.initLog(term, commitIndex, nil)
@@ -200,5 +225,12 @@ func (raft *Raft) loadSnapshot(snapshot [][]byte) protocol.ErrorReply {
 		}
 	}
 	raft.nodes = nodes
+	for _, node := range nodes {
+		raft.cluster.setPeerAddr(node.ID, node.Addr)
+	}
+	// 加载配置文件或从 leader 收到快照后，按照快照中的主从关系开始或停止复制
+	if selfNode := nodes[selfNodeId]; selfNode != nil && selfNode.MasterID != prevMasterID {
+		raft.onSelfMasterChanged(selfNode.MasterID)
+	}
 	return nil
 }
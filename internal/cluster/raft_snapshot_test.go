@@ -0,0 +1,89 @@
+package cluster
+
+import (
+	"Godis/datastruct/dict"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotPersistNodeIDs(t *testing.T) {
+	selfID, peerID, replicaID := genNodeID(), genNodeID(), genNodeID()
+	raft, _ := makeTestLeader(selfID, peerID, replicaID)
+	raft.persistFile = filepath.Join(t.TempDir(), "nodes.conf")
+	raft.mu.Lock()
+	raft.state = follower
+	raft.nodes[selfID].setState(follower)
+	raft.leaderId = peerID
+	raft.nodes[peerID].setState(leader)
+	raft.term = 3
+	raft.votedFor = peerID
+	raft.slots = make([]*Slot, slotCount)
+	for i := range raft.slots {
+		owner := raft.nodes[selfID]
+		if i >= slotCount/2 {
+			owner = raft.nodes[peerID]
+		}
+		raft.slots[i] = &Slot{ID: uint32(i), NodeID: owner.ID}
+		owner.Slots = append(owner.Slots, raft.slots[i])
+	}
+	raft.nodes[selfID].Addr = "127.0.0.1:6399"
+	raft.nodes[peerID].Addr = "127.0.0.1:6400"
+	raft.nodes[replicaID].Addr = "127.0.0.1:6401"
+	raft.nodes[replicaID].MasterID = selfID
+	if err := raft.persist(); err != nil {
+		t.Fatal(err)
+	}
+	raft.mu.Unlock()
+
+	// 重启后监听地址发生了变化，节点ID保持不变
+	reloaded, _ := makeTestLeader("unknown")
+	reloaded.persistFile = raft.persistFile
+	reloaded.cluster.addr = "127.0.0.1:7399"
+	reloaded.cluster.peerAddrs = dict.MakeSyncSimple()
+	if errReply := reloaded.LoadConfigFile(); errReply != nil {
+		t.Fatal(errReply)
+	}
+	defer func() {
+		_ = reloaded.Close()
+	}()
+
+	reloaded.mu.RLock()
+	defer reloaded.mu.RUnlock()
+	if reloaded.selfNodeID != selfID || reloaded.cluster.self != selfID {
+		t.Errorf("expect self node id %s, actual %s", selfID, reloaded.selfNodeID)
+	}
+	if len(selfID) != nodeIDLen {
+		t.Errorf("expect node id of %d characters, actual %q", nodeIDLen, selfID)
+	}
+	if reloaded.leaderId != peerID || reloaded.term != 3 || reloaded.votedFor != peerID {
+		t.Errorf("unexpected leader %s term %d voted for %s", reloaded.leaderId, reloaded.term, reloaded.votedFor)
+	}
+	if len(reloaded.nodes) != 3 {
+		t.Fatalf("expect 3 nodes, actual %d", len(reloaded.nodes))
+	}
+	for _, expected := range []struct {
+		id     string
+		addr   string
+		slots  int
+		master string
+	}{
+		{selfID, "127.0.0.1:6399", slotCount / 2, ""},
+		{peerID, "127.0.0.1:6400", slotCount / 2, ""},
+		{replicaID, "127.0.0.1:6401", 0, selfID},
+	} {
+		node := reloaded.nodes[expected.id]
+		if node == nil {
+			t.Errorf("node %s is lost", expected.id)
+			continue
+		}
+		if node.Addr != expected.addr || len(node.Slots) != expected.slots || node.MasterID != expected.master {
+			t.Errorf("unexpected node %s: addr %s, %d slots, master %s", node.ID, node.Addr, len(node.Slots), node.MasterID)
+		}
+		if addr := reloaded.cluster.getPeerAddr(expected.id); addr != expected.addr {
+			t.Errorf("expect peer address %s of %s, actual %s", expected.addr, expected.id, addr)
+		}
+	}
+	if reloaded.slots[0].NodeID != selfID || reloaded.slots[slotCount-1].NodeID != peerID {
+		t.Errorf("unexpected slot owners %s, %s", reloaded.slots[0].NodeID, reloaded.slots[slotCount-1].NodeID)
+	}
+}
@@ -0,0 +1,81 @@
+package cluster
+
+// 集群中的副本
+// 通过 CLUSTER REPLICATE 成为其他节点的副本，副本不持有槽位，使用数据库层的主从复制同步主节点的全部数据
+// 主节点被标记为 FAIL 后，leader 选择它的一个副本提升为主节点并将槽位转移给它，见 raft_failure.go
+
+import (
+	"Godis/interface/database"
+	"Godis/internal/config"
+	database2 "Godis/internal/database"
+	"Godis/internal/redis/connection"
+	"Godis/lib/logger"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"net"
+	"time"
+)
+
+func init() {
+	// 副本通过 PSYNC 和 REPLCONF 与主节点建立复制，由数据库层处理
+	registerCmd("PSync", genPenetratingExecutor("PSync"))
+	registerCmd("ReplConf", genPenetratingExecutor("ReplConf"))
+}
+
+// replicate makes current node a replica of the node listening on masterAddr
+// empty masterAddr stops replication and makes current node a master
+func (cluster *Cluster) replicate(masterAddr string) {
+	var cmdLine [][]byte
+	if masterAddr == "" {
+		cmdLine = utils.ToCmdLine("SlaveOf", "no", "one")
+	} else {
+		host, port, err := net.SplitHostPort(masterAddr)
+		if err != nil {
+			logger.Errorf("illegal master address %s: %v", masterAddr, err)
+			return
+		}
+		cmdLine = utils.ToCmdLine("SlaveOf", host, port)
+	}
+	conn := connection.NewFakeConn()
+	conn.SetPassword(config.Properties.RequirePass)
+	resp := cluster.db.Exec(conn, cmdLine)
+	if protocol.IsErrorReply(resp) {
+		logger.Errorf("replicate %s failed: %s", masterAddr, string(resp.ToBytes()))
+		return
+	}
+	if masterAddr == "" {
+		logger.Info("stop replication, become master")
+	} else {
+		logger.Info("start replicating " + masterAddr)
+	}
+}
+
+// takeOverSlots hosts slots whose data is already in local db, such as slots of failed master taken over by its replica
+func (cluster *Cluster) takeOverSlots(slotIDs []uint32) {
+	slotSet := make(map[uint32]struct{}, len(slotIDs))
+	for _, slotID := range slotIDs {
+		if cluster.getHostSlot(slotID) == nil {
+			cluster.initSlot(slotID, slotStateHost)
+		}
+		slotSet[slotID] = struct{}{}
+	}
+	// 复制过程中写入的key不会触发回调，需要遍历数据库记录槽位中的key
+	cluster.db.ForEach(0, func(key string, data *database.DataEntity, expiration *time.Time) bool {
+		slotID := getSlot(key)
+		if _, ok := slotSet[slotID]; ok {
+			slot := cluster.getHostSlot(slotID)
+			slot.mu.Lock()
+			slot.keys.Add(key)
+			slot.mu.Unlock()
+		}
+		return true
+	})
+	logger.Infof("took over %d slots", len(slotIDs))
+}
+
+// syncReplicationID uses node id as replication id of current node, so that replicas can find their master by node id
+func (cluster *Cluster) syncReplicationID() {
+	if server, ok := cluster.db.(*database2.Server); ok && cluster.self != "" {
+		server.SetReplicationID(cluster.self)
+	}
+}
@@ -90,6 +90,10 @@ func init() {
 		"incrByFloat",
 		"decr",
 		"decrBy",
+		"append",
+		"strLen",
+		"getRange",
+		"setRange",
 		"lPush",
 		"lPushX",
 		"rPush",
@@ -116,6 +120,7 @@ func init() {
 		"HIncrBy",
 		"HIncrByFloat",
 		"HRandField",
+		"HScan",
 		"SAdd",
 		"SIsMember",
 		"SRem",
@@ -129,6 +134,7 @@ func init() {
 		"SDiff",
 		"SDiffStore",
 		"SRandMember",
+		"SScan",
 		"ZAdd",
 		"ZScore",
 		"ZIncrBy",
@@ -143,14 +149,40 @@ func init() {
 		"ZRem",
 		"ZRemRangeByScore",
 		"ZRemRangeByRank",
+		"ZPopMin",
+		"ZPopMax",
 		"GeoAdd",
 		"GeoPos",
 		"GeoDist",
 		"GeoHash",
 		"GeoRadius",
 		"GeoRadiusByMember",
+		"BF.Reserve",
+		"BF.Add",
+		"BF.Exists",
+		"BF.MAdd",
+		"BF.MExists",
+		"BF.Card",
+		"BF.Info",
+		"CF.Reserve",
+		"CF.Add",
+		"CF.AddNX",
+		"CF.Exists",
+		"CF.Count",
+		"CF.Del",
+		"TS.Create",
+		"TS.Add",
+		"TS.Get",
+		"TS.Range",
+		// 源键和目标键需要在同一个节点上
+		"TS.CreateRule",
+		"TS.DeleteRule",
+		"JSON.Set",
+		"JSON.Get",
+		"JSON.Del",
 		"GetVer",
 		"DumpKey",
+		"Undelete",
 	}
 	for _, name := range defaultCmds {
 		registerDefaultCmd(name)
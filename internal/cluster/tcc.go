@@ -1,11 +1,13 @@
 package cluster
 
 import (
-	"Godis/database"
 	"Godis/interface/redis"
+	"Godis/internal/database"
 	"Godis/lib/logger"
 	"Godis/lib/timewheel"
+	"Godis/lib/utils"
 	"Godis/redis/protocol"
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -39,8 +41,11 @@ type Transaction struct {
 }
 
 const (
-	maxLockTime       = 3 * time.Second
-	waitBeforeCleanTx = 2 * maxLockTime
+	maxLockTime = 3 * time.Second
+	// lockAttemptTime is the max time of each attempt to lock keys in prepare
+	lockAttemptTime     = 50 * time.Millisecond
+	maxLockRetryBackoff = 100 * time.Millisecond
+	waitBeforeCleanTx   = 2 * maxLockTime
 
 	createdStatus    = 0
 	preparedStatus   = 1
@@ -74,6 +79,29 @@ func (tx *Transaction) lockKeys() {
 	}
 }
 
+// tryLockKeys is like lockKeys, but gives up if keys cannot be locked within timeout
+// prepare uses it so that a transaction blocked by others fails fast instead of wedging the keys
+// invoker should hold tx.mu
+func (tx *Transaction) tryLockKeys(timeout time.Duration) error {
+	if tx.keysLocked {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// 每次只尝试较短的时间，失败后随机退避再重试，使冲突的事务错开加锁的时机，减少因互相等待超时导致的回滚
+	backoff := &utils.Backoff{Base: time.Millisecond, Max: maxLockRetryBackoff}
+	err := utils.Retry(ctx, backoff, 0, func() (bool, error) {
+		attemptCtx, cancelAttempt := context.WithTimeout(ctx, lockAttemptTime)
+		defer cancelAttempt()
+		return tx.cluster.db.TryRWLocks(attemptCtx, tx.dbIndex, tx.writeKeys, tx.readKeys) == nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("lock keys of transaction %s failed: %v", tx.id, err)
+	}
+	tx.keysLocked = true
+	return nil
+}
+
 func (tx *Transaction) unLockKeys() {
 	if tx.keysLocked {
 		tx.cluster.db.RWUnLocks(tx.dbIndex, tx.writeKeys, tx.readKeys)
@@ -88,7 +116,9 @@ func (tx *Transaction) prepare() error {
 
 	tx.writeKeys, tx.readKeys = database.GetRelatedKeys(tx.cmdLine)
 	// lock writeKeys
-	tx.lockKeys()
+	if err := tx.tryLockKeys(maxLockTime); err != nil {
+		return err
+	}
 
 	for _, key := range tx.writeKeys {
 		err := tx.cluster.ensureKey(key)
@@ -143,9 +173,7 @@ func execPrepare(cluster *Cluster, c redis.Connection, cmdLine CmdLine) redis.Re
 	txID := string(cmdLine[1])
 	cmdName := strings.ToLower(string(cmdLine[2]))
 	tx := NewTransaction(cluster, c, txID, cmdLine[2:])
-	cluster.transactionMu.Lock()
 	cluster.transactions.Put(txID, tx)
-	cluster.transactionMu.Unlock()
 	err := tx.prepare()
 	if err != nil {
 		return protocol.MakeErrReply(err.Error())
@@ -163,9 +191,7 @@ func execRollback(cluster *Cluster, c redis.Connection, cmdLine CmdLine) redis.R
 		return protocol.MakeErrReply("ERR wrong number of arguments for 'rollback' command")
 	}
 	txID := string(cmdLine[1])
-	cluster.transactionMu.RLock()
 	raw, ok := cluster.transactions.Get(txID)
-	cluster.transactionMu.RUnlock()
 	if !ok {
 		return protocol.MakeIntReply(0)
 	}
@@ -179,9 +205,7 @@ func execRollback(cluster *Cluster, c redis.Connection, cmdLine CmdLine) redis.R
 	}
 	// clean transaction
 	timewheel.Delay(waitBeforeCleanTx, "", func() {
-		cluster.transactionMu.Lock()
 		cluster.transactions.Remove(tx.id)
-		cluster.transactionMu.Unlock()
 	})
 	return protocol.MakeIntReply(1)
 }
@@ -192,9 +216,7 @@ func execCommit(cluster *Cluster, c redis.Connection, cmdLine CmdLine) redis.Rep
 		return protocol.MakeErrReply("ERR wrong number of arguments for 'commit' command")
 	}
 	txID := string(cmdLine[1])
-	cluster.transactionMu.RLock()
 	raw, ok := cluster.transactions.Get(txID)
-	cluster.transactionMu.RUnlock()
 	if !ok {
 		return protocol.MakeIntReply(0)
 	}
@@ -216,9 +238,7 @@ func execCommit(cluster *Cluster, c redis.Connection, cmdLine CmdLine) redis.Rep
 	// clean finished transaction
 	// do not clean immediately, in case rollback
 	timewheel.Delay(waitBeforeCleanTx, "", func() {
-		cluster.transactionMu.Lock()
 		cluster.transactions.Remove(tx.id)
-		cluster.transactionMu.Unlock()
 	})
 	return result
 }
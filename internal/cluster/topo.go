@@ -1,10 +1,10 @@
 package cluster
 
 import (
-	"Godis/database"
+	"Godis/internal/database"
+	"Godis/internal/redis/connection"
 	"Godis/lib/logger"
 	"Godis/lib/utils"
-	"Godis/redis/connection"
 	"Godis/redis/protocol"
 	"fmt"
 	"strconv"
@@ -70,13 +70,12 @@ func (cluster *Cluster) reBalance() {
 			logger.Errorf("get client of %s failed: %v", node.Addr, err)
 			continue
 		}
-		resp := peerCli.Send(reqDonateCmdLine)
-		payload, ok := resp.(*protocol.MultiBulkReply)
-		if !ok {
+		donated, err := protocol.AsBulkArray(peerCli.Send(reqDonateCmdLine))
+		if err != nil {
 			logger.Errorf("request donate to %s failed: %v", node.Addr, err)
 			continue
 		}
-		for _, bin := range payload.Args {
+		for _, bin := range donated {
 			slotID64, err := strconv.ParseUint(string(bin), 10, 64)
 			if err != nil {
 				continue
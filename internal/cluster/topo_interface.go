@@ -1,9 +1,8 @@
 package cluster
 
 import (
+	"Godis/lib/crc"
 	"Godis/redis/protocol"
-	"hash/crc32"
-	"strings"
 	"time"
 )
 
@@ -18,30 +17,19 @@ type Slot struct {
 	Flags uint32
 }
 
-// getPartitionKey extract hashtag
-func getPartitionKey(key string) string {
-	beg := strings.Index(key, "{")
-	if beg == -1 {
-		return key
-	}
-	end := strings.Index(key, "}")
-	if end == -1 || end == beg+1 {
-		return key
-	}
-	return key[beg+1 : end]
-}
-
+// getSlot returns slot of key, same as redis cluster so that smart clients can route commands
 func getSlot(key string) uint32 {
-	partitionKey := getPartitionKey(key)
-	return crc32.ChecksumIEEE([]byte(partitionKey)) % uint32(slotCount)
+	return crc.KeySlot(key)
 }
 
 // Node represents a node and its slots, used in cluster internal messages
 type Node struct {
-	ID        string
-	Addr      string
-	Slots     []*Slot // ascending order by slot id
-	Flags     uint32
+	ID    string
+	Addr  string
+	Slots []*Slot // ascending order by slot id
+	Flags uint32
+	// MasterID is id of the master node if the node is a replica, replica does not host slots
+	MasterID  string
 	lastHeard time.Time
 }
 
@@ -52,6 +40,8 @@ type topology interface {
 	GetSlots() []*Slot
 	StartAsSeed(addr string) protocol.ErrorReply
 	SetSlot(slotIDs []uint32, newNodeID string) protocol.ErrorReply
+	// SetMaster makes node a replica of master, empty masterID promotes the replica to master
+	SetMaster(nodeID string, masterID string) protocol.ErrorReply
 	LoadConfigFile() protocol.ErrorReply
 	Join(seed string) protocol.ErrorReply
 	Close() error
@@ -2,8 +2,8 @@ package cluster
 
 import (
 	"Godis/datastruct/set"
+	"Godis/internal/redis/connection"
 	"Godis/lib/utils"
-	"Godis/redis/connection"
 )
 
 func (cluster *Cluster) isImportedKey(key string) bool {
@@ -97,3 +97,12 @@ func (cluster *Cluster) cleanDroppedSlot(slotID uint32) {
 		}
 	}()
 }
+
+// nodeIDLen 节点ID的长度，与 redis cluster 一致使用40位十六进制字符串
+const nodeIDLen = 40
+
+// genNodeID 生成新节点的ID，节点首次启动时生成并随集群配置持久化
+// 节点ID与地址无关，地址变化(如IP改变)后节点身份和持有的槽位不变
+func genNodeID() string {
+	return utils.RandHexString(nodeIDLen)
+}
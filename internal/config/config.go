@@ -0,0 +1,454 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"Godis/lib/clock"
+	"Godis/lib/utils"
+
+	"Godis/lib/logger"
+)
+
+var (
+	ClusterMode    = "cluster"
+	StandaloneMode = "standalone"
+)
+
+const (
+	defaultLfuLogFactor = 10
+	defaultLfuDecayTime = 1
+
+	defaultListpackEntries = 128
+	defaultListpackValue   = 64
+
+	defaultSlowlogLogSlowerThan = 10000
+	defaultSlowlogMaxLen        = 128
+
+	defaultAofMaxWriteErrors = 1
+)
+
+// ServerProperties defines global config properties
+type ServerProperties struct {
+	// for Public configuration
+	RunID             string `cfg:"runid"` // runID always different at every exec.
+	Bind              string `cfg:"bind"`
+	Port              int    `cfg:"port"`
+	TLSPort           int    `cfg:"tls-port"`
+	TLSCertFile       string `cfg:"tls-cert-file"`
+	TLSKeyFile        string `cfg:"tls-key-file"`
+	GatewayPort       int    `cfg:"http-gateway-port"` // 0 disables http gateway
+	Dir               string `cfg:"dir"`
+	AnnounceHost      string `cfg:"announce-host"`
+	AppendOnly        bool   `cfg:"appendonly"`
+	AppendFilename    string `cfg:"appendfilename"`
+	AppendFsync       string `cfg:"appendfsync"`
+	AofUseRdbPreamble bool   `cfg:"aof-use-rdb-preamble"`
+	// write commands are rejected with MISCONF after aof-max-write-errors consecutive failed aof writes
+	// or a failed fsync, until retrying succeeds, 0 disables rejecting, default 1 same as redis
+	AofMaxWriteErrors int `cfg:"aof-max-write-errors"`
+	// connections authenticated with adminpass are never rejected by MISCONF or MAXQUEUE, so operators could still fix an overloaded server
+	AdminPass string `cfg:"adminpass"`
+	// write commands are rejected with MAXQUEUE while internal queues are longer than the limits, instead of waiting in line, 0 disables
+	// max-aof-queue is commands waiting to be written into aof, writers block when all 1048576 slots are used
+	// max-job-queue is expired jobs of time wheel waiting for workers, such as purging trash and hash field expiration
+	// max-output-buffer is total bytes of replies waiting for slow clients to read
+	MaxAofQueue       int    `cfg:"max-aof-queue"`
+	MaxJobQueue       int    `cfg:"max-job-queue"`
+	MaxOutputBuffer   int    `cfg:"max-output-buffer"`
+	MaxClients        int    `cfg:"maxclients"`
+	RequirePass       string `cfg:"requirepass"`
+	Databases         int    `cfg:"databases"`
+	RDBFilename       string `cfg:"dbfilename"`
+	MasterAuth        string `cfg:"masterauth"`
+	SlaveAnnouncePort int    `cfg:"slave-announce-port"`
+	SlaveAnnounceIP   string `cfg:"slave-announce-ip"`
+	ReplTimeout       int    `cfg:"repl-timeout"`
+	ClusterEnable     bool   `cfg:"cluster-enable"`
+	ClusterAsSeed     bool   `cfg:"cluster-as-seed"`
+	ClusterSeed       string `cfg:"cluster-seed"`
+	ClusterConfigFile string `cfg:"cluster-config-file"`
+	PeerKeepalive     int    `cfg:"peer-keepalive"` // seconds, interval of PING on idle peer connections
+	// milliseconds, a node unreachable for longer than it is suspected to be failing (PFAIL), 0 means default 15000
+	ClusterNodeTimeout int `cfg:"cluster-node-timeout"`
+
+	// for websocket bridge, port 0 disables it
+	WebSocketPort int `cfg:"websocket-port"`
+	// comma separated Origin whitelist, empty means same origin only
+	WebSocketOrigins []string `cfg:"websocket-allowed-origins"`
+
+	// for read-only web dashboard, port 0 disables it, user and password are required
+	DashboardPort     int    `cfg:"dashboard-port"`
+	DashboardUser     string `cfg:"dashboard-user"`
+	DashboardPassword string `cfg:"dashboard-password"`
+
+	// for memcached text protocol adapter, port 0 disables it
+	MemcachedPort int `cfg:"memcached-port"`
+	// memcached protocol has no authentication, it must be set to yes explicitly if requirepass is set
+	MemcachedNoAuth bool `cfg:"memcached-no-auth"`
+
+	// for webhook, events are posted to every url in comma separated webhook-urls
+	WebhookURLs      []string `cfg:"webhook-urls"`
+	WebhookRateLimit int      `cfg:"webhook-rate-limit"`             // max events per second
+	WebhookSlowTime  int      `cfg:"webhook-slow-command-threshold"` // milliseconds, 0 disables slow command events
+
+	// slow log, same as redis
+	// commands took at least slowlog-log-slower-than microseconds are logged, negative disables slow log, 0 logs every command
+	SlowlogLogSlowerThan int `cfg:"slowlog-log-slower-than"` // default 10000
+	SlowlogMaxLen        int `cfg:"slowlog-max-len"`         // default 128
+
+	// one of every hotkeys-sample-rate key accesses is sampled to find the hottest keys by HOTKEYS, 0 disables sampling
+	// access counts are approximate and kept within a sliding window of hotkeys-window seconds
+	HotKeysSampleRate int `cfg:"hotkeys-sample-rate"`
+	HotKeysWindow     int `cfg:"hotkeys-window"` // default 60
+
+	// key eviction policy, same as redis: noeviction, allkeys-lru, volatile-lru, allkeys-lfu, volatile-lfu ...
+	// access metadata of keys is only maintained by lru and lfu policies
+	MaxMemoryPolicy string `cfg:"maxmemory-policy"`
+	// tuning of LFU counter, same as redis
+	// lfu-log-factor controls how hard the counter grows, larger is harder, default 10
+	// lfu-decay-time is minutes after which the counter decreases by one, 0 means never decay, default 1
+	LfuLogFactor int `cfg:"lfu-log-factor"`
+	LfuDecayTime int `cfg:"lfu-decay-time"`
+
+	// for reproducing bugs, non-zero debug-seed seeds the global random generator and enables deterministic mode,
+	// see lib/random. It makes random key selection slow, do not use it in production
+	DebugSeed int `cfg:"debug-seed"`
+
+	// initial shard count of concurrent dicts in each db, must be power of two, 0 means default
+	// shards of data dict are also the stripes of key locks, fewer shards use less memory but cause more lock contention
+	DataDictShards    int `cfg:"data-dict-shards"`    // default 65536
+	TTLDictShards     int `cfg:"ttl-dict-shards"`     // default 1024
+	VersionDictShards int `cfg:"version-dict-shards"` // default same as data-dict-shards
+	// stripe count of other key locks such as locks of pubsub channels, must be power of two, 0 means default 16
+	LockStripes int `cfg:"lock-stripes"`
+	// hash function choosing shards of string keys, "fnv"(default) or "siphash"
+	// siphash is slower but resists hash flooding by crafted keys, see datastruct/dict/hash.go
+	HashFunction string `cfg:"hash-function"`
+	// seed of hash-function, 0 means random seed generated at startup. fix it only for reproducing bugs
+	HashSeed int `cfg:"hash-seed"`
+
+	// string values not shorter than value-compression-threshold bytes are stored compressed, 0 disables compression
+	// useful for caching large json or html blobs, costs cpu on every read and write
+	ValueCompressionThreshold int `cfg:"value-compression-threshold"`
+	// relative ttls set by EX, PX, SETEX, EXPIRE and so on are randomly extended by up to ttl-jitter percent,
+	// to spread expiration of keys written at the same time. 0 disables jitter, SET and EXPIRE accept JITTER option to override it
+	TTLJitter int `cfg:"ttl-jitter"`
+	// seconds keys removed by DEL and FLUSHDB are kept in trash before freed, 0 disables trash
+	// trashed keys can be restored by UNDELETE, they are not persisted and still take memory until purged
+	// for development environments only, do not use it in production
+	TrashTTL int `cfg:"trash-ttl"`
+	// classes of keyspace events published to __keyspace@<db>__ and __keyevent@<db>__ channels, same letters as redis, empty disables notification
+	// only generic events (g) of DEL, EXPIRE family, PERSIST, RENAME, COPY and implicit deletion of empty collections are published at present
+	NotifyKeyspaceEvents string `cfg:"notify-keyspace-events"`
+	// string values of integers in [0, 10000) share one allocation, same as redis shared integers, default yes
+	SharedIntegers bool `cfg:"shared-integers"`
+
+	// small hashes, lists and zsets are encoded as compact listpack, same as redis
+	// they are converted to full structure once having more than max-listpack-entries elements
+	// or an element longer than max-listpack-value bytes, 0 entries disables listpack
+	HashMaxListpackEntries int `cfg:"hash-max-listpack-entries"` // default 128
+	HashMaxListpackValue   int `cfg:"hash-max-listpack-value"`   // default 64
+	ListMaxListpackEntries int `cfg:"list-max-listpack-entries"` // default 128
+	ListMaxListpackValue   int `cfg:"list-max-listpack-value"`   // default 64
+	ZSetMaxListpackEntries int `cfg:"zset-max-listpack-entries"` // default 128
+	ZSetMaxListpackValue   int `cfg:"zset-max-listpack-value"`   // default 64
+
+	// for cluster mode configuration
+	ClusterEnabled string   `cfg:"cluster-enabled"` // Not used at present.
+	Peers          []string `cfg:"peers"`
+	Self           string   `cfg:"self"`
+
+	// config file path
+	CfPath string `cfg:"cf,omitempty"`
+}
+
+type ServerInfo struct {
+	StartUpTime time.Time
+}
+
+func (p *ServerProperties) AnnounceAddress() string {
+	return p.AnnounceHost + ":" + strconv.Itoa(p.Port)
+}
+
+// Properties holds global config properties
+var Properties *ServerProperties
+var EachTimeServerInfo *ServerInfo
+
+func init() {
+	// A few stats we don't want to reset: server startup time, and peak mem.
+	EachTimeServerInfo = &ServerInfo{
+		StartUpTime: clock.Now(),
+	}
+
+	// default config
+	Properties = &ServerProperties{
+		Bind:       "127.0.0.1",
+		Port:       6379,
+		AppendOnly: false,
+		RunID:      utils.RandString(40),
+	}
+	Properties.setDefaults()
+}
+
+// setDefaults 填入零值是合法取值的配置项的默认值，这些配置项不能用零值表示未配置
+func (p *ServerProperties) setDefaults() {
+	p.LfuLogFactor = defaultLfuLogFactor
+	p.LfuDecayTime = defaultLfuDecayTime
+	p.SlowlogLogSlowerThan = defaultSlowlogLogSlowerThan
+	p.SlowlogMaxLen = defaultSlowlogMaxLen
+	p.AofMaxWriteErrors = defaultAofMaxWriteErrors
+	p.SharedIntegers = true
+	p.HashMaxListpackEntries = defaultListpackEntries
+	p.HashMaxListpackValue = defaultListpackValue
+	p.ListMaxListpackEntries = defaultListpackEntries
+	p.ListMaxListpackValue = defaultListpackValue
+	p.ZSetMaxListpackEntries = defaultListpackEntries
+	p.ZSetMaxListpackValue = defaultListpackValue
+}
+
+// readRawConfig reads key value pairs of config file, keys are in lower case
+func readRawConfig(src io.Reader) (map[string]string, error) {
+	rawMap := make(map[string]string)
+	scanner := bufio.NewScanner(src)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) > 0 && strings.TrimLeft(line, " ")[0] == '#' {
+			continue
+		}
+		pivot := strings.IndexAny(line, " ")
+		if pivot > 0 && pivot < len(line)-1 { // separator found
+			key := line[0:pivot]
+			value := strings.Trim(line[pivot+1:], " ")
+			rawMap[strings.ToLower(key)] = value
+		}
+	}
+	return rawMap, scanner.Err()
+}
+
+// configKey returns the key of field in config file
+func configKey(field reflect.StructField) string {
+	key, ok := field.Tag.Lookup("cfg")
+	if !ok || strings.TrimLeft(key, " ") == "" {
+		key = field.Name
+	}
+	return strings.ToLower(key)
+}
+
+// UnknownKeys returns keys in config file which are not any property, parse ignores them silently
+func UnknownKeys(src io.Reader) ([]string, error) {
+	rawMap, err := readRawConfig(src)
+	if err != nil {
+		return nil, err
+	}
+	t := reflect.TypeOf(ServerProperties{})
+	for i := 0; i < t.NumField(); i++ {
+		delete(rawMap, configKey(t.Field(i)))
+	}
+	keys := make([]string, 0, len(rawMap))
+	for key := range rawMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func parse(src io.Reader) *ServerProperties {
+	// 0 是 lfu-decay-time 等配置项的合法取值，不能用零值表示未配置，因此解析前先填入默认值
+	config := &ServerProperties{}
+	config.setDefaults()
+
+	// read config file
+	rawMap, err := readRawConfig(src)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	// parse format
+	t := reflect.TypeOf(config)
+	v := reflect.ValueOf(config)
+	n := t.Elem().NumField()
+	for i := 0; i < n; i++ {
+		field := t.Elem().Field(i)
+		fieldVal := v.Elem().Field(i)
+		value, ok := rawMap[configKey(field)]
+		if ok {
+			// fill config
+			switch field.Type.Kind() {
+			case reflect.String:
+				fieldVal.SetString(value)
+			case reflect.Int:
+				intValue, err := strconv.ParseInt(value, 10, 64)
+				if err == nil {
+					fieldVal.SetInt(intValue)
+				}
+			case reflect.Bool:
+				boolValue := "yes" == value
+				fieldVal.SetBool(boolValue)
+			case reflect.Slice:
+				if field.Type.Elem().Kind() == reflect.String {
+					slice := strings.Split(value, ",")
+					fieldVal.Set(reflect.ValueOf(slice))
+				}
+			}
+		}
+	}
+	return config
+}
+
+// SetupConfig read config file and store properties into Properties
+func SetupConfig(configFilename string) {
+	properties, err := LoadConfig(configFilename)
+	if err != nil {
+		panic(err)
+	}
+	Properties = properties
+}
+
+// LoadConfig reads and validates config file, paths in the returned properties are resolved
+func LoadConfig(configFilename string) (*ServerProperties, error) {
+	file, err := os.Open(configFilename)
+	if err != nil {
+		return nil, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+	properties := parse(file)
+	if err := properties.Validate(); err != nil {
+		return nil, err
+	}
+	properties.RunID = utils.RandString(40)
+	configFilePath, err := filepath.Abs(configFilename)
+	if err != nil {
+		return properties, nil
+	}
+	properties.CfPath = configFilePath
+	properties.ResolvePaths()
+	return properties, nil
+}
+
+// Validate checks properties which cannot be used as configured
+func (p *ServerProperties) Validate() error {
+	shards := []struct {
+		name  string
+		value int
+	}{
+		{"data-dict-shards", p.DataDictShards},
+		{"ttl-dict-shards", p.TTLDictShards},
+		{"version-dict-shards", p.VersionDictShards},
+		{"lock-stripes", p.LockStripes},
+	}
+	for _, item := range shards {
+		// 分段数通过位运算取模，必须是2的幂
+		if item.value < 0 || item.value&(item.value-1) != 0 {
+			return fmt.Errorf("%s must be a power of two, got %d", item.name, item.value)
+		}
+	}
+	if p.HashFunction != "" && p.HashFunction != "fnv" && p.HashFunction != "siphash" {
+		return fmt.Errorf("hash-function must be fnv or siphash, got %s", p.HashFunction)
+	}
+	if p.LfuLogFactor < 0 {
+		return fmt.Errorf("lfu-log-factor must not be negative, got %d", p.LfuLogFactor)
+	}
+	if p.LfuDecayTime < 0 {
+		return fmt.Errorf("lfu-decay-time must not be negative, got %d", p.LfuDecayTime)
+	}
+	listpacks := []struct {
+		name  string
+		value int
+	}{
+		{"hash-max-listpack-entries", p.HashMaxListpackEntries},
+		{"hash-max-listpack-value", p.HashMaxListpackValue},
+		{"list-max-listpack-entries", p.ListMaxListpackEntries},
+		{"list-max-listpack-value", p.ListMaxListpackValue},
+		{"zset-max-listpack-entries", p.ZSetMaxListpackEntries},
+		{"zset-max-listpack-value", p.ZSetMaxListpackValue},
+	}
+	for _, item := range listpacks {
+		if item.value < 0 {
+			return fmt.Errorf("%s must not be negative, got %d", item.name, item.value)
+		}
+	}
+	if p.SlowlogMaxLen < 0 {
+		return fmt.Errorf("slowlog-max-len must not be negative, got %d", p.SlowlogMaxLen)
+	}
+	if p.AofMaxWriteErrors < 0 {
+		return fmt.Errorf("aof-max-write-errors must not be negative, got %d", p.AofMaxWriteErrors)
+	}
+	if p.MaxAofQueue < 0 {
+		return fmt.Errorf("max-aof-queue must not be negative, got %d", p.MaxAofQueue)
+	}
+	if p.MaxJobQueue < 0 {
+		return fmt.Errorf("max-job-queue must not be negative, got %d", p.MaxJobQueue)
+	}
+	if p.MaxOutputBuffer < 0 {
+		return fmt.Errorf("max-output-buffer must not be negative, got %d", p.MaxOutputBuffer)
+	}
+	if p.AdminPass != "" && p.AdminPass == p.RequirePass {
+		return fmt.Errorf("adminpass must differ from requirepass")
+	}
+	if p.HotKeysSampleRate < 0 {
+		return fmt.Errorf("hotkeys-sample-rate must not be negative, got %d", p.HotKeysSampleRate)
+	}
+	if p.HotKeysWindow < 0 {
+		return fmt.Errorf("hotkeys-window must not be negative, got %d", p.HotKeysWindow)
+	}
+	if p.TTLJitter < 0 || p.TTLJitter > 100 {
+		return fmt.Errorf("ttl-jitter must be between 0 and 100, got %d", p.TTLJitter)
+	}
+	if p.TrashTTL < 0 {
+		return fmt.Errorf("trash-ttl must not be negative, got %d", p.TrashTTL)
+	}
+	if p.ValueCompressionThreshold < 0 {
+		return fmt.Errorf("value-compression-threshold must not be negative, got %d", p.ValueCompressionThreshold)
+	}
+	for _, c := range p.NotifyKeyspaceEvents {
+		if !strings.ContainsRune(notifyKeyspaceEventLetters, c) {
+			return fmt.Errorf("illegal class %q of notify-keyspace-events", c)
+		}
+	}
+	return nil
+}
+
+// notifyKeyspaceEventLetters are legal letters of notify-keyspace-events, same as redis
+const notifyKeyspaceEventLetters = "KEg$lshzxetmdnA"
+
+// ResolvePaths makes Dir absolute and resolves data files relative to it,
+// so that server never depends on its working directory
+func (p *ServerProperties) ResolvePaths() {
+	if p.Dir == "" {
+		p.Dir = "."
+	}
+	if dir, err := filepath.Abs(p.Dir); err == nil {
+		p.Dir = dir
+	}
+	p.AppendFilename = p.resolve(p.AppendFilename)
+	p.RDBFilename = p.resolve(p.RDBFilename)
+	p.ClusterConfigFile = p.resolve(p.ClusterConfigFile)
+}
+
+// resolve returns absolute path of name within Dir, empty name means not configured
+func (p *ServerProperties) resolve(name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(p.Dir, name)
+}
+
+// GetDataPath returns absolute path of the given file within data dir
+func GetDataPath(name string) string {
+	return Properties.resolve(name)
+}
+
+func GetTmpDir() string {
+	return filepath.Join(Properties.Dir, "tmp")
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"Godis/interface/database"
+	"Godis/interface/redis"
+	"Godis/internal/config"
+	"Godis/lib/clock"
+	"Godis/lib/random"
+	"Godis/redis/protocol"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// key的访问元数据，保存在 DataEntity.Access 中，格式与 Redis 的 robj.lru 相同:
+//   LRU 策略下为最近一次访问的时间，单位为秒
+//   LFU 策略下高16位为最近一次衰减的时间，单位为分钟，低8位为按对数增长的访问计数
+// 与 Redis 一样只是近似值: LRU 时钟精度为1秒，同一秒内重复访问不会写入；
+// LFU 计数按概率增长，计数越大增长的概率越小，8位即可表示百万级的访问次数
+// 未配置 LRU/LFU 淘汰策略时不维护，读命令不会写入共享的 DataEntity
+
+type accessMode uint8
+
+const (
+	accessNone accessMode = iota
+	accessLRU
+	accessLFU
+)
+
+// lfuInitVal 新写入的key的初始计数，避免刚写入的key立即被淘汰
+const lfuInitVal = 5
+
+// 计数增长的难度以及衰减的周期由 lfu-log-factor 和 lfu-decay-time 配置，与 Redis 相同:
+//   lfu-log-factor 越大计数增长越慢，为10时约一百万次访问使计数达到255
+//   lfu-decay-time 为计数每经过多少分钟减一，为0时不衰减
+// 每次访问时读取配置，修改配置后对已有的key立即生效
+
+// parseAccessMode 根据 maxmemory-policy 决定需要维护的访问元数据
+func parseAccessMode(policy string) accessMode {
+	switch strings.ToLower(policy) {
+	case "allkeys-lru", "volatile-lru":
+		return accessLRU
+	case "allkeys-lfu", "volatile-lfu":
+		return accessLFU
+	}
+	return accessNone
+}
+
+// lruClock 秒级时钟，溢出后回绕
+func lruClock() uint32 {
+	return uint32(clock.Now().Unix())
+}
+
+// lfuClock 分钟级时钟，只保留低16位
+func lfuClock() uint32 {
+	return uint32(clock.Now().Unix()/60) & 0xffff
+}
+
+// initAccess 初始化新写入的key的访问元数据
+func (db *DB) initAccess(entity *database.DataEntity) {
+	switch db.accessMode {
+	case accessLRU:
+		atomic.StoreUint32(&entity.Access, lruClock())
+	case accessLFU:
+		atomic.StoreUint32(&entity.Access, lfuClock()<<8|lfuInitVal)
+	}
+}
+
+// touch 记录一次访问
+// 多个读命令可能并发访问同一个key，丢失个别更新不影响近似的结果，因此直接写入而不使用CAS
+func (db *DB) touch(entity *database.DataEntity) {
+	switch db.accessMode {
+	case accessLRU:
+		now := lruClock()
+		if atomic.LoadUint32(&entity.Access) != now {
+			atomic.StoreUint32(&entity.Access, now)
+		}
+	case accessLFU:
+		old := atomic.LoadUint32(&entity.Access)
+		counter := lfuLogIncr(lfuDecr(old))
+		access := lfuClock()<<8 | uint32(counter)
+		if access != old {
+			atomic.StoreUint32(&entity.Access, access)
+		}
+	}
+}
+
+// lfuDecr 返回按经过的时间衰减后的计数
+func lfuDecr(access uint32) uint8 {
+	lastDecr := access >> 8
+	counter := uint8(access & 0xff)
+	decayTime := uint32(config.Properties.LfuDecayTime)
+	if decayTime == 0 {
+		return counter
+	}
+	now := lfuClock()
+	var elapsed uint32
+	if now >= lastDecr {
+		elapsed = now - lastDecr
+	} else {
+		// 分钟时钟回绕
+		elapsed = 0xffff - lastDecr + now
+	}
+	periods := elapsed / decayTime
+	if periods >= uint32(counter) {
+		return 0
+	}
+	return counter - uint8(periods)
+}
+
+// lfuLogIncr 以 1/((counter-lfuInitVal)*lfu-log-factor+1) 的概率将计数加一
+func lfuLogIncr(counter uint8) uint8 {
+	if counter == 255 {
+		return 255
+	}
+	base := float64(counter) - lfuInitVal
+	if base < 0 {
+		base = 0
+	}
+	if random.Float64() < 1.0/(base*float64(config.Properties.LfuLogFactor)+1) {
+		counter++
+	}
+	return counter
+}
+
+// idleTime 返回key自最近一次访问以来的时间，仅在 LRU 策略下有意义
+func idleTime(entity *database.DataEntity) time.Duration {
+	last := atomic.LoadUint32(&entity.Access)
+	// uint32 的减法自动处理时钟回绕
+	return time.Duration(lruClock()-last) * time.Second
+}
+
+// accessFreq 返回key衰减后的访问计数，仅在 LFU 策略下有意义
+func accessFreq(entity *database.DataEntity) uint8 {
+	return lfuDecr(atomic.LoadUint32(&entity.Access))
+}
+
+// execObject inspects internals of a key, supports ENCODING, IDLETIME and FREQ
+func execObject(db *DB, args [][]byte) redis.Reply {
+	subCmd := strings.ToLower(string(args[0]))
+	if len(args) != 2 {
+		return protocol.MakeErrReply("ERR unknown subcommand or wrong number of arguments for '" + subCmd + "'")
+	}
+	entity, exists := db.peekEntity(string(args[1]))
+	if !exists {
+		return protocol.MakeNullBulkReply()
+	}
+	switch subCmd {
+	case "encoding":
+		return protocol.MakeBulkReply([]byte(entity.Encoding()))
+	case "idletime":
+		if db.accessMode != accessLRU {
+			return protocol.MakeErrReply("ERR An LRU maxmemory policy is not selected, idle time not tracked.")
+		}
+		return protocol.MakeIntReply(int64(idleTime(entity) / time.Second))
+	case "freq":
+		if db.accessMode != accessLFU {
+			return protocol.MakeErrReply("ERR An LFU maxmemory policy is not selected, access frequency not tracked.")
+		}
+		return protocol.MakeIntReply(int64(accessFreq(entity)))
+	}
+	return protocol.MakeErrReply("ERR unknown subcommand '" + subCmd + "'")
+}
+
+func prepareObject(args [][]byte) ([]string, []string) {
+	if len(args) < 2 {
+		return nil, nil
+	}
+	return nil, []string{string(args[1])}
+}
+
+func init() {
+	registerCommand("Object", execObject, prepareObject, nil, -3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly}, 2, 2, 1)
+}
@@ -0,0 +1,257 @@
+package database
+
+import (
+	"Godis/datastruct/bloom"
+	"Godis/interface/database"
+	"Godis/interface/redis"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"strconv"
+	"strings"
+)
+
+func (db *DB) getAsBloom(key string) (*bloom.Filter, protocol.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	filter, ok := entity.Data.(*bloom.Filter)
+	if !ok {
+		return nil, &protocol.WrongTypeErrReply{}
+	}
+	return filter, nil
+}
+
+// getOrInitBloom creates a filter with default options if key not exists, same as RedisBloom
+func (db *DB) getOrInitBloom(key string) (*bloom.Filter, protocol.ErrorReply) {
+	filter, errReply := db.getAsBloom(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if filter == nil {
+		filter = bloom.MakeDefault()
+		db.PutEntity(key, &database.DataEntity{
+			Data: filter,
+			Type: database.TypeBloom,
+		})
+	}
+	return filter, nil
+}
+
+// execBFReserve creates an empty filter: BF.RESERVE key error_rate capacity [EXPANSION expansion] [NONSCALING]
+func execBFReserve(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	errorRate, err := strconv.ParseFloat(string(args[1]), 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR bad error rate")
+	}
+	if errorRate <= 0 || errorRate >= 1 {
+		return protocol.MakeErrReply("ERR (0 < error rate range < 1)")
+	}
+	capacity, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR bad capacity")
+	}
+	if capacity <= 0 {
+		return protocol.MakeErrReply("ERR (capacity should be larger than 0)")
+	}
+	expansion := int64(bloom.DefaultExpansion)
+	nonScaling := false
+	for i := 3; i < len(args); i++ {
+		arg := strings.ToUpper(string(args[i]))
+		if arg == "NONSCALING" {
+			nonScaling = true
+		} else if arg == "EXPANSION" && i+1 < len(args) {
+			i++
+			expansion, err = strconv.ParseInt(string(args[i]), 10, 32)
+			if err != nil {
+				return protocol.MakeErrReply("ERR bad expansion")
+			}
+			if expansion < 1 {
+				return protocol.MakeErrReply("ERR expansion should be greater or equal to 1")
+			}
+		} else {
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+	if _, exists := db.GetEntity(key); exists {
+		return protocol.MakeErrReply("ERR item exists")
+	}
+	db.PutEntity(key, &database.DataEntity{
+		Data: bloom.New(uint64(capacity), errorRate, uint32(expansion), nonScaling),
+		Type: database.TypeBloom,
+	})
+	db.addAof(utils.ToCmdLine3("bf.reserve", args...))
+	return protocol.MakeOkReply()
+}
+
+// execBFAdd adds an item into filter, returns 1 if the item did not exist
+func execBFAdd(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	filter, errReply := db.getOrInitBloom(key)
+	if errReply != nil {
+		return errReply
+	}
+	added, err := filter.Add(args[1])
+	if err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	if !added {
+		return protocol.MakeIntReply(0)
+	}
+	db.addAof(utils.ToCmdLine3("bf.add", args...))
+	return protocol.MakeIntReply(1)
+}
+
+// execBFExists checks whether an item may exist in filter
+func execBFExists(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	filter, errReply := db.getAsBloom(key)
+	if errReply != nil {
+		return errReply
+	}
+	if filter == nil || !filter.Exists(args[1]) {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(1)
+}
+
+// execBFMAdd adds items into filter, returns 1 for each item did not exist
+// 非扩展的过滤器写满后，剩余的元素返回错误
+func execBFMAdd(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	filter, errReply := db.getOrInitBloom(key)
+	if errReply != nil {
+		return errReply
+	}
+	replies := make([]redis.Reply, 0, len(args)-1)
+	anyAdded := false
+	for _, item := range args[1:] {
+		added, err := filter.Add(item)
+		if err != nil {
+			replies = append(replies, protocol.MakeErrReply(err.Error()))
+		} else if added {
+			anyAdded = true
+			replies = append(replies, protocol.MakeIntReply(1))
+		} else {
+			replies = append(replies, protocol.MakeIntReply(0))
+		}
+	}
+	if anyAdded {
+		db.addAof(utils.ToCmdLine3("bf.madd", args...))
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+// execBFMExists checks whether each item may exist in filter
+func execBFMExists(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	filter, errReply := db.getAsBloom(key)
+	if errReply != nil {
+		return errReply
+	}
+	replies := make([]redis.Reply, len(args)-1)
+	for i, item := range args[1:] {
+		if filter != nil && filter.Exists(item) {
+			replies[i] = protocol.MakeIntReply(1)
+		} else {
+			replies[i] = protocol.MakeIntReply(0)
+		}
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+// execBFCard returns number of items added into filter
+func execBFCard(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	filter, errReply := db.getAsBloom(key)
+	if errReply != nil {
+		return errReply
+	}
+	if filter == nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(int64(filter.Len()))
+}
+
+// execBFInfo returns information of filter: BF.INFO key [CAPACITY | SIZE | FILTERS | ITEMS | EXPANSION]
+func execBFInfo(db *DB, args [][]byte) redis.Reply {
+	if len(args) > 2 {
+		return protocol.MakeArgNumErrReply("bf.info")
+	}
+	key := string(args[0])
+	filter, errReply := db.getAsBloom(key)
+	if errReply != nil {
+		return errReply
+	}
+	if filter == nil {
+		return protocol.MakeErrReply("ERR not found")
+	}
+	var expansion redis.Reply = protocol.MakeNullBulkReply()
+	if filter.Expansion() > 0 {
+		expansion = protocol.MakeIntReply(int64(filter.Expansion()))
+	}
+	items := []struct {
+		option string
+		name   string
+		value  redis.Reply
+	}{
+		{"CAPACITY", "Capacity", protocol.MakeIntReply(int64(filter.Capacity()))},
+		{"SIZE", "Size", protocol.MakeIntReply(int64(filter.Size()))},
+		{"FILTERS", "Number of filters", protocol.MakeIntReply(int64(filter.Filters()))},
+		{"ITEMS", "Number of items inserted", protocol.MakeIntReply(int64(filter.Len()))},
+		{"EXPANSION", "Expansion rate", expansion},
+	}
+	if len(args) == 2 {
+		option := strings.ToUpper(string(args[1]))
+		for _, item := range items {
+			if item.option == option {
+				return protocol.MakeMultiRawReply([]redis.Reply{item.value})
+			}
+		}
+		return protocol.MakeErrReply("ERR Invalid information value")
+	}
+	replies := make([]redis.Reply, 0, len(items)*2)
+	for _, item := range items {
+		replies = append(replies, protocol.MakeBulkReply([]byte(item.name)), item.value)
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+// execBFLoadChunk restores a filter: BF.LOADCHUNK key iterator data
+// Godis 把整个过滤器保存在一个分块中，用于 AOF 重写和事务回滚，与 RedisBloom 的分块格式不兼容
+func execBFLoadChunk(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	if _, err := strconv.ParseInt(string(args[1]), 10, 64); err != nil {
+		return protocol.MakeErrReply("ERR invalid iterator")
+	}
+	filter, err := bloom.Parse(args[2])
+	if err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	db.PutEntity(key, &database.DataEntity{
+		Data: filter,
+		Type: database.TypeBloom,
+	})
+	db.addAof(utils.ToCmdLine3("bf.loadchunk", args...))
+	return protocol.MakeOkReply()
+}
+
+func init() {
+	registerCommand("BF.Reserve", execBFReserve, writeFirstKey, rollbackFirstKey, -4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, 1, 1)
+	registerCommand("BF.Add", execBFAdd, writeFirstKey, rollbackFirstKey, 3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
+	registerCommand("BF.Exists", execBFExists, readFirstKey, nil, 3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("BF.MAdd", execBFMAdd, writeFirstKey, rollbackFirstKey, -3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, 1, 1)
+	registerCommand("BF.MExists", execBFMExists, readFirstKey, nil, -3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("BF.Card", execBFCard, readFirstKey, nil, 2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("BF.Info", execBFInfo, readFirstKey, nil, -2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("BF.LoadChunk", execBFLoadChunk, writeFirstKey, rollbackFirstKey, 4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, 1, 1)
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Godis/interface/redis"
+	"Godis/lib/clock"
+	"Godis/redis/protocol"
+)
+
+// clientRegistry tracks network clients for CLIENT LIST
+// clients are registered on their first command and removed in AfterClientClose,
+// FakeConn used inside server has no remote address and is never registered
+type clientRegistry struct {
+	// redis.Connection -> *clientInfo
+	clients sync.Map
+	nextID  int64
+}
+
+type clientInfo struct {
+	id      int64
+	addr    string
+	created time.Time
+
+	mu         sync.Mutex
+	lastActive time.Time
+	lastCmd    string
+}
+
+func makeClientRegistry() *clientRegistry {
+	return &clientRegistry{}
+}
+
+// touch records the command sent by c
+func (registry *clientRegistry) touch(c redis.Connection, cmdName string) {
+	if registry == nil || c == nil {
+		return
+	}
+	addr := c.RemoteAddr()
+	if addr == "" {
+		return
+	}
+	now := clock.Now()
+	raw, ok := registry.clients.Load(c)
+	if !ok {
+		raw, _ = registry.clients.LoadOrStore(c, &clientInfo{
+			id:      atomic.AddInt64(&registry.nextID, 1),
+			addr:    addr,
+			created: now,
+		})
+	}
+	info := raw.(*clientInfo)
+	info.mu.Lock()
+	info.lastActive = now
+	info.lastCmd = cmdName
+	info.mu.Unlock()
+}
+
+func (registry *clientRegistry) remove(c redis.Connection) {
+	if registry == nil || c == nil {
+		return
+	}
+	registry.clients.Delete(c)
+}
+
+// list returns lines of CLIENT LIST ordered by client id
+func (registry *clientRegistry) list() string {
+	type row struct {
+		id   int64
+		line string
+	}
+	now := clock.Now()
+	var rows []row
+	registry.clients.Range(func(key, value any) bool {
+		c := key.(redis.Connection)
+		info := value.(*clientInfo)
+		info.mu.Lock()
+		idle := now.Sub(info.lastActive)
+		cmd := info.lastCmd
+		info.mu.Unlock()
+		multi := -1
+		if c.InMultiState() {
+			multi = len(c.GetQueuedCmdLine())
+		}
+		line := fmt.Sprintf("id=%d addr=%s age=%d idle=%d db=%d sub=%d multi=%d cmd=%s",
+			info.id, info.addr, int64(now.Sub(info.created).Seconds()), int64(idle.Seconds()),
+			c.GetDBIndex(), c.SubsCount(), multi, cmd)
+		rows = append(rows, row{id: info.id, line: line})
+		return true
+	})
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].id < rows[j].id
+	})
+	var sb strings.Builder
+	for _, r := range rows {
+		sb.WriteString(r.line)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// execClient implements CLIENT LIST | ID | HELP
+func (server *Server) execClient(c redis.Connection, args [][]byte) redis.Reply {
+	subCommand := strings.ToLower(string(args[0]))
+	switch subCommand {
+	case "list":
+		if len(args) != 1 {
+			return protocol.MakeArgNumErrReply("client|list")
+		}
+		return protocol.MakeBulkReply([]byte(server.clients.list()))
+	case "id":
+		if len(args) != 1 {
+			return protocol.MakeArgNumErrReply("client|id")
+		}
+		if raw, ok := server.clients.clients.Load(c); ok {
+			return protocol.MakeIntReply(raw.(*clientInfo).id)
+		}
+		return protocol.MakeIntReply(0)
+	case "help":
+		return protocol.MakeMultiBulkReply([][]byte{
+			[]byte("CLIENT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:"),
+			[]byte("ID"),
+			[]byte("    Return the ID of the current connection, 0 for internal connections."),
+			[]byte("LIST"),
+			[]byte("    Return information about client connections."),
+		})
+	}
+	return protocol.MakeErrReply("ERR unknown subcommand '" + subCommand + "'. Try CLIENT HELP.")
+}
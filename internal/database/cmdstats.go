@@ -0,0 +1,72 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"Godis/interface/redis"
+	"Godis/redis/protocol"
+)
+
+// commandStats counts calls and execution time of each command, shown in INFO commandstats
+type commandStats struct {
+	// name -> *commandStat
+	stats sync.Map
+}
+
+type commandStat struct {
+	calls       int64
+	usec        int64
+	failedCalls int64
+}
+
+func makeCommandStats() *commandStats {
+	return &commandStats{}
+}
+
+// record counts a finished command, unknown commands are not counted like redis
+func (cs *commandStats) record(name string, cost time.Duration, reply redis.Reply) {
+	if cs == nil {
+		return
+	}
+	if _, ok := cmdTable[name]; !ok {
+		return
+	}
+	raw, ok := cs.stats.Load(name)
+	if !ok {
+		raw, _ = cs.stats.LoadOrStore(name, &commandStat{})
+	}
+	stat := raw.(*commandStat)
+	atomic.AddInt64(&stat.calls, 1)
+	atomic.AddInt64(&stat.usec, cost.Microseconds())
+	if reply != nil && protocol.IsErrorReply(reply) {
+		atomic.AddInt64(&stat.failedCalls, 1)
+	}
+}
+
+// info returns lines of INFO commandstats sorted by command name
+func (cs *commandStats) info() string {
+	var names []string
+	cs.stats.Range(func(key, value any) bool {
+		names = append(names, key.(string))
+		return true
+	})
+	sort.Strings(names)
+	s := "# Commandstats\r\n"
+	for _, name := range names {
+		raw, _ := cs.stats.Load(name)
+		stat := raw.(*commandStat)
+		calls := atomic.LoadInt64(&stat.calls)
+		usec := atomic.LoadInt64(&stat.usec)
+		perCall := 0.0
+		if calls > 0 {
+			perCall = float64(usec) / float64(calls)
+		}
+		s += fmt.Sprintf("cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,failed_calls=%d\r\n",
+			name, calls, usec, perCall, atomic.LoadInt64(&stat.failedCalls))
+	}
+	return s
+}
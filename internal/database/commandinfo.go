@@ -94,6 +94,10 @@ func init() {
 		attachCommandExtra([]string{redisFlagNoScript, redisFlagLoading, redisFlagStale, redisFlagSkipMonitor, redisFlagFast}, 0, 0, 0)
 	registerSpecialCommand("Info", -1, 0).
 		attachCommandExtra([]string{redisFlagRandom, redisFlagLoading, redisFlagStale}, 0, 0, 0)
+	registerSpecialCommand("SlowLog", -2, 0).
+		attachCommandExtra([]string{redisFlagAdmin, redisFlagRandom, redisFlagLoading, redisFlagStale}, 0, 0, 0)
+	registerSpecialCommand("Client", -2, 0).
+		attachCommandExtra([]string{redisFlagAdmin, redisFlagNoScript, redisFlagRandom, redisFlagLoading, redisFlagStale}, 0, 0, 0)
 	registerSpecialCommand("SlaveOf", 3, 0).
 		attachCommandExtra([]string{redisFlagAdmin, redisFlagNoScript, redisFlagStale}, 0, 0, 0)
 	registerSpecialCommand("Subscribe", -2, 0).
@@ -110,6 +114,12 @@ func init() {
 		attachCommandExtra([]string{redisFlagAdmin, redisFlagNoScript}, 0, 0, 0)
 	registerSpecialCommand("Select", 2, 0).
 		attachCommandExtra([]string{redisFlagLoading, redisFlagFast}, 0, 0, 0)
+	registerSpecialCommand("Role", 1, 0).
+		attachCommandExtra([]string{redisFlagNoScript, redisFlagLoading, redisFlagStale, redisFlagFast}, 0, 0, 0)
+	registerSpecialCommand("ReadAfter", 3, 0).
+		attachCommandExtra([]string{redisFlagNoScript, redisFlagStale}, 0, 0, 0)
+	registerSpecialCommand("Debug", -2, 0).
+		attachCommandExtra([]string{redisFlagAdmin, redisFlagNoScript, redisFlagLoading, redisFlagStale}, 0, 0, 0)
 	registerSpecialCommand("ReplConf", -1, 0).
 		attachCommandExtra([]string{redisFlagAdmin, redisFlagNoScript, redisFlagLoading, redisFlagStale}, 0, 0, 0)
 	//attachCommandExtra("ReplConf", 3, []string{redisFlagReadonly, redisFlagAdmin, redisFlagNoScript}, 0, 0, 0, nil)
@@ -0,0 +1,197 @@
+package database
+
+import (
+	"Godis/datastruct/cuckoo"
+	"Godis/interface/database"
+	"Godis/interface/redis"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"strconv"
+	"strings"
+)
+
+func (db *DB) getAsCuckoo(key string) (*cuckoo.Filter, protocol.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	filter, ok := entity.Data.(*cuckoo.Filter)
+	if !ok {
+		return nil, &protocol.WrongTypeErrReply{}
+	}
+	return filter, nil
+}
+
+// getOrInitCuckoo creates a filter with default options if key not exists, same as RedisBloom
+func (db *DB) getOrInitCuckoo(key string) (*cuckoo.Filter, protocol.ErrorReply) {
+	filter, errReply := db.getAsCuckoo(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if filter == nil {
+		filter = cuckoo.MakeDefault()
+		db.PutEntity(key, &database.DataEntity{
+			Data: filter,
+			Type: database.TypeCuckoo,
+		})
+	}
+	return filter, nil
+}
+
+// execCFReserve creates an empty filter: CF.RESERVE key capacity [BUCKETSIZE bucketsize] [MAXITERATIONS maxiterations] [EXPANSION expansion]
+func execCFReserve(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	capacity, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil || capacity <= 0 {
+		return protocol.MakeErrReply("ERR Bad capacity")
+	}
+	bucketSize := int64(cuckoo.DefaultBucketSize)
+	maxIterations := int64(cuckoo.DefaultMaxIterations)
+	expansion := int64(cuckoo.DefaultExpansion)
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return protocol.MakeSyntaxErrReply()
+		}
+		value, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		switch strings.ToUpper(string(args[i])) {
+		case "BUCKETSIZE":
+			if err != nil || value < 1 || value > 255 {
+				return protocol.MakeErrReply("ERR Bad bucket size")
+			}
+			bucketSize = value
+		case "MAXITERATIONS":
+			if err != nil || value < 1 || value > 65535 {
+				return protocol.MakeErrReply("ERR Bad maxIterations")
+			}
+			maxIterations = value
+		case "EXPANSION":
+			if err != nil || value < 0 || value > 32768 {
+				return protocol.MakeErrReply("ERR Bad expansion")
+			}
+			expansion = value
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+	if _, exists := db.GetEntity(key); exists {
+		return protocol.MakeErrReply("ERR item exists")
+	}
+	db.PutEntity(key, &database.DataEntity{
+		Data: cuckoo.New(uint64(capacity), uint8(bucketSize), uint16(maxIterations), uint16(expansion)),
+		Type: database.TypeCuckoo,
+	})
+	db.addAof(utils.ToCmdLine3("cf.reserve", args...))
+	return protocol.MakeOkReply()
+}
+
+// execCFAdd adds an item into filter, the same item could be added more than once
+func execCFAdd(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	filter, errReply := db.getOrInitCuckoo(key)
+	if errReply != nil {
+		return errReply
+	}
+	if err := filter.Add(args[1]); err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	// 踢出指纹时使用了随机数，重放命令得到的过滤器可能与当前不同，但包含的元素相同
+	db.addAof(utils.ToCmdLine3("cf.add", args...))
+	return protocol.MakeIntReply(1)
+}
+
+// execCFAddNX adds an item into filter if it does not exist
+func execCFAddNX(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	filter, errReply := db.getOrInitCuckoo(key)
+	if errReply != nil {
+		return errReply
+	}
+	added, err := filter.AddNX(args[1])
+	if err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	if !added {
+		return protocol.MakeIntReply(0)
+	}
+	db.addAof(utils.ToCmdLine3("cf.addnx", args...))
+	return protocol.MakeIntReply(1)
+}
+
+// execCFExists checks whether an item may exist in filter
+func execCFExists(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	filter, errReply := db.getAsCuckoo(key)
+	if errReply != nil {
+		return errReply
+	}
+	if filter == nil || !filter.Exists(args[1]) {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(1)
+}
+
+// execCFCount returns the times an item may have been added
+func execCFCount(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	filter, errReply := db.getAsCuckoo(key)
+	if errReply != nil {
+		return errReply
+	}
+	if filter == nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(int64(filter.Count(args[1])))
+}
+
+// execCFDel removes one occurrence of an item
+func execCFDel(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	filter, errReply := db.getAsCuckoo(key)
+	if errReply != nil {
+		return errReply
+	}
+	if filter == nil {
+		return protocol.MakeErrReply("ERR not found")
+	}
+	if !filter.Delete(args[1]) {
+		return protocol.MakeIntReply(0)
+	}
+	db.addAof(utils.ToCmdLine3("cf.del", args...))
+	return protocol.MakeIntReply(1)
+}
+
+// execCFLoadChunk restores a filter: CF.LOADCHUNK key iterator data
+// 与 BF.LOADCHUNK 一样，整个过滤器保存在一个分块中
+func execCFLoadChunk(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	if _, err := strconv.ParseInt(string(args[1]), 10, 64); err != nil {
+		return protocol.MakeErrReply("ERR invalid iterator")
+	}
+	filter, err := cuckoo.Parse(args[2])
+	if err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	db.PutEntity(key, &database.DataEntity{
+		Data: filter,
+		Type: database.TypeCuckoo,
+	})
+	db.addAof(utils.ToCmdLine3("cf.loadchunk", args...))
+	return protocol.MakeOkReply()
+}
+
+func init() {
+	registerCommand("CF.Reserve", execCFReserve, writeFirstKey, rollbackFirstKey, -3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, 1, 1)
+	registerCommand("CF.Add", execCFAdd, writeFirstKey, rollbackFirstKey, 3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
+	registerCommand("CF.AddNX", execCFAddNX, writeFirstKey, rollbackFirstKey, 3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
+	registerCommand("CF.Exists", execCFExists, readFirstKey, nil, 3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("CF.Count", execCFCount, readFirstKey, nil, 3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("CF.Del", execCFDel, writeFirstKey, rollbackFirstKey, 3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("CF.LoadChunk", execCFLoadChunk, writeFirstKey, rollbackFirstKey, 4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, 1, 1)
+}
@@ -0,0 +1,527 @@
+package database
+
+import (
+	"Godis/datastruct/dict"
+	"Godis/datastruct/expire"
+	Hash "Godis/datastruct/hash"
+	"Godis/interface/database"
+	"Godis/interface/redis"
+	"Godis/internal/config"
+	"Godis/lib/clock"
+	"Godis/lib/hotkeys"
+	"Godis/lib/webhook"
+	"Godis/redis/protocol"
+	"context"
+	"strings"
+	"time"
+)
+
+// 默认分段数，可以通过 data-dict-shards 等配置项修改
+const (
+	dataDictSize = 1 << 16
+	ttlDictSize  = 1 << 10
+)
+
+type DB struct {
+	index int
+	// key -> DataEntity
+	data *dict.Concurrent[string, *database.DataEntity]
+	// key -> expireTime
+	ttlMap *dict.Concurrent[string, time.Time]
+	// 与 ttlMap 内容相同，按过期时间排序，用于主动过期
+	expireIndex *expire.Index
+	// 设置了字段过期时间的哈希表，按最早过期的字段排序，用于主动过期
+	hashExpireIndex *expire.Index
+	// key -> version
+	versionMap *dict.Concurrent[string, uint32]
+	// accessMode 需要维护的访问元数据，由 maxmemory-policy 决定
+	accessMode accessMode
+	// trash 保存 DEL 和 FLUSHDB 删除的key，见 trash-ttl
+	trash *trashBin
+	// hotKeys 采样统计key的访问次数，所有DB共用，为 nil 表示没有开启
+	hotKeys *hotkeys.Tracker
+	// notifier 发布键空间通知，所有DB共用，为 nil 表示没有开启
+	notifier *keyspaceNotifier
+
+	// addaof is used to add command to aof
+	addAof func(CmdLine)
+
+	// callbacks
+	insertCallback database.KeyEventCallback
+	deleteCallback database.KeyEventCallback
+}
+
+// ExecFunc is interface for command executor
+// args don't include cmd line
+type ExecFunc func(db *DB, args [][]byte) redis.Reply
+
+// PreFunc analyses command line when queued command to `multi`
+// returns related write keys and read keys
+type PreFunc func(args [][]byte) ([]string, []string)
+
+// CmdLine is alias for [][]byte, represents a command line
+type CmdLine = [][]byte
+
+// UndoFunc returns undo logs for the given command line
+// execute from head to tail when undo
+type UndoFunc func(db *DB, args [][]byte) []CmdLine
+
+// 创建一个DB，DB的底层是由ConCurrentDict实现的
+
+func makeDB() *DB {
+	dataShards := shardsOf(config.Properties.DataDictShards, dataDictSize)
+	return &DB{
+		data:            dict.MakeStringKeyed[*database.DataEntity](dataShards),
+		ttlMap:          dict.MakeStringKeyed[time.Time](shardsOf(config.Properties.TTLDictShards, ttlDictSize)),
+		expireIndex:     expire.MakeIndex(),
+		hashExpireIndex: expire.MakeIndex(),
+		versionMap:      dict.MakeStringKeyed[uint32](shardsOf(config.Properties.VersionDictShards, dataShards)),
+		addAof:          func(line CmdLine) {},
+		accessMode:      parseAccessMode(config.Properties.MaxMemoryPolicy),
+		trash:           makeTrashBin(),
+	}
+}
+
+// makeBasicDB create DB instance only with basic abilities.
+func makeBasicDB() *DB {
+	dataShards := shardsOf(config.Properties.DataDictShards, dataDictSize)
+	db := &DB{
+		data:            dict.MakeStringKeyed[*database.DataEntity](dataShards),
+		ttlMap:          dict.MakeStringKeyed[time.Time](shardsOf(config.Properties.TTLDictShards, ttlDictSize)),
+		expireIndex:     expire.MakeIndex(),
+		hashExpireIndex: expire.MakeIndex(),
+		versionMap:      dict.MakeStringKeyed[uint32](shardsOf(config.Properties.VersionDictShards, dataShards)),
+		addAof:          func(line CmdLine) {},
+		accessMode:      parseAccessMode(config.Properties.MaxMemoryPolicy),
+		trash:           makeTrashBin(),
+	}
+	return db
+}
+
+// shardsOf returns configured shard count, or def if not configured
+func shardsOf(configured int, def int) int {
+	if configured > 0 {
+		return configured
+	}
+	return def
+}
+
+// Exec handles the execution of Redis commands. It checks if the given command
+// is a transaction control command or any other command that cannot be executed
+// within a transaction. If it's a transaction control command (e.g., MULTI, DISCARD, EXEC),
+// it performs the corresponding action. If the command is not related to transactions,
+// and the connection is not currently in a MULTI state, it calls execNormalCommand
+// to execute the command in a non-transactional context.
+//
+// Example of a normal transaction:
+//
+//	MULTI
+//	SET key1 value1
+//	INCR key2
+//	EXEC
+//
+// Parameters:
+//   - db: The Redis database instance.
+//   - c: The Redis connection on which the command is executed.
+//   - cmdLine: The command line containing the Redis command and its arguments.
+//
+// Returns:
+//   - redis.Reply: The Redis reply generated as a result of the command execution.
+func (db *DB) Exec(c redis.Connection, cmdLine [][]byte) redis.Reply {
+	// transaction control commands and other commands which cannot execute within transaction
+	// 一个正常的事务例子如下
+	// MULTI
+	// SET key1 value1
+	// INCR key2
+	// EXEC
+	// 先判断cmdLine是否为事务处理，如果是则执行相应的操作，否则调用execNormalCommand
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	if cmdName == "multi" {
+		if len(cmdLine) != 1 {
+			return protocol.MakeArgNumErrReply(cmdName)
+		}
+		return StartMulti(c)
+	} else if cmdName == "discard" {
+		// discard 取消当前的事务，如果事务存在
+		// 只能取消事务，而不能撤销已执行的事务
+		if len(cmdLine) != 1 {
+			return protocol.MakeArgNumErrReply(cmdName)
+		}
+		return DiscardMulti(c)
+	} else if cmdName == "exec" {
+		if len(cmdLine) != 1 {
+			return protocol.MakeArgNumErrReply(cmdName)
+		}
+		return execMulti(db, c)
+	} else if cmdName == "watch" {
+		if !validateArity(-2, cmdLine) {
+			return protocol.MakeArgNumErrReply(cmdName)
+		}
+		return Watch(db, c, cmdLine[1:])
+	}
+	if c != nil && c.InMultiState() {
+		return EnqueueCmd(c, cmdLine)
+	}
+
+	return db.execNormalCommand(cmdLine)
+}
+
+func validateArity(arity int, cmdArgs [][]byte) bool {
+	argNum := len(cmdArgs)
+	// 如果命令的arity为正数，表示只能接收arity个参数；负数则表示可以接收不少于arity个参数
+	if arity >= 0 {
+		return argNum == arity
+	}
+	return argNum >= -arity
+}
+
+func (db *DB) execNormalCommand(cmdLine [][]byte) redis.Reply {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	cmd, ok := cmdTable[cmdName]
+	if !ok {
+		return protocol.MakeErrReply("ERR unknown command '" + cmdName + "'")
+	}
+	if !validateArity(cmd.arity, cmdLine) {
+		return protocol.MakeArgNumErrReply(cmdName)
+	}
+
+	// 调用该命令的准备函数
+	prepare := cmd.prepare
+	write, read := prepare(cmdLine[1:])
+	if db.hotKeys != nil {
+		db.hotKeys.Touch(db.index, write...)
+		db.hotKeys.Touch(db.index, read...)
+	}
+	db.RWLocks(write, read)
+	defer db.RWUnLocks(write, read)
+	fun := cmd.executor
+	result := fun(db, cmdLine[1:])
+	// 执行成功后、释放锁之前更新写入key的版本，WATCH 了这些key的事务在 EXEC 时一定能发现修改
+	// 执行失败的命令没有修改数据，不更新版本
+	if !protocol.IsErrorReply(result) {
+		db.addVersion(write...)
+	}
+	return result
+}
+
+// execWithLock executes normal commands, invoker should provide locks
+func (db *DB) execWithLock(cmdLine [][]byte) redis.Reply {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	cmd, ok := cmdTable[cmdName]
+	if !ok {
+		return protocol.MakeErrReply("ERR unknown command '" + cmdName + "'")
+	}
+	if !validateArity(cmd.arity, cmdLine) {
+		return protocol.MakeArgNumErrReply(cmdName)
+	}
+	fun := cmd.executor
+	return fun(db, cmdLine[1:])
+}
+
+/* ---- Data Access ---- */
+// 这里的方法都是调用了ConcurrentDict中的方法并封装了DB额外的功能
+
+func (db DB) GetEntity(key string) (*database.DataEntity, bool) {
+	// 从db的ConCurrentDict中读取
+	entity, ok := db.data.GetWithLock(key)
+	if !ok {
+		return nil, false
+	}
+	// 检查是否过期
+	if db.IsExpired(key) {
+		return nil, false
+	}
+	db.touch(entity)
+	return entity, true
+}
+
+// peekEntity 与 GetEntity 相同，但不记录访问，也不删除已过期的key
+func (db *DB) peekEntity(key string) (*database.DataEntity, bool) {
+	entity, ok := db.data.GetWithLock(key)
+	if !ok {
+		return nil, false
+	}
+	if expireTime, hasTTL := db.ttlMap.Get(key); hasTTL && clock.Now().After(expireTime) {
+		return nil, false
+	}
+	return entity, true
+}
+
+// PutEntity a DataEntity into DB
+func (db *DB) PutEntity(key string, entity *database.DataEntity) int {
+	db.initAccess(entity)
+	ret := db.data.PutWithLock(key, entity)
+	// RENAME、COPY 等移动的哈希表需要在新的key下继续删除过期的字段
+	if hash, ok := entity.Data.(*Hash.Hash); ok && hash.ExpiresCount() > 0 {
+		db.scheduleHashExpire(key, hash)
+	}
+	// db.insertCallback may be set as nil, during `if` and actually callback
+	// so introduce a local variable `cb`
+	if cb := db.insertCallback; ret > 0 && cb != nil {
+		cb(db.index, key, entity)
+	}
+	return ret
+}
+
+// PutIfExists edit an existing DataEntity
+func (db *DB) PutIfExists(key string, entity *database.DataEntity) int {
+	db.initAccess(entity)
+	return db.data.PutIfExistsWithLock(key, entity)
+}
+
+// PutIfAbsent insert an DataEntity only if the key not exists
+func (db *DB) PutIfAbsent(key string, entity *database.DataEntity) int {
+	db.initAccess(entity)
+	ret := db.data.PutIfAbsentWithLock(key, entity)
+	// db.insertCallback may be set as nil, during `if` and actually callback
+	// so introduce a local variable `cb`
+	if cb := db.insertCallback; ret > 0 && cb != nil {
+		cb(db.index, key, entity)
+	}
+	return ret
+}
+
+// Remove the given key from db
+func (db *DB) Remove(key string) {
+	entity, deleted := db.data.RemoveWithLock(key)
+	db.ttlMap.Remove(key)
+	db.expireIndex.Remove(key)
+	db.hashExpireIndex.Remove(key)
+	if cb := db.deleteCallback; cb != nil {
+		if deleted == 0 {
+			entity = nil
+		}
+		cb(db.index, key, entity)
+	}
+}
+
+// Removes the given keys from db
+func (db *DB) Removes(keys ...string) (deleted int) {
+	deleted = 0
+	for _, key := range keys {
+		_, exists := db.data.GetWithLock(key)
+		if exists {
+			db.Remove(key)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// removeIfEmpty removes a collection key (list, hash, set and zset) after its last element was removed, returns true if removed
+// 与 Redis 一致，集合类型的key不会以空集合的形式存在；通过 Remove 删除，与显式的 DEL 一样触发 deleteCallback，
+// 并发布 del 键空间通知
+// 调用者需要持有key的写锁
+func (db *DB) removeIfEmpty(key string, size int) bool {
+	if size > 0 {
+		return false
+	}
+	db.Remove(key)
+	db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	return true
+}
+
+// GetVersion returns version code for given key
+func (db *DB) GetVersion(key string) uint32 {
+	version, ok := db.versionMap.Get(key)
+	if !ok {
+		return 0
+	}
+	return version
+}
+
+// addVersion increases version code of keys once per call, duplicate keys are bumped only once
+// invoker should hold write locks of keys
+func (db *DB) addVersion(keys ...string) {
+	if len(keys) == 1 {
+		db.versionMap.Put(keys[0], db.GetVersion(keys[0])+1)
+		return
+	}
+	bumped := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := bumped[key]; ok {
+			continue
+		}
+		bumped[key] = struct{}{}
+		db.versionMap.Put(key, db.GetVersion(key)+1)
+	}
+}
+
+/* ---- RWLocks Functions ---- */
+
+func (db *DB) RWLocks(writeKeys []string, readKeys []string) {
+	db.data.RWLocks(writeKeys, readKeys)
+}
+
+// TryRWLocks locks keys like RWLocks, gives up and returns ctx.Err() when ctx is done
+func (db *DB) TryRWLocks(ctx context.Context, writeKeys []string, readKeys []string) error {
+	return db.data.TryRWLocks(ctx, writeKeys, readKeys)
+}
+
+func (db *DB) RWUnLocks(writeKeys []string, readKeys []string) {
+	db.data.RWUnLocks(writeKeys, readKeys)
+}
+
+/* ---- Expired Functions ---- */
+
+// IsExpired check whether a key is expired
+func (db *DB) IsExpired(key string) bool {
+	expireTime, ok := db.ttlMap.Get(key)
+	if !ok {
+		return false
+	}
+	expired := clock.Now().After(expireTime)
+	if expired {
+		db.removeExpired(key)
+	}
+	return expired
+}
+
+// peekExpireTime 返回key的过期时间，已过期的key视为不存在
+// 与 GetEntity 不同，不会删除已过期的key，只读命令只持有读锁，可以安全调用
+// 修改过期时间的命令都持有key的写锁，因此读锁期间读到的过期时间与key是一致的；ttlMap 只读取一次，判断过期与返回的是同一个值
+func (db *DB) peekExpireTime(key string) (expireTime time.Time, hasTTL bool, exists bool) {
+	if _, ok := db.data.GetWithLock(key); !ok {
+		return time.Time{}, false, false
+	}
+	expireTime, hasTTL = db.ttlMap.Get(key)
+	if hasTTL && clock.Now().After(expireTime) {
+		return time.Time{}, false, false
+	}
+	return expireTime, hasTTL, true
+}
+
+// removeExpired removes an expired key and publishes expired event
+func (db *DB) removeExpired(key string) {
+	db.Remove(key)
+	webhook.Publish(&webhook.Event{
+		Type: webhook.EventExpired,
+		DB:   db.index,
+		Key:  key,
+	})
+}
+
+// Expire sets ttlCmd of key
+// 过期时间同时写入 expireIndex，即使之后再也没有访问该key，也会在主动过期中被删除
+func (db *DB) Expire(key string, expireTime time.Time) {
+	db.ttlMap.Put(key, expireTime)
+	db.expireIndex.Set(key, expireTime)
+}
+
+// Persist cancel ttlCmd of key
+func (db *DB) Persist(key string) {
+	db.ttlMap.Remove(key)
+	db.expireIndex.Remove(key)
+}
+
+/* ---- Active Expiration ---- */
+
+const (
+	// activeExpireInterval 主动过期的执行间隔
+	activeExpireInterval = 100 * time.Millisecond
+	// activeExpireBatch 每批从索引中取出的key数
+	activeExpireBatch = 20
+	// activeExpireTimeLimit 每个DB每轮主动过期的最长耗时，避免长时间占用分段锁影响正常命令
+	activeExpireTimeLimit = 25 * time.Millisecond
+)
+
+// activeExpireCycle 从 expireIndex 中按过期时间从早到晚取出已过期的key并删除，返回删除的key数
+// 取出的一批都已过期时继续下一批，直到没有过期的key或超过 timeLimit
+// 之后以同样的方式从 hashExpireIndex 中取出哈希表删除过期的字段，两者共用 timeLimit
+func (db *DB) activeExpireCycle(timeLimit time.Duration) int {
+	start := clock.Now()
+	removed := 0
+	for {
+		keys := db.expireIndex.PopExpired(clock.Now(), activeExpireBatch)
+		for _, key := range keys {
+			if db.expireIfNeeded(key) {
+				removed++
+			}
+		}
+		if len(keys) < activeExpireBatch || clock.Since(start) > timeLimit {
+			break
+		}
+	}
+	for clock.Since(start) <= timeLimit {
+		keys := db.hashExpireIndex.PopExpired(clock.Now(), activeExpireBatch)
+		for _, key := range keys {
+			if db.expireHashFields(key) {
+				removed++
+			}
+		}
+		if len(keys) < activeExpireBatch {
+			break
+		}
+	}
+	return removed
+}
+
+// expireIfNeeded 加锁后删除已过期的key
+func (db *DB) expireIfNeeded(key string) bool {
+	keys := []string{key}
+	db.RWLocks(keys, nil)
+	defer db.RWUnLocks(keys, nil)
+	// check-lock-check, ttl may be updated during waiting lock
+	expireTime, ok := db.ttlMap.Get(key)
+	if !ok {
+		return false
+	}
+	if !clock.Now().After(expireTime) {
+		// 从索引中取出后过期时间被修改，放回索引
+		db.expireIndex.Set(key, expireTime)
+		return false
+	}
+	db.removeExpired(key)
+	return true
+}
+
+/* ---- Undo Functions ---- */
+
+// GetUndoLogs return rollback commands
+func (db *DB) GetUndoLogs(cmdLine [][]byte) []CmdLine {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	cmd, ok := cmdTable[cmdName]
+	if !ok {
+		return nil
+	}
+	undo := cmd.undo
+	if undo == nil {
+		return nil
+	}
+	return undo(db, cmdLine[1:])
+}
+
+/* ---- Exec Functions ---- */
+
+func (db DB) AfterClientClose(c redis.Connection) {
+
+}
+func (db DB) Close() {
+
+}
+
+// ForEach traverses all the keys in the database
+// 遍历的是调用时刻的快照，遍历期间不持有分段锁，其他命令可以继续修改数据库
+func (db *DB) ForEach(cb func(key string, data *database.DataEntity, expiration *time.Time) bool) {
+	snap := db.data.Snapshot()
+	defer snap.Release()
+	snap.ForEach(func(key string, entity *database.DataEntity) bool {
+		var expiration *time.Time
+		expireTime, ok := db.ttlMap.Get(key)
+		if ok {
+			expiration = &expireTime
+		}
+
+		return cb(key, entity, expiration)
+	})
+}
+
+// Flush clean database
+// deprecated
+// for test only
+func (db *DB) Flush() {
+	db.data.Clear()
+	db.ttlMap.Clear()
+	db.expireIndex.Clear()
+	db.hashExpireIndex.Clear()
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"Godis/interface/database"
+	"Godis/internal/redis/connection"
+	"Godis/lib/utils"
+	"testing"
+)
+
+func TestRemoveEmptyCollection(t *testing.T) {
+	db := makeDB()
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(db.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+	var deleted []string
+	db.deleteCallback = func(dbIndex int, key string, entity *database.DataEntity) {
+		deleted = append(deleted, key)
+	}
+
+	cases := []struct {
+		init   []string
+		remove []string
+	}{
+		{[]string{"RPUSH", "k", "a"}, []string{"LPOP", "k"}},
+		{[]string{"RPUSH", "k", "a"}, []string{"RPOP", "k"}},
+		{[]string{"RPUSH", "k", "a", "a"}, []string{"LREM", "k", "0", "a"}},
+		{[]string{"RPUSH", "k", "a", "b"}, []string{"LTRIM", "k", "1", "0"}},
+		{[]string{"RPUSH", "k", "a"}, []string{"RPOPLPUSH", "k", "other"}},
+		{[]string{"HSET", "k", "f", "v"}, []string{"HDEL", "k", "f"}},
+		{[]string{"SADD", "k", "a"}, []string{"SREM", "k", "a"}},
+		{[]string{"SADD", "k", "a", "b"}, []string{"SPOP", "k", "2"}},
+		{[]string{"SADD", "k", "a"}, []string{"SMOVE", "k", "other", "a"}},
+		{[]string{"ZADD", "k", "1", "a"}, []string{"ZREM", "k", "a"}},
+		{[]string{"ZADD", "k", "1", "a", "2", "b"}, []string{"ZPOPMAX", "k", "2"}},
+		{[]string{"ZADD", "k", "1", "a"}, []string{"ZREMRANGEBYSCORE", "k", "0", "1"}},
+		{[]string{"ZADD", "k", "1", "a"}, []string{"ZREMRANGEBYRANK", "k", "0", "-1"}},
+		{[]string{"ZADD", "k", "0", "a"}, []string{"ZREMRANGEBYLEX", "k", "-", "+"}},
+	}
+	for _, c := range cases {
+		exec(c.init...)
+		deleted = nil
+		exec(c.remove...)
+		if reply := exec("EXISTS", "k"); reply != ":0\r\n" {
+			t.Errorf("%v: empty collection should be removed", c.remove)
+		}
+		if len(deleted) != 1 || deleted[0] != "k" {
+			t.Errorf("%v: expect delete callback of k, actual %v", c.remove, deleted)
+		}
+		exec("DEL", "other")
+	}
+
+	// 没有删空的集合不会被删除
+	exec("RPUSH", "k", "a", "b")
+	deleted = nil
+	exec("LPOP", "k")
+	if reply := exec("LLEN", "k"); reply != ":1\r\n" || len(deleted) != 0 {
+		t.Errorf("non-empty list should be kept, actual %q, deleted %v", reply, deleted)
+	}
+}
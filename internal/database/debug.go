@@ -0,0 +1,126 @@
+package database
+
+import (
+	"Godis/datastruct/dict"
+	"Godis/interface/redis"
+	"Godis/redis/protocol"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultLockStatsCount is the number of shards shown for each db by DEBUG LOCKSTATS
+const defaultLockStatsCount = 10
+
+// execDebug executes DEBUG subcommands
+func (server *Server) execDebug(args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return protocol.MakeArgNumErrReply("debug")
+	}
+	subCommand := strings.ToLower(string(args[0]))
+	switch subCommand {
+	case "lockstats":
+		return server.execLockStats(args[1:])
+	case "help":
+		return protocol.MakeMultiBulkReply([][]byte{
+			[]byte("DEBUG <subcommand> [<arg> [value] [opt] ...]. Subcommands are:"),
+			[]byte("LOCKSTATS ON|OFF|RESET"),
+			[]byte("    Start, stop or reset recording wait time of shard locks."),
+			[]byte("LOCKSTATS [COUNT <count>]"),
+			[]byte("    Show <count> shards with the longest total wait time of each db (default: 10)."),
+		})
+	}
+	return protocol.MakeErrReply("ERR unknown subcommand '" + subCommand + "'. Try DEBUG HELP.")
+}
+
+// execLockStats 开启统计后 FLUSHDB 等替换的新DB会继承统计，见 loadDB
+func (server *Server) execLockStats(args [][]byte) redis.Reply {
+	if len(args) == 1 {
+		switch strings.ToLower(string(args[0])) {
+		case "on":
+			for i := range server.dbSet {
+				if db := server.mustSelectDB(i); db.data.LockStats() == nil {
+					db.data.SetLockStats(dict.MakeLockStats())
+				}
+			}
+			return protocol.MakeOkReply()
+		case "off":
+			for i := range server.dbSet {
+				server.mustSelectDB(i).data.SetLockStats(nil)
+			}
+			return protocol.MakeOkReply()
+		case "reset":
+			for i := range server.dbSet {
+				if db := server.mustSelectDB(i); db.data.LockStats() != nil {
+					db.data.SetLockStats(dict.MakeLockStats())
+				}
+			}
+			return protocol.MakeOkReply()
+		}
+		return &protocol.SyntaxErrReply{}
+	}
+	count := defaultLockStatsCount
+	if len(args) == 2 && strings.ToLower(string(args[0])) == "count" {
+		var err error
+		count, err = strconv.Atoi(string(args[1]))
+		if err != nil || count <= 0 {
+			return protocol.MakeErrReply("ERR count should be greater than 0")
+		}
+	} else if len(args) != 0 {
+		return &protocol.SyntaxErrReply{}
+	}
+	if server.mustSelectDB(0).data.LockStats() == nil {
+		return protocol.MakeErrReply("ERR lock stats is disabled, use DEBUG LOCKSTATS ON to enable it")
+	}
+	builder := &strings.Builder{}
+	for i := range server.dbSet {
+		db := server.mustSelectDB(i)
+		stats := db.data.LockStats()
+		if stats == nil {
+			continue
+		}
+		shards := stats.Shards()
+		if len(shards) == 0 {
+			continue
+		}
+		if len(shards) > count {
+			shards = shards[:count]
+		}
+		// 附上分段当前的键值对数，便于判断竞争是否由 key 分布不均导致
+		sizes := db.data.Stats().ShardSizes
+		builder.WriteString(fmt.Sprintf("# db%d\r\n", db.index))
+		for _, s := range shards {
+			keys := "-"
+			if int(s.Index) < len(sizes) {
+				keys = strconv.Itoa(sizes[s.Index])
+			}
+			builder.WriteString(fmt.Sprintf("shard:%d keys=%s acquisitions=%d contended=%d wait_total_us=%d wait_max_us=%d wait_hist=%s\r\n",
+				s.Index, keys, s.Acquisitions, s.Contended, s.TotalWait.Microseconds(), s.MaxWait.Microseconds(), formatLockWaitHistogram(s.Histogram[:])))
+		}
+	}
+	return protocol.MakeBulkReply([]byte(builder.String()))
+}
+
+// formatLockWaitHistogram formats histogram as "10us:1,100us:0,...,+inf:0"
+func formatLockWaitHistogram(histogram []int64) string {
+	parts := make([]string, len(histogram))
+	for i, n := range histogram {
+		bound := "+inf"
+		if i < len(dict.LockWaitBuckets) {
+			bound = formatLockWaitBound(dict.LockWaitBuckets[i])
+		}
+		parts[i] = bound + ":" + strconv.FormatInt(n, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatLockWaitBound(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return strconv.FormatInt(d.Microseconds(), 10) + "us"
+	case d < time.Second:
+		return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+	}
+	return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"Godis/internal/redis/connection"
+	"Godis/lib/utils"
+	"strings"
+	"testing"
+)
+
+func TestDebugLockStats(t *testing.T) {
+	server := NewStandaloneServer()
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(server.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+	if reply := exec("DEBUG", "LOCKSTATS"); !strings.HasPrefix(reply, "-ERR lock stats is disabled") {
+		t.Errorf("expect disabled error, actual %q", reply)
+	}
+	if reply := exec("DEBUG", "LOCKSTATS", "ON"); reply != "+OK\r\n" {
+		t.Errorf("expect OK, actual %q", reply)
+	}
+	exec("SET", "k", "v")
+	exec("GET", "k")
+	reply := exec("DEBUG", "LOCKSTATS")
+	if !strings.Contains(reply, "# db0\r\n") || !strings.Contains(reply, "keys=1 acquisitions=2 contended=0") ||
+		!strings.Contains(reply, "wait_hist=10us:0,100us:0,1ms:0,10ms:0,100ms:0,1s:0,+inf:0") {
+		t.Errorf("unexpected lock stats: %q", reply)
+	}
+
+	// FLUSHDB 后的新DB继承统计
+	exec("FLUSHDB")
+	exec("GET", "k")
+	if reply := exec("DEBUG", "LOCKSTATS", "COUNT", "1"); !strings.Contains(reply, "acquisitions=3") {
+		t.Errorf("lock stats should be kept after flushdb, actual %q", reply)
+	}
+	exec("DEBUG", "LOCKSTATS", "RESET")
+	if reply := exec("DEBUG", "LOCKSTATS"); reply != "$0\r\n\r\n" {
+		t.Errorf("expect empty stats after reset, actual %q", reply)
+	}
+	exec("DEBUG", "LOCKSTATS", "OFF")
+	if reply := exec("DEBUG", "LOCKSTATS"); reply[0] != '-' {
+		t.Errorf("expect disabled error, actual %q", reply)
+	}
+	if reply := exec("DEBUG", "LOCKSTATS", "COUNT", "0"); reply[0] != '-' {
+		t.Errorf("expect error, actual %q", reply)
+	}
+	if reply := exec("DEBUG", "NOSUCH"); !strings.HasPrefix(reply, "-ERR unknown subcommand") {
+		t.Errorf("expect unknown subcommand error, actual %q", reply)
+	}
+}
@@ -0,0 +1,844 @@
+package database
+
+import (
+	Hash "Godis/datastruct/hash"
+	"Godis/interface/database"
+	"Godis/interface/redis"
+	"Godis/internal/config"
+	"Godis/lib/clock"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (db *DB) getAsHash(key string) (*Hash.Hash, protocol.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	hash, ok := entity.Data.(*Hash.Hash)
+	if !ok {
+		return nil, &protocol.WrongTypeErrReply{}
+	}
+	return hash, nil
+}
+
+// makeHash makes an empty hash, small hash is encoded as listpack
+func makeHash() *Hash.Hash {
+	return Hash.Make(config.Properties.HashMaxListpackEntries, config.Properties.HashMaxListpackValue)
+}
+
+func (db *DB) getOrInitHash(key string) (hash *Hash.Hash, inited bool, errReply protocol.ErrorReply) {
+	hash, errReply = db.getAsHash(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	inited = false
+	if hash == nil {
+		hash = makeHash()
+		db.PutEntity(key, &database.DataEntity{
+			Data: hash,
+			Type: database.TypeHash,
+		})
+		inited = true
+	}
+	return hash, inited, nil
+}
+
+// execHSet sets field in hash table
+func execHSet(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	key := string(args[0])
+	field := string(args[1])
+	value := args[2]
+
+	// get or init entity
+	hash, _, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	result := hash.Set(field, value)
+	db.addAof(utils.ToCmdLine3("hset", args...))
+	return protocol.MakeIntReply(int64(result))
+}
+
+func undoHSet(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	field := string(args[1])
+	return rollbackHashFields(db, key, field)
+}
+
+// execHSetNX sets field in hash table only if field not exists
+func execHSetNX(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	key := string(args[0])
+	field := string(args[1])
+	value := args[2]
+
+	hash, _, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	result := hash.SetIfAbsent(field, value)
+	if result > 0 {
+		db.addAof(utils.ToCmdLine3("hsetnx", args...))
+
+	}
+	return protocol.MakeIntReply(int64(result))
+}
+
+// execHGet gets field value of hash table
+func execHGet(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	key := string(args[0])
+	field := string(args[1])
+
+	// get entity
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return &protocol.NullBulkReply{}
+	}
+
+	value, exists := hash.Get(field)
+	if !exists {
+		return &protocol.NullBulkReply{}
+	}
+	return protocol.MakeBulkReply(value)
+}
+
+// execHExists checks if a hash field exists
+func execHExists(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	key := string(args[0])
+	field := string(args[1])
+
+	// get entity
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeIntReply(0)
+	}
+
+	if hash.Has(field) {
+		return protocol.MakeIntReply(1)
+	}
+	return protocol.MakeIntReply(0)
+}
+
+// execHDel deletes a hash field
+func execHDel(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	key := string(args[0])
+	fields := make([]string, len(args)-1)
+	fieldArgs := args[1:]
+	for i, v := range fieldArgs {
+		fields[i] = string(v)
+	}
+
+	// get entity
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeIntReply(0)
+	}
+
+	deleted := 0
+	for _, field := range fields {
+		deleted += hash.Del(field)
+	}
+	db.removeIfEmpty(key, hash.Len())
+	if deleted > 0 {
+		db.addAof(utils.ToCmdLine3("hdel", args...))
+	}
+
+	return protocol.MakeIntReply(int64(deleted))
+}
+
+func undoHDel(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	fields := make([]string, len(args)-1)
+	fieldArgs := args[1:]
+	for i, v := range fieldArgs {
+		fields[i] = string(v)
+	}
+	return rollbackHashFields(db, key, fields...)
+}
+
+// execHLen gets number of fields in hash table
+func execHLen(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	key := string(args[0])
+
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeIntReply(0)
+	}
+	return protocol.MakeIntReply(int64(hash.Len()))
+}
+
+// execHStrlen Returns the string length of the value associated with field in the hash stored at key.
+// If the key or the field do not exist, 0 is returned.
+func execHStrlen(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	field := string(args[1])
+
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeIntReply(0)
+	}
+
+	value, exists := hash.Get(field)
+	if exists {
+		return protocol.MakeIntReply(int64(len(value)))
+	}
+	return protocol.MakeIntReply(0)
+}
+
+// execHMSet sets multi fields in hash table
+func execHMSet(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	if len(args)%2 != 1 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	key := string(args[0])
+	size := (len(args) - 1) / 2
+	fields := make([]string, size)
+	values := make([][]byte, size)
+	for i := 0; i < size; i++ {
+		fields[i] = string(args[2*i+1])
+		values[i] = args[2*i+2]
+	}
+
+	// get or init entity
+	hash, _, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	// put data
+	for i, field := range fields {
+		value := values[i]
+		hash.Set(field, value)
+	}
+	db.addAof(utils.ToCmdLine3("hmset", args...))
+	return &protocol.OkReply{}
+}
+
+func undoHMSet(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	size := (len(args) - 1) / 2
+	fields := make([]string, size)
+	for i := 0; i < size; i++ {
+		fields[i] = string(args[2*i+1])
+	}
+	return rollbackHashFields(db, key, fields...)
+}
+
+// execHMGet gets multi fields in hash table
+func execHMGet(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	size := len(args) - 1
+	fields := make([]string, size)
+	for i := 0; i < size; i++ {
+		fields[i] = string(args[i+1])
+	}
+
+	// get entity
+	result := make([][]byte, size)
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return protocol.MakeMultiBulkReply(result)
+	}
+
+	for i, field := range fields {
+		result[i], _ = hash.Get(field)
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// execHKeys gets all field names in hash table
+func execHKeys(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return &protocol.EmptyMultiBulkReply{}
+	}
+
+	fields := make([][]byte, hash.Len())
+	i := 0
+	hash.ForEach(func(field string, value []byte) bool {
+		fields[i] = []byte(field)
+		i++
+		return true
+	})
+	return protocol.MakeMultiBulkReply(fields[:i])
+}
+
+// execHVals gets all field value in hash table
+func execHVals(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	// get entity
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return &protocol.EmptyMultiBulkReply{}
+	}
+
+	values := make([][]byte, hash.Len())
+	i := 0
+	hash.ForEach(func(field string, value []byte) bool {
+		values[i] = value
+		i++
+		return true
+	})
+	return protocol.MakeMultiBulkReply(values[:i])
+}
+
+// execHGetAll gets all key-value entries in hash table
+func execHGetAll(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	// get entity
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return &protocol.EmptyMultiBulkReply{}
+	}
+
+	size := hash.Len()
+	result := make([][]byte, size*2)
+	i := 0
+	hash.ForEach(func(field string, value []byte) bool {
+		result[i] = []byte(field)
+		i++
+		result[i] = value
+		i++
+		return true
+	})
+	return protocol.MakeMultiBulkReply(result[:i])
+}
+
+// execHIncrBy increments the integer value of a hash field by the given number
+func execHIncrBy(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	rawDelta := string(args[2])
+	delta, err := strconv.ParseInt(rawDelta, 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+
+	hash, _, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	value, exists := hash.Get(field)
+	if !exists {
+		hash.Set(field, args[2])
+		db.addAof(utils.ToCmdLine3("hincrby", args...))
+		return protocol.MakeBulkReply(args[2])
+	}
+	val, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR hash value is not an integer")
+	}
+	val += delta
+	bytes := []byte(strconv.FormatInt(val, 10))
+	hash.Set(field, bytes)
+	db.addAof(utils.ToCmdLine3("hincrby", args...))
+	return protocol.MakeBulkReply(bytes)
+}
+
+func undoHIncr(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	field := string(args[1])
+	return rollbackHashFields(db, key, field)
+}
+
+// execHIncrByFloat increments the float value of a hash field by the given number
+func execHIncrByFloat(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	rawDelta := string(args[2])
+	delta, err := strconv.ParseFloat(rawDelta, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR value is not a valid float")
+	}
+
+	// get or init entity
+	hash, _, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	value, exists := hash.Get(field)
+	if !exists {
+		hash.Set(field, args[2])
+		db.addAof(utils.ToCmdLine3("hincrbyfloat", args...))
+		return protocol.MakeBulkReply(args[2])
+	}
+	val, err := strconv.ParseFloat(string(value), 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR hash value is not a float")
+	}
+	result := val + delta
+	resultBytes := []byte(strconv.FormatFloat(result, 'f', -1, 64))
+	hash.Set(field, resultBytes)
+	db.addAof(utils.ToCmdLine3("hincrbyfloat", args...))
+	return protocol.MakeBulkReply(resultBytes)
+}
+
+// execHRandField return a random field(or field-value) from the hash value stored at key.
+func execHRandField(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	count := 1
+	withvalues := 0
+
+	if len(args) > 3 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'hrandfield' command")
+	}
+
+	if len(args) == 3 {
+		if strings.ToLower(string(args[2])) == "withvalues" {
+			withvalues = 1
+		} else {
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+
+	if len(args) >= 2 {
+		count64, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return protocol.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		count = int(count64)
+	}
+
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return &protocol.EmptyMultiBulkReply{}
+	}
+
+	if count > 0 {
+		fields := hash.RandomDistinctFields(count)
+		Numfield := len(fields)
+		if withvalues == 0 {
+			result := make([][]byte, Numfield)
+			for i, v := range fields {
+				result[i] = []byte(v)
+			}
+			return protocol.MakeMultiBulkReply(result)
+		} else {
+			result := make([][]byte, 2*Numfield)
+			for i, v := range fields {
+				result[2*i] = []byte(v)
+				result[2*i+1], _ = hash.Get(v)
+			}
+			return protocol.MakeMultiBulkReply(result)
+		}
+	} else if count < 0 {
+		fields := hash.RandomFields(-count)
+		Numfield := len(fields)
+		if withvalues == 0 {
+			result := make([][]byte, Numfield)
+			for i, v := range fields {
+				result[i] = []byte(v)
+			}
+			return protocol.MakeMultiBulkReply(result)
+		} else {
+			result := make([][]byte, 2*Numfield)
+			for i, v := range fields {
+				result[2*i] = []byte(v)
+				result[2*i+1], _ = hash.Get(v)
+			}
+			return protocol.MakeMultiBulkReply(result)
+		}
+	}
+
+	// 'count' is 0 will reach.
+	return &protocol.EmptyMultiBulkReply{}
+}
+
+// execHScan iterates fields and values of hash table
+// usage: HSCAN key cursor [MATCH pattern] [COUNT count]
+func execHScan(db *DB, args [][]byte) redis.Reply {
+	cursor, pattern, count, errReply := parseScanArgs(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	hash, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if hash == nil {
+		return makeScanReply(0, nil)
+	}
+	fields, next := hash.Scan(cursor, count)
+	result := make([][]byte, 0, 2*len(fields))
+	for _, field := range fields {
+		if pattern != nil && !pattern.IsMatch(field) {
+			continue
+		}
+		value, _ := hash.Get(field)
+		result = append(result, []byte(field), value)
+	}
+	return makeScanReply(next, result)
+}
+
+/* ---- Field Expiration ---- */
+
+// HEXPIRE 系列命令对每个字段的返回值
+const (
+	fieldNotExists       = -2
+	fieldNoTTL           = -1
+	fieldConditionNotMet = 0
+	fieldTTLUpdated      = 1
+	fieldDeleted         = 2
+)
+
+// maxFieldExpire 过期时间的上限，与 Redis 一致为 2^48 毫秒
+const maxFieldExpire = int64(1) << 48
+
+// parseHashFields parses `FIELDS numfields field [field ...]`
+func parseHashFields(args [][]byte) ([]string, protocol.ErrorReply) {
+	if len(args) < 3 || strings.ToLower(string(args[0])) != "fields" {
+		return nil, protocol.MakeErrReply("ERR Mandatory argument FIELDS is missing or not at the right position")
+	}
+	numFields, err := strconv.Atoi(string(args[1]))
+	if err != nil || numFields <= 0 {
+		return nil, protocol.MakeErrReply("ERR Parameter `numFields` should be greater than 0")
+	}
+	if numFields != len(args)-2 {
+		return nil, protocol.MakeErrReply("ERR The `numfields` parameter must match the number of arguments")
+	}
+	fields := make([]string, numFields)
+	for i, arg := range args[2:] {
+		fields[i] = string(arg)
+	}
+	return fields, nil
+}
+
+// fieldsOfTTLCmd returns fields of HEXPIRE/HPERSIST family commands, used by undo
+func fieldsOfTTLCmd(args [][]byte) []string {
+	for i := 1; i < len(args); i++ {
+		if strings.ToLower(string(args[i])) == "fields" {
+			fields, errReply := parseHashFields(args[i:])
+			if errReply == nil {
+				return fields
+			}
+		}
+	}
+	return nil
+}
+
+// scheduleHashExpire 将最早过期的字段的过期时间写入 hashExpireIndex，由主动过期删除已过期的字段
+// 没有字段设置过期时间时从索引中移除
+func (db *DB) scheduleHashExpire(key string, hash *Hash.Hash) {
+	next, ok := hash.NextExpireTime()
+	if !ok {
+		db.hashExpireIndex.Remove(key)
+		return
+	}
+	db.hashExpireIndex.Set(key, next)
+}
+
+// expireHashFields 加锁后删除哈希表中所有已过期的字段，字段全部过期后删除哈希表并返回true
+func (db *DB) expireHashFields(key string) bool {
+	keys := []string{key}
+	db.RWLocks(keys, nil)
+	defer db.RWUnLocks(keys, nil)
+	// 哈希表可能已经被删除或覆盖
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return false
+	}
+	hash, ok := entity.Data.(*Hash.Hash)
+	if !ok {
+		return false
+	}
+	hash.RemoveExpired(clock.Now())
+	if db.removeIfEmpty(key, hash.Len()) {
+		return true
+	}
+	db.scheduleHashExpire(key, hash)
+	return false
+}
+
+// execHExpireGeneric returns executor of HEXPIRE/HPEXPIRE/HEXPIREAT/HPEXPIREAT
+// usage: HEXPIRE key seconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+func execHExpireGeneric(unit time.Duration, absolute bool) ExecFunc {
+	return func(db *DB, args [][]byte) redis.Reply {
+		key := string(args[0])
+		raw, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return protocol.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		if raw < 0 || raw > maxFieldExpire/int64(unit/time.Millisecond) {
+			return protocol.MakeErrReply("ERR invalid expire time, must be >= 0 and <= 2^48")
+		}
+		var expireAt time.Time
+		if absolute {
+			expireAt = time.UnixMilli(raw * int64(unit/time.Millisecond))
+		} else {
+			expireAt = clock.Now().Add(time.Duration(raw) * unit)
+		}
+
+		fieldArgs := args[2:]
+		condition := ""
+		if len(fieldArgs) > 0 {
+			switch arg := strings.ToLower(string(fieldArgs[0])); arg {
+			case "nx", "xx", "gt", "lt":
+				condition = arg
+				fieldArgs = fieldArgs[1:]
+			}
+		}
+		fields, errReply := parseHashFields(fieldArgs)
+		if errReply != nil {
+			return errReply
+		}
+
+		hash, errReply := db.getAsHash(key)
+		if errReply != nil {
+			return errReply
+		}
+		result := make([]redis.Reply, len(fields))
+		if hash == nil {
+			for i := range result {
+				result[i] = protocol.MakeIntReply(fieldNotExists)
+			}
+			return protocol.MakeMultiRawReply(result)
+		}
+
+		now := clock.Now()
+		changed := false
+		for i, field := range fields {
+			if !hash.Has(field) {
+				result[i] = protocol.MakeIntReply(fieldNotExists)
+				continue
+			}
+			current, hasTTL := hash.ExpireTime(field)
+			if !checkExpireCondition(condition, hasTTL, current, expireAt) {
+				result[i] = protocol.MakeIntReply(fieldConditionNotMet)
+				continue
+			}
+			changed = true
+			if !expireAt.After(now) {
+				hash.Del(field)
+				result[i] = protocol.MakeIntReply(fieldDeleted)
+				continue
+			}
+			hash.Expire(field, expireAt)
+			result[i] = protocol.MakeIntReply(fieldTTLUpdated)
+		}
+
+		if changed {
+			// 使用绝对时间写入 aof，保证重放结果一致
+			aofArgs := [][]byte{[]byte("hpexpireat"), args[0], []byte(strconv.FormatInt(expireAt.UnixMilli(), 10))}
+			if condition != "" {
+				aofArgs = append(aofArgs, []byte(condition))
+			}
+			aofArgs = append(aofArgs, fieldArgs...)
+			db.addAof(aofArgs)
+		}
+		if !db.removeIfEmpty(key, hash.Len()) {
+			db.scheduleHashExpire(key, hash)
+		}
+		return protocol.MakeMultiRawReply(result)
+	}
+}
+
+// checkExpireCondition checks NX/XX/GT/LT option, field without ttl is treated as infinite ttl
+func checkExpireCondition(condition string, hasTTL bool, current time.Time, expireAt time.Time) bool {
+	switch condition {
+	case "nx":
+		return !hasTTL
+	case "xx":
+		return hasTTL
+	case "gt":
+		return hasTTL && expireAt.After(current)
+	case "lt":
+		return !hasTTL || expireAt.Before(current)
+	}
+	return true
+}
+
+// execHPersist removes expiration of hash fields
+// usage: HPERSIST key FIELDS numfields field [field ...]
+func execHPersist(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	fields, errReply := parseHashFields(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	hash, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]redis.Reply, len(fields))
+	persisted := false
+	for i, field := range fields {
+		if hash == nil {
+			result[i] = protocol.MakeIntReply(fieldNotExists)
+			continue
+		}
+		if !hash.Has(field) {
+			result[i] = protocol.MakeIntReply(fieldNotExists)
+			continue
+		}
+		if !hash.Persist(field) {
+			result[i] = protocol.MakeIntReply(fieldNoTTL)
+			continue
+		}
+		persisted = true
+		result[i] = protocol.MakeIntReply(1)
+	}
+	if persisted {
+		db.scheduleHashExpire(key, hash)
+		db.addAof(utils.ToCmdLine3("hpersist", args...))
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+// execHTTLGeneric returns executor of HTTL/HPTTL/HEXPIRETIME/HPEXPIRETIME
+// usage: HTTL key FIELDS numfields field [field ...]
+func execHTTLGeneric(unit time.Duration, absolute bool) ExecFunc {
+	return func(db *DB, args [][]byte) redis.Reply {
+		key := string(args[0])
+		fields, errReply := parseHashFields(args[1:])
+		if errReply != nil {
+			return errReply
+		}
+		hash, errReply := db.getAsHash(key)
+		if errReply != nil {
+			return errReply
+		}
+		result := make([]redis.Reply, len(fields))
+		for i, field := range fields {
+			if hash == nil {
+				result[i] = protocol.MakeIntReply(fieldNotExists)
+				continue
+			}
+			if !hash.Has(field) {
+				result[i] = protocol.MakeIntReply(fieldNotExists)
+				continue
+			}
+			expireAt, hasTTL := hash.ExpireTime(field)
+			if !hasTTL {
+				result[i] = protocol.MakeIntReply(fieldNoTTL)
+				continue
+			}
+			if absolute {
+				result[i] = protocol.MakeIntReply(expireAt.UnixMilli() / int64(unit/time.Millisecond))
+			} else {
+				result[i] = protocol.MakeIntReply(int64(clock.Until(expireAt) / unit))
+			}
+		}
+		return protocol.MakeMultiRawReply(result)
+	}
+}
+
+// undoHFieldTTL restores values and expiration of the given fields
+func undoHFieldTTL(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	fields := fieldsOfTTLCmd(args)
+	undoCmdLines := rollbackHashFields(db, key, fields...)
+	hash, _ := db.getAsHash(key)
+	if hash == nil {
+		return undoCmdLines
+	}
+	for _, field := range fields {
+		if expireAt, hasTTL := hash.ExpireTime(field); hasTTL {
+			undoCmdLines = append(undoCmdLines, utils.ToCmdLine("HPEXPIREAT", key,
+				strconv.FormatInt(expireAt.UnixMilli(), 10), "FIELDS", "1", field))
+		}
+	}
+	return undoCmdLines
+}
+
+func init() {
+	registerCommand("HSet", execHSet, writeFirstKey, undoHSet, 4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
+	registerCommand("HSetNX", execHSetNX, writeFirstKey, undoHSet, 4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
+	registerCommand("HGet", execHGet, readFirstKey, nil, 3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("HExists", execHExists, readFirstKey, nil, 3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("HDel", execHDel, writeFirstKey, undoHDel, -3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("HLen", execHLen, readFirstKey, nil, 2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("HStrlen", execHStrlen, readFirstKey, nil, 3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("HMSet", execHMSet, writeFirstKey, undoHMSet, -4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
+	registerCommand("HMGet", execHMGet, readFirstKey, nil, -3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("HGet", execHGet, readFirstKey, nil, -3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("HKeys", execHKeys, readFirstKey, nil, 2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagSortForScript}, 1, 1, 1)
+	registerCommand("HVals", execHVals, readFirstKey, nil, 2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagSortForScript}, 1, 1, 1)
+	registerCommand("HGetAll", execHGetAll, readFirstKey, nil, 2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagRandom}, 1, 1, 1)
+	registerCommand("HIncrBy", execHIncrBy, writeFirstKey, undoHIncr, 4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
+	registerCommand("HIncrByFloat", execHIncrByFloat, writeFirstKey, undoHIncr, 4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
+	registerCommand("HRandField", execHRandField, readFirstKey, nil, -2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagRandom, redisFlagReadonly}, 1, 1, 1)
+	registerCommand("HScan", execHScan, readFirstKey, nil, -3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagRandom}, 1, 1, 1)
+	registerCommand("HExpire", execHExpireGeneric(time.Second, false), writeFirstKey, undoHFieldTTL, -6, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("HPExpire", execHExpireGeneric(time.Millisecond, false), writeFirstKey, undoHFieldTTL, -6, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("HExpireAt", execHExpireGeneric(time.Second, true), writeFirstKey, undoHFieldTTL, -6, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("HPExpireAt", execHExpireGeneric(time.Millisecond, true), writeFirstKey, undoHFieldTTL, -6, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("HPersist", execHPersist, writeFirstKey, undoHFieldTTL, -5, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("HTTL", execHTTLGeneric(time.Second, false), readFirstKey, nil, -5, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("HPTTL", execHTTLGeneric(time.Millisecond, false), readFirstKey, nil, -5, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("HExpireTime", execHTTLGeneric(time.Second, true), readFirstKey, nil, -5, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("HPExpireTime", execHTTLGeneric(time.Millisecond, true), readFirstKey, nil, -5, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+}
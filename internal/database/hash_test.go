@@ -0,0 +1,168 @@
+package database
+
+import (
+	"Godis/internal/config"
+	"Godis/internal/redis/connection"
+	"Godis/lib/clock"
+	"Godis/lib/utils"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHExpireCondition(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	clock.Set(fake)
+	defer clock.Set(nil)
+	// ttl 字段的过期时间为100秒，plain 字段没有过期时间
+	cases := []struct {
+		condition string
+		seconds   string
+		field     string
+		expected  string
+	}{
+		{"NX", "200", "plain", ":1"},
+		{"NX", "200", "ttl", ":0"},
+		{"XX", "200", "plain", ":0"},
+		{"XX", "200", "ttl", ":1"},
+		{"GT", "200", "plain", ":0"},
+		{"GT", "200", "ttl", ":1"},
+		{"GT", "50", "ttl", ":0"},
+		{"LT", "200", "plain", ":1"},
+		{"LT", "50", "ttl", ":1"},
+		{"LT", "200", "ttl", ":0"},
+	}
+	for _, c := range cases {
+		db := makeDB()
+		conn := connection.NewFakeConn()
+		db.Exec(conn, utils.ToCmdLine("HSET", "h", "plain", "v"))
+		db.Exec(conn, utils.ToCmdLine("HSET", "h", "ttl", "v"))
+		db.Exec(conn, utils.ToCmdLine("HEXPIRE", "h", "100", "FIELDS", "1", "ttl"))
+		reply := db.Exec(conn, utils.ToCmdLine("HEXPIRE", "h", c.seconds, c.condition, "FIELDS", "1", c.field))
+		if actual := string(reply.ToBytes()); actual != "*1\r\n"+c.expected+"\r\n" {
+			t.Errorf("HEXPIRE %s %s %s: expect %s, actual %q", c.seconds, c.condition, c.field, c.expected, actual)
+			continue
+		}
+		expectedTTL := ":-1"
+		if c.field == "ttl" {
+			expectedTTL = ":100"
+		}
+		if c.expected == ":1" {
+			expectedTTL = ":" + c.seconds
+		}
+		reply = db.Exec(conn, utils.ToCmdLine("HTTL", "h", "FIELDS", "1", c.field))
+		if actual := string(reply.ToBytes()); actual != "*1\r\n"+expectedTTL+"\r\n" {
+			t.Errorf("HTTL after HEXPIRE %s %s %s: expect %s, actual %q", c.seconds, c.condition, c.field, expectedTTL, actual)
+		}
+	}
+}
+
+func TestHExpirePastTime(t *testing.T) {
+	db := makeDB()
+	conn := connection.NewFakeConn()
+	db.Exec(conn, utils.ToCmdLine("HSET", "h", "a", "1"))
+	db.Exec(conn, utils.ToCmdLine("HSET", "h", "b", "2"))
+	// 过去的时间立即删除字段
+	reply := db.Exec(conn, utils.ToCmdLine("HPEXPIREAT", "h", "1", "FIELDS", "2", "a", "missing"))
+	if actual := string(reply.ToBytes()); actual != "*2\r\n:2\r\n:-2\r\n" {
+		t.Errorf("unexpected reply %q", actual)
+	}
+	if actual := string(db.Exec(conn, utils.ToCmdLine("HEXISTS", "h", "a")).ToBytes()); actual != ":0\r\n" {
+		t.Errorf("field a should be deleted, actual %q", actual)
+	}
+	// 删除最后一个字段后删除哈希表
+	reply = db.Exec(conn, utils.ToCmdLine("HEXPIRE", "h", "0", "FIELDS", "1", "b"))
+	if actual := string(reply.ToBytes()); actual != "*1\r\n:2\r\n" {
+		t.Errorf("unexpected reply %q", actual)
+	}
+	if _, exists := db.GetEntity("h"); exists {
+		t.Error("empty hash should be removed")
+	}
+}
+
+func TestHTTLReply(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	clock.Set(fake)
+	defer clock.Set(nil)
+	db := makeDB()
+	conn := connection.NewFakeConn()
+	reply := db.Exec(conn, utils.ToCmdLine("HTTL", "missing", "FIELDS", "1", "a"))
+	if actual := string(reply.ToBytes()); actual != "*1\r\n:-2\r\n" {
+		t.Errorf("missing key: unexpected reply %q", actual)
+	}
+	db.Exec(conn, utils.ToCmdLine("HSET", "h", "a", "1"))
+	db.Exec(conn, utils.ToCmdLine("HSET", "h", "b", "2"))
+	db.Exec(conn, utils.ToCmdLine("HPEXPIRE", "h", "1500", "FIELDS", "1", "b"))
+	reply = db.Exec(conn, utils.ToCmdLine("HTTL", "h", "FIELDS", "3", "a", "b", "c"))
+	if actual := string(reply.ToBytes()); actual != "*3\r\n:-1\r\n:1\r\n:-2\r\n" {
+		t.Errorf("unexpected HTTL reply %q", actual)
+	}
+	fake.Advance(500 * time.Millisecond)
+	reply = db.Exec(conn, utils.ToCmdLine("HPTTL", "h", "FIELDS", "1", "b"))
+	if actual := string(reply.ToBytes()); actual != "*1\r\n:1000\r\n" {
+		t.Errorf("unexpected HPTTL reply %q", actual)
+	}
+	reply = db.Exec(conn, utils.ToCmdLine("HPERSIST", "h", "FIELDS", "2", "a", "b"))
+	if actual := string(reply.ToBytes()); actual != "*2\r\n:-1\r\n:1\r\n" {
+		t.Errorf("unexpected HPERSIST reply %q", actual)
+	}
+}
+
+func TestHashFieldActiveExpire(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	clock.Set(fake)
+	defer clock.Set(nil)
+	db := makeDB()
+	conn := connection.NewFakeConn()
+	db.Exec(conn, utils.ToCmdLine("HSET", "h", "a", "1"))
+	db.Exec(conn, utils.ToCmdLine("HSET", "h", "b", "2"))
+	db.Exec(conn, utils.ToCmdLine("HPEXPIRE", "h", "100", "FIELDS", "1", "a"))
+	db.Exec(conn, utils.ToCmdLine("HPEXPIRE", "h", "200", "FIELDS", "1", "b"))
+	// 改名后的哈希表在新的key下继续过期
+	db.Exec(conn, utils.ToCmdLine("RENAME", "h", "renamed"))
+
+	fake.Advance(150 * time.Millisecond)
+	db.activeExpireCycle(activeExpireTimeLimit)
+	if actual := string(db.Exec(conn, utils.ToCmdLine("HLEN", "renamed")).ToBytes()); actual != ":1\r\n" {
+		t.Errorf("expect 1 field left, actual %q", actual)
+	}
+	fake.Advance(100 * time.Millisecond)
+	if removed := db.activeExpireCycle(activeExpireTimeLimit); removed != 1 {
+		t.Errorf("expect hash removed, actual %d", removed)
+	}
+	if _, exists := db.data.GetWithLock("renamed"); exists {
+		t.Error("hash should be removed after all fields expired")
+	}
+	if db.hashExpireIndex.Len() != 0 {
+		t.Errorf("expect empty hash expire index, actual %d", db.hashExpireIndex.Len())
+	}
+}
+
+func TestHashFieldExpireAofRewrite(t *testing.T) {
+	config.Properties.AppendOnly = true
+	config.Properties.AppendFilename = filepath.Join(t.TempDir(), "a.aof")
+	config.Properties.AppendFsync = "always"
+	defer func() {
+		config.Properties.AppendOnly = false
+	}()
+	// 2100-01-01
+	const expireAt = "4102444800000"
+	server := NewStandaloneServer()
+	conn := connection.NewFakeConn()
+	server.Exec(conn, utils.ToCmdLine("HSET", "h", "a", "1"))
+	server.Exec(conn, utils.ToCmdLine("HSET", "h", "b", "2"))
+	server.Exec(conn, utils.ToCmdLine("HSET", "h", "c", "3"))
+	server.Exec(conn, utils.ToCmdLine("HPEXPIREAT", "h", expireAt, "FIELDS", "2", "a", "b"))
+	if reply := server.Exec(conn, utils.ToCmdLine("REWRITEAOF")); string(reply.ToBytes()) != "+OK\r\n" {
+		t.Fatalf("rewrite failed: %q", reply.ToBytes())
+	}
+	server.Close()
+
+	server = NewStandaloneServer()
+	defer server.Close()
+	conn = connection.NewFakeConn()
+	reply := server.Exec(conn, utils.ToCmdLine("HPEXPIRETIME", "h", "FIELDS", "3", "a", "b", "c"))
+	if actual := string(reply.ToBytes()); actual != "*3\r\n:"+expireAt+"\r\n:"+expireAt+"\r\n:-1\r\n" {
+		t.Errorf("unexpected field expiration after rewrite %q", actual)
+	}
+}
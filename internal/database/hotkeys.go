@@ -0,0 +1,65 @@
+package database
+
+import (
+	"Godis/interface/redis"
+	"Godis/internal/config"
+	"Godis/lib/hotkeys"
+	"Godis/redis/protocol"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// hotKeysCapacity is max keys counted in each half of the window
+	hotKeysCapacity = 1024
+	// defaultHotKeysWindow is the sliding window of access counts if hotkeys-window is not set
+	defaultHotKeysWindow = 60 * time.Second
+	// defaultHotKeysCount is count of keys returned by HOTKEYS without COUNT
+	defaultHotKeysCount = 10
+)
+
+// makeHotKeysTracker returns nil if hotkeys-sample-rate is not set
+func makeHotKeysTracker() *hotkeys.Tracker {
+	if config.Properties.HotKeysSampleRate <= 0 {
+		return nil
+	}
+	window := defaultHotKeysWindow
+	if config.Properties.HotKeysWindow > 0 {
+		window = time.Duration(config.Properties.HotKeysWindow) * time.Second
+	}
+	return hotkeys.New(config.Properties.HotKeysSampleRate, window, hotKeysCapacity)
+}
+
+// execHotKeys returns the hottest keys of current db and their estimated access counts: HOTKEYS [COUNT count]
+// 计数来自采样，是近似值；返回 [key, count] 数组，按访问次数从高到低排列
+func execHotKeys(db *DB, args [][]byte) redis.Reply {
+	if db.hotKeys == nil {
+		return protocol.MakeErrReply("ERR hotkeys sampling is disabled, set hotkeys-sample-rate to enable it")
+	}
+	count := defaultHotKeysCount
+	if len(args) > 0 {
+		if len(args) != 2 || strings.ToUpper(string(args[0])) != "COUNT" {
+			return protocol.MakeSyntaxErrReply()
+		}
+		n, err := strconv.Atoi(string(args[1]))
+		if err != nil || n <= 0 {
+			return protocol.MakeErrReply("ERR count should be greater than 0")
+		}
+		count = n
+	}
+	entries := db.hotKeys.Top(db.index, count)
+	replies := make([]redis.Reply, len(entries))
+	for i, entry := range entries {
+		replies[i] = protocol.MakeMultiRawReply([]redis.Reply{
+			protocol.MakeBulkReply([]byte(entry.Key)),
+			protocol.MakeIntReply(entry.Count),
+		})
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+func init() {
+	registerCommand("HotKeys", execHotKeys, noPrepare, nil, -1, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagAdmin, redisFlagRandom}, 0, 0, 0)
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"Godis/internal/config"
+	"Godis/internal/redis/connection"
+	"Godis/lib/utils"
+	"testing"
+)
+
+func TestHotKeys(t *testing.T) {
+	config.Properties.HotKeysSampleRate = 1
+	defer func() {
+		config.Properties.HotKeysSampleRate = 0
+	}()
+	server := NewStandaloneServer()
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(server.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+
+	for i := 0; i < 5; i++ {
+		exec("INCR", "hot")
+	}
+	exec("MGET", "hot", "warm")
+	exec("SET", "warm", "1")
+	if reply := exec("HOTKEYS", "COUNT", "2"); reply != "*2\r\n*2\r\n$3\r\nhot\r\n:6\r\n*2\r\n$4\r\nwarm\r\n:2\r\n" {
+		t.Errorf("unexpected hot keys %q", reply)
+	}
+	// 其他 db 的 key 不返回，FLUSHDB 之后继续统计
+	exec("SELECT", "1")
+	exec("FLUSHDB")
+	exec("GET", "hot")
+	if reply := exec("HOTKEYS"); reply != "*1\r\n*2\r\n$3\r\nhot\r\n:1\r\n" {
+		t.Errorf("unexpected hot keys of db 1 %q", reply)
+	}
+	for _, args := range [][]string{{"HOTKEYS", "COUNT"}, {"HOTKEYS", "COUNT", "0"}, {"HOTKEYS", "foo", "1"}} {
+		if reply := exec(args...); reply[0] != '-' {
+			t.Errorf("%v: expect error, actual %q", args, reply)
+		}
+	}
+
+	config.Properties.HotKeysSampleRate = 0
+	if reply := string(NewStandaloneServer().Exec(conn, utils.ToCmdLine("HOTKEYS")).ToBytes()); reply[0] != '-' {
+		t.Errorf("expect error when disabled, actual %q", reply)
+	}
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"Godis/internal/config"
+	"Godis/lib/random"
+	"Godis/redis/protocol"
+	"math"
+	"strconv"
+)
+
+// TTL 抖动: 同时写入的大量缓存key设置了相同的ttl时会在同一时刻过期，请求同时穿透到后端(thundering herd)
+// 开启后相对的过期时间(EX、PX、SETEX、EXPIRE、PEXPIRE 等)随机延长不超过 percent% 的时长，
+// 绝对的过期时间(EXAT、PXAT、EXPIREAT、PEXPIREAT)不受影响
+// 默认由 ttl-jitter 配置，SET 和 EXPIRE、PEXPIRE 可以通过 JITTER 选项单独指定
+// 抖动只在执行命令的节点上计算一次，AOF 和复制中记录的是绝对时间，重放时不会再次抖动
+
+// jitterNotGiven means JITTER option is not given, ttl-jitter is used
+const jitterNotGiven = -1
+
+// ttlJitter returns jitter percent of the command, the JITTER option overrides ttl-jitter
+func ttlJitter(option int) int {
+	if option != jitterNotGiven {
+		return option
+	}
+	return config.Properties.TTLJitter
+}
+
+// parseJitterArg parses the argument of JITTER option
+func parseJitterArg(arg []byte) (int, protocol.ErrorReply) {
+	percent, err := strconv.Atoi(string(arg))
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, protocol.MakeErrReply("ERR jitter should be an integer between 0 and 100")
+	}
+	return percent, nil
+}
+
+// addTTLJitter adds random time in [0, ttl * percent / 100] to ttl in milliseconds
+// 非正的ttl表示立即过期，不增加抖动
+func addTTLJitter(ms int64, percent int) int64 {
+	if ms <= 0 || percent <= 0 {
+		return ms
+	}
+	// 先除后乘，避免溢出
+	bound := ms/100*int64(percent) + ms%100*int64(percent)/100
+	if bound <= 0 || ms > math.MaxInt64-bound {
+		return ms
+	}
+	return ms + random.Int63n(bound+1)
+}
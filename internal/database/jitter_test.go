@@ -0,0 +1,90 @@
+package database
+
+import (
+	"Godis/internal/config"
+	"Godis/internal/redis/connection"
+	"Godis/lib/clock"
+	"Godis/lib/utils"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTTLJitter(t *testing.T) {
+	config.Properties.TTLJitter = 50
+	defer func() {
+		config.Properties.TTLJitter = 0
+	}()
+	server := NewStandaloneServer()
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(server.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+	fake := clock.NewFake(time.Now().Truncate(time.Millisecond))
+	clock.Set(fake)
+	defer clock.Set(nil)
+	pttl := func(key string) int64 {
+		reply := exec("PTTL", key)
+		ttl, err := strconv.ParseInt(reply[1:len(reply)-2], 10, 64)
+		if err != nil {
+			t.Fatalf("unexpected reply %q", reply)
+		}
+		return ttl
+	}
+
+	ttls := make(map[int64]struct{})
+	for i := 0; i < 20; i++ {
+		key := "k" + strconv.Itoa(i)
+		cmds := [][]string{
+			{"SET", key, "v", "EX", "100"},
+			{"SETEX", key, "100", "v"},
+			{"EXPIRE", key, "100"},
+			{"PEXPIRE", key, "100000"},
+			{"GETEX", key, "PX", "100000"},
+		}
+		for _, cmd := range cmds {
+			exec(cmd...)
+			ttl := pttl(key)
+			if ttl < 100000 || ttl > 150000 {
+				t.Errorf("%v: ttl %d out of jitter range", cmd, ttl)
+			}
+			ttls[ttl] = struct{}{}
+		}
+	}
+	if len(ttls) < 2 {
+		t.Error("ttls should be spread by jitter")
+	}
+
+	// 绝对时间和 JITTER 0 不增加抖动
+	expireAt := fake.Now().Add(100 * time.Second).UnixMilli()
+	exec("SET", "a", "v", "PXAT", strconv.FormatInt(expireAt, 10))
+	if ttl := pttl("a"); ttl != 100000 {
+		t.Errorf("absolute expire time should not be jittered, ttl %d", ttl)
+	}
+	exec("SET", "a", "v", "JITTER", "0", "EX", "100")
+	if ttl := pttl("a"); ttl != 100000 {
+		t.Errorf("JITTER 0 should disable jitter, ttl %d", ttl)
+	}
+	exec("PEXPIRE", "a", "200000", "JITTER", "0")
+	if ttl := pttl("a"); ttl != 200000 {
+		t.Errorf("JITTER 0 should disable jitter, ttl %d", ttl)
+	}
+	config.Properties.TTLJitter = 0
+	exec("EXPIRE", "a", "100", "JITTER", "10")
+	if ttl := pttl("a"); ttl < 100000 || ttl > 110000 {
+		t.Errorf("ttl %d out of jitter range", ttl)
+	}
+
+	for _, cmd := range [][]string{
+		{"SET", "a", "v", "JITTER", "10"},
+		{"SET", "a", "v", "EXAT", "99999999999", "JITTER", "10"},
+		{"SET", "a", "v", "EX", "100", "JITTER", "101"},
+		{"EXPIREAT", "a", "99999999999", "JITTER", "10"},
+		{"EXPIRE", "a", "100", "JITTER"},
+		{"EXPIRE", "a", "100", "JITTER", "-1"},
+	} {
+		if reply := exec(cmd...); reply[0] != '-' {
+			t.Errorf("%v: expect error, actual %q", cmd, reply)
+		}
+	}
+}
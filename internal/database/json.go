@@ -0,0 +1,194 @@
+package database
+
+import (
+	JSON "Godis/datastruct/json"
+	"Godis/interface/database"
+	"Godis/interface/redis"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"strings"
+)
+
+func (db *DB) getAsJSON(key string) (*JSON.Document, protocol.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	doc, ok := entity.Data.(*JSON.Document)
+	if !ok {
+		return nil, &protocol.WrongTypeErrReply{}
+	}
+	return doc, nil
+}
+
+func parseJSONPath(path string) (*JSON.Path, protocol.ErrorReply) {
+	p, err := JSON.ParsePath(path)
+	if err != nil {
+		return nil, protocol.MakeErrReply("ERR " + err.Error() + ": " + path)
+	}
+	return p, nil
+}
+
+func makeJSONPathNotExistsReply(path *JSON.Path) protocol.ErrorReply {
+	return protocol.MakeErrReply("ERR Path '" + path.String() + "' does not exist")
+}
+
+// execJSONSet sets value at path: JSON.SET key path value [NX | XX]
+// returns nil if nothing is set because of NX, XX or missing parent
+func execJSONSet(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	path, errReply := parseJSONPath(string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+	policy := JSON.SetAlways
+	if len(args) == 4 {
+		switch strings.ToUpper(string(args[3])) {
+		case "NX":
+			policy = JSON.SetIfNotExists
+		case "XX":
+			policy = JSON.SetIfExists
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	} else if len(args) > 4 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	doc, errReply := db.getAsJSON(key)
+	if errReply != nil {
+		return errReply
+	}
+	if doc == nil {
+		if policy == JSON.SetIfExists {
+			return &protocol.NullBulkReply{}
+		}
+		if !path.IsRoot() {
+			return protocol.MakeErrReply("ERR new objects must be created at the root")
+		}
+		doc, err := JSON.Parse(args[2])
+		if err != nil {
+			return protocol.MakeErrReply("ERR " + err.Error())
+		}
+		db.PutEntity(key, &database.DataEntity{
+			Data: doc,
+			Type: database.TypeJSON,
+		})
+		db.addAof(utils.ToCmdLine3("json.set", args...))
+		return protocol.MakeOkReply()
+	}
+	set, err := doc.Set(path, args[2], policy)
+	if err != nil {
+		return protocol.MakeErrReply("ERR " + err.Error())
+	}
+	if !set {
+		return &protocol.NullBulkReply{}
+	}
+	db.addAof(utils.ToCmdLine3("json.set", args...))
+	return protocol.MakeOkReply()
+}
+
+// execJSONGet returns values at paths: JSON.GET key [INDENT indent] [NEWLINE newline] [SPACE space] [path ...]
+// JSONPath returns an array of all matched values, legacy path returns the first matched value,
+// multiple paths return an object mapping each path to its result
+func execJSONGet(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	format := &JSON.Format{}
+	i := 1
+options:
+	for ; i+1 < len(args); i += 2 {
+		switch strings.ToUpper(string(args[i])) {
+		case "INDENT":
+			format.Indent = string(args[i+1])
+		case "NEWLINE":
+			format.Newline = string(args[i+1])
+		case "SPACE":
+			format.Space = string(args[i+1])
+		default:
+			break options
+		}
+	}
+	rawPaths := []string{"."}
+	if i < len(args) {
+		rawPaths = make([]string, 0, len(args)-i)
+		for _, arg := range args[i:] {
+			rawPaths = append(rawPaths, string(arg))
+		}
+	}
+	paths := make([]*JSON.Path, len(rawPaths))
+	// 只有全部是旧式路径时才使用旧式的返回值
+	legacy := true
+	for j, raw := range rawPaths {
+		path, errReply := parseJSONPath(raw)
+		if errReply != nil {
+			return errReply
+		}
+		paths[j] = path
+		legacy = legacy && path.IsLegacy()
+	}
+	doc, errReply := db.getAsJSON(key)
+	if errReply != nil {
+		return errReply
+	}
+	if doc == nil {
+		return &protocol.NullBulkReply{}
+	}
+	results := make([]interface{}, len(paths))
+	for j, path := range paths {
+		values := doc.Get(path)
+		if !legacy {
+			results[j] = values
+			continue
+		}
+		if len(values) == 0 {
+			return makeJSONPathNotExistsReply(path)
+		}
+		results[j] = values[0]
+	}
+	if len(paths) == 1 {
+		return protocol.MakeBulkReply(JSON.MarshalValue(results[0], format))
+	}
+	return protocol.MakeBulkReply(JSON.MarshalObject(rawPaths, results, format))
+}
+
+// execJSONDel removes values at path: JSON.DEL key [path], returns number of removed values
+// removing the root removes the key
+func execJSONDel(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	if len(args) > 2 {
+		return protocol.MakeSyntaxErrReply()
+	}
+	rawPath := "."
+	if len(args) == 2 {
+		rawPath = string(args[1])
+	}
+	path, errReply := parseJSONPath(rawPath)
+	if errReply != nil {
+		return errReply
+	}
+	doc, errReply := db.getAsJSON(key)
+	if errReply != nil {
+		return errReply
+	}
+	if doc == nil {
+		return protocol.MakeIntReply(0)
+	}
+	if path.IsRoot() {
+		db.Remove(key)
+		db.addAof(utils.ToCmdLine3("json.del", args...))
+		return protocol.MakeIntReply(1)
+	}
+	removed := doc.Del(path)
+	if removed > 0 {
+		db.addAof(utils.ToCmdLine3("json.del", args...))
+	}
+	return protocol.MakeIntReply(int64(removed))
+}
+
+func init() {
+	registerCommand("JSON.Set", execJSONSet, writeFirstKey, rollbackFirstKey, -4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, 1, 1)
+	registerCommand("JSON.Get", execJSONGet, readFirstKey, nil, -2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly}, 1, 1, 1)
+	registerCommand("JSON.Del", execJSONDel, writeFirstKey, rollbackFirstKey, -2, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite}, 1, 1, 1)
+}
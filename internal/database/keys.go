@@ -0,0 +1,605 @@
+package database
+
+import (
+	"Godis/interface/database"
+	"Godis/interface/redis"
+	"Godis/internal/aof"
+	"Godis/lib/clock"
+	"Godis/lib/utils"
+	"Godis/lib/wildcard"
+	"Godis/redis/protocol"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// execDel removes a key from db
+func execDel(db *DB, args [][]byte) redis.Reply {
+	keys := make([]string, len(args))
+	for i, v := range args {
+		keys[i] = string(v)
+	}
+
+	// 删除前记录存在的key，用于发布 del 事件
+	notified := db.presentKeys(keys)
+	var deleted int
+	if ttl := trashTTL(); ttl > 0 {
+		deleted = db.moveToTrash(ttl, keys...)
+	} else {
+		deleted = db.Removes(keys...)
+	}
+	if deleted > 0 {
+		db.addAof(utils.ToCmdLine3("del", args...))
+	}
+	for _, key := range notified {
+		db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	}
+	return protocol.MakeIntReply(int64(deleted))
+}
+
+func undoDel(db *DB, args [][]byte) []CmdLine {
+	keys := make([]string, len(args))
+	for i, v := range args {
+		keys[i] = string(v)
+	}
+	return rollbackGivenKeys(db, keys...)
+}
+
+// execExists returns how many of given keys exist, a key given multiple times is counted multiple times
+// EXISTS 和 TYPE 只持有读锁，与 Redis 的 LOOKUP_NOTOUCH 相同，通过 peekEntity 读取，不删除已过期的key也不记录访问
+func execExists(db *DB, args [][]byte) redis.Reply {
+	result := int64(0)
+	for _, arg := range args {
+		key := string(arg)
+		_, exists := db.peekEntity(key)
+		if exists {
+			result++
+		}
+	}
+	return protocol.MakeIntReply(result)
+}
+
+// execFlushDB removes all data in current db
+// deprecated, use Server.flushDB
+func execFlushDB(db *DB, args [][]byte) redis.Reply {
+	db.Flush()
+	db.addAof(utils.ToCmdLine3("flushdb", args...))
+	return &protocol.OkReply{}
+}
+
+// execType returns the type of entity, including: string, list, hash, set, zset and module types
+// 类型名来自 DataEntity.Type 标记，不需要对 Data 做类型断言；同一类型的不同编码(如 listpack 和 hashtable)返回相同的类型名，编码见 OBJECT ENCODING
+func execType(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	entity, exists := db.peekEntity(key)
+	if !exists {
+		return protocol.MakeStatusReply("none")
+	}
+	return protocol.MakeStatusReply(entity.Type.String())
+}
+
+func prepareRename(args [][]byte) ([]string, []string) {
+	src := string(args[0])
+	dest := string(args[1])
+	return []string{dest}, []string{src}
+}
+
+// execRename a key
+func execRename(db *DB, args [][]byte) redis.Reply {
+	if len(args) != 2 {
+		return protocol.MakeErrReply("ERR wrong number of arguments for 'rename' command")
+	}
+	src := string(args[0])
+	dest := string(args[1])
+
+	entity, ok := db.GetEntity(src)
+	if !ok {
+		return protocol.MakeErrReply("no such key")
+	}
+	expireTime, hasTTL := db.ttlMap.Get(src)
+	db.PutEntity(dest, entity)
+	db.Remove(src)
+	if hasTTL {
+		db.Persist(src) // clean src and dest with their ttl
+		db.Persist(dest)
+		db.Expire(dest, expireTime)
+	}
+	db.addAof(utils.ToCmdLine3("rename", args...))
+	db.notifyKeyspaceEvent(notifyGeneric, "rename_from", src)
+	db.notifyKeyspaceEvent(notifyGeneric, "rename_to", dest)
+	return &protocol.OkReply{}
+}
+
+func undoRename(db *DB, args [][]byte) []CmdLine {
+	src := string(args[0])
+	dest := string(args[1])
+	return rollbackGivenKeys(db, src, dest)
+}
+
+// execRenameNx a key, only if the new key does not exist
+func execRenameNx(db *DB, args [][]byte) redis.Reply {
+	src := string(args[0])
+	dest := string(args[1])
+
+	_, ok := db.GetEntity(dest)
+	if ok {
+		return protocol.MakeIntReply(0)
+	}
+
+	entity, ok := db.GetEntity(src)
+	if !ok {
+		return protocol.MakeErrReply("no such key")
+	}
+	expireTime, hasTTL := db.ttlMap.Get(src)
+	db.Removes(src, dest) // clean src and dest with their ttl
+	db.PutEntity(dest, entity)
+	if hasTTL {
+		db.Persist(src) // clean src and dest with their ttl
+		db.Persist(dest)
+		db.Expire(dest, expireTime)
+	}
+	db.addAof(utils.ToCmdLine3("renamenx", args...))
+	db.notifyKeyspaceEvent(notifyGeneric, "rename_from", src)
+	db.notifyKeyspaceEvent(notifyGeneric, "rename_to", dest)
+	return protocol.MakeIntReply(1)
+}
+
+// expireFlags are the conditions of EXPIRE family, same as redis 7.0
+type expireFlags uint8
+
+const (
+	// expireNX sets expiration only when the key has no expiration
+	expireNX expireFlags = 1 << iota
+	// expireXX sets expiration only when the key has an expiration
+	expireXX
+	// expireGT sets expiration only when the new expiration is greater than current one
+	expireGT
+	// expireLT sets expiration only when the new expiration is less than current one
+	expireLT
+)
+
+// parseExpireOptions parses [NX | XX | GT | LT] [JITTER percent] options, XX could be used with GT or LT
+// jitter is jitterNotGiven if JITTER is not given
+func parseExpireOptions(args [][]byte) (flags expireFlags, jitter int, errReply protocol.ErrorReply) {
+	jitter = jitterNotGiven
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch strings.ToUpper(string(arg)) {
+		case "NX":
+			flags |= expireNX
+		case "XX":
+			flags |= expireXX
+		case "GT":
+			flags |= expireGT
+		case "LT":
+			flags |= expireLT
+		case "JITTER":
+			if i+1 >= len(args) {
+				return 0, 0, protocol.MakeSyntaxErrReply()
+			}
+			if jitter, errReply = parseJitterArg(args[i+1]); errReply != nil {
+				return 0, 0, errReply
+			}
+			i++
+		default:
+			return 0, 0, protocol.MakeErrReply("ERR Unsupported option " + string(arg))
+		}
+	}
+	if flags&expireNX != 0 && flags&(expireXX|expireGT|expireLT) != 0 {
+		return 0, 0, protocol.MakeErrReply("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	if flags&expireGT != 0 && flags&expireLT != 0 {
+		return 0, 0, protocol.MakeErrReply("ERR GT and LT options at the same time are not compatible")
+	}
+	return flags, jitter, nil
+}
+
+// allow returns whether expiration of key could be updated to expireAt
+// 与 redis 一致，没有过期时间的 key 视为过期时间无穷大：GT 总是不满足，LT 总是满足
+func (flags expireFlags) allow(current time.Time, hasTTL bool, expireAt time.Time) bool {
+	switch {
+	case flags&expireNX != 0 && hasTTL:
+		return false
+	case flags&expireXX != 0 && !hasTTL:
+		return false
+	case flags&expireGT != 0 && (!hasTTL || !expireAt.After(current)):
+		return false
+	case flags&expireLT != 0 && hasTTL && !expireAt.Before(current):
+		return false
+	}
+	return true
+}
+
+// parseExpireTime converts argument of EXPIRE family into absolute time
+// unit is milliseconds of the argument, relative means the argument is ttl rather than unix time
+// jitter is percent of random time added to relative ttl, see addTTLJitter
+func parseExpireTime(cmd string, arg []byte, unit int64, relative bool, jitter int) (time.Time, protocol.ErrorReply) {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return time.Time{}, protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if n > math.MaxInt64/unit || n < math.MinInt64/unit {
+		return time.Time{}, makeInvalidExpireErrReply(cmd)
+	}
+	ms := n * unit
+	if relative {
+		ms = addTTLJitter(ms, jitter)
+		now := clock.Now().UnixMilli()
+		if ms > math.MaxInt64-now {
+			return time.Time{}, makeInvalidExpireErrReply(cmd)
+		}
+		ms += now
+	}
+	return time.UnixMilli(ms), nil
+}
+
+// execExpireGeneric implements EXPIRE family: CMD key time [NX | XX | GT | LT] [JITTER percent]
+// 过期时间已经过去时直接删除 key，AOF 中记录为 DEL，否则记录为 PEXPIREAT，重放时不依赖执行时刻
+// JITTER 只能用于相对的过期时间
+func execExpireGeneric(db *DB, args [][]byte, cmd string, unit int64, relative bool) redis.Reply {
+	key := string(args[0])
+	flags, jitter, errReply := parseExpireOptions(args[2:])
+	if errReply != nil {
+		return errReply
+	}
+	if jitter != jitterNotGiven && !relative {
+		return protocol.MakeSyntaxErrReply()
+	}
+	expireAt, errReply := parseExpireTime(cmd, args[1], unit, relative, ttlJitter(jitter))
+	if errReply != nil {
+		return errReply
+	}
+
+	_, exists := db.GetEntity(key)
+	if !exists {
+		return protocol.MakeIntReply(0)
+	}
+	current, hasTTL := db.ttlMap.Get(key)
+	if !flags.allow(current, hasTTL, expireAt) {
+		return protocol.MakeIntReply(0)
+	}
+	if !expireAt.After(clock.Now()) {
+		db.Remove(key)
+		db.addAof(utils.ToCmdLine3("del", args[0]))
+		db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+		return protocol.MakeIntReply(1)
+	}
+	db.Expire(key, expireAt)
+	db.addAof(aof.MakeExpireCmd(key, expireAt).Args)
+	db.notifyKeyspaceEvent(notifyGeneric, "expire", key)
+	return protocol.MakeIntReply(1)
+}
+
+// execExpire sets a key's time to live in seconds
+func execExpire(db *DB, args [][]byte) redis.Reply {
+	return execExpireGeneric(db, args, "expire", 1000, true)
+}
+
+// execExpireAt sets a key's expiration in unix timestamp
+func execExpireAt(db *DB, args [][]byte) redis.Reply {
+	return execExpireGeneric(db, args, "expireat", 1000, false)
+}
+
+// execExpireTime returns the absolute Unix expiration timestamp in seconds at which the given key will expire.
+func execExpireTime(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	expireTime, hasTTL, exists := db.peekExpireTime(key)
+	if !exists {
+		return protocol.MakeIntReply(-2)
+	}
+	if !hasTTL {
+		return protocol.MakeIntReply(-1)
+	}
+	return protocol.MakeIntReply(expireTimeInSeconds(expireTime))
+}
+
+// expireTimeInSeconds 与 Redis 一致，毫秒时间戳四舍五入到秒
+func expireTimeInSeconds(expireTime time.Time) int64 {
+	return (expireTime.UnixMilli() + 500) / 1000
+}
+
+// execPExpire sets a key's time to live in milliseconds
+func execPExpire(db *DB, args [][]byte) redis.Reply {
+	return execExpireGeneric(db, args, "pexpire", 1, true)
+}
+
+// execPExpireAt sets a key's expiration in unix timestamp specified in milliseconds
+func execPExpireAt(db *DB, args [][]byte) redis.Reply {
+	return execExpireGeneric(db, args, "pexpireat", 1, false)
+}
+
+// execPExpireTime returns the absolute Unix expiration timestamp in milliseconds at which the given key will expire.
+func execPExpireTime(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	expireTime, hasTTL, exists := db.peekExpireTime(key)
+	if !exists {
+		return protocol.MakeIntReply(-2)
+	}
+	if !hasTTL {
+		return protocol.MakeIntReply(-1)
+	}
+	return protocol.MakeIntReply(expireTime.UnixMilli())
+}
+
+// execTTL returns a key's time to live in seconds
+func execTTL(db *DB, args [][]byte) redis.Reply {
+	return ttlGeneric(db, string(args[0]), false)
+}
+
+// execPTTL returns a key's time to live in milliseconds
+func execPTTL(db *DB, args [][]byte) redis.Reply {
+	return ttlGeneric(db, string(args[0]), true)
+}
+
+// ttlGeneric returns -2 if key does not exist, -1 if key has no expiration
+// TTL 只持有读锁，不能像 GetEntity 那样删除已过期的key，因此通过 peekExpireTime 读取
+func ttlGeneric(db *DB, key string, inMillis bool) redis.Reply {
+	expireTime, hasTTL, exists := db.peekExpireTime(key)
+	if !exists {
+		return protocol.MakeIntReply(-2)
+	}
+	if !hasTTL {
+		return protocol.MakeIntReply(-1)
+	}
+	// 判断过期之后时间仍在流逝，与 Redis 一致，剩余时间最小为0
+	ttl := expireTime.Sub(clock.Now()).Milliseconds()
+	if ttl < 0 {
+		ttl = 0
+	}
+	if inMillis {
+		return protocol.MakeIntReply(ttl)
+	}
+	// 与 Redis 一致，四舍五入到秒
+	return protocol.MakeIntReply((ttl + 500) / 1000)
+}
+
+// execPersist removes expiration from a key
+func execPersist(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	_, exists := db.GetEntity(key)
+	if !exists {
+		return protocol.MakeIntReply(0)
+	}
+
+	_, exists = db.ttlMap.Get(key)
+	if !exists {
+		return protocol.MakeIntReply(0)
+	}
+
+	db.Persist(key)
+	db.addAof(utils.ToCmdLine3("persist", args...))
+	db.notifyKeyspaceEvent(notifyGeneric, "persist", key)
+	return protocol.MakeIntReply(1)
+}
+
+// execKeys returns all keys matching the given pattern
+func execKeys(db *DB, args [][]byte) redis.Reply {
+	pattern, err := wildcard.CompilePattern(string(args[0]))
+	if err != nil {
+		return protocol.MakeErrReply("ERR illegal wildcard")
+	}
+	result := make([][]byte, 0)
+	db.data.ForEach(func(key string, _ *database.DataEntity) bool {
+		if !pattern.IsMatch(key) {
+			return true
+		}
+		if !db.IsExpired(key) {
+			result = append(result, []byte(key))
+		}
+		return true
+	})
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// execScan iterates keys of db without blocking it, unlike KEYS only one shard is locked at a time
+// usage: SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+// 基于 dict.Concurrent.Scan，遍历期间一直存在的key至少返回一次；MATCH 和 TYPE 在遍历之后过滤，因此返回的key可能少于 COUNT 甚至为空
+func execScan(db *DB, args [][]byte) redis.Reply {
+	typeName := ""
+	rest := make([][]byte, 0, len(args))
+	rest = append(rest, args[0])
+	for i := 1; i < len(args); i += 2 {
+		if strings.ToLower(string(args[i])) == "type" && i+1 < len(args) {
+			typeName = strings.ToLower(string(args[i+1]))
+			continue
+		}
+		rest = append(rest, args[i])
+		if i+1 < len(args) {
+			rest = append(rest, args[i+1])
+		}
+	}
+	cursor, pattern, count, errReply := parseScanArgs(rest)
+	if errReply != nil {
+		return errReply
+	}
+	keys, next := db.data.Scan(cursor, count)
+	result := make([][]byte, 0, len(keys))
+	now := clock.Now()
+	for _, key := range keys {
+		if pattern != nil && !pattern.IsMatch(key) {
+			continue
+		}
+		// SCAN 不持有key的锁，只跳过已过期的key而不删除
+		if expireTime, hasTTL := db.ttlMap.Get(key); hasTTL && now.After(expireTime) {
+			continue
+		}
+		if typeName != "" {
+			entity, ok := db.data.Get(key)
+			if !ok || strings.ToLower(entity.Type.String()) != typeName {
+				continue
+			}
+		}
+		result = append(result, []byte(key))
+	}
+	return makeScanReply(next, result)
+}
+
+// parseScanArgs parses `cursor [MATCH pattern] [COUNT count]` of SSCAN/HSCAN, pattern is nil if not given
+func parseScanArgs(args [][]byte) (cursor int, pattern *wildcard.Pattern, count int, errReply protocol.ErrorReply) {
+	cursor, err := strconv.Atoi(string(args[0]))
+	if err != nil || cursor < 0 {
+		return 0, nil, 0, protocol.MakeErrReply("ERR invalid cursor")
+	}
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return 0, nil, 0, protocol.MakeSyntaxErrReply()
+		}
+		switch strings.ToLower(string(args[i])) {
+		case "match":
+			pattern, err = wildcard.CompilePattern(string(args[i+1]))
+			if err != nil {
+				return 0, nil, 0, protocol.MakeErrReply("ERR illegal wildcard")
+			}
+		case "count":
+			count, err = strconv.Atoi(string(args[i+1]))
+			if err != nil {
+				return 0, nil, 0, protocol.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			if count < 1 {
+				return 0, nil, 0, protocol.MakeSyntaxErrReply()
+			}
+		default:
+			return 0, nil, 0, protocol.MakeSyntaxErrReply()
+		}
+	}
+	return cursor, pattern, count, nil
+}
+
+// makeScanReply makes reply of SCAN family commands: the next cursor and the elements
+func makeScanReply(cursor int, elements [][]byte) redis.Reply {
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte(strconv.Itoa(cursor))),
+		protocol.MakeMultiBulkReply(elements),
+	})
+}
+
+func toTTLCmd(db *DB, key string) *protocol.MultiBulkReply {
+	expireTime, exists := db.ttlMap.Get(key)
+	if !exists {
+		// has no TTL
+		return protocol.MakeMultiBulkReply(utils.ToCmdLine("PERSIST", key))
+	}
+	timestamp := strconv.FormatInt(expireTime.UnixNano()/1000/1000, 10)
+	return protocol.MakeMultiBulkReply(utils.ToCmdLine("PEXPIREAT", key, timestamp))
+}
+
+func undoExpire(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	return []CmdLine{
+		toTTLCmd(db, key).Args,
+	}
+}
+
+// makeUndoExpire returns undo function of EXPIRE family, unit and relative are same as parseExpireTime
+// 过期时间已经过去时命令会直接删除key，回滚时需要连同值一起恢复
+func makeUndoExpire(unit int64, relative bool) UndoFunc {
+	return func(db *DB, args [][]byte) []CmdLine {
+		key := string(args[0])
+		expireAt, errReply := parseExpireTime("", args[1], unit, relative, 0)
+		if errReply != nil || expireAt.After(clock.Now()) {
+			return undoExpire(db, args)
+		}
+		if _, exists := db.GetEntity(key); !exists {
+			return nil
+		}
+		return rollbackGivenKeys(db, key)
+	}
+}
+
+// execCopy usage: COPY source destination [DB destination-db] [REPLACE]
+// This command copies the value stored at the source key to the destination key.
+func execCopy(mdb *Server, conn redis.Connection, args [][]byte) redis.Reply {
+	dbIndex := conn.GetDBIndex()
+	db := mdb.mustSelectDB(dbIndex) // Current DB
+	replaceFlag := false
+	srcKey := string(args[0])
+	destKey := string(args[1])
+
+	// Parse options
+	if len(args) > 2 {
+		for i := 2; i < len(args); i++ {
+			arg := strings.ToLower(string(args[i]))
+			if arg == "db" {
+				if i+1 >= len(args) {
+					return &protocol.SyntaxErrReply{}
+				}
+				idx, err := strconv.Atoi(string(args[i+1]))
+				if err != nil {
+					return &protocol.SyntaxErrReply{}
+				}
+				if idx >= len(mdb.dbSet) || idx < 0 {
+					return protocol.MakeErrReply("ERR DB index is out of range")
+				}
+				dbIndex = idx
+				i++
+			} else if arg == "replace" {
+				replaceFlag = true
+			} else {
+				return &protocol.SyntaxErrReply{}
+			}
+		}
+	}
+
+	if srcKey == destKey && dbIndex == conn.GetDBIndex() {
+		return protocol.MakeErrReply("ERR source and destination objects are the same")
+	}
+
+	// source key does not exist
+	src, exists := db.GetEntity(srcKey)
+	if !exists {
+		return protocol.MakeIntReply(0)
+	}
+
+	destDB := mdb.mustSelectDB(dbIndex)
+	if _, exists = destDB.GetEntity(destKey); exists != false {
+		// If destKey exists and there is no "replace" option
+		if replaceFlag == false {
+			return protocol.MakeIntReply(0)
+		}
+	}
+
+	destDB.PutEntity(destKey, src)
+	expire, exists := db.ttlMap.Get(srcKey)
+	if exists {
+		destDB.Expire(destKey, expire)
+	}
+	mdb.AddAof(conn.GetDBIndex(), utils.ToCmdLine3("copy", args...))
+	destDB.notifyKeyspaceEvent(notifyGeneric, "copy_to", destKey)
+	return protocol.MakeIntReply(1)
+}
+
+func init() {
+	registerCommand("Del", execDel, writeAllKeys, undoDel, -2, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite}, 1, -1, 1)
+	registerCommand("Expire", execExpire, writeFirstKey, makeUndoExpire(1000, true), -3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("ExpireAt", execExpireAt, writeFirstKey, makeUndoExpire(1000, false), -3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("ExpireTime", execExpireTime, readFirstKey, nil, 2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("PExpire", execPExpire, writeFirstKey, makeUndoExpire(1, true), -3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("PExpireAt", execPExpireAt, writeFirstKey, makeUndoExpire(1, false), -3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("PExpireTime", execPExpireTime, readFirstKey, nil, 2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("TTL", execTTL, readFirstKey, nil, 2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagRandom, redisFlagFast}, 1, 1, 1)
+	registerCommand("PTTL", execPTTL, readFirstKey, nil, 2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagRandom, redisFlagFast}, 1, 1, 1)
+	registerCommand("Persist", execPersist, writeFirstKey, undoExpire, 2, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("Exists", execExists, readAllKeys, nil, -2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("Type", execType, readFirstKey, nil, 2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("Rename", execRename, prepareRename, undoRename, 3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagWrite}, 1, 1, 1)
+	registerCommand("RenameNx", execRenameNx, prepareRename, undoRename, 3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("Keys", execKeys, noPrepare, nil, 2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagSortForScript}, 1, 1, 1)
+	registerCommand("Scan", execScan, noPrepare, nil, -2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagRandom}, 0, 0, 0)
+}
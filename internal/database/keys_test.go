@@ -0,0 +1,382 @@
+package database
+
+import (
+	"Godis/interface/redis"
+	"Godis/internal/redis/connection"
+	"Godis/lib/clock"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpireOptions(t *testing.T) {
+	server := NewStandaloneServer()
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(server.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"EXPIRE", "k", "100"}, ":0\r\n"},
+		{[]string{"SET", "k", "v"}, "+OK\r\n"},
+		{[]string{"EXPIRE", "k", "100", "XX"}, ":0\r\n"},
+		{[]string{"EXPIRE", "k", "100", "GT"}, ":0\r\n"},
+		{[]string{"EXPIRE", "k", "100", "NX"}, ":1\r\n"},
+		{[]string{"EXPIRE", "k", "200", "NX"}, ":0\r\n"},
+		{[]string{"EXPIRE", "k", "50", "GT"}, ":0\r\n"},
+		{[]string{"EXPIRE", "k", "200", "XX", "GT"}, ":1\r\n"},
+		{[]string{"EXPIRE", "k", "199", "GT"}, ":0\r\n"},
+		{[]string{"PEXPIRE", "k", "300000", "LT"}, ":0\r\n"},
+		{[]string{"PEXPIRE", "k", "100000", "lt"}, ":1\r\n"},
+		{[]string{"EXPIRE", "k", "101", "LT"}, ":0\r\n"},
+		{[]string{"PERSIST", "k"}, ":1\r\n"},
+		{[]string{"EXPIREAT", "k", "99999999999", "LT"}, ":1\r\n"},
+		{[]string{"EXPIRE", "k", "100", "NX", "XX"}, "-ERR NX and XX, GT or LT options at the same time are not compatible\r\n"},
+		{[]string{"EXPIRE", "k", "100", "GT", "LT"}, "-ERR GT and LT options at the same time are not compatible\r\n"},
+		{[]string{"EXPIRE", "k", "100", "YY"}, "-ERR Unsupported option YY\r\n"},
+		{[]string{"EXPIRE", "k", "abc"}, "-ERR value is not an integer or out of range\r\n"},
+		{[]string{"EXPIRE", "k", "9223372036854775807"}, "-ERR invalid expire time in 'expire' command\r\n"},
+		{[]string{"PEXPIRE", "k", "9223372036854775807"}, "-ERR invalid expire time in 'pexpire' command\r\n"},
+		// 过期时间已经过去时直接删除 key
+		{[]string{"PEXPIREAT", "k", "1"}, ":1\r\n"},
+		{[]string{"EXISTS", "k"}, ":0\r\n"},
+		{[]string{"SET", "k", "v"}, "+OK\r\n"},
+		{[]string{"EXPIRE", "k", "-1"}, ":1\r\n"},
+		{[]string{"EXISTS", "k"}, ":0\r\n"},
+	}
+	for _, c := range cases {
+		if reply := exec(c.args...); reply != c.expected {
+			t.Errorf("%v: expect %q, actual %q", c.args, c.expected, reply)
+		}
+	}
+}
+
+func TestTTL(t *testing.T) {
+	server := NewStandaloneServer()
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(server.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+	now := time.Now().Truncate(time.Second)
+	fake := clock.NewFake(now)
+	clock.Set(fake)
+	defer clock.Set(nil)
+
+	exec("SET", "k", "v", "PX", "1500")
+	exec("SET", "persistent", "v")
+	expireAt := now.Add(1500 * time.Millisecond).UnixMilli()
+	cases := []struct {
+		args     []string
+		expected int64
+	}{
+		{[]string{"TTL", "missing"}, -2},
+		{[]string{"PTTL", "missing"}, -2},
+		{[]string{"EXPIRETIME", "missing"}, -2},
+		{[]string{"PEXPIRETIME", "missing"}, -2},
+		{[]string{"TTL", "persistent"}, -1},
+		{[]string{"PTTL", "persistent"}, -1},
+		{[]string{"EXPIRETIME", "persistent"}, -1},
+		{[]string{"PEXPIRETIME", "persistent"}, -1},
+		// 与 Redis 一致，TTL 四舍五入到秒
+		{[]string{"TTL", "k"}, 2},
+		{[]string{"PTTL", "k"}, 1500},
+		{[]string{"EXPIRETIME", "k"}, (expireAt + 500) / 1000},
+		{[]string{"PEXPIRETIME", "k"}, expireAt},
+	}
+	for _, c := range cases {
+		if reply := exec(c.args...); reply != ":"+strconv.FormatInt(c.expected, 10)+"\r\n" {
+			t.Errorf("%v: expect %d, actual %q", c.args, c.expected, reply)
+		}
+	}
+
+	fake.Advance(1500 * time.Millisecond)
+	if reply := exec("PTTL", "k"); reply != ":0\r\n" {
+		t.Errorf("expect 0 at expire time, actual %q", reply)
+	}
+	fake.Advance(time.Millisecond)
+	for _, cmd := range []string{"TTL", "PTTL", "EXPIRETIME", "PEXPIRETIME"} {
+		if reply := exec(cmd, "k"); reply != ":-2\r\n" {
+			t.Errorf("%s: expect -2 after expired, actual %q", cmd, reply)
+		}
+	}
+}
+
+func TestExistsType(t *testing.T) {
+	db := makeDB()
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(db.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+	now := time.Now()
+	fake := clock.NewFake(now)
+	clock.Set(fake)
+	defer clock.Set(nil)
+
+	exec("SET", "s", "v")
+	exec("RPUSH", "l", "a")
+	exec("HSET", "h", "f", "v")
+	exec("SADD", "set", "m")
+	exec("ZADD", "z", "1", "m")
+	exec("SET", "expiring", "v", "PX", "100")
+	exec("SET", "int", "100")
+	exec("BF.ADD", "bf", "a")
+	exec("CF.ADD", "cf", "a")
+	exec("TS.ADD", "ts", "1", "1")
+	exec("JSON.SET", "json", "$", "{}")
+	// 重复的key按出现次数计数
+	if reply := exec("EXISTS", "s", "l", "s", "missing", "expiring"); reply != ":4\r\n" {
+		t.Errorf("expect 4, actual %q", reply)
+	}
+	for key, typeName := range map[string]string{
+		"s": "string", "l": "list", "h": "hash", "set": "set", "z": "zset", "missing": "none",
+		// 不同编码的字符串类型相同
+		"int": "string",
+		"bf":  "MBbloom--", "cf": "MBbloomCF", "ts": "TSDB-TYPE", "json": "ReJSON-RL",
+	} {
+		if reply := exec("TYPE", key); reply != "+"+typeName+"\r\n" {
+			t.Errorf("TYPE %s: expect %s, actual %q", key, typeName, reply)
+		}
+	}
+
+	// 已过期的key视为不存在，但只持有读锁的 EXISTS 和 TYPE 不会删除它
+	fake.Advance(100 * time.Millisecond)
+	if reply := exec("EXISTS", "expiring"); reply != ":0\r\n" {
+		t.Errorf("expect expired key not exist, actual %q", reply)
+	}
+	if reply := exec("TYPE", "expiring"); reply != "+none\r\n" {
+		t.Errorf("expect none for expired key, actual %q", reply)
+	}
+	if _, ok := db.data.Get("expiring"); !ok {
+		t.Error("expired key should be removed by write commands or active expiring only")
+	}
+}
+
+func TestPersistRollback(t *testing.T) {
+	server := NewStandaloneServer()
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(server.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+	exec("SET", "k", "v", "EX", "100")
+	exec("SET", "s", "abc")
+	before := exec("PEXPIRETIME", "k")
+
+	// 事务中的 INCR 失败，PERSIST 需要回滚
+	exec("MULTI")
+	exec("PERSIST", "k")
+	exec("INCR", "s")
+	if reply := exec("EXEC"); reply[0] != '-' {
+		t.Fatalf("expect exec abort, actual %q", reply)
+	}
+	if reply := exec("PEXPIRETIME", "k"); reply != before {
+		t.Errorf("expire time should be restored, expect %q, actual %q", before, reply)
+	}
+
+	// 过期时间已经过去的 EXPIRE 删除了key，回滚时恢复key和原来的过期时间
+	exec("MULTI")
+	exec("EXPIRE", "k", "-1")
+	exec("INCR", "s")
+	exec("EXEC")
+	if reply := exec("GET", "k"); reply != "$1\r\nv\r\n" {
+		t.Errorf("removed key should be restored, actual %q", reply)
+	}
+	if reply := exec("PEXPIRETIME", "k"); reply != before {
+		t.Errorf("expire time should be restored, expect %q, actual %q", before, reply)
+	}
+
+	exec("MULTI")
+	exec("PERSIST", "k")
+	exec("EXEC")
+	if reply := exec("TTL", "k"); reply != ":-1\r\n" {
+		t.Errorf("expect persistent key, actual %q", reply)
+	}
+}
+
+func TestPersistAof(t *testing.T) {
+	db := makeDB()
+	var aofLines []string
+	db.addAof = func(line CmdLine) {
+		aofLines = append(aofLines, strings.ToLower(string(line[0])))
+	}
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(db.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+	exec("SET", "k", "v", "EX", "100")
+	exec("SET", "persistent", "v")
+	aofLines = nil
+	if reply := exec("PERSIST", "k"); reply != ":1\r\n" {
+		t.Errorf("expect 1, actual %q", reply)
+	}
+	// 没有过期时间或不存在的key不需要写入 aof
+	for _, key := range []string{"k", "persistent", "missing"} {
+		if reply := exec("PERSIST", key); reply != ":0\r\n" {
+			t.Errorf("PERSIST %s: expect 0, actual %q", key, reply)
+		}
+	}
+	if len(aofLines) != 1 || aofLines[0] != "persist" {
+		t.Errorf("expect only one persist in aof, actual %v", aofLines)
+	}
+}
+
+func TestExpireRollbackCollections(t *testing.T) {
+	db := makeDB()
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(db.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+	exec("SET", "s", "abc")
+	cases := []struct {
+		init  []string
+		check []string
+	}{
+		{[]string{"RPUSH", "k", "a", "b"}, []string{"LRANGE", "k", "0", "-1"}},
+		{[]string{"HSET", "k", "f", "v"}, []string{"HGETALL", "k"}},
+		{[]string{"SADD", "k", "m"}, []string{"SMEMBERS", "k"}},
+		{[]string{"ZADD", "k", "1.5", "a", "2", "b"}, []string{"ZRANGE", "k", "0", "-1", "WITHSCORES"}},
+	}
+	for _, c := range cases {
+		exec("DEL", "k")
+		exec(c.init...)
+		exec("PEXPIRE", "k", "100000")
+		before, ttl := exec(c.check...), exec("PEXPIRETIME", "k")
+
+		// 过期时间已经过去的 EXPIRE 删除了key，事务失败时回滚需要恢复整个集合
+		exec("MULTI")
+		exec("EXPIRE", "k", "-1")
+		exec("INCR", "s")
+		if reply := exec("EXEC"); reply[0] != '-' {
+			t.Fatalf("expect exec abort, actual %q", reply)
+		}
+		if reply := exec(c.check...); reply != before {
+			t.Errorf("%v: expect %q after rollback, actual %q", c.init, before, reply)
+		}
+		if reply := exec("PEXPIRETIME", "k"); reply != ttl {
+			t.Errorf("%v: expect expire time %q after rollback, actual %q", c.init, ttl, reply)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	db := makeDB()
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) redis.Reply {
+		return db.Exec(conn, utils.ToCmdLine(args...))
+	}
+	scanAll := func(during func(), args ...string) map[string]int {
+		seen := make(map[string]int)
+		cursor := "0"
+		for {
+			reply, ok := exec(append([]string{"SCAN", cursor}, args...)...).(*protocol.MultiRawReply)
+			if !ok {
+				t.Fatalf("unexpected reply of SCAN")
+			}
+			cursor = string(reply.Replies[0].(*protocol.BulkReply).Arg)
+			for _, key := range reply.Replies[1].(*protocol.MultiBulkReply).Args {
+				seen[string(key)]++
+			}
+			if during != nil {
+				during()
+			}
+			if cursor == "0" {
+				return seen
+			}
+		}
+	}
+
+	for i := 0; i < 300; i++ {
+		exec("SET", "str:"+strconv.Itoa(i), "v")
+		exec("SADD", "set:"+strconv.Itoa(i), "m")
+	}
+	exec("SET", "expired", "v", "PX", "1")
+	time.Sleep(5 * time.Millisecond)
+
+	seen := scanAll(nil, "COUNT", "20")
+	if len(seen) != 600 {
+		t.Errorf("expect 600 keys, actual %d", len(seen))
+	}
+	if _, ok := seen["expired"]; ok {
+		t.Error("expired key should not be returned")
+	}
+	seen = scanAll(nil, "MATCH", "str:1*", "COUNT", "50")
+	if len(seen) != 111 {
+		t.Errorf("expect 111 keys matching str:1*, actual %d", len(seen))
+	}
+	seen = scanAll(nil, "TYPE", "SET")
+	for key := range seen {
+		if !strings.HasPrefix(key, "set:") {
+			t.Errorf("unexpected key %s of type set", key)
+		}
+	}
+	if len(seen) != 300 {
+		t.Errorf("expect 300 sets, actual %d", len(seen))
+	}
+
+	// 遍历期间写入和删除其他key，一直存在的key至少返回一次
+	added := 0
+	seen = scanAll(func() {
+		for j := 0; j < 5; j++ {
+			exec("SET", "new:"+strconv.Itoa(added), "v")
+			added++
+		}
+		exec("DEL", "set:"+strconv.Itoa(added/5))
+	}, "COUNT", "10")
+	for i := 0; i < 300; i++ {
+		if seen["str:"+strconv.Itoa(i)] == 0 {
+			t.Errorf("str:%d was not returned", i)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"SCAN", "abc"},
+		{"SCAN", "0", "COUNT"},
+		{"SCAN", "0", "COUNT", "0"},
+		{"SCAN", "0", "FOO", "bar"},
+	} {
+		if reply := exec(args...); !protocol.IsErrorReply(reply) {
+			t.Errorf("%v: expect error, actual %q", args, reply.ToBytes())
+		}
+	}
+}
+
+func TestActiveExpireCronStop(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	clock.Set(fake)
+	defer clock.Set(nil)
+	server := NewStandaloneServer()
+	conn := connection.NewFakeConn()
+	db := server.mustSelectDB(0)
+	server.Exec(conn, utils.ToCmdLine("SET", "a", "1", "PX", "50"))
+	fake.Advance(activeExpireInterval)
+	// 没有访问的key由主动过期删除
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := db.data.Get("a"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired key is not removed by active expiring")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	server.Close()
+	select {
+	case <-server.expireCronDone:
+	default:
+		t.Fatal("expire cron should exit after Close")
+	}
+	// 重复 Close 不会阻塞或 panic
+	server.stopExpireCron()
+	server.Exec(conn, utils.ToCmdLine("SET", "b", "1", "PX", "50"))
+	fake.Advance(activeExpireInterval)
+	time.Sleep(10 * time.Millisecond)
+	if _, ok := db.data.Get("b"); !ok {
+		t.Error("active expiring should be stopped after Close")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	List "Godis/datastruct/list"
 	"Godis/interface/database"
 	"Godis/interface/redis"
+	"Godis/internal/config"
 	"Godis/lib/utils"
 	"Godis/redis/protocol"
 )
@@ -24,6 +25,11 @@ func (db *DB) getAsList(key string) (List.List, protocol.ErrorReply) {
 	return list, nil
 }
 
+// makeList makes an empty list, small list is encoded as listpack
+func makeList() *List.PackedList {
+	return List.NewPackedList(config.Properties.ListMaxListpackEntries, config.Properties.ListMaxListpackValue)
+}
+
 func (db *DB) getOrInitList(key string) (list List.List, isNew bool, errReply protocol.ErrorReply) {
 	list, errReply = db.getAsList(key)
 	if errReply != nil {
@@ -31,9 +37,10 @@ func (db *DB) getOrInitList(key string) (list List.List, isNew bool, errReply pr
 	}
 	isNew = false
 	if list == nil {
-		list = List.NewQuickList()
+		list = makeList()
 		db.PutEntity(key, &database.DataEntity{
 			Data: list,
+			Type: database.TypeList,
 		})
 		isNew = true
 	}
@@ -103,10 +110,8 @@ func execLPop(db *DB, args [][]byte) redis.Reply {
 		return &protocol.NullBulkReply{}
 	}
 
-	val, _ := list.Remove(0).([]byte)
-	if list.Len() == 0 {
-		db.Remove(key)
-	}
+	val, _ := list.RemoveFirst().([]byte)
+	db.removeIfEmpty(key, list.Len())
 	db.addAof(utils.ToCmdLine3("lpop", args...))
 	return protocol.MakeBulkReply(val)
 }
@@ -145,7 +150,7 @@ func execLPush(db *DB, args [][]byte) redis.Reply {
 
 	// insert
 	for _, value := range values {
-		list.Insert(0, value)
+		list.AddFirst(value)
 	}
 
 	db.addAof(utils.ToCmdLine3("lpush", args...))
@@ -178,7 +183,7 @@ func execLPushX(db *DB, args [][]byte) redis.Reply {
 
 	// insert
 	for _, value := range values {
-		list.Insert(0, value)
+		list.AddFirst(value)
 	}
 	db.addAof(utils.ToCmdLine3("lpushx", args...))
 	return protocol.MakeIntReply(int64(list.Len()))
@@ -275,9 +280,7 @@ func execLRem(db *DB, args [][]byte) redis.Reply {
 		}, -count)
 	}
 
-	if list.Len() == 0 {
-		db.Remove(key)
-	}
+	db.removeIfEmpty(key, list.Len())
 	if removed > 0 {
 		db.addAof(utils.ToCmdLine3("lrem", args...))
 	}
@@ -367,9 +370,7 @@ func execRPop(db *DB, args [][]byte) redis.Reply {
 	}
 
 	val, _ := list.RemoveLast().([]byte)
-	if list.Len() == 0 {
-		db.Remove(key)
-	}
+	db.removeIfEmpty(key, list.Len())
 	db.addAof(utils.ToCmdLine3("rpop", args...))
 	return protocol.MakeBulkReply(val)
 }
@@ -424,11 +425,9 @@ func execRPopLPush(db *DB, args [][]byte) redis.Reply {
 
 	// pop and push
 	val, _ := sourceList.RemoveLast().([]byte)
-	destList.Insert(0, val)
+	destList.AddFirst(val)
 
-	if sourceList.Len() == 0 {
-		db.Remove(sourceKey)
-	}
+	db.removeIfEmpty(sourceKey, sourceList.Len())
 
 	db.addAof(utils.ToCmdLine3("rpoplpush", args...))
 	return protocol.MakeBulkReply(val)
@@ -550,11 +549,12 @@ func execLTrim(db *DB, args [][]byte) redis.Reply {
 	rightCount := length - end - 1
 
 	for i := 0; i < leftCount && list.Len() > 0; i++ {
-		list.Remove(0)
+		list.RemoveFirst()
 	}
 	for i := 0; i < rightCount && list.Len() > 0; i++ {
 		list.RemoveLast()
 	}
+	db.removeIfEmpty(key, list.Len())
 
 	db.addAof(utils.ToCmdLine3("ltrim", args...))
 
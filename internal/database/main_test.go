@@ -0,0 +1,13 @@
+package database
+
+import (
+	"Godis/internal/config"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// 每个 Server 有16个数据库，默认的 65536 个分段会让每个测试占用数百MB内存
+	config.Properties.DataDictShards = 1024
+	os.Exit(m.Run())
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"Godis/internal/config"
+	"Godis/internal/pubsub"
+	"strconv"
+)
+
+// classes of keyspace events, same as letters of notify-keyspace-events in redis
+const (
+	// notifyKeyspace publishes events to __keyspace@<db>__:<key>, K
+	notifyKeyspace = 1 << iota
+	// notifyKeyevent publishes events to __keyevent@<db>__:<event>, E
+	notifyKeyevent
+	// notifyGeneric are events not specific to a type, such as del, g
+	notifyGeneric
+)
+
+// keyspaceNotifier publishes keyspace events to subscribers of hub, see notify-keyspace-events
+// 目前只发布 generic(g) 类的事件，事件名与 Redis 相同：
+//   - del: DEL、过期时间已过去的 EXPIRE 系列命令，以及集合删空后的隐式删除
+//   - expire: EXPIRE 系列命令设置了过期时间
+//   - persist: PERSIST 删除了过期时间
+//   - rename_from/rename_to: RENAME 和 RENAMENX 分别在源key和目标key上发布
+//   - copy_to: COPY 在目标key上发布
+//
+// 其余类型的字母(如 $、l、x)被接受但没有对应的事件，主动过期和访问时的被动过期也不发布 expired 事件
+type keyspaceNotifier struct {
+	flags int
+	hub   *pubsub.Hub
+}
+
+// parseNotifyKeyspaceEvents returns enabled classes of notify-keyspace-events
+// 与 Redis 一致，K 和 E 至少需要一个，且至少开启一类事件，否则不发布任何事件；非法字母已在 config.Validate 中检查
+func parseNotifyKeyspaceEvents(flags string) int {
+	result := 0
+	for _, c := range flags {
+		switch c {
+		case 'K':
+			result |= notifyKeyspace
+		case 'E':
+			result |= notifyKeyevent
+		case 'g', 'A':
+			result |= notifyGeneric
+		}
+	}
+	if result&(notifyKeyspace|notifyKeyevent) == 0 || result&^(notifyKeyspace|notifyKeyevent) == 0 {
+		return 0
+	}
+	return result
+}
+
+// makeKeyspaceNotifier returns nil if notify-keyspace-events is not set
+func makeKeyspaceNotifier(hub *pubsub.Hub) *keyspaceNotifier {
+	flags := parseNotifyKeyspaceEvents(config.Properties.NotifyKeyspaceEvents)
+	if flags == 0 {
+		return nil
+	}
+	return &keyspaceNotifier{
+		flags: flags,
+		hub:   hub,
+	}
+}
+
+// notify publishes event of key if class is enabled
+func (n *keyspaceNotifier) notify(class int, event string, dbIndex int, key string) {
+	if n == nil || n.flags&class == 0 {
+		return
+	}
+	db := strconv.Itoa(dbIndex)
+	if n.flags&notifyKeyspace > 0 {
+		channel := "__keyspace@" + db + "__:" + key
+		pubsub.Publish(n.hub, [][]byte{[]byte(channel), []byte(event)})
+	}
+	if n.flags&notifyKeyevent > 0 {
+		channel := "__keyevent@" + db + "__:" + event
+		pubsub.Publish(n.hub, [][]byte{[]byte(channel), []byte(key)})
+	}
+}
+
+// notifyKeyspaceEvent publishes event of key in db, does nothing if notification is disabled
+func (db *DB) notifyKeyspaceEvent(class int, event string, key string) {
+	db.notifier.notify(class, event, db.index, key)
+}
+
+// presentKeys returns keys existing in db without duplicates, so that del events could be published after removing them
+// notification is disabled in most cases, returns nil without looking up keys then
+func (db *DB) presentKeys(keys []string) []string {
+	if db.notifier == nil || db.notifier.flags&notifyGeneric == 0 {
+		return nil
+	}
+	var result []string
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		if _, exists := db.data.GetWithLock(key); exists {
+			result = append(result, key)
+		}
+	}
+	return result
+}
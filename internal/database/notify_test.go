@@ -0,0 +1,127 @@
+package database
+
+import (
+	"Godis/interface/redis"
+	"Godis/internal/config"
+	"Godis/internal/redis/connection"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"strings"
+	"testing"
+)
+
+// recordConn records messages pushed to subscriber
+type recordConn struct {
+	*connection.FakeConn
+	messages []string
+}
+
+func (c *recordConn) Capabilities() redis.Capability {
+	return redis.CapMulti | redis.CapPush
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	c.messages = append(c.messages, string(b))
+	return len(b), nil
+}
+
+func TestParseNotifyKeyspaceEvents(t *testing.T) {
+	cases := map[string]int{
+		"":   0,
+		"g":  0,
+		"K":  0,
+		"Kg": notifyKeyspace | notifyGeneric,
+		"EA": notifyKeyevent | notifyGeneric,
+		// 目前只有 generic 类型的事件
+		"KEl$": 0,
+	}
+	for flags, expected := range cases {
+		if actual := parseNotifyKeyspaceEvents(flags); actual != expected {
+			t.Errorf("%q: expect %b, actual %b", flags, expected, actual)
+		}
+	}
+}
+
+func TestNotifyImplicitDel(t *testing.T) {
+	config.Properties.NotifyKeyspaceEvents = "KEg"
+	server := NewStandaloneServer()
+	config.Properties.NotifyKeyspaceEvents = ""
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(server.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+	subscriber := &recordConn{FakeConn: connection.NewFakeConn()}
+	server.Exec(subscriber, utils.ToCmdLine("SUBSCRIBE", "__keyspace@0__:l", "__keyevent@0__:del"))
+	subscriber.messages = nil
+
+	exec("RPUSH", "l", "a", "b")
+	exec("LPOP", "l")
+	if len(subscriber.messages) != 0 {
+		t.Errorf("expect no event before list is empty, actual %q", subscriber.messages)
+	}
+	exec("LPOP", "l")
+	expected := []string{
+		"*3\r\n$7\r\nmessage\r\n$16\r\n__keyspace@0__:l\r\n$3\r\ndel\r\n",
+		"*3\r\n$7\r\nmessage\r\n$18\r\n__keyevent@0__:del\r\n$1\r\nl\r\n",
+	}
+	if strings.Join(subscriber.messages, "") != strings.Join(expected, "") {
+		t.Errorf("expect %q, actual %q", expected, subscriber.messages)
+	}
+
+	// 其他DB的事件发布到对应的频道
+	subscriber.messages = nil
+	exec("SELECT", "1")
+	exec("SADD", "l", "m")
+	exec("SREM", "l", "m")
+	if len(subscriber.messages) != 0 {
+		t.Errorf("expect no event of db 1, actual %q", subscriber.messages)
+	}
+}
+
+func TestNotifyGenericEvents(t *testing.T) {
+	config.Properties.NotifyKeyspaceEvents = "Eg"
+	server := NewStandaloneServer()
+	config.Properties.NotifyKeyspaceEvents = ""
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(server.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+	subscriber := &recordConn{FakeConn: connection.NewFakeConn()}
+	events := []string{"del", "expire", "persist", "rename_from", "rename_to", "copy_to"}
+	for _, event := range events {
+		server.Exec(subscriber, utils.ToCmdLine("SUBSCRIBE", "__keyevent@0__:"+event))
+	}
+	cases := []struct {
+		cmdLine []string
+		// event and key of published messages
+		expected []string
+	}{
+		{[]string{"SET", "a", "1"}, nil},
+		{[]string{"EXPIRE", "a", "100"}, []string{"expire", "a"}},
+		// 条件不满足时不发布事件
+		{[]string{"EXPIRE", "a", "100", "NX"}, nil},
+		{[]string{"PERSIST", "a"}, []string{"persist", "a"}},
+		{[]string{"PERSIST", "a"}, nil},
+		{[]string{"RENAME", "a", "b"}, []string{"rename_from", "a", "rename_to", "b"}},
+		{[]string{"RENAMENX", "b", "c"}, []string{"rename_from", "b", "rename_to", "c"}},
+		{[]string{"COPY", "c", "d"}, []string{"copy_to", "d"}},
+		// 重复和不存在的key只发布一次或不发布
+		{[]string{"DEL", "c", "c", "missing"}, []string{"del", "c"}},
+		{[]string{"EXPIRE", "d", "-1"}, []string{"del", "d"}},
+	}
+	for _, c := range cases {
+		subscriber.messages = nil
+		exec(c.cmdLine...)
+		var expected []string
+		for i := 0; i < len(c.expected); i += 2 {
+			channel := "__keyevent@0__:" + c.expected[i]
+			key := c.expected[i+1]
+			expected = append(expected, string(protocol.MakeMultiBulkReply([][]byte{
+				[]byte("message"), []byte(channel), []byte(key),
+			}).ToBytes()))
+		}
+		if strings.Join(subscriber.messages, "") != strings.Join(expected, "") {
+			t.Errorf("%s: expect %q, actual %q", strings.Join(c.cmdLine, " "), expected, subscriber.messages)
+		}
+	}
+}
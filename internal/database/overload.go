@@ -0,0 +1,58 @@
+package database
+
+import (
+	"Godis/internal/config"
+	"Godis/internal/redis/connection"
+	"Godis/lib/timewheel"
+	"Godis/redis/protocol"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+// 过载保护: 内部队列积压超过阈值时拒绝写命令，客户端立即得到 MAXQUEUE 错误，而不是排队等待使延迟无限增长
+// 读命令不产生 aof 和后台任务，不受影响
+
+// overloadRejected is count of write commands rejected because of overload
+var overloadRejected int64
+
+// checkQueue returns MAXQUEUE error if length of the queue exceeds limit, limit <= 0 disables the check
+func checkQueue(queue string, option string, length int, limit int) protocol.ErrorReply {
+	if limit <= 0 || length <= limit {
+		return nil
+	}
+	atomic.AddInt64(&overloadRejected, 1)
+	return protocol.MakeErrReply(fmt.Sprintf("MAXQUEUE %s has %d pending items, more than %s %d, try again later",
+		queue, length, option, limit))
+}
+
+// checkOverload returns MAXQUEUE error if any internal queue is saturated
+func (server *Server) checkOverload() protocol.ErrorReply {
+	if server.persister != nil {
+		errReply := checkQueue("aof queue", "max-aof-queue", server.persister.QueueLength(), config.Properties.MaxAofQueue)
+		if errReply != nil {
+			return errReply
+		}
+	}
+	errReply := checkQueue("job queue", "max-job-queue", timewheel.Pending(), config.Properties.MaxJobQueue)
+	if errReply != nil {
+		return errReply
+	}
+	return checkQueue("output buffer", "max-output-buffer", int(connection.OutputPending()), config.Properties.MaxOutputBuffer)
+}
+
+// genOverloadInfo returns saturation gauges of internal queues for INFO
+func (server *Server) genOverloadInfo() string {
+	aofQueue := 0
+	if server.persister != nil {
+		aofQueue = server.persister.QueueLength()
+	}
+	return "# Overload\r\n" +
+		"aof_queue_length:" + strconv.Itoa(aofQueue) + "\r\n" +
+		"max_aof_queue:" + strconv.Itoa(config.Properties.MaxAofQueue) + "\r\n" +
+		"job_queue_length:" + strconv.Itoa(timewheel.Pending()) + "\r\n" +
+		"max_job_queue:" + strconv.Itoa(config.Properties.MaxJobQueue) + "\r\n" +
+		"output_buffer_length:" + strconv.FormatInt(connection.OutputPending(), 10) + "\r\n" +
+		"max_output_buffer:" + strconv.Itoa(config.Properties.MaxOutputBuffer) + "\r\n" +
+		"overload_rejected_writes:" + strconv.FormatInt(atomic.LoadInt64(&overloadRejected), 10) + "\r\n"
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"Godis/internal/config"
+	"Godis/internal/redis/connection"
+	"Godis/lib/utils"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckQueue(t *testing.T) {
+	rejected := atomic.LoadInt64(&overloadRejected)
+	if errReply := checkQueue("aof queue", "max-aof-queue", 100, 0); errReply != nil {
+		t.Errorf("0 should disable the check, actual %s", errReply.Error())
+	}
+	if errReply := checkQueue("aof queue", "max-aof-queue", 100, 100); errReply != nil {
+		t.Errorf("queue at limit should not be rejected, actual %s", errReply.Error())
+	}
+	errReply := checkQueue("aof queue", "max-aof-queue", 101, 100)
+	if errReply == nil || !strings.HasPrefix(errReply.Error(), "MAXQUEUE") {
+		t.Errorf("expect MAXQUEUE error, actual %v", errReply)
+	}
+	if n := atomic.LoadInt64(&overloadRejected); n != rejected+1 {
+		t.Errorf("expect %d rejected writes, actual %d", rejected+1, n)
+	}
+
+	server := NewStandaloneServer()
+	reply := string(server.Exec(connection.NewFakeConn(), utils.ToCmdLine("INFO", "overload")).ToBytes())
+	for _, field := range []string{"aof_queue_length:0", "job_queue_length:", "overload_rejected_writes:"} {
+		if !strings.Contains(reply, field) {
+			t.Errorf("expect %s in %q", field, reply)
+		}
+	}
+}
+
+// blockOutput writes a reply to a client which never reads, returns a function reading the reply
+func blockOutput(t *testing.T, reply string) func() {
+	client, peer := net.Pipe()
+	conn := connection.NewConn(client)
+	go func() {
+		_, _ = conn.Write([]byte(reply))
+	}()
+	deadline := time.Now().Add(time.Second)
+	for connection.OutputPending() < int64(len(reply)) {
+		if time.Now().After(deadline) {
+			t.Fatal("expect pending output")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return func() {
+		_, _ = io.ReadFull(peer, make([]byte, len(reply)))
+		_ = conn.Close()
+		_ = peer.Close()
+	}
+}
+
+func TestOutputBufferOverload(t *testing.T) {
+	config.Properties.MaxOutputBuffer = 4
+	defer func() {
+		config.Properties.MaxOutputBuffer = 0
+	}()
+	server := NewStandaloneServer()
+	c := connection.NewFakeConn()
+	release := blockOutput(t, "+OK\r\n")
+
+	// 慢客户端积压的回复超过阈值时拒绝写命令，读命令不受影响
+	reply := string(server.Exec(c, utils.ToCmdLine("SET", "k", "v")).ToBytes())
+	if !strings.HasPrefix(reply, "-MAXQUEUE output buffer") {
+		t.Errorf("expect MAXQUEUE error, actual %q", reply)
+	}
+	if reply := string(server.Exec(c, utils.ToCmdLine("GET", "k")).ToBytes()); reply != "$-1\r\n" {
+		t.Errorf("expect read command executed, actual %q", reply)
+	}
+	info := string(server.Exec(c, utils.ToCmdLine("INFO", "overload")).ToBytes())
+	if !strings.Contains(info, "output_buffer_length:5\r\n") || !strings.Contains(info, "max_output_buffer:4\r\n") {
+		t.Errorf("expect output buffer gauge in %q", info)
+	}
+
+	release()
+	if reply := string(server.Exec(c, utils.ToCmdLine("SET", "k", "v")).ToBytes()); reply != "+OK\r\n" {
+		t.Errorf("expect write accepted after output drained, actual %q", reply)
+	}
+}
+
+func TestAdminBypassOverload(t *testing.T) {
+	config.Properties.MaxOutputBuffer = 4
+	config.Properties.RequirePass = "pass"
+	config.Properties.AdminPass = "admin"
+	defer func() {
+		config.Properties.MaxOutputBuffer = 0
+		config.Properties.RequirePass = ""
+		config.Properties.AdminPass = ""
+	}()
+	server := NewStandaloneServer()
+	release := blockOutput(t, "+OK\r\n")
+	defer release()
+
+	normal := connection.NewFakeConn()
+	server.Exec(normal, utils.ToCmdLine("AUTH", "pass"))
+	if reply := string(server.Exec(normal, utils.ToCmdLine("SET", "k", "v")).ToBytes()); !strings.HasPrefix(reply, "-MAXQUEUE") {
+		t.Errorf("expect MAXQUEUE error, actual %q", reply)
+	}
+
+	// 使用 adminpass 认证的连接在过载时仍可执行写命令
+	admin := connection.NewFakeConn()
+	if reply := string(server.Exec(admin, utils.ToCmdLine("SET", "k", "v")).ToBytes()); !strings.HasPrefix(reply, "-NOAUTH") {
+		t.Errorf("expect NOAUTH error, actual %q", reply)
+	}
+	if reply := string(server.Exec(admin, utils.ToCmdLine("AUTH", "admin")).ToBytes()); reply != "+OK\r\n" {
+		t.Fatalf("expect admin authenticated, actual %q", reply)
+	}
+	if reply := string(server.Exec(admin, utils.ToCmdLine("SET", "k", "v")).ToBytes()); reply != "+OK\r\n" {
+		t.Errorf("expect admin write accepted, actual %q", reply)
+	}
+	if reply := string(server.Exec(admin, utils.ToCmdLine("INFO", "overload")).ToBytes()); !strings.Contains(reply, "output_buffer_length:5") {
+		t.Errorf("expect INFO executed, actual %q", reply)
+	}
+
+	// 未设置 requirepass 时也可以使用 adminpass 认证
+	config.Properties.RequirePass = ""
+	admin = connection.NewFakeConn()
+	server.Exec(admin, utils.ToCmdLine("AUTH", "admin"))
+	if reply := string(server.Exec(admin, utils.ToCmdLine("SET", "k", "v")).ToBytes()); reply != "+OK\r\n" {
+		t.Errorf("expect admin write accepted, actual %q", reply)
+	}
+	if reply := string(server.Exec(admin, utils.ToCmdLine("AUTH", "wrong")).ToBytes()); reply != "-ERR invalid password\r\n" {
+		t.Errorf("expect invalid password, actual %q", reply)
+	}
+}
@@ -1,18 +1,15 @@
 package database
 
 import (
-	"Godis/aof"
-	"Godis/config"
-	"Godis/datastruct/dict"
 	"Godis/interface/database"
+	"Godis/internal/aof"
+	"Godis/internal/config"
 	"fmt"
 	"github.com/hdt3213/rdb/core"
 	"os"
 	"sync/atomic"
 
-	List "Godis/datastruct/list"
 	HashSet "Godis/datastruct/set"
-	SortedSet "Godis/datastruct/sortedset"
 
 	rdb "github.com/hdt3213/rdb/parser"
 )
@@ -42,26 +39,26 @@ func (server *Server) LoadRDB(dec *core.Decoder) error {
 		switch o.GetType() {
 		case rdb.StringType:
 			str := o.(*rdb.StringObject)
-			entity = &database.DataEntity{
-				Data: str.Value,
-			}
+			entity = makeStringEntity(str.Value)
 		case rdb.ListType:
 			listObj := o.(*rdb.ListObject)
-			list := List.NewQuickList()
+			list := makeList()
 			for _, v := range listObj.Values {
 				list.Add(v)
 			}
 			entity = &database.DataEntity{
 				Data: list,
+				Type: database.TypeList,
 			}
 		case rdb.HashType:
 			hashObj := o.(*rdb.HashObject)
-			hash := dict.MakeSimple()
+			hash := makeHash()
 			for k, v := range hashObj.Hash {
-				hash.Put(k, v)
+				hash.Set(k, v)
 			}
 			entity = &database.DataEntity{
 				Data: hash,
+				Type: database.TypeHash,
 			}
 		case rdb.SetType:
 			setObj := o.(*rdb.SetObject)
@@ -71,15 +68,17 @@ func (server *Server) LoadRDB(dec *core.Decoder) error {
 			}
 			entity = &database.DataEntity{
 				Data: set,
+				Type: database.TypeSet,
 			}
 		case rdb.ZSetType:
 			zsetObj := o.(*rdb.ZSetObject)
-			zSet := SortedSet.Make()
+			zSet := makeSortedSet()
 			for _, e := range zsetObj.Entries {
 				zSet.Add(e.Member, e.Score)
 			}
 			entity = &database.DataEntity{
 				Data: zSet,
+				Type: database.TypeZSet,
 			}
 		}
 		if entity != nil {
@@ -88,7 +87,12 @@ func (server *Server) LoadRDB(dec *core.Decoder) error {
 				db.Expire(o.GetKey(), *o.GetExpiration())
 			}
 			// add to aof
-			db.addAof(aof.EntityToCmd(o.GetKey(), entity).Args)
+			if cmd := aof.EntityToCmd(o.GetKey(), entity); cmd != nil {
+				db.addAof(cmd.Args)
+			}
+			if o.GetExpiration() != nil {
+				db.addAof(aof.MakeExpireCmd(o.GetKey(), *o.GetExpiration()).Args)
+			}
 		}
 		return true
 	})
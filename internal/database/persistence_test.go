@@ -0,0 +1,93 @@
+package database
+
+import (
+	"Godis/internal/config"
+	"Godis/internal/redis/connection"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"path/filepath"
+	"testing"
+)
+
+func TestRewriteAof_WritesDuringRewrite(t *testing.T) {
+	config.Properties.AppendOnly = true
+	config.Properties.AppendFilename = filepath.Join(t.TempDir(), "a.aof")
+	config.Properties.AppendFsync = "always"
+	defer func() {
+		config.Properties.AppendOnly = false
+	}()
+	server := NewStandaloneServer()
+	conn := connection.NewFakeConn()
+	server.Exec(conn, utils.ToCmdLine("SET", "before", "1"))
+	ctx, err := server.persister.StartRewrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.persister.StartRewrite(); err == nil {
+		t.Error("expect error when rewrite is in progress")
+	}
+	// writes in another db after the temp db snapshot is taken
+	server.Exec(conn, utils.ToCmdLine("SELECT", "1"))
+	server.Exec(conn, utils.ToCmdLine("SET", "during", "2"))
+	if err := server.persister.DoRewrite(ctx); err != nil {
+		t.Fatal(err)
+	}
+	server.Exec(conn, utils.ToCmdLine("SET", "before", "3"))
+	server.persister.FinishRewrite(ctx)
+	server.Exec(conn, utils.ToCmdLine("SET", "after", "4"))
+	server.Close()
+
+	server = NewStandaloneServer()
+	defer server.Close()
+	conn = connection.NewFakeConn()
+	expected := []struct {
+		db    string
+		key   string
+		value string
+	}{
+		{"0", "before", "1"},
+		{"1", "during", "2"},
+		{"1", "before", "3"},
+		{"1", "after", "4"},
+	}
+	for _, e := range expected {
+		server.Exec(conn, utils.ToCmdLine("SELECT", e.db))
+		reply := server.Exec(conn, utils.ToCmdLine("GET", e.key))
+		if bulk, ok := reply.(*protocol.BulkReply); !ok || string(bulk.Arg) != e.value {
+			t.Errorf("db %s key %s: expect %s, actual %s", e.db, e.key, e.value, reply.ToBytes())
+		}
+	}
+}
+
+func TestRestartAof(t *testing.T) {
+	for _, fsync := range []string{"always", "everysec"} {
+		config.Properties.AppendOnly = true
+		config.Properties.AppendFilename = filepath.Join(t.TempDir(), "a.aof")
+		config.Properties.AppendFsync = fsync
+		server := NewStandaloneServer()
+		conn := connection.NewFakeConn()
+		server.Exec(conn, utils.ToCmdLine("SET", "old", "1"))
+		// snapshot received from master during full resync
+		snapshot := MakeAuxiliaryServer()
+		snapshot.Exec(conn, utils.ToCmdLine("SET", "new", "2"))
+		if err := server.persister.Restart(snapshot); err != nil {
+			t.Fatal(err)
+		}
+		server.Exec(conn, utils.ToCmdLine("SET", "after", "3"))
+		server.Close()
+
+		server = NewStandaloneServer()
+		conn = connection.NewFakeConn()
+		if reply := server.Exec(conn, utils.ToCmdLine("EXISTS", "old")); string(reply.ToBytes()) != ":0\r\n" {
+			t.Errorf("%s: expect old data discarded", fsync)
+		}
+		for key, value := range map[string]string{"new": "2", "after": "3"} {
+			reply := server.Exec(conn, utils.ToCmdLine("GET", key))
+			if bulk, ok := reply.(*protocol.BulkReply); !ok || string(bulk.Arg) != value {
+				t.Errorf("%s key %s: expect %s, actual %s", fsync, key, value, reply.ToBytes())
+			}
+		}
+		server.Close()
+	}
+	config.Properties.AppendOnly = false
+}
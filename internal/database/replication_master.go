@@ -434,6 +434,13 @@ func (server *Server) initMaster() {
 	}
 }
 
+// SetReplicationID sets replication id of current server, cluster uses node id as replication id
+func (server *Server) SetReplicationID(id string) {
+	server.masterStatus.mu.Lock()
+	defer server.masterStatus.mu.Unlock()
+	server.masterStatus.replId = id
+}
+
 func (server *Server) stopMaster() {
 	server.masterStatus.mu.Lock()
 	defer server.masterStatus.mu.Unlock()
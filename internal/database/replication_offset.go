@@ -0,0 +1,108 @@
+package database
+
+import (
+	"Godis/interface/redis"
+	"Godis/internal/config"
+	"Godis/redis/protocol"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// 从节点的读己之写：客户端在主节点写入后通过 ROLE 得到主节点的复制偏移量，
+// 在从节点读取之前执行 READAFTER offset timeout，从节点执行到该偏移量后才返回，之后的读取一定包含这次写入
+
+// replTimeout returns repl-timeout, default 60 seconds
+func replTimeout() time.Duration {
+	if config.Properties.ReplTimeout != 0 {
+		return time.Duration(config.Properties.ReplTimeout) * time.Second
+	}
+	return 60 * time.Second
+}
+
+// execRole returns replication role and offset of the server, same as redis ROLE
+// master: ["master", offset, [[ip, port, offset], ...]]
+// slave: ["slave", master host, master port, state, offset]
+func (server *Server) execRole() redis.Reply {
+	if atomic.LoadInt32(&server.role) == slaveRole {
+		repl := server.slaveStatus
+		repl.mutex.Lock()
+		host, port := repl.masterHost, repl.masterPort
+		connected := repl.masterConn != nil
+		repl.mutex.Unlock()
+		offset := atomic.LoadInt64(&repl.appliedOffset)
+		state := "connect"
+		if connected && offset >= 0 {
+			state = "connected"
+		} else if connected {
+			state = "sync"
+		}
+		return protocol.MakeMultiRawReply([]redis.Reply{
+			protocol.MakeBulkReply([]byte("slave")),
+			protocol.MakeBulkReply([]byte(host)),
+			protocol.MakeIntReply(int64(port)),
+			protocol.MakeBulkReply([]byte(state)),
+			protocol.MakeIntReply(offset),
+		})
+	}
+	// 等待此前的写命令进入复制积压缓冲区，客户端写入后执行 ROLE 得到的偏移量一定包含它的写入
+	if server.persister != nil {
+		server.persister.Barrier()
+	}
+	server.masterStatus.mu.RLock()
+	offset := server.masterStatus.backlog.currentOffset
+	slaves := make([]redis.Reply, 0, len(server.masterStatus.onlineSlaves))
+	for slave := range server.masterStatus.onlineSlaves {
+		ip := slave.announceIp
+		if ip == "" {
+			ip, _, _ = net.SplitHostPort(slave.conn.RemoteAddr())
+		}
+		slaves = append(slaves, protocol.MakeMultiBulkReply([][]byte{
+			[]byte(ip),
+			[]byte(strconv.Itoa(slave.announcePort)),
+			[]byte(strconv.FormatInt(slave.offset, 10)),
+		}))
+	}
+	server.masterStatus.mu.RUnlock()
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeBulkReply([]byte("master")),
+		protocol.MakeIntReply(offset),
+		protocol.MakeMultiRawReply(slaves),
+	})
+}
+
+// execReadAfter blocks until the replica has applied the given offset of master: READAFTER offset timeout
+// timeout 单位为毫秒，0 或超过 repl-timeout 时最多等待 repl-timeout；主节点的数据总是最新的，立即返回
+func (server *Server) execReadAfter(c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) != 2 {
+		return protocol.MakeArgNumErrReply("readafter")
+	}
+	if c != nil && c.InMultiState() {
+		return protocol.MakeErrReply("ERR READAFTER cannot be used in MULTI")
+	}
+	offset, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil || offset < 0 {
+		return protocol.MakeErrReply("ERR offset is not an integer or out of range")
+	}
+	timeoutMs, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return protocol.MakeErrReply("ERR timeout is not an integer or out of range")
+	}
+	if timeoutMs < 0 {
+		return protocol.MakeErrReply("ERR timeout is negative")
+	}
+	if atomic.LoadInt32(&server.role) != slaveRole {
+		return protocol.MakeOkReply()
+	}
+	timeout := replTimeout()
+	if timeoutMs > 0 && timeoutMs < timeout.Milliseconds() {
+		timeout = time.Duration(timeoutMs) * time.Millisecond
+	}
+	if !server.slaveStatus.waitAppliedOffset(offset, timeout) {
+		applied := atomic.LoadInt64(&server.slaveStatus.appliedOffset)
+		return protocol.MakeErrReply("TIMEOUT replica has applied offset " + strconv.FormatInt(applied, 10) +
+			", less than " + strconv.FormatInt(offset, 10))
+	}
+	return protocol.MakeOkReply()
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"Godis/internal/redis/connection"
+	"Godis/lib/utils"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadAfter(t *testing.T) {
+	server := NewStandaloneServer()
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(server.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+
+	if reply := exec("ROLE"); reply != "*3\r\n$6\r\nmaster\r\n:0\r\n*0\r\n" {
+		t.Errorf("unexpected role of master %q", reply)
+	}
+	// 主节点立即返回
+	if reply := exec("READAFTER", "100", "10"); reply != "+OK\r\n" {
+		t.Errorf("expect OK on master, actual %q", reply)
+	}
+	for _, args := range [][]string{{"a", "10"}, {"-1", "10"}, {"1", "-1"}, {"1"}} {
+		if reply := exec(append([]string{"READAFTER"}, args...)...); reply[0] != '-' {
+			t.Errorf("%v: expect error, actual %q", args, reply)
+		}
+	}
+
+	atomic.StoreInt32(&server.role, slaveRole)
+	defer atomic.StoreInt32(&server.role, masterRole)
+	if reply := exec("READAFTER", "100", "10"); !strings.HasPrefix(reply, "-TIMEOUT") {
+		t.Errorf("expect timeout, actual %q", reply)
+	}
+	if reply := exec("ROLE"); !strings.Contains(reply, "connect\r\n:-1\r\n") {
+		t.Errorf("unexpected role of slave %q", reply)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		server.slaveStatus.setAppliedOffset(50)
+		server.slaveStatus.setAppliedOffset(120)
+	}()
+	if reply := exec("READAFTER", "100", "5000"); reply != "+OK\r\n" {
+		t.Errorf("expect OK, actual %q", reply)
+	}
+	if reply := exec("READAFTER", "120", "10"); reply != "+OK\r\n" {
+		t.Errorf("expect OK for applied offset, actual %q", reply)
+	}
+	server.slaveStatus.waitersMu.Lock()
+	n := len(server.slaveStatus.offsetWaiters)
+	server.slaveStatus.waitersMu.Unlock()
+	if n != 0 {
+		t.Errorf("expect no waiters, actual %d", n)
+	}
+}
@@ -1,12 +1,11 @@
 package database
 
 import (
-	"Godis/aof"
-	"Godis/config"
 	"Godis/interface/redis"
+	"Godis/internal/config"
+	"Godis/internal/redis/connection"
 	"Godis/lib/logger"
 	"Godis/lib/utils"
-	"Godis/redis/connection"
 	"Godis/redis/parser"
 	"Godis/redis/protocol"
 	"bytes"
@@ -14,9 +13,7 @@ import (
 	"errors"
 	"fmt"
 	rdb "github.com/hdt3213/rdb/parser"
-	"io/ioutil"
 	"net"
-	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -48,12 +45,63 @@ type slaveStatus struct {
 	replOffset   int64
 	lastRecvTime time.Time
 	running      sync.WaitGroup
+
+	// appliedOffset 是已经在本地执行的复制偏移量，-1 表示还没有完成全量同步
+	// 与 replOffset 不同，全量同步时要等 RDB 载入后才更新，READAFTER 通过它判断能否读取，使用原子操作读写
+	appliedOffset int64
+	// offsetWaiters 是等待 appliedOffset 到达指定偏移量的 READAFTER 命令，到达时关闭对应的 channel
+	waitersMu     sync.Mutex
+	offsetWaiters map[chan struct{}]int64
 }
 
 var configChangedErr = errors.New("slaveStatus config changed")
 
 func initReplSlaveStatus() *slaveStatus {
-	return &slaveStatus{}
+	return &slaveStatus{
+		appliedOffset: -1,
+		offsetWaiters: make(map[chan struct{}]int64),
+	}
+}
+
+// setAppliedOffset updates appliedOffset and wakes up READAFTER commands waiting for it
+func (repl *slaveStatus) setAppliedOffset(offset int64) {
+	atomic.StoreInt64(&repl.appliedOffset, offset)
+	repl.waitersMu.Lock()
+	defer repl.waitersMu.Unlock()
+	for ch, waiting := range repl.offsetWaiters {
+		if offset >= waiting {
+			close(ch)
+			delete(repl.offsetWaiters, ch)
+		}
+	}
+}
+
+// waitAppliedOffset blocks until appliedOffset reaches offset or timeout, returns false on timeout
+func (repl *slaveStatus) waitAppliedOffset(offset int64, timeout time.Duration) bool {
+	if atomic.LoadInt64(&repl.appliedOffset) >= offset {
+		return true
+	}
+	ch := make(chan struct{})
+	repl.waitersMu.Lock()
+	// 加锁后再检查一次，避免在注册之前偏移量已经更新
+	if atomic.LoadInt64(&repl.appliedOffset) >= offset {
+		repl.waitersMu.Unlock()
+		return true
+	}
+	repl.offsetWaiters[ch] = offset
+	repl.waitersMu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ch:
+		return true
+	case <-timer.C:
+		repl.waitersMu.Lock()
+		delete(repl.offsetWaiters, ch)
+		repl.waitersMu.Unlock()
+		return false
+	}
 }
 
 func (server *Server) execSlaveOf(c redis.Connection, args [][]byte) redis.Reply {
@@ -84,6 +132,7 @@ func (server *Server) slaveOfNone() {
 	server.slaveStatus.masterPort = 0
 	server.slaveStatus.replId = ""
 	server.slaveStatus.replOffset = -1
+	server.slaveStatus.setAppliedOffset(-1)
 	server.slaveStatus.stopSlaveWithMutex()
 	server.role = masterRole
 }
@@ -290,6 +339,8 @@ func (server *Server) psyncHandshake() (bool, error) {
 		logger.Info("full re-sync with master")
 		server.slaveStatus.replId = headers[1]
 		server.slaveStatus.replOffset, err = strconv.ParseInt(headers[2], 10, 64)
+		// 载入 RDB 之前本地数据不对应任何偏移量
+		server.slaveStatus.setAppliedOffset(-1)
 		isFullReSync = true
 	} else if headers[0] == "CONTINUE" {
 		logger.Info("continue partial sync")
@@ -306,25 +357,6 @@ func (server *Server) psyncHandshake() (bool, error) {
 	return isFullReSync, nil
 }
 
-func makeRdbLoader(upgradeAof bool) (*Server, string, error) {
-	rdbLoader := MakeAuxiliaryServer()
-	if !upgradeAof {
-		return rdbLoader, "", nil
-	}
-	// make aof handler to generate new aof file during loading rdb
-	newAofFile, err := ioutil.TempFile("", "*.aof")
-	if err != nil {
-		return nil, "", fmt.Errorf("create temp rdb failed: %v", err)
-	}
-	newAofFilename := newAofFile.Name()
-	aofHandler, err := NewPersister(rdbLoader, newAofFilename, false, aof.FsyncNo)
-	if err != nil {
-		return nil, "", err
-	}
-	rdbLoader.bindPersister(aofHandler)
-	return rdbLoader, newAofFilename, nil
-}
-
 // loadMasterRDB downloads rdb after handshake has been done
 func (server *Server) loadMasterRDB(configVersion int32) error {
 	rdbPayload := <-server.slaveStatus.masterChan
@@ -339,11 +371,8 @@ func (server *Server) loadMasterRDB(configVersion int32) error {
 	logger.Info(fmt.Sprintf("receive %d bytes of rdb from master", len(rdbReply.Arg)))
 	rdbDec := rdb.NewDecoder(bytes.NewReader(rdbReply.Arg))
 
-	rdbLoader, newAofFilename, err := makeRdbLoader(config.Properties.AppendOnly)
-	if err != nil {
-		return err
-	}
-	err = rdbLoader.LoadRDB(rdbDec)
+	rdbLoader := MakeAuxiliaryServer()
+	err := rdbLoader.LoadRDB(rdbDec)
 	if err != nil {
 		return errors.New("dump rdb failed: " + err.Error())
 	}
@@ -354,25 +383,18 @@ func (server *Server) loadMasterRDB(configVersion int32) error {
 		// slaveStatus conf changed during connecting and waiting mutex
 		return configChangedErr
 	}
+	if server.persister != nil {
+		// 全量同步是 aof 的分界点，在新数据生效之前用它的快照重启 aof，丢弃旧数据的历史
+		err = server.persister.Restart(rdbLoader)
+		if err != nil {
+			return errors.New("restart aof failed: " + err.Error())
+		}
+	}
 	for i, h := range rdbLoader.dbSet {
 		newDB := h.Load().(*DB)
 		server.loadDB(i, newDB)
 	}
-
-	if config.Properties.AppendOnly {
-		// use new aof file
-		server.persister.Close()
-		err = os.Rename(newAofFilename, config.Properties.AppendFilename)
-		if err != nil {
-			return err
-		}
-		persister, err := NewPersister(server, config.Properties.AppendFilename, false, config.Properties.AppendFsync)
-		if err != nil {
-			return err
-		}
-		server.bindPersister(persister)
-	}
-
+	server.slaveStatus.setAppliedOffset(server.slaveStatus.replOffset)
 	return nil
 }
 
@@ -402,6 +424,7 @@ func (server *Server) receiveAOF(ctx context.Context, configVersion int32) error
 			server.Exec(conn, cmdLine.Args)
 			n := len(cmdLine.ToBytes()) // todo: directly get size from socket
 			server.slaveStatus.replOffset += int64(n)
+			server.slaveStatus.setAppliedOffset(server.slaveStatus.replOffset)
 			server.slaveStatus.lastRecvTime = time.Now()
 			logger.Info(fmt.Sprintf("receive %d bytes from master, current offset %d, %s",
 				n, server.slaveStatus.replOffset, strconv.Quote(string(cmdLine.ToBytes()))))
@@ -420,11 +443,7 @@ func (server *Server) slaveCron() {
 	}
 
 	// check master timeout
-	replTimeout := 60 * time.Second
-	if config.Properties.ReplTimeout != 0 {
-		replTimeout = time.Duration(config.Properties.ReplTimeout) * time.Second
-	}
-	minLastRecvTime := time.Now().Add(-replTimeout)
+	minLastRecvTime := time.Now().Add(-replTimeout())
 	if repl.lastRecvTime.Before(minLastRecvTime) {
 		// reconnect with master
 		err := server.reconnectWithMaster()
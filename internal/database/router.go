@@ -39,8 +39,14 @@ type command struct {
 	flags int
 	// 用于储存额外的命令信息
 	extra *commandExtra
+	// estimator 估计命令的执行代价，结果附在慢命令日志中，可以为 nil
+	estimator CostFunc
 }
 
+// CostFunc returns a human readable cost estimate of the command, such as algorithm and sizes of operands
+// invoker should provide read locks of the keys
+type CostFunc func(db *DB, args [][]byte) string
+
 type commandExtra struct {
 	signs    []string
 	firstKey int
@@ -92,6 +98,15 @@ func isReadOnlyCommand(name string) bool {
 	return cmd.flags&flagReadOnly > 0
 }
 
+// isWriteCommand returns true for normal commands which are not read only
+func isWriteCommand(name string) bool {
+	cmd := cmdTable[strings.ToLower(name)]
+	if cmd == nil {
+		return false
+	}
+	return cmd.flags&(flagReadOnly|flagSpecial) == 0
+}
+
 func (cmd *command) toDescReply() redis.Reply {
 	args := make([]redis.Reply, 0, 6)
 	args = append(args,
@@ -120,3 +135,9 @@ func (cmd *command) attachCommandExtra(signs []string, firstKey int, lastKey int
 		keyStep:  keyStep,
 	}
 }
+
+// attachCostEstimator sets the cost estimator shown in slowlog entries
+func (cmd *command) attachCostEstimator(estimator CostFunc) *command {
+	cmd.estimator = estimator
+	return cmd
+}
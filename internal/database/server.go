@@ -1,21 +1,27 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
-	"Godis/aof"
-	"Godis/config"
+	"Godis/datastruct/dict"
 	"Godis/interface/database"
 	"Godis/interface/redis"
+	"Godis/internal/aof"
+	"Godis/internal/config"
+	"Godis/internal/pubsub"
+	"Godis/lib/clock"
 	"Godis/lib/logger"
+	"Godis/lib/random"
 	"Godis/lib/utils"
-	"Godis/pubsub"
+	"Godis/lib/webhook"
 	"Godis/redis/protocol"
 )
 
@@ -34,6 +40,18 @@ type Server struct {
 	slaveStatus  *slaveStatus
 	masterStatus *masterStatus
 
+	// commands slower than slowlog-log-slower-than
+	slowLog *slowLog
+	// calls and time of each command for INFO commandstats
+	cmdStats *commandStats
+	// network clients for CLIENT LIST
+	clients *clientRegistry
+	// closed by Close to stop the active expire cron
+	expireCronStop chan struct{}
+	// closed after the active expire cron exits
+	expireCronDone chan struct{}
+	stopExpireOnce sync.Once
+
 	// hooks
 	insertCallback database.KeyEventCallback
 	deleteCallback database.KeyEventCallback
@@ -46,11 +64,22 @@ func fileExists(filename string) bool {
 
 // NewStandaloneServer creates a standalone redis server, with multi database and all other functions
 func NewStandaloneServer() *Server {
-	server := &Server{}
+	server := &Server{
+		slowLog:  makeSlowLog(),
+		cmdStats: makeCommandStats(),
+		clients:  makeClientRegistry(),
+	}
 	// 如果配置中未指定数据库数量，则默认为16个数据库
 	if config.Properties.Databases == 0 {
 		config.Properties.Databases = 16
 	}
+	if config.Properties.DebugSeed != 0 {
+		random.Seed(int64(config.Properties.DebugSeed))
+	}
+	// 哈希函数需要在创建字典之前设置
+	if err := dict.SetStringHash(config.Properties.HashFunction, uint64(config.Properties.HashSeed)); err != nil {
+		panic(err)
+	}
 
 	// 创建临时目录的意义？
 	err := os.MkdirAll(config.GetTmpDir(), os.ModePerm)
@@ -60,15 +89,19 @@ func NewStandaloneServer() *Server {
 
 	// 创建多个数据库并放入集合server.dbSet
 	server.dbSet = make([]*atomic.Value, config.Properties.Databases)
+	// 创建发布-订阅中心
+	server.hub = pubsub.MakeHub(config.Properties.LockStripes)
+	hotKeys := makeHotKeysTracker()
+	notifier := makeKeyspaceNotifier(server.hub)
 	for i := range server.dbSet {
 		singleDB := makeDB()
 		singleDB.index = i
+		singleDB.hotKeys = hotKeys
+		singleDB.notifier = notifier
 		holder := &atomic.Value{}
 		holder.Store(singleDB)
 		server.dbSet[i] = holder
 	}
-	// 创建发布-订阅中心
-	server.hub = pubsub.MakeHub()
 
 	// 检查是否需要记录 AOF 日志
 	validAof := false
@@ -93,6 +126,7 @@ func NewStandaloneServer() *Server {
 	server.slaveStatus = initReplSlaveStatus()
 	server.initMaster()
 	server.startReplCron()
+	server.startExpireCron()
 	server.role = masterRole // The initialization process does not require atomicity
 	return server
 }
@@ -106,8 +140,21 @@ func (server *Server) Exec(c redis.Connection, cmdLine [][]byte) (result redis.R
 			result = &protocol.UnknownErrReply{}
 		}
 	}()
+	start := clock.Now()
+	defer func() {
+		cost := clock.Since(start)
+		server.cmdStats.record(strings.ToLower(string(cmdLine[0])), cost, result)
+		if webhook.IsSlow(cost) {
+			publishSlowCommand(c, cmdLine, cost)
+		}
+		// auth 的参数是密码，slowlog 本身也不必记录，readafter 的耗时主要是等待复制
+		if name := strings.ToLower(string(cmdLine[0])); name != "auth" && name != "slowlog" && name != "readafter" && server.slowLog.isSlow(cost) {
+			server.slowLog.add(c, cmdLine, cost, server.estimateCost(c, cmdLine))
+		}
+	}()
 
 	cmdName := strings.ToLower(string(cmdLine[0]))
+	server.clients.touch(c, cmdName)
 	// ping
 	if cmdName == "ping" {
 		return Ping(c, cmdLine[1:])
@@ -116,7 +163,7 @@ func (server *Server) Exec(c redis.Connection, cmdLine [][]byte) (result redis.R
 	if cmdName == "auth" {
 		return Auth(c, cmdLine[1:])
 	}
-	if !isAuthenticated(c) {
+	if !IsAuthenticated(c) {
 		return protocol.MakeErrReply("NOAUTH Authentication required")
 	}
 	// info
@@ -133,6 +180,23 @@ func (server *Server) Exec(c redis.Connection, cmdLine [][]byte) (result redis.R
 		return server.execSlaveOf(c, cmdLine[1:])
 	} else if cmdName == "command" {
 		return execCommand(cmdLine[1:])
+	} else if cmdName == "slowlog" {
+		if len(cmdLine) < 2 {
+			return protocol.MakeArgNumErrReply("slowlog")
+		}
+		return server.execSlowLog(cmdLine[1:])
+	} else if cmdName == "client" {
+		if len(cmdLine) < 2 {
+			return protocol.MakeArgNumErrReply("client")
+		}
+		return server.execClient(c, cmdLine[1:])
+	} else if cmdName == "role" {
+		return server.execRole()
+	} else if cmdName == "readafter" {
+		// 只读的从节点也可以执行
+		return server.execReadAfter(c, cmdLine[1:])
+	} else if cmdName == "debug" {
+		return server.execDebug(cmdLine[1:])
 	}
 
 	// read only slave
@@ -144,11 +208,29 @@ func (server *Server) Exec(c redis.Connection, cmdLine [][]byte) (result redis.R
 		}
 	}
 
+	// 管理员连接不受下面的 MISCONF 和 MAXQUEUE 限制
+	admin := isAdmin(c)
+	// reject writes while aof keeps failing, the master connection is not rejected to keep replication consistent
+	if server.persister != nil && isWriteCommand(cmdName) && !c.IsMaster() && !admin {
+		if err := server.persister.CheckWrite(config.Properties.AofMaxWriteErrors); err != nil {
+			return protocol.MakeErrReply(err.Error())
+		}
+	}
+	// shed writes while internal queues are saturated
+	if isWriteCommand(cmdName) && !c.IsMaster() && !admin {
+		if errReply := server.checkOverload(); errReply != nil {
+			return errReply
+		}
+	}
+
 	// special commands which cannot execute within transaction
 	if cmdName == "subscribe" {
 		if len(cmdLine) < 2 {
 			return protocol.MakeArgNumErrReply("subscribe")
 		}
+		if !redis.Supports(c, redis.CapPush) {
+			return protocol.MakeErrReply("ERR this connection does not support pub/sub")
+		}
 		return pubsub.Subscribe(server.hub, c, cmdLine[1:])
 	} else if cmdName == "publish" {
 		return pubsub.Publish(server.hub, cmdLine[1:])
@@ -208,15 +290,31 @@ func (server *Server) Exec(c redis.Connection, cmdLine [][]byte) (result redis.R
 	return selectedDB.Exec(c, cmdLine)
 }
 
+// publishSlowCommand publishes slow command event, long arguments are truncated like slowlog
+func publishSlowCommand(c redis.Connection, cmdLine [][]byte, cost time.Duration) {
+	args := truncateCmdLine(cmdLine)
+	event := &webhook.Event{
+		Type:     webhook.EventSlowCommand,
+		Detail:   strings.Join(args, " "),
+		Duration: cost.Microseconds(),
+	}
+	if c != nil {
+		event.DB = c.GetDBIndex()
+	}
+	webhook.Publish(event)
+}
+
 // AfterClientClose does some clean after client close connection
 func (server *Server) AfterClientClose(c redis.Connection) {
 	pubsub.UnsubscribeAll(server.hub, c)
+	server.clients.remove(c)
 }
 
 // Close graceful shutdown database
 func (server *Server) Close() {
 	// stop slaveStatus first
 	server.slaveStatus.close()
+	server.stopExpireCron()
 	if server.persister != nil {
 		server.persister.Close()
 	}
@@ -247,8 +345,12 @@ func (server *Server) flushDB(dbIndex int) redis.Reply {
 	if dbIndex >= len(server.dbSet) || dbIndex < 0 {
 		return protocol.MakeErrReply("ERR DB index is out of range")
 	}
+	oldDB := server.mustSelectDB(dbIndex)
 	newDB := makeDB()
 	server.loadDB(dbIndex, newDB)
+	if ttl := trashTTL(); ttl > 0 {
+		oldDB.moveAllToTrash(ttl)
+	}
 	return &protocol.OkReply{}
 }
 
@@ -259,6 +361,10 @@ func (server *Server) loadDB(dbIndex int, newDB *DB) redis.Reply {
 	oldDB := server.mustSelectDB(dbIndex)
 	newDB.index = dbIndex
 	newDB.addAof = oldDB.addAof // inherit oldDB
+	newDB.trash = oldDB.trash
+	newDB.hotKeys = oldDB.hotKeys
+	newDB.notifier = oldDB.notifier
+	newDB.data.SetLockStats(oldDB.data.LockStats())
 	server.dbSet[dbIndex].Store(newDB)
 	return &protocol.OkReply{}
 }
@@ -302,11 +408,10 @@ func (server *Server) GetEntity(dbIndex int, key string) (*database.DataEntity,
 }
 
 func (server *Server) GetExpiration(dbIndex int, key string) *time.Time {
-	raw, ok := server.mustSelectDB(dbIndex).ttlMap.Get(key)
+	expireTime, ok := server.mustSelectDB(dbIndex).ttlMap.Get(key)
 	if !ok {
 		return nil
 	}
-	expireTime, _ := raw.(time.Time)
 	return &expireTime
 }
 
@@ -324,6 +429,11 @@ func (server *Server) RWLocks(dbIndex int, writeKeys []string, readKeys []string
 	server.mustSelectDB(dbIndex).RWLocks(writeKeys, readKeys)
 }
 
+// TryRWLocks lock keys for writing and reading, gives up and returns ctx.Err() when ctx is done
+func (server *Server) TryRWLocks(ctx context.Context, dbIndex int, writeKeys []string, readKeys []string) error {
+	return server.mustSelectDB(dbIndex).TryRWLocks(ctx, writeKeys, readKeys)
+}
+
 // RWUnLocks unlock keys for writing and reading
 func (server *Server) RWUnLocks(dbIndex int, writeKeys []string, readKeys []string) {
 	server.mustSelectDB(dbIndex).RWUnLocks(writeKeys, readKeys)
@@ -335,12 +445,18 @@ func (server *Server) GetUndoLogs(dbIndex int, cmdLine [][]byte) []CmdLine {
 }
 
 // ExecWithLock executes normal commands, invoker should provide locks
+// versions of write keys are increased if the command succeeded
 func (server *Server) ExecWithLock(conn redis.Connection, cmdLine [][]byte) redis.Reply {
 	db, errReply := server.selectDB(conn.GetDBIndex())
 	if errReply != nil {
 		return errReply
 	}
-	return db.execWithLock(cmdLine)
+	result := db.execWithLock(cmdLine)
+	if !protocol.IsErrorReply(result) {
+		write, _ := GetRelatedKeys(cmdLine)
+		db.addVersion(write...)
+	}
+	return result
 }
 
 // BGRewriteAOF asynchronously rewrites Append-Only-File
@@ -365,7 +481,7 @@ func SaveRDB(db *Server, args [][]byte) redis.Reply {
 	}
 	rdbFilename := config.Properties.RDBFilename
 	if rdbFilename == "" {
-		rdbFilename = "dump.rdb"
+		rdbFilename = config.GetDataPath("dump.rdb")
 	}
 	err := db.persister.GenerateRDB(rdbFilename)
 	if err != nil {
@@ -387,7 +503,7 @@ func BGSaveRDB(db *Server, args [][]byte) redis.Reply {
 		}()
 		rdbFilename := config.Properties.RDBFilename
 		if rdbFilename == "" {
-			rdbFilename = "dump.rdb"
+			rdbFilename = config.GetDataPath("dump.rdb")
 		}
 		err := db.persister.GenerateRDB(rdbFilename)
 		if err != nil {
@@ -413,18 +529,50 @@ func (server *Server) startReplCron() {
 	}(server)
 }
 
+// startExpireCron 定期删除各个DB中已过期的key，直到 stopExpireCron 被调用
+func (server *Server) startExpireCron() {
+	// 在启动协程前创建 ticker，使用 clock.Fake 时之后的 Advance 一定能触发主动过期
+	ticker := clock.NewTicker(activeExpireInterval)
+	server.expireCronStop = make(chan struct{})
+	server.expireCronDone = make(chan struct{})
+	go func(mdb *Server) {
+		defer close(mdb.expireCronDone)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.Chan():
+				for i := range mdb.dbSet {
+					mdb.mustSelectDB(i).activeExpireCycle(activeExpireTimeLimit)
+				}
+			case <-mdb.expireCronStop:
+				return
+			}
+		}
+	}(server)
+}
+
+// stopExpireCron 停止主动过期并等待正在进行的一轮结束，可以重复调用
+func (server *Server) stopExpireCron() {
+	if server.expireCronStop == nil {
+		return
+	}
+	server.stopExpireOnce.Do(func() {
+		close(server.expireCronStop)
+	})
+	<-server.expireCronDone
+}
+
 // GetAvgTTL Calculate the average expiration time of keys
 func (server *Server) GetAvgTTL(dbIndex, randomKeyCount int) int64 {
 	var ttlCount int64
 	db := server.mustSelectDB(dbIndex)
 	keys := db.data.RandomKeys(randomKeyCount)
 	for _, k := range keys {
-		t := time.Now()
-		rawExpireTime, ok := db.ttlMap.Get(k)
+		t := clock.Now()
+		expireTime, ok := db.ttlMap.Get(k)
 		if !ok {
 			continue
 		}
-		expireTime, _ := rawExpireTime.(time.Time)
 		// if the key has already reached its expiration time during calculation, ignore it
 		if expireTime.Sub(t).Microseconds() > 0 {
 			ttlCount += expireTime.Sub(t).Microseconds()
@@ -7,6 +7,7 @@ import (
 	"Godis/lib/utils"
 	"Godis/redis/protocol"
 	"strconv"
+	"strings"
 )
 
 func (db *DB) getAsSet(key string) (*HashSet.Set, protocol.ErrorReply) {
@@ -31,6 +32,7 @@ func (db *DB) getOrInitSet(key string) (set *HashSet.Set, inited bool, errReply
 		set = HashSet.Make()
 		db.PutEntity(key, &database.DataEntity{
 			Data: set,
+			Type: database.TypeSet,
 		})
 		inited = true
 	}
@@ -76,6 +78,64 @@ func execSIsMember(db *DB, args [][]byte) redis.Reply {
 	return protocol.MakeIntReply(0)
 }
 
+// execSMIsMember checks if each given value is member of set
+func execSMIsMember(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	members := args[1:]
+
+	// 只查找一次集合，不存在的集合视为空集
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	zero := protocol.MakeIntReply(0)
+	one := protocol.MakeIntReply(1)
+	result := make([]redis.Reply, len(members))
+	for i, member := range members {
+		if set != nil && set.Has(string(member)) {
+			result[i] = one
+		} else {
+			result[i] = zero
+		}
+	}
+	return protocol.MakeMultiRawReply(result)
+}
+
+// execSMove moves a member from source set to destination set
+func execSMove(db *DB, args [][]byte) redis.Reply {
+	src := string(args[0])
+	dest := string(args[1])
+	member := string(args[2])
+
+	// 两个key在 prepareSMove 中一起加了写锁，移动过程对其他命令是原子的
+	srcSet, errReply := db.getAsSet(src)
+	if errReply != nil {
+		return errReply
+	}
+	destSet, errReply := db.getAsSet(dest)
+	if errReply != nil {
+		return errReply
+	}
+	if srcSet == nil || !srcSet.Has(member) {
+		return protocol.MakeIntReply(0)
+	}
+	if src == dest {
+		return protocol.MakeIntReply(1)
+	}
+	srcSet.Remove(member)
+	db.removeIfEmpty(src, srcSet.Len())
+	if destSet == nil {
+		destSet = HashSet.Make()
+		db.PutEntity(dest, &database.DataEntity{
+			Data: destSet,
+			Type: database.TypeSet,
+		})
+	}
+	destSet.Add(member)
+	db.addAof(utils.ToCmdLine3("smove", args...))
+	return protocol.MakeIntReply(1)
+}
+
 // execSRem removes a member from set
 func execSRem(db *DB, args [][]byte) redis.Reply {
 	key := string(args[0])
@@ -92,9 +152,7 @@ func execSRem(db *DB, args [][]byte) redis.Reply {
 	for _, member := range members {
 		counter += set.Remove(string(member))
 	}
-	if set.Len() == 0 {
-		db.Remove(key)
-	}
+	db.removeIfEmpty(key, set.Len())
 	if counter > 0 {
 		db.addAof(utils.ToCmdLine3("srem", args...))
 	}
@@ -134,6 +192,7 @@ func execSPop(db *DB, args [][]byte) redis.Reply {
 		set.Remove(v)
 		result[i] = []byte(v)
 	}
+	db.removeIfEmpty(key, set.Len())
 
 	if count > 0 {
 		db.addAof(utils.ToCmdLine3("spop", args...))
@@ -208,6 +267,40 @@ func execSInter(db *DB, args [][]byte) redis.Reply {
 	return set2reply(result)
 }
 
+// execSInterCard returns cardinality of the intersection: SINTERCARD numkeys key [key ...] [LIMIT limit]
+// 达到 limit 后立即停止计数，limit 为 0 表示不限制
+func execSInterCard(db *DB, args [][]byte) redis.Reply {
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil || numKeys <= 0 {
+		return protocol.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys > len(args)-1 {
+		return protocol.MakeErrReply("ERR Number of keys can't be greater than number of args")
+	}
+	limit := 0
+	for i := numKeys + 1; i < len(args); i += 2 {
+		if strings.ToLower(string(args[i])) != "limit" || i+1 >= len(args) {
+			return protocol.MakeSyntaxErrReply()
+		}
+		limit, err = strconv.Atoi(string(args[i+1]))
+		if err != nil || limit < 0 {
+			return protocol.MakeErrReply("ERR LIMIT can't be negative")
+		}
+	}
+	sets := make([]*HashSet.Set, 0, numKeys)
+	for _, arg := range args[1 : numKeys+1] {
+		set, errReply := db.getAsSet(string(arg))
+		if errReply != nil {
+			return errReply
+		}
+		if set.Len() == 0 {
+			return protocol.MakeIntReply(0)
+		}
+		sets = append(sets, set)
+	}
+	return protocol.MakeIntReply(int64(HashSet.IntersectCard(limit, sets...)))
+}
+
 // execSInterStore intersects multiple sets and store the result in a key
 func execSInterStore(db *DB, args [][]byte) redis.Reply {
 	dest := string(args[0])
@@ -227,6 +320,7 @@ func execSInterStore(db *DB, args [][]byte) redis.Reply {
 
 	db.PutEntity(dest, &database.DataEntity{
 		Data: result,
+		Type: database.TypeSet,
 	})
 	db.addAof(utils.ToCmdLine3("sinterstore", args...))
 	return protocol.MakeIntReply(int64(result.Len()))
@@ -267,6 +361,7 @@ func execSUnionStore(db *DB, args [][]byte) redis.Reply {
 
 	db.PutEntity(dest, &database.DataEntity{
 		Data: result,
+		Type: database.TypeSet,
 	})
 	db.addAof(utils.ToCmdLine3("sunionstore", args...))
 	return protocol.MakeIntReply(int64(result.Len()))
@@ -306,11 +401,36 @@ func execSDiffStore(db *DB, args [][]byte) redis.Reply {
 	}
 	db.PutEntity(dest, &database.DataEntity{
 		Data: result,
+		Type: database.TypeSet,
 	})
 	db.addAof(utils.ToCmdLine3("sdiffstore", args...))
 	return protocol.MakeIntReply(int64(result.Len()))
 }
 
+// execSScan iterates members of set
+// usage: SSCAN key cursor [MATCH pattern] [COUNT count]
+func execSScan(db *DB, args [][]byte) redis.Reply {
+	cursor, pattern, count, errReply := parseScanArgs(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	set, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		return makeScanReply(0, nil)
+	}
+	members, next := set.Scan(cursor, count)
+	result := make([][]byte, 0, len(members))
+	for _, member := range members {
+		if pattern == nil || pattern.IsMatch(member) {
+			result = append(result, []byte(member))
+		}
+	}
+	return makeScanReply(next, result)
+}
+
 // execSRandMember gets random members from set
 func execSRandMember(db *DB, args [][]byte) redis.Reply {
 	if len(args) != 1 && len(args) != 2 {
@@ -354,11 +474,60 @@ func execSRandMember(db *DB, args [][]byte) redis.Reply {
 	return &protocol.EmptyMultiBulkReply{}
 }
 
+// readSetsForCost 读取参与运算的集合，不存在或类型错误的键视为空集合
+func readSetsForCost(db *DB, keys [][]byte) []*HashSet.Set {
+	sets := make([]*HashSet.Set, len(keys))
+	for i, key := range keys {
+		sets[i], _ = db.getAsSet(string(key))
+	}
+	return sets
+}
+
+func estimateSInter(db *DB, args [][]byte) string {
+	return HashSet.IntersectCost(0, readSetsForCost(db, args)...).String()
+}
+
+func estimateSInterStore(db *DB, args [][]byte) string {
+	return estimateSInter(db, args[1:])
+}
+
+func estimateSInterCard(db *DB, args [][]byte) string {
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil || numKeys <= 0 || numKeys > len(args)-1 {
+		return ""
+	}
+	limit := 0
+	if len(args) == numKeys+3 && strings.ToLower(string(args[numKeys+1])) == "limit" {
+		limit, _ = strconv.Atoi(string(args[numKeys+2]))
+	}
+	return HashSet.IntersectCost(limit, readSetsForCost(db, args[1:numKeys+1])...).String()
+}
+
+func estimateSUnion(db *DB, args [][]byte) string {
+	return HashSet.UnionCost(readSetsForCost(db, args)...).String()
+}
+
+func estimateSUnionStore(db *DB, args [][]byte) string {
+	return estimateSUnion(db, args[1:])
+}
+
+func estimateSDiff(db *DB, args [][]byte) string {
+	return HashSet.DiffCost(readSetsForCost(db, args)...).String()
+}
+
+func estimateSDiffStore(db *DB, args [][]byte) string {
+	return estimateSDiff(db, args[1:])
+}
+
 func init() {
 	registerCommand("SAdd", execSAdd, writeFirstKey, undoSetChange, -3, flagWrite).
 		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
 	registerCommand("SIsMember", execSIsMember, readFirstKey, nil, 3, flagReadOnly).
 		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("SMIsMember", execSMIsMember, readFirstKey, nil, -3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("SMove", execSMove, prepareSMove, undoSMove, 4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 2, 1)
 	registerCommand("SRem", execSRem, writeFirstKey, undoSetChange, -3, flagWrite).
 		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
 	registerCommand("SPop", execSPop, writeFirstKey, undoSetChange, -2, flagWrite).
@@ -368,17 +537,28 @@ func init() {
 	registerCommand("SMembers", execSMembers, readFirstKey, nil, 2, flagReadOnly).
 		attachCommandExtra([]string{redisFlagReadonly, redisFlagSortForScript}, 1, 1, 1)
 	registerCommand("SInter", execSInter, prepareSetCalculate, nil, -2, flagReadOnly).
+		attachCostEstimator(estimateSInter).
 		attachCommandExtra([]string{redisFlagReadonly, redisFlagSortForScript}, 1, -1, 1)
 	registerCommand("SInterStore", execSInterStore, prepareSetCalculateStore, rollbackFirstKey, -3, flagWrite).
+		attachCostEstimator(estimateSInterStore).
 		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, -1, 1)
+	registerCommand("SInterCard", execSInterCard, prepareZSetCalculate, nil, -3, flagReadOnly).
+		attachCostEstimator(estimateSInterCard).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagMovableKeys}, 0, 0, 0)
 	registerCommand("SUnion", execSUnion, prepareSetCalculate, nil, -2, flagReadOnly).
+		attachCostEstimator(estimateSUnion).
 		attachCommandExtra([]string{redisFlagReadonly, redisFlagSortForScript}, 1, -1, 1)
 	registerCommand("SUnionStore", execSUnionStore, prepareSetCalculateStore, rollbackFirstKey, -3, flagWrite).
+		attachCostEstimator(estimateSUnionStore).
 		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, -1, 1)
 	registerCommand("SDiff", execSDiff, prepareSetCalculate, nil, -2, flagReadOnly).
+		attachCostEstimator(estimateSDiff).
 		attachCommandExtra([]string{redisFlagReadonly, redisFlagSortForScript}, 1, 1, 1)
 	registerCommand("SDiffStore", execSDiffStore, prepareSetCalculateStore, rollbackFirstKey, -3, flagWrite).
+		attachCostEstimator(estimateSDiffStore).
 		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, 1, 1)
 	registerCommand("SRandMember", execSRandMember, readFirstKey, nil, -2, flagReadOnly).
 		attachCommandExtra([]string{redisFlagReadonly, redisFlagRandom}, 1, 1, 1)
+	registerCommand("SScan", execSScan, readFirstKey, nil, -3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagRandom}, 1, 1, 1)
 }
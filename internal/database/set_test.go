@@ -0,0 +1,96 @@
+package database
+
+import (
+	"Godis/internal/redis/connection"
+	"Godis/lib/utils"
+	"strings"
+	"testing"
+)
+
+func TestSMove(t *testing.T) {
+	db := makeDB()
+	var aofLines []string
+	db.addAof = func(line CmdLine) {
+		aofLines = append(aofLines, strings.ToLower(string(line[0])))
+	}
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(db.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+	exec("SADD", "src", "a", "b")
+	exec("SET", "str", "v")
+	aofLines = nil
+
+	// 目标key类型错误时不修改源集合
+	if reply := exec("SMOVE", "src", "str", "a"); !strings.HasPrefix(reply, "-WRONGTYPE") {
+		t.Errorf("expect wrong type error, actual %q", reply)
+	}
+	if reply := exec("SMOVE", "str", "src", "a"); !strings.HasPrefix(reply, "-WRONGTYPE") {
+		t.Errorf("expect wrong type error, actual %q", reply)
+	}
+	if reply := exec("SCARD", "src"); reply != ":2\r\n" {
+		t.Errorf("source should be unchanged, actual %q", reply)
+	}
+
+	// 源和目标相同时成员存在返回1，集合不变
+	if reply := exec("SMOVE", "src", "src", "a"); reply != ":1\r\n" {
+		t.Errorf("expect 1, actual %q", reply)
+	}
+	if reply := exec("SMOVE", "src", "src", "x"); reply != ":0\r\n" {
+		t.Errorf("expect 0, actual %q", reply)
+	}
+	if reply := exec("SCARD", "src"); reply != ":2\r\n" {
+		t.Errorf("source should be unchanged, actual %q", reply)
+	}
+
+	if reply := exec("SMOVE", "missing", "dest", "a"); reply != ":0\r\n" {
+		t.Errorf("expect 0 of missing source, actual %q", reply)
+	}
+	if reply := exec("SMOVE", "src", "dest", "x"); reply != ":0\r\n" {
+		t.Errorf("expect 0 of missing member, actual %q", reply)
+	}
+	if len(aofLines) != 0 {
+		t.Errorf("nothing should be written to aof, actual %v", aofLines)
+	}
+
+	if reply := exec("SMOVE", "src", "dest", "a"); reply != ":1\r\n" {
+		t.Errorf("expect 1, actual %q", reply)
+	}
+	// 移动最后一个成员后删除源集合
+	if reply := exec("SMOVE", "src", "dest", "b"); reply != ":1\r\n" {
+		t.Errorf("expect 1, actual %q", reply)
+	}
+	if reply := exec("EXISTS", "src"); reply != ":0\r\n" {
+		t.Errorf("empty source should be removed, actual %q", reply)
+	}
+	if reply := exec("SMISMEMBER", "dest", "a", "b"); reply != "*2\r\n:1\r\n:1\r\n" {
+		t.Errorf("unexpected members of dest %q", reply)
+	}
+	if len(aofLines) != 2 || aofLines[0] != "smove" || aofLines[1] != "smove" {
+		t.Errorf("expect two smove in aof, actual %v", aofLines)
+	}
+}
+
+func TestSMIsMember(t *testing.T) {
+	db := makeDB()
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(db.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+	exec("SADD", "s", "a", "b")
+	exec("SET", "str", "v")
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"SMISMEMBER", "s", "a", "x", "b", "a"}, "*4\r\n:1\r\n:0\r\n:1\r\n:1\r\n"},
+		{[]string{"SMISMEMBER", "missing", "a", "b"}, "*2\r\n:0\r\n:0\r\n"},
+		{[]string{"SMISMEMBER", "str", "a"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+		{[]string{"SMISMEMBER", "s"}, "-ERR wrong number of arguments for 'smismember' command\r\n"},
+	}
+	for _, c := range cases {
+		if actual := exec(c.args...); actual != c.expected {
+			t.Errorf("%s: expect %q, actual %q", strings.Join(c.args, " "), c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Godis/interface/redis"
+	"Godis/internal/config"
+	"Godis/redis/protocol"
+)
+
+// slowLog 记录执行时间超过 slowlog-log-slower-than 的命令，与 redis 的 SLOWLOG 一致
+// 最多保留 slowlog-max-len 条，新的记录在前
+type slowLog struct {
+	mu      sync.Mutex
+	entries []*slowLogEntry
+	nextID  int64
+}
+
+type slowLogEntry struct {
+	id        int64
+	timestamp int64 // unix seconds
+	duration  int64 // microseconds
+	args      []string
+	addr      string
+	name      string
+	// cost 是命令执行前的代价估计，例如集合运算的算法和规模，没有估计时为空
+	cost string
+}
+
+func makeSlowLog() *slowLog {
+	return &slowLog{}
+}
+
+func (log *slowLog) isSlow(cost time.Duration) bool {
+	if log == nil {
+		return false
+	}
+	threshold := config.Properties.SlowlogLogSlowerThan
+	return threshold >= 0 && cost.Microseconds() >= int64(threshold)
+}
+
+func (log *slowLog) add(c redis.Connection, cmdLine [][]byte, duration time.Duration, cost string) {
+	entry := &slowLogEntry{
+		timestamp: time.Now().Unix(),
+		duration:  duration.Microseconds(),
+		args:      truncateCmdLine(cmdLine),
+		cost:      cost,
+	}
+	if c != nil {
+		entry.addr = c.RemoteAddr()
+		entry.name = c.Name()
+	}
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	entry.id = log.nextID
+	log.nextID++
+	maxLen := config.Properties.SlowlogMaxLen
+	if maxLen <= 0 {
+		log.entries = nil
+		return
+	}
+	log.entries = append(log.entries, nil)
+	copy(log.entries[1:], log.entries)
+	log.entries[0] = entry
+	if len(log.entries) > maxLen {
+		log.entries = log.entries[:maxLen]
+	}
+}
+
+// get returns the newest count entries, count < 0 means all
+func (log *slowLog) get(count int) []*slowLogEntry {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	if count < 0 || count > len(log.entries) {
+		count = len(log.entries)
+	}
+	result := make([]*slowLogEntry, count)
+	copy(result, log.entries)
+	return result
+}
+
+func (log *slowLog) len() int {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	return len(log.entries)
+}
+
+func (log *slowLog) reset() {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	log.entries = nil
+}
+
+func (entry *slowLogEntry) toReply() redis.Reply {
+	args := make([][]byte, len(entry.args))
+	for i, arg := range entry.args {
+		args[i] = []byte(arg)
+	}
+	replies := []redis.Reply{
+		protocol.MakeIntReply(entry.id),
+		protocol.MakeIntReply(entry.timestamp),
+		protocol.MakeIntReply(entry.duration),
+		protocol.MakeMultiBulkReply(args),
+		protocol.MakeBulkReply([]byte(entry.addr)),
+		protocol.MakeBulkReply([]byte(entry.name)),
+	}
+	// redis 的记录只有6项，代价估计附加在最后，不认识的客户端会忽略
+	if entry.cost != "" {
+		replies = append(replies, protocol.MakeBulkReply([]byte(entry.cost)))
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+const (
+	slowCommandMaxArgs   = 32
+	slowCommandMaxArgLen = 128
+)
+
+// truncateCmdLine truncates long arguments and long command lines like redis slowlog
+func truncateCmdLine(cmdLine [][]byte) []string {
+	args := make([]string, 0, len(cmdLine))
+	for i, arg := range cmdLine {
+		if i == slowCommandMaxArgs-1 && len(cmdLine) > slowCommandMaxArgs {
+			args = append(args, fmt.Sprintf("... (%d more arguments)", len(cmdLine)-i))
+			break
+		}
+		if len(arg) > slowCommandMaxArgLen {
+			args = append(args, fmt.Sprintf("%s... (%d more bytes)", arg[:slowCommandMaxArgLen], len(arg)-slowCommandMaxArgLen))
+			continue
+		}
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+// execSlowLog implements SLOWLOG GET [count] | LEN | RESET | HELP
+func (server *Server) execSlowLog(args [][]byte) redis.Reply {
+	subCommand := strings.ToLower(string(args[0]))
+	switch subCommand {
+	case "get":
+		if len(args) > 2 {
+			return protocol.MakeArgNumErrReply("slowlog|get")
+		}
+		count := 10
+		if len(args) == 2 {
+			var err error
+			count, err = strconv.Atoi(string(args[1]))
+			if err != nil || count < -1 {
+				return protocol.MakeErrReply("ERR count should be greater than or equal to -1")
+			}
+		}
+		entries := server.slowLog.get(count)
+		replies := make([]redis.Reply, len(entries))
+		for i, entry := range entries {
+			replies[i] = entry.toReply()
+		}
+		return protocol.MakeMultiRawReply(replies)
+	case "len":
+		if len(args) != 1 {
+			return protocol.MakeArgNumErrReply("slowlog|len")
+		}
+		return protocol.MakeIntReply(int64(server.slowLog.len()))
+	case "reset":
+		if len(args) != 1 {
+			return protocol.MakeArgNumErrReply("slowlog|reset")
+		}
+		server.slowLog.reset()
+		return protocol.MakeOkReply()
+	case "help":
+		return protocol.MakeMultiBulkReply([][]byte{
+			[]byte("SLOWLOG <subcommand> [<arg> [value] [opt] ...]. Subcommands are:"),
+			[]byte("GET [<count>]"),
+			[]byte("    Return top <count> entries from the slowlog (default: 10, -1 mean all)."),
+			[]byte("    Entries are made of:"),
+			[]byte("    id, timestamp, time in microseconds, arguments array, client IP and port,"),
+			[]byte("    client name, and estimated cost for commands supporting it"),
+			[]byte("LEN"),
+			[]byte("    Return the length of the slowlog."),
+			[]byte("RESET"),
+			[]byte("    Reset the slowlog."),
+		})
+	}
+	return protocol.MakeErrReply("ERR unknown subcommand '" + subCommand + "'. Try SLOWLOG HELP.")
+}
+
+// estimateCost returns the cost estimate of a slow command, it runs after the command so keys may have changed
+func (server *Server) estimateCost(c redis.Connection, cmdLine [][]byte) string {
+	cmd, ok := cmdTable[strings.ToLower(string(cmdLine[0]))]
+	if !ok || cmd.estimator == nil || cmd.prepare == nil || !validateArity(cmd.arity, cmdLine) {
+		return ""
+	}
+	dbIndex := 0
+	if c != nil {
+		dbIndex = c.GetDBIndex()
+	}
+	db, errReply := server.selectDB(dbIndex)
+	if errReply != nil {
+		return ""
+	}
+	write, read := cmd.prepare(cmdLine[1:])
+	keys := append(write, read...)
+	db.RWLocks(nil, keys)
+	defer db.RWUnLocks(nil, keys)
+	return cmd.estimator(db, cmdLine[1:])
+}
@@ -5,9 +5,11 @@ import (
 	"strconv"
 	"strings"
 
+	HashSet "Godis/datastruct/set"
 	SortedSet "Godis/datastruct/sortedset"
 	"Godis/interface/database"
 	"Godis/interface/redis"
+	"Godis/internal/config"
 	"Godis/lib/utils"
 	"Godis/redis/protocol"
 )
@@ -24,6 +26,11 @@ func (db *DB) getAsSortedSet(key string) (*SortedSet.SortedSet, protocol.ErrorRe
 	return sortedSet, nil
 }
 
+// makeSortedSet makes an empty sorted set, small sorted set is encoded as listpack
+func makeSortedSet() *SortedSet.SortedSet {
+	return SortedSet.MakePacked(config.Properties.ZSetMaxListpackEntries, config.Properties.ZSetMaxListpackValue)
+}
+
 func (db *DB) getOrInitSortedSet(key string) (sortedSet *SortedSet.SortedSet, inited bool, errReply protocol.ErrorReply) {
 	sortedSet, errReply = db.getAsSortedSet(key)
 	if errReply != nil {
@@ -31,9 +38,10 @@ func (db *DB) getOrInitSortedSet(key string) (sortedSet *SortedSet.SortedSet, in
 	}
 	inited = false
 	if sortedSet == nil {
-		sortedSet = SortedSet.Make()
+		sortedSet = makeSortedSet()
 		db.PutEntity(key, &database.DataEntity{
 			Data: sortedSet,
+			Type: database.TypeZSet,
 		})
 		inited = true
 	}
@@ -107,8 +115,7 @@ func execZScore(db *DB, args [][]byte) redis.Reply {
 	if !exists {
 		return &protocol.NullBulkReply{}
 	}
-	value := strconv.FormatFloat(element.Score, 'f', -1, 64)
-	return protocol.MakeBulkReply([]byte(value))
+	return protocol.MakeDoubleReply(element.Score)
 }
 
 // execZRank gets index of a member in sortedset, ascending order, start from 0
@@ -459,6 +466,7 @@ func execZRemRangeByScore(db *DB, args [][]byte) redis.Reply {
 	}
 
 	removed := sortedSet.RemoveRange(min, max)
+	db.removeIfEmpty(key, int(sortedSet.Len()))
 	if removed > 0 {
 		db.addAof(utils.ToCmdLine3("zremrangebyscore", args...))
 	}
@@ -510,6 +518,7 @@ func execZRemRangeByRank(db *DB, args [][]byte) redis.Reply {
 
 	// assert: start in [0, size - 1], stop in [start, size]
 	removed := sortedSet.RemoveByRank(start, stop)
+	db.removeIfEmpty(key, int(sortedSet.Len()))
 	if removed > 0 {
 		db.addAof(utils.ToCmdLine3("zremrangebyrank", args...))
 	}
@@ -517,6 +526,15 @@ func execZRemRangeByRank(db *DB, args [][]byte) redis.Reply {
 }
 
 func execZPopMin(db *DB, args [][]byte) redis.Reply {
+	return execZPop(db, args, false)
+}
+
+func execZPopMax(db *DB, args [][]byte) redis.Reply {
+	return execZPop(db, args, true)
+}
+
+// execZPop removes and returns members with the lowest scores, or the highest scores if max is true
+func execZPop(db *DB, args [][]byte, max bool) redis.Reply {
 	key := string(args[0])
 	count := 1
 	if len(args) > 1 {
@@ -535,9 +553,17 @@ func execZPopMin(db *DB, args [][]byte) redis.Reply {
 		return protocol.MakeEmptyMultiBulkReply()
 	}
 
-	removed := sortedSet.PopMin(count)
+	var removed []*SortedSet.Element
+	cmdName := "zpopmin"
+	if max {
+		removed = sortedSet.PopMax(count)
+		cmdName = "zpopmax"
+	} else {
+		removed = sortedSet.PopMin(count)
+	}
+	db.removeIfEmpty(key, int(sortedSet.Len()))
 	if len(removed) > 0 {
-		db.addAof(utils.ToCmdLine3("zpopmin", args...))
+		db.addAof(utils.ToCmdLine3(cmdName, args...))
 	}
 	result := make([][]byte, 0, len(removed)*2)
 	for _, element := range removed {
@@ -572,6 +598,7 @@ func execZRem(db *DB, args [][]byte) redis.Reply {
 			deleted++
 		}
 	}
+	db.removeIfEmpty(key, int(sortedSet.Len()))
 	if deleted > 0 {
 		db.addAof(utils.ToCmdLine3("zrem", args...))
 	}
@@ -733,7 +760,10 @@ func execZRemRangeByLex(db *DB, args [][]byte) redis.Reply {
 	}
 
 	count := sortedSet.RemoveRange(min, max)
-
+	db.removeIfEmpty(key, int(sortedSet.Len()))
+	if count > 0 {
+		db.addAof(utils.ToCmdLine3("zremrangebylex", args...))
+	}
 	return protocol.MakeIntReply(count)
 }
 
@@ -796,6 +826,283 @@ func execZRevRangeByLex(db *DB, args [][]byte) redis.Reply {
 	return protocol.MakeMultiBulkReply(result)
 }
 
+// execZMScore gets scores of multiple members in sortedset
+func execZMScore(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, len(args)-1)
+	if sortedSet == nil {
+		return protocol.MakeMultiBulkReply(result)
+	}
+	for i, member := range args[1:] {
+		element, exists := sortedSet.Get(string(member))
+		if !exists {
+			continue
+		}
+		result[i] = []byte(strconv.FormatFloat(element.Score, 'f', -1, 64))
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+/* ---- Set Calculation ---- */
+
+const (
+	aggregateSum = iota
+	aggregateMin
+	aggregateMax
+)
+
+// zsetCalcArgs is parsed arguments of ZUNION/ZINTER/ZDIFF and their STORE variants
+type zsetCalcArgs struct {
+	keys       []string
+	weights    []float64
+	aggregate  int
+	withScores bool
+}
+
+// parseZSetCalcArgs 解析 numkeys key [key ...] [WEIGHTS weight ...] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]
+// ZDIFF 不支持 WEIGHTS 和 AGGREGATE，STORE 版本不支持 WITHSCORES
+func parseZSetCalcArgs(args [][]byte, allowWeights bool, allowWithScores bool) (*zsetCalcArgs, protocol.ErrorReply) {
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil {
+		return nil, protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if numKeys <= 0 {
+		return nil, protocol.MakeErrReply("ERR at least 1 input key is needed for this command")
+	}
+	if numKeys > len(args)-1 {
+		return nil, protocol.MakeSyntaxErrReply()
+	}
+	calcArgs := &zsetCalcArgs{
+		keys:      make([]string, numKeys),
+		weights:   make([]float64, numKeys),
+		aggregate: aggregateSum,
+	}
+	for i := 0; i < numKeys; i++ {
+		calcArgs.keys[i] = string(args[i+1])
+		calcArgs.weights[i] = 1
+	}
+	for i := numKeys + 1; i < len(args); {
+		switch strings.ToLower(string(args[i])) {
+		case "weights":
+			if !allowWeights || i+numKeys >= len(args) {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			for j := 0; j < numKeys; j++ {
+				weight, err := strconv.ParseFloat(string(args[i+1+j]), 64)
+				if err != nil {
+					return nil, protocol.MakeErrReply("ERR weight value is not a float")
+				}
+				calcArgs.weights[j] = weight
+			}
+			i += numKeys + 1
+		case "aggregate":
+			if !allowWeights || i+1 >= len(args) {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			switch strings.ToLower(string(args[i+1])) {
+			case "sum":
+				calcArgs.aggregate = aggregateSum
+			case "min":
+				calcArgs.aggregate = aggregateMin
+			case "max":
+				calcArgs.aggregate = aggregateMax
+			default:
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			i += 2
+		case "withscores":
+			if !allowWithScores {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			calcArgs.withScores = true
+			i++
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+	return calcArgs, nil
+}
+
+// getZSetsForCalc 读取参与运算的集合，不存在的key返回nil，普通集合中成员的分值视为1
+func (db *DB) getZSetsForCalc(keys []string) ([]*SortedSet.SortedSet, protocol.ErrorReply) {
+	zsets := make([]*SortedSet.SortedSet, len(keys))
+	for i, key := range keys {
+		entity, exists := db.GetEntity(key)
+		if !exists {
+			continue
+		}
+		switch data := entity.Data.(type) {
+		case *SortedSet.SortedSet:
+			zsets[i] = data
+		case *HashSet.Set:
+			zset := SortedSet.Make()
+			data.ForEach(func(member string) bool {
+				zset.Add(member, 1)
+				return true
+			})
+			zsets[i] = zset
+		default:
+			return nil, &protocol.WrongTypeErrReply{}
+		}
+	}
+	return zsets, nil
+}
+
+func forEachZSetElement(zset *SortedSet.SortedSet, consumer func(element *SortedSet.Element) bool) {
+	if zset == nil || zset.Len() == 0 {
+		return
+	}
+	zset.ForEachByRank(0, zset.Len(), false, consumer)
+}
+
+// weightScore 计算加权后的分值，inf * 0 的结果按0处理
+func weightScore(score float64, weight float64) float64 {
+	result := score * weight
+	if math.IsNaN(result) {
+		return 0
+	}
+	return result
+}
+
+func aggregateScore(a float64, b float64, aggregate int) float64 {
+	switch aggregate {
+	case aggregateMin:
+		return math.Min(a, b)
+	case aggregateMax:
+		return math.Max(a, b)
+	}
+	// inf + -inf 的结果按0处理
+	result := a + b
+	if math.IsNaN(result) {
+		return 0
+	}
+	return result
+}
+
+func zsetUnion(zsets []*SortedSet.SortedSet, calcArgs *zsetCalcArgs) *SortedSet.SortedSet {
+	result := SortedSet.Make()
+	for i, zset := range zsets {
+		forEachZSetElement(zset, func(element *SortedSet.Element) bool {
+			score := weightScore(element.Score, calcArgs.weights[i])
+			if old, ok := result.Get(element.Member); ok {
+				score = aggregateScore(old.Score, score, calcArgs.aggregate)
+			}
+			result.Add(element.Member, score)
+			return true
+		})
+	}
+	return result
+}
+
+func zsetInter(zsets []*SortedSet.SortedSet, calcArgs *zsetCalcArgs) *SortedSet.SortedSet {
+	result := SortedSet.Make()
+	for _, zset := range zsets {
+		if zset == nil {
+			return result
+		}
+	}
+	forEachZSetElement(zsets[0], func(element *SortedSet.Element) bool {
+		score := weightScore(element.Score, calcArgs.weights[0])
+		for i := 1; i < len(zsets); i++ {
+			other, ok := zsets[i].Get(element.Member)
+			if !ok {
+				return true
+			}
+			score = aggregateScore(score, weightScore(other.Score, calcArgs.weights[i]), calcArgs.aggregate)
+		}
+		result.Add(element.Member, score)
+		return true
+	})
+	return result
+}
+
+// zsetDiff returns members of the first set which not exist in other sets, scores are kept
+func zsetDiff(zsets []*SortedSet.SortedSet) *SortedSet.SortedSet {
+	result := SortedSet.Make()
+	forEachZSetElement(zsets[0], func(element *SortedSet.Element) bool {
+		for i := 1; i < len(zsets); i++ {
+			if zsets[i] == nil {
+				continue
+			}
+			if _, ok := zsets[i].Get(element.Member); ok {
+				return true
+			}
+		}
+		result.Add(element.Member, element.Score)
+		return true
+	})
+	return result
+}
+
+func zsetToReply(zset *SortedSet.SortedSet, withScores bool) redis.Reply {
+	if zset.Len() == 0 {
+		return &protocol.EmptyMultiBulkReply{}
+	}
+	result := make([][]byte, 0, zset.Len())
+	forEachZSetElement(zset, func(element *SortedSet.Element) bool {
+		result = append(result, []byte(element.Member))
+		if withScores {
+			result = append(result, []byte(strconv.FormatFloat(element.Score, 'f', -1, 64)))
+		}
+		return true
+	})
+	return protocol.MakeMultiBulkReply(result)
+}
+
+// zsetCalc computes result of ZUNION/ZINTER/ZDIFF, cmd is lower case command name without `store`
+func zsetCalc(db *DB, cmd string, args [][]byte, allowWithScores bool) (*SortedSet.SortedSet, *zsetCalcArgs, protocol.ErrorReply) {
+	calcArgs, errReply := parseZSetCalcArgs(args, cmd != "zdiff", allowWithScores)
+	if errReply != nil {
+		return nil, nil, errReply
+	}
+	zsets, errReply := db.getZSetsForCalc(calcArgs.keys)
+	if errReply != nil {
+		return nil, nil, errReply
+	}
+	switch cmd {
+	case "zunion":
+		return zsetUnion(zsets, calcArgs), calcArgs, nil
+	case "zinter":
+		return zsetInter(zsets, calcArgs), calcArgs, nil
+	}
+	return zsetDiff(zsets), calcArgs, nil
+}
+
+func execZSetCalc(cmd string) ExecFunc {
+	return func(db *DB, args [][]byte) redis.Reply {
+		result, calcArgs, errReply := zsetCalc(db, cmd, args, true)
+		if errReply != nil {
+			return errReply
+		}
+		return zsetToReply(result, calcArgs.withScores)
+	}
+}
+
+// execZSetCalcStore stores result into destination, destination is deleted if result is empty
+func execZSetCalcStore(cmd string) ExecFunc {
+	return func(db *DB, args [][]byte) redis.Reply {
+		dest := string(args[0])
+		result, _, errReply := zsetCalc(db, cmd, args[1:], false)
+		if errReply != nil {
+			return errReply
+		}
+		if result.Len() == 0 {
+			db.Remove(dest)
+		} else {
+			db.PutEntity(dest, &database.DataEntity{
+				Data: result,
+				Type: database.TypeZSet,
+			})
+		}
+		db.addAof(utils.ToCmdLine3(cmd+"store", args...))
+		return protocol.MakeIntReply(result.Len())
+	}
+}
+
 func init() {
 	registerCommand("ZAdd", execZAdd, writeFirstKey, undoZAdd, -4, flagWrite).
 		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
@@ -821,6 +1128,8 @@ func init() {
 		attachCommandExtra([]string{redisFlagReadonly}, 1, 1, 1)
 	registerCommand("ZPopMin", execZPopMin, writeFirstKey, rollbackFirstKey, -2, flagWrite).
 		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("ZPopMax", execZPopMax, writeFirstKey, rollbackFirstKey, -2, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
 	registerCommand("ZRem", execZRem, writeFirstKey, undoZRem, -3, flagWrite).
 		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
 	registerCommand("ZRemRangeByScore", execZRemRangeByScore, writeFirstKey, rollbackFirstKey, 4, flagWrite).
@@ -835,4 +1144,18 @@ func init() {
 		attachCommandExtra([]string{redisFlagWrite}, 1, 1, 1)
 	registerCommand("ZRevRangeByLex", execZRevRangeByLex, readFirstKey, nil, -4, flagReadOnly).
 		attachCommandExtra([]string{redisFlagReadonly}, 1, 1, 1)
+	registerCommand("ZMScore", execZMScore, readFirstKey, nil, -3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("ZUnion", execZSetCalc("zunion"), prepareZSetCalculate, nil, -3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagMovableKeys}, 0, 0, 0)
+	registerCommand("ZUnionStore", execZSetCalcStore("zunion"), prepareZSetCalculateStore, rollbackFirstKey, -4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagMovableKeys}, 1, 1, 1)
+	registerCommand("ZInter", execZSetCalc("zinter"), prepareZSetCalculate, nil, -3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagMovableKeys}, 0, 0, 0)
+	registerCommand("ZInterStore", execZSetCalcStore("zinter"), prepareZSetCalculateStore, rollbackFirstKey, -4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagMovableKeys}, 1, 1, 1)
+	registerCommand("ZDiff", execZSetCalc("zdiff"), prepareZSetCalculate, nil, -3, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagMovableKeys}, 0, 0, 0)
+	registerCommand("ZDiffStore", execZSetCalcStore("zdiff"), prepareZSetCalculateStore, rollbackFirstKey, -4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagMovableKeys}, 1, 1, 1)
 }
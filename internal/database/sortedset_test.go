@@ -0,0 +1,113 @@
+package database
+
+import (
+	"Godis/internal/redis/connection"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"strings"
+	"testing"
+)
+
+func TestZRemRangeByLexAof(t *testing.T) {
+	db := makeDB()
+	var aofLines []string
+	db.addAof = func(line CmdLine) {
+		aofLines = append(aofLines, strings.ToLower(string(line[0])))
+	}
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(db.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+	exec("ZADD", "z", "0", "a", "0", "b", "0", "c")
+	aofLines = nil
+	// 没有删除成员时不写入 aof
+	if reply := exec("ZREMRANGEBYLEX", "z", "[x", "[z"); reply != ":0\r\n" {
+		t.Errorf("expect 0, actual %q", reply)
+	}
+	if reply := exec("ZREMRANGEBYLEX", "z", "[a", "(c"); reply != ":2\r\n" {
+		t.Errorf("expect 2, actual %q", reply)
+	}
+	if len(aofLines) != 1 || aofLines[0] != "zremrangebylex" {
+		t.Errorf("expect one zremrangebylex in aof, actual %v", aofLines)
+	}
+	if reply := exec("ZRANGE", "z", "0", "-1"); reply != "*1\r\n$1\r\nc\r\n" {
+		t.Errorf("unexpected members %q", reply)
+	}
+}
+
+func TestZSetCalc(t *testing.T) {
+	db := makeDB()
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(db.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+	exec("ZADD", "z1", "1", "a", "2", "b", "3", "c")
+	exec("ZADD", "z2", "10", "b", "20", "c", "30", "d")
+	// 普通集合中成员的分值视为1
+	exec("SADD", "s", "c", "e")
+	exec("SET", "str", "v")
+
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"ZUNION", "2", "z1", "z2", "WITHSCORES"}, multiBulk("a", "1", "b", "12", "c", "23", "d", "30")},
+		{[]string{"ZUNION", "2", "z1", "z2", "WEIGHTS", "2", "1", "WITHSCORES"}, multiBulk("a", "2", "b", "14", "c", "26", "d", "30")},
+		{[]string{"ZUNION", "2", "z1", "z2", "AGGREGATE", "MIN", "WITHSCORES"}, multiBulk("a", "1", "b", "2", "c", "3", "d", "30")},
+		{[]string{"ZUNION", "2", "z1", "z2", "AGGREGATE", "MAX", "WITHSCORES"}, multiBulk("a", "1", "b", "10", "c", "20", "d", "30")},
+		{[]string{"ZUNION", "2", "z1", "z2", "AGGREGATE", "SUM"}, multiBulk("a", "b", "c", "d")},
+		{[]string{"ZUNION", "2", "z1", "missing", "WITHSCORES"}, multiBulk("a", "1", "b", "2", "c", "3")},
+		{[]string{"ZUNION", "2", "z1", "s", "WITHSCORES"}, multiBulk("a", "1", "e", "1", "b", "2", "c", "4")},
+		{[]string{"ZUNION", "2", "missing", "missing2"}, "*0\r\n"},
+		{[]string{"ZINTER", "2", "z1", "z2", "WITHSCORES"}, multiBulk("b", "12", "c", "23")},
+		{[]string{"ZINTER", "2", "z1", "z2", "WEIGHTS", "1", "0.5", "AGGREGATE", "MAX", "WITHSCORES"}, multiBulk("b", "5", "c", "10")},
+		{[]string{"ZINTER", "2", "z1", "z2", "AGGREGATE", "MIN", "WITHSCORES"}, multiBulk("b", "2", "c", "3")},
+		{[]string{"ZINTER", "3", "z1", "z2", "s"}, multiBulk("c")},
+		{[]string{"ZINTER", "2", "z1", "missing"}, "*0\r\n"},
+		{[]string{"ZDIFF", "2", "z1", "z2", "WITHSCORES"}, multiBulk("a", "1")},
+		{[]string{"ZDIFF", "2", "z1", "missing"}, multiBulk("a", "b", "c")},
+		{[]string{"ZDIFF", "1", "missing"}, "*0\r\n"},
+		// 错误的参数
+		{[]string{"ZDIFF", "2", "z1", "z2", "WEIGHTS", "1", "1"}, "-Err syntax error\r\n"},
+		{[]string{"ZUNION", "2", "z1", "z2", "AGGREGATE", "AVG"}, "-Err syntax error\r\n"},
+		{[]string{"ZUNION", "2", "z1", "z2", "WEIGHTS", "1"}, "-Err syntax error\r\n"},
+		{[]string{"ZUNION", "2", "z1", "z2", "WEIGHTS", "1", "x"}, "-ERR weight value is not a float\r\n"},
+		{[]string{"ZUNION", "3", "z1", "z2"}, "-Err syntax error\r\n"},
+		{[]string{"ZUNION", "0", "z1"}, "-ERR at least 1 input key is needed for this command\r\n"},
+		{[]string{"ZUNION", "2", "z1", "str"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	}
+	for _, c := range cases {
+		if actual := exec(c.args...); actual != c.expected {
+			t.Errorf("%s: expect %q, actual %q", strings.Join(c.args, " "), c.expected, actual)
+		}
+	}
+
+	storeCases := []struct {
+		args     []string
+		expected string
+		// ZRANGE dest 0 -1 WITHSCORES after store
+		members string
+	}{
+		{[]string{"ZUNIONSTORE", "dest", "2", "z1", "z2", "WEIGHTS", "1", "2"}, ":4\r\n", multiBulk("a", "1", "b", "22", "c", "43", "d", "60")},
+		{[]string{"ZINTERSTORE", "dest", "2", "z1", "z2", "AGGREGATE", "MAX"}, ":2\r\n", multiBulk("b", "10", "c", "20")},
+		{[]string{"ZDIFFSTORE", "dest", "2", "z2", "z1"}, ":1\r\n", multiBulk("d", "30")},
+		// 结果为空时删除目标key
+		{[]string{"ZINTERSTORE", "dest", "2", "z1", "missing"}, ":0\r\n", "*0\r\n"},
+		{[]string{"ZUNIONSTORE", "dest", "1", "z1", "WITHSCORES"}, "-Err syntax error\r\n", "*0\r\n"},
+	}
+	for _, c := range storeCases {
+		if actual := exec(c.args...); actual != c.expected {
+			t.Errorf("%s: expect %q, actual %q", strings.Join(c.args, " "), c.expected, actual)
+		}
+		if actual := exec("ZRANGE", "dest", "0", "-1", "WITHSCORES"); actual != c.members {
+			t.Errorf("%s: expect dest %q, actual %q", strings.Join(c.args, " "), c.members, actual)
+		}
+	}
+	if actual := exec("EXISTS", "dest"); actual != ":0\r\n" {
+		t.Errorf("empty result should remove dest, actual %q", actual)
+	}
+}
+
+func multiBulk(args ...string) string {
+	return string(protocol.MakeMultiBulkReply(utils.ToCmdLine(args...)).ToBytes())
+}
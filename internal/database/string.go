@@ -0,0 +1,741 @@
+package database
+
+import (
+	"Godis/interface/database"
+	"Godis/interface/redis"
+	"Godis/internal/aof"
+	"Godis/internal/config"
+	"Godis/lib/clock"
+	"Godis/lib/compress"
+	"Godis/lib/logger"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (db *DB) getAsString(key string) ([]byte, protocol.ErrorReply) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return nil, nil
+	}
+	bytes, ok := database.StringBytes(entity.Data)
+	if !ok {
+		return nil, &protocol.WrongTypeErrReply{}
+	}
+	if entity.Compressed {
+		raw, err := compress.Decompress(bytes)
+		if err != nil {
+			logger.Warn("decompress value of " + key + " failed: " + err.Error())
+			return nil, protocol.MakeErrReply("ERR corrupted compressed value")
+		}
+		return raw, nil
+	}
+	return bytes, nil
+}
+
+// sharedIntegersCount 与 redis OBJ_SHARED_INTEGERS 一致
+const sharedIntegersCount = 10000
+
+// sharedIntegers 缓存 0 到 9999 的 int 编码的值，计数器、标志位等常见的值共享同一次分配，而不是每个 key 各保存一份
+var sharedIntegers = func() []interface{} {
+	values := make([]interface{}, sharedIntegersCount)
+	for i := range values {
+		values[i] = int64(i)
+	}
+	return values
+}()
+
+// getSharedInteger returns the shared data of value, returns false if value is not an integer in [0, 10000)
+// integers with leading zeros are not shared since they must be stored as they are
+func getSharedInteger(value []byte) (interface{}, bool) {
+	if len(value) == 0 || len(value) > 4 || (len(value) > 1 && value[0] == '0') {
+		return nil, false
+	}
+	n := 0
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return nil, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return sharedIntegers[n], true
+}
+
+// makeStringEntity wraps value into DataEntity, large value is compressed if value-compression-threshold is set
+// small integers use the shared value if shared-integers is enabled, other values use the compact encodings,
+// see database.MakeStringData
+func makeStringEntity(value []byte) *database.DataEntity {
+	if config.Properties.SharedIntegers {
+		if shared, ok := getSharedInteger(value); ok {
+			return &database.DataEntity{
+				Data: shared,
+				Type: database.TypeString,
+			}
+		}
+	}
+	data, compressed := compress.Compress(value, config.Properties.ValueCompressionThreshold)
+	if compressed {
+		return &database.DataEntity{
+			Data:       data,
+			Type:       database.TypeString,
+			Compressed: true,
+		}
+	}
+	return &database.DataEntity{
+		Data: database.MakeStringData(value),
+		Type: database.TypeString,
+	}
+}
+
+// maxStringLength 字符串的最大长度，与 redis proto-max-bulk-len 的默认值一致
+const maxStringLength = 512 * 1024 * 1024
+
+func makeStringTooLongErrReply() protocol.ErrorReply {
+	return protocol.MakeErrReply("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+}
+
+// 以下是 APPEND、SETRANGE、GETRANGE、SETBIT 等修改或读取字符串局部的命令共用的辅助函数
+// 与 redis 一致: 不存在的key视为空字符串，其他类型的key返回 WRONGTYPE，字符串长度不能超过 maxStringLength
+
+// updateString applies update to value of the string key and stores the result, keeping ttl of the key
+// missing key is treated as empty string and created after update, even if the result is empty
+// commands which must not create key for empty input, such as SETRANGE with empty value, should not call it
+// update receives a copy of the value, so it can modify value in place
+func (db *DB) updateString(key string, update func(value []byte) ([]byte, protocol.ErrorReply)) ([]byte, protocol.ErrorReply) {
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return nil, errReply
+	}
+	// 不能原地修改读取到的值，它可能与 AOF 中的命令行或尚未发送的回复共享内存
+	value = append([]byte{}, value...)
+	value, errReply = update(value)
+	if errReply != nil {
+		return nil, errReply
+	}
+	db.PutEntity(key, makeStringEntity(value))
+	return value, nil
+}
+
+// extendString pads value with zero bytes to size if it is shorter
+func extendString(value []byte, size int64) ([]byte, protocol.ErrorReply) {
+	if size > maxStringLength {
+		return nil, makeStringTooLongErrReply()
+	}
+	if int64(len(value)) >= size {
+		return value, nil
+	}
+	return append(value, make([]byte, int(size)-len(value))...), nil
+}
+
+// setRange overwrites value starting at offset with data, value is zero padded if it is shorter than offset
+func setRange(value []byte, offset int64, data []byte) ([]byte, protocol.ErrorReply) {
+	if offset < 0 {
+		return nil, protocol.MakeErrReply("ERR offset is out of range")
+	}
+	value, errReply := extendString(value, offset+int64(len(data)))
+	if errReply != nil {
+		return nil, errReply
+	}
+	copy(value[offset:], data)
+	return value, nil
+}
+
+// getRange returns substring of value within [start, end], negative index counts from the tail, same as GETRANGE
+func getRange(value []byte, start int64, end int64) []byte {
+	size := int64(len(value))
+	if start < 0 && end < 0 && start > end {
+		return []byte{}
+	}
+	if start < 0 {
+		start += size
+		if start < 0 {
+			start = 0
+		}
+	}
+	if end < 0 {
+		end += size
+		if end < 0 {
+			end = 0
+		}
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if start > end || size == 0 {
+		return []byte{}
+	}
+	return value[start : end+1]
+}
+
+// execGet returns string value bound to the given key
+func execGet(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	bytes, err := db.getAsString(key)
+	if err != nil {
+		return err
+	}
+	if bytes == nil {
+		return &protocol.NullBulkReply{}
+	}
+	return protocol.MakeBulkReply(bytes)
+}
+
+// setPolicy decides whether SET writes by existence of the key, which is NX or XX option
+type setPolicy uint8
+
+const (
+	setAlways setPolicy = iota
+	setIfAbsent
+	setIfExists
+)
+
+// setOptions are options of SET
+type setOptions struct {
+	policy setPolicy
+	// expireAt 是 EX、PX、EXAT 或 PXAT 指定的过期时间，零值表示没有指定
+	expireAt time.Time
+	keepTTL  bool
+	get      bool
+}
+
+func makeInvalidExpireErrReply(cmd string) protocol.ErrorReply {
+	return protocol.MakeErrReply("ERR invalid expire time in '" + cmd + "' command")
+}
+
+// parseExpireArg parses the argument of EX, PX, EXAT or PXAT into an absolute time
+// the time must be positive and not overflow in milliseconds, jitter is percent of random time added to EX or PX
+func parseExpireArg(cmd string, option string, arg []byte, jitter int) (time.Time, protocol.ErrorReply) {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return time.Time{}, protocol.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	unit := int64(1)
+	if option == "EX" || option == "EXAT" {
+		unit = 1000
+	}
+	if n <= 0 || n > math.MaxInt64/unit {
+		return time.Time{}, makeInvalidExpireErrReply(cmd)
+	}
+	ms := n * unit
+	if option == "EX" || option == "PX" {
+		ms = addTTLJitter(ms, jitter)
+		now := clock.Now().UnixNano() / 1e6
+		if ms > math.MaxInt64-now {
+			return time.Time{}, makeInvalidExpireErrReply(cmd)
+		}
+		ms += now
+	}
+	return time.Unix(ms/1000, ms%1000*int64(time.Millisecond)), nil
+}
+
+// parseSetOptions parses [NX | XX] [GET] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL] [JITTER percent]
+// 与 redis 一致，重复的选项以最后一个为准，互斥的选项返回语法错误；JITTER 只能与 EX 或 PX 一起使用
+func parseSetOptions(args [][]byte) (*setOptions, protocol.ErrorReply) {
+	opts := &setOptions{}
+	expireOption := ""
+	var expireArg []byte
+	jitter := jitterNotGiven
+	for i := 0; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		switch option {
+		case "NX", "XX":
+			policy := setIfAbsent
+			if option == "XX" {
+				policy = setIfExists
+			}
+			if opts.policy != setAlways && opts.policy != policy {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			opts.policy = policy
+		case "GET":
+			opts.get = true
+		case "KEEPTTL", "EX", "PX", "EXAT", "PXAT":
+			if expireOption != "" && expireOption != option {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			expireOption = option
+			if option == "KEEPTTL" {
+				opts.keepTTL = true
+				continue
+			}
+			if i+1 >= len(args) {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			expireArg = args[i+1]
+			i++
+		case "JITTER":
+			if i+1 >= len(args) {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			var errReply protocol.ErrorReply
+			if jitter, errReply = parseJitterArg(args[i+1]); errReply != nil {
+				return nil, errReply
+			}
+			i++
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+	if jitter != jitterNotGiven && expireOption != "EX" && expireOption != "PX" {
+		return nil, protocol.MakeSyntaxErrReply()
+	}
+	if expireArg != nil {
+		// 过期时间在所有选项解析完之后计算，JITTER 可以出现在 EX 或 PX 之后
+		expireAt, errReply := parseExpireArg("set", expireOption, expireArg, ttlJitter(jitter))
+		if errReply != nil {
+			return nil, errReply
+		}
+		opts.expireAt = expireAt
+	}
+	return opts, nil
+}
+
+// execSet sets string value: SET key value [NX | XX] [GET] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+// ttl of the key is removed unless KEEPTTL or an expiration is given, the expiration is set within the same write
+// returns nil if the value is not set because of NX or XX, with GET returns the old value instead
+func execSet(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	value := args[1]
+	opts, errReply := parseSetOptions(args[2:])
+	if errReply != nil {
+		return errReply
+	}
+	// 与 redis 一致，GET 要求旧值是字符串，否则不写入并返回 WRONGTYPE
+	var old []byte
+	exists := false
+	if opts.get {
+		old, errReply = db.getAsString(key)
+		if errReply != nil {
+			return errReply
+		}
+		exists = old != nil
+	} else if opts.policy != setAlways {
+		_, exists = db.GetEntity(key)
+	}
+	if (opts.policy == setIfAbsent && exists) || (opts.policy == setIfExists && !exists) {
+		if old != nil {
+			return protocol.MakeBulkReply(old)
+		}
+		return &protocol.NullBulkReply{}
+	}
+	db.PutEntity(key, makeStringEntity(value))
+	// AOF 中把相对的过期时间转换为绝对时间，重放时不会延长 ttl
+	cmdLine := utils.ToCmdLine3("set", args[0], value)
+	if !opts.expireAt.IsZero() {
+		db.Expire(key, opts.expireAt)
+		cmdLine = append(cmdLine, []byte("PXAT"), []byte(strconv.FormatInt(opts.expireAt.UnixNano()/1e6, 10)))
+	} else if opts.keepTTL {
+		cmdLine = append(cmdLine, []byte("KEEPTTL"))
+	} else {
+		db.Persist(key)
+	}
+	db.addAof(cmdLine)
+	if opts.get {
+		if old == nil {
+			return &protocol.NullBulkReply{}
+		}
+		return protocol.MakeBulkReply(old)
+	}
+	return &protocol.OkReply{}
+}
+
+// execSetNX sets string value only if the key does not exist, returns 1 if set: SETNX key value
+func execSetNX(db *DB, args [][]byte) redis.Reply {
+	reply := execSet(db, [][]byte{args[0], args[1], []byte("NX")})
+	if protocol.IsOKReply(reply) {
+		return protocol.MakeIntReply(1)
+	}
+	return protocol.MakeIntReply(0)
+}
+
+// execSetEX sets string value and ttl in seconds: SETEX key seconds value
+func execSetEX(db *DB, args [][]byte) redis.Reply {
+	return execSetWithExpire(db, "setex", "EX", args)
+}
+
+// execPSetEX sets string value and ttl in milliseconds: PSETEX key milliseconds value
+func execPSetEX(db *DB, args [][]byte) redis.Reply {
+	return execSetWithExpire(db, "psetex", "PX", args)
+}
+
+// execSetWithExpire executes SETEX and PSETEX as SET key value PXAT unix-time-milliseconds
+// 先转换为绝对时间，错误信息中使用原命令名，AOF 中同样记录为 SET
+func execSetWithExpire(db *DB, cmd string, option string, args [][]byte) redis.Reply {
+	expireAt, errReply := parseExpireArg(cmd, option, args[1], ttlJitter(jitterNotGiven))
+	if errReply != nil {
+		return errReply
+	}
+	ms := strconv.FormatInt(expireAt.UnixNano()/1e6, 10)
+	return execSet(db, [][]byte{args[0], args[2], []byte("PXAT"), []byte(ms)})
+}
+
+// execGetDel returns string value and removes the key: GETDEL key
+func execGetDel(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if value == nil {
+		return &protocol.NullBulkReply{}
+	}
+	db.Remove(key)
+	db.addAof(utils.ToCmdLine3("del", args[0]))
+	return protocol.MakeBulkReply(value)
+}
+
+// execGetEx returns string value and updates its ttl: GETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | PERSIST]
+// 与 redis 一致，过期时间已经过去时删除 key，AOF 中记录为 DEL；否则记录为 PEXPIREAT 或 PERSIST
+func execGetEx(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	var expireAt time.Time
+	expireOption := ""
+	for i := 1; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		switch option {
+		case "PERSIST", "EX", "PX", "EXAT", "PXAT":
+			if expireOption != "" && expireOption != option {
+				return protocol.MakeSyntaxErrReply()
+			}
+			expireOption = option
+			if option == "PERSIST" {
+				continue
+			}
+			if i+1 >= len(args) {
+				return protocol.MakeSyntaxErrReply()
+			}
+			var errReply protocol.ErrorReply
+			expireAt, errReply = parseExpireArg("getex", option, args[i+1], ttlJitter(jitterNotGiven))
+			if errReply != nil {
+				return errReply
+			}
+			i++
+		default:
+			return protocol.MakeSyntaxErrReply()
+		}
+	}
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if value == nil {
+		return &protocol.NullBulkReply{}
+	}
+	if expireOption == "PERSIST" {
+		if _, hasTTL := db.ttlMap.Get(key); hasTTL {
+			db.Persist(key)
+			db.addAof(utils.ToCmdLine3("persist", args[0]))
+		}
+	} else if !expireAt.IsZero() {
+		if clock.Now().After(expireAt) {
+			db.Remove(key)
+			db.addAof(utils.ToCmdLine3("del", args[0]))
+		} else {
+			db.Expire(key, expireAt)
+			db.addAof(aof.MakeExpireCmd(key, expireAt).Args)
+		}
+	}
+	return protocol.MakeBulkReply(value)
+}
+
+// prepareMSet returns all keys of MSET and MSETNX as write keys, values are skipped
+func prepareMSet(args [][]byte) ([]string, []string) {
+	keys := make([]string, len(args)/2)
+	for i := range keys {
+		keys[i] = string(args[2*i])
+	}
+	return keys, nil
+}
+
+func undoMSet(db *DB, args [][]byte) []CmdLine {
+	writeKeys, _ := prepareMSet(args)
+	return rollbackGivenKeys(db, writeKeys...)
+}
+
+// execMSet sets multiple string values, ttl of the keys are removed like SET: MSET key value [key value ...]
+func execMSet(db *DB, args [][]byte) redis.Reply {
+	if len(args)%2 != 0 {
+		return protocol.MakeArgNumErrReply("mset")
+	}
+	for i := 0; i < len(args); i += 2 {
+		key := string(args[i])
+		db.PutEntity(key, makeStringEntity(args[i+1]))
+		db.Persist(key)
+	}
+	db.addAof(utils.ToCmdLine3("mset", args...))
+	return &protocol.OkReply{}
+}
+
+// execMSetNX sets multiple string values only if none of the keys exists: MSETNX key value [key value ...]
+// 所有 key 的写锁在执行前一起获得，检查和写入之间不会有其他命令创建其中的 key
+func execMSetNX(db *DB, args [][]byte) redis.Reply {
+	if len(args)%2 != 0 {
+		return protocol.MakeArgNumErrReply("msetnx")
+	}
+	for i := 0; i < len(args); i += 2 {
+		if _, exists := db.GetEntity(string(args[i])); exists {
+			return protocol.MakeIntReply(0)
+		}
+	}
+	for i := 0; i < len(args); i += 2 {
+		key := string(args[i])
+		db.PutEntity(key, makeStringEntity(args[i+1]))
+		db.Persist(key)
+	}
+	db.addAof(utils.ToCmdLine3("msetnx", args...))
+	return protocol.MakeIntReply(1)
+}
+
+// execMGet returns values of multiple keys, nil for missing keys and keys which are not string: MGET key [key ...]
+func execMGet(db *DB, args [][]byte) redis.Reply {
+	result := make([][]byte, len(args))
+	for i, arg := range args {
+		value, errReply := db.getAsString(string(arg))
+		if errReply != nil {
+			continue
+		}
+		result[i] = value
+	}
+	return protocol.MakeMultiBulkReply(result)
+}
+
+var (
+	notIntegerErrReply = protocol.MakeErrReply("ERR value is not an integer or out of range")
+	notFloatErrReply   = protocol.MakeErrReply("ERR value is not a valid float")
+)
+
+// getAsInt returns value of the string key as integer, missing key is treated as 0
+func (db *DB) getAsInt(key string) (int64, protocol.ErrorReply) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return 0, nil
+	}
+	// 整数通常以 int64 保存，无需格式化后再解析
+	if !entity.Compressed {
+		if n, ok := database.StringInt(entity.Data); ok {
+			return n, nil
+		}
+	}
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return 0, errReply
+	}
+	n, ok := database.StringInt(value)
+	if !ok {
+		return 0, notIntegerErrReply
+	}
+	return n, nil
+}
+
+// incrBy adds delta to the integer value of key, keeping ttl of the key
+func (db *DB) incrBy(key string, delta int64) redis.Reply {
+	value, errReply := db.getAsInt(key)
+	if errReply != nil {
+		return errReply
+	}
+	if (delta > 0 && value > math.MaxInt64-delta) || (delta < 0 && value < math.MinInt64-delta) {
+		return protocol.MakeErrReply("ERR increment or decrement would overflow")
+	}
+	value += delta
+	db.PutEntity(key, makeStringEntity(strconv.AppendInt(nil, value, 10)))
+	return protocol.MakeIntReply(value)
+}
+
+// execIncr increments the integer value of key by one: INCR key
+func execIncr(db *DB, args [][]byte) redis.Reply {
+	reply := db.incrBy(string(args[0]), 1)
+	if !protocol.IsErrorReply(reply) {
+		db.addAof(utils.ToCmdLine3("incr", args...))
+	}
+	return reply
+}
+
+// execDecr decrements the integer value of key by one: DECR key
+func execDecr(db *DB, args [][]byte) redis.Reply {
+	reply := db.incrBy(string(args[0]), -1)
+	if !protocol.IsErrorReply(reply) {
+		db.addAof(utils.ToCmdLine3("decr", args...))
+	}
+	return reply
+}
+
+// execIncrBy increments the integer value of key by the given number: INCRBY key increment
+func execIncrBy(db *DB, args [][]byte) redis.Reply {
+	delta, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return notIntegerErrReply
+	}
+	reply := db.incrBy(string(args[0]), delta)
+	if !protocol.IsErrorReply(reply) {
+		db.addAof(utils.ToCmdLine3("incrby", args...))
+	}
+	return reply
+}
+
+// execDecrBy decrements the integer value of key by the given number: DECRBY key decrement
+func execDecrBy(db *DB, args [][]byte) redis.Reply {
+	delta, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return notIntegerErrReply
+	}
+	// -math.MinInt64 溢出
+	if delta == math.MinInt64 {
+		return protocol.MakeErrReply("ERR decrement would overflow")
+	}
+	reply := db.incrBy(string(args[0]), -delta)
+	if !protocol.IsErrorReply(reply) {
+		db.addAof(utils.ToCmdLine3("decrby", args...))
+	}
+	return reply
+}
+
+// parseStringFloat parses float value of string, NaN and infinity are not valid values
+func parseStringFloat(value []byte) (float64, bool) {
+	f, err := strconv.ParseFloat(string(value), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
+
+// execIncrByFloat increments the float value of key by the given number: INCRBYFLOAT key increment
+// 与 redis 一致，AOF 中记录为 SET key result KEEPTTL，避免重放时浮点运算在不同平台上得到不同的结果
+func execIncrByFloat(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	delta, ok := parseStringFloat(args[1])
+	if !ok {
+		return notFloatErrReply
+	}
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	current := float64(0)
+	if value != nil {
+		if current, ok = parseStringFloat(value); !ok {
+			return notFloatErrReply
+		}
+	}
+	result := current + delta
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return protocol.MakeErrReply("ERR increment would produce NaN or Infinity")
+	}
+	bytes := []byte(strconv.FormatFloat(result, 'f', -1, 64))
+	db.PutEntity(key, makeStringEntity(bytes))
+	db.addAof(utils.ToCmdLine3("set", args[0], bytes, []byte("KEEPTTL")))
+	return protocol.MakeBulkReply(bytes)
+}
+
+// execAppend appends value to the string key, missing key is created: APPEND key value
+func execAppend(db *DB, args [][]byte) redis.Reply {
+	value, errReply := db.updateString(string(args[0]), func(value []byte) ([]byte, protocol.ErrorReply) {
+		if int64(len(value))+int64(len(args[1])) > maxStringLength {
+			return nil, makeStringTooLongErrReply()
+		}
+		return append(value, args[1]...), nil
+	})
+	if errReply != nil {
+		return errReply
+	}
+	db.addAof(utils.ToCmdLine3("append", args...))
+	return protocol.MakeIntReply(int64(len(value)))
+}
+
+// execStrLen returns length of the string value, 0 for missing key: STRLEN key
+func execStrLen(db *DB, args [][]byte) redis.Reply {
+	value, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	return protocol.MakeIntReply(int64(len(value)))
+}
+
+// execGetRange returns substring of the string value: GETRANGE key start end
+func execGetRange(db *DB, args [][]byte) redis.Reply {
+	start, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return notIntegerErrReply
+	}
+	end, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return notIntegerErrReply
+	}
+	value, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	return protocol.MakeBulkReply(getRange(value, start, end))
+}
+
+// execSetRange overwrites part of the string value starting at offset: SETRANGE key offset value
+// 与 redis 一致，value 为空时不修改也不创建 key，只返回当前长度
+func execSetRange(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	offset, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return notIntegerErrReply
+	}
+	if offset < 0 {
+		return protocol.MakeErrReply("ERR offset is out of range")
+	}
+	data := args[2]
+	if len(data) == 0 {
+		value, errReply := db.getAsString(key)
+		if errReply != nil {
+			return errReply
+		}
+		return protocol.MakeIntReply(int64(len(value)))
+	}
+	value, errReply := db.updateString(key, func(value []byte) ([]byte, protocol.ErrorReply) {
+		return setRange(value, offset, data)
+	})
+	if errReply != nil {
+		return errReply
+	}
+	db.addAof(utils.ToCmdLine3("setrange", args...))
+	return protocol.MakeIntReply(int64(len(value)))
+}
+
+func init() {
+	registerCommand("Set", execSet, writeFirstKey, rollbackFirstKey, -3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, 1, 1)
+	registerCommand("Get", execGet, readFirstKey, nil, 2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("SetNX", execSetNX, writeFirstKey, rollbackFirstKey, 3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
+	registerCommand("SetEX", execSetEX, writeFirstKey, rollbackFirstKey, 4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, 1, 1)
+	registerCommand("PSetEX", execPSetEX, writeFirstKey, rollbackFirstKey, 4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, 1, 1)
+	registerCommand("MSet", execMSet, prepareMSet, undoMSet, -3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, -1, 2)
+	registerCommand("MSetNX", execMSetNX, prepareMSet, undoMSet, -3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, -1, 2)
+	registerCommand("MGet", execMGet, readAllKeys, nil, -2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, -1, 1)
+	registerCommand("GetDel", execGetDel, writeFirstKey, rollbackFirstKey, 2, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("GetEx", execGetEx, writeFirstKey, rollbackFirstKey, -2, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+	registerCommand("Incr", execIncr, writeFirstKey, rollbackFirstKey, 2, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
+	registerCommand("Decr", execDecr, writeFirstKey, rollbackFirstKey, 2, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
+	registerCommand("IncrBy", execIncrBy, writeFirstKey, rollbackFirstKey, 3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
+	registerCommand("DecrBy", execDecrBy, writeFirstKey, rollbackFirstKey, 3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
+	registerCommand("IncrByFloat", execIncrByFloat, writeFirstKey, rollbackFirstKey, 3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
+	registerCommand("Append", execAppend, writeFirstKey, rollbackFirstKey, 3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM, redisFlagFast}, 1, 1, 1)
+	registerCommand("StrLen", execStrLen, readFirstKey, nil, 2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("GetRange", execGetRange, readFirstKey, nil, 4, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly}, 1, 1, 1)
+	registerCommand("SetRange", execSetRange, writeFirstKey, rollbackFirstKey, 4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, 1, 1)
+}
@@ -0,0 +1,500 @@
+package database
+
+import (
+	HashSet "Godis/datastruct/set"
+	"Godis/interface/database"
+	"Godis/internal/config"
+	"Godis/internal/redis/connection"
+	"Godis/lib/clock"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetRange(t *testing.T) {
+	value := []byte("This is a string")
+	cases := []struct {
+		start, end int64
+		expected   string
+	}{
+		{0, 3, "This"},
+		{-3, -1, "ing"},
+		{0, -1, "This is a string"},
+		{10, 100, "string"},
+		{-100, 3, "This"},
+		{5, 3, ""},
+		{-1, -5, ""},
+		{100, 200, ""},
+		{-100, -50, "T"},
+	}
+	for _, c := range cases {
+		actual := string(getRange(value, c.start, c.end))
+		if actual != c.expected {
+			t.Errorf("getRange(%d, %d): expect %q, actual %q", c.start, c.end, c.expected, actual)
+		}
+	}
+	if actual := getRange(nil, 0, -1); len(actual) != 0 {
+		t.Errorf("expect empty string for missing key, actual %q", actual)
+	}
+}
+
+func TestSetRange(t *testing.T) {
+	cases := []struct {
+		value    string
+		offset   int64
+		data     string
+		expected string
+		err      bool
+	}{
+		{"Hello World", 6, "Redis", "Hello Redis", false},
+		{"", 6, "Redis", "\x00\x00\x00\x00\x00\x00Redis", false},
+		{"Hello", 0, "", "Hello", false},
+		{"Hello", 3, "p me", "Help me", false},
+		{"Hello", -1, "x", "", true},
+		{"", maxStringLength, "x", "", true},
+	}
+	for _, c := range cases {
+		actual, errReply := setRange([]byte(c.value), c.offset, []byte(c.data))
+		if c.err {
+			if errReply == nil {
+				t.Errorf("setRange(%q, %d, %q): expect error", c.value, c.offset, c.data)
+			}
+			continue
+		}
+		if errReply != nil {
+			t.Errorf("setRange(%q, %d, %q): %s", c.value, c.offset, c.data, errReply.Error())
+			continue
+		}
+		if string(actual) != c.expected {
+			t.Errorf("setRange(%q, %d, %q): expect %q, actual %q", c.value, c.offset, c.data, c.expected, actual)
+		}
+	}
+}
+
+func TestUpdateString(t *testing.T) {
+	db := makeDB()
+	appendFoo := func(value []byte) ([]byte, protocol.ErrorReply) {
+		return append(value, "foo"...), nil
+	}
+
+	// missing key is treated as empty string
+	value, errReply := db.updateString("missing", appendFoo)
+	if errReply != nil || string(value) != "foo" {
+		t.Errorf("expect foo, actual %q %v", value, errReply)
+	}
+	if stored, _ := db.getAsString("missing"); string(stored) != "foo" {
+		t.Errorf("expect foo stored, actual %q", stored)
+	}
+
+	// value read before must not be modified
+	original := []byte("bar")
+	db.PutEntity("str", &database.DataEntity{Data: original})
+	if _, errReply = db.updateString("str", appendFoo); errReply != nil {
+		t.Error(errReply.Error())
+	}
+	if string(original) != "bar" {
+		t.Errorf("original value is modified: %q", original)
+	}
+	if stored, _ := db.getAsString("str"); string(stored) != "barfoo" {
+		t.Errorf("expect barfoo, actual %q", stored)
+	}
+
+	// wrong type
+	db.PutEntity("set", &database.DataEntity{Data: HashSet.Make("a")})
+	if _, errReply = db.updateString("set", appendFoo); errReply == nil {
+		t.Error("expect wrong type error")
+	}
+
+	// error of update keeps the key unchanged
+	_, errReply = db.updateString("absent", func(value []byte) ([]byte, protocol.ErrorReply) {
+		return nil, makeStringTooLongErrReply()
+	})
+	if errReply == nil {
+		t.Error("expect error")
+	}
+	if _, exists := db.GetEntity("absent"); exists {
+		t.Error("key should not be created when update fails")
+	}
+}
+
+func TestSetOptions(t *testing.T) {
+	db := makeDB()
+	set := func(args ...string) string {
+		return string(execSet(db, utils.ToCmdLine(args...)).ToBytes())
+	}
+	ttl := func(key string) (time.Duration, bool) {
+		expireTime, ok := db.ttlMap.Get(key)
+		return expireTime.Sub(clock.Now()), ok
+	}
+
+	if reply := set("k", "1", "EX", "100", "NX"); reply != "+OK\r\n" {
+		t.Errorf("expect OK, actual %q", reply)
+	}
+	if d, ok := ttl("k"); !ok || d <= 99*time.Second || d > 100*time.Second {
+		t.Errorf("expect ttl about 100s, actual %v", d)
+	}
+	if reply := set("k", "2", "NX", "GET"); reply != "$1\r\n1\r\n" {
+		t.Errorf("NX with GET should return old value, actual %q", reply)
+	}
+	// KEEPTTL 保留过期时间，不带过期选项时清除过期时间
+	if reply := set("k", "3", "XX", "KEEPTTL"); reply != "+OK\r\n" {
+		t.Errorf("expect OK, actual %q", reply)
+	}
+	if _, ok := ttl("k"); !ok {
+		t.Error("KEEPTTL should keep ttl")
+	}
+	if reply := set("k", "4", "GET"); reply != "$1\r\n3\r\n" {
+		t.Errorf("expect old value 3, actual %q", reply)
+	}
+	if _, ok := ttl("k"); ok {
+		t.Error("SET without KEEPTTL should remove ttl")
+	}
+	if reply := set("missing", "1", "XX"); reply != "$-1\r\n" {
+		t.Errorf("expect nil, actual %q", reply)
+	}
+	if _, exists := db.GetEntity("missing"); exists {
+		t.Error("XX should not create key")
+	}
+	if reply := set("k", "5", "PXAT", "1"); reply != "+OK\r\n" {
+		t.Errorf("expect OK, actual %q", reply)
+	}
+	if _, exists := db.GetEntity("k"); exists {
+		t.Error("key with expire time in the past should be expired")
+	}
+
+	for _, args := range [][]string{
+		{"k", "v", "NX", "XX"},
+		{"k", "v", "EX", "1", "PX", "1"},
+		{"k", "v", "KEEPTTL", "EX", "1"},
+		{"k", "v", "EX"},
+		{"k", "v", "EX", "0"},
+		{"k", "v", "EX", "a"},
+		{"k", "v", "EX", "9223372036854775807"},
+		{"k", "v", "foo"},
+	} {
+		if reply := set(args...); reply[0] != '-' {
+			t.Errorf("%v: expect error, actual %q", args, reply)
+		}
+	}
+	db.PutEntity("set", &database.DataEntity{Data: HashSet.Make("a")})
+	if reply := set("set", "v", "GET"); reply[0] != '-' {
+		t.Errorf("expect wrong type error, actual %q", reply)
+	}
+}
+
+func TestIncr(t *testing.T) {
+	db := makeDB()
+	exec := func(fn ExecFunc, args ...string) string {
+		return string(fn(db, utils.ToCmdLine(args...)).ToBytes())
+	}
+	cases := []struct {
+		fn       ExecFunc
+		args     []string
+		expected string
+	}{
+		{execIncr, []string{"k"}, ":1\r\n"},
+		{execIncrBy, []string{"k", "41"}, ":42\r\n"},
+		{execDecr, []string{"k"}, ":41\r\n"},
+		{execDecrBy, []string{"k", "-9"}, ":50\r\n"},
+		{execIncrBy, []string{"k", "9223372036854775807"}, "-ERR increment or decrement would overflow\r\n"},
+		{execDecrBy, []string{"k", "-9223372036854775808"}, "-ERR decrement would overflow\r\n"},
+		{execIncrBy, []string{"k", "1.5"}, "-ERR value is not an integer or out of range\r\n"},
+		{execIncrByFloat, []string{"k", "0.5"}, "$4\r\n50.5\r\n"},
+		{execIncr, []string{"k"}, "-ERR value is not an integer or out of range\r\n"},
+		{execIncrByFloat, []string{"k", "nan"}, "-ERR value is not a valid float\r\n"},
+		{execIncrByFloat, []string{"f", "3.0e3"}, "$4\r\n3000\r\n"},
+	}
+	for _, c := range cases {
+		if actual := exec(c.fn, c.args...); actual != c.expected {
+			t.Errorf("%v: expect %q, actual %q", c.args, c.expected, actual)
+		}
+	}
+	// 只有规范形式的整数可以自增
+	for _, value := range []string{"01", "+1", " 1", "1 ", ""} {
+		db.PutEntity("s", makeStringEntity([]byte(value)))
+		if reply := exec(execIncr, "s"); reply[0] != '-' {
+			t.Errorf("%q: expect error, actual %q", value, reply)
+		}
+	}
+	// 自增保留过期时间
+	db.PutEntity("t", makeStringEntity([]byte("1")))
+	db.Expire("t", clock.Now().Add(time.Hour))
+	exec(execIncr, "t")
+	if _, ok := db.ttlMap.Get("t"); !ok {
+		t.Error("INCR should keep ttl")
+	}
+	db.PutEntity("set", &database.DataEntity{Data: HashSet.Make("a")})
+	if reply := exec(execIncr, "set"); !strings.HasPrefix(reply, "-WRONGTYPE") {
+		t.Errorf("expect wrong type error, actual %q", reply)
+	}
+}
+
+func TestSharedIntegers(t *testing.T) {
+	// 共享的值不需要再分配，只分配 DataEntity 本身
+	value := []byte("4242")
+	if allocs := testing.AllocsPerRun(100, func() { makeStringEntity(value) }); allocs != 1 {
+		t.Errorf("expect 1 allocation, actual %v", allocs)
+	}
+	for _, value := range []string{"10000", "042", "-1", "4a", ""} {
+		if _, ok := getSharedInteger([]byte(value)); ok {
+			t.Errorf("%q should not be shared", value)
+		}
+	}
+	if shared, ok := getSharedInteger([]byte("9999")); !ok || shared.(int64) != 9999 {
+		t.Errorf("expect 9999, actual %v", shared)
+	}
+
+	config.Properties.SharedIntegers = false
+	defer func() {
+		config.Properties.SharedIntegers = true
+	}()
+	if allocs := testing.AllocsPerRun(100, func() { makeStringEntity(value) }); allocs != 2 {
+		t.Errorf("expect 2 allocations without shared integers, actual %v", allocs)
+	}
+}
+
+func TestStringEncoding(t *testing.T) {
+	db := makeDB()
+	cases := []struct {
+		value    string
+		encoding string
+	}{
+		{"12345", "int"},
+		{"-9223372036854775808", "int"},
+		{"9223372036854775808", "embstr"},
+		{"007", "embstr"},
+		{"", "embstr"},
+		{strings.Repeat("a", 44), "embstr"},
+		{strings.Repeat("a", 45), "raw"},
+	}
+	for _, c := range cases {
+		db.PutEntity("str", makeStringEntity([]byte(c.value)))
+		entity, _ := db.GetEntity("str")
+		if entity.Encoding() != c.encoding {
+			t.Errorf("%q: expect %s, actual %s", c.value, c.encoding, entity.Encoding())
+		}
+		if value, _ := db.getAsString("str"); string(value) != c.value {
+			t.Errorf("expect %q, actual %q", c.value, value)
+		}
+	}
+	// 修改 int 和 embstr 编码的值后重新选择编码
+	db.PutEntity("str", makeStringEntity([]byte("12345")))
+	_, _ = db.updateString("str", func(value []byte) ([]byte, protocol.ErrorReply) {
+		return append(value, "abc"...), nil
+	})
+	if value, _ := db.getAsString("str"); string(value) != "12345abc" {
+		t.Errorf("expect 12345abc, actual %q", value)
+	}
+}
+
+func TestStringRangeCommands(t *testing.T) {
+	db := makeDB()
+	exec := func(fn ExecFunc, args ...string) string {
+		return string(fn(db, utils.ToCmdLine(args...)).ToBytes())
+	}
+	cases := []struct {
+		fn       ExecFunc
+		args     []string
+		expected string
+	}{
+		{execAppend, []string{"k", "Hello"}, ":5\r\n"},
+		{execAppend, []string{"k", " World"}, ":11\r\n"},
+		{execStrLen, []string{"k"}, ":11\r\n"},
+		{execStrLen, []string{"missing"}, ":0\r\n"},
+		{execGetRange, []string{"k", "0", "4"}, "$5\r\nHello\r\n"},
+		{execGetRange, []string{"k", "-5", "-1"}, "$5\r\nWorld\r\n"},
+		{execGetRange, []string{"k", "5", "1"}, "$0\r\n\r\n"},
+		{execGetRange, []string{"missing", "0", "-1"}, "$0\r\n\r\n"},
+		{execGetRange, []string{"k", "a", "1"}, "-ERR value is not an integer or out of range\r\n"},
+		{execSetRange, []string{"k", "6", "Redis"}, ":11\r\n"},
+		{execGetRange, []string{"k", "0", "-1"}, "$11\r\nHello Redis\r\n"},
+		// 超出当前长度时以零字节填充
+		{execSetRange, []string{"p", "3", "ab"}, ":5\r\n"},
+		{execGetRange, []string{"p", "0", "-1"}, "$5\r\n\x00\x00\x00ab\r\n"},
+		{execSetRange, []string{"k", "-1", "x"}, "-ERR offset is out of range\r\n"},
+		{execSetRange, []string{"k", "536870912", "x"}, "-ERR string exceeds maximum allowed size (proto-max-bulk-len)\r\n"},
+		// value 为空时不创建 key
+		{execSetRange, []string{"empty", "10", ""}, ":0\r\n"},
+		{execStrLen, []string{"empty"}, ":0\r\n"},
+		{execIncr, []string{"n"}, ":1\r\n"},
+		{execAppend, []string{"n", "0"}, ":2\r\n"},
+		{execIncr, []string{"n"}, ":11\r\n"},
+	}
+	for _, c := range cases {
+		if actual := exec(c.fn, c.args...); actual != c.expected {
+			t.Errorf("%v: expect %q, actual %q", c.args, c.expected, actual)
+		}
+	}
+	if _, exists := db.GetEntity("empty"); exists {
+		t.Error("SETRANGE with empty value should not create key")
+	}
+	db.PutEntity("set", &database.DataEntity{Data: HashSet.Make("a")})
+	for _, fn := range []ExecFunc{execStrLen, execAppend} {
+		if reply := exec(fn, "set", "a"); !strings.HasPrefix(reply, "-WRONGTYPE") {
+			t.Errorf("expect wrong type error, actual %q", reply)
+		}
+	}
+}
+
+func TestGetDelGetEx(t *testing.T) {
+	db := makeDB()
+	var aofLines []string
+	db.addAof = func(line CmdLine) {
+		aofLines = append(aofLines, strings.ToLower(string(line[0])))
+	}
+	exec := func(fn ExecFunc, args ...string) string {
+		return string(fn(db, utils.ToCmdLine(args...)).ToBytes())
+	}
+
+	db.PutEntity("k", makeStringEntity([]byte("v")))
+	if reply := exec(execGetDel, "k"); reply != "$1\r\nv\r\n" {
+		t.Errorf("expect v, actual %q", reply)
+	}
+	if _, exists := db.GetEntity("k"); exists {
+		t.Error("GETDEL should remove key")
+	}
+	if reply := exec(execGetDel, "k"); reply != "$-1\r\n" {
+		t.Errorf("expect nil, actual %q", reply)
+	}
+
+	db.PutEntity("k", makeStringEntity([]byte("v")))
+	if reply := exec(execGetEx, "k", "EX", "100"); reply != "$1\r\nv\r\n" {
+		t.Errorf("expect v, actual %q", reply)
+	}
+	if _, ok := db.ttlMap.Get("k"); !ok {
+		t.Error("GETEX EX should set ttl")
+	}
+	if reply := exec(execGetEx, "k", "PERSIST"); reply != "$1\r\nv\r\n" {
+		t.Errorf("expect v, actual %q", reply)
+	}
+	if _, ok := db.ttlMap.Get("k"); ok {
+		t.Error("GETEX PERSIST should remove ttl")
+	}
+	// 没有 ttl 时 PERSIST 不写 AOF
+	exec(execGetEx, "k", "PERSIST")
+	exec(execGetEx, "k")
+	if reply := exec(execGetEx, "k", "PXAT", "1"); reply != "$1\r\nv\r\n" {
+		t.Errorf("expect v, actual %q", reply)
+	}
+	if _, exists := db.GetEntity("k"); exists {
+		t.Error("GETEX with expire time in the past should remove key")
+	}
+	if reply := exec(execGetEx, "k", "EX", "100"); reply != "$-1\r\n" {
+		t.Errorf("expect nil, actual %q", reply)
+	}
+	expected := "del,pexpireat,persist,del"
+	if actual := strings.Join(aofLines, ","); actual != expected {
+		t.Errorf("expect aof %s, actual %s", expected, actual)
+	}
+
+	for _, args := range [][]string{
+		{"k", "EX", "1", "PERSIST"},
+		{"k", "EX"},
+		{"k", "EX", "0"},
+		{"k", "KEEPTTL"},
+	} {
+		if reply := exec(execGetEx, args...); reply[0] != '-' {
+			t.Errorf("%v: expect error, actual %q", args, reply)
+		}
+	}
+	db.PutEntity("set", &database.DataEntity{Data: HashSet.Make("a")})
+	for _, fn := range []ExecFunc{execGetDel, execGetEx} {
+		if reply := exec(fn, "set"); !strings.HasPrefix(reply, "-WRONGTYPE") {
+			t.Errorf("expect wrong type error, actual %q", reply)
+		}
+	}
+}
+
+func TestMSet(t *testing.T) {
+	server := NewStandaloneServer()
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(server.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+
+	exec("SET", "a", "0", "EX", "100")
+	if reply := exec("MSET", "a", "1", "b", "2", "a", "3"); reply != "+OK\r\n" {
+		t.Errorf("expect OK, actual %q", reply)
+	}
+	if reply := exec("TTL", "a"); reply != ":-1\r\n" {
+		t.Errorf("MSET should remove ttl, actual %q", reply)
+	}
+	exec("SADD", "set", "x")
+	if reply := exec("MGET", "a", "b", "missing", "set"); reply != "*4\r\n$1\r\n3\r\n$1\r\n2\r\n$-1\r\n$-1\r\n" {
+		t.Errorf("unexpected MGET reply %q", reply)
+	}
+	// 任何一个 key 已存在时都不写入
+	if reply := exec("MSETNX", "c", "1", "b", "1"); reply != ":0\r\n" {
+		t.Errorf("expect 0, actual %q", reply)
+	}
+	if reply := exec("EXISTS", "c"); reply != ":0\r\n" {
+		t.Errorf("MSETNX should not write any key, actual %q", reply)
+	}
+	if reply := exec("MSETNX", "c", "1", "d", "2"); reply != ":1\r\n" {
+		t.Errorf("expect 1, actual %q", reply)
+	}
+	if reply := exec("MGET", "c", "d"); reply != "*2\r\n$1\r\n1\r\n$1\r\n2\r\n" {
+		t.Errorf("unexpected MGET reply %q", reply)
+	}
+	for _, args := range [][]string{{"MSET", "a"}, {"MSET", "a", "1", "b"}, {"MSETNX", "a", "1", "b"}} {
+		if reply := exec(args...); !strings.HasPrefix(reply, "-ERR wrong number of arguments") {
+			t.Errorf("%v: expect arity error, actual %q", args, reply)
+		}
+	}
+
+	keys, _ := prepareMSet(utils.ToCmdLine("a", "1", "b", "2"))
+	if strings.Join(keys, ",") != "a,b" {
+		t.Errorf("expect keys a,b, actual %v", keys)
+	}
+}
+
+func TestLegacySet(t *testing.T) {
+	db := makeDB()
+	var aofLines []string
+	db.addAof = func(line CmdLine) {
+		aofLines = append(aofLines, string(bytes.Join(line[:3], []byte(" "))))
+	}
+	exec := func(fn ExecFunc, args ...string) string {
+		return string(fn(db, utils.ToCmdLine(args...)).ToBytes())
+	}
+
+	if reply := exec(execSetNX, "lock", "a"); reply != ":1\r\n" {
+		t.Errorf("expect 1, actual %q", reply)
+	}
+	if reply := exec(execSetNX, "lock", "b"); reply != ":0\r\n" {
+		t.Errorf("expect 0, actual %q", reply)
+	}
+	if reply := exec(execSetEX, "k", "100", "v"); reply != "+OK\r\n" {
+		t.Errorf("expect OK, actual %q", reply)
+	}
+	if d, _ := db.ttlMap.Get("k"); d.Sub(clock.Now()) <= 99*time.Second {
+		t.Errorf("expect ttl about 100s, actual %v", d.Sub(clock.Now()))
+	}
+	if reply := exec(execPSetEX, "p", "100000", "v"); reply != "+OK\r\n" {
+		t.Errorf("expect OK, actual %q", reply)
+	}
+	if d, _ := db.ttlMap.Get("p"); d.Sub(clock.Now()) <= 99*time.Second {
+		t.Errorf("expect ttl about 100s, actual %v", d.Sub(clock.Now()))
+	}
+	// AOF 中都记录为 SET
+	expected := "set lock a,set k v,set p v"
+	if actual := strings.Join(aofLines, ","); actual != expected {
+		t.Errorf("expect aof %s, actual %s", expected, actual)
+	}
+	for _, c := range []struct {
+		fn       ExecFunc
+		args     []string
+		expected string
+	}{
+		{execSetEX, []string{"k", "0", "v"}, "-ERR invalid expire time in 'setex' command\r\n"},
+		{execPSetEX, []string{"k", "-1", "v"}, "-ERR invalid expire time in 'psetex' command\r\n"},
+		{execSetEX, []string{"k", "a", "v"}, "-ERR value is not an integer or out of range\r\n"},
+	} {
+		if actual := exec(c.fn, c.args...); actual != c.expected {
+			t.Errorf("%v: expect %q, actual %q", c.args, c.expected, actual)
+		}
+	}
+}
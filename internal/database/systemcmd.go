@@ -1,14 +1,17 @@
 package database
 
 import (
-	"Godis/config"
 	"Godis/interface/redis"
+	"Godis/internal/config"
+	"Godis/internal/tcp"
+	"Godis/lib/clock"
+	"Godis/lib/compress"
 	"Godis/redis/protocol"
-	"Godis/tcp"
 	"fmt"
 	"os"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,24 +29,33 @@ func Ping(c redis.Connection, args [][]byte) redis.Reply {
 // Info the information of the godis server returned by the INFO command
 func Info(db *Server, args [][]byte) redis.Reply {
 	if len(args) == 0 {
-		infoCommandList := [...]string{"server", "client", "cluster", "keyspace"}
+		infoCommandList := [...]string{"server", "client", "cluster", "persistence", "compression", "overload", "keyspace"}
 		var allSection []byte
 		for _, s := range infoCommandList {
 			allSection = append(allSection, GenGodisInfoString(s, db)...)
 		}
-		return protocol.MakeBulkReply(allSection)
+		return protocol.MakeVerbatimStringReply(allSection)
 	} else if len(args) == 1 {
 		section := strings.ToLower(string(args[0]))
 		switch section {
 		case "server":
 			reply := GenGodisInfoString("server", db)
-			return protocol.MakeBulkReply(reply)
+			return protocol.MakeVerbatimStringReply(reply)
 		case "client":
-			return protocol.MakeBulkReply(GenGodisInfoString("client", db))
+			return protocol.MakeVerbatimStringReply(GenGodisInfoString("client", db))
 		case "cluster":
-			return protocol.MakeBulkReply(GenGodisInfoString("cluster", db))
+			return protocol.MakeVerbatimStringReply(GenGodisInfoString("cluster", db))
+		case "persistence":
+			return protocol.MakeVerbatimStringReply(GenGodisInfoString("persistence", db))
+		case "compression":
+			return protocol.MakeVerbatimStringReply(GenGodisInfoString("compression", db))
+		case "overload":
+			return protocol.MakeVerbatimStringReply(GenGodisInfoString("overload", db))
 		case "keyspace":
-			return protocol.MakeBulkReply(GenGodisInfoString("keyspace", db))
+			return protocol.MakeVerbatimStringReply(GenGodisInfoString("keyspace", db))
+		case "commandstats":
+			// 与 redis 一致，不带参数的 INFO 不包含 commandstats
+			return protocol.MakeVerbatimStringReply(GenGodisInfoString("commandstats", db))
 		default:
 			return protocol.MakeErrReply("Invalid section for 'info' command")
 		}
@@ -56,22 +68,28 @@ func Auth(c redis.Connection, args [][]byte) redis.Reply {
 	if len(args) != 1 {
 		return protocol.MakeErrReply("ERR wrong number of arguments for 'auth' command")
 	}
-	if config.Properties.RequirePass == "" {
+	if config.Properties.RequirePass == "" && config.Properties.AdminPass == "" {
 		return protocol.MakeErrReply("ERR Client sent AUTH, but no password is set")
 	}
 	passwd := string(args[0])
 	c.SetPassword(passwd)
-	if config.Properties.RequirePass != passwd {
+	if config.Properties.RequirePass != passwd && !isAdmin(c) {
 		return protocol.MakeErrReply("ERR invalid password")
 	}
 	return &protocol.OkReply{}
 }
 
-func isAuthenticated(c redis.Connection) bool {
+// IsAuthenticated returns true if requirepass is not set or the connection authenticated with requirepass or adminpass
+func IsAuthenticated(c redis.Connection) bool {
 	if config.Properties.RequirePass == "" {
 		return true
 	}
-	return c.GetPassword() == config.Properties.RequirePass
+	return c.GetPassword() == config.Properties.RequirePass || isAdmin(c)
+}
+
+// isAdmin returns true if the connection authenticated with adminpass
+func isAdmin(c redis.Connection) bool {
+	return config.Properties.AdminPass != "" && c != nil && c.GetPassword() == config.Properties.AdminPass
 }
 
 func GenGodisInfoString(section string, db *Server) []byte {
@@ -120,11 +138,16 @@ func GenGodisInfoString(section string, db *Server) []byte {
 			//"client_recent_max_input_buffer:%d\r\n"+
 			//"client_recent_max_output_buffer:%d\r\n"+
 			//"blocked_clients:%d\n",
-			tcp.ClientCounter,
+			atomic.LoadInt32(&tcp.ClientCounter),
 			//TODO,
 			//TODO,
 			//TODO,
 		)
+		// 每个 listener 的连接统计
+		for i, stats := range tcp.GetListenerStats() {
+			s += fmt.Sprintf("listener%d:addr=%s,tls=%t,connected=%d,accepted=%d\r\n",
+				i, stats.Address, stats.TLS, stats.Active, stats.Accepted)
+		}
 		return []byte(s)
 	case "cluster":
 		if getGodisRunningMode() == config.ClusterMode {
@@ -140,6 +163,52 @@ func GenGodisInfoString(section string, db *Server) []byte {
 			)
 			return []byte(s)
 		}
+	case "persistence":
+		s := "# Persistence\r\n"
+		if db.persister == nil {
+			return []byte(s + "aof_enabled:0\r\n")
+		}
+		stats := db.persister.Stats()
+		s += fmt.Sprintf("aof_enabled:1\r\n"+
+			"aof_rewrite_in_progress:%d\r\n"+
+			"aof_last_bgrewrite_status:%s\r\n"+
+			"aof_last_write_status:%s\r\n"+
+			"aof_consecutive_write_errors:%d\r\n"+
+			"aof_buffer_length:%d\r\n"+
+			"aof_last_fsync_duration_us:%d\r\n"+
+			"aof_delayed_fsync:%d\r\n",
+			boolToInt(stats.RewriteInProgress),
+			okOrErr(stats.LastRewriteOK),
+			okOrErr(stats.LastWriteOK),
+			stats.WriteErrors,
+			stats.PendingBytes,
+			stats.LastFsyncDuration.Microseconds(),
+			stats.DelayedFsync,
+		)
+		return []byte(s)
+	case "overload":
+		return []byte(db.genOverloadInfo())
+	case "commandstats":
+		return []byte(db.cmdStats.info())
+	case "compression":
+		stats := compress.GetStats()
+		s := fmt.Sprintf("# Compression\r\n"+
+			"value_compression_threshold:%d\r\n"+
+			"compressed_values:%d\r\n"+
+			"compression_skipped_values:%d\r\n"+
+			"compression_raw_bytes:%d\r\n"+
+			"compression_compressed_bytes:%d\r\n"+
+			"compression_ratio:%.4f\r\n"+
+			"decompressed_reads:%d\r\n",
+			config.Properties.ValueCompressionThreshold,
+			stats.Compressed,
+			stats.Skipped,
+			stats.RawBytes,
+			stats.CompressedBytes,
+			stats.Ratio(),
+			stats.Decompressed,
+		)
+		return []byte(s)
 	case "keyspace":
 		dbCount := config.Properties.Databases
 		var serv []byte
@@ -168,7 +237,7 @@ func getGodisRunningMode() string {
 
 // getGodisRuninngTime return the running time of godis
 func getGodisRuninngTime() time.Duration {
-	return time.Since(config.EachTimeServerInfo.StartUpTime) / time.Second
+	return clock.Since(config.EachTimeServerInfo.StartUpTime) / time.Second
 }
 
 func getDbSize(dbIndex, keys, expiresKeys int, ttl int64) []byte {
@@ -176,3 +245,18 @@ func getDbSize(dbIndex, keys, expiresKeys int, ttl int64) []byte {
 		dbIndex, keys, expiresKeys, ttl)
 	return []byte(s)
 }
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// okOrErr formats status fields of persistence section
+func okOrErr(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "err"
+}
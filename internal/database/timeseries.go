@@ -0,0 +1,467 @@
+package database
+
+import (
+	"Godis/datastruct/timeseries"
+	"Godis/interface/database"
+	"Godis/interface/redis"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var tsKeyNotExistsReply = protocol.MakeErrReply("ERR TSDB: the key does not exist")
+
+func (db *DB) getAsTimeSeries(key string) (*timeseries.Series, protocol.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	series, ok := entity.Data.(*timeseries.Series)
+	if !ok {
+		return nil, &protocol.WrongTypeErrReply{}
+	}
+	return series, nil
+}
+
+// tsOptions are options shared by TS.CREATE and TS.ADD
+type tsOptions struct {
+	retention int64
+	policy    *timeseries.DuplicatePolicy
+	labels    []timeseries.Label
+}
+
+// parseTSOptions parses RETENTION, LABELS and the duplicate policy option, which is DUPLICATE_POLICY in TS.CREATE and ON_DUPLICATE in TS.ADD
+// LABELS consumes all remaining arguments as label value pairs
+func parseTSOptions(args [][]byte, policyOption string) (*tsOptions, protocol.ErrorReply) {
+	opts := &tsOptions{}
+	for i := 0; i < len(args); i += 2 {
+		if strings.ToUpper(string(args[i])) == "LABELS" {
+			rest := args[i+1:]
+			if len(rest)%2 != 0 {
+				return nil, protocol.MakeErrReply("ERR TSDB: wrong number of label value pairs")
+			}
+			for j := 0; j < len(rest); j += 2 {
+				opts.labels = append(opts.labels, timeseries.Label{Name: string(rest[j]), Value: string(rest[j+1])})
+			}
+			break
+		}
+		if i+1 >= len(args) {
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "RETENTION":
+			retention, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil || retention < 0 {
+				return nil, protocol.MakeErrReply("ERR TSDB: invalid RETENTION value")
+			}
+			opts.retention = retention
+		case policyOption:
+			policy, ok := timeseries.ParseDuplicatePolicy(string(args[i+1]))
+			if !ok {
+				return nil, protocol.MakeErrReply("ERR TSDB: Unknown DUPLICATE_POLICY")
+			}
+			opts.policy = &policy
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+	return opts, nil
+}
+
+func (opts *tsOptions) makeSeries() *timeseries.Series {
+	policy := timeseries.PolicyBlock
+	if opts.policy != nil {
+		policy = *opts.policy
+	}
+	series := timeseries.New(opts.retention, policy)
+	series.SetLabels(opts.labels)
+	return series
+}
+
+// execTSCreate creates an empty series: TS.CREATE key [RETENTION retentionPeriod] [DUPLICATE_POLICY policy] [LABELS label value ...]
+func execTSCreate(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	opts, errReply := parseTSOptions(args[1:], "DUPLICATE_POLICY")
+	if errReply != nil {
+		return errReply
+	}
+	if _, exists := db.GetEntity(key); exists {
+		return protocol.MakeErrReply("ERR TSDB: key already exists")
+	}
+	db.PutEntity(key, &database.DataEntity{
+		Data: opts.makeSeries(),
+		Type: database.TypeTimeSeries,
+	})
+	db.addAof(utils.ToCmdLine3("ts.create", args...))
+	return protocol.MakeOkReply()
+}
+
+// parseTimestamp parses milliseconds timestamp, "*" means current time
+func parseTimestamp(arg []byte) (int64, bool) {
+	if string(arg) == "*" {
+		return time.Now().UnixNano() / 1e6, true
+	}
+	timestamp, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil || timestamp < 0 {
+		return 0, false
+	}
+	return timestamp, true
+}
+
+// execTSAdd adds a sample: TS.ADD key timestamp value [RETENTION retentionPeriod] [ON_DUPLICATE policy] [LABELS label value ...]
+// the series is created with options if key not exists, RETENTION and LABELS are ignored for existing series
+func execTSAdd(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	timestamp, ok := parseTimestamp(args[1])
+	if !ok {
+		return protocol.MakeErrReply("ERR TSDB: invalid timestamp")
+	}
+	value, err := strconv.ParseFloat(string(args[2]), 64)
+	if err != nil || math.IsNaN(value) {
+		return protocol.MakeErrReply("ERR TSDB: invalid value")
+	}
+	opts, errReply := parseTSOptions(args[3:], "ON_DUPLICATE")
+	if errReply != nil {
+		return errReply
+	}
+	series, errReply := db.getAsTimeSeries(key)
+	if errReply != nil {
+		return errReply
+	}
+	if series == nil {
+		series = opts.makeSeries()
+		db.PutEntity(key, &database.DataEntity{
+			Data: series,
+			Type: database.TypeTimeSeries,
+		})
+	}
+	if err := series.Add(timestamp, value, opts.policy); err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	// 重放时使用实际的时间戳代替 *，压缩规则写入目标序列的样本由重放的 TS.ADD 重新生成
+	cmdLine := utils.ToCmdLine3("ts.add", args...)
+	cmdLine[2] = []byte(strconv.FormatInt(timestamp, 10))
+	db.addAof(cmdLine)
+	return protocol.MakeIntReply(timestamp)
+}
+
+func makeSampleReply(sample timeseries.Sample) redis.Reply {
+	return protocol.MakeMultiRawReply([]redis.Reply{
+		protocol.MakeIntReply(sample.Timestamp),
+		protocol.MakeBulkReply([]byte(strconv.FormatFloat(sample.Value, 'f', -1, 64))),
+	})
+}
+
+// execTSGet returns the latest sample
+func execTSGet(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	series, errReply := db.getAsTimeSeries(key)
+	if errReply != nil {
+		return errReply
+	}
+	if series == nil {
+		return tsKeyNotExistsReply
+	}
+	sample, ok := series.Last()
+	if !ok {
+		return protocol.MakeEmptyMultiBulkReply()
+	}
+	return makeSampleReply(sample)
+}
+
+// parseRangeTimestamp parses range bound, "-" and "+" are the earliest and latest timestamp
+func parseRangeTimestamp(arg []byte) (int64, bool) {
+	switch string(arg) {
+	case "-":
+		return 0, true
+	case "+":
+		return math.MaxInt64, true
+	}
+	return parseTimestamp(arg)
+}
+
+// parseAggregation parses "AGGREGATION aggregator bucketDuration"
+func parseAggregation(args [][]byte) (timeseries.Aggregation, int64, protocol.ErrorReply) {
+	agg, ok := timeseries.ParseAggregation(string(args[0]))
+	if !ok {
+		return 0, 0, protocol.MakeErrReply("ERR TSDB: Unknown aggregation type")
+	}
+	bucketDuration, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil || bucketDuration <= 0 {
+		return 0, 0, protocol.MakeErrReply("ERR TSDB: bucketDuration must be greater than zero")
+	}
+	return agg, bucketDuration, nil
+}
+
+// tsRangeOptions are arguments of TS.RANGE and TS.MRANGE
+type tsRangeOptions struct {
+	from           int64
+	to             int64
+	count          int
+	agg            *timeseries.Aggregation
+	bucketDuration int64
+	// withLabels 和 filters 只用于 TS.MRANGE
+	withLabels bool
+	filters    []*timeseries.Filter
+}
+
+// parseTSRangeOptions parses "fromTimestamp toTimestamp [COUNT count] [AGGREGATION aggregator bucketDuration]"
+// multi allows WITHLABELS and FILTER of TS.MRANGE, FILTER consumes all remaining arguments
+func parseTSRangeOptions(args [][]byte, multi bool) (*tsRangeOptions, protocol.ErrorReply) {
+	opts := &tsRangeOptions{}
+	var ok bool
+	opts.from, ok = parseRangeTimestamp(args[0])
+	if !ok {
+		return nil, protocol.MakeErrReply("ERR TSDB: wrong fromTimestamp")
+	}
+	opts.to, ok = parseRangeTimestamp(args[1])
+	if !ok {
+		return nil, protocol.MakeErrReply("ERR TSDB: wrong toTimestamp")
+	}
+	for i := 2; i < len(args); {
+		switch option := strings.ToUpper(string(args[i])); {
+		case option == "COUNT":
+			if i+1 >= len(args) {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			n, err := strconv.Atoi(string(args[i+1]))
+			if err != nil || n <= 0 {
+				return nil, protocol.MakeErrReply("ERR TSDB: Couldn't parse COUNT")
+			}
+			opts.count = n
+			i += 2
+		case option == "AGGREGATION":
+			if i+2 >= len(args) {
+				return nil, protocol.MakeSyntaxErrReply()
+			}
+			agg, duration, errReply := parseAggregation(args[i+1 : i+3])
+			if errReply != nil {
+				return nil, errReply
+			}
+			opts.agg, opts.bucketDuration = &agg, duration
+			i += 3
+		case multi && option == "WITHLABELS":
+			opts.withLabels = true
+			i++
+		case multi && option == "FILTER":
+			for _, arg := range args[i+1:] {
+				filter, err := timeseries.ParseFilter(string(arg))
+				if err != nil {
+					return nil, protocol.MakeErrReply(err.Error())
+				}
+				opts.filters = append(opts.filters, filter)
+			}
+			i = len(args)
+		default:
+			return nil, protocol.MakeSyntaxErrReply()
+		}
+	}
+	if multi {
+		// 与 RedisTimeSeries 一致，至少需要一个 label=value 形式的过滤条件，避免选中所有序列
+		hasMatcher := false
+		for _, filter := range opts.filters {
+			hasMatcher = hasMatcher || filter.IsMatcher()
+		}
+		if !hasMatcher {
+			return nil, protocol.MakeErrReply("ERR TSDB: please provide at least one matcher")
+		}
+	}
+	return opts, nil
+}
+
+func makeSamplesReply(samples []timeseries.Sample) redis.Reply {
+	replies := make([]redis.Reply, len(samples))
+	for i, sample := range samples {
+		replies[i] = makeSampleReply(sample)
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+// execTSRange returns samples in range: TS.RANGE key fromTimestamp toTimestamp [COUNT count] [AGGREGATION aggregator bucketDuration]
+func execTSRange(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	opts, errReply := parseTSRangeOptions(args[1:], false)
+	if errReply != nil {
+		return errReply
+	}
+	series, errReply := db.getAsTimeSeries(key)
+	if errReply != nil {
+		return errReply
+	}
+	if series == nil {
+		return tsKeyNotExistsReply
+	}
+	return makeSamplesReply(series.Range(opts.from, opts.to, opts.count, opts.agg, opts.bucketDuration))
+}
+
+// execTSMRange returns samples in range of all series matching filters:
+// TS.MRANGE fromTimestamp toTimestamp [WITHLABELS] [COUNT count] [AGGREGATION aggregator bucketDuration] FILTER filter...
+// each element of reply is [key, labels, samples], labels is empty without WITHLABELS
+// 与 KEYS 一样遍历整个数据库，序列按键名排序
+func execTSMRange(db *DB, args [][]byte) redis.Reply {
+	opts, errReply := parseTSRangeOptions(args, true)
+	if errReply != nil {
+		return errReply
+	}
+	matched := make(map[string]*timeseries.Series)
+	keys := make([]string, 0)
+	db.data.ForEach(func(key string, entity *database.DataEntity) bool {
+		series, ok := entity.Data.(*timeseries.Series)
+		if !ok || !series.Match(opts.filters) || db.IsExpired(key) {
+			return true
+		}
+		matched[key] = series
+		keys = append(keys, key)
+		return true
+	})
+	sort.Strings(keys)
+	replies := make([]redis.Reply, len(keys))
+	for i, key := range keys {
+		series := matched[key]
+		labelReplies := make([]redis.Reply, 0)
+		if opts.withLabels {
+			for _, label := range series.Labels() {
+				labelReplies = append(labelReplies, protocol.MakeMultiBulkReply([][]byte{[]byte(label.Name), []byte(label.Value)}))
+			}
+		}
+		replies[i] = protocol.MakeMultiRawReply([]redis.Reply{
+			protocol.MakeBulkReply([]byte(key)),
+			protocol.MakeMultiRawReply(labelReplies),
+			makeSamplesReply(series.Range(opts.from, opts.to, opts.count, opts.agg, opts.bucketDuration)),
+		})
+	}
+	return protocol.MakeMultiRawReply(replies)
+}
+
+func prepareTSRule(args [][]byte) ([]string, []string) {
+	return []string{string(args[0]), string(args[1])}, nil
+}
+
+func undoTSRule(db *DB, args [][]byte) []CmdLine {
+	return rollbackGivenKeys(db, string(args[0]), string(args[1]))
+}
+
+// getTSRuleKeys returns source and destination series of TS.CREATERULE and TS.DELETERULE
+func (db *DB) getTSRuleKeys(args [][]byte) (*timeseries.Series, *timeseries.Series, protocol.ErrorReply) {
+	if string(args[0]) == string(args[1]) {
+		return nil, nil, protocol.MakeErrReply("ERR TSDB: the source key and destination key should be different")
+	}
+	var pair [2]*timeseries.Series
+	for i := range pair {
+		series, errReply := db.getAsTimeSeries(string(args[i]))
+		if errReply != nil {
+			return nil, nil, errReply
+		}
+		if series == nil {
+			return nil, nil, tsKeyNotExistsReply
+		}
+		pair[i] = series
+	}
+	return pair[0], pair[1], nil
+}
+
+// execTSCreateRule creates a compaction rule: TS.CREATERULE sourceKey destKey AGGREGATION aggregator bucketDuration
+// 规则直接引用目标序列，删除或覆盖目标键后需要用 TS.DELETERULE 删除规则
+func execTSCreateRule(db *DB, args [][]byte) redis.Reply {
+	if strings.ToUpper(string(args[2])) != "AGGREGATION" {
+		return protocol.MakeSyntaxErrReply()
+	}
+	agg, bucketDuration, errReply := parseAggregation(args[3:5])
+	if errReply != nil {
+		return errReply
+	}
+	src, dest, errReply := db.getTSRuleKeys(args)
+	if errReply != nil {
+		return errReply
+	}
+	if err := src.CreateRule(string(args[1]), dest, agg, bucketDuration); err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	db.addAof(utils.ToCmdLine3("ts.createrule", args...))
+	return protocol.MakeOkReply()
+}
+
+// execTSDeleteRule removes a compaction rule: TS.DELETERULE sourceKey destKey
+func execTSDeleteRule(db *DB, args [][]byte) redis.Reply {
+	src, _, errReply := db.getTSRuleKeys(args)
+	if errReply != nil {
+		return errReply
+	}
+	if err := src.DeleteRule(string(args[1])); err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	db.addAof(utils.ToCmdLine3("ts.deleterule", args...))
+	return protocol.MakeOkReply()
+}
+
+// prepareTSLoadChunk locks destination keys of rules in the chunk as well
+func prepareTSLoadChunk(args [][]byte) ([]string, []string) {
+	keys := []string{string(args[0])}
+	if series, err := timeseries.Parse(args[2]); err == nil {
+		for _, rule := range series.Rules() {
+			keys = append(keys, rule.DestKey)
+		}
+	}
+	return keys, nil
+}
+
+// execTSLoadChunk restores a series: TS.LOADCHUNK key iterator data
+// 加载顺序是任意的，目标键还不存在时先创建空的序列，之后加载目标键时原地替换其内容，保证规则引用的序列不变
+func execTSLoadChunk(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	if _, err := strconv.ParseInt(string(args[1]), 10, 64); err != nil {
+		return protocol.MakeErrReply("ERR invalid iterator")
+	}
+	series, err := timeseries.Parse(args[2])
+	if err != nil {
+		return protocol.MakeErrReply(err.Error())
+	}
+	for _, rule := range series.Rules() {
+		dest, errReply := db.getAsTimeSeries(rule.DestKey)
+		if errReply != nil || rule.DestKey == key {
+			_ = series.DeleteRule(rule.DestKey)
+			continue
+		}
+		if dest == nil {
+			dest = timeseries.New(0, timeseries.PolicyBlock)
+			db.PutEntity(rule.DestKey, &database.DataEntity{
+				Data: dest,
+				Type: database.TypeTimeSeries,
+			})
+		}
+		series.Link(rule.DestKey, dest)
+	}
+	if existed, errReply := db.getAsTimeSeries(key); errReply == nil && existed != nil {
+		existed.Replace(series)
+	} else {
+		db.PutEntity(key, &database.DataEntity{
+			Data: series,
+			Type: database.TypeTimeSeries,
+		})
+	}
+	db.addAof(utils.ToCmdLine3("ts.loadchunk", args...))
+	return protocol.MakeOkReply()
+}
+
+func init() {
+	registerCommand("TS.Create", execTSCreate, writeFirstKey, rollbackFirstKey, -2, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, 1, 1)
+	registerCommand("TS.Add", execTSAdd, writeFirstKey, rollbackFirstKey, -4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, 1, 1)
+	registerCommand("TS.Get", execTSGet, readFirstKey, nil, 2, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly, redisFlagFast}, 1, 1, 1)
+	registerCommand("TS.Range", execTSRange, readFirstKey, nil, -4, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly}, 1, 1, 1)
+	registerCommand("TS.MRange", execTSMRange, noPrepare, nil, -5, flagReadOnly).
+		attachCommandExtra([]string{redisFlagReadonly}, 0, 0, 0)
+	registerCommand("TS.CreateRule", execTSCreateRule, prepareTSRule, undoTSRule, 6, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite}, 1, 2, 1)
+	registerCommand("TS.DeleteRule", execTSDeleteRule, prepareTSRule, undoTSRule, 3, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite}, 1, 2, 1)
+	registerCommand("TS.LoadChunk", execTSLoadChunk, prepareTSLoadChunk, rollbackFirstKey, 4, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagDenyOOM}, 1, 1, 1)
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"Godis/internal/redis/connection"
+	"Godis/lib/utils"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWatch(t *testing.T) {
+	server := NewStandaloneServer()
+	conn := connection.NewFakeConn()
+	other := connection.NewFakeConn()
+	exec := func(c *connection.FakeConn, args ...string) string {
+		return string(server.Exec(c, utils.ToCmdLine(args...)).ToBytes())
+	}
+
+	// 其他连接修改了被监视的key，EXEC 失败
+	exec(conn, "WATCH", "k")
+	exec(other, "SET", "k", "v")
+	exec(conn, "MULTI")
+	exec(conn, "SET", "k", "v2")
+	if reply := exec(conn, "EXEC"); reply != "*0\r\n" {
+		t.Errorf("exec should fail after watched key changed, actual %q", reply)
+	}
+	if reply := exec(conn, "GET", "k"); reply != "$1\r\nv\r\n" {
+		t.Errorf("expect v, actual %q", reply)
+	}
+
+	// 执行失败的命令和只读命令不修改版本
+	exec(conn, "WATCH", "k")
+	exec(other, "INCR", "k")
+	exec(other, "GET", "k")
+	exec(conn, "MULTI")
+	exec(conn, "SET", "k", "v2")
+	if reply := exec(conn, "EXEC"); reply != "*1\r\n+OK\r\n" {
+		t.Errorf("exec should succeed, actual %q", reply)
+	}
+
+	// 事务执行成功后同样修改版本
+	exec(conn, "WATCH", "k")
+	exec(other, "MULTI")
+	exec(other, "SET", "k", "v3")
+	exec(other, "EXEC")
+	exec(conn, "MULTI")
+	exec(conn, "SET", "k", "v4")
+	if reply := exec(conn, "EXEC"); reply != "*0\r\n" {
+		t.Errorf("exec should fail after watched key changed, actual %q", reply)
+	}
+
+	// 同一个命令中重复的key只增加一次版本
+	exec(conn, "MSET", "a", "1", "a", "2")
+	if version := server.mustSelectDB(0).GetVersion("a"); version != 1 {
+		t.Errorf("expect version 1, actual %d", version)
+	}
+}
+
+func TestWatchRace(t *testing.T) {
+	server := NewStandaloneServer()
+	const workers = 8
+	const increments = 50
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn := connection.NewFakeConn()
+			exec := func(args ...string) string {
+				return string(server.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+			}
+			for done := 0; done < increments; {
+				exec("WATCH", "counter")
+				n := 0
+				if reply := exec("GET", "counter"); reply != "$-1\r\n" {
+					n, _ = strconv.Atoi(strings.Split(reply, "\r\n")[1])
+				}
+				exec("MULTI")
+				exec("SET", "counter", strconv.Itoa(n+1))
+				if reply := exec("EXEC"); reply != "*0\r\n" {
+					done++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	conn := connection.NewFakeConn()
+	expected := strconv.Itoa(workers * increments)
+	reply := string(server.Exec(conn, utils.ToCmdLine("GET", "counter")).ToBytes())
+	if reply != "$"+strconv.Itoa(len(expected))+"\r\n"+expected+"\r\n" {
+		t.Errorf("lost update: expect %s, actual %q", expected, reply)
+	}
+}
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"Godis/interface/database"
+	"Godis/interface/redis"
+	"Godis/internal/aof"
+	"Godis/internal/config"
+	"Godis/lib/clock"
+	"Godis/lib/logger"
+	"Godis/lib/timewheel"
+	"Godis/redis/protocol"
+	"sync"
+	"time"
+)
+
+// 回收站: 配置 trash-ttl 后，DEL 和 FLUSHDB 删除的key不会立即释放，而是放入回收站，
+// trash-ttl 秒后由时间轮清除，在此之前可以通过 UNDELETE 恢复，用于开发环境防止误删
+// 回收站只在内存中，不写入 aof 和 rdb，重启后丢失；主动过期和淘汰删除的key不会放入回收站
+
+// trashEntry is a removed key kept in trash
+type trashEntry struct {
+	entity     *database.DataEntity
+	expireTime time.Time
+	hasTTL     bool
+}
+
+// trashBin holds removed keys of a db
+// FLUSHDB 会替换整个DB，新的DB继承旧DB的回收站
+type trashBin struct {
+	mu      sync.Mutex
+	entries map[string]*trashEntry
+}
+
+func makeTrashBin() *trashBin {
+	return &trashBin{}
+}
+
+// trashTTL returns how long removed keys are kept, 0 means trash is disabled
+func trashTTL() time.Duration {
+	return time.Duration(config.Properties.TrashTTL) * time.Second
+}
+
+// add puts entries into trash and purges them after ttl
+func (bin *trashBin) add(ttl time.Duration, keys []string, entries []*trashEntry) {
+	if len(keys) == 0 {
+		return
+	}
+	bin.mu.Lock()
+	if bin.entries == nil {
+		bin.entries = make(map[string]*trashEntry)
+	}
+	for i, key := range keys {
+		bin.entries[key] = entries[i]
+	}
+	bin.mu.Unlock()
+	// 同一批删除的key共用一个定时任务
+	timewheel.Delay(ttl, "", func() {
+		bin.purge(keys, entries)
+	})
+}
+
+// purge removes given entries from trash, a key which is restored or removed again is not affected
+func (bin *trashBin) purge(keys []string, entries []*trashEntry) {
+	bin.mu.Lock()
+	defer bin.mu.Unlock()
+	for i, key := range keys {
+		if bin.entries[key] == entries[i] {
+			delete(bin.entries, key)
+		}
+	}
+}
+
+// take removes the entry of key from trash and returns it
+func (bin *trashBin) take(key string) (*trashEntry, bool) {
+	bin.mu.Lock()
+	defer bin.mu.Unlock()
+	entry, ok := bin.entries[key]
+	if ok {
+		delete(bin.entries, key)
+	}
+	return entry, ok
+}
+
+// makeTrashEntry returns nil if the key has expired, expired keys are not kept in trash
+func makeTrashEntry(entity *database.DataEntity, expiration *time.Time) *trashEntry {
+	entry := &trashEntry{entity: entity}
+	if expiration != nil {
+		if clock.Now().After(*expiration) {
+			return nil
+		}
+		entry.expireTime, entry.hasTTL = *expiration, true
+	}
+	return entry
+}
+
+// moveToTrash removes the given keys from db and puts them into trash, returns count of removed keys
+// 与 Removes 相同，调用者需要持有这些key的写锁
+func (db *DB) moveToTrash(ttl time.Duration, keys ...string) (deleted int) {
+	var trashed []string
+	var entries []*trashEntry
+	for _, key := range keys {
+		entity, exists := db.data.GetWithLock(key)
+		if !exists {
+			continue
+		}
+		var expiration *time.Time
+		if expireTime, ok := db.ttlMap.Get(key); ok {
+			expiration = &expireTime
+		}
+		if entry := makeTrashEntry(entity, expiration); entry != nil {
+			trashed = append(trashed, key)
+			entries = append(entries, entry)
+		}
+		db.Remove(key)
+		deleted++
+	}
+	db.trash.add(ttl, trashed, entries)
+	return deleted
+}
+
+// moveAllToTrash puts all keys of a flushed db into trash, the db must have been replaced
+func (db *DB) moveAllToTrash(ttl time.Duration) {
+	var keys []string
+	var entries []*trashEntry
+	db.ForEach(func(key string, entity *database.DataEntity, expiration *time.Time) bool {
+		if entry := makeTrashEntry(entity, expiration); entry != nil {
+			keys = append(keys, key)
+			entries = append(entries, entry)
+		}
+		return true
+	})
+	db.trash.add(ttl, keys, entries)
+}
+
+// execUndelete restores a key removed by DEL or FLUSHDB from trash
+// 返回1表示恢复成功，0表示回收站中没有该key或者该key已过期
+func execUndelete(db *DB, args [][]byte) redis.Reply {
+	if trashTTL() <= 0 {
+		return protocol.MakeErrReply("ERR trash is disabled, set trash-ttl to enable it")
+	}
+	key := string(args[0])
+	if _, exists := db.GetEntity(key); exists {
+		return protocol.MakeErrReply("BUSYKEY Target key name already exists.")
+	}
+	entry, ok := db.trash.take(key)
+	if !ok || entry.hasTTL && clock.Now().After(entry.expireTime) {
+		return protocol.MakeIntReply(0)
+	}
+	db.PutEntity(key, entry.entity)
+	if entry.hasTTL {
+		db.Expire(key, entry.expireTime)
+	}
+	if cmd := aof.EntityToCmd(key, entry.entity); cmd != nil {
+		db.addAof(cmd.Args)
+		if entry.hasTTL {
+			db.addAof(aof.MakeExpireCmd(key, entry.expireTime).Args)
+		}
+		for _, fieldCmd := range aof.HashFieldExpireCmds(key, entry.entity) {
+			db.addAof(fieldCmd.Args)
+		}
+	} else {
+		logger.Warn("undelete " + key + ": type " + entry.entity.Type.String() + " cannot be written to aof")
+	}
+	return protocol.MakeIntReply(1)
+}
+
+func init() {
+	// 事务回滚时通过 DEL 删除恢复的key，开启回收站时会重新放入回收站
+	registerCommand("Undelete", execUndelete, writeFirstKey, rollbackFirstKey, 2, flagWrite).
+		attachCommandExtra([]string{redisFlagWrite, redisFlagFast}, 1, 1, 1)
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"Godis/internal/config"
+	"Godis/internal/redis/connection"
+	"Godis/lib/utils"
+	"testing"
+)
+
+func TestTrash(t *testing.T) {
+	config.Properties.TrashTTL = 60
+	defer func() {
+		config.Properties.TrashTTL = 0
+	}()
+	server := NewStandaloneServer()
+	conn := connection.NewFakeConn()
+	exec := func(args ...string) string {
+		return string(server.Exec(conn, utils.ToCmdLine(args...)).ToBytes())
+	}
+
+	exec("SET", "k", "v", "EX", "100")
+	if reply := exec("DEL", "k"); reply != ":1\r\n" {
+		t.Errorf("expect 1, actual %q", reply)
+	}
+	if reply := exec("UNDELETE", "k"); reply != ":1\r\n" {
+		t.Errorf("expect 1, actual %q", reply)
+	}
+	if reply := exec("GET", "k"); reply != "$1\r\nv\r\n" {
+		t.Errorf("expect v, actual %q", reply)
+	}
+	if reply := exec("TTL", "k"); reply == ":-1\r\n" || reply == ":-2\r\n" {
+		t.Errorf("ttl should be restored, actual %q", reply)
+	}
+	if reply := exec("UNDELETE", "k"); reply[0] != '-' {
+		t.Errorf("expect busy key error, actual %q", reply)
+	}
+	if reply := exec("UNDELETE", "missing"); reply != ":0\r\n" {
+		t.Errorf("expect 0, actual %q", reply)
+	}
+
+	// FLUSHDB 之后的key同样可以恢复
+	exec("SET", "a", "1")
+	exec("FLUSHDB")
+	if reply := exec("UNDELETE", "a"); reply != ":1\r\n" {
+		t.Errorf("expect 1 after flushdb, actual %q", reply)
+	}
+
+	// 被清除或已过期的key不能恢复
+	db := server.mustSelectDB(0)
+	exec("DEL", "a")
+	entry := db.trash.entries["a"]
+	db.trash.purge([]string{"a"}, []*trashEntry{entry})
+	if reply := exec("UNDELETE", "a"); reply != ":0\r\n" {
+		t.Errorf("expect 0 after purge, actual %q", reply)
+	}
+	exec("SET", "e", "1", "PXAT", "1")
+	exec("DEL", "e")
+	if reply := exec("UNDELETE", "e"); reply != ":0\r\n" {
+		t.Errorf("expired key should not be restored, actual %q", reply)
+	}
+	// 再次删除后，之前的清除任务不会清除新的记录
+	exec("SET", "b", "1")
+	exec("DEL", "b")
+	stale := db.trash.entries["b"]
+	exec("UNDELETE", "b")
+	exec("DEL", "b")
+	db.trash.purge([]string{"b"}, []*trashEntry{stale})
+	if reply := exec("UNDELETE", "b"); reply != ":1\r\n" {
+		t.Errorf("expect 1, actual %q", reply)
+	}
+}
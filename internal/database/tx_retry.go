@@ -0,0 +1,58 @@
+package database
+
+import (
+	"Godis/interface/database"
+	"Godis/interface/redis"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"context"
+	"errors"
+	"time"
+)
+
+// 乐观事务重试
+// 嵌入式使用 godis 时，read-modify-write 通常写作 WATCH、读取、MULTI/EXEC，EXEC 因被监视的key被修改而失败后重新执行。
+// RetryTx 封装了这一过程，失败后按指数退避等待一段时间再重试，而不是立即重试与其他事务反复冲突
+
+const (
+	txRetryBaseBackoff = time.Millisecond
+	txRetryMaxBackoff  = 100 * time.Millisecond
+)
+
+// ErrTxConflict is returned by RetryTx if watched keys were modified in every attempt
+var ErrTxConflict = errors.New("transaction aborted: watched keys changed")
+
+// RetryTx runs an optimistic transaction: records versions of watchKeys, calls fn, then executes the returned
+// commands like MULTI/EXEC. If any watched key changed after it was recorded, it waits with backoff and retries,
+// at most maxAttempts times in total. ctx can cancel the waiting between attempts.
+// Returns reply of EXEC on success.
+func (server *Server) RetryTx(ctx context.Context, conn redis.Connection, watchKeys []string, maxAttempts int,
+	fn database.TxFunc) (redis.Reply, error) {
+	db, errReply := server.selectDB(conn.GetDBIndex())
+	if errReply != nil {
+		return nil, errReply
+	}
+	backoff := &utils.Backoff{Base: txRetryBaseBackoff, Max: txRetryMaxBackoff}
+	var result redis.Reply
+	err := utils.Retry(ctx, backoff, maxAttempts, func() (bool, error) {
+		watching := make(map[string]uint32, len(watchKeys))
+		for _, key := range watchKeys {
+			watching[key] = db.GetVersion(key)
+		}
+		cmdLines, err := fn()
+		if err != nil {
+			return false, err
+		}
+		result = db.ExecMulti(conn, watching, cmdLines)
+		// EmptyMultiBulkReply 表示被监视的key已被修改
+		_, conflict := result.(*protocol.EmptyMultiBulkReply)
+		return !conflict, nil
+	})
+	if err == utils.ErrMaxAttempts {
+		return nil, ErrTxConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
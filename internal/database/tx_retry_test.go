@@ -0,0 +1,92 @@
+package database
+
+import (
+	"Godis/internal/redis/connection"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// incrTx reads counter and returns SET counter value+1
+func incrTx(t *testing.T, server *Server, conn *connection.FakeConn) func() ([]CmdLine, error) {
+	return func() ([]CmdLine, error) {
+		value := 0
+		if bulk, ok := server.Exec(conn, utils.ToCmdLine("GET", "counter")).(*protocol.BulkReply); ok {
+			var err error
+			if value, err = strconv.Atoi(string(bulk.Arg)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return []CmdLine{utils.ToCmdLine("SET", "counter", strconv.Itoa(value+1))}, nil
+	}
+}
+
+func TestRetryTx(t *testing.T) {
+	server := NewStandaloneServer()
+	defer server.Close()
+	conn := connection.NewFakeConn()
+	other := connection.NewFakeConn()
+	ctx := context.Background()
+	getCounter := func() string {
+		return string(server.Exec(conn, utils.ToCmdLine("GET", "counter")).ToBytes())
+	}
+
+	// 没有冲突时一次成功
+	calls := 0
+	incr := incrTx(t, server, conn)
+	reply, err := server.RetryTx(ctx, conn, []string{"counter"}, 3, func() ([]CmdLine, error) {
+		calls++
+		return incr()
+	})
+	if err != nil || string(reply.ToBytes()) != "*1\r\n+OK\r\n" || calls != 1 {
+		t.Errorf("unexpected result %v %v after %d calls", reply, err, calls)
+	}
+	if actual := getCounter(); actual != "$1\r\n1\r\n" {
+		t.Errorf("expect counter 1, actual %q", actual)
+	}
+
+	// 第一次读取后被其他连接修改，重试时读到新值
+	calls = 0
+	reply, err = server.RetryTx(ctx, conn, []string{"counter"}, 3, func() ([]CmdLine, error) {
+		calls++
+		cmdLines, err := incr()
+		if calls == 1 {
+			server.Exec(other, utils.ToCmdLine("SET", "counter", "10"))
+		}
+		return cmdLines, err
+	})
+	if err != nil || string(reply.ToBytes()) != "*1\r\n+OK\r\n" || calls != 2 {
+		t.Errorf("unexpected result %v %v after %d calls", reply, err, calls)
+	}
+	if actual := getCounter(); actual != "$2\r\n11\r\n" {
+		t.Errorf("expect counter 11, actual %q", actual)
+	}
+
+	// 每次都冲突，用完尝试次数
+	calls = 0
+	reply, err = server.RetryTx(ctx, conn, []string{"counter"}, 3, func() ([]CmdLine, error) {
+		calls++
+		server.Exec(other, utils.ToCmdLine("SET", "counter", "100"))
+		return incr()
+	})
+	if err != ErrTxConflict || reply != nil || calls != 3 {
+		t.Errorf("expect ErrTxConflict after 3 calls, actual %v %v after %d calls", reply, err, calls)
+	}
+	if actual := getCounter(); actual != "$3\r\n100\r\n" {
+		t.Errorf("expect counter 100, actual %q", actual)
+	}
+
+	// 等待重试时 ctx 被取消
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = server.RetryTx(ctx, conn, []string{"counter"}, 1000, func() ([]CmdLine, error) {
+		server.Exec(other, utils.ToCmdLine("SET", "counter", "100"))
+		return incr()
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("expect deadline exceeded, actual %v", err)
+	}
+}
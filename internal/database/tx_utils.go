@@ -1,7 +1,7 @@
 package database
 
 import (
-	"Godis/aof"
+	"Godis/internal/aof"
 	"Godis/lib/utils"
 	"strconv"
 )
@@ -50,12 +50,20 @@ func rollbackGivenKeys(db *DB, keys ...string) []CmdLine {
 			undoCmdLines = append(undoCmdLines,
 				utils.ToCmdLine("DEL", key),
 			)
+		} else if cmd := aof.EntityToCmd(key, entity); cmd == nil {
+			// 无法序列化的值(如解压失败)不能整体恢复，只恢复过期时间
+			undoCmdLines = append(undoCmdLines,
+				toTTLCmd(db, key).Args,
+			)
 		} else {
 			undoCmdLines = append(undoCmdLines,
 				utils.ToCmdLine("DEL", key), // clean existed first
-				aof.EntityToCmd(key, entity).Args,
+				cmd.Args,
 				toTTLCmd(db, key).Args,
 			)
+			for _, fieldCmd := range aof.HashFieldExpireCmds(key, entity) {
+				undoCmdLines = append(undoCmdLines, fieldCmd.Args)
+			}
 		}
 	}
 	return undoCmdLines
@@ -63,24 +71,23 @@ func rollbackGivenKeys(db *DB, keys ...string) []CmdLine {
 
 func rollbackHashFields(db *DB, key string, fields ...string) []CmdLine {
 	var undoCmdLines [][][]byte
-	dict, errReply := db.getAsDict(key)
+	hash, errReply := db.getAsHash(key)
 	if errReply != nil {
 		return nil
 	}
-	if dict == nil {
+	if hash == nil {
 		undoCmdLines = append(undoCmdLines,
 			utils.ToCmdLine("DEL", key),
 		)
 		return undoCmdLines
 	}
 	for _, field := range fields {
-		entity, ok := dict.Get(field)
+		value, ok := hash.Get(field)
 		if !ok {
 			undoCmdLines = append(undoCmdLines,
 				utils.ToCmdLine("HDEL", key, field),
 			)
 		} else {
-			value, _ := entity.([]byte)
 			undoCmdLines = append(undoCmdLines,
 				utils.ToCmdLine("HSET", key, field, string(value)),
 			)
@@ -107,6 +114,26 @@ func prepareSetCalculateStore(args [][]byte) ([]string, []string) {
 	return []string{dest}, keys
 }
 
+// prepareZSetCalculate returns keys of ZUNION/ZINTER/ZDIFF, args starts with numkeys
+func prepareZSetCalculate(args [][]byte) ([]string, []string) {
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil || numKeys <= 0 || numKeys > len(args)-1 {
+		// 参数错误由命令本身返回
+		return nil, nil
+	}
+	keys := make([]string, numKeys)
+	for i := 0; i < numKeys; i++ {
+		keys[i] = string(args[i+1])
+	}
+	return nil, keys
+}
+
+func prepareZSetCalculateStore(args [][]byte) ([]string, []string) {
+	dest := string(args[0])
+	_, keys := prepareZSetCalculate(args[1:])
+	return []string{dest}, keys
+}
+
 func rollbackSetMembers(db *DB, key string, members ...string) []CmdLine {
 	var undoCmdLines [][][]byte
 	set, errReply := db.getAsSet(key)
@@ -145,6 +172,23 @@ func undoSetChange(db *DB, args [][]byte) []CmdLine {
 	return rollbackSetMembers(db, key, members...)
 }
 
+// prepareSMove locks source and destination of SMOVE
+func prepareSMove(args [][]byte) ([]string, []string) {
+	return []string{string(args[0]), string(args[1])}, nil
+}
+
+// undoSMove rollbacks SMOVE, restores the member in both source and destination
+func undoSMove(db *DB, args [][]byte) []CmdLine {
+	src := string(args[0])
+	dest := string(args[1])
+	member := string(args[2])
+	undoCmdLines := rollbackSetMembers(db, src, member)
+	if dest != src {
+		undoCmdLines = append(undoCmdLines, rollbackSetMembers(db, dest, member)...)
+	}
+	return undoCmdLines
+}
+
 func rollbackZSetFields(db *DB, key string, fields ...string) []CmdLine {
 	var undoCmdLines [][][]byte
 	zset, errReply := db.getAsSortedSet(key)
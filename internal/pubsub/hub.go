@@ -0,0 +1,28 @@
+package pubsub
+
+import (
+	"Godis/datastruct/dict"
+	"Godis/datastruct/lock"
+)
+
+// Hub stores all subscribe relations
+type Hub struct {
+	// channel -> list(*Client)
+	subs dict.Dict
+	// lock channel
+	subsLocker *lock.Locks
+}
+
+// defaultLockStripes is stripe count of channel locks if not configured
+const defaultLockStripes = 16
+
+// MakeHub creates new hub, lockStripes is stripe count of channel locks and must be power of two, 0 means default
+func MakeHub(lockStripes int) *Hub {
+	if lockStripes <= 0 {
+		lockStripes = defaultLockStripes
+	}
+	return &Hub{
+		subs:       dict.MakeConcurrent(4),
+		subsLocker: lock.Make(lockStripes),
+	}
+}
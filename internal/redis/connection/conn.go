@@ -1,9 +1,11 @@
 package connection
 
 import (
+	"Godis/interface/redis"
 	"Godis/lib/sync/wait"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -67,16 +69,24 @@ var connPool = sync.Pool{
 	},
 }
 
+// outputPending is total bytes of replies being written to all clients
+// 回复是同步写入的，客户端读取缓慢时 Write 会阻塞，积压的字节数即为输出缓冲的大小
+var outputPending int64
+
+// OutputPending returns total bytes of replies waiting for clients to read
+func OutputPending() int64 {
+	return atomic.LoadInt64(&outputPending)
+}
+
 // Write sends response to client over tcp connection
 func (c *Connection) Write(bytes []byte) (int, error) {
 	if len(bytes) == 0 {
 		return 0, nil
 	}
 	c.sendingData.Add(1)
-	defer func() {
-		c.sendingData.Done()
-	}()
 	defer c.sendingData.Done()
+	atomic.AddInt64(&outputPending, int64(len(bytes)))
+	defer atomic.AddInt64(&outputPending, -int64(len(bytes)))
 	return c.conn.Write(bytes)
 }
 
@@ -84,7 +94,10 @@ func (c *Connection) Write(bytes []byte) (int, error) {
 func (c *Connection) Close() error {
 	c.sendingData.WaitWithTimeout(10 * time.Second)
 	_ = c.conn.Close()
+	// 服务关闭时可能与处理命令的协程并发执行
+	c.mu.Lock()
 	c.subs = nil
+	c.mu.Unlock()
 	c.password = ""
 	c.queue = nil
 	c.watching = nil
@@ -96,7 +109,11 @@ func (c *Connection) Close() error {
 }
 
 // RemoteAddr returns the remote network address
+// 服务端内部使用的 FakeConn 没有网络连接，返回空字符串
 func (c *Connection) RemoteAddr() string {
+	if c.conn == nil {
+		return ""
+	}
 	return c.conn.RemoteAddr().String()
 }
 
@@ -231,3 +248,13 @@ func (c *Connection) Name() string {
 	}
 	return ""
 }
+
+// Capabilities returns features supported by a client connection
+func (c *Connection) Capabilities() redis.Capability {
+	caps := redis.CapMulti | redis.CapBlocking
+	// 与主节点之间的复制连接不会收到推送消息
+	if !c.IsMaster() {
+		caps |= redis.CapPush
+	}
+	return caps
+}
@@ -1,6 +1,7 @@
 package connection
 
 import (
+	"Godis/interface/redis"
 	"Godis/lib/logger"
 	"fmt"
 	"io"
@@ -35,6 +36,13 @@ func (c *FakeConn) Write(b []byte) (int, error) {
 	return len(b), nil
 }
 
+// Capabilities returns features supported by FakeConn
+// FakeConn 用于在服务端内部执行命令(如 AOF 加载、集群转发、HTTP 网关)，执行完即丢弃，
+// 因此不能接收推送消息，也不能被阻塞
+func (c *FakeConn) Capabilities() redis.Capability {
+	return redis.CapMulti
+}
+
 func (c *FakeConn) notify() {
 	if c.waitOn != nil {
 		c.mu.Lock()
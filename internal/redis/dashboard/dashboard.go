@@ -0,0 +1,480 @@
+// Package dashboard provides a read-only web admin dashboard showing INFO sections, slowlog, clients, command stats and browsing keyspace
+package dashboard
+
+import (
+	"Godis/interface/database"
+	"Godis/interface/redis"
+	"Godis/internal/redis/connection"
+	"Godis/lib/logger"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+	// maxScanCalls limits SCAN calls of a page, so a sparse pattern returns a short page instead of walking the whole keyspace
+	maxScanCalls = 16
+
+	defaultSlowlogCount = 50
+)
+
+// Dashboard serves read-only admin pages with the given db
+// it only issues INFO, SELECT, SCAN, TYPE, PTTL, SLOWLOG GET and CLIENT LIST, nothing could be modified through dashboard
+type Dashboard struct {
+	db       database.DB
+	server   *http.Server
+	user     string
+	password string
+	// requirePass is used to authenticate with db if requirepass is set
+	requirePass string
+}
+
+// Settings of dashboard
+type Settings struct {
+	User     string
+	Password string
+	// RequirePass is the requirepass of server, dashboard sends AUTH with it
+	RequirePass string
+}
+
+// infoSection is a section of INFO reply, fields keep the order in reply
+type infoSection struct {
+	Name   string     `json:"name"`
+	Fields [][]string `json:"fields"`
+}
+
+// keyItem is a row of key browser
+type keyItem struct {
+	Key  string `json:"key"`
+	Type string `json:"type"`
+	// TTL in milliseconds, -1 means no expiration
+	TTL int64 `json:"ttl"`
+}
+
+// keysPage is a page of key browser
+type keysPage struct {
+	Keys []*keyItem `json:"keys"`
+	// Cursor of next page returned by SCAN, 0 means iteration finished
+	Cursor int `json:"cursor"`
+}
+
+// slowlogItem is an entry of SLOWLOG GET
+type slowlogItem struct {
+	ID        int64    `json:"id"`
+	Timestamp int64    `json:"timestamp"`
+	Duration  int64    `json:"duration"`
+	Args      []string `json:"args"`
+	Addr      string   `json:"addr"`
+	Name      string   `json:"name"`
+	Cost      string   `json:"cost,omitempty"`
+}
+
+// commandStat is a line of INFO commandstats
+type commandStat struct {
+	Command     string  `json:"command"`
+	Calls       int64   `json:"calls"`
+	Usec        int64   `json:"usec"`
+	UsecPerCall float64 `json:"usecPerCall"`
+	FailedCalls int64   `json:"failedCalls"`
+}
+
+// response is the json body of all api responses
+type response struct {
+	Result interface{} `json:"result"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// MakeDashboard creates a dashboard, admin credentials are required
+func MakeDashboard(db database.DB, settings *Settings) (*Dashboard, error) {
+	if settings.User == "" || settings.Password == "" {
+		return nil, errors.New("dashboard requires admin user and password")
+	}
+	d := &Dashboard{
+		db:          db,
+		user:        settings.User,
+		password:    settings.Password,
+		requirePass: settings.RequirePass,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", d.basicAuth(d.handleIndex))
+	mux.HandleFunc("/api/info", d.basicAuth(d.handleInfo))
+	mux.HandleFunc("/api/keys", d.basicAuth(d.handleKeys))
+	mux.HandleFunc("/api/slowlog", d.basicAuth(d.handleSlowlog))
+	mux.HandleFunc("/api/clients", d.basicAuth(d.handleClients))
+	mux.HandleFunc("/api/commandstats", d.basicAuth(d.handleCommandStats))
+	d.server = &http.Server{
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	return d, nil
+}
+
+// Serve accepts http requests on listener, blocks until Close
+func (d *Dashboard) Serve(listener net.Listener) error {
+	logger.Info("dashboard listening on " + listener.Addr().String())
+	err := d.server.Serve(listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Close stops the dashboard
+func (d *Dashboard) Close() error {
+	return d.server.Close()
+}
+
+// basicAuth checks admin credentials with http basic authentication
+func (d *Dashboard) basicAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		// 使用常量时间比较，避免通过响应时间猜测密码
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(d.user)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(d.password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="godis"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "dashboard is read-only", http.StatusMethodNotAllowed)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// newConn creates a fake connection which has been authenticated and selected db
+func (d *Dashboard) newConn(dbIndex string) (redis.Connection, error) {
+	conn := connection.NewFakeConn()
+	if d.requirePass != "" {
+		reply := d.db.Exec(conn, utils.ToCmdLine("AUTH", d.requirePass))
+		if protocol.IsErrorReply(reply) {
+			return nil, reply.(protocol.ErrorReply)
+		}
+	}
+	if dbIndex != "" {
+		reply := d.db.Exec(conn, utils.ToCmdLine("SELECT", dbIndex))
+		if protocol.IsErrorReply(reply) {
+			return nil, reply.(protocol.ErrorReply)
+		}
+	}
+	return conn, nil
+}
+
+func (d *Dashboard) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(indexPage))
+}
+
+// handleInfo returns INFO sections, GET /api/info?section=keyspace
+func (d *Dashboard) handleInfo(w http.ResponseWriter, r *http.Request) {
+	conn, err := d.newConn("")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, &response{Error: err.Error()})
+		return
+	}
+	cmdLine := utils.ToCmdLine("INFO")
+	if section := r.URL.Query().Get("section"); section != "" {
+		cmdLine = append(cmdLine, []byte(section))
+	}
+	reply := d.db.Exec(conn, cmdLine)
+	text, ok := replyText(reply)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, &response{Error: replyError(reply)})
+		return
+	}
+	writeJSON(w, http.StatusOK, &response{Result: parseInfo(text)})
+}
+
+// handleKeys returns a page of keys with type and ttl, GET /api/keys?db=0&pattern=*&cursor=0&count=50
+// cursor is the cursor of SCAN, so a page never walks the whole keyspace, keys may repeat across pages like SCAN
+func (d *Dashboard) handleKeys(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	pattern := query.Get("pattern")
+	if pattern == "" {
+		pattern = "*"
+	}
+	cursor, err := parseIntParam(query.Get("cursor"), 0)
+	if err != nil || cursor < 0 {
+		writeJSON(w, http.StatusBadRequest, &response{Error: "illegal cursor"})
+		return
+	}
+	count, err := parseIntParam(query.Get("count"), defaultPageSize)
+	if err != nil || count <= 0 {
+		writeJSON(w, http.StatusBadRequest, &response{Error: "illegal count"})
+		return
+	}
+	if count > maxPageSize {
+		count = maxPageSize
+	}
+	conn, err := d.newConn(query.Get("db"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, &response{Error: err.Error()})
+		return
+	}
+
+	page := &keysPage{Keys: make([]*keyItem, 0, count)}
+	// SCAN 按 COUNT 遍历槽位而不是返回的键数，模式稀疏时一次可能没有结果，多调用几次凑满一页
+	for i := 0; i < maxScanCalls; i++ {
+		reply := d.db.Exec(conn, utils.ToCmdLine("SCAN", strconv.Itoa(cursor),
+			"MATCH", pattern, "COUNT", strconv.Itoa(count-len(page.Keys))))
+		next, keys, ok := parseScanReply(reply)
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, &response{Error: replyError(reply)})
+			return
+		}
+		for _, key := range keys {
+			page.Keys = append(page.Keys, d.describeKey(conn, key))
+		}
+		cursor = next
+		if cursor == 0 || len(page.Keys) >= count {
+			break
+		}
+	}
+	page.Cursor = cursor
+	writeJSON(w, http.StatusOK, &response{Result: page})
+}
+
+// handleSlowlog returns the newest slowlog entries, GET /api/slowlog?count=50
+func (d *Dashboard) handleSlowlog(w http.ResponseWriter, r *http.Request) {
+	count, err := parseIntParam(r.URL.Query().Get("count"), defaultSlowlogCount)
+	if err != nil || count <= 0 {
+		writeJSON(w, http.StatusBadRequest, &response{Error: "illegal count"})
+		return
+	}
+	conn, err := d.newConn("")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, &response{Error: err.Error()})
+		return
+	}
+	reply := d.db.Exec(conn, utils.ToCmdLine("SLOWLOG", "GET", strconv.Itoa(count)))
+	entries, ok := reply.(*protocol.MultiRawReply)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, &response{Error: replyError(reply)})
+		return
+	}
+	items := make([]*slowlogItem, 0, len(entries.Replies))
+	for _, entry := range entries.Replies {
+		if item := parseSlowlogEntry(entry); item != nil {
+			items = append(items, item)
+		}
+	}
+	writeJSON(w, http.StatusOK, &response{Result: items})
+}
+
+// handleClients returns connected clients, GET /api/clients
+// every client is a map of fields in CLIENT LIST, such as id, addr, idle and cmd
+func (d *Dashboard) handleClients(w http.ResponseWriter, r *http.Request) {
+	conn, err := d.newConn("")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, &response{Error: err.Error()})
+		return
+	}
+	reply := d.db.Exec(conn, utils.ToCmdLine("CLIENT", "LIST"))
+	text, ok := replyText(reply)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, &response{Error: replyError(reply)})
+		return
+	}
+	clients := make([]map[string]string, 0)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		client := make(map[string]string)
+		for _, field := range strings.Fields(line) {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) == 2 {
+				client[kv[0]] = kv[1]
+			}
+		}
+		clients = append(clients, client)
+	}
+	writeJSON(w, http.StatusOK, &response{Result: clients})
+}
+
+// handleCommandStats returns calls and time of each command, GET /api/commandstats
+func (d *Dashboard) handleCommandStats(w http.ResponseWriter, r *http.Request) {
+	conn, err := d.newConn("")
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, &response{Error: err.Error()})
+		return
+	}
+	reply := d.db.Exec(conn, utils.ToCmdLine("INFO", "commandstats"))
+	text, ok := replyText(reply)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, &response{Error: replyError(reply)})
+		return
+	}
+	stats := make([]*commandStat, 0)
+	for _, section := range parseInfo(text) {
+		for _, kv := range section.Fields {
+			if stat := parseCommandStat(kv[0], kv[1]); stat != nil {
+				stats = append(stats, stat)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, &response{Result: stats})
+}
+
+func (d *Dashboard) describeKey(conn redis.Connection, key string) *keyItem {
+	item := &keyItem{Key: key, TTL: -1}
+	if status, ok := d.db.Exec(conn, utils.ToCmdLine("TYPE", key)).(*protocol.StatusReply); ok {
+		item.Type = status.Status
+	}
+	if ttl, ok := d.db.Exec(conn, utils.ToCmdLine("PTTL", key)).(*protocol.IntReply); ok {
+		item.TTL = ttl.Code
+	}
+	return item
+}
+
+// parseInfo splits INFO reply into sections
+func parseInfo(info string) []*infoSection {
+	var sections []*infoSection
+	var current *infoSection
+	for _, line := range strings.Split(info, "\r\n") {
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "# ") {
+			current = &infoSection{Name: strings.TrimPrefix(line, "# ")}
+			sections = append(sections, current)
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) == 2 {
+			current.Fields = append(current.Fields, kv)
+		}
+	}
+	return sections
+}
+
+// parseScanReply parses [cursor, [key...]] returned by SCAN
+func parseScanReply(reply redis.Reply) (int, []string, bool) {
+	multiRaw, ok := reply.(*protocol.MultiRawReply)
+	if !ok || len(multiRaw.Replies) != 2 {
+		return 0, nil, false
+	}
+	cursorReply, ok := multiRaw.Replies[0].(*protocol.BulkReply)
+	if !ok {
+		return 0, nil, false
+	}
+	cursor, err := strconv.Atoi(string(cursorReply.Arg))
+	if err != nil {
+		return 0, nil, false
+	}
+	keysReply, ok := multiRaw.Replies[1].(*protocol.MultiBulkReply)
+	if !ok {
+		return 0, nil, false
+	}
+	keys := make([]string, len(keysReply.Args))
+	for i, arg := range keysReply.Args {
+		keys[i] = string(arg)
+	}
+	return cursor, keys, true
+}
+
+// parseSlowlogEntry parses [id, timestamp, duration, [arg...], addr, name, (cost)], returns nil if malformed
+func parseSlowlogEntry(reply redis.Reply) *slowlogItem {
+	fields, ok := reply.(*protocol.MultiRawReply)
+	if !ok || len(fields.Replies) < 6 {
+		return nil
+	}
+	item := &slowlogItem{}
+	ints := []*int64{&item.ID, &item.Timestamp, &item.Duration}
+	for i, dst := range ints {
+		intReply, ok := fields.Replies[i].(*protocol.IntReply)
+		if !ok {
+			return nil
+		}
+		*dst = intReply.Code
+	}
+	if args, ok := fields.Replies[3].(*protocol.MultiBulkReply); ok {
+		for _, arg := range args.Args {
+			item.Args = append(item.Args, string(arg))
+		}
+	}
+	item.Addr, _ = replyText(fields.Replies[4])
+	item.Name, _ = replyText(fields.Replies[5])
+	if len(fields.Replies) > 6 {
+		item.Cost, _ = replyText(fields.Replies[6])
+	}
+	return item
+}
+
+// parseCommandStat parses a line of INFO commandstats, such as cmdstat_get:calls=1,usec=2,usec_per_call=2.00,failed_calls=0
+func parseCommandStat(name, value string) *commandStat {
+	if !strings.HasPrefix(name, "cmdstat_") {
+		return nil
+	}
+	stat := &commandStat{Command: strings.TrimPrefix(name, "cmdstat_")}
+	for _, field := range strings.Split(value, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "calls":
+			stat.Calls, _ = strconv.ParseInt(kv[1], 10, 64)
+		case "usec":
+			stat.Usec, _ = strconv.ParseInt(kv[1], 10, 64)
+		case "usec_per_call":
+			stat.UsecPerCall, _ = strconv.ParseFloat(kv[1], 64)
+		case "failed_calls":
+			stat.FailedCalls, _ = strconv.ParseInt(kv[1], 10, 64)
+		}
+	}
+	return stat
+}
+
+// replyText returns the text of bulk, verbatim string or status reply
+func replyText(reply redis.Reply) (string, bool) {
+	switch r := reply.(type) {
+	case *protocol.BulkReply:
+		return string(r.Arg), true
+	case *protocol.VerbatimStringReply:
+		return string(r.Text), true
+	case *protocol.StatusReply:
+		return r.Status, true
+	}
+	return "", false
+}
+
+func parseIntParam(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func replyError(reply redis.Reply) string {
+	if errReply, ok := reply.(protocol.ErrorReply); ok {
+		return errReply.Error()
+	}
+	return "unexpected reply: " + strings.TrimSpace(string(reply.ToBytes()))
+}
+
+func writeJSON(w http.ResponseWriter, status int, resp *response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,134 @@
+package dashboard
+
+import (
+	"Godis/internal/config"
+	database2 "Godis/internal/database"
+	"Godis/internal/redis/connection"
+	"Godis/lib/utils"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestDashboard(t *testing.T) {
+	slowerThan, maxLen := config.Properties.SlowlogLogSlowerThan, config.Properties.SlowlogMaxLen
+	config.Properties.DataDictShards = 1024
+	config.Properties.SlowlogLogSlowerThan = 0
+	config.Properties.SlowlogMaxLen = 128
+	defer func() {
+		config.Properties.DataDictShards = 0
+		config.Properties.SlowlogLogSlowerThan = slowerThan
+		config.Properties.SlowlogMaxLen = maxLen
+	}()
+	db := database2.NewStandaloneServer()
+	defer db.Close()
+	d, err := MakeDashboard(db, &Settings{User: "admin", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(d.server.Handler)
+	defer server.Close()
+
+	get := func(path string, result interface{}) int {
+		req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("admin", "secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		if err := json.NewDecoder(resp.Body).Decode(&response{Result: result}); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	// 模拟一个网络客户端
+	server1, client1 := net.Pipe()
+	defer func() {
+		_ = server1.Close()
+		_ = client1.Close()
+	}()
+	conn := connection.NewConn(server1)
+	const keyCount = 120
+	for i := 0; i < keyCount; i++ {
+		db.Exec(conn, utils.ToCmdLine("SET", "k"+strconv.Itoa(i), "v"))
+	}
+	db.Exec(conn, utils.ToCmdLine("RPUSH", "list", "a"))
+	db.Exec(conn, utils.ToCmdLine("PEXPIRE", "list", "100000"))
+
+	// 通过游标遍历所有键
+	seen := make(map[string]*keyItem)
+	cursor := 0
+	for i := 0; ; i++ {
+		if i > keyCount {
+			t.Fatal("scan does not finish")
+		}
+		page := &keysPage{}
+		if status := get("/api/keys?count=10&cursor="+strconv.Itoa(cursor), page); status != http.StatusOK {
+			t.Fatalf("keys: unexpected status %d", status)
+		}
+		for _, item := range page.Keys {
+			seen[item.Key] = item
+		}
+		cursor = page.Cursor
+		if cursor == 0 {
+			break
+		}
+	}
+	if len(seen) != keyCount+1 {
+		t.Errorf("expect %d keys, actual %d", keyCount+1, len(seen))
+	}
+	if item := seen["list"]; item == nil || item.Type != "list" || item.TTL <= 0 {
+		t.Errorf("unexpected key item %+v", item)
+	}
+	page := &keysPage{}
+	get("/api/keys?pattern=list", page)
+	if len(page.Keys) != 1 || page.Keys[0].Key != "list" || page.Cursor != 0 {
+		t.Errorf("unexpected page of pattern %+v", page)
+	}
+
+	var sections []*infoSection
+	if status := get("/api/info?section=server", &sections); status != http.StatusOK || len(sections) != 1 || sections[0].Name != "Server" {
+		t.Errorf("info: unexpected response %d %+v", status, sections)
+	}
+
+	var stats []*commandStat
+	get("/api/commandstats", &stats)
+	calls := make(map[string]int64)
+	for _, stat := range stats {
+		calls[stat.Command] = stat.Calls
+	}
+	if calls["set"] != keyCount || calls["rpush"] != 1 {
+		t.Errorf("unexpected command stats %v", calls)
+	}
+
+	var slowlog []*slowlogItem
+	get("/api/slowlog?count=5", &slowlog)
+	if len(slowlog) != 5 || slowlog[0].Args[0] != "INFO" {
+		t.Errorf("unexpected slowlog %+v", slowlog)
+	}
+
+	var clients []map[string]string
+	get("/api/clients", &clients)
+	if len(clients) != 1 || clients[0]["cmd"] != "pexpire" || clients[0]["addr"] != conn.RemoteAddr() {
+		t.Errorf("unexpected clients %v", clients)
+	}
+	db.AfterClientClose(conn)
+	get("/api/clients", &clients)
+	if len(clients) != 0 {
+		t.Errorf("expect no clients after close, actual %v", clients)
+	}
+
+	if status := get("/api/keys?cursor=-1", nil); status != http.StatusBadRequest {
+		t.Errorf("expect bad request of illegal cursor, actual %d", status)
+	}
+}
@@ -0,0 +1,172 @@
+package dashboard
+
+// indexPage is the single page of dashboard, data are loaded from /api/info, /api/commandstats, /api/slowlog, /api/clients and /api/keys
+// all values are rendered with textContent, keys written by clients are never interpreted as html
+const indexPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Godis Dashboard</title>
+<style>
+body { font-family: sans-serif; margin: 20px; color: #333; }
+h2 { border-bottom: 1px solid #ddd; padding-bottom: 4px; }
+table { border-collapse: collapse; margin-bottom: 16px; }
+td, th { border: 1px solid #ddd; padding: 4px 10px; text-align: left; font-family: monospace; }
+th { background: #f5f5f5; }
+.sections { display: flex; flex-wrap: wrap; gap: 16px; }
+</style>
+</head>
+<body>
+<h1>Godis Dashboard</h1>
+<h2>Info</h2>
+<div id="info" class="sections"></div>
+<h2>Command Stats</h2>
+<table>
+  <thead><tr><th>Command</th><th>Calls</th><th>Usec</th><th>Usec/Call</th><th>Failed</th></tr></thead>
+  <tbody id="commandstats"></tbody>
+</table>
+<h2>Slowlog</h2>
+<table>
+  <thead><tr><th>ID</th><th>Time</th><th>Duration (us)</th><th>Command</th><th>Client</th><th>Cost</th></tr></thead>
+  <tbody id="slowlog"></tbody>
+</table>
+<h2>Clients</h2>
+<table>
+  <thead><tr><th>ID</th><th>Addr</th><th>Age (s)</th><th>Idle (s)</th><th>DB</th><th>Sub</th><th>Multi</th><th>Cmd</th></tr></thead>
+  <tbody id="clients"></tbody>
+</table>
+<h2>Keys</h2>
+<form id="filter">
+  db <input id="db" type="number" min="0" value="0" style="width:4em">
+  pattern <input id="pattern" value="*">
+  page size <input id="count" type="number" min="1" max="500" value="50" style="width:5em">
+  <button type="submit">Search</button>
+</form>
+<p id="summary"></p>
+<table>
+  <thead><tr><th>Key</th><th>Type</th><th>TTL (ms)</th></tr></thead>
+  <tbody id="keys"></tbody>
+</table>
+<button id="prev" disabled>Prev</button>
+<button id="next" disabled>Next</button>
+<script>
+var cursors = [0];
+
+function cell(row, text, tag) {
+  var el = document.createElement(tag || "td");
+  el.textContent = text;
+  row.appendChild(el);
+}
+
+function loadInfo() {
+  fetch("api/info").then(function (r) { return r.json(); }).then(function (body) {
+    var container = document.getElementById("info");
+    container.textContent = "";
+    if (body.error) { container.textContent = body.error; return; }
+    (body.result || []).forEach(function (section) {
+      var table = document.createElement("table");
+      var head = document.createElement("tr");
+      cell(head, section.name, "th");
+      cell(head, "", "th");
+      table.appendChild(head);
+      (section.fields || []).forEach(function (kv) {
+        var row = document.createElement("tr");
+        cell(row, kv[0]);
+        cell(row, kv[1]);
+        table.appendChild(row);
+      });
+      container.appendChild(table);
+    });
+  });
+}
+
+// loadTable fills tbody with rows returned by api, columns maps an item to its cells
+function loadTable(api, id, columns) {
+  fetch(api).then(function (r) { return r.json(); }).then(function (body) {
+    var tbody = document.getElementById(id);
+    tbody.textContent = "";
+    if (body.error) {
+      var row = document.createElement("tr");
+      cell(row, body.error);
+      tbody.appendChild(row);
+      return;
+    }
+    (body.result || []).forEach(function (item) {
+      var row = document.createElement("tr");
+      columns(item).forEach(function (text) { cell(row, text); });
+      tbody.appendChild(row);
+    });
+  });
+}
+
+function loadCommandStats() {
+  loadTable("api/commandstats", "commandstats", function (s) {
+    return [s.command, s.calls, s.usec, s.usecPerCall.toFixed(2), s.failedCalls];
+  });
+}
+
+function loadSlowlog() {
+  loadTable("api/slowlog", "slowlog", function (e) {
+    return [e.id, new Date(e.timestamp * 1000).toLocaleString(), e.duration,
+      (e.args || []).join(" "), e.addr, e.cost || ""];
+  });
+}
+
+function loadClients() {
+  loadTable("api/clients", "clients", function (c) {
+    return [c.id, c.addr, c.age, c.idle, c.db, c.sub, c.multi, c.cmd];
+  });
+}
+
+function loadKeys() {
+  var cursor = cursors[cursors.length - 1];
+  var params = new URLSearchParams({
+    db: document.getElementById("db").value,
+    pattern: document.getElementById("pattern").value,
+    count: document.getElementById("count").value,
+    cursor: cursor
+  });
+  fetch("api/keys?" + params).then(function (r) { return r.json(); }).then(function (body) {
+    var tbody = document.getElementById("keys");
+    tbody.textContent = "";
+    var summary = document.getElementById("summary");
+    if (body.error) { summary.textContent = body.error; return; }
+    var page = body.result;
+    summary.textContent = page.keys.length + " keys in this page";
+    page.keys.forEach(function (item) {
+      var row = document.createElement("tr");
+      cell(row, item.key);
+      cell(row, item.type);
+      cell(row, item.ttl < 0 ? "-" : item.ttl);
+      tbody.appendChild(row);
+    });
+    document.getElementById("prev").disabled = cursors.length <= 1;
+    var next = document.getElementById("next");
+    next.disabled = page.cursor === 0;
+    next.dataset.cursor = page.cursor;
+  });
+}
+
+document.getElementById("filter").addEventListener("submit", function (e) {
+  e.preventDefault();
+  cursors = [0];
+  loadKeys();
+});
+document.getElementById("prev").addEventListener("click", function () {
+  cursors.pop();
+  loadKeys();
+});
+document.getElementById("next").addEventListener("click", function () {
+  cursors.push(parseInt(this.dataset.cursor, 10));
+  loadKeys();
+});
+
+loadInfo();
+loadCommandStats();
+loadSlowlog();
+loadClients();
+loadKeys();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,433 @@
+// Package doctor checks configuration, ports, data files and system limits before the server starts serving,
+// `godis doctor` runs all checks and exits, normal startup runs the checks which do not conflict with the server
+// and logs the warnings
+package doctor
+
+import (
+	"Godis/internal/config"
+	"Godis/lib/fileutil"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Level is severity of a finding
+type Level uint8
+
+const (
+	LevelOK Level = iota
+	// LevelWarn means the server can start but may not work as expected
+	LevelWarn
+	// LevelError means the server cannot start or may lose data
+	LevelError
+)
+
+var levelNames = []string{"OK", "WARN", "ERROR"}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// Finding is the result of a check
+type Finding struct {
+	Level   Level
+	Check   string
+	Message string
+	// Hint tells how to fix the problem, empty for LevelOK
+	Hint string
+}
+
+func (f *Finding) String() string {
+	s := f.Check + ": " + f.Message
+	if f.Hint != "" {
+		s += ", " + f.Hint
+	}
+	return s
+}
+
+// Report holds findings of all checks in order
+type Report struct {
+	Findings []*Finding
+}
+
+func (r *Report) add(level Level, check string, message string, hint string) {
+	r.Findings = append(r.Findings, &Finding{
+		Level:   level,
+		Check:   check,
+		Message: message,
+		Hint:    hint,
+	})
+}
+
+// HasError returns true if any finding is LevelError
+func (r *Report) HasError() bool {
+	for _, f := range r.Findings {
+		if f.Level == LevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes findings in human readable format
+func (r *Report) Print(w io.Writer) {
+	warnings, errors := 0, 0
+	for _, f := range r.Findings {
+		_, _ = fmt.Fprintf(w, "[%s] %s: %s\n", f.Level, f.Check, f.Message)
+		if f.Hint != "" {
+			_, _ = fmt.Fprintf(w, "    hint: %s\n", f.Hint)
+		}
+		switch f.Level {
+		case LevelWarn:
+			warnings++
+		case LevelError:
+			errors++
+		}
+	}
+	_, _ = fmt.Fprintf(w, "%d checks, %d warnings, %d errors\n", len(r.Findings), warnings, errors)
+}
+
+// Run loads config file and runs all checks, it is `godis doctor`
+// configFile is empty if the server uses defaults, which must have resolved paths
+func Run(configFile string, defaults *config.ServerProperties) *Report {
+	r := &Report{}
+	p := defaults
+	if configFile != "" {
+		var err error
+		p, err = config.LoadConfig(configFile)
+		if err != nil {
+			r.add(LevelError, "config", "load "+configFile+" failed: "+err.Error(), "fix the config file")
+			return r
+		}
+		r.add(LevelOK, "config", "loaded "+p.CfPath, "")
+	} else {
+		r.add(LevelOK, "config", "no config file, using defaults", "")
+	}
+	diagnose(r, p, configFile, true)
+	return r
+}
+
+// Diagnose runs checks before serving, offline checks such as listening on ports and locking data dir are skipped,
+// since the server holds the lock and listeners may be passed by systemd
+func Diagnose(p *config.ServerProperties, configFile string) *Report {
+	r := &Report{}
+	diagnose(r, p, configFile, false)
+	return r
+}
+
+func diagnose(r *Report, p *config.ServerProperties, configFile string, offline bool) {
+	checkConfig(r, p, configFile)
+	if offline {
+		checkPorts(r, p)
+	}
+	checkDataDir(r, p, offline)
+	checkAof(r, p)
+	checkRdb(r, p)
+	checkClusterConfig(r, p)
+	checkLimits(r, p)
+}
+
+var (
+	fsyncPolicies     = []string{"", "always", "everysec", "no"}
+	maxMemoryPolicies = []string{"", "noeviction", "allkeys-lru", "volatile-lru", "allkeys-lfu", "volatile-lfu",
+		"allkeys-random", "volatile-random", "volatile-ttl"}
+)
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func checkConfig(r *Report, p *config.ServerProperties, configFile string) {
+	if configFile != "" {
+		if file, err := os.Open(configFile); err == nil {
+			keys, _ := config.UnknownKeys(file)
+			_ = file.Close()
+			for _, key := range keys {
+				r.add(LevelWarn, "config", "unknown option "+key+" is ignored", "check its spelling or remove it")
+			}
+		}
+	}
+	if err := p.Validate(); err != nil {
+		r.add(LevelError, "config", err.Error(), "fix the config file")
+	}
+	if !contains(fsyncPolicies, strings.ToLower(p.AppendFsync)) {
+		r.add(LevelWarn, "config", "unknown appendfsync "+p.AppendFsync+", aof is never fsynced explicitly",
+			"use always, everysec or no")
+	}
+	if !contains(maxMemoryPolicies, strings.ToLower(p.MaxMemoryPolicy)) {
+		r.add(LevelWarn, "config", "unknown maxmemory-policy "+p.MaxMemoryPolicy+", keys are never evicted",
+			"use one of "+strings.Join(maxMemoryPolicies[1:], ", "))
+	}
+	if p.TLSPort > 0 {
+		if _, err := tls.LoadX509KeyPair(p.TLSCertFile, p.TLSKeyFile); err != nil {
+			r.add(LevelError, "config", "load tls certificate failed: "+err.Error(),
+				"set tls-cert-file and tls-key-file to a valid key pair")
+		}
+	}
+	if p.DashboardPort > 0 && (p.DashboardUser == "" || p.DashboardPassword == "") {
+		r.add(LevelError, "config", "dashboard is enabled without user or password",
+			"set dashboard-user and dashboard-password")
+	}
+	if p.MemcachedPort > 0 && p.RequirePass != "" && !p.MemcachedNoAuth {
+		r.add(LevelError, "config", "memcached adapter bypasses requirepass",
+			"set memcached-no-auth yes to enable it anyway")
+	}
+	if p.RequirePass == "" && !isLoopback(p.Bind) {
+		r.add(LevelWarn, "config", "listening on "+p.Bind+" without requirepass, anyone in the network can access data",
+			"set requirepass or bind 127.0.0.1")
+	}
+	if p.ClusterEnable && !p.ClusterAsSeed && p.ClusterSeed == "" {
+		r.add(LevelError, "config", "cluster node has no seed to join", "set cluster-seed or cluster-as-seed yes")
+	}
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// listener is a port option
+type listener struct {
+	option string
+	port   int
+}
+
+func enabledListeners(p *config.ServerProperties) []listener {
+	var listeners []listener
+	for _, l := range []listener{
+		{"port", p.Port},
+		{"tls-port", p.TLSPort},
+		{"http-gateway-port", p.GatewayPort},
+		{"websocket-port", p.WebSocketPort},
+		{"dashboard-port", p.DashboardPort},
+		{"memcached-port", p.MemcachedPort},
+	} {
+		if l.port > 0 {
+			listeners = append(listeners, l)
+		}
+	}
+	return listeners
+}
+
+// checkPorts tries to listen on every enabled port
+func checkPorts(r *Report, p *config.ServerProperties) {
+	used := make(map[int]string)
+	for _, l := range enabledListeners(p) {
+		if other, ok := used[l.port]; ok {
+			r.add(LevelError, "ports", fmt.Sprintf("%s and %s are both %d", other, l.option, l.port),
+				"use different ports")
+			continue
+		}
+		used[l.port] = l.option
+		addr := net.JoinHostPort(p.Bind, strconv.Itoa(l.port))
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			r.add(LevelError, "ports", "cannot listen on "+addr+": "+err.Error(),
+				"stop the process using it or change "+l.option)
+			continue
+		}
+		_ = ln.Close()
+		r.add(LevelOK, "ports", l.option+" "+addr+" is available", "")
+	}
+}
+
+// checkDataDir checks data dir is writable and not used by another instance
+func checkDataDir(r *Report, p *config.ServerProperties, offline bool) {
+	info, err := os.Stat(p.Dir)
+	if os.IsNotExist(err) {
+		r.add(LevelOK, "data", "dir "+p.Dir+" does not exist and will be created", "")
+		return
+	}
+	if err != nil || !info.IsDir() {
+		r.add(LevelError, "data", "dir "+p.Dir+" is not a directory", "change dir or remove the file")
+		return
+	}
+	file, err := os.CreateTemp(p.Dir, "doctor-*")
+	if err != nil {
+		r.add(LevelError, "data", "dir "+p.Dir+" is not writable: "+err.Error(), "fix permission of the dir")
+		return
+	}
+	_ = file.Close()
+	_ = os.Remove(file.Name())
+	if offline {
+		lock, err := fileutil.LockDir(p.Dir, false)
+		if err != nil {
+			r.add(LevelError, "data", err.Error(), "")
+			return
+		}
+		_ = lock.Unlock()
+	}
+	// 重写 AOF 或保存 RDB 时需要先写入同样大小的临时文件
+	need := uint64(fileSize(p.AppendFilename) + fileSize(p.RDBFilename))
+	if err := fileutil.CheckFreeSpace(p.Dir, need); err != nil {
+		r.add(LevelWarn, "data", err.Error()+", rewriting aof or saving rdb may fail", "free up disk space")
+		return
+	}
+	r.add(LevelOK, "data", "dir "+p.Dir+" is writable", "")
+}
+
+func fileSize(name string) int64 {
+	if name == "" {
+		return 0
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// rdbMagic is the header of rdb file and rdb preamble of aof, followed by 4 digits version
+const rdbMagic = "REDIS"
+
+// maxRdbVersion is the max version supported by rdb decoder
+const maxRdbVersion = 10
+
+// checkRdbHeader returns error if header is not a supported rdb header
+func checkRdbHeader(header []byte) error {
+	if len(header) < len(rdbMagic)+4 || string(header[:len(rdbMagic)]) != rdbMagic {
+		return fmt.Errorf("invalid rdb header %q", header)
+	}
+	version, err := strconv.Atoi(string(header[len(rdbMagic) : len(rdbMagic)+4]))
+	if err != nil {
+		return fmt.Errorf("invalid rdb header %q", header)
+	}
+	if version < 1 || version > maxRdbVersion {
+		return fmt.Errorf("unsupported rdb version %d", version)
+	}
+	return nil
+}
+
+// readHeadAndTail returns the first and last n bytes of file, returns nil if file not exists
+func readHeadAndTail(name string, n int64) (head []byte, tail []byte, size int64, err error) {
+	file, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil, 0, nil
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	size = info.Size()
+	if size < n {
+		n = size
+	}
+	head = make([]byte, n)
+	if _, err = file.ReadAt(head, 0); err != nil {
+		return nil, nil, 0, err
+	}
+	tail = make([]byte, n)
+	if _, err = file.ReadAt(tail, size-n); err != nil {
+		return nil, nil, 0, err
+	}
+	return head, tail, size, nil
+}
+
+// checkAof checks aof starts with a command or rdb preamble and is not truncated in the middle of a command
+func checkAof(r *Report, p *config.ServerProperties) {
+	if !p.AppendOnly || p.AppendFilename == "" {
+		return
+	}
+	head, tail, size, err := readHeadAndTail(p.AppendFilename, 9)
+	if err != nil {
+		r.add(LevelError, "aof", "read "+p.AppendFilename+" failed: "+err.Error(), "fix permission of the file")
+		return
+	}
+	if head == nil || size == 0 {
+		r.add(LevelOK, "aof", p.AppendFilename+" is empty or not created yet", "")
+		return
+	}
+	if head[0] != '*' {
+		if err := checkRdbHeader(head); err != nil {
+			r.add(LevelError, "aof", p.AppendFilename+" is not an aof file: "+err.Error(),
+				"restore it from backup or change appendfilename")
+			return
+		}
+	}
+	if !strings.HasSuffix(string(tail), "\r\n") {
+		r.add(LevelWarn, "aof", p.AppendFilename+" ends in the middle of a command, the last command will be lost",
+			"truncate the incomplete command or restore it from backup")
+		return
+	}
+	r.add(LevelOK, "aof", p.AppendFilename+" looks good", "")
+}
+
+func checkRdb(r *Report, p *config.ServerProperties) {
+	if p.RDBFilename == "" {
+		return
+	}
+	head, _, _, err := readHeadAndTail(p.RDBFilename, 9)
+	if err != nil {
+		r.add(LevelError, "rdb", "read "+p.RDBFilename+" failed: "+err.Error(), "fix permission of the file")
+		return
+	}
+	if head == nil {
+		return
+	}
+	if err := checkRdbHeader(head); err != nil {
+		r.add(LevelError, "rdb", p.RDBFilename+" is not a valid rdb file: "+err.Error(),
+			"restore it from backup or remove it")
+		return
+	}
+	r.add(LevelOK, "rdb", p.RDBFilename+" looks good", "")
+}
+
+func checkClusterConfig(r *Report, p *config.ServerProperties) {
+	if !p.ClusterEnable || p.ClusterConfigFile == "" {
+		return
+	}
+	data, err := os.ReadFile(p.ClusterConfigFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil || !json.Valid(data) {
+		r.add(LevelError, "cluster", filepath.Base(p.ClusterConfigFile)+" is corrupted",
+			"remove it and rejoin the cluster with cluster-seed")
+	}
+}
+
+// reservedFds 与 redis CONFIG_MIN_RESERVED_FDS 一致，为持久化文件、监听端口和集群连接预留的文件描述符
+const reservedFds = 32
+
+// checkLimits checks limit of open files is enough for maxclients
+func checkLimits(r *Report, p *config.ServerProperties) {
+	if p.MaxClients <= 0 {
+		return
+	}
+	soft, hard, ok := openFilesLimit()
+	if !ok {
+		return
+	}
+	need := uint64(p.MaxClients) + reservedFds
+	if soft >= need {
+		r.add(LevelOK, "limits", fmt.Sprintf("open files limit %d is enough for maxclients %d", soft, p.MaxClients), "")
+		return
+	}
+	hint := fmt.Sprintf("run `ulimit -n %d` before starting, or set LimitNOFILE=%d in systemd unit", need, need)
+	if hard < need {
+		hint = fmt.Sprintf("raise hard limit to %d in /etc/security/limits.conf or LimitNOFILE of systemd unit, "+
+			"or lower maxclients to %d", need, hard-reservedFds)
+	}
+	r.add(LevelWarn, "limits", fmt.Sprintf("open files limit %d is lower than maxclients %d + %d reserved",
+		soft, p.MaxClients, reservedFds), hint)
+}
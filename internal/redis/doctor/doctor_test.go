@@ -0,0 +1,91 @@
+package doctor
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func findingsOf(r *Report, level Level, check string) []string {
+	var messages []string
+	for _, f := range r.Findings {
+		if f.Level == level && f.Check == check {
+			messages = append(messages, f.Message)
+		}
+	}
+	return messages
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	// 占用一个端口
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = ln.Close()
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+	conf := "bind 127.0.0.1\n" +
+		"port " + strconv.Itoa(port) + "\n" +
+		"dir " + dir + "\n" +
+		"appendonly yes\n" +
+		"appendfilename appendonly.aof\n" +
+		"dbfilename dump.rdb\n" +
+		"appendfsnyc always\n"
+	confFile := filepath.Join(dir, "redis.conf")
+	files := map[string]string{
+		confFile:                             conf,
+		filepath.Join(dir, "appendonly.aof"): "*1\r\n$4\r\nPING\r\n*3\r\n$3\r\nSET\r\n$1\r\na",
+		filepath.Join(dir, "dump.rdb"):       "REDIS0099",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := Run(confFile, nil)
+	if !r.HasError() {
+		t.Error("expect errors")
+	}
+	if warnings := findingsOf(r, LevelWarn, "config"); len(warnings) != 1 || !strings.Contains(warnings[0], "appendfsnyc") {
+		t.Errorf("expect unknown option warning, actual %v", warnings)
+	}
+	if errors := findingsOf(r, LevelError, "ports"); len(errors) != 1 {
+		t.Errorf("expect port in use, actual %v", errors)
+	}
+	if warnings := findingsOf(r, LevelWarn, "aof"); len(warnings) != 1 {
+		t.Errorf("expect truncated aof warning, actual %v", warnings)
+	}
+	if errors := findingsOf(r, LevelError, "rdb"); len(errors) != 1 || !strings.Contains(errors[0], "version 99") {
+		t.Errorf("expect unsupported rdb version, actual %v", errors)
+	}
+	if ok := findingsOf(r, LevelOK, "data"); len(ok) != 1 {
+		t.Errorf("expect writable data dir, actual %v", ok)
+	}
+	// 修复后不再有错误
+	_ = ln.Close()
+	_ = os.WriteFile(filepath.Join(dir, "dump.rdb"), []byte("REDIS0009\xff"), 0644)
+	if r = Run(confFile, nil); r.HasError() {
+		r.Print(os.Stderr)
+		t.Error("expect no error")
+	}
+	if r = Run(filepath.Join(dir, "missing.conf"), nil); !r.HasError() {
+		t.Error("expect error for missing config file")
+	}
+}
+
+func TestCheckRdbHeader(t *testing.T) {
+	if err := checkRdbHeader([]byte("REDIS0010")); err != nil {
+		t.Error(err)
+	}
+	for _, header := range []string{"", "REDIS", "REDIS00x1", "RDB000009", "REDIS0000", "REDIS0011"} {
+		if err := checkRdbHeader([]byte(header)); err == nil {
+			t.Errorf("expect error for %q", header)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+
+package doctor
+
+// openFilesLimit is not supported on this platform, the check is skipped
+func openFilesLimit() (soft uint64, hard uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd
+
+package doctor
+
+import "syscall"
+
+// openFilesLimit returns soft and hard limit of open files, RLIMIT_NOFILE
+func openFilesLimit() (soft uint64, hard uint64, ok bool) {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return 0, 0, false
+	}
+	return uint64(limit.Cur), uint64(limit.Max), true
+}
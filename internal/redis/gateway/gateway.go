@@ -0,0 +1,202 @@
+// Package gateway provides an HTTP+JSON gateway which maps simple REST calls onto the command engine
+package gateway
+
+import (
+	"Godis/interface/database"
+	"Godis/interface/redis"
+	"Godis/internal/redis/connection"
+	"Godis/lib/logger"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// keyPrefix is the path prefix of key resources, for example:
+//
+//	GET    /v1/keys/foo          -> GET foo
+//	PUT    /v1/keys/foo          -> SET foo value [PX ttl]
+//	DELETE /v1/keys/foo          -> DEL foo
+//	POST   /v1/keys/foo/expire   -> EXPIRE foo seconds
+//
+// query parameter db selects database, default 0
+const keyPrefix = "/v1/keys/"
+
+// Gateway serves HTTP requests with the given db
+type Gateway struct {
+	db     database.DB
+	server *http.Server
+}
+
+// setBody is the body of PUT request
+type setBody struct {
+	Value string `json:"value"`
+	// TTL in milliseconds, 0 means no expiration
+	TTL int64 `json:"ttl,omitempty"`
+}
+
+// expireBody is the body of expire request
+type expireBody struct {
+	Seconds int64 `json:"seconds"`
+}
+
+// response is the json body of all responses
+type response struct {
+	Result interface{} `json:"result"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// MakeGateway creates a gateway for db
+func MakeGateway(db database.DB) *Gateway {
+	g := &Gateway{
+		db: db,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(keyPrefix, g.handleKey)
+	g.server = &http.Server{
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	return g
+}
+
+// Serve accepts http requests on listener, blocks until Close
+func (g *Gateway) Serve(listener net.Listener) error {
+	logger.Info("http gateway listening on " + listener.Addr().String())
+	err := g.server.Serve(listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Close stops the gateway
+func (g *Gateway) Close() error {
+	return g.server.Close()
+}
+
+func (g *Gateway) handleKey(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, keyPrefix)
+	var key, action string
+	if strings.HasSuffix(path, "/expire") {
+		key, action = strings.TrimSuffix(path, "/expire"), "expire"
+	} else {
+		key = path
+	}
+	if key == "" {
+		writeJSON(w, http.StatusNotFound, &response{Error: "key is required"})
+		return
+	}
+
+	var cmdLine [][]byte
+	switch {
+	case action == "expire" && r.Method == http.MethodPost:
+		body := &expireBody{}
+		if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+			writeJSON(w, http.StatusBadRequest, &response{Error: "illegal body: " + err.Error()})
+			return
+		}
+		cmdLine = utils.ToCmdLine("EXPIRE", key, strconv.FormatInt(body.Seconds, 10))
+	case action == "" && r.Method == http.MethodGet:
+		cmdLine = utils.ToCmdLine("GET", key)
+	case action == "" && r.Method == http.MethodPut:
+		body := &setBody{}
+		if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+			writeJSON(w, http.StatusBadRequest, &response{Error: "illegal body: " + err.Error()})
+			return
+		}
+		cmdLine = utils.ToCmdLine("SET", key, body.Value)
+		if body.TTL > 0 {
+			cmdLine = append(cmdLine, []byte("PX"), []byte(strconv.FormatInt(body.TTL, 10)))
+		}
+	case action == "" && r.Method == http.MethodDelete:
+		cmdLine = utils.ToCmdLine("DEL", key)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, &response{Error: "method not allowed"})
+		return
+	}
+
+	conn, errResp, status := g.prepareConn(r)
+	if errResp != nil {
+		writeJSON(w, status, errResp)
+		return
+	}
+	reply := g.db.Exec(conn, cmdLine)
+	if r.Method == http.MethodGet {
+		if _, ok := reply.(*protocol.NullBulkReply); ok {
+			writeJSON(w, http.StatusNotFound, &response{Error: "key not found"})
+			return
+		}
+	}
+	writeReply(w, reply)
+}
+
+// prepareConn authenticates with password in Authorization header and selects db
+// auth is shared with the RESP server, see requirepass
+func (g *Gateway) prepareConn(r *http.Request) (redis.Connection, *response, int) {
+	conn := connection.NewFakeConn()
+	if password := bearerToken(r); password != "" {
+		reply := g.db.Exec(conn, utils.ToCmdLine("AUTH", password))
+		if protocol.IsErrorReply(reply) {
+			return nil, &response{Error: reply.(protocol.ErrorReply).Error()}, http.StatusUnauthorized
+		}
+	}
+	if dbIndex := r.URL.Query().Get("db"); dbIndex != "" {
+		reply := g.db.Exec(conn, utils.ToCmdLine("SELECT", dbIndex))
+		if protocol.IsErrorReply(reply) {
+			return nil, &response{Error: reply.(protocol.ErrorReply).Error()}, http.StatusBadRequest
+		}
+	}
+	return conn, nil, 0
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ""
+}
+
+// writeReply converts redis reply to json
+func writeReply(w http.ResponseWriter, reply redis.Reply) {
+	switch r := reply.(type) {
+	case protocol.ErrorReply:
+		status := http.StatusBadRequest
+		if strings.HasPrefix(r.Error(), "NOAUTH") {
+			status = http.StatusUnauthorized
+		}
+		writeJSON(w, status, &response{Error: r.Error()})
+	case *protocol.BulkReply:
+		writeJSON(w, http.StatusOK, &response{Result: string(r.Arg)})
+	case *protocol.NullBulkReply:
+		writeJSON(w, http.StatusOK, &response{Result: nil})
+	case *protocol.IntReply:
+		writeJSON(w, http.StatusOK, &response{Result: r.Code})
+	case *protocol.StatusReply:
+		writeJSON(w, http.StatusOK, &response{Result: r.Status})
+	case *protocol.OkReply:
+		writeJSON(w, http.StatusOK, &response{Result: "OK"})
+	case *protocol.MultiBulkReply:
+		result := make([]interface{}, len(r.Args))
+		for i, arg := range r.Args {
+			if arg != nil {
+				result[i] = string(arg)
+			}
+		}
+		writeJSON(w, http.StatusOK, &response{Result: result})
+	default:
+		writeJSON(w, http.StatusOK, &response{Result: string(reply.ToBytes())})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, resp *response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
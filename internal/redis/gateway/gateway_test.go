@@ -0,0 +1,90 @@
+package gateway
+
+import (
+	"Godis/internal/config"
+	database2 "Godis/internal/database"
+	"Godis/internal/redis/connection"
+	"Godis/lib/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGateway(t *testing.T) {
+	config.Properties.DataDictShards = 1024
+	defer func() {
+		config.Properties.DataDictShards = 0
+	}()
+	db := database2.NewStandaloneServer()
+	defer db.Close()
+	server := httptest.NewServer(MakeGateway(db).server.Handler)
+	defer server.Close()
+
+	do := func(method string, path string, body string) (int, *response) {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		result := &response{}
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, result
+	}
+
+	if status, resp := do(http.MethodPut, "/v1/keys/k", `{"value":"v"}`); status != http.StatusOK || resp.Result != "OK" {
+		t.Errorf("PUT: unexpected response %d %+v", status, resp)
+	}
+	if status, resp := do(http.MethodGet, "/v1/keys/k", ""); status != http.StatusOK || resp.Result != "v" {
+		t.Errorf("GET: unexpected response %d %+v", status, resp)
+	}
+	conn := connection.NewFakeConn()
+	if reply := string(db.Exec(conn, utils.ToCmdLine("TTL", "k")).ToBytes()); reply != ":-1\r\n" {
+		t.Errorf("expect no ttl, actual %q", reply)
+	}
+
+	if status, resp := do(http.MethodPut, "/v1/keys/t", `{"value":"v","ttl":100000}`); status != http.StatusOK || resp.Result != "OK" {
+		t.Errorf("PUT with ttl: unexpected response %d %+v", status, resp)
+	}
+	if reply := string(db.Exec(conn, utils.ToCmdLine("TTL", "t")).ToBytes()); reply != ":100\r\n" {
+		t.Errorf("expect ttl 100, actual %q", reply)
+	}
+	if status, resp := do(http.MethodPost, "/v1/keys/k/expire", `{"seconds":50}`); status != http.StatusOK || resp.Result != float64(1) {
+		t.Errorf("expire: unexpected response %d %+v", status, resp)
+	}
+
+	if status, resp := do(http.MethodDelete, "/v1/keys/k", ""); status != http.StatusOK || resp.Result != float64(1) {
+		t.Errorf("DELETE: unexpected response %d %+v", status, resp)
+	}
+	if status, resp := do(http.MethodGet, "/v1/keys/k", ""); status != http.StatusNotFound || resp.Error == "" {
+		t.Errorf("GET deleted key: unexpected response %d %+v", status, resp)
+	}
+	if status, _ := do(http.MethodGet, "/v1/keys/", ""); status != http.StatusNotFound {
+		t.Errorf("expect 404 without key, actual %d", status)
+	}
+
+	for _, path := range []string{"/v1/keys/k", "/v1/keys/k/expire"} {
+		method := http.MethodPut
+		if strings.HasSuffix(path, "/expire") {
+			method = http.MethodPost
+		}
+		if status, resp := do(method, path, `{"value":`); status != http.StatusBadRequest || resp.Error == "" {
+			t.Errorf("%s %s with bad json: unexpected response %d %+v", method, path, status, resp)
+		}
+	}
+	if status, _ := do(http.MethodPatch, "/v1/keys/k", ""); status != http.StatusMethodNotAllowed {
+		t.Errorf("expect 405, actual %d", status)
+	}
+	if status, resp := do(http.MethodGet, "/v1/keys/k?db=100", ""); status != http.StatusBadRequest || resp.Error == "" {
+		t.Errorf("illegal db: unexpected response %d %+v", status, resp)
+	}
+}
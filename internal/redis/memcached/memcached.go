@@ -0,0 +1,456 @@
+// Package memcached provides an adapter speaking memcached text protocol,
+// commands are translated into redis commands and executed by the same db engine
+package memcached
+
+import (
+	"Godis/interface/database"
+	"Godis/interface/redis"
+	"Godis/internal/redis/connection"
+	"Godis/lib/logger"
+	"Godis/lib/utils"
+	"Godis/redis/protocol"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// maxKeyLen is the max length of key allowed by memcached
+	maxKeyLen = 250
+	// maxLineLen limits length of command line
+	maxLineLen = 2048
+	// maxValueLen limits size of a single item, same as default item size limit of memcached
+	maxValueLen = 1 << 20
+	// relativeExpireLimit exptime larger than 30 days is treated as unix timestamp
+	relativeExpireLimit = 60 * 60 * 24 * 30
+	// maxIncrRetry limits retry times of incr/decr when key is modified concurrently
+	maxIncrRetry = 16
+	// flagsKeyPrefix is the prefix of keys storing non-zero client flags
+	// value is stored as plain string so that redis clients could read it directly
+	flagsKeyPrefix = "memcached:flags:"
+)
+
+const (
+	replyStored       = "STORED\r\n"
+	replyDeleted      = "DELETED\r\n"
+	replyNotFound     = "NOT_FOUND\r\n"
+	replyEnd          = "END\r\n"
+	replyError        = "ERROR\r\n"
+	replyVersion      = "VERSION godis\r\n"
+	clientErrorPrefix = "CLIENT_ERROR "
+	serverErrorPrefix = "SERVER_ERROR "
+)
+
+var errNotNumeric = errors.New("cannot increment or decrement non-numeric value")
+
+// Handler implements tcp.Handler and serves memcached clients
+type Handler struct {
+	db          database.DB
+	requirePass string
+	activeConn  sync.Map // net.Conn -> placeholder
+	closing     atomic.Bool
+}
+
+// MakeHandler creates a memcached handler, memcached text protocol has no authentication,
+// so handler authenticates with requirePass on behalf of clients
+func MakeHandler(db database.DB, requirePass string) *Handler {
+	return &Handler{
+		db:          db,
+		requirePass: requirePass,
+	}
+}
+
+// session is the state of a memcached client
+type session struct {
+	h      *Handler
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+	// client keeps selected db and transaction state when executing redis commands
+	client redis.Connection
+}
+
+// Handle serves a memcached client
+func (h *Handler) Handle(ctx context.Context, conn net.Conn) {
+	if h.closing.Load() {
+		_ = conn.Close()
+		return
+	}
+	h.activeConn.Store(conn, struct{}{})
+	defer func() {
+		h.activeConn.Delete(conn)
+		_ = conn.Close()
+	}()
+	s := &session{
+		h:      h,
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriter(conn),
+		client: connection.NewFakeConn(),
+	}
+	if h.requirePass != "" {
+		reply := h.db.Exec(s.client, utils.ToCmdLine("AUTH", h.requirePass))
+		if protocol.IsErrorReply(reply) {
+			logger.Error("memcached adapter auth failed: " + reply.(protocol.ErrorReply).Error())
+			return
+		}
+	}
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			if err != io.EOF {
+				logger.Info("memcached connection closed: " + err.Error())
+			}
+			return
+		}
+		if !s.dispatch(line) {
+			_ = s.writer.Flush()
+			return
+		}
+		// flush after all pipelined commands handled
+		if s.reader.Buffered() == 0 {
+			if err := s.writer.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Close stops handler and closes all clients
+func (h *Handler) Close() error {
+	h.closing.Store(true)
+	h.activeConn.Range(func(key, value any) bool {
+		_ = key.(net.Conn).Close()
+		return true
+	})
+	return nil
+}
+
+func (s *session) readLine() (string, error) {
+	line, err := s.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull || len(line) > maxLineLen {
+		return "", errors.New("command line too long")
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// dispatch executes a command line, returns false if connection should be closed
+func (s *session) dispatch(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		s.writeString(replyError)
+		return true
+	}
+	switch strings.ToLower(fields[0]) {
+	case "get", "gets":
+		s.handleGet(fields[1:])
+	case "set":
+		return s.handleSet(fields[1:])
+	case "delete":
+		s.handleDelete(fields[1:])
+	case "incr":
+		s.handleIncr(fields[1:], true)
+	case "decr":
+		s.handleIncr(fields[1:], false)
+	case "version":
+		s.writeString(replyVersion)
+	case "quit":
+		return false
+	default:
+		s.writeString(replyError)
+	}
+	return true
+}
+
+// handleGet: get <key>*
+func (s *session) handleGet(keys []string) {
+	if len(keys) == 0 {
+		s.writeString(replyError)
+		return
+	}
+	for _, key := range keys {
+		if !validKey(key) {
+			s.clientError("bad command line format")
+			return
+		}
+	}
+	for _, key := range keys {
+		value, ok, err := s.getString(key)
+		if err != nil {
+			s.serverError(err)
+			return
+		}
+		if !ok {
+			continue
+		}
+		flags, _, err := s.getString(flagsKeyPrefix + key)
+		if err != nil {
+			s.serverError(err)
+			return
+		}
+		if len(flags) == 0 {
+			flags = []byte("0")
+		}
+		s.writeString("VALUE " + key + " " + string(flags) + " " + strconv.Itoa(len(value)) + "\r\n")
+		_, _ = s.writer.Write(value)
+		s.writeString("\r\n")
+	}
+	s.writeString(replyEnd)
+}
+
+// handleSet: set <key> <flags> <exptime> <bytes> [noreply]\r\n<data>\r\n
+func (s *session) handleSet(args []string) bool {
+	if len(args) != 4 && len(args) != 5 {
+		s.writeString(replyError)
+		return true
+	}
+	key := args[0]
+	flags, flagsErr := strconv.ParseUint(args[1], 10, 32)
+	exptime, expErr := strconv.ParseInt(args[2], 10, 64)
+	size, sizeErr := strconv.Atoi(args[3])
+	if !validKey(key) || flagsErr != nil || expErr != nil || sizeErr != nil || size < 0 {
+		s.clientError("bad command line format")
+		return true
+	}
+	if size > maxValueLen {
+		// data block can not be skipped safely, close connection like memcached does
+		s.serverError(errors.New("object too large for cache"))
+		return false
+	}
+	noreply := len(args) == 5 && args[4] == "noreply"
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(s.reader, data); err != nil {
+		return false
+	}
+	if !bytes.HasSuffix(data, []byte("\r\n")) {
+		s.clientError("bad data chunk")
+		return false
+	}
+	data = data[:size]
+
+	// set value, flags and expiration in a transaction so that they are always consistent
+	cmdLines := [][][]byte{
+		utils.ToCmdLine3("SET", []byte(key), data),
+	}
+	flagsKey := flagsKeyPrefix + key
+	if flags != 0 {
+		cmdLines = append(cmdLines, utils.ToCmdLine("SET", flagsKey, strconv.FormatUint(flags, 10)))
+	} else {
+		cmdLines = append(cmdLines, utils.ToCmdLine("DEL", flagsKey))
+	}
+	if expireAt, ok := toExpireAt(exptime); ok {
+		at := strconv.FormatInt(expireAt.UnixMilli(), 10)
+		cmdLines = append(cmdLines,
+			utils.ToCmdLine("PEXPIREAT", key, at),
+			utils.ToCmdLine("PEXPIREAT", flagsKey, at),
+		)
+	}
+	if _, err := s.multi(cmdLines); err != nil {
+		s.serverError(err)
+		return true
+	}
+	if !noreply {
+		s.writeString(replyStored)
+	}
+	return true
+}
+
+// handleDelete: delete <key> [0] [noreply]
+func (s *session) handleDelete(args []string) {
+	if len(args) == 0 || len(args) > 3 || !validKey(args[0]) {
+		s.writeString(replyError)
+		return
+	}
+	key := args[0]
+	noreply := args[len(args)-1] == "noreply"
+	results, err := s.multi([][][]byte{
+		utils.ToCmdLine("DEL", key),
+		utils.ToCmdLine("DEL", flagsKeyPrefix+key),
+	})
+	if err != nil {
+		s.serverError(err)
+		return
+	}
+	if noreply {
+		return
+	}
+	if n, ok := results[0].(*protocol.IntReply); ok && n.Code > 0 {
+		s.writeString(replyDeleted)
+	} else {
+		s.writeString(replyNotFound)
+	}
+}
+
+// handleIncr: incr|decr <key> <value> [noreply]
+// value is treated as 64-bit unsigned integer, incr wraps around on overflow and decr stops at 0
+func (s *session) handleIncr(args []string, incr bool) {
+	if len(args) != 2 && len(args) != 3 {
+		s.writeString(replyError)
+		return
+	}
+	key := args[0]
+	delta, err := strconv.ParseUint(args[1], 10, 64)
+	if !validKey(key) || err != nil {
+		s.clientError("invalid numeric delta argument")
+		return
+	}
+	noreply := len(args) == 3 && args[2] == "noreply"
+	var result []byte
+	var found bool
+	for i := 0; i < maxIncrRetry; i++ {
+		result, found, err = s.tryIncr(key, delta, incr)
+		if err != nil || !found || result != nil {
+			break
+		}
+		// result is nil means key was modified by others, retry
+	}
+	switch {
+	case errors.Is(err, errNotNumeric):
+		s.clientError(err.Error())
+	case err != nil:
+		s.serverError(err)
+	case noreply:
+	case !found:
+		s.writeString(replyNotFound)
+	case result == nil:
+		s.serverError(errors.New("too many concurrent modifications"))
+	default:
+		s.writeString(string(result) + "\r\n")
+	}
+}
+
+// tryIncr computes new value with optimistic lock, returns nil result if watched key changed
+func (s *session) tryIncr(key string, delta uint64, incr bool) ([]byte, bool, error) {
+	if reply := s.h.db.Exec(s.client, utils.ToCmdLine("WATCH", key)); protocol.IsErrorReply(reply) {
+		return nil, false, reply.(protocol.ErrorReply)
+	}
+	value, ok, err := s.getString(key)
+	if err != nil || !ok {
+		s.client.SetMultiState(false) // clean watching keys
+		return nil, ok, err
+	}
+	current, parseErr := strconv.ParseUint(string(value), 10, 64)
+	if parseErr != nil {
+		s.client.SetMultiState(false)
+		return nil, true, errNotNumeric
+	}
+	if incr {
+		current += delta
+	} else if delta > current {
+		current = 0
+	} else {
+		current -= delta
+	}
+	newValue := strconv.FormatUint(current, 10)
+	ttlReply := s.h.db.Exec(s.client, utils.ToCmdLine("PTTL", key))
+	cmdLines := [][][]byte{
+		utils.ToCmdLine("SET", key, newValue),
+	}
+	// SET removes expiration, restore it
+	if ttl, ok := ttlReply.(*protocol.IntReply); ok && ttl.Code > 0 {
+		cmdLines = append(cmdLines, utils.ToCmdLine("PEXPIRE", key, strconv.FormatInt(ttl.Code, 10)))
+	}
+	results, err := s.multi(cmdLines)
+	if err != nil {
+		return nil, true, err
+	}
+	if results == nil {
+		return nil, true, nil
+	}
+	return []byte(newValue), true, nil
+}
+
+// multi executes command lines in a transaction
+// returns nil results if transaction aborted because watching keys changed
+func (s *session) multi(cmdLines [][][]byte) ([]redis.Reply, error) {
+	db := s.h.db
+	if reply := db.Exec(s.client, utils.ToCmdLine("MULTI")); protocol.IsErrorReply(reply) {
+		return nil, reply.(protocol.ErrorReply)
+	}
+	for _, cmdLine := range cmdLines {
+		if reply := db.Exec(s.client, cmdLine); protocol.IsErrorReply(reply) {
+			db.Exec(s.client, utils.ToCmdLine("DISCARD"))
+			return nil, reply.(protocol.ErrorReply)
+		}
+	}
+	reply := db.Exec(s.client, utils.ToCmdLine("EXEC"))
+	switch r := reply.(type) {
+	case protocol.ErrorReply:
+		return nil, r
+	case *protocol.EmptyMultiBulkReply:
+		return nil, nil
+	case *protocol.MultiRawReply:
+		for _, result := range r.Replies {
+			if protocol.IsErrorReply(result) {
+				return nil, result.(protocol.ErrorReply)
+			}
+		}
+		return r.Replies, nil
+	}
+	return nil, errors.New("unexpected reply of exec")
+}
+
+// getString returns string value of key
+func (s *session) getString(key string) ([]byte, bool, error) {
+	reply := s.h.db.Exec(s.client, utils.ToCmdLine("GET", key))
+	switch r := reply.(type) {
+	case *protocol.BulkReply:
+		return r.Arg, true, nil
+	case *protocol.NullBulkReply:
+		return nil, false, nil
+	case protocol.ErrorReply:
+		return nil, false, r
+	}
+	return nil, false, errors.New("unexpected reply of get")
+}
+
+// toExpireAt converts memcached exptime to expire time, returns false if item never expires
+// exptime larger than 30 days is unix timestamp, negative exptime means expired immediately
+func toExpireAt(exptime int64) (time.Time, bool) {
+	switch {
+	case exptime == 0:
+		return time.Time{}, false
+	case exptime < 0:
+		return time.Now(), true
+	case exptime > relativeExpireLimit:
+		return time.Unix(exptime, 0), true
+	default:
+		return time.Now().Add(time.Duration(exptime) * time.Second), true
+	}
+}
+
+// validKey checks key length and control characters
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > maxKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *session) writeString(str string) {
+	_, _ = s.writer.WriteString(str)
+}
+
+func (s *session) clientError(msg string) {
+	s.writeString(clientErrorPrefix + msg + "\r\n")
+}
+
+func (s *session) serverError(err error) {
+	s.writeString(serverErrorPrefix + err.Error() + "\r\n")
+}
@@ -0,0 +1,61 @@
+package memcached
+
+import (
+	"Godis/internal/config"
+	database2 "Godis/internal/database"
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	config.Properties = &config.ServerProperties{
+		Databases: 16,
+	}
+	h := MakeHandler(database2.NewStandaloneServer(), "")
+	server, client := net.Pipe()
+	go h.Handle(context.Background(), server)
+	defer func() {
+		_ = h.Close()
+	}()
+	reader := bufio.NewReader(client)
+
+	cases := []struct {
+		request  string
+		response string
+	}{
+		{"get foo\r\n", "END\r\n"},
+		{"set foo 5 0 3\r\nbar\r\n", "STORED\r\n"},
+		{"get foo missing\r\n", "VALUE foo 5 3\r\nbar\r\nEND\r\n"},
+		{"incr foo 1\r\n", "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n"},
+		{"set n 0 100 2\r\n10\r\n", "STORED\r\n"},
+		{"incr n 5\r\n", "15\r\n"},
+		{"decr n 20\r\n", "0\r\n"},
+		{"incr missing 1\r\n", "NOT_FOUND\r\n"},
+		{"set n 0 0 20\r\n18446744073709551615\r\n", "STORED\r\n"},
+		{"incr n 2\r\n", "1\r\n"},
+		{"delete foo\r\n", "DELETED\r\n"},
+		{"delete foo\r\n", "NOT_FOUND\r\n"},
+		{"set foo 0 -1 3 noreply\r\nbar\r\n", ""},
+		{"get foo\r\n", "END\r\n"},
+		{"unknown\r\n", "ERROR\r\n"},
+	}
+	for _, c := range cases {
+		// net.Pipe is synchronous, write returns after handler read the request
+		if _, err := client.Write([]byte(c.request)); err != nil {
+			t.Fatal(err)
+		}
+		if c.response == "" {
+			continue
+		}
+		buf := make([]byte, len(c.response))
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			t.Fatal(err)
+		}
+		if string(buf) != c.response {
+			t.Errorf("request %q: expect %q, actual %q", c.request, c.response, string(buf))
+		}
+	}
+}
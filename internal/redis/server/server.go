@@ -1,12 +1,12 @@
 package server
 
 import (
-	"Godis/cluster"
-	"Godis/config"
-	database2 "Godis/database"
 	"Godis/interface/database"
+	"Godis/internal/cluster"
+	"Godis/internal/config"
+	database2 "Godis/internal/database"
+	"Godis/internal/redis/connection"
 	"Godis/lib/logger"
-	"Godis/redis/connection"
 	"Godis/redis/parser"
 	"Godis/redis/protocol"
 	"context"
@@ -37,11 +37,21 @@ func MakeHandler() *Handler {
 	}
 }
 
+// GetDB returns the storage engine, used by other frontends such as http gateway
+func (h *Handler) GetDB() database.DB {
+	return h.db
+}
+
 // closeClient 对Connection,db和activeConn分别关闭
+// 只有从 activeConn 中删除连接的一方关闭它：Handler.Close 关闭的连接会使 Handle 读取失败并再次调用 closeClient，
+// Connection.Close 会将对象放回缓存池，不能重复关闭
 func (h *Handler) closeClient(client *connection.Connection) {
-	_ = client.Close()
+	if _, ok := h.activeConn.LoadAndDelete(client); !ok {
+		return
+	}
+	// Close 会清空订阅的频道并将连接放回缓存池，需要先退订
 	h.db.AfterClientClose(client)
-	h.activeConn.Delete(client)
+	_ = client.Close()
 }
 
 // Handle receives and executes redis commands
@@ -98,6 +108,8 @@ func (h *Handler) Handle(ctx context.Context, conn net.Conn) {
 			_, _ = client.Write([]byte("-ERR unknown\r\n"))
 		}
 	}
+	// 解析器遇到无法恢复的错误后停止读取，如 inline 命令过长
+	h.closeClient(client)
 }
 
 // Close stops handler
@@ -106,7 +118,9 @@ func (h *Handler) Close() error {
 	h.closing.Store(true)
 	h.activeConn.Range(func(key any, val any) bool {
 		client := key.(*connection.Connection)
-		_ = client.Close()
+		if _, ok := h.activeConn.LoadAndDelete(client); ok {
+			_ = client.Close()
+		}
 		return true
 	})
 	h.db.Close()
@@ -0,0 +1,225 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// opcodes defined in RFC 6455 section 5.2
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+const (
+	finBit  = 0x80
+	maskBit = 0x80
+	// maxFrameSize limits payload of a single frame to protect server from malicious clients
+	maxFrameSize = 64 << 20
+	// maxControlSize is the max payload of control frames
+	maxControlSize = 125
+)
+
+const (
+	closeNormal        = 1000
+	closeProtocolError = 1002
+	closeTooBig        = 1009
+)
+
+var errProtocol = errors.New("websocket protocol error")
+
+// Conn tunnels a byte stream over websocket messages and implements net.Conn,
+// so that it could be served by the same handler as tcp connections.
+// Payload of text and binary messages are concatenated into the stream,
+// data written to Conn is sent as one binary message per Write
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// pending payload of current data frame
+	remaining int64
+	maskKey   [4]byte
+	maskPos   int
+
+	// lock while writing frames, pong replies and handler responses may be written concurrently
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+}
+
+func newConn(conn net.Conn, reader *bufio.Reader) *Conn {
+	return &Conn{
+		conn:   conn,
+		reader: reader,
+	}
+}
+
+// Read reads payload of data frames, control frames are handled internally
+// returns io.EOF after client sent close frame
+func (c *Conn) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		if err := c.nextDataFrame(); err != nil {
+			return 0, err
+		}
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.reader.Read(p)
+	for i := 0; i < n; i++ {
+		p[i] ^= c.maskKey[c.maskPos&3]
+		c.maskPos++
+	}
+	c.remaining -= int64(n)
+	return n, err
+}
+
+// nextDataFrame reads frame headers until a data frame comes, remaining is set to its payload length
+func (c *Conn) nextDataFrame() error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return err
+	}
+	opcode := header[0] & 0x0f
+	// client must mask all frames it sends to server
+	if header[1]&maskBit == 0 {
+		c.closeWithCode(closeProtocolError)
+		return errProtocol
+	}
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		buf := make([]byte, 2)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return err
+		}
+		length = int64(binary.BigEndian.Uint16(buf))
+	case 127:
+		buf := make([]byte, 8)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return err
+		}
+		length = int64(binary.BigEndian.Uint64(buf))
+	}
+	if length < 0 || length > maxFrameSize {
+		c.closeWithCode(closeTooBig)
+		return errProtocol
+	}
+	if _, err := io.ReadFull(c.reader, c.maskKey[:]); err != nil {
+		return err
+	}
+	c.maskPos = 0
+
+	switch opcode {
+	case opContinuation, opText, opBinary:
+		c.remaining = length
+		return nil
+	case opClose, opPing, opPong:
+		if length > maxControlSize || header[0]&finBit == 0 {
+			c.closeWithCode(closeProtocolError)
+			return errProtocol
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.reader, payload); err != nil {
+			return err
+		}
+		for i := range payload {
+			payload[i] ^= c.maskKey[i&3]
+		}
+		switch opcode {
+		case opClose:
+			c.closeWithCode(closeNormal)
+			return io.EOF
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		c.closeWithCode(closeProtocolError)
+		return errProtocol
+	}
+}
+
+// Write sends p as a binary message
+// binary is used since RESP payload may not be valid utf-8 required by text message
+func (c *Conn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(opBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeFrame writes a single unmasked frame, server must not mask frames
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	var header []byte
+	length := len(payload)
+	switch {
+	case length < 126:
+		header = []byte{finBit | opcode, byte(length)}
+	case length <= 0xffff:
+		header = make([]byte, 4)
+		header[0], header[1] = finBit|opcode, 126
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = make([]byte, 10)
+		header[0], header[1] = finBit|opcode, 127
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	buffers := net.Buffers{header, payload}
+	_, err := buffers.WriteTo(c.conn)
+	return err
+}
+
+// closeWithCode sends close frame and closes underlying connection, only the first call takes effect
+func (c *Conn) closeWithCode(code uint16) {
+	c.closeOnce.Do(func() {
+		payload := make([]byte, 2)
+		binary.BigEndian.PutUint16(payload, code)
+		_ = c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		_ = c.writeFrame(opClose, payload)
+		_ = c.conn.Close()
+	})
+}
+
+// Close sends close frame and closes the connection
+func (c *Conn) Close() error {
+	c.closeWithCode(closeNormal)
+	return nil
+}
+
+// LocalAddr returns local address of underlying connection
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr returns remote address of underlying connection
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetDeadline sets deadline of underlying connection
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets read deadline of underlying connection
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets write deadline of underlying connection
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
@@ -0,0 +1,158 @@
+// Package websocket provides a websocket endpoint tunneling RESP frames,
+// so that browser based admin UI could issue commands and subscribe channels directly
+package websocket
+
+import (
+	"Godis/interface/tcp"
+	"Godis/lib/logger"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Path is the url path of websocket endpoint
+const Path = "/ws"
+
+// subprotocol is echoed back if client requested it in Sec-WebSocket-Protocol
+const subprotocol = "resp"
+
+// acceptGUID is the magic string defined in RFC 6455 for computing Sec-WebSocket-Accept
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Bridge upgrades http requests to websocket and serves them with the redis handler.
+// Every websocket connection is handled exactly like a tcp client, thus AUTH, SELECT, MULTI and
+// SUBSCRIBE work the same way. Browser should send RESP arrays or inline commands ending with CRLF,
+// replies and published messages are sent back as binary messages
+type Bridge struct {
+	handler tcp.Handler
+	server  *http.Server
+	// allowedOrigins is the whitelist of Origin header, empty means same origin only
+	allowedOrigins []string
+}
+
+// MakeBridge creates a bridge serving websocket connections with handler
+func MakeBridge(handler tcp.Handler, allowedOrigins []string) *Bridge {
+	b := &Bridge{
+		handler:        handler,
+		allowedOrigins: allowedOrigins,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(Path, b.handleUpgrade)
+	b.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return b
+}
+
+// Serve accepts http requests on listener, blocks until Close
+func (b *Bridge) Serve(listener net.Listener) error {
+	logger.Info("websocket bridge listening on " + listener.Addr().String())
+	err := b.server.Serve(listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Close stops accepting new websocket connections,
+// established connections are closed by handler like tcp clients
+func (b *Bridge) Close() error {
+	return b.server.Close()
+}
+
+func (b *Bridge) handleUpgrade(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+	// 浏览器会自动携带 Origin，校验 Origin 防止其他站点的页面跨站连接
+	if !b.checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		logger.Error("websocket hijack failed: " + err.Error())
+		return
+	}
+	// clear deadlines set by http server
+	_ = conn.SetDeadline(time.Time{})
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + computeAcceptKey(key) + "\r\n"
+	if headerContains(r.Header, "Sec-WebSocket-Protocol", subprotocol) {
+		response += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	response += "\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		_ = conn.Close()
+		return
+	}
+	// 握手完成后，连接交给 redis handler 处理，与 tcp 客户端完全一致
+	b.handler.Handle(context.Background(), newConn(conn, rw.Reader))
+}
+
+// checkOrigin allows requests without Origin header (non-browser clients),
+// requests whose origin is in whitelist, or same origin requests if whitelist is empty
+func (b *Bridge) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(b.allowedOrigins) > 0 {
+		for _, allowed := range b.allowedOrigins {
+			if allowed == "*" || strings.EqualFold(allowed, origin) {
+				return true
+			}
+		}
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func computeAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContains checks whether comma separated header contains token, case insensitive
+func headerContains(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
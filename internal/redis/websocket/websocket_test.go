@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"Godis/internal/tcp"
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestBridge(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge := MakeBridge(tcp.MakeEchoHandler(), nil)
+	go func() {
+		_ = bridge.Serve(listener)
+	}()
+	defer func() {
+		_ = bridge.Close()
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	_, err = conn.Write([]byte("GET " + Path + " HTTP/1.1\r\n" +
+		"Host: " + listener.Addr().String() + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expect 101, actual %d", resp.StatusCode)
+	}
+	// example from RFC 6455 section 1.3
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("wrong accept key: %s", accept)
+	}
+
+	// send a masked text frame
+	msg := "hello\n"
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{finBit | opText, maskBit | byte(len(msg))}
+	frame = append(frame, mask...)
+	for i := 0; i < len(msg); i++ {
+		frame = append(frame, msg[i]^mask[i%4])
+	}
+	if _, err = conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	header := make([]byte, 2)
+	if _, err = io.ReadFull(reader, header); err != nil {
+		t.Fatal(err)
+	}
+	if header[0] != finBit|opBinary || int(header[1]) != len(msg) {
+		t.Fatalf("unexpected frame header: %v", header)
+	}
+	payload := make([]byte, len(msg))
+	if _, err = io.ReadFull(reader, payload); err != nil {
+		t.Fatal(err)
+	}
+	if string(payload) != msg {
+		t.Fatalf("expect %q, actual %q", msg, string(payload))
+	}
+
+	// cross origin request should be rejected
+	req, _ := http.NewRequest(http.MethodGet, "http://"+listener.Addr().String()+Path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Origin", "http://evil.example.com")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expect cross origin request rejected, actual %d", resp.StatusCode)
+	}
+}
@@ -1,8 +1,9 @@
 package tcp
 
 import (
-	"Godis/wait"
+	"Godis/lib/sync/wait"
 	"bufio"
+	"context"
 	"io"
 	"log"
 	"net"
@@ -34,7 +35,7 @@ func MakeEchoHandler() *EchoHandler {
 }
 
 // Handle 对于结构体EchoHandler实现Handle接口中的方法
-func (h *EchoHandler) Handle(conn net.Conn) {
+func (h *EchoHandler) Handle(ctx context.Context, conn net.Conn) {
 	// 如果服务器的状态变成关闭了，Handle不再处理新进连接
 	if h.closing.Load() == true {
 		err := conn.Close()
@@ -58,6 +59,7 @@ func (h *EchoHandler) Handle(conn net.Conn) {
 				log.Println("connection close")
 				// 从服务器上删除此连接
 				h.activeConn.Delete(client)
+				return
 			} else {
 				log.Println(err)
 				h.activeConn.Delete(client)
@@ -0,0 +1,106 @@
+package tcp
+
+import (
+	"bufio"
+	"math/rand"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestListenAndServe(t *testing.T) {
+	var err error
+	closeChan := make(chan struct{})
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	addr := listener.Addr().String()
+	go ListenAndServe(listener, MakeEchoHandler(), closeChan)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for i := 0; i < 10; i++ {
+		val := strconv.Itoa(rand.Int())
+		_, err = conn.Write([]byte(val + "\n"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		bufReader := bufio.NewReader(conn)
+		line, _, err := bufReader.ReadLine()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if string(line) != val {
+			t.Error("get wrong response")
+			return
+		}
+	}
+	_ = conn.Close()
+	for i := 0; i < 5; i++ {
+		// create idle connection
+		_, _ = net.Dial("tcp", addr)
+	}
+	closeChan <- struct{}{}
+	time.Sleep(time.Second)
+}
+
+func TestListenAndServeMulti(t *testing.T) {
+	closeChan := make(chan struct{})
+	var listeners []net.Listener
+	for i := 0; i < 2; i++ {
+		listener, err := net.Listen("tcp", ":0")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		listeners = append(listeners, listener)
+	}
+	go ListenAndServeMulti(listeners, MakeEchoHandler(), closeChan)
+
+	for _, listener := range listeners {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, err = conn.Write([]byte("ping\n"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		line, _, err := bufio.NewReader(conn).ReadLine()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if string(line) != "ping" {
+			t.Error("get wrong response")
+		}
+		_ = conn.Close()
+	}
+	for _, listener := range listeners {
+		addr := listener.Addr().String()
+		found := false
+		for _, stats := range GetListenerStats() {
+			if stats.Address == addr {
+				found = true
+				if stats.Accepted != 1 {
+					t.Errorf("listener %s expect 1 accepted connection, actual %d", addr, stats.Accepted)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("stats of listener %s not found", addr)
+		}
+	}
+	closeChan <- struct{}{}
+	time.Sleep(time.Second)
+}
@@ -0,0 +1,227 @@
+package tcp
+
+import (
+	"Godis/interface/tcp"
+	"Godis/lib/logger"
+	"Godis/lib/systemd"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Config stores tcp server properties
+type Config struct {
+	Address    string        `yaml:"address"`
+	MaxConnect uint32        `yaml:"max-connect"`
+	Timeout    time.Duration `yaml:"timeout"`
+	// TLSAddress enables a TLS listener besides the plaintext one if not empty
+	TLSAddress string      `yaml:"tls-address"`
+	TLSConfig  *tls.Config `yaml:"-"`
+}
+
+// ClientCounter Record the number of clients in the current Godis server
+var ClientCounter int32
+
+// ListenerStats records connections accepted by a listener
+type ListenerStats struct {
+	Address  string
+	TLS      bool
+	Active   int32 // alive connections
+	Accepted int64 // total accepted connections
+}
+
+var (
+	listenerStatsMu sync.Mutex
+	listenerStats   []*ListenerStats
+)
+
+// GetListenerStats returns a snapshot of stats of all listeners
+func GetListenerStats() []ListenerStats {
+	listenerStatsMu.Lock()
+	defer listenerStatsMu.Unlock()
+	result := make([]ListenerStats, 0, len(listenerStats))
+	for _, stats := range listenerStats {
+		result = append(result, ListenerStats{
+			Address:  stats.Address,
+			TLS:      stats.TLS,
+			Active:   atomic.LoadInt32(&stats.Active),
+			Accepted: atomic.LoadInt64(&stats.Accepted),
+		})
+	}
+	return result
+}
+
+func registerListener(listener net.Listener) *ListenerStats {
+	_, isTLS := listener.(*tlsListener)
+	stats := &ListenerStats{
+		Address: listener.Addr().String(),
+		TLS:     isTLS,
+	}
+	listenerStatsMu.Lock()
+	listenerStats = append(listenerStats, stats)
+	listenerStatsMu.Unlock()
+	return stats
+}
+
+// tlsListener marks a listener serving TLS
+type tlsListener struct {
+	net.Listener
+}
+
+// listen opens all listeners in cfg, plaintext listener comes first
+func listen(cfg *Config) ([]net.Listener, error) {
+	var listeners []net.Listener
+	closeAll := func() {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}
+	if cfg.Address != "" {
+		listener, err := net.Listen("tcp", cfg.Address)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+		logger.Info(fmt.Sprintf("bind: %s, start listening...", cfg.Address))
+	}
+	if cfg.TLSAddress != "" {
+		if cfg.TLSConfig == nil {
+			closeAll()
+			return nil, fmt.Errorf("tls config is required by tls listener %s", cfg.TLSAddress)
+		}
+		listener, err := tls.Listen("tcp", cfg.TLSAddress, cfg.TLSConfig)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		listeners = append(listeners, &tlsListener{Listener: listener})
+		logger.Info(fmt.Sprintf("bind tls: %s, start listening...", cfg.TLSAddress))
+	}
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("no address to listen")
+	}
+	return listeners, nil
+}
+
+// ListenAndServeWithSignal
+// 功能：监听关闭信号，调用listenAndServe函数实现监听
+// 输入：端口号，handler,handler是redis/server/server.go中的结构体，其实现了tcp.Handler接口，并用于Redis服务器连接处理
+func ListenAndServeWithSignal(cfg *Config, handler tcp.Handler) error {
+	// 创建两个通道，closeChan用于将关闭服务器的通知传递给listenAndServe, sigChan用于监听系统关闭通知
+	// 当程序需要在不同的 goroutine 之间进行通信，但又不需要传递具体的数据时，使用空结构体的通道是一种有效的方式。
+	closeChan := make(chan struct{})
+	sigChan := make(chan os.Signal, 1)
+	// SIGUP 终端挂起或控制进程终止; SIGQUIT 退出进程，并生成 core 文件; SIGTERM 终止进程;SIGINT 中断进程;
+	signal.Notify(sigChan, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT)
+	// 创建一个匿名函数的协程，用于监听系统信号，若系统信号为上述四种信号，则通知服务器关闭
+	// 既然signal.Notify()函数中已经指定了监听的信号，下面的case判断是否必要呢
+	go func() {
+		sig := <-sigChan
+		switch sig {
+		case syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT:
+			notifySystemd(systemd.Stopping)
+			closeChan <- struct{}{}
+		}
+	}()
+	// 开启listener, 通过 systemd socket activation 启动时直接使用其传入的 listener
+	listeners, err := systemd.Listeners()
+	if err == nil && len(listeners) == 0 {
+		listeners, err = listen(cfg)
+	} else if err == nil {
+		logger.Info(fmt.Sprintf("use %d listeners passed by systemd", len(listeners)))
+	}
+	if err != nil {
+		log.Println("Listen start error")
+		log.Println(err)
+		return err
+	}
+	notifySystemd(systemd.Ready)
+	ListenAndServeMulti(listeners, handler, closeChan)
+	return nil
+}
+
+// notifySystemd sends state to systemd if the server is supervised by it
+func notifySystemd(state string) {
+	if _, err := systemd.Notify(state); err != nil {
+		logger.Warn("notify systemd failed: " + err.Error())
+	}
+}
+
+func ListenAndServe(listener net.Listener, handler tcp.Handler, closeChan <-chan struct{}) {
+	ListenAndServeMulti([]net.Listener{listener}, handler, closeChan)
+}
+
+// ListenAndServeMulti serves all listeners with one handler, and shuts them down together
+func ListenAndServeMulti(listeners []net.Listener, handler tcp.Handler, closeChan <-chan struct{}) {
+	closeListeners := func() {
+		for _, listener := range listeners {
+			_ = listener.Close()
+		}
+	}
+	// 监听关闭通知，执行关闭函数
+	go func() {
+		<-closeChan
+		log.Println("shutting down......")
+		// 先关闭listener阻止新连接的建立，再逐个关闭所有已建立的连接
+		closeListeners()
+		_ = handler.Close()
+	}()
+
+	// 意外中断释放资源
+	defer func() {
+		closeListeners()
+		_ = handler.Close()
+	}()
+	ctx := context.Background()
+	// sync.WaitGroup可用于协程计数和等待一组协程完成，Add()方法增加计数，Done()方法减少计数，Wait()方法等待完成
+	// waitDone 用于统计存活的连接数, acceptDone 用于等待所有 listener 退出
+	var waitDone sync.WaitGroup
+	var acceptDone sync.WaitGroup
+	for _, listener := range listeners {
+		listener := listener
+		stats := registerListener(listener)
+		acceptDone.Add(1)
+		go func() {
+			defer acceptDone.Done()
+			serve(ctx, listener, stats, handler, &waitDone)
+			// 任一 listener 退出时关闭其余 listener，保证统一退出
+			closeListeners()
+		}()
+	}
+	acceptDone.Wait()
+	// 主程序等待所有协程执行完毕
+	waitDone.Wait()
+}
+
+func serve(ctx context.Context, listener net.Listener, stats *ListenerStats, handler tcp.Handler, waitDone *sync.WaitGroup) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			break
+		}
+		log.Println("new connection")
+		atomic.AddInt64(&stats.Accepted, 1)
+		atomic.AddInt32(&stats.Active, 1)
+		atomic.AddInt32(&ClientCounter, 1)
+		waitDone.Add(1)
+		// 开启一个新的协程处理新连接
+		go func() {
+			// 在该协程结束时对计数器减一
+			defer func() {
+				atomic.AddInt32(&stats.Active, -1)
+				atomic.AddInt32(&ClientCounter, -1)
+				waitDone.Done()
+			}()
+			//调用handler接口中的Handle方法处理连接
+			handler.Handle(ctx, conn)
+		}()
+	}
+}
@@ -0,0 +1,108 @@
+package clock
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 时间源
+// 过期、时间轮、慢命令统计、INFO 中的运行时间等通过本包获取时间，测试中可以用 Fake 替换，
+// 手动推进时间，无需真的等待即可复现与时间相关的行为
+// 未替换时直接调用 time 包，只多一次原子读
+
+// Clock is source of time
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is like time.Ticker
+type Ticker interface {
+	Chan() <-chan time.Time
+	Stop()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) Chan() <-chan time.Time {
+	return t.C
+}
+
+// Real is the clock backed by package time
+var Real Clock = realClock{}
+
+var (
+	replaced int32
+	mu       sync.RWMutex
+	current  = Real
+)
+
+// Set replaces global clock, nil restores the real clock
+// Tickers created before Set are not affected
+func Set(c Clock) {
+	mu.Lock()
+	defer mu.Unlock()
+	if c == nil || c == Real {
+		current = Real
+		atomic.StoreInt32(&replaced, 0)
+		return
+	}
+	current = c
+	atomic.StoreInt32(&replaced, 1)
+}
+
+// Get returns global clock
+func Get() Clock {
+	if atomic.LoadInt32(&replaced) == 0 {
+		return Real
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Now returns current time of global clock
+func Now() time.Time {
+	if atomic.LoadInt32(&replaced) == 0 {
+		return time.Now()
+	}
+	return Get().Now()
+}
+
+// Since returns time elapsed since t by global clock
+func Since(t time.Time) time.Duration {
+	return Now().Sub(t)
+}
+
+// Until returns duration until t by global clock
+func Until(t time.Time) time.Duration {
+	return t.Sub(Now())
+}
+
+// After waits for the duration to elapse by global clock
+func After(d time.Duration) <-chan time.Time {
+	return Get().After(d)
+}
+
+// NewTicker returns a ticker of global clock
+func NewTicker(d time.Duration) Ticker {
+	return Get().NewTicker(d)
+}
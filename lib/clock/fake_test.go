@@ -0,0 +1,54 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	after := c.After(3 * time.Second)
+	ticker := c.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	c.Advance(time.Second)
+	select {
+	case tick := <-ticker.Chan():
+		if !tick.Equal(start.Add(time.Second)) {
+			t.Errorf("expect tick at %v, actually %v", start.Add(time.Second), tick)
+		}
+	default:
+		t.Error("expect ticker fired")
+	}
+	select {
+	case <-after:
+		t.Error("after fired too early")
+	default:
+	}
+
+	c.Advance(2 * time.Second)
+	select {
+	case <-after:
+	default:
+		t.Error("expect after fired")
+	}
+	if !c.Now().Equal(start.Add(3 * time.Second)) {
+		t.Errorf("expect now %v, actually %v", start.Add(3*time.Second), c.Now())
+	}
+}
+
+func TestSet(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+	Set(c)
+	defer Set(nil)
+	c.Advance(time.Minute)
+	if Since(start) != time.Minute {
+		t.Errorf("expect global clock replaced")
+	}
+	Set(nil)
+	if Now().Before(time.Now().Add(-time.Second)) {
+		t.Errorf("expect real clock restored")
+	}
+}
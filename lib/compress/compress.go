@@ -0,0 +1,90 @@
+// Package compress compresses large string values transparently, see value-compression-threshold
+package compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// 使用标准库的 deflate 而不是 snappy/zstd，避免引入新的依赖
+// 压缩级别使用 BestSpeed，缓存场景下写入延迟比压缩率更重要
+
+var writerPool = sync.Pool{
+	New: func() interface{} {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	},
+}
+
+// Stats is cumulative statistics of compression
+type Stats struct {
+	// Compressed is the count of values stored compressed
+	Compressed int64
+	// Skipped is the count of values above threshold but stored raw because compression did not make them smaller
+	Skipped int64
+	// RawBytes and CompressedBytes are total size of compressed values before and after compression
+	RawBytes        int64
+	CompressedBytes int64
+	// Decompressed is the count of reads which decompressed a value
+	Decompressed int64
+}
+
+// Ratio returns CompressedBytes / RawBytes, returns 1 if nothing compressed
+func (s Stats) Ratio() float64 {
+	if s.RawBytes == 0 {
+		return 1
+	}
+	return float64(s.CompressedBytes) / float64(s.RawBytes)
+}
+
+var stats Stats
+
+// GetStats returns a snapshot of statistics
+func GetStats() Stats {
+	return Stats{
+		Compressed:      atomic.LoadInt64(&stats.Compressed),
+		Skipped:         atomic.LoadInt64(&stats.Skipped),
+		RawBytes:        atomic.LoadInt64(&stats.RawBytes),
+		CompressedBytes: atomic.LoadInt64(&stats.CompressedBytes),
+		Decompressed:    atomic.LoadInt64(&stats.Decompressed),
+	}
+}
+
+// Compress returns compressed data and true if data is not shorter than threshold and compression makes it smaller,
+// otherwise returns data itself and false. threshold <= 0 disables compression
+func Compress(data []byte, threshold int) ([]byte, bool) {
+	if threshold <= 0 || len(data) < threshold {
+		return data, false
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, len(data)/2))
+	w := writerPool.Get().(*flate.Writer)
+	w.Reset(buf)
+	_, err := w.Write(data)
+	if err == nil {
+		err = w.Close()
+	}
+	writerPool.Put(w)
+	if err != nil || buf.Len() >= len(data) {
+		atomic.AddInt64(&stats.Skipped, 1)
+		return data, false
+	}
+	atomic.AddInt64(&stats.Compressed, 1)
+	atomic.AddInt64(&stats.RawBytes, int64(len(data)))
+	atomic.AddInt64(&stats.CompressedBytes, int64(buf.Len()))
+	return buf.Bytes(), true
+}
+
+// Decompress restores data returned by Compress
+func Decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&stats.Decompressed, 1)
+	return raw, nil
+}
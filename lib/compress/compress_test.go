@@ -0,0 +1,39 @@
+package compress
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"name":"godis","tags":["cache","kv"]}`), 100)
+	if _, ok := Compress(data, 0); ok {
+		t.Error("threshold 0 should disable compression")
+	}
+	if _, ok := Compress(data, len(data)+1); ok {
+		t.Error("value below threshold should not be compressed")
+	}
+	compressed, ok := Compress(data, 64)
+	if !ok {
+		t.Fatal("expect compressed")
+	}
+	if len(compressed) >= len(data) {
+		t.Errorf("compressed size %d is not smaller than %d", len(compressed), len(data))
+	}
+	raw, err := Decompress(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw, data) {
+		t.Error("decompressed data mismatch")
+	}
+	if ratio := GetStats().Ratio(); ratio <= 0 || ratio >= 1 {
+		t.Errorf("unexpected ratio %f", ratio)
+	}
+
+	// incompressible value is stored raw
+	random := []byte{0x9f, 0x03, 0xe2, 0x51, 0x7a, 0xc4}
+	if _, ok := Compress(random, 1); ok {
+		t.Error("compression should be skipped when it does not save space")
+	}
+}
@@ -0,0 +1,53 @@
+// Package crc implements CRC16 used by cluster slots and CRC64 used by DUMP payloads, both compatible with redis
+package crc
+
+import "strings"
+
+// SlotCount is the number of hash slots in cluster
+const SlotCount = 16384
+
+// crc16Table CRC16/XMODEM 的查找表: 多项式 0x1021，初始值为0，不反转
+var crc16Table [256]uint16
+
+func init() {
+	for i := 0; i < 256; i++ {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		crc16Table[i] = crc
+	}
+}
+
+// CRC16 returns CRC16/XMODEM checksum of data
+func CRC16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^b]
+	}
+	return crc
+}
+
+// HashTag returns the part of key used to compute slot
+// if key contains a non-empty substring between the first '{' and the first '}' after it, only the substring is hashed
+// so keys with the same hash tag, such as {user1000}.following and {user1000}.followers, are in the same slot
+func HashTag(key string) string {
+	beg := strings.IndexByte(key, '{')
+	if beg == -1 {
+		return key
+	}
+	end := strings.IndexByte(key[beg+1:], '}')
+	if end <= 0 {
+		return key
+	}
+	return key[beg+1 : beg+1+end]
+}
+
+// KeySlot returns the cluster slot of key, same as redis CLUSTER KEYSLOT
+func KeySlot(key string) uint32 {
+	return uint32(CRC16([]byte(HashTag(key)))) % SlotCount
+}
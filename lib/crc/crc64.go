@@ -0,0 +1,30 @@
+package crc
+
+// crc64Table CRC64/Jones 的查找表，与 redis 的 crc64 一致: 多项式 0xad93d23594c935a9，按位反转处理，初始值为0
+// 标准库 hash/crc64 会在计算前后对 crc 取反，结果与 redis 不同，因此单独实现
+var crc64Table [256]uint64
+
+// crc64Poly 是反转后的 Jones 多项式
+const crc64Poly = 0x95ac9329ac4bc9b5
+
+func init() {
+	for i := 0; i < 256; i++ {
+		crc := uint64(i)
+		for j := 0; j < 8; j++ {
+			if crc&1 == 1 {
+				crc = crc>>1 ^ crc64Poly
+			} else {
+				crc >>= 1
+			}
+		}
+		crc64Table[i] = crc
+	}
+}
+
+// CRC64 returns CRC64/Jones checksum of data, starting with crc, pass 0 for a new checksum
+func CRC64(crc uint64, data []byte) uint64 {
+	for _, b := range data {
+		crc = crc64Table[byte(crc)^b] ^ crc>>8
+	}
+	return crc
+}
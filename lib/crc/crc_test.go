@@ -0,0 +1,41 @@
+package crc
+
+import "testing"
+
+func TestCRC(t *testing.T) {
+	// check values of the standard test input
+	if crc := CRC16([]byte("123456789")); crc != 0x31c3 {
+		t.Errorf("expect crc16 0x31c3, actual %#x", crc)
+	}
+	if crc := CRC64(0, []byte("123456789")); crc != 0xe9c6d914c4b8d9ca {
+		t.Errorf("expect crc64 0xe9c6d914c4b8d9ca, actual %#x", crc)
+	}
+	// incremental update
+	if crc := CRC64(CRC64(0, []byte("1234")), []byte("56789")); crc != 0xe9c6d914c4b8d9ca {
+		t.Errorf("incremental crc64 mismatch: %#x", crc)
+	}
+}
+
+func TestKeySlot(t *testing.T) {
+	cases := []struct {
+		key  string
+		tag  string
+		slot uint32
+	}{
+		{"foo", "foo", 12182},
+		{"{user1000}.following", "user1000", 3443},
+		{"{user1000}.followers", "user1000", 3443},
+		{"foo{}{bar}", "foo{}{bar}", 8363},
+		{"foo{{bar}}zap", "{bar", 4015},
+		{"foo{bar}{zap}", "bar", 5061},
+		{"}{a}", "a", 15495},
+	}
+	for _, c := range cases {
+		if tag := HashTag(c.key); tag != c.tag {
+			t.Errorf("hash tag of %s: expect %s, actual %s", c.key, c.tag, tag)
+		}
+		if slot := KeySlot(c.key); slot != c.slot {
+			t.Errorf("slot of %s: expect %d, actual %d", c.key, c.slot, slot)
+		}
+	}
+}
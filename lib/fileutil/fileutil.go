@@ -0,0 +1,92 @@
+// Package fileutil provides helpers for writing persistence files safely
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrNoSpace is returned when free space of the target file system is not enough
+type ErrNoSpace struct {
+	Dir       string
+	Need      uint64
+	Available uint64
+}
+
+func (e *ErrNoSpace) Error() string {
+	return fmt.Sprintf("no enough disk space in %s: need %d bytes, available %d bytes", e.Dir, e.Need, e.Available)
+}
+
+// CreateTemp creates a temp file in the directory of target, so that it can be renamed to target atomically
+// Rename is atomic only within one file system, so never create temp file in another directory
+func CreateTemp(target string, pattern string) (*os.File, error) {
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create dir %s failed: %v", dir, err)
+	}
+	file, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("create temp file in %s failed: %v", dir, err)
+	}
+	return file, nil
+}
+
+// SyncDir fsyncs a directory to persist entries changes such as rename
+func SyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = d.Close()
+	}()
+	return d.Sync()
+}
+
+// AtomicRename renames src to dst and fsyncs parent directory of dst
+// so that the rename survives a crash
+func AtomicRename(src string, dst string) error {
+	if err := os.Rename(src, dst); err != nil {
+		return fmt.Errorf("rename %s to %s failed: %v", src, dst, err)
+	}
+	if err := SyncDir(filepath.Dir(dst)); err != nil {
+		return fmt.Errorf("fsync dir of %s failed: %v", dst, err)
+	}
+	return nil
+}
+
+// CommitTemp fsyncs and closes tmpFile then renames it to target atomically
+// tmpFile will be removed if any error occurs
+func CommitTemp(tmpFile *os.File, target string) error {
+	err := tmpFile.Sync()
+	if err == nil {
+		err = tmpFile.Close()
+	} else {
+		_ = tmpFile.Close()
+	}
+	if err == nil {
+		err = AtomicRename(tmpFile.Name(), target)
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return err
+	}
+	return nil
+}
+
+// CheckFreeSpace returns *ErrNoSpace if free space of the file system holding dir is less than need
+func CheckFreeSpace(dir string, need uint64) error {
+	available, err := freeSpace(dir)
+	if err != nil {
+		return fmt.Errorf("check free space of %s failed: %v", dir, err)
+	}
+	if available < need {
+		return &ErrNoSpace{
+			Dir:       dir,
+			Need:      need,
+			Available: available,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LockFilename is the name of lock file in data directory
+const LockFilename = "godis.lock"
+
+// DirLock prevents two processes from sharing one data directory
+type DirLock struct {
+	file *os.File
+	path string
+}
+
+// LockDir acquires the lock of data directory and writes pid of current process into lock file
+// force removes the lock file left by a crashed process on platforms without flock,
+// with flock the lock of a crashed process has been released by kernel
+func LockDir(dir string, force bool) (*DirLock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create data dir %s failed: %v", dir, err)
+	}
+	path := filepath.Join(dir, LockFilename)
+	file, err := lockFile(path)
+	if err != nil && force && !releasedOnCrash {
+		// lock file left by a crashed process, clean it up
+		_ = os.Remove(path)
+		file, err = lockFile(path)
+	}
+	if err != nil {
+		owner := readOwner(path)
+		return nil, fmt.Errorf("data dir %s is locked by process %s: %v, "+
+			"stop that process or start with --force if it has crashed", dir, owner, err)
+	}
+	if err := file.Truncate(0); err == nil {
+		_, _ = file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+		_ = file.Sync()
+	}
+	return &DirLock{
+		file: file,
+		path: path,
+	}, nil
+}
+
+// Unlock releases the lock and removes lock file
+func (l *DirLock) Unlock() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	_ = os.Remove(l.path)
+	unlockFile(l.file)
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func readOwner(path string) string {
+	bin, err := os.ReadFile(path)
+	if err != nil {
+		return "unknown"
+	}
+	pid := strings.TrimSpace(string(bin))
+	if pid == "" {
+		return "unknown"
+	}
+	return pid
+}
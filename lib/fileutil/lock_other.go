@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd
+
+package fileutil
+
+import "os"
+
+const releasedOnCrash = false
+
+// lockFile creates lock file exclusively, the file is left behind if the process crashed
+func lockFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+}
+
+func unlockFile(file *os.File) {}
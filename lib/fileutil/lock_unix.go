@@ -0,0 +1,28 @@
+//go:build linux || darwin || freebsd
+
+package fileutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// releasedOnCrash reports whether the lock is released automatically when the holder exits
+const releasedOnCrash = true
+
+// lockFile uses flock, the lock is released by kernel even if the process crashed
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func unlockFile(file *os.File) {
+	_ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
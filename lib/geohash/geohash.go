@@ -0,0 +1,204 @@
+// Package geohash 与 redis 兼容的 geohash 编码，用于在有序集合中保存经纬度
+// 经度和纬度各用 26 位表示，交错排列成 52 位整数，纬度在偶数位，经度在奇数位
+// 52 位整数可以用 float64 精确表示，因此可以直接作为有序集合的 score
+// 与 redis 一致，纬度范围限制为 Web Mercator 投影的 [-85.05112878, 85.05112878]
+package geohash
+
+import "math"
+
+const (
+	// MaxStep is the bits of longitude or latitude in a full precision geohash
+	MaxStep = 26
+
+	LonMin = -180.0
+	LonMax = 180.0
+	LatMin = -85.05112878
+	LatMax = 85.05112878
+
+	// EarthRadius is the earth radius in meters used by redis
+	EarthRadius = 6372797.560856
+	// mercatorMax 是 Web Mercator 投影中从中心到边缘的距离
+	mercatorMax = 20037726.37
+)
+
+// Hash is a geohash with precision of Step bits for each of longitude and latitude
+type Hash struct {
+	Bits uint64
+	Step uint8
+}
+
+// Area is the bounding box of a geohash
+type Area struct {
+	LonMin, LonMax float64
+	LatMin, LatMax float64
+}
+
+// Valid returns whether the coordinate can be encoded
+func Valid(lon float64, lat float64) bool {
+	return lon >= LonMin && lon <= LonMax && lat >= LatMin && lat <= LatMax
+}
+
+// spread 将 x 的低 32 位分散到偶数位
+func spread(x uint32) uint64 {
+	v := uint64(x)
+	v = (v | v<<16) & 0x0000ffff0000ffff
+	v = (v | v<<8) & 0x00ff00ff00ff00ff
+	v = (v | v<<4) & 0x0f0f0f0f0f0f0f0f
+	v = (v | v<<2) & 0x3333333333333333
+	v = (v | v<<1) & 0x5555555555555555
+	return v
+}
+
+// squash 是 spread 的逆运算，收集偶数位
+func squash(v uint64) uint32 {
+	v &= 0x5555555555555555
+	v = (v | v>>1) & 0x3333333333333333
+	v = (v | v>>2) & 0x0f0f0f0f0f0f0f0f
+	v = (v | v>>4) & 0x00ff00ff00ff00ff
+	v = (v | v>>8) & 0x0000ffff0000ffff
+	v = (v | v>>16) & 0x00000000ffffffff
+	return uint32(v)
+}
+
+func interleave(latIndex uint32, lonIndex uint32) uint64 {
+	return spread(latIndex) | spread(lonIndex)<<1
+}
+
+func deinterleave(bits uint64) (latIndex uint32, lonIndex uint32) {
+	return squash(bits), squash(bits >> 1)
+}
+
+func encodeInRange(lon float64, lat float64, step uint8, latMin float64, latMax float64) Hash {
+	latOffset := (lat - latMin) / (latMax - latMin)
+	lonOffset := (lon - LonMin) / (LonMax - LonMin)
+	return Hash{
+		Bits: interleave(cellIndex(latOffset, step), cellIndex(lonOffset, step)),
+		Step: step,
+	}
+}
+
+// cellIndex returns index of the cell containing offset within [0, 1]
+// 坐标在范围的上边界(如经度180)时 offset 为1，归入最后一个格子，否则会溢出到更高的位
+func cellIndex(offset float64, step uint8) uint32 {
+	cells := uint64(1) << step
+	index := uint64(offset * float64(cells))
+	if index >= cells {
+		index = cells - 1
+	}
+	return uint32(index)
+}
+
+// EncodeWithStep returns geohash of the coordinate with the given precision, the coordinate should be valid
+func EncodeWithStep(lon float64, lat float64, step uint8) Hash {
+	return encodeInRange(lon, lat, step, LatMin, LatMax)
+}
+
+// Encode returns the full precision geohash of the coordinate, which is used as score in sorted set
+func Encode(lon float64, lat float64) uint64 {
+	return EncodeWithStep(lon, lat, MaxStep).Bits
+}
+
+// Area returns the bounding box of hash
+func (hash Hash) Area() Area {
+	latIndex, lonIndex := deinterleave(hash.Bits)
+	cells := float64(uint64(1) << hash.Step)
+	latScale := LatMax - LatMin
+	lonScale := LonMax - LonMin
+	return Area{
+		LatMin: LatMin + float64(latIndex)/cells*latScale,
+		LatMax: LatMin + float64(latIndex+1)/cells*latScale,
+		LonMin: LonMin + float64(lonIndex)/cells*lonScale,
+		LonMax: LonMin + float64(lonIndex+1)/cells*lonScale,
+	}
+}
+
+// Decode returns the center of a full precision geohash
+func Decode(bits uint64) (lon float64, lat float64) {
+	area := Hash{Bits: bits, Step: MaxStep}.Area()
+	lon = math.Max(LonMin, math.Min(LonMax, (area.LonMin+area.LonMax)/2))
+	lat = math.Max(LatMin, math.Min(LatMax, (area.LatMin+area.LatMax)/2))
+	return lon, lat
+}
+
+// Neighbors returns hashes of the same step around hash, in order of N, NE, E, SE, S, SW, W, NW
+// longitude wraps around the 180th meridian, neighbors beyond the latitude range are omitted
+func (hash Hash) Neighbors() []Hash {
+	latIndex, lonIndex := deinterleave(hash.Bits)
+	cells := int64(1) << hash.Step
+	moves := [8][2]int64{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	neighbors := make([]Hash, 0, len(moves))
+	for _, move := range moves {
+		lat := int64(latIndex) + move[0]
+		if lat < 0 || lat >= cells {
+			continue
+		}
+		lon := (int64(lonIndex) + move[1] + cells) % cells
+		neighbors = append(neighbors, Hash{
+			Bits: interleave(uint32(lat), uint32(lon)),
+			Step: hash.Step,
+		})
+	}
+	return neighbors
+}
+
+// ScoreRange returns the range [min, max) of full precision geohash within hash
+func (hash Hash) ScoreRange() (min uint64, max uint64) {
+	shift := 2 * (MaxStep - hash.Step)
+	return hash.Bits << shift, (hash.Bits + 1) << shift
+}
+
+// EstimateStepsByRadius returns the largest step whose cell is still wide enough to cover radius with its neighbors
+func EstimateStepsByRadius(radius float64, lat float64) uint8 {
+	if radius == 0 {
+		return MaxStep
+	}
+	step := 1
+	for radius < mercatorMax {
+		radius *= 2
+		step++
+	}
+	// 减小精度，保证大多数情况下半径范围被包含在中心和相邻的8个区域内
+	step -= 2
+	// 高纬度地区经线间距变小，需要更大的区域
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+	if step < 1 {
+		step = 1
+	}
+	if step > MaxStep {
+		step = MaxStep
+	}
+	return uint8(step)
+}
+
+// Distance returns distance in meters between two coordinates by the haversine formula
+func Distance(lon1 float64, lat1 float64, lon2 float64, lat2 float64) float64 {
+	lat1r := lat1 * math.Pi / 180
+	lat2r := lat2 * math.Pi / 180
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin((lon2 - lon1) * math.Pi / 180 / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2 * EarthRadius * math.Asin(math.Sqrt(a))
+}
+
+const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// ToString returns the standard 11 characters geohash string of the coordinate, same as redis GEOHASH
+// standard geohash uses latitude range [-90, 90], so it is different from the score in sorted set
+func ToString(lon float64, lat float64) string {
+	bits := encodeInRange(lon, lat, MaxStep, -90, 90).Bits
+	buf := make([]byte, 11)
+	for i := range buf {
+		var index uint64
+		// 52 位不足 55 位，最后一个字符补0
+		if i < 10 {
+			index = (bits >> (52 - uint(i+1)*5)) & 0x1f
+		}
+		buf[i] = base32[index]
+	}
+	return string(buf)
+}
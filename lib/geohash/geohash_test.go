@@ -0,0 +1,60 @@
+package geohash
+
+import (
+	"math"
+	"testing"
+)
+
+// expected values are from the examples in redis documents
+func TestEncode(t *testing.T) {
+	cases := []struct {
+		name     string
+		lon, lat float64
+		score    uint64
+		str      string
+	}{
+		{"Palermo", 13.361389, 38.115556, 3479099956230698, "sqc8b49rny0"},
+		{"Catania", 15.087269, 37.502669, 3479447370796909, "sqdtr74hyu0"},
+	}
+	for _, c := range cases {
+		if score := Encode(c.lon, c.lat); score != c.score {
+			t.Errorf("%s: expect score %d, actual %d", c.name, c.score, score)
+		}
+		if str := ToString(c.lon, c.lat); str != c.str {
+			t.Errorf("%s: expect geohash %s, actual %s", c.name, c.str, str)
+		}
+		lon, lat := Decode(c.score)
+		if math.Abs(lon-c.lon) > 1e-5 || math.Abs(lat-c.lat) > 1e-5 {
+			t.Errorf("%s: decoded %f %f", c.name, lon, lat)
+		}
+	}
+	// redis computes distance between decoded positions
+	lon1, lat1 := Decode(cases[0].score)
+	lon2, lat2 := Decode(cases[1].score)
+	dist := Distance(lon1, lat1, lon2, lat2)
+	if math.Abs(dist-166274.1516) > 0.01 {
+		t.Errorf("expect distance 166274.1516, actual %f", dist)
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	step := EstimateStepsByRadius(200*1000, 37)
+	center := EncodeWithStep(15, 37, step)
+	neighbors := center.Neighbors()
+	if len(neighbors) != 8 {
+		t.Fatalf("expect 8 neighbors, actual %d", len(neighbors))
+	}
+	area := center.Area()
+	north := neighbors[0].Area()
+	if north.LatMin != area.LatMax || north.LonMin != area.LonMin {
+		t.Errorf("north neighbor %+v is not adjacent to %+v", north, area)
+	}
+	// the 9 areas should cover the radius
+	if Distance(15, 37, 15, area.LatMax+(north.LatMax-north.LatMin)) < 200*1000 {
+		t.Error("areas do not cover the radius")
+	}
+	min, max := center.ScoreRange()
+	if score := Encode(15, 37); score < min || score >= max {
+		t.Errorf("score %d out of range [%d, %d)", score, min, max)
+	}
+}
@@ -0,0 +1,187 @@
+// Package hotkeys finds the most frequently accessed keys by sampling,
+// it helps operators to find skewed access patterns which cause lock contention of shards
+package hotkeys
+
+import (
+	"Godis/lib/clock"
+	"container/heap"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 使用 Space-Saving 算法统计近似的 top-K：最多保存 capacity 个计数器，已满时新的 key 替换计数最小的 key 并继承其计数，
+// 因此计数可能偏高，但真正的热点 key 不会被遗漏
+// 滑动窗口由两代计数器近似：每半个窗口轮换一次，统计结果是当前代与上一代之和，覆盖最近半个到一个窗口内的访问
+
+// Entry is an estimated access count of key
+type Entry struct {
+	DB    int
+	Key   string
+	Count int64
+}
+
+type entryKey struct {
+	db  int
+	key string
+}
+
+type counter struct {
+	key   entryKey
+	count int64
+	index int // index in heap
+}
+
+// counterHeap is min heap of counters by count
+type counterHeap []*counter
+
+func (h counterHeap) Len() int           { return len(h) }
+func (h counterHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *counterHeap) Push(x interface{}) {
+	c := x.(*counter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *counterHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// generation counts accesses within half of the window
+type generation struct {
+	start    time.Time
+	counters map[entryKey]*counter
+	heap     counterHeap
+}
+
+func makeGeneration(start time.Time) *generation {
+	return &generation{
+		start:    start,
+		counters: make(map[entryKey]*counter),
+	}
+}
+
+func (g *generation) add(key entryKey, weight int64, capacity int) {
+	if c, ok := g.counters[key]; ok {
+		c.count += weight
+		heap.Fix(&g.heap, c.index)
+		return
+	}
+	if len(g.heap) < capacity {
+		c := &counter{key: key, count: weight}
+		heap.Push(&g.heap, c)
+		g.counters[key] = c
+		return
+	}
+	// 替换计数最小的 key
+	c := g.heap[0]
+	delete(g.counters, c.key)
+	c.key = key
+	c.count += weight
+	g.counters[key] = c
+	heap.Fix(&g.heap, 0)
+}
+
+// Tracker samples key accesses and keeps approximate access counts of the hottest keys
+type Tracker struct {
+	rate     uint64
+	window   time.Duration
+	capacity int
+	// accesses is count of all accesses, one of every rate accesses is sampled
+	accesses uint64
+
+	mu       sync.Mutex
+	current  *generation
+	previous *generation
+}
+
+// New creates a tracker which samples one of every rate accesses and keeps at most capacity keys in each half of window
+func New(rate int, window time.Duration, capacity int) *Tracker {
+	if rate <= 0 {
+		rate = 1
+	}
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &Tracker{
+		rate:     uint64(rate),
+		window:   window,
+		capacity: capacity,
+		current:  makeGeneration(clock.Now()),
+	}
+}
+
+// Touch records accesses of keys in db
+func (t *Tracker) Touch(db int, keys ...string) {
+	for _, key := range keys {
+		if atomic.AddUint64(&t.accesses, 1)%t.rate != 0 {
+			continue
+		}
+		t.mu.Lock()
+		t.rotate(clock.Now())
+		// 每个样本代表 rate 次访问
+		t.current.add(entryKey{db: db, key: key}, int64(t.rate), t.capacity)
+		t.mu.Unlock()
+	}
+}
+
+// rotate starts a new generation every half window, invoker should hold the lock
+func (t *Tracker) rotate(now time.Time) {
+	elapsed := now.Sub(t.current.start)
+	if elapsed < t.window/2 {
+		return
+	}
+	if elapsed < t.window {
+		t.previous = t.current
+	} else {
+		// 超过一个窗口没有访问，上一代已经过期
+		t.previous = nil
+	}
+	t.current = makeGeneration(now)
+}
+
+// Top returns at most n hottest keys of db within the window in descending order of count, negative db means all dbs
+func (t *Tracker) Top(db int, n int) []Entry {
+	t.mu.Lock()
+	t.rotate(clock.Now())
+	counts := make(map[entryKey]int64)
+	for _, g := range []*generation{t.previous, t.current} {
+		if g == nil {
+			continue
+		}
+		for key, c := range g.counters {
+			if db < 0 || key.db == db {
+				counts[key] += c.count
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	entries := make([]Entry, 0, len(counts))
+	for key, count := range counts {
+		entries = append(entries, Entry{DB: key.db, Key: key.key, Count: count})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		if entries[i].DB != entries[j].DB {
+			return entries[i].DB < entries[j].DB
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	if n >= 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
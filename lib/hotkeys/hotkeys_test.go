@@ -0,0 +1,63 @@
+package hotkeys
+
+import (
+	"Godis/lib/clock"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	clock.Set(c)
+	defer clock.Set(nil)
+
+	tracker := New(1, time.Minute, 16)
+	for i := 0; i < 100; i++ {
+		tracker.Touch(0, "hot")
+		tracker.Touch(0, "warm"+strconv.Itoa(i%2))
+		// 大量只访问一次的 key 不会挤掉热点 key
+		tracker.Touch(0, "cold"+strconv.Itoa(i))
+	}
+	tracker.Touch(1, "other")
+	top := tracker.Top(0, 3)
+	if len(top) != 3 || top[0].Key != "hot" || top[0].Count < 100 {
+		t.Fatalf("unexpected top keys %v", top)
+	}
+	if top[1].Key != "warm0" || top[2].Key != "warm1" {
+		t.Errorf("unexpected top keys %v", top)
+	}
+	// 所有 db 共用 capacity 个计数器
+	if all := tracker.Top(-1, -1); len(all) != 16 {
+		t.Errorf("expect 16 keys in all dbs, actual %d", len(all))
+	}
+	if top := tracker.Top(1, 10); len(top) != 1 || top[0].Key != "other" {
+		t.Errorf("unexpected top keys of db 1 %v", top)
+	}
+
+	// 上一代在下一个半窗口内仍然计入
+	c.Advance(40 * time.Second)
+	tracker.Touch(0, "new")
+	if top := tracker.Top(0, 1); top[0].Key != "hot" {
+		t.Errorf("previous generation should be counted, actual %v", top)
+	}
+	c.Advance(40 * time.Second)
+	if top := tracker.Top(0, 10); len(top) != 1 || top[0].Key != "new" {
+		t.Errorf("expect only new key, actual %v", top)
+	}
+	c.Advance(2 * time.Minute)
+	if top := tracker.Top(-1, 10); len(top) != 0 {
+		t.Errorf("expect no keys after window, actual %v", top)
+	}
+}
+
+func TestTracker_Sample(t *testing.T) {
+	tracker := New(10, time.Minute, 16)
+	for i := 0; i < 1000; i++ {
+		tracker.Touch(0, "k")
+	}
+	// 每个样本代表 10 次访问
+	if top := tracker.Top(0, 1); len(top) != 1 || top[0].Count != 1000 {
+		t.Errorf("expect estimated count 1000, actual %v", top)
+	}
+}
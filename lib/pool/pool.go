@@ -115,6 +115,14 @@ func (pool *Pool) Put(x interface{}) {
 	}
 }
 
+// Discard destroys an item got from pool instead of returning it, such as a broken connection
+func (pool *Pool) Discard(x interface{}) {
+	pool.mu.Lock()
+	pool.activeCount--
+	pool.mu.Unlock()
+	pool.finalizer(x)
+}
+
 func (pool *Pool) Close() {
 	pool.mu.Lock()
 	if pool.closed {
@@ -0,0 +1,162 @@
+package workers
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"Godis/lib/logger"
+)
+
+// ErrClosed is returned when submitting job to a closed pool
+var ErrClosed = errors.New("workers pool closed")
+
+// Pool runs jobs on a bounded number of goroutines
+// 大量任务同时到期时(如批量过期)，逐个开启协程会导致协程数量暴涨，
+// 使用固定数量的 worker 消费任务队列可以限制协程数量
+type Pool struct {
+	jobs   chan func()
+	wg     sync.WaitGroup
+	mu     sync.RWMutex // protects closed and jobs from being closed during submitting
+	closed bool
+
+	// overflow 保存 Post 时队列已满的任务，由 worker 在执行完队列中的任务后取出执行
+	overflowMu sync.Mutex
+	overflow   []func()
+	// wake 在 overflow 中加入任务时通知空闲的 worker
+	wake chan struct{}
+}
+
+// New creates a pool with size workers, at most queueSize jobs could be pending
+func New(size int, queueSize int) *Pool {
+	if size <= 0 {
+		size = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	pool := &Pool{
+		jobs: make(chan func(), queueSize),
+		wake: make(chan struct{}, 1),
+	}
+	pool.wg.Add(size)
+	for i := 0; i < size; i++ {
+		go pool.work()
+	}
+	return pool
+}
+
+func (pool *Pool) work() {
+	defer pool.wg.Done()
+	for {
+		select {
+		case job, ok := <-pool.jobs:
+			if !ok {
+				pool.runOverflow()
+				return
+			}
+			run(job)
+		case <-pool.wake:
+		}
+		pool.runOverflow()
+	}
+}
+
+// runOverflow 执行 overflow 中的任务直到其为空
+func (pool *Pool) runOverflow() {
+	for {
+		pool.overflowMu.Lock()
+		if len(pool.overflow) == 0 {
+			pool.overflowMu.Unlock()
+			return
+		}
+		job := pool.overflow[0]
+		pool.overflow[0] = nil
+		pool.overflow = pool.overflow[1:]
+		pool.overflowMu.Unlock()
+		run(job)
+	}
+}
+
+// run executes job and recovers from panic so that a bad job won't kill the worker
+func run(job func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error(fmt.Sprintf("worker job panic: %v\n%s", err, string(debug.Stack())))
+		}
+	}()
+	job()
+}
+
+// Submit puts job into queue, blocks if the queue is full
+func (pool *Pool) Submit(job func()) error {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	if pool.closed {
+		return ErrClosed
+	}
+	pool.jobs <- job
+	return nil
+}
+
+// TrySubmit puts job into queue without blocking, returns false if the queue is full or pool closed
+func (pool *Pool) TrySubmit(job func()) bool {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	if pool.closed {
+		return false
+	}
+	select {
+	case pool.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Post puts job into queue without blocking, jobs beyond the queue are kept in overflow list until workers are free
+// 与 TrySubmit 不同，Post 不会丢弃任务，适合不能阻塞又不能丢弃任务的调用方，如时间轮
+func (pool *Pool) Post(job func()) error {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	if pool.closed {
+		return ErrClosed
+	}
+	pool.overflowMu.Lock()
+	defer pool.overflowMu.Unlock()
+	// overflow 不为空时直接追加，避免其中的任务被之后提交的任务长期插队
+	if len(pool.overflow) == 0 {
+		select {
+		case pool.jobs <- job:
+			return nil
+		default:
+		}
+	}
+	pool.overflow = append(pool.overflow, job)
+	select {
+	case pool.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pending returns count of jobs waiting in queue and overflow list
+func (pool *Pool) Pending() int {
+	pool.overflowMu.Lock()
+	defer pool.overflowMu.Unlock()
+	return len(pool.jobs) + len(pool.overflow)
+}
+
+// Close stops accepting new jobs and blocks until all pending jobs finished
+func (pool *Pool) Close() {
+	pool.mu.Lock()
+	if pool.closed {
+		pool.mu.Unlock()
+		return
+	}
+	pool.closed = true
+	close(pool.jobs)
+	pool.mu.Unlock()
+	pool.wg.Wait()
+}
@@ -0,0 +1,126 @@
+package workers
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	pool := New(4, 16)
+	var count int32
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		if err := pool.Submit(func() {
+			defer wg.Done()
+			atomic.AddInt32(&count, 1)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	if count != 100 {
+		t.Errorf("expect 100 jobs finished, actual %d", count)
+	}
+
+	// 任务 panic 不会导致 worker 退出
+	for i := 0; i < 4; i++ {
+		_ = pool.Submit(func() { panic("bad job") })
+	}
+	done := make(chan struct{})
+	_ = pool.Submit(func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("workers stopped after job panic")
+	}
+
+	pool.Close()
+	if err := pool.Submit(func() {}); err != ErrClosed {
+		t.Errorf("expect ErrClosed, actual %v", err)
+	}
+	if pool.TrySubmit(func() {}) {
+		t.Error("expect TrySubmit fail after closed")
+	}
+}
+
+func TestTrySubmit(t *testing.T) {
+	pool := New(1, 1)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	_ = pool.Submit(func() {
+		close(started)
+		<-release
+	})
+	<-started
+	if !pool.TrySubmit(func() {}) {
+		t.Error("expect job queued")
+	}
+	if pool.Pending() != 1 {
+		t.Errorf("expect 1 pending job, actual %d", pool.Pending())
+	}
+	if pool.TrySubmit(func() {}) {
+		t.Error("expect TrySubmit fail when queue is full")
+	}
+	close(release)
+	// Close 等待队列中的任务执行完
+	pool.Close()
+	if pool.Pending() != 0 {
+		t.Errorf("expect no pending job after closed, actual %d", pool.Pending())
+	}
+}
+
+func TestPost(t *testing.T) {
+	pool := New(1, 1)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	_ = pool.Submit(func() {
+		close(started)
+		<-release
+	})
+	<-started
+	// 队列已满时任务进入 overflow，不会阻塞也不会被丢弃
+	var count int32
+	for i := 0; i < 10; i++ {
+		if err := pool.Post(func() {
+			atomic.AddInt32(&count, 1)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pool.Pending() != 10 {
+		t.Errorf("expect 10 pending jobs, actual %d", pool.Pending())
+	}
+	close(release)
+	pool.Close()
+	// Close 等待 overflow 中的任务执行完
+	if count != 10 {
+		t.Errorf("expect 10 jobs finished, actual %d", count)
+	}
+	if err := pool.Post(func() {}); err != ErrClosed {
+		t.Errorf("expect ErrClosed, actual %v", err)
+	}
+}
+
+func TestPostWakesIdleWorkers(t *testing.T) {
+	pool := New(4, 0)
+	defer pool.Close()
+	// 无缓冲队列在没有 worker 等待时任务进入 overflow，空闲的 worker 也要能执行它
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		_ = pool.Post(wg.Done)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("jobs in overflow are not executed, %d pending", pool.Pending())
+	}
+}
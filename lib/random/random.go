@@ -0,0 +1,89 @@
+package random
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 全局随机数生成器，并发安全
+// 默认以当前时间为种子。调用 Seed 后进入确定性模式，随机数序列固定，
+// 随机选取key(RANDOMKEY、SPOP、HRANDFIELD 等)、LFU 计数增长、重试的随机退避等行为
+// 在相同的操作序列下结果相同，用于在测试、CI 和问题报告中复现与随机相关的问题
+// go 的 map 遍历顺序无法固定，确定性模式下随机选取key前需要先排序，开销较大，只应在测试中使用
+
+var (
+	mu            sync.Mutex
+	r             = rand.New(rand.NewSource(time.Now().UnixNano()))
+	deterministic int32
+)
+
+// Seed resets global generator with the given seed and enables deterministic mode
+func Seed(seed int64) {
+	mu.Lock()
+	r = rand.New(rand.NewSource(seed))
+	mu.Unlock()
+	atomic.StoreInt32(&deterministic, 1)
+}
+
+// Reset reseeds global generator with current time and disables deterministic mode
+func Reset() {
+	mu.Lock()
+	r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	mu.Unlock()
+	atomic.StoreInt32(&deterministic, 0)
+}
+
+// Deterministic returns whether deterministic mode is enabled
+func Deterministic() bool {
+	return atomic.LoadInt32(&deterministic) == 1
+}
+
+// New returns a generator seeded by global generator, the returned generator is not safe for concurrent use
+// In deterministic mode its sequence is also determined by the seed
+func New() *rand.Rand {
+	return rand.New(rand.NewSource(Int63()))
+}
+
+// Int63 returns a non-negative pseudo-random 63-bit integer
+func Int63() int64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return r.Int63()
+}
+
+// Int63n returns a non-negative pseudo-random number in [0,n)
+func Int63n(n int64) int64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return r.Int63n(n)
+}
+
+// Intn returns a non-negative pseudo-random number in [0,n)
+func Intn(n int) int {
+	mu.Lock()
+	defer mu.Unlock()
+	return r.Intn(n)
+}
+
+// Uint64 returns a pseudo-random 64-bit value
+func Uint64() uint64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return r.Uint64()
+}
+
+// Float64 returns a pseudo-random number in [0.0,1.0)
+func Float64() float64 {
+	mu.Lock()
+	defer mu.Unlock()
+	return r.Float64()
+}
+
+// Shuffle pseudo-randomizes the order of elements, swap should not call functions of this package
+func Shuffle(n int, swap func(i, j int)) {
+	mu.Lock()
+	defer mu.Unlock()
+	r.Shuffle(n, swap)
+}
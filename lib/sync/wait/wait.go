@@ -1,6 +1,8 @@
 package wait
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"time"
 )
@@ -8,40 +10,87 @@ import (
 // 这里原作者在sync.WaitGroup方法基础上写了一个wait类
 // Add,Done,Wait的实现直接用原方法，增加了WaitWithTimeout方法
 // 如果返回false代表程序Wait程序正常结束，true代表程序到达指定等待时间
+// 原实现在超时后会遗留一个阻塞在 wg.Wait() 上的 goroutine，
+// 现改为计数器 + 完成时关闭的 channel，等待方超时即可直接返回，不会产生泄漏
+
+// ErrTimeout is returned by WaitCtx when the deadline exceeded before counter reached zero
+var ErrTimeout = errors.New("wait timeout")
 
 // Wait is similar with sync.WaitGroup which can wait with timeout
+// The zero value is ready to use
 type Wait struct {
-	wg sync.WaitGroup
+	mu    sync.Mutex
+	count int
+	done  chan struct{} // closed when count reaches zero, nil if count is zero
 }
 
 // Add adds delta, which may be negative, to the WaitGroup counter.
 func (w *Wait) Add(delta int) {
-	w.wg.Add(delta)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.count == 0 && delta > 0 {
+		w.done = make(chan struct{})
+	}
+	w.count += delta
+	if w.count < 0 {
+		panic("wait: negative counter")
+	}
+	if w.count == 0 && w.done != nil {
+		close(w.done)
+		w.done = nil
+	}
 }
 
 // Done decrements the WaitGroup counter by one
 func (w *Wait) Done() {
-	w.wg.Done()
+	w.Add(-1)
+}
+
+// doneChan returns a channel closed when counter reaches zero, nil means already zero
+func (w *Wait) doneChan() <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.done
 }
 
 // Wait blocks until the WaitGroup counter is zero.
 func (w *Wait) Wait() {
-	w.wg.Wait()
+	if ch := w.doneChan(); ch != nil {
+		<-ch
+	}
 }
 
 // WaitWithTimeout blocks until the WaitGroup counter is zero or timeout
 // returns true if timeout
 func (w *Wait) WaitWithTimeout(timeout time.Duration) bool {
-	c := make(chan struct{}, 1)
-	go func() {
-		defer close(c)
-		w.Wait()
-		c <- struct{}{}
-	}()
+	ch := w.doneChan()
+	if ch == nil {
+		return false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 	select {
-	case <-c:
+	case <-ch:
 		return false // completed normally
-	case <-time.After(timeout):
+	case <-timer.C:
 		return true // timed out
 	}
 }
+
+// WaitCtx blocks until the WaitGroup counter is zero or ctx is done
+// returns nil if completed, ErrTimeout if ctx deadline exceeded, otherwise ctx.Err()
+func (w *Wait) WaitCtx(ctx context.Context) error {
+	ch := w.doneChan()
+	if ch == nil {
+		return nil
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrTimeout
+		}
+		return ctx.Err()
+	}
+}
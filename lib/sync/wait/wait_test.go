@@ -0,0 +1,100 @@
+package wait
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWaitWithTimeout(t *testing.T) {
+	w := &Wait{}
+	w.Add(1)
+	if !w.WaitWithTimeout(10 * time.Millisecond) {
+		t.Error("expect timeout")
+	}
+
+	// 截止时间前完成
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		w.Done()
+	}()
+	if w.WaitWithTimeout(time.Second) {
+		t.Error("expect completed before deadline")
+	}
+	// 计数为0时立即返回
+	if w.WaitWithTimeout(0) {
+		t.Error("expect no timeout on zero counter")
+	}
+}
+
+func TestWaitTimeoutDoesNotLeak(t *testing.T) {
+	w := &Wait{}
+	w.Add(1)
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		w.WaitWithTimeout(time.Microsecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("expect no goroutine left after timeout, before %d, after %d", before, after)
+	}
+	w.Done()
+}
+
+func TestWaitRearm(t *testing.T) {
+	w := &Wait{}
+	for i := 0; i < 3; i++ {
+		// 计数归零后再次 Add 需要重新等待
+		w.Add(2)
+		if !w.WaitWithTimeout(10 * time.Millisecond) {
+			t.Fatalf("round %d: expect timeout before counter reaches zero", i)
+		}
+		w.Done()
+		w.Done()
+		if w.WaitWithTimeout(10 * time.Millisecond) {
+			t.Fatalf("round %d: expect completed", i)
+		}
+		w.Wait()
+	}
+}
+
+func TestNegativeCounter(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expect panic on negative counter")
+		}
+	}()
+	w := &Wait{}
+	w.Done()
+}
+
+func TestWaitCtx(t *testing.T) {
+	w := &Wait{}
+	if err := w.WaitCtx(context.Background()); err != nil {
+		t.Errorf("expect nil on zero counter, actual %v", err)
+	}
+
+	w.Add(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := w.WaitCtx(ctx); err != ErrTimeout {
+		t.Errorf("expect ErrTimeout, actual %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if err := w.WaitCtx(ctx); err != context.Canceled {
+		t.Errorf("expect context.Canceled, actual %v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		w.Done()
+	}()
+	if err := w.WaitCtx(context.Background()); err != nil {
+		t.Errorf("expect completed, actual %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+// Package systemd supports socket activation and readiness notification of systemd
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd, SD_LISTEN_FDS_START
+const listenFdsStart = 3
+
+const (
+	// Ready tells systemd that service startup is finished
+	Ready = "READY=1"
+	// Stopping tells systemd that service is beginning its shutdown
+	Stopping = "STOPPING=1"
+)
+
+// Listeners returns listeners passed by systemd socket activation
+// returns nil if the process is not socket activated
+func Listeners() ([]net.Listener, error) {
+	defer func() {
+		// avoid passing fds to child processes
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	nfds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nfds <= 0 {
+		return nil, nil
+	}
+	listeners := make([]net.Listener, 0, nfds)
+	for fd := listenFdsStart; fd < listenFdsStart+nfds; fd++ {
+		file := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		listener, err := net.FileListener(file)
+		// FileListener dups fd, close the original one
+		_ = file.Close()
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, fmt.Errorf("fd %d passed by systemd is not a listener: %v", fd, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// Notify sends state to systemd, like sd_notify
+// returns false if the service is not supervised by systemd (NOTIFY_SOCKET not set)
+func Notify(state string) (bool, error) {
+	socketAddr := os.Getenv("NOTIFY_SOCKET")
+	if socketAddr == "" {
+		return false, nil
+	}
+	// abstract namespace socket
+	if socketAddr[0] == '@' {
+		socketAddr = "\x00" + socketAddr[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{
+		Name: socketAddr,
+		Net:  "unixgram",
+	})
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if _, err = conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
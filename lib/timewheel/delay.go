@@ -1,6 +1,10 @@
 package timewheel
 
-import "time"
+import (
+	"time"
+
+	"Godis/lib/clock"
+)
 
 // 一个时间槽是1s，一个时间轮上共有3600个时间槽
 var tw = New(time.Second, 3600)
@@ -16,7 +20,17 @@ func Delay(duration time.Duration, key string, job func()) {
 
 // At executes job at given time
 func At(at time.Time, key string, job func()) {
-	tw.AddJob(at.Sub(time.Now()), key, job)
+	tw.AddJob(at.Sub(clock.Now()), key, job)
+}
+
+// SetClock replaces time source of the default time wheel, such as using clock.Fake in tests
+func SetClock(c clock.Clock) {
+	tw.SetClock(c)
+}
+
+// Pending returns count of expired jobs of the default time wheel waiting for workers
+func Pending() int {
+	return tw.workers.Pending()
 }
 
 // Cancel stops a pending job
@@ -3,6 +3,15 @@ package timewheel
 import (
 	"container/list"
 	"time"
+
+	"Godis/lib/clock"
+	"Godis/lib/pool/workers"
+)
+
+const (
+	// 执行到期任务的协程数上限及等待队列长度
+	maxWorkers     = 64
+	jobQueueLength = 4096
 )
 
 type location struct {
@@ -19,7 +28,8 @@ type task struct {
 
 type TimeWheel struct {
 	interval          time.Duration        // 时间轮的基本时间单元
-	ticker            *time.Ticker         // 用于定期触发时间轮的旋转，以驱动任务的执行
+	clock             clock.Clock          // 时间源，测试中可以替换为 clock.Fake
+	ticker            clock.Ticker         // 用于定期触发时间轮的旋转，以驱动任务的执行
 	slots             []*list.List         // 一个链表的切片，每个元素是时间轮中一个槽位对应的任务链表
 	timer             map[string]*location // 用于快速查找某个任务在时间轮中的位置
 	currentPos        int                  // 时间轮当前所处的槽位
@@ -27,6 +37,9 @@ type TimeWheel struct {
 	addTaskChannel    chan task            // 用于接收新任务的通道，通过该通道将任务添加到时间轮中
 	removeTaskChannel chan string          // 用于接收需要移除的任务的通道，通过该通道将指定任务从时间轮中移除
 	stopChannel       chan bool            // 用于停止时间轮
+	done              chan struct{}        // 时间轮停止后关闭，此后 AddJob 等操作不再阻塞
+	clockChannel      chan clock.Clock     // 用于替换时间源
+	workers           *workers.Pool        // 执行到期任务的协程池
 }
 
 // New create a new time wheel
@@ -43,6 +56,10 @@ func New(interval time.Duration, slotNum int) *TimeWheel {
 		addTaskChannel:    make(chan task),
 		removeTaskChannel: make(chan string),
 		stopChannel:       make(chan bool),
+		done:              make(chan struct{}),
+		clockChannel:      make(chan clock.Clock),
+		clock:             clock.Real,
+		workers:           workers.New(maxWorkers, jobQueueLength),
 	}
 	// 创建每个时间槽上对应的链表
 	tw.initSlots()
@@ -58,7 +75,7 @@ func (tw *TimeWheel) initSlots() {
 
 // Start starts ticker for time wheel
 func (tw *TimeWheel) Start() {
-	tw.ticker = time.NewTicker(tw.interval)
+	tw.ticker = tw.clock.NewTicker(tw.interval)
 	go tw.start()
 }
 
@@ -66,14 +83,21 @@ func (tw *TimeWheel) Start() {
 func (tw *TimeWheel) start() {
 	for {
 		select {
-		case <-tw.ticker.C:
+		case <-tw.ticker.Chan():
 			tw.tickHandler()
+		case c := <-tw.clockChannel:
+			tw.ticker.Stop()
+			tw.clock = c
+			tw.ticker = c.NewTicker(tw.interval)
 		case task := <-tw.addTaskChannel:
 			tw.addTask(&task)
 		case key := <-tw.removeTaskChannel:
 			tw.removeTask(key)
 		case <-tw.stopChannel:
 			tw.ticker.Stop()
+			// 先关闭 done 再等待协程池：正在执行的任务可能调用 AddJob，此时不能再阻塞在通道上
+			close(tw.done)
+			tw.workers.Close()
 			return
 		}
 	}
@@ -88,7 +112,8 @@ func (tw *TimeWheel) tickHandler() {
 	} else {
 		tw.currentPos++
 	}
-	go tw.scanAndRunTask(l)
+	// 在时间轮协程中扫描，避免与 addTask/removeTask 并发修改链表
+	tw.scanAndRunTask(l)
 }
 
 func (tw *TimeWheel) addTask(task *task) {
@@ -132,16 +157,10 @@ func (tw *TimeWheel) scanAndRunTask(l *list.List) {
 			continue
 		}
 
-		// 如果定时任务到时间，则开启协程执行
-		go func() {
-			defer func() {
-				if err := recover(); err != any(nil) {
-					// logger.Error(err)
-				}
-			}()
-			job := task.job
-			job()
-		}()
+		// 如果定时任务到时间，则交给协程池执行，协程池负责 recover
+		// 不能阻塞等待协程池：任务中可能再次调用 AddJob/RemoveJob，而只有时间轮协程读取这两个通道，
+		// 协程池已满时阻塞会导致 worker 与时间轮互相等待，因此超出队列的任务暂存在协程池的 overflow 中
+		_ = tw.workers.Post(task.job)
 		next := e.Next()
 		l.Remove(e)
 		if task.key != "" {
@@ -160,17 +179,33 @@ func (tw *TimeWheel) getPositionAndCircle(d time.Duration) (pos int, circle int)
 	return
 }
 
-// Stop stops the time wheel
+// SetClock replaces time source of a started time wheel, pending jobs are kept
+func (tw *TimeWheel) SetClock(c clock.Clock) {
+	select {
+	case tw.clockChannel <- c:
+	case <-tw.done:
+	}
+}
+
+// Stop stops the time wheel, jobs already expired are finished before time wheel goroutine exits
+// calling Stop more than once is a no-op
 func (tw *TimeWheel) Stop() {
-	tw.stopChannel <- true
+	select {
+	case tw.stopChannel <- true:
+	case <-tw.done:
+	}
 }
 
 // AddJob add new job into pending queue
+// jobs added after the time wheel stopped are discarded
 func (tw *TimeWheel) AddJob(delay time.Duration, key string, job func()) {
 	if delay < 0 {
 		return
 	}
-	tw.addTaskChannel <- task{delay: delay, key: key, job: job}
+	select {
+	case tw.addTaskChannel <- task{delay: delay, key: key, job: job}:
+	case <-tw.done:
+	}
 }
 
 // RemoveJob add remove job from pending queue
@@ -179,5 +214,8 @@ func (tw *TimeWheel) RemoveJob(key string) {
 	if key == "" {
 		return
 	}
-	tw.removeTaskChannel <- key
+	select {
+	case tw.removeTaskChannel <- key:
+	case <-tw.done:
+	}
 }
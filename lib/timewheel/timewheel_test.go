@@ -0,0 +1,122 @@
+package timewheel
+
+import (
+	"Godis/lib/clock"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 到期任务超过协程池容量且任务中再次添加任务时，时间轮不能与 worker 互相等待
+func TestRescheduleWhenWorkersFull(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	tw := New(time.Second, 8)
+	tw.clock = fake
+	tw.Start()
+
+	const jobs = maxWorkers + jobQueueLength + 100
+	var rescheduled, finished int32
+	for i := 0; i < jobs; i++ {
+		tw.AddJob(time.Second, "", func() {
+			atomic.AddInt32(&rescheduled, 1)
+			tw.AddJob(time.Second, "", func() {
+				atomic.AddInt32(&finished, 1)
+			})
+		})
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for atomic.LoadInt32(&finished) < jobs {
+		if time.Now().After(deadline) {
+			t.Fatalf("time wheel blocked, rescheduled %d, finished %d", atomic.LoadInt32(&rescheduled), atomic.LoadInt32(&finished))
+		}
+		fake.Advance(time.Second)
+		time.Sleep(10 * time.Millisecond)
+	}
+	// 阻塞时 Stop 也会阻塞，因此只在成功时停止
+	tw.Stop()
+}
+
+// 到期任务超过协程池容量时不会额外开启协程，多出的任务计入 Pending
+func TestOverflowJobsDoNotSpawnGoroutines(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	tw := New(time.Second, 8)
+	tw.clock = fake
+	tw.Start()
+	defer tw.Stop()
+
+	base := runtime.NumGoroutine()
+	const jobs = maxWorkers + jobQueueLength + 100
+	release := make(chan struct{})
+	var finished int32
+	for i := 0; i < jobs; i++ {
+		tw.AddJob(time.Second, "", func() {
+			<-release
+			atomic.AddInt32(&finished, 1)
+		})
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for tw.workers.Pending() != jobs-maxWorkers {
+		if time.Now().After(deadline) {
+			t.Fatalf("expect %d pending jobs, actual %d", jobs-maxWorkers, tw.workers.Pending())
+		}
+		fake.Advance(time.Second)
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > base+10 {
+		t.Errorf("expect no goroutine for overflow jobs, %d goroutines before and %d after", base, n)
+	}
+	close(release)
+	for atomic.LoadInt32(&finished) < jobs {
+		if time.Now().After(deadline) {
+			t.Fatalf("expect %d jobs finished, actual %d", jobs, atomic.LoadInt32(&finished))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 停止时正在执行的任务调用 AddJob/RemoveJob 不会阻塞，Stop 可以重复调用
+func TestStopWhileJobAddsJob(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	tw := New(time.Second, 8)
+	tw.clock = fake
+	tw.Start()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	added := make(chan struct{})
+	tw.AddJob(time.Second, "", func() {
+		close(started)
+		<-release
+		tw.AddJob(time.Second, "k", func() {})
+		tw.RemoveJob("k")
+		close(added)
+	})
+	advanceUntil(t, fake, started)
+	tw.Stop()
+	close(release)
+	select {
+	case <-added:
+	case <-time.After(time.Second):
+		t.Fatal("AddJob blocked after time wheel stopped")
+	}
+	tw.Stop()
+	tw.AddJob(time.Second, "", func() {})
+}
+
+// advanceUntil 不断推进时钟直到 ch 被关闭
+func advanceUntil(t *testing.T, fake *clock.Fake, ch chan struct{}) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		select {
+		case <-ch:
+			return
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("job is not executed")
+		}
+		fake.Advance(time.Second)
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"Godis/lib/random"
+	"context"
+	"errors"
+	"time"
+)
+
+// Backoff 计算重试前的等待时间，每次重试等待时间翻倍直到 Max，并加入随机抖动，
+// 避免多个冲突的协程以相同的节奏重试后再次冲突
+type Backoff struct {
+	Base    time.Duration
+	Max     time.Duration
+	attempt int
+}
+
+// Next returns wait time before next retry
+func (b *Backoff) Next() time.Duration {
+	d := b.Base << uint(b.attempt)
+	if d <= 0 || d > b.Max {
+		d = b.Max
+	} else {
+		b.attempt++
+	}
+	// 在 [d/2, d) 之间随机等待
+	half := int64(d / 2)
+	if half <= 0 {
+		return d
+	}
+	return time.Duration(half + random.Int63n(half))
+}
+
+// Wait sleeps for next backoff, returns ctx.Err() if ctx is done before that
+func (b *Backoff) Wait(ctx context.Context) error {
+	timer := time.NewTimer(b.Next())
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// ErrMaxAttempts is returned by Retry if all attempts failed
+var ErrMaxAttempts = errors.New("max attempts exceeded")
+
+// Retry calls attempt until it returns true, waits with backoff between attempts
+// attempt returning an error stops retrying and Retry returns the error
+// maxAttempts <= 0 means retrying until ctx is done, otherwise ErrMaxAttempts is returned after maxAttempts failures
+func Retry(ctx context.Context, backoff *Backoff, maxAttempts int, attempt func() (bool, error)) error {
+	for i := 0; maxAttempts <= 0 || i < maxAttempts; i++ {
+		if i > 0 {
+			if err := backoff.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		done, err := attempt()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+	return ErrMaxAttempts
+}
@@ -1,11 +1,7 @@
 package utils
 
-import (
-	"math/rand"
-	"time"
-)
+import "Godis/lib/random"
 
-var r = rand.New(rand.NewSource(time.Now().UnixNano()))
 var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
 
 // RandString create a random string no longer than n
@@ -13,7 +9,7 @@ var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ012345
 func RandString(n int) string {
 	b := make([]rune, n)
 	for i := range b {
-		b[i] = letters[r.Intn(len(letters))]
+		b[i] = letters[random.Intn(len(letters))]
 	}
 	return string(b)
 }
@@ -23,7 +19,7 @@ var hexLetters = []rune("0123456789abcdef")
 func RandHexString(n int) string {
 	b := make([]rune, n)
 	for i := range b {
-		b[i] = hexLetters[r.Intn(len(hexLetters))]
+		b[i] = hexLetters[random.Intn(len(hexLetters))]
 	}
 	return string(b)
 }
@@ -34,7 +30,7 @@ func RandIndex(size int) []int {
 	for i := range result {
 		result[i] = i
 	}
-	rand.Shuffle(size, func(i, j int) {
+	random.Shuffle(size, func(i, j int) {
 		result[i], result[j] = result[j], result[i]
 	})
 	return result
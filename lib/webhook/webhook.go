@@ -0,0 +1,266 @@
+// Package webhook posts server events (key expired, key evicted, node failover, slow command)
+// as json to user supplied urls with retry and rate limiting
+package webhook
+
+import (
+	"Godis/lib/logger"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// event types
+const (
+	EventExpired     = "key_expired"
+	EventEvicted     = "key_evicted"
+	EventFailover    = "node_failover"
+	EventSlowCommand = "slow_command"
+)
+
+const (
+	// queueSize is the max pending events of each url, new events are dropped when the queue is full
+	queueSize = 1024
+	// maxRetry is the max retry times of a failed request
+	maxRetry = 3
+	// retryBackoff is the wait time before first retry, doubled on each retry
+	retryBackoff = 200 * time.Millisecond
+	// defaultRateLimit is the max events published per second if not set
+	defaultRateLimit = 100
+	requestTimeout   = 5 * time.Second
+)
+
+// Event is the json body posted to webhook
+type Event struct {
+	Type string `json:"type"`
+	// Time is unix timestamp in milliseconds
+	Time   int64  `json:"time"`
+	DB     int    `json:"db"`
+	Key    string `json:"key,omitempty"`
+	Node   string `json:"node,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	// Duration of slow command in microseconds
+	Duration int64 `json:"duration,omitempty"`
+}
+
+// Settings stores config for Dispatcher
+type Settings struct {
+	URLs []string
+	// RateLimit is the max events published per second, 0 means defaultRateLimit
+	RateLimit int
+	// SlowThreshold commands slower than it are published as slow command events, 0 disables them
+	SlowThreshold time.Duration
+}
+
+// Stats counts events of dispatcher
+type Stats struct {
+	Sent    int64
+	Failed  int64
+	Dropped int64
+}
+
+// Dispatcher sends events to webhooks asynchronously
+// each url has its own queue and goroutine, so a slow endpoint won't block others
+type Dispatcher struct {
+	targets       []*target
+	client        *http.Client
+	limiter       *limiter
+	slowThreshold time.Duration
+	stats         Stats
+	closeChan     chan struct{}
+	wg            sync.WaitGroup
+}
+
+type target struct {
+	url   string
+	queue chan []byte
+}
+
+// MakeDispatcher creates a dispatcher and starts sending goroutines
+func MakeDispatcher(settings *Settings) *Dispatcher {
+	rate := settings.RateLimit
+	if rate <= 0 {
+		rate = defaultRateLimit
+	}
+	d := &Dispatcher{
+		client:        &http.Client{Timeout: requestTimeout},
+		limiter:       newLimiter(rate),
+		slowThreshold: settings.SlowThreshold,
+		closeChan:     make(chan struct{}),
+	}
+	for _, url := range settings.URLs {
+		if url == "" {
+			continue
+		}
+		t := &target{
+			url:   url,
+			queue: make(chan []byte, queueSize),
+		}
+		d.targets = append(d.targets, t)
+		d.wg.Add(1)
+		go d.send(t)
+	}
+	return d
+}
+
+// Publish puts event into queues without blocking, event is dropped if rate limit exceeded or queue full
+func (d *Dispatcher) Publish(event *Event) {
+	if len(d.targets) == 0 {
+		return
+	}
+	if !d.limiter.allow() {
+		atomic.AddInt64(&d.stats.Dropped, 1)
+		return
+	}
+	if event.Time == 0 {
+		event.Time = time.Now().UnixMilli()
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.Error("marshal webhook event failed: " + err.Error())
+		return
+	}
+	for _, t := range d.targets {
+		select {
+		case t.queue <- body:
+		default:
+			atomic.AddInt64(&d.stats.Dropped, 1)
+		}
+	}
+}
+
+// IsSlow returns whether a command took duration should be published as slow command
+func (d *Dispatcher) IsSlow(duration time.Duration) bool {
+	return d.slowThreshold > 0 && duration >= d.slowThreshold
+}
+
+// GetStats returns counters of dispatcher
+func (d *Dispatcher) GetStats() Stats {
+	return Stats{
+		Sent:    atomic.LoadInt64(&d.stats.Sent),
+		Failed:  atomic.LoadInt64(&d.stats.Failed),
+		Dropped: atomic.LoadInt64(&d.stats.Dropped),
+	}
+}
+
+// Close stops sending, pending events are discarded
+func (d *Dispatcher) Close() {
+	close(d.closeChan)
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) send(t *target) {
+	defer d.wg.Done()
+	for {
+		select {
+		case body := <-t.queue:
+			if d.post(t.url, body) {
+				atomic.AddInt64(&d.stats.Sent, 1)
+			} else {
+				atomic.AddInt64(&d.stats.Failed, 1)
+			}
+		case <-d.closeChan:
+			return
+		}
+	}
+}
+
+// post sends body to url, retries with exponential backoff on network error, 5xx or 429
+func (d *Dispatcher) post(url string, body []byte) bool {
+	backoff := retryBackoff
+	for i := 0; ; i++ {
+		retryable := false
+		resp, err := d.client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			retryable = true
+		} else {
+			_ = resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return true
+			}
+			retryable = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		}
+		if !retryable || i >= maxRetry {
+			if err != nil {
+				logger.Warn("webhook " + url + " failed: " + err.Error())
+			} else {
+				logger.Warn("webhook " + url + " failed: " + resp.Status)
+			}
+			return false
+		}
+		select {
+		case <-time.After(backoff):
+		case <-d.closeChan:
+			return false
+		}
+		backoff *= 2
+	}
+}
+
+// limiter is a token bucket allowing rate events per second
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(rate int) *limiter {
+	return &limiter{
+		rate:   float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+func (l *limiter) allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+/* ---- default dispatcher ---- */
+
+// dispatcher is the global dispatcher, nil means webhook disabled
+var dispatcher *Dispatcher
+
+// Setup creates the global dispatcher, must be called before serving
+func Setup(settings *Settings) {
+	dispatcher = MakeDispatcher(settings)
+}
+
+// Enabled returns whether webhook is configured
+func Enabled() bool {
+	return dispatcher != nil
+}
+
+// Publish sends event with global dispatcher, does nothing if webhook disabled
+func Publish(event *Event) {
+	if dispatcher != nil {
+		dispatcher.Publish(event)
+	}
+}
+
+// IsSlow returns whether a command should be published as slow command
+func IsSlow(duration time.Duration) bool {
+	return dispatcher != nil && dispatcher.IsSlow(duration)
+}
+
+// Close stops the global dispatcher
+func Close() {
+	if dispatcher != nil {
+		dispatcher.Close()
+	}
+}
@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDispatcher(t *testing.T) {
+	var calls int32
+	received := make(chan *Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// first request fails, dispatcher should retry
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		event := &Event{}
+		if err := json.NewDecoder(r.Body).Decode(event); err != nil {
+			t.Error(err)
+		}
+		received <- event
+	}))
+	defer server.Close()
+
+	d := MakeDispatcher(&Settings{
+		URLs:      []string{server.URL},
+		RateLimit: 1,
+	})
+	defer d.Close()
+	d.Publish(&Event{Type: EventExpired, DB: 1, Key: "foo"})
+	// exceeds rate limit
+	d.Publish(&Event{Type: EventExpired, DB: 1, Key: "bar"})
+
+	select {
+	case event := <-received:
+		if event.Type != EventExpired || event.DB != 1 || event.Key != "foo" || event.Time == 0 {
+			t.Errorf("unexpected event: %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not received")
+	}
+	stats := d.GetStats()
+	if atomic.LoadInt32(&calls) != 2 || stats.Dropped != 1 {
+		t.Errorf("expect 2 calls and 1 dropped, actual %d calls, stats %+v", calls, stats)
+	}
+}
@@ -1,13 +1,24 @@
 package main
 
 import (
-	"Godis/config"
+	"Godis/internal/config"
+	"Godis/internal/redis/dashboard"
+	"Godis/internal/redis/doctor"
+	"Godis/internal/redis/gateway"
+	"Godis/internal/redis/memcached"
+	RedisServer "Godis/internal/redis/server"
+	"Godis/internal/redis/websocket"
+	"Godis/internal/tcp"
+	"Godis/lib/fileutil"
 	"Godis/lib/logger"
 	"Godis/lib/utils"
-	RedisServer "Godis/redis/server"
-	"Godis/tcp"
+	"Godis/lib/webhook"
+	"crypto/tls"
+	"flag"
 	"fmt"
+	"net"
 	"os"
+	"time"
 )
 
 var banner = `
@@ -32,27 +43,166 @@ func fileExists(filename string) bool {
 	return err == nil && !info.IsDir()
 }
 
+var force = flag.Bool("force", false, "remove data dir lock left by a crashed instance")
+
+var doctorMode = flag.Bool("doctor", false, "check config, ports, data files and limits, then exit, same as `godis doctor`")
+
+// getConfigFilename returns config file used by server, empty means using defaults
+func getConfigFilename() string {
+	configFilename := os.Getenv("CONFIG")
+	if configFilename == "" && fileExists("redis.conf") {
+		configFilename = "redis.conf"
+	}
+	return configFilename
+}
+
+// runDoctor runs all checks of doctor and returns exit code, 1 if any error found
+func runDoctor() int {
+	defaultProperties.ResolvePaths()
+	report := doctor.Run(getConfigFilename(), defaultProperties)
+	report.Print(os.Stdout)
+	if report.HasError() {
+		return 1
+	}
+	return 0
+}
+
 func main() {
+	flag.Parse()
+	if *doctorMode || flag.Arg(0) == "doctor" {
+		os.Exit(runDoctor())
+	}
 	print(banner)
+	configFilename := getConfigFilename()
+	if configFilename == "" {
+		config.Properties = defaultProperties
+		config.Properties.ResolvePaths()
+	} else {
+		config.SetupConfig(configFilename)
+	}
+	// 日志文件同样放在数据目录下
 	logger.Setup(&logger.Settings{
-		Path:       "logs",
+		Path:       config.GetDataPath("logs"),
 		Name:       "godis",
 		Ext:        "log",
 		TimeFormat: "2006-01-02",
 	})
-	configFilename := os.Getenv("CONFIG")
-	if configFilename == "" {
-		if fileExists("redis.conf") {
-			config.SetupConfig("redis.conf")
-		} else {
-			config.Properties = defaultProperties
+	// 防止两个实例共用同一个数据目录，同时追加写 AOF 导致文件损坏
+	dirLock, err := fileutil.LockDir(config.Properties.Dir, *force)
+	if err != nil {
+		logger.Fatal(err)
+		os.Exit(1)
+	}
+	defer func() {
+		_ = dirLock.Unlock()
+	}()
+	// 启动前自检，只记录警告，无法启动的错误仍由之后的步骤报告
+	for _, finding := range doctor.Diagnose(config.Properties, configFilename).Findings {
+		if finding.Level != doctor.LevelOK {
+			logger.Warn(finding.String())
 		}
-	} else {
-		config.SetupConfig(configFilename)
 	}
-	err := tcp.ListenAndServeWithSignal(&tcp.Config{
-		Address: fmt.Sprintf("%s:%d", config.Properties.Bind, config.Properties.Port),
-	}, RedisServer.MakeHandler())
+	if len(config.Properties.WebhookURLs) > 0 {
+		webhook.Setup(&webhook.Settings{
+			URLs:          config.Properties.WebhookURLs,
+			RateLimit:     config.Properties.WebhookRateLimit,
+			SlowThreshold: time.Duration(config.Properties.WebhookSlowTime) * time.Millisecond,
+		})
+		defer webhook.Close()
+	}
+	tcpConfig := &tcp.Config{}
+	// port 0 disables plaintext listener, like redis
+	if config.Properties.Port > 0 {
+		tcpConfig.Address = fmt.Sprintf("%s:%d", config.Properties.Bind, config.Properties.Port)
+	}
+	if config.Properties.TLSPort > 0 {
+		cert, err := tls.LoadX509KeyPair(config.Properties.TLSCertFile, config.Properties.TLSKeyFile)
+		if err != nil {
+			logger.Fatal("load tls certificate failed: " + err.Error())
+			os.Exit(1)
+		}
+		tcpConfig.TLSAddress = fmt.Sprintf("%s:%d", config.Properties.Bind, config.Properties.TLSPort)
+		tcpConfig.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		}
+	}
+	handler := RedisServer.MakeHandler()
+	if config.Properties.GatewayPort > 0 {
+		gatewayAddr := fmt.Sprintf("%s:%d", config.Properties.Bind, config.Properties.GatewayPort)
+		listener, err := net.Listen("tcp", gatewayAddr)
+		if err != nil {
+			logger.Fatal("http gateway listen failed: " + err.Error())
+			os.Exit(1)
+		}
+		g := gateway.MakeGateway(handler.GetDB())
+		go func() {
+			if err := g.Serve(listener); err != nil {
+				logger.Error(err)
+			}
+		}()
+		defer func() {
+			_ = g.Close()
+		}()
+	}
+	if config.Properties.WebSocketPort > 0 {
+		wsAddr := fmt.Sprintf("%s:%d", config.Properties.Bind, config.Properties.WebSocketPort)
+		listener, err := net.Listen("tcp", wsAddr)
+		if err != nil {
+			logger.Fatal("websocket bridge listen failed: " + err.Error())
+			os.Exit(1)
+		}
+		bridge := websocket.MakeBridge(handler, config.Properties.WebSocketOrigins)
+		go func() {
+			if err := bridge.Serve(listener); err != nil {
+				logger.Error(err)
+			}
+		}()
+		defer func() {
+			_ = bridge.Close()
+		}()
+	}
+	if config.Properties.DashboardPort > 0 {
+		d, err := dashboard.MakeDashboard(handler.GetDB(), &dashboard.Settings{
+			User:        config.Properties.DashboardUser,
+			Password:    config.Properties.DashboardPassword,
+			RequirePass: config.Properties.RequirePass,
+		})
+		if err != nil {
+			logger.Fatal(err)
+			os.Exit(1)
+		}
+		dashboardAddr := fmt.Sprintf("%s:%d", config.Properties.Bind, config.Properties.DashboardPort)
+		listener, err := net.Listen("tcp", dashboardAddr)
+		if err != nil {
+			logger.Fatal("dashboard listen failed: " + err.Error())
+			os.Exit(1)
+		}
+		go func() {
+			if err := d.Serve(listener); err != nil {
+				logger.Error(err)
+			}
+		}()
+		defer func() {
+			_ = d.Close()
+		}()
+	}
+	if config.Properties.MemcachedPort > 0 {
+		if config.Properties.RequirePass != "" && !config.Properties.MemcachedNoAuth {
+			logger.Fatal("memcached adapter bypasses requirepass, set memcached-no-auth yes to enable it")
+			os.Exit(1)
+		}
+		memcachedAddr := fmt.Sprintf("%s:%d", config.Properties.Bind, config.Properties.MemcachedPort)
+		listener, err := net.Listen("tcp", memcachedAddr)
+		if err != nil {
+			logger.Fatal("memcached adapter listen failed: " + err.Error())
+			os.Exit(1)
+		}
+		logger.Info("memcached adapter listening on " + memcachedAddr)
+		closeChan := make(chan struct{})
+		go tcp.ListenAndServe(listener, memcached.MakeHandler(handler.GetDB(), config.Properties.RequirePass), closeChan)
+		defer close(closeChan)
+	}
+	err = tcp.ListenAndServeWithSignal(tcpConfig, handler)
 	if err != nil {
 		logger.Error(err)
 	}
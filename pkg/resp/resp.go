@@ -0,0 +1,73 @@
+// Package resp is a standalone RESP codec for Go programs which talk to Godis or Redis
+//
+// 该包是 redis/parser 和 redis/protocol 对外的稳定接口，第三方程序只需依赖这里导出的类型和函数，
+// 内部包的重构不会影响调用方
+package resp
+
+import (
+	"Godis/interface/redis"
+	"Godis/redis/parser"
+	"Godis/redis/protocol"
+	"io"
+)
+
+// Reply is a decoded RESP message, ToBytes returns its wire format
+type Reply = redis.Reply
+
+// Decoder reads RESP messages from a stream
+type Decoder struct {
+	ch <-chan *parser.Payload
+}
+
+// NewDecoder creates a Decoder reading from r
+// 解析在后台协程中进行，直到 r 返回错误为止
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		ch: parser.ParseStream(r),
+	}
+}
+
+// Decode returns the next message, returns io.EOF when the stream ended
+// 协议错误不会中断解析，调用方可以继续读取后面的消息
+// 读取失败后 parser 会关闭通道，之后的调用都返回 io.EOF
+func (d *Decoder) Decode() (Reply, error) {
+	payload, ok := <-d.ch
+	if !ok || payload == nil {
+		return nil, io.EOF
+	}
+	if payload.Err != nil {
+		return nil, payload.Err
+	}
+	return payload.Data, nil
+}
+
+// Encoder writes RESP messages into a stream
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder creates an Encoder writing into w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes reply in RESP format
+func (e *Encoder) Encode(reply Reply) error {
+	_, err := e.w.Write(reply.ToBytes())
+	return err
+}
+
+// EncodeCommand writes a command line as RESP array of bulk strings
+func (e *Encoder) EncodeCommand(args ...[]byte) error {
+	return e.Encode(protocol.MakeMultiBulkReply(args))
+}
+
+// Parse decodes all messages in data
+func Parse(data []byte) ([]Reply, error) {
+	return parser.ParseBytes(data)
+}
+
+// IsError returns true if reply is an error reply
+func IsError(reply Reply) bool {
+	return protocol.IsErrorReply(reply)
+}
@@ -0,0 +1,48 @@
+package resp
+
+import (
+	"Godis/redis/protocol"
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	replies := []Reply{
+		protocol.MakeStatusReply("OK"),
+		protocol.MakeErrReply("ERR unknown"),
+		protocol.MakeIntReply(42),
+		protocol.MakeBulkReply([]byte("a\r\nb")),
+	}
+	for _, reply := range replies {
+		if err := enc.Encode(reply); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.EncodeCommand([]byte("SET"), []byte("key"), []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	replies = append(replies, protocol.MakeMultiBulkReply([][]byte{[]byte("SET"), []byte("key"), []byte("value")}))
+
+	dec := NewDecoder(bytes.NewReader(buf.Bytes()))
+	for _, expected := range replies {
+		reply, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(reply.ToBytes(), expected.ToBytes()) {
+			t.Errorf("expected %q, actual %q", expected.ToBytes(), reply.ToBytes())
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("expected EOF, actual %v", err)
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("expected EOF after stream ended, actual %v", err)
+	}
+	if !IsError(replies[1]) || IsError(replies[0]) {
+		t.Error("IsError failed")
+	}
+}
@@ -28,9 +28,26 @@ type Client struct {
 	waitingReqs chan *request // waiting response
 	ticker      *time.Ticker
 	addr        string
+	keepalive   time.Duration // send PING if connection is idle longer than keepalive, 0 disables it
+	lastActive  int64         // unix nano of last read or write
+
+	stats    Stats
+	failures int32 // consecutive timeouts and errors, reset when receives reply
 
 	status  int32
 	working *sync.WaitGroup // its counter presents unfinished requests(pending and waiting)
+
+	// subscription state, see pubsub.go
+	subMu    sync.Mutex
+	channels map[string]MessageHandler
+	patterns map[string]MessageHandler
+	pushMode int32 // 1 while the connection has subscriptions
+	pushChan chan *Message
+	stopChan chan struct{}
+
+	// heartbeat goroutine exits after heartbeatStop closed, then closes heartbeatDone
+	heartbeatStop chan struct{}
+	heartbeatDone chan struct{}
 }
 
 // request is a message sends to redis server
@@ -39,6 +56,7 @@ type request struct {
 	args      [][]byte
 	reply     redis.Reply
 	heartbeat bool
+	push      bool // replies of push requests arrive as push messages, do not wait for them
 	waiting   *wait.Wait
 	err       error
 }
@@ -46,8 +64,21 @@ type request struct {
 const (
 	chanSize = 256
 	maxWait  = 3 * time.Second
+
+	defaultKeepalive = 10 * time.Second
+	// a client is considered unhealthy after maxFailures consecutive failures
+	maxFailures = 3
 )
 
+// Stats holds counters of a client connection
+type Stats struct {
+	Sent       int64 // commands written to server, including heartbeats
+	Received   int64 // replies read from server, including push messages
+	Reconnects int64
+	Timeouts   int64
+	Dropped    int64 // push messages dropped because handlers are too slow
+}
+
 // MakeClient creates a new client
 func MakeClient(addr string) (*Client, error) {
 	conn, err := net.Dial("tcp", addr)
@@ -57,25 +88,50 @@ func MakeClient(addr string) (*Client, error) {
 	return &Client{
 		addr:        addr,
 		conn:        conn,
+		keepalive:   defaultKeepalive,
 		pendingReqs: make(chan *request, chanSize),
 		waitingReqs: make(chan *request, chanSize),
 		working:     &sync.WaitGroup{},
+		channels:    make(map[string]MessageHandler),
+		patterns:    make(map[string]MessageHandler),
+		pushChan:    make(chan *Message, chanSize),
+		stopChan:    make(chan struct{}),
 	}, nil
 }
 
+// SetKeepalive sets interval of PING keepalive on idle connection, 0 disables keepalive
+// It should be called before Start
+func (client *Client) SetKeepalive(interval time.Duration) {
+	client.keepalive = interval
+}
+
 // Start starts asynchronous goroutines
 func (client *Client) Start() {
-	client.ticker = time.NewTicker(10 * time.Second)
+	client.touch()
 	go client.handleWrite()
 	go client.handleRead()
-	go client.heartbeat()
+	if client.keepalive > 0 {
+		client.ticker = time.NewTicker(client.keepalive)
+		client.heartbeatStop = make(chan struct{})
+		client.heartbeatDone = make(chan struct{})
+		go client.heartbeat()
+	}
+	go client.dispatch()
 	atomic.StoreInt32(&client.status, running)
 }
 
 // Close stops asynchronous goroutines and close connection
+// Calling Close more than once is a no-op, a client closed after failing to reconnect could be closed again by its owner
 func (client *Client) Close() {
-	atomic.StoreInt32(&client.status, closed)
-	client.ticker.Stop()
+	if atomic.SwapInt32(&client.status, closed) == closed {
+		return
+	}
+	if client.ticker != nil {
+		client.ticker.Stop()
+		// 等待正在发送的 PING 结束，避免向已关闭的 pendingReqs 发送
+		close(client.heartbeatStop)
+		<-client.heartbeatDone
+	}
 	// stop new request
 	close(client.pendingReqs)
 
@@ -83,6 +139,7 @@ func (client *Client) Close() {
 	client.working.Wait()
 
 	// clean
+	close(client.stopChan)
 	_ = client.conn.Close()
 	close(client.waitingReqs)
 }
@@ -108,6 +165,7 @@ func (client *Client) reconnect() {
 		return
 	}
 	client.conn = conn
+	atomic.AddInt64(&client.stats.Reconnects, 1)
 
 	close(client.waitingReqs)
 	for req := range client.waitingReqs {
@@ -117,14 +175,53 @@ func (client *Client) reconnect() {
 	client.waitingReqs = make(chan *request, chanSize)
 	// restart handle read
 	go client.handleRead()
+	// subscriptions are bound to connection, restore them on the new one
+	client.resubscribe()
 }
 
 func (client *Client) heartbeat() {
-	for range client.ticker.C {
-		client.doHeartbeat()
+	defer close(client.heartbeatDone)
+	for {
+		select {
+		case <-client.ticker.C:
+			idle := time.Since(time.Unix(0, atomic.LoadInt64(&client.lastActive)))
+			if idle >= client.keepalive {
+				client.doHeartbeat()
+			}
+		case <-client.heartbeatStop:
+			return
+		}
 	}
 }
 
+func (client *Client) touch() {
+	atomic.StoreInt64(&client.lastActive, time.Now().UnixNano())
+}
+
+// GetStats returns a snapshot of counters
+func (client *Client) GetStats() Stats {
+	return Stats{
+		Sent:       atomic.LoadInt64(&client.stats.Sent),
+		Received:   atomic.LoadInt64(&client.stats.Received),
+		Reconnects: atomic.LoadInt64(&client.stats.Reconnects),
+		Timeouts:   atomic.LoadInt64(&client.stats.Timeouts),
+		Dropped:    atomic.LoadInt64(&client.stats.Dropped),
+	}
+}
+
+// Healthy returns false if client has closed or failed continuously
+func (client *Client) Healthy() bool {
+	return atomic.LoadInt32(&client.status) == running &&
+		atomic.LoadInt32(&client.failures) < maxFailures
+}
+
+func (client *Client) markFailure(timeout bool) {
+	if timeout {
+		atomic.AddInt64(&client.stats.Timeouts, 1)
+	}
+	atomic.AddInt32(&client.failures, 1)
+}
+
 func (client *Client) handleWrite() {
 	for req := range client.pendingReqs {
 		client.doRequest(req)
@@ -147,9 +244,11 @@ func (client *Client) Send(args [][]byte) redis.Reply {
 	client.pendingReqs <- req
 	timeout := req.waiting.WaitWithTimeout(maxWait)
 	if timeout {
+		client.markFailure(true)
 		return protocol.MakeErrReply("server time out")
 	}
 	if req.err != nil {
+		client.markFailure(false)
 		return protocol.MakeErrReply("request failed " + req.err.Error())
 	}
 	return req.reply
@@ -165,7 +264,9 @@ func (client *Client) doHeartbeat() {
 	client.working.Add(1)
 	defer client.working.Done()
 	client.pendingReqs <- request
-	request.waiting.WaitWithTimeout(maxWait)
+	if request.waiting.WaitWithTimeout(maxWait) {
+		client.markFailure(true)
+	}
 }
 
 func (client *Client) doRequest(req *request) {
@@ -184,6 +285,12 @@ func (client *Client) doRequest(req *request) {
 		}
 	}
 	if err == nil {
+		atomic.AddInt64(&client.stats.Sent, 1)
+		client.touch()
+	}
+	if err == nil && req.push {
+		req.waiting.Done()
+	} else if err == nil {
 		client.waitingReqs <- req
 	} else {
 		req.err = err
@@ -219,6 +326,12 @@ func (client *Client) handleRead() {
 			client.reconnect()
 			return
 		}
+		atomic.AddInt64(&client.stats.Received, 1)
+		atomic.StoreInt32(&client.failures, 0)
+		client.touch()
+		if client.handlePush(payload.Data) {
+			continue
+		}
 		client.finishRequest(payload.Data)
 	}
 }
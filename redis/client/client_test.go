@@ -0,0 +1,183 @@
+package client_test
+
+import (
+	"Godis/internal/config"
+	"Godis/internal/redis/server"
+	"Godis/internal/tcp"
+	"Godis/lib/utils"
+	"Godis/redis/client"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// 每个 Server 有16个数据库，默认的 65536 个分段会让每个测试占用数百MB内存
+	config.Properties.DataDictShards = 1024
+	os.Exit(m.Run())
+}
+
+// startServer starts a standalone godis server, returns its address
+func startServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeChan := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		tcp.ListenAndServe(listener, server.MakeHandler(), closeChan)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		close(closeChan)
+		<-done
+	})
+	return listener.Addr().String()
+}
+
+// proxy forwards connections to target, so that tests could break connections between client and server
+type proxy struct {
+	listener net.Listener
+	target   string
+	mu       sync.Mutex
+	conns    []net.Conn
+}
+
+func startProxy(t *testing.T, target string) *proxy {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &proxy{
+		listener: listener,
+		target:   target,
+	}
+	go p.serve()
+	t.Cleanup(p.close)
+	return p
+}
+
+func (p *proxy) addr() string {
+	return p.listener.Addr().String()
+}
+
+func (p *proxy) serve() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		upstream, err := net.Dial("tcp", p.target)
+		if err != nil {
+			_ = conn.Close()
+			continue
+		}
+		p.mu.Lock()
+		p.conns = append(p.conns, conn, upstream)
+		p.mu.Unlock()
+		go func() {
+			_, _ = io.Copy(upstream, conn)
+			_ = upstream.Close()
+		}()
+		go func() {
+			_, _ = io.Copy(conn, upstream)
+			_ = conn.Close()
+		}()
+	}
+}
+
+// drop closes all forwarded connections, new connections are still accepted
+func (p *proxy) drop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conn := range p.conns {
+		_ = conn.Close()
+	}
+	p.conns = nil
+}
+
+// close stops accepting connections and closes forwarded connections
+func (p *proxy) close() {
+	_ = p.listener.Close()
+	p.drop()
+}
+
+// waitFor polls condition until it is true
+func waitFor(t *testing.T, timeout time.Duration, condition func() bool, msg string) {
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestKeepalive(t *testing.T) {
+	addr := startServer(t)
+	c, err := client.MakeClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetKeepalive(20 * time.Millisecond)
+	c.Start()
+	defer c.Close()
+	// 空闲连接上定期发送 PING
+	waitFor(t, 2*time.Second, func() bool {
+		stats := c.GetStats()
+		return stats.Sent >= 3 && stats.Received == stats.Sent
+	}, "expect PING on idle connection")
+	if !c.Healthy() {
+		t.Error("expect healthy client")
+	}
+
+	disabled, err := client.MakeClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disabled.SetKeepalive(0)
+	disabled.Start()
+	defer disabled.Close()
+	time.Sleep(100 * time.Millisecond)
+	if stats := disabled.GetStats(); stats.Sent != 0 {
+		t.Errorf("expect no PING when keepalive is disabled, actual %+v", stats)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	p := startProxy(t, startServer(t))
+	c, err := client.MakeClient(p.addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+	defer c.Close()
+	if reply := c.Send(utils.ToCmdLine("SET", "k", "v")); string(reply.ToBytes()) != "+OK\r\n" {
+		t.Fatalf("unexpected reply %q", reply.ToBytes())
+	}
+
+	// 连接断开后重连，客户端仍然健康
+	p.drop()
+	waitFor(t, 5*time.Second, func() bool {
+		return c.GetStats().Reconnects == 1
+	}, "expect reconnecting")
+	if reply := c.Send(utils.ToCmdLine("GET", "k")); string(reply.ToBytes()) != "$1\r\nv\r\n" {
+		t.Errorf("unexpected reply after reconnecting %q", reply.ToBytes())
+	}
+	if !c.Healthy() {
+		t.Error("expect healthy client after reconnecting")
+	}
+
+	// 无法重连时客户端关闭，变为不健康
+	p.close()
+	waitFor(t, 10*time.Second, func() bool {
+		return !c.Healthy()
+	}, "expect unhealthy client after failing to reconnect")
+	if reply := c.Send(utils.ToCmdLine("GET", "k")); string(reply.ToBytes()) != "-client closed\r\n" {
+		t.Errorf("expect client closed, actual %q", reply.ToBytes())
+	}
+}
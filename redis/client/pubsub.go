@@ -0,0 +1,233 @@
+package client
+
+import (
+	"Godis/interface/redis"
+	"Godis/lib/logger"
+	"Godis/lib/sync/wait"
+	"Godis/redis/protocol"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Message is a message pushed by server to a subscribing client
+type Message struct {
+	// Pattern is the matched pattern, only valid for messages received by PSubscribe
+	Pattern string
+	Channel string
+	Payload []byte
+}
+
+// MessageHandler handles messages of subscribed channels, it runs on a dedicated goroutine
+type MessageHandler func(msg *Message)
+
+const (
+	kindMessage      = "message"
+	kindPMessage     = "pmessage"
+	kindSubscribe    = "subscribe"
+	kindPSubscribe   = "psubscribe"
+	kindUnsubscribe  = "unsubscribe"
+	kindPUnsubscribe = "punsubscribe"
+)
+
+// Subscribe subscribes the given channels, messages will be dispatched to handler
+// The connection switches into push mode until all subscriptions are cancelled
+func (client *Client) Subscribe(handler MessageHandler, channels ...string) error {
+	if len(channels) == 0 {
+		return errors.New("no channel to subscribe")
+	}
+	client.subMu.Lock()
+	for _, channel := range channels {
+		client.channels[channel] = handler
+	}
+	atomic.StoreInt32(&client.pushMode, 1)
+	client.subMu.Unlock()
+	return client.sendPush(kindSubscribe, channels)
+}
+
+// PSubscribe subscribes channels matching the given patterns
+func (client *Client) PSubscribe(handler MessageHandler, patterns ...string) error {
+	if len(patterns) == 0 {
+		return errors.New("no pattern to subscribe")
+	}
+	client.subMu.Lock()
+	for _, pattern := range patterns {
+		client.patterns[pattern] = handler
+	}
+	atomic.StoreInt32(&client.pushMode, 1)
+	client.subMu.Unlock()
+	return client.sendPush(kindPSubscribe, patterns)
+}
+
+// UnSubscribe cancels the given channels, cancels all channels if none given
+func (client *Client) UnSubscribe(channels ...string) error {
+	client.subMu.Lock()
+	if len(channels) == 0 {
+		client.channels = make(map[string]MessageHandler)
+	}
+	for _, channel := range channels {
+		delete(client.channels, channel)
+	}
+	client.subMu.Unlock()
+	return client.sendPush(kindUnsubscribe, channels)
+}
+
+// PUnSubscribe cancels the given patterns, cancels all patterns if none given
+func (client *Client) PUnSubscribe(patterns ...string) error {
+	client.subMu.Lock()
+	if len(patterns) == 0 {
+		client.patterns = make(map[string]MessageHandler)
+	}
+	for _, pattern := range patterns {
+		delete(client.patterns, pattern)
+	}
+	client.subMu.Unlock()
+	return client.sendPush(kindPUnsubscribe, patterns)
+}
+
+// sendPush sends a command whose replies arrive as push messages
+func (client *Client) sendPush(cmd string, targets []string) error {
+	if atomic.LoadInt32(&client.status) != running {
+		return errors.New("client closed")
+	}
+	args := make([][]byte, 0, len(targets)+1)
+	args = append(args, []byte(cmd))
+	for _, target := range targets {
+		args = append(args, []byte(target))
+	}
+	req := &request{
+		args:    args,
+		push:    true,
+		waiting: &wait.Wait{},
+	}
+	req.waiting.Add(1)
+	client.working.Add(1)
+	defer client.working.Done()
+	client.pendingReqs <- req
+	if req.waiting.WaitWithTimeout(maxWait) {
+		client.markFailure(true)
+		return errors.New("server time out")
+	}
+	if req.err != nil {
+		client.markFailure(false)
+	}
+	return req.err
+}
+
+// resubscribe restores subscriptions after reconnected
+func (client *Client) resubscribe() {
+	if atomic.LoadInt32(&client.status) != running {
+		return
+	}
+	client.subMu.Lock()
+	channels := make([]string, 0, len(client.channels))
+	for channel := range client.channels {
+		channels = append(channels, channel)
+	}
+	patterns := make([]string, 0, len(client.patterns))
+	for pattern := range client.patterns {
+		patterns = append(patterns, pattern)
+	}
+	client.subMu.Unlock()
+	// reconnect runs on read goroutine, send asynchronously to avoid blocking it
+	go func() {
+		if len(channels) > 0 {
+			if err := client.sendPush(kindSubscribe, channels); err != nil {
+				logger.Error("resubscribe failed: " + err.Error())
+			}
+		}
+		if len(patterns) > 0 {
+			if err := client.sendPush(kindPSubscribe, patterns); err != nil {
+				logger.Error("resubscribe failed: " + err.Error())
+			}
+		}
+	}()
+}
+
+// handlePush consumes reply if it is a push message, returns false if reply belongs to a waiting request
+func (client *Client) handlePush(reply redis.Reply) bool {
+	if atomic.LoadInt32(&client.pushMode) == 0 {
+		return false
+	}
+	mbr, ok := reply.(*protocol.MultiBulkReply)
+	if !ok || len(mbr.Args) < 3 {
+		return false
+	}
+	kind := strings.ToLower(string(mbr.Args[0]))
+	switch kind {
+	case kindMessage:
+		client.deliver(&Message{
+			Channel: string(mbr.Args[1]),
+			Payload: mbr.Args[2],
+		})
+	case kindPMessage:
+		if len(mbr.Args) != 4 {
+			return false
+		}
+		client.deliver(&Message{
+			Pattern: string(mbr.Args[1]),
+			Channel: string(mbr.Args[2]),
+			Payload: mbr.Args[3],
+		})
+	case kindSubscribe, kindPSubscribe:
+		// subscribe confirmation, nothing to do
+	case kindUnsubscribe, kindPUnsubscribe:
+		count, _ := strconv.Atoi(string(mbr.Args[2]))
+		if count == 0 {
+			client.subMu.Lock()
+			if len(client.channels) == 0 && len(client.patterns) == 0 {
+				atomic.StoreInt32(&client.pushMode, 0)
+			}
+			client.subMu.Unlock()
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// deliver queues message for dispatch without blocking the read goroutine
+// 队列已满时(处理函数太慢)丢弃消息并计入 Stats.Dropped，否则阻塞读取会使同一连接上的普通回复也无法被读取
+func (client *Client) deliver(msg *Message) {
+	select {
+	case client.pushChan <- msg:
+	default:
+		if atomic.AddInt64(&client.stats.Dropped, 1) == 1 {
+			logger.Warn("message handler is too slow, drop messages of channel " + msg.Channel)
+		}
+	}
+}
+
+// dispatch calls handlers on a dedicated goroutine, messages are dropped if handlers fall behind by more than chanSize messages
+func (client *Client) dispatch() {
+	for {
+		select {
+		case msg := <-client.pushChan:
+			client.subMu.Lock()
+			var handler MessageHandler
+			if msg.Pattern != "" {
+				handler = client.patterns[msg.Pattern]
+			} else {
+				handler = client.channels[msg.Channel]
+			}
+			client.subMu.Unlock()
+			if handler != nil {
+				callHandler(handler, msg)
+			}
+		case <-client.stopChan:
+			return
+		}
+	}
+}
+
+func callHandler(handler MessageHandler, msg *Message) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error(fmt.Sprintf("message handler panic: %v\n%s", err, string(debug.Stack())))
+		}
+	}()
+	handler(msg)
+}
@@ -0,0 +1,200 @@
+package client_test
+
+import (
+	"Godis/lib/utils"
+	"Godis/redis/client"
+	"Godis/redis/parser"
+	"Godis/redis/protocol"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startClient(t *testing.T, addr string) *client.Client {
+	c, err := client.MakeClient(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+	t.Cleanup(c.Close)
+	return c
+}
+
+// receive waits for a message from ch
+func receive(t *testing.T, ch <-chan *client.Message) *client.Message {
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(3 * time.Second):
+		t.Fatal("message is not received")
+		return nil
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	addr := startServer(t)
+	subscriber := startClient(t, addr)
+	publisher := startClient(t, addr)
+	received := make(chan *client.Message, 16)
+	if err := subscriber.Subscribe(func(msg *client.Message) {
+		received <- msg
+	}, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	// 订阅是异步确认的，等待服务端完成订阅
+	waitFor(t, 3*time.Second, func() bool {
+		reply := publisher.Send(utils.ToCmdLine("PUBLISH", "a", "hello"))
+		return string(reply.ToBytes()) == ":1\r\n"
+	}, "subscription is not confirmed")
+	msg := receive(t, received)
+	if msg.Channel != "a" || string(msg.Payload) != "hello" || msg.Pattern != "" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	publisher.Send(utils.ToCmdLine("PUBLISH", "b", "world"))
+	if msg := receive(t, received); msg.Channel != "b" || string(msg.Payload) != "world" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+
+	// 退订全部频道后恢复为普通连接
+	if err := subscriber.UnSubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 3*time.Second, func() bool {
+		reply := publisher.Send(utils.ToCmdLine("PUBLISH", "a", "hello"))
+		return string(reply.ToBytes()) == ":0\r\n"
+	}, "unsubscription is not confirmed")
+	if reply := subscriber.Send(utils.ToCmdLine("SET", "k", "v")); string(reply.ToBytes()) != "+OK\r\n" {
+		t.Errorf("expect normal reply after unsubscribed, actual %q", reply.ToBytes())
+	}
+}
+
+func TestSlowHandlerDoesNotBlockReplies(t *testing.T) {
+	addr := startServer(t)
+	subscriber := startClient(t, addr)
+	publisher := startClient(t, addr)
+	release := make(chan struct{})
+	defer close(release)
+	if err := subscriber.Subscribe(func(msg *client.Message) {
+		<-release
+	}, "slow"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 3*time.Second, func() bool {
+		reply := publisher.Send(utils.ToCmdLine("PUBLISH", "slow", "0"))
+		return string(reply.ToBytes()) == ":1\r\n"
+	}, "subscription is not confirmed")
+	// 处理函数阻塞时超出队列的消息被丢弃，读取不会被阻塞
+	for i := 0; i < 1000; i++ {
+		publisher.Send(utils.ToCmdLine("PUBLISH", "slow", "m"))
+	}
+	waitFor(t, 3*time.Second, func() bool {
+		return subscriber.GetStats().Dropped > 0
+	}, "expect messages dropped")
+	if reply := subscriber.Send(utils.ToCmdLine("PING")); string(reply.ToBytes()) != "+PONG\r\n" {
+		t.Errorf("expect reply while handler is blocked, actual %q", reply.ToBytes())
+	}
+}
+
+func TestResubscribe(t *testing.T) {
+	addr := startServer(t)
+	p := startProxy(t, addr)
+	subscriber := startClient(t, p.addr())
+	publisher := startClient(t, addr)
+	received := make(chan *client.Message, 16)
+	if err := subscriber.Subscribe(func(msg *client.Message) {
+		received <- msg
+	}, "a"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 3*time.Second, func() bool {
+		reply := publisher.Send(utils.ToCmdLine("PUBLISH", "a", "before"))
+		return string(reply.ToBytes()) == ":1\r\n"
+	}, "subscription is not confirmed")
+	receive(t, received)
+
+	// 重连后在新连接上恢复订阅，旧连接可能尚未被服务端清理，以收到消息为准
+	p.drop()
+	waitFor(t, 5*time.Second, func() bool {
+		if subscriber.GetStats().Reconnects != 1 {
+			return false
+		}
+		publisher.Send(utils.ToCmdLine("PUBLISH", "a", "after"))
+		select {
+		case msg := <-received:
+			return string(msg.Payload) == "after"
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}, "subscription is not restored after reconnecting")
+}
+
+// startPushServer starts a server confirming PSUBSCRIBE and pushing one pmessage for each pattern
+// godis server does not support PSUBSCRIBE, the replies are the same as redis
+func startPushServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() {
+					_ = conn.Close()
+				}()
+				for payload := range parser.ParseStream(conn) {
+					if payload.Err != nil {
+						return
+					}
+					args := payload.Data.(*protocol.MultiBulkReply).Args
+					if strings.ToLower(string(args[0])) != "psubscribe" {
+						_, _ = conn.Write([]byte("+OK\r\n"))
+						continue
+					}
+					for i, pattern := range args[1:] {
+						confirm := "*3\r\n$10\r\npsubscribe\r\n$" + strconv.Itoa(len(pattern)) + "\r\n" +
+							string(pattern) + "\r\n:" + strconv.Itoa(i+1) + "\r\n"
+						_, _ = conn.Write([]byte(confirm))
+						channel := strings.TrimSuffix(string(pattern), "*") + "x"
+						msg := protocol.MakeMultiBulkReply(utils.ToCmdLine("pmessage", string(pattern), channel, "payload"))
+						_, _ = conn.Write(msg.ToBytes())
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestPSubscribe(t *testing.T) {
+	c := startClient(t, startPushServer(t))
+	received := make(chan *client.Message, 16)
+	if err := c.PSubscribe(func(msg *client.Message) {
+		received <- msg
+	}, "news.*", "sport.*"); err != nil {
+		t.Fatal(err)
+	}
+	patterns := make(map[string]string)
+	for i := 0; i < 2; i++ {
+		msg := receive(t, received)
+		if string(msg.Payload) != "payload" {
+			t.Errorf("unexpected message %+v", msg)
+		}
+		patterns[msg.Pattern] = msg.Channel
+	}
+	if patterns["news.*"] != "news.x" || patterns["sport.*"] != "sport.x" {
+		t.Errorf("unexpected pattern messages %v", patterns)
+	}
+	// 订阅确认不会被当作普通回复
+	if reply := c.Send(utils.ToCmdLine("PING")); string(reply.ToBytes()) != "+OK\r\n" {
+		t.Errorf("unexpected reply %q", reply.ToBytes())
+	}
+}
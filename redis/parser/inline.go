@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"bufio"
+	"errors"
+)
+
+// maxInlineSize 与 redis PROTO_INLINE_MAX_SIZE 一致，inline 命令以及各类报文的首行不能超过该长度
+const maxInlineSize = 64 * 1024
+
+var errTooBigInline = errors.New("protocol error: too big inline request")
+
+var errUnbalancedQuotes = errors.New("unbalanced quotes in request")
+
+// readLine reads a line including the delimiter, returns errTooBigInline if the line exceeds maxInlineSize
+// 与 ReadBytes 不同，没有换行符的超长输入不会无限制地占用内存
+// 状态回复和错误回复只由服务端发送，不限制长度
+func readLine(reader *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > maxInlineSize && line[0] != '+' && line[0] != '-' {
+			return nil, errTooBigInline
+		}
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\v', '\f':
+		return true
+	}
+	return false
+}
+
+func hexValue(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// splitArgs splits inline command into arguments, same as sdssplitargs of redis
+// 参数以空白字符分隔；双引号内支持 \n \r \t \b \a 等转义以及 \xhh 十六进制转义，单引号内只支持 \' 转义；
+// 闭合的引号后面必须是空白字符或行尾，否则视为引号不匹配
+func splitArgs(line []byte) ([][]byte, error) {
+	var args [][]byte
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, nil
+		}
+		arg := make([]byte, 0, 16)
+		inDoubleQuotes, inSingleQuotes := false, false
+		for done := false; !done; i++ {
+			if i == len(line) {
+				if inDoubleQuotes || inSingleQuotes {
+					return nil, errUnbalancedQuotes
+				}
+				break
+			}
+			c := line[i]
+			switch {
+			case inDoubleQuotes:
+				if c == '\\' && i+3 < len(line) && line[i+1] == 'x' {
+					high, ok1 := hexValue(line[i+2])
+					low, ok2 := hexValue(line[i+3])
+					if ok1 && ok2 {
+						arg = append(arg, high<<4|low)
+						i += 3
+						continue
+					}
+				}
+				if c == '\\' && i+1 < len(line) {
+					i++
+					c = line[i]
+					switch c {
+					case 'n':
+						c = '\n'
+					case 'r':
+						c = '\r'
+					case 't':
+						c = '\t'
+					case 'b':
+						c = '\b'
+					case 'a':
+						c = '\a'
+					}
+					arg = append(arg, c)
+				} else if c == '"' {
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, errUnbalancedQuotes
+					}
+					done = true
+				} else {
+					arg = append(arg, c)
+				}
+			case inSingleQuotes:
+				if c == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					arg = append(arg, '\'')
+				} else if c == '\'' {
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, errUnbalancedQuotes
+					}
+					done = true
+				} else {
+					arg = append(arg, c)
+				}
+			default:
+				switch {
+				case isSpace(c):
+					done = true
+				case c == '"':
+					inDoubleQuotes = true
+				case c == '\'':
+					inSingleQuotes = true
+				default:
+					arg = append(arg, c)
+				}
+			}
+		}
+		args = append(args, arg)
+	}
+}
@@ -67,7 +67,7 @@ func parser0(rawReader io.Reader, ch chan<- *Payload) {
 	for {
 		// 读取输入流中的字节，直到读取换行符为止
 		// returning a slice containing the data up to and including the delimiter.
-		line, err := reader.ReadBytes('\n')
+		line, err := readLine(reader)
 		if err != nil {
 			// 创建一个新的Payload对象，并将该对象的地址通过通道发送
 			// 错误发送完后关闭通道
@@ -77,7 +77,12 @@ func parser0(rawReader io.Reader, ch chan<- *Payload) {
 		}
 		// length := len(line)
 		// TrimSuffix 用于去除字节切片末尾的指定字符
-		line = bytes.TrimSuffix(line, []byte{'\r', '\n'})
+		// inline 命令可能只以 '\n' 结尾
+		line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte{'\n'}), []byte{'\r'})
+		if len(line) == 0 {
+			// 空行，如主节点在生成 RDB 期间发送的换行符
+			continue
+		}
 		// 对各种类型RESP的Reply，这里没有涉及，后面再补充
 		switch line[0] {
 		// 简单字符串，以'+'开头，以'\r\n'结尾，不允许换行
@@ -114,7 +119,15 @@ func parser0(rawReader io.Reader, ch chan<- *Payload) {
 				return
 			}
 		default:
-			args := bytes.Split(line, []byte{' '})
+			// inline 命令，如 telnet 或 PING 握手中发送的纯文本命令
+			args, err := splitArgs(line)
+			if err != nil {
+				protocolError(ch, err.Error())
+				continue
+			}
+			if len(args) == 0 {
+				continue
+			}
 			ch <- &Payload{
 				Data: protocol.MakeMultiBulkReply(args),
 			}
@@ -126,6 +139,17 @@ func parseBulkString(line []byte, reader *bufio.Reader, ch chan<- *Payload) erro
 	// 一个字符串报文的例子： $3\r\nSET\r\n
 	// 第一行为$加上字符串长度，第二行为字符串
 	strLen, err := strconv.ParseInt(string(line[1:]), 10, 64)
+	if err != nil || strLen < -1 {
+		protocolError(ch, "illegal bulk string header: "+string(line))
+		return nil
+	}
+	// $-1 是空字符串，如 GET 不存在的 key 的回复
+	if strLen == -1 {
+		ch <- &Payload{
+			Data: protocol.MakeNullBulkReply(),
+		}
+		return nil
+	}
 	body := make([]byte, strLen+2)
 	_, err = io.ReadFull(reader, body)
 	if err != nil {
@@ -156,7 +180,19 @@ func parseArray(header []byte, reader *bufio.Reader, ch chan<- *Payload) error {
 			return err
 		}
 		length := len(line)
+		// 订阅模式下服务端推送的数组中可能包含整数，如 subscribe 的确认消息
+		if line[0] == ':' {
+			lines = append(lines, line[1:length-2])
+			continue
+		}
 		strLen, err := strconv.ParseInt(string(line[1:length-2]), 10, 64)
+		if err != nil {
+			return err
+		}
+		if strLen < 0 {
+			lines = append(lines, nil)
+			continue
+		}
 		// 读取第一个字符串,包含结尾的'\r\n'
 		body := make([]byte, strLen+2)
 		_, err = io.ReadFull(reader, body)
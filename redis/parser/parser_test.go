@@ -14,6 +14,7 @@ func TestParseStream(t *testing.T) {
 		protocol.MakeErrReply("Unknown Error"),
 		protocol.MakeIntReply(1),
 		protocol.MakeBulkReply([]byte("a\r\nb")),
+		protocol.MakeNullBulkReply(),
 		protocol.MakeMultiBulkReply([][]byte{
 			[]byte("SET"),
 			[]byte("key"),
@@ -72,3 +73,50 @@ func BytesEquals(a []byte, b []byte) bool {
 	}
 	return true
 }
+
+func TestParseInline(t *testing.T) {
+	input := "PING\r\n" +
+		"\r\n" +
+		"\n" +
+		"  SET  key \"a b\\r\\n\\x41\"  'it\\'s'\n" +
+		"SET key \"\"\r\n" +
+		"GET \"key\r\n" +
+		"GET \"key\"x\r\n" +
+		"ECHO ok\r\n"
+	expected := []string{
+		string(protocol.MakeMultiBulkReply([][]byte{[]byte("PING")}).ToBytes()),
+		string(protocol.MakeMultiBulkReply([][]byte{[]byte("SET"), []byte("key"), []byte("a b\r\nA"), []byte("it's")}).ToBytes()),
+		string(protocol.MakeMultiBulkReply([][]byte{[]byte("SET"), []byte("key"), []byte("")}).ToBytes()),
+		"error",
+		"error",
+		string(protocol.MakeMultiBulkReply([][]byte{[]byte("ECHO"), []byte("ok")}).ToBytes()),
+	}
+	i := 0
+	for payload := range ParseStream(bytes.NewBufferString(input)) {
+		if payload.Err == io.EOF {
+			break
+		}
+		if i >= len(expected) {
+			t.Fatalf("unexpected payload %v", payload)
+		}
+		if payload.Err != nil {
+			if expected[i] != "error" {
+				t.Errorf("unexpected error %v", payload.Err)
+			}
+		} else if string(payload.Data.ToBytes()) != expected[i] {
+			t.Errorf("expect %q, actual %q", expected[i], payload.Data.ToBytes())
+		}
+		i++
+	}
+	if i != len(expected) {
+		t.Errorf("expect %d payloads, actual %d", len(expected), i)
+	}
+}
+
+func TestParseInline_TooBig(t *testing.T) {
+	input := bytes.Repeat([]byte("a"), maxInlineSize+1)
+	payload := <-ParseStream(bytes.NewReader(input))
+	if payload.Err != errTooBigInline {
+		t.Errorf("expect too big inline error, actual %v", payload.Err)
+	}
+}
@@ -0,0 +1,134 @@
+package protocol
+
+import (
+	"Godis/interface/redis"
+	"errors"
+	"strconv"
+)
+
+// 以下函数按回复的类型读取其中的值，供集群节点间通信和客户端使用，避免通过 ToBytes 的前缀判断回复的类型
+// 错误回复作为 error 返回，它本身实现了 error 接口
+
+// ErrNilReply is returned by accessors for null bulk reply
+var ErrNilReply = errors.New("nil reply")
+
+// unexpectedReply is returned by accessors when the type of reply does not match
+func unexpectedReply(reply redis.Reply) error {
+	return errors.New("unexpected reply: " + strconv.Quote(string(reply.ToBytes())))
+}
+
+// AsError returns reply itself as error if it is an error reply, otherwise returns nil
+func AsError(reply redis.Reply) error {
+	if errReply, ok := reply.(ErrorReply); ok {
+		return errReply
+	}
+	return nil
+}
+
+// AsInt returns value of integer reply, a bulk or status reply is parsed as integer
+func AsInt(reply redis.Reply) (int64, error) {
+	switch r := reply.(type) {
+	case *IntReply:
+		return r.Code, nil
+	case *BooleanReply:
+		if r.Value {
+			return 1, nil
+		}
+		return 0, nil
+	case *BulkReply, *StatusReply:
+		str, _ := AsString(r)
+		value, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return 0, unexpectedReply(reply)
+		}
+		return value, nil
+	case *NullBulkReply:
+		return 0, ErrNilReply
+	case ErrorReply:
+		return 0, r
+	}
+	return 0, unexpectedReply(reply)
+}
+
+// AsString returns content of string-like reply, such as bulk, status, integer and double reply
+func AsString(reply redis.Reply) (string, error) {
+	switch r := reply.(type) {
+	case *BulkReply:
+		return string(r.Arg), nil
+	case *StatusReply:
+		return r.Status, nil
+	case *OkReply:
+		return "OK", nil
+	case *PongReply:
+		return "PONG", nil
+	case *QueuedReply:
+		return "QUEUED", nil
+	case *IntReply:
+		return strconv.FormatInt(r.Code, 10), nil
+	case *DoubleReply:
+		return formatDouble(r.Value), nil
+	case *BigNumberReply:
+		return r.Value.String(), nil
+	case *VerbatimStringReply:
+		return string(r.Text), nil
+	case *NullBulkReply:
+		return "", ErrNilReply
+	case ErrorReply:
+		return "", r
+	}
+	return "", unexpectedReply(reply)
+}
+
+// AsArray returns elements of array reply, elements of MultiBulkReply are converted into BulkReply or NullBulkReply
+func AsArray(reply redis.Reply) ([]redis.Reply, error) {
+	switch r := reply.(type) {
+	case *MultiRawReply:
+		return r.Replies, nil
+	case *MultiBulkReply:
+		replies := make([]redis.Reply, len(r.Args))
+		for i, arg := range r.Args {
+			if arg == nil {
+				replies[i] = MakeNullBulkReply()
+			} else {
+				replies[i] = MakeBulkReply(arg)
+			}
+		}
+		return replies, nil
+	case *EmptyMultiBulkReply:
+		return []redis.Reply{}, nil
+	case ErrorReply:
+		return nil, r
+	}
+	return nil, unexpectedReply(reply)
+}
+
+// AsBulkArray returns elements of array reply as bytes, nil element is kept as nil
+// MultiRawReply is accepted only if all elements are string-like
+func AsBulkArray(reply redis.Reply) ([][]byte, error) {
+	switch r := reply.(type) {
+	case *MultiBulkReply:
+		return r.Args, nil
+	case *MultiRawReply:
+		args := make([][]byte, len(r.Replies))
+		for i, element := range r.Replies {
+			if _, ok := element.(*NullBulkReply); ok {
+				continue
+			}
+			if bulk, ok := element.(*BulkReply); ok {
+				args[i] = bulk.Arg
+				continue
+			}
+			str, err := AsString(element)
+			if err != nil {
+				return nil, unexpectedReply(reply)
+			}
+			args[i] = []byte(str)
+		}
+		return args, nil
+	case *EmptyMultiBulkReply:
+		return [][]byte{}, nil
+	case ErrorReply:
+		return nil, r
+	}
+	return nil, unexpectedReply(reply)
+}
@@ -0,0 +1,93 @@
+package protocol
+
+import (
+	"Godis/interface/redis"
+	"testing"
+)
+
+func TestAsInt(t *testing.T) {
+	for _, reply := range []redis.Reply{MakeIntReply(42), MakeBulkReply([]byte("42")), MakeStatusReply("42")} {
+		if n, err := AsInt(reply); err != nil || n != 42 {
+			t.Errorf("expect 42, actual %d %v", n, err)
+		}
+	}
+	if n, err := AsInt(MakeBooleanReply(true)); err != nil || n != 1 {
+		t.Errorf("expect 1, actual %d %v", n, err)
+	}
+	if _, err := AsInt(MakeNullBulkReply()); err != ErrNilReply {
+		t.Errorf("expect ErrNilReply, actual %v", err)
+	}
+	if _, err := AsInt(MakeErrReply("ERR x")); err == nil || err.Error() != "ERR x" {
+		t.Errorf("expect error reply, actual %v", err)
+	}
+	for _, reply := range []redis.Reply{MakeBulkReply([]byte("a")), MakeEmptyMultiBulkReply()} {
+		if _, err := AsInt(reply); err == nil {
+			t.Errorf("expect error for %q", reply.ToBytes())
+		}
+	}
+}
+
+func TestAsString(t *testing.T) {
+	cases := []struct {
+		reply    redis.Reply
+		expected string
+	}{
+		{MakeBulkReply([]byte("a\r\nb")), "a\r\nb"},
+		{MakeStatusReply("OK"), "OK"},
+		{MakeOkReply(), "OK"},
+		{&PongReply{}, "PONG"},
+		{MakeIntReply(-1), "-1"},
+		{MakeDoubleReply(1.5), "1.5"},
+		{MakeVerbatimStringReply([]byte("text")), "text"},
+	}
+	for _, c := range cases {
+		if actual, err := AsString(c.reply); err != nil || actual != c.expected {
+			t.Errorf("expect %q, actual %q %v", c.expected, actual, err)
+		}
+	}
+	if _, err := AsString(MakeNullBulkReply()); err != ErrNilReply {
+		t.Errorf("expect ErrNilReply, actual %v", err)
+	}
+	if _, err := AsString(MakeMultiBulkReply(nil)); err == nil {
+		t.Error("expect error for array")
+	}
+}
+
+func TestAsArray(t *testing.T) {
+	replies, err := AsArray(MakeMultiBulkReply([][]byte{[]byte("a"), nil}))
+	if err != nil || len(replies) != 2 {
+		t.Fatalf("unexpected result %v %v", replies, err)
+	}
+	if str, _ := AsString(replies[0]); str != "a" {
+		t.Errorf("expect a, actual %s", str)
+	}
+	if _, err = AsString(replies[1]); err != ErrNilReply {
+		t.Errorf("expect nil element, actual %v", err)
+	}
+	if replies, err = AsArray(MakeEmptyMultiBulkReply()); err != nil || len(replies) != 0 {
+		t.Errorf("expect empty array, actual %v %v", replies, err)
+	}
+	if _, err = AsArray(&WrongTypeErrReply{}); err == nil {
+		t.Error("expect error reply")
+	}
+
+	args, err := AsBulkArray(MakeMultiRawReply([]redis.Reply{MakeBulkReply([]byte("a")), MakeNullBulkReply(), MakeIntReply(1)}))
+	if err != nil || len(args) != 3 || string(args[0]) != "a" || args[1] != nil || string(args[2]) != "1" {
+		t.Errorf("unexpected result %q %v", args, err)
+	}
+	if _, err = AsBulkArray(MakeMultiRawReply([]redis.Reply{MakeEmptyMultiBulkReply()})); err == nil {
+		t.Error("expect error for nested array")
+	}
+}
+
+func TestIsOKReply(t *testing.T) {
+	if !IsOKReply(MakeOkReply()) || !IsOKReply(MakeStatusReply("OK")) || IsOKReply(MakeStatusReply("QUEUED")) {
+		t.Error("unexpected IsOKReply")
+	}
+	if !IsErrorReply(MakeSyntaxErrReply()) || IsErrorReply(MakeStatusReply("ERR")) {
+		t.Error("unexpected IsErrorReply")
+	}
+	if !IsEmptyMultiBulkReply(MakeMultiBulkReply(nil)) || IsEmptyMultiBulkReply(MakeNullBulkReply()) {
+		t.Error("unexpected IsEmptyMultiBulkReply")
+	}
+}
@@ -2,7 +2,6 @@ package protocol
 
 import (
 	"Godis/interface/redis"
-	"bytes"
 )
 
 // PongReply is +PONG
@@ -62,8 +61,17 @@ func MakeEmptyMultiBulkReply() *EmptyMultiBulkReply {
 	return &EmptyMultiBulkReply{}
 }
 
+// IsEmptyMultiBulkReply returns true if reply is an empty array, including empty MultiBulkReply received by client
 func IsEmptyMultiBulkReply(reply redis.Reply) bool {
-	return bytes.Equal(reply.ToBytes(), emptyMultiBulkBytes)
+	switch r := reply.(type) {
+	case *EmptyMultiBulkReply:
+		return true
+	case *MultiBulkReply:
+		return len(r.Args) == 0
+	case *MultiRawReply:
+		return len(r.Replies) == 0
+	}
+	return false
 }
 
 // NoReply respond nothing, for commands like subscribe
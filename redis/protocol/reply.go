@@ -138,12 +138,19 @@ func (r *MultiRawReply) ToBytes() []byte {
 
 // IsErrorReply returns true if the given protocol is error
 func IsErrorReply(reply redis.Reply) bool {
-	return reply.ToBytes()[0] == '-'
+	_, ok := reply.(ErrorReply)
+	return ok
 }
 
-// IsOKReply returns true if the given protocol is +OK
+// IsOKReply returns true if the given protocol is +OK, including status reply OK received by client
 func IsOKReply(reply redis.Reply) bool {
-	return string(reply.ToBytes()) == "+OK\r\n"
+	switch r := reply.(type) {
+	case *OkReply:
+		return true
+	case *StatusReply:
+		return r.Status == "OK"
+	}
+	return false
 }
 
 // StandardErrReply represents server error
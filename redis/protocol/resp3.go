@@ -0,0 +1,140 @@
+package protocol
+
+import (
+	"Godis/interface/redis"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// 以下回复在 RESP3 中有独立的类型，ToBytes 返回 RESP2 中与 redis 一致的替代形式
+
+/* ---- Double Reply ---- */
+
+// DoubleReply is a float number, RESP2 fallback is a bulk string
+type DoubleReply struct {
+	Value float64
+}
+
+// MakeDoubleReply creates DoubleReply
+func MakeDoubleReply(value float64) *DoubleReply {
+	return &DoubleReply{
+		Value: value,
+	}
+}
+
+// formatDouble 与 redis 一致，无穷大和 NaN 表示为 inf、-inf 和 nan
+func formatDouble(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "inf"
+	case math.IsInf(value, -1):
+		return "-inf"
+	case math.IsNaN(value):
+		return "nan"
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// ToBytes marshal redis.Reply
+func (r *DoubleReply) ToBytes() []byte {
+	value := formatDouble(r.Value)
+	return []byte("$" + strconv.Itoa(len(value)) + CRLF + value + CRLF)
+}
+
+// ToRESP3Bytes marshal reply in RESP3
+func (r *DoubleReply) ToRESP3Bytes() []byte {
+	return []byte("," + formatDouble(r.Value) + CRLF)
+}
+
+/* ---- Boolean Reply ---- */
+
+// BooleanReply is true or false, RESP2 fallback is integer 1 or 0
+type BooleanReply struct {
+	Value bool
+}
+
+// MakeBooleanReply creates BooleanReply
+func MakeBooleanReply(value bool) *BooleanReply {
+	return &BooleanReply{
+		Value: value,
+	}
+}
+
+// ToBytes marshal redis.Reply
+func (r *BooleanReply) ToBytes() []byte {
+	if r.Value {
+		return []byte(":1" + CRLF)
+	}
+	return []byte(":0" + CRLF)
+}
+
+// ToRESP3Bytes marshal reply in RESP3
+func (r *BooleanReply) ToRESP3Bytes() []byte {
+	if r.Value {
+		return []byte("#t" + CRLF)
+	}
+	return []byte("#f" + CRLF)
+}
+
+/* ---- Big Number Reply ---- */
+
+// BigNumberReply is an integer out of the range of int64, RESP2 fallback is a bulk string
+type BigNumberReply struct {
+	Value *big.Int
+}
+
+// MakeBigNumberReply creates BigNumberReply
+func MakeBigNumberReply(value *big.Int) *BigNumberReply {
+	return &BigNumberReply{
+		Value: value,
+	}
+}
+
+// ToBytes marshal redis.Reply
+func (r *BigNumberReply) ToBytes() []byte {
+	value := r.Value.String()
+	return []byte("$" + strconv.Itoa(len(value)) + CRLF + value + CRLF)
+}
+
+// ToRESP3Bytes marshal reply in RESP3
+func (r *BigNumberReply) ToRESP3Bytes() []byte {
+	return []byte("(" + r.Value.String() + CRLF)
+}
+
+/* ---- Verbatim String Reply ---- */
+
+// VerbatimStringReply is a string with its format, such as the text returned by INFO, RESP2 fallback is a bulk string
+type VerbatimStringReply struct {
+	// Format is exactly three bytes, txt for plain text and mkd for markdown
+	Format string
+	Text   []byte
+}
+
+// MakeVerbatimStringReply creates VerbatimStringReply of plain text
+func MakeVerbatimStringReply(text []byte) *VerbatimStringReply {
+	return &VerbatimStringReply{
+		Format: "txt",
+		Text:   text,
+	}
+}
+
+// ToBytes marshal redis.Reply
+func (r *VerbatimStringReply) ToBytes() []byte {
+	return MakeBulkReply(r.Text).ToBytes()
+}
+
+// ToRESP3Bytes marshal reply in RESP3
+func (r *VerbatimStringReply) ToRESP3Bytes() []byte {
+	// 长度包括格式和冒号
+	return []byte("=" + strconv.Itoa(len(r.Format)+1+len(r.Text)) + CRLF + r.Format + ":" + string(r.Text) + CRLF)
+}
+
+// ToRESP3Bytes marshal reply in RESP3 if it has its own type in RESP3, otherwise returns ToBytes
+// 只转换最外层的回复，MultiRawReply 中的元素仍然使用 RESP2
+func ToRESP3Bytes(reply redis.Reply) []byte {
+	if r, ok := reply.(redis.RESP3Reply); ok {
+		return r.ToRESP3Bytes()
+	}
+	return reply.ToBytes()
+}
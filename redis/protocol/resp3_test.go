@@ -0,0 +1,38 @@
+package protocol
+
+import (
+	"Godis/interface/redis"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestRESP3Replies(t *testing.T) {
+	bigNumber, _ := new(big.Int).SetString("3492890328409238509324850943850943825024385", 10)
+	cases := []struct {
+		reply redis.Reply
+		resp2 string
+		resp3 string
+	}{
+		{MakeDoubleReply(1.5), "$3\r\n1.5\r\n", ",1.5\r\n"},
+		{MakeDoubleReply(10), "$2\r\n10\r\n", ",10\r\n"},
+		{MakeDoubleReply(math.Inf(1)), "$3\r\ninf\r\n", ",inf\r\n"},
+		{MakeDoubleReply(math.Inf(-1)), "$4\r\n-inf\r\n", ",-inf\r\n"},
+		{MakeDoubleReply(math.NaN()), "$3\r\nnan\r\n", ",nan\r\n"},
+		{MakeBooleanReply(true), ":1\r\n", "#t\r\n"},
+		{MakeBooleanReply(false), ":0\r\n", "#f\r\n"},
+		{MakeBigNumberReply(bigNumber), "$43\r\n3492890328409238509324850943850943825024385\r\n",
+			"(3492890328409238509324850943850943825024385\r\n"},
+		{MakeVerbatimStringReply([]byte("Some string")), "$11\r\nSome string\r\n", "=15\r\ntxt:Some string\r\n"},
+		// 没有 RESP3 类型的回复保持不变
+		{MakeIntReply(1), ":1\r\n", ":1\r\n"},
+	}
+	for _, c := range cases {
+		if actual := string(c.reply.ToBytes()); actual != c.resp2 {
+			t.Errorf("resp2: expect %q, actual %q", c.resp2, actual)
+		}
+		if actual := string(ToRESP3Bytes(c.reply)); actual != c.resp3 {
+			t.Errorf("resp3: expect %q, actual %q", c.resp3, actual)
+		}
+	}
+}